@@ -0,0 +1,202 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// -----------------------------------------------------------------------------
+// EmergencyBlock - Schema Registration
+// -----------------------------------------------------------------------------
+
+func init() {
+	SchemeBuilder.Register(&EmergencyBlock{}, &EmergencyBlockList{})
+}
+
+// -----------------------------------------------------------------------------
+// EmergencyBlock
+// -----------------------------------------------------------------------------
+
+// EmergencyBlock blocks requests from client addresses, for a path, or
+// matching a pattern, on the Engines it selects, for a limited time. It is
+// meant for incident response: the block is added to the RuleSets of the
+// selected Engines ahead of every other rule, without editing them and
+// without the RuleSource debounce window, and expires on its own once its
+// TTL has elapsed.
+//
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=eb
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Expires",type=string,JSONPath=`.status.expiresAt`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type EmergencyBlock struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata.
+	//
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitzero"`
+
+	// spec defines the requests to block and for how long.
+	//
+	// +required
+	Spec EmergencyBlockSpec `json:"spec,omitzero"`
+
+	// status defines the observed state of EmergencyBlock.
+	//
+	// +optional
+	Status EmergencyBlockStatus `json:"status,omitzero"`
+}
+
+// EmergencyBlockList contains a list of EmergencyBlock resources.
+//
+// +kubebuilder:object:root=true
+type EmergencyBlockList struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// ListMeta is standard list metadata.
+	//
+	// +optional
+	metav1.ListMeta `json:"metadata,omitzero"`
+
+	// Items is the list of EmergencyBlocks.
+	//
+	// +required
+	Items []EmergencyBlock `json:"items"`
+}
+
+// -----------------------------------------------------------------------------
+// EmergencyBlock - Spec
+// -----------------------------------------------------------------------------
+
+// EmergencyBlockSpec defines the requests to block and for how long.
+// Exactly one of clientIPs, path and pattern must be set.
+//
+// +kubebuilder:validation:XValidation:rule="[has(self.clientIPs), has(self.path), has(self.pattern)].filter(x, x).size() == 1",message="exactly one of clientIPs, path and pattern must be set"
+type EmergencyBlockSpec struct {
+	// engineSelector selects the Engines in the same namespace the block
+	// applies to, by their labels. An empty selector selects every Engine
+	// in the namespace. The block is added to the RuleSets of the selected
+	// Engines, so it also applies to other Engines sharing these RuleSets.
+	//
+	// +required
+	EngineSelector *metav1.LabelSelector `json:"engineSelector,omitempty"`
+
+	// clientIPs blocks requests from these client addresses and CIDR
+	// ranges.
+	//
+	// +optional
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=64
+	// +kubebuilder:validation:items:MaxLength=43
+	// +kubebuilder:validation:items:Pattern=`^[0-9A-Fa-f:.]+(/[0-9]{1,3})?$`
+	// +listType=set
+	ClientIPs []string `json:"clientIPs,omitempty"`
+
+	// path blocks requests whose path starts with this prefix.
+	//
+	// +optional
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=1024
+	// +kubebuilder:validation:Pattern=`^/[^\s"'\\%]*$`
+	Path string `json:"path,omitempty"`
+
+	// pattern blocks requests whose URI, including the query string,
+	// matches this RE2 regular expression.
+	//
+	// +optional
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=256
+	// +kubebuilder:validation:Pattern=`^[^\s"']+$`
+	Pattern string `json:"pattern,omitempty"`
+
+	// ttlSeconds is how long the block lasts, from the creation of the
+	// EmergencyBlock. Once elapsed, the block is removed from the RuleSets
+	// and the EmergencyBlock is kept as a record in the Expired phase.
+	// It may be changed to extend or shorten an active block.
+	//
+	// +required
+	// +kubebuilder:validation:Minimum=60
+	// +kubebuilder:validation:Maximum=604800
+	TTLSeconds int32 `json:"ttlSeconds,omitempty"`
+
+	// reason explains the block, such as a reference to the incident.
+	//
+	// +optional
+	// +kubebuilder:validation:MaxLength=1024
+	Reason string `json:"reason,omitempty"`
+}
+
+// -----------------------------------------------------------------------------
+// EmergencyBlock - Status
+// -----------------------------------------------------------------------------
+
+// EmergencyBlockPhase is whether an EmergencyBlock is in effect.
+//
+// +kubebuilder:validation:Enum=Active;Expired
+type EmergencyBlockPhase string
+
+const (
+	// EmergencyBlockPhaseActive is the phase of a block whose TTL has not
+	// elapsed yet.
+	EmergencyBlockPhaseActive EmergencyBlockPhase = "Active"
+
+	// EmergencyBlockPhaseExpired is the phase of a block whose TTL has
+	// elapsed.
+	EmergencyBlockPhaseExpired EmergencyBlockPhase = "Expired"
+)
+
+// EmergencyBlockStatus defines the observed state of EmergencyBlock.
+// +kubebuilder:validation:MinProperties=0
+type EmergencyBlockStatus struct {
+	// conditions represent the current state of the EmergencyBlock resource.
+	//
+	// Standard condition types include:
+	// - "Ready": the block is in effect on the RuleSets of the selected
+	//   Engines
+	// - "Degraded": the block is invalid and not in effect
+	//
+	// +listType=map
+	// +listMapKey=type
+	// +patchStrategy=merge
+	// +patchMergeKey=type
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=16
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+
+	// phase is whether the block is in effect.
+	//
+	// +optional
+	Phase EmergencyBlockPhase `json:"phase,omitempty"`
+
+	// expiresAt is when the TTL of the block elapses.
+	//
+	// +optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// ruleSets are the names of the RuleSets of the selected Engines, which
+	// the block is added to while it is active.
+	//
+	// +optional
+	// +kubebuilder:validation:MaxItems=256
+	// +kubebuilder:validation:items:MaxLength=253
+	// +listType=set
+	RuleSets []string `json:"ruleSets,omitempty"`
+}
@@ -70,7 +70,8 @@ const (
 type WasmDriverConfig struct {
 	// image is the OCI image reference for the Coraza WASM plugin.
 	// If omitted the operator uses its configured default WASM OCI reference
-	// (--default-wasm-image / CORAZA_DEFAULT_WASM_IMAGE).
+	// (OperatorConfig spec.defaultWasmImage, or --default-wasm-image /
+	// CORAZA_DEFAULT_WASM_IMAGE).
 	//
 	// +optional
 	// +kubebuilder:validation:MinLength=1
@@ -16,18 +16,28 @@ limitations under the License.
 
 package v1alpha1
 
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
 // -----------------------------------------------------------------------------
 // Engine - Target
 // -----------------------------------------------------------------------------
 
 // EngineTarget identifies the workload that the Engine protects.
 //
-// +kubebuilder:validation:XValidation:rule="self.type == 'Gateway' ? has(self.name) : true",message="name is required when type is Gateway"
-// +kubebuilder:validation:XValidation:rule="self.provider == 'Istio' ? self.type == 'Gateway' : true",message="provider \"Istio\" is only supported when target type is Gateway"
+// +kubebuilder:validation:XValidation:rule="has(self.name) != has(self.selector)",message="exactly one of name and selector must be set"
+// +kubebuilder:validation:XValidation:rule="has(self.selector) ? self.type == 'Gateway' : true",message="selector is only supported when target type is Gateway"
+// +kubebuilder:validation:XValidation:rule="self.provider == 'Istio' ? self.type in ['Gateway', 'IngressGateway'] : true",message="provider \"Istio\" is only supported when target type is Gateway or IngressGateway"
+// +kubebuilder:validation:XValidation:rule="has(self.sectionName) ? self.type == 'Gateway' : true",message="sectionName is only supported when target type is Gateway"
 type EngineTarget struct {
-	// type is the type of resource being targeted.
+	// type is the type of resource being targeted:
 	//
-	// Currently only supports "Gateway" mode, utilizing Gateway API resources.
+	// - "Gateway": a Gateway API Gateway
+	// - "IngressGateway": an Istio ingress gateway deployment that is not
+	//   managed through the Gateway API, such as the istio-ingressgateway
+	//   serving Istio Gateway resources, Ingresses with the "istio" class,
+	//   and Knative Serving routes through net-istio
 	//
 	// +required
 	Type EngineTargetType `json:"type,omitempty"`
@@ -35,7 +45,9 @@ type EngineTarget struct {
 	// name is the name of the target resource in the same namespace as the
 	// Engine. For Gateway targets, the operator derives the workload selector
 	// from this name using the GEP-1762 convention
-	// (gateway.networking.k8s.io/gateway-name label).
+	// (gateway.networking.k8s.io/gateway-name label). For IngressGateway
+	// targets, name is the value of the "istio" label of the gateway pods,
+	// such as "ingressgateway".
 	//
 	// Must conform to RFC 1035 label syntax: lowercase alphanumeric or
 	// hyphens, must start with a letter and end with an alphanumeric
@@ -43,12 +55,41 @@ type EngineTarget struct {
 	// rules and ensures compatibility with Gateway implementations that
 	// derive Service names from the Gateway name.
 	//
-	// +required
+	// Exactly one of name and selector must be set.
+	//
+	// +optional
 	// +kubebuilder:validation:MinLength=1
 	// +kubebuilder:validation:MaxLength=63
 	// +kubebuilder:validation:XValidation:rule="!format.dns1035Label().validate(self).hasValue()",message="name must be a valid DNS-1035 label (lowercase, starts with a letter)"
 	Name string `json:"name,omitempty"`
 
+	// sectionName is the name of a listener of the target Gateway. When set,
+	// the WAF only inspects the traffic the gateway receives on the port of
+	// that listener, which includes the other listeners sharing the port.
+	// The Engine still claims the whole Gateway, so other Engines cannot
+	// target its other listeners. When the Gateway has no such listener, the
+	// Engine is not accepted.
+	//
+	// +optional
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?(\.[a-z0-9]([-a-z0-9]*[a-z0-9])?)*$`
+	SectionName string `json:"sectionName,omitempty"`
+
+	// selector selects Gateways in the same namespace as the Engine by their
+	// labels, so that one Engine protects a fleet of Gateways. The operator
+	// creates one Engine per selected Gateway, named after this Engine and
+	// the Gateway, with the spec of this Engine and the Gateway as target,
+	// and reports them in status.targets. Each of them is accepted, or not,
+	// like any other Engine, and is deleted when its Gateway is no longer
+	// selected. An empty selector selects every Gateway of the namespace.
+	// At most 64 Gateways are selected, in name order.
+	//
+	// Only supported when type is Gateway.
+	//
+	// +optional
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+
 	// provider identifies the infrastructure provider that manages the
 	// target workload. The provider determines which driver types are
 	// valid for the Engine.
@@ -80,12 +121,17 @@ type EngineTarget struct {
 
 // EngineTargetType specifies the type of resource an Engine targets.
 //
-// +kubebuilder:validation:Enum=Gateway
+// +kubebuilder:validation:Enum=Gateway;IngressGateway
 type EngineTargetType string
 
 const (
 	// EngineTargetTypeGateway targets a Gateway API Gateway resource.
 	EngineTargetTypeGateway EngineTargetType = "Gateway"
+
+	// EngineTargetTypeIngressGateway targets the Istio ingress gateway pods
+	// labeled istio=<name>, for ingress that is not managed through the
+	// Gateway API.
+	EngineTargetTypeIngressGateway EngineTargetType = "IngressGateway"
 )
 
 // -----------------------------------------------------------------------------
@@ -35,13 +35,18 @@ func init() {
 // Engine represents an instance of a Web Application Firewall (WAF) engine.
 //
 // +kubebuilder:object:root=true
+// +kubebuilder:storageversion
 // +kubebuilder:subresource:status
 // +kubebuilder:printcolumn:name="RuleSet",type=string,JSONPath=`.spec.ruleSet.name`
 // +kubebuilder:printcolumn:name="Provider",type=string,JSONPath=`.spec.target.provider`
 // +kubebuilder:printcolumn:name="Target Type",type=string,JSONPath=`.spec.target.type`
 // +kubebuilder:printcolumn:name="Target Name",type=string,JSONPath=`.spec.target.name`
 // +kubebuilder:printcolumn:name="Failure Policy",type=string,JSONPath=`.spec.failurePolicy`
+// +kubebuilder:printcolumn:name="Active RuleSet",type=string,JSONPath=`.status.activeRuleSet`,priority=1
+// +kubebuilder:printcolumn:name="Mode",type=string,JSONPath=`.status.enforcementMode`
 // +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Revision",type=string,JSONPath=`.status.dataPlane.revision`,priority=1
+// +kubebuilder:printcolumn:name="Last Heartbeat",type=date,JSONPath=`.status.dataPlane.lastHeartbeatTime`
 // +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 type Engine struct {
 	metav1.TypeMeta `json:",inline"`
@@ -86,6 +91,15 @@ type EngineList struct {
 // EngineSpec defines the desired state of an Engine.
 //
 // +kubebuilder:validation:XValidation:rule="!has(self.driver) || !has(self.driver.type) || (self.target.provider == 'Istio' && self.driver.type == 'wasm')",message="driver type must be compatible with the target provider (Istio supports wasm)"
+// +kubebuilder:validation:XValidation:rule="!has(self.learning) || has(self.ruleSetCacheServer)",message="learning requires ruleSetCacheServer"
+// +kubebuilder:validation:XValidation:rule="(has(self.failurePolicy) && self.failurePolicy == 'degrade') == has(self.fallbackRuleSet)",message="fallbackRuleSet must be set if and only if failurePolicy is degrade"
+// +kubebuilder:validation:XValidation:rule="!has(self.responseInspection)",message="responseInspection is not supported yet: no qualified WASM plugin release supports response inspection"
+// +kubebuilder:validation:XValidation:rule="!has(self.redaction)",message="redaction is not supported yet: no qualified WASM plugin release supports audit log redaction"
+// +kubebuilder:validation:XValidation:rule="!has(self.verdictMetadata)",message="verdictMetadata is not supported yet: no qualified WASM plugin release writes the verdict into the dynamic metadata"
+// +kubebuilder:validation:XValidation:rule="!has(self.requestCorrelation)",message="requestCorrelation is not supported yet: no qualified WASM plugin release records correlation headers in its audit log"
+// +kubebuilder:validation:XValidation:rule="!has(self.blockResponse)",message="blockResponse is not supported yet: no qualified WASM plugin release renders block responses"
+// +kubebuilder:validation:XValidation:rule="!has(self.rateLimit)",message="rateLimit is not supported yet: no qualified WASM plugin release supports rate limiting"
+// +kubebuilder:validation:XValidation:rule="!has(self.learning)",message="learning is not supported yet: no qualified WASM plugin release reports rule matches or switches the rule engine mode"
 type EngineSpec struct {
 	// ruleSet specifies the RuleSet resource that will be used to load rules
 	// into the Engine. The referenced RuleSet must be in the same namespace
@@ -106,6 +120,8 @@ type EngineSpec struct {
 	//
 	// - "Fail": Block traffic when the WAF is not ready or encounters errors
 	// - "Allow": Allow traffic through when the WAF is not ready or encounters errors
+	// - "Degrade": Load the rules of fallbackRuleSet when those of ruleSet
+	//   cannot be loaded, and block traffic when neither can
 	//
 	// When omitted, this means the user has no opinion and the platform
 	// will choose a reasonable default, which is subject to change over time.
@@ -116,6 +132,60 @@ type EngineSpec struct {
 	// +default="fail"
 	FailurePolicy FailurePolicy `json:"failurePolicy,omitempty"`
 
+	// fallbackRuleSet specifies the RuleSet, such as a minimal set of
+	// emergency rules, whose rules the Engine loads with failurePolicy
+	// degrade while those of ruleSet cannot be loaded: while the RuleSet
+	// does not exist, or is Degraded before it ever published a revision of
+	// its rules. A Degraded RuleSet that published a revision keeps serving
+	// it, so the Engine keeps using it. The referenced RuleSet must be in
+	// the same namespace as the Engine. status.activeRuleSet reports which
+	// RuleSet the Engine uses.
+	//
+	// +optional
+	FallbackRuleSet *RuleSetReference `json:"fallbackRuleSet,omitempty"`
+
+	// failurePolicyDowngrade temporarily switches a failurePolicy of fail
+	// to allow while the Engine is Degraded, so that a broken WAF does not
+	// block the traffic of the gateway for long. The failure policy is
+	// restored when the Engine recovers or when the downgrade expires,
+	// whichever comes first, and is not downgraded again until the Engine
+	// has recovered.
+	//
+	// When omitted, the Engine keeps failing closed for as long as it is
+	// Degraded.
+	//
+	// +optional
+	FailurePolicyDowngrade *FailurePolicyDowngrade `json:"failurePolicyDowngrade,omitempty"`
+
+	// mode determines whether the Engine blocks the requests matching its
+	// rules. Valid values are:
+	//
+	// - "Enforce": Block the requests matching the rules
+	// - "DetectionOnly": Evaluate and log the rules, but never block, so that
+	//   new rules can be audited against real traffic before they are enforced
+	//
+	// DetectionOnly is reserved: it is rejected until a qualified WASM plugin
+	// release supports switching the rule engine mode.
+	//
+	// When omitted, this means the user has no opinion and the platform
+	// will choose a reasonable default, which is subject to change over time.
+	//
+	// The current default is Enforce.
+	//
+	// +optional
+	// +default="Enforce"
+	// +kubebuilder:validation:XValidation:rule="self != 'DetectionOnly'",message="DetectionOnly is not supported yet: no qualified WASM plugin release supports switching the rule engine mode"
+	Mode EngineMode `json:"mode,omitempty"`
+
+	// schedule switches the mode of the Engine during recurring time
+	// windows, such as DetectionOnly during business hours while a
+	// migration is rolled out and Enforce overnight. Outside of its windows,
+	// the Engine uses mode. Learning mode takes precedence over the
+	// schedule.
+	//
+	// +optional
+	Schedule *EngineSchedule `json:"schedule,omitempty"`
+
 	// ruleSetCacheServer contains configuration for the ruleset cache server.
 	//
 	// When omitted, no cache server will be used and no rulesets will be
@@ -131,6 +201,147 @@ type EngineSpec struct {
 	//
 	// +optional
 	Driver DriverConfig `json:"driver,omitempty,omitzero"`
+
+	// responseInspection enables the inspection of responses, so that rules
+	// in the response headers (3) and response body (4) phases take effect,
+	// for example to detect data leaks.
+	//
+	// When omitted, responses are not inspected and rules in these phases
+	// never match.
+	//
+	// responseInspection is reserved: it is rejected until a qualified WASM
+	// plugin release supports response inspection.
+	//
+	// +optional
+	ResponseInspection *ResponseInspection `json:"responseInspection,omitempty"`
+
+	// inspectionBypass turns the rule engine off for requests that are
+	// expensive to inspect and rarely carry attacks, such as video uploads
+	// and large files, or that must never be blocked, such as health
+	// checks, so that they do not pay the inspection latency of the WASM
+	// plugin. The bypass is compiled into SecRules that are composed into
+	// the RuleSet of the Engine, before its rules, and run in phase 1.
+	//
+	// The bypass applies to every Engine loading the RuleSet, so the Engines
+	// using a RuleSet must configure the same bypass; otherwise the RuleSet
+	// is degraded and keeps serving its previous rules.
+	//
+	// +optional
+	InspectionBypass *InspectionBypass `json:"inspectionBypass,omitempty"`
+
+	// ruleExclusions remove rules, or some of the request variables they
+	// inspect, from every transaction of this Engine, so that the team
+	// owning the Engine can suppress false positives without editing the
+	// RuleSources of the RuleSet. They are compiled into SecRules that are
+	// composed into the RuleSet of the Engine, before its rules, and run in
+	// phase 1.
+	//
+	// The exclusions apply to every Engine loading the RuleSet, so the
+	// Engines using a RuleSet must configure the same exclusions; otherwise
+	// the RuleSet is degraded and keeps serving its previous rules.
+	//
+	// +optional
+	// +listType=atomic
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=64
+	RuleExclusions []RuleExclusion `json:"ruleExclusions,omitempty"`
+
+	// learning runs the Engine in learning mode: for the configured
+	// duration, rules only log (detection mode) and the Engine reports which
+	// rules match. All traffic seen while learning is presumed legitimate;
+	// at the end, the operator generates a draft RuleSource removing the
+	// rules that matched it, for review and approval.
+	//
+	// Learning reports matches to the ruleset cache server, so it requires
+	// ruleSetCacheServer.
+	//
+	// learning is reserved: it is rejected until a qualified WASM plugin
+	// release reports rule matches and switches the rule engine mode.
+	//
+	// +optional
+	Learning *LearningConfig `json:"learning,omitempty"`
+
+	// redaction masks sensitive request data in the audit log the Engine
+	// writes, so that it can be enabled where credentials and personal data
+	// must not be logged. The masked values are still inspected by the
+	// rules.
+	//
+	// redaction is reserved: it is rejected until a qualified WASM plugin
+	// release supports it, so that no Engine appears to redact an audit log
+	// that is written in full.
+	//
+	// +optional
+	Redaction *Redaction `json:"redaction,omitempty"`
+
+	// accessLog enables the access log of the targeted gateway through a
+	// generated Istio Telemetry resource, and tags the traces of the gateway
+	// with the Engine, RuleSet and rules revision, so that existing log
+	// pipelines get the WAF context without manual mesh configuration.
+	//
+	// When omitted, the operator does not change the telemetry of the
+	// gateway. Requires the Istio Telemetry API.
+	//
+	// +optional
+	AccessLog *AccessLog `json:"accessLog,omitempty"`
+
+	// verdictMetadata has the WASM plugin write its verdict and the IDs of
+	// the rules that matched into the Envoy dynamic metadata of each
+	// request, so that the filters running after it, such as rate limiters
+	// and external authorization, and the access log can react to WAF
+	// decisions. With metricLabel, the generated Istio Telemetry also labels
+	// the request metrics of the gateway with the verdict.
+	//
+	// verdictMetadata is reserved: it is rejected until a qualified WASM
+	// plugin release writes the verdict into the dynamic metadata.
+	//
+	// +optional
+	VerdictMetadata *VerdictMetadata `json:"verdictMetadata,omitempty"`
+
+	// requestCorrelation has the WASM plugin record the request ID and
+	// trace context headers of each request in its audit log entries, so
+	// that a blocked request can be found in the distributed traces and
+	// application logs of the same request during an investigation.
+	//
+	// requestCorrelation is reserved: it is rejected until a qualified WASM
+	// plugin release records the headers in its audit log.
+	//
+	// +optional
+	RequestCorrelation *RequestCorrelation `json:"requestCorrelation,omitempty"`
+
+	// blockResponse is the response body the WASM plugin sends for blocked
+	// requests, instead of an empty body, so that users get an informative
+	// page without a separate error service. The body is a template whose
+	// placeholders the plugin renders for each request, and can be
+	// localized after the Accept-Language header of the request.
+	//
+	// blockResponse is reserved: it is rejected until a qualified WASM
+	// plugin release renders block responses.
+	//
+	// +optional
+	BlockResponse *BlockResponse `json:"blockResponse,omitempty"`
+
+	// rateLimit limits the number of requests each client may send within a
+	// window, keyed by client address or by a request header, such as an
+	// API key, for basic abuse protection without hand-written SecLang.
+	// Requests over the limit are answered with status 429, or only logged.
+	// Counters are kept by the WASM plugin of each gateway pod, so that the
+	// limit applies to every pod separately.
+	//
+	// rateLimit is reserved: it is rejected until a qualified WASM plugin
+	// release supports rate limiting.
+	//
+	// +optional
+	RateLimit *RateLimit `json:"rateLimit,omitempty"`
+
+	// probe periodically sends a canary request carrying a marker the
+	// Engine blocks to each gateway pod, and reports in status.probe whether
+	// it was blocked: end-to-end proof that the WAF is enforcing, not just
+	// configured. The operator must be able to reach the gateway pods. The
+	// rule blocking the canary requests is composed into the RuleSet of the
+	// Engine, before its rules.
+	//
+	// +optional
+	Probe *EngineProbe `json:"probe,omitempty"`
 }
 
 // -----------------------------------------------------------------------------
@@ -146,10 +357,15 @@ type EngineStatus struct {
 	//
 	// Standard condition types include:
 	// - "Accepted": the target is valid and not contested by another Engine.
-	//    Reasons: "Accepted", "TargetNotFound", "TargetConflict"
+	//    Reasons: "Accepted", "TargetNotFound", "TargetConflict",
+	//    "UnsupportedGatewayClass", "GatewayClassNotVerified"
 	// - "Ready": the engine has been successfully deployed and is operational
 	// - "Progressing": the resource is being created or updated
 	// - "Degraded": the resource failed to reach or maintain its desired state
+	// - "FailingClosed": the Engine is Degraded with failurePolicy fail, so
+	//    traffic may be blocked. Reasons: the reason of the Degraded
+	//    condition when True; "NotDegraded", "FailurePolicyAllow" or
+	//    "FailurePolicyDowngraded" when False
 	//
 	// The status of each condition is one of True, False, or Unknown.
 	//
@@ -161,6 +377,272 @@ type EngineStatus struct {
 	// +kubebuilder:validation:MaxItems=16
 	// +optional
 	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+
+	// ancestors reports the status of the Engine for each Gateway it is
+	// attached to, in the form of the Gateway API PolicyAncestorStatus, so
+	// that policy-aware tooling can show the WAF attached to a Gateway.
+	//
+	// The Accepted condition of an ancestor uses the Gateway API policy
+	// reasons: "Accepted", "Conflicted", "TargetNotFound" and "Invalid".
+	//
+	// +listType=atomic
+	// +kubebuilder:validation:MaxItems=16
+	// +optional
+	Ancestors []PolicyAncestorStatus `json:"ancestors,omitempty"`
+
+	// learning reports the progress of learning mode, while spec.learning
+	// is set.
+	//
+	// +optional
+	Learning *LearningStatus `json:"learning,omitempty"`
+
+	// schedule reports the mode the schedule selects and when it next
+	// changes, while spec.schedule is set.
+	//
+	// +optional
+	Schedule *ScheduleStatus `json:"schedule,omitempty"`
+
+	// failurePolicyDowngrade reports the downgrade of the failure policy to
+	// allow, from when it starts until the Engine recovers.
+	//
+	// +optional
+	FailurePolicyDowngrade *FailurePolicyDowngradeStatus `json:"failurePolicyDowngrade,omitempty"`
+
+	// enforcementMode is whether the WasmPlugin of the Engine blocks the
+	// requests matching its rules, or only logs them in DetectionOnly mode
+	// or while learning.
+	//
+	// +optional
+	EnforcementMode EnforcementMode `json:"enforcementMode,omitempty"`
+
+	// activeRuleSet is the name of the RuleSet whose rules the gateways of
+	// the Engine load: spec.ruleSet, or spec.fallbackRuleSet while the rules
+	// of spec.ruleSet cannot be loaded.
+	//
+	// +kubebuilder:validation:MaxLength=253
+	// +optional
+	ActiveRuleSet string `json:"activeRuleSet,omitempty"`
+
+	// effectiveConfig summarizes the configuration the operator last wrote
+	// to the WasmPlugin of the Engine, with the operator defaults, the
+	// OperatorConfig overrides, the image mirrors and the failure policy
+	// downgrade applied, so that what runs on the gateways can be read in
+	// one place.
+	//
+	// +optional
+	EffectiveConfig *EffectiveConfig `json:"effectiveConfig,omitempty"`
+
+	// dataPlane reports what the gateways of the Engine last reported to
+	// the ruleset cache server.
+	//
+	// +optional
+	DataPlane *DataPlaneStatus `json:"dataPlane,omitempty"`
+
+	// probe reports the result of the last enforcement probe, while
+	// spec.probe is set.
+	//
+	// +optional
+	Probe *ProbeStatus `json:"probe,omitempty"`
+
+	// targets reports the Engines created for the Gateways selected by
+	// spec.target.selector, in Gateway name order.
+	//
+	// +listType=map
+	// +listMapKey=gateway
+	// +kubebuilder:validation:MaxItems=64
+	// +optional
+	Targets []SelectedTargetStatus `json:"targets,omitempty"`
+}
+
+// SelectedTargetStatus is the status of the Engine created for a Gateway
+// selected by the target selector of an Engine.
+type SelectedTargetStatus struct {
+	// gateway is the name of the selected Gateway.
+	//
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=253
+	Gateway string `json:"gateway,omitempty"`
+
+	// engine is the name of the Engine targeting the Gateway.
+	//
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=253
+	Engine string `json:"engine,omitempty"`
+
+	// accepted is the status of the Accepted condition of the Engine, or
+	// Unknown until it has one.
+	//
+	// +optional
+	Accepted metav1.ConditionStatus `json:"accepted,omitempty"`
+
+	// ready is the status of the Ready condition of the Engine, or Unknown
+	// until it has one.
+	//
+	// +optional
+	Ready metav1.ConditionStatus `json:"ready,omitempty"`
+
+	// message explains why the Engine is not accepted or not ready, or why
+	// it could not be created.
+	//
+	// +optional
+	// +kubebuilder:validation:MaxLength=32768
+	Message string `json:"message,omitempty"`
+}
+
+// EnforcementMode is whether an Engine blocks the requests matching its
+// rules.
+//
+// +kubebuilder:validation:Enum=Block;Detect
+type EnforcementMode string
+
+const (
+	// EnforcementModeBlock blocks the requests matching the rules.
+	EnforcementModeBlock EnforcementMode = "Block"
+
+	// EnforcementModeDetect only logs the requests matching the rules.
+	EnforcementModeDetect EnforcementMode = "Detect"
+)
+
+// EffectiveConfig is the resolved configuration of the WasmPlugin of an
+// Engine.
+type EffectiveConfig struct {
+	// image is the WASM plugin image, from the Engine or the operator
+	// default, after the image mirrors apply.
+	//
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// failurePolicy is the failure policy the WASM plugin applies, which is
+	// allow while the failure policy is downgraded.
+	//
+	// +optional
+	FailurePolicy FailurePolicy `json:"failurePolicy,omitempty"`
+
+	// pollIntervalSeconds is how often the WASM plugin polls the ruleset
+	// cache server for new rules, when set on the Engine.
+	//
+	// +optional
+	PollIntervalSeconds int32 `json:"pollIntervalSeconds,omitempty"`
+
+	// listenerPort is the port of the Gateway listener the WasmPlugin is
+	// restricted to, when the Engine targets a listener.
+	//
+	// +optional
+	ListenerPort int32 `json:"listenerPort,omitempty"`
+
+	// pluginConfigVersion is the version of the pluginConfig schema written
+	// for the image.
+	//
+	// +optional
+	PluginConfigVersion int32 `json:"pluginConfigVersion,omitempty"`
+
+	// pluginConfigKeys are the pluginConfig keys written for the image, in
+	// order. The WASM plugin image must support each of them.
+	//
+	// +optional
+	// +listType=set
+	PluginConfigKeys []string `json:"pluginConfigKeys,omitempty"`
+
+	// directives are the SecLang directives the operator generates from the
+	// Engine spec, such as the probe rule, inspection bypass and rule
+	// exclusions, and composes into the RuleSet of the Engine, before its
+	// rules.
+	//
+	// +optional
+	Directives string `json:"directives,omitempty"`
+}
+
+// DataPlaneStatus reports the heartbeats of the gateways of an Engine.
+type DataPlaneStatus struct {
+	// revision is the revision of the rules of the RuleSet that a gateway
+	// last reported enforcing, or empty when it has not loaded any.
+	//
+	// +optional
+	// +kubebuilder:validation:MaxLength=36
+	Revision string `json:"revision,omitempty"`
+
+	// snapshot is the name of the RuleSetSnapshot recording the revision
+	// the gateway last reported enforcing.
+	//
+	// +optional
+	// +kubebuilder:validation:MaxLength=253
+	Snapshot string `json:"snapshot,omitempty"`
+
+	// lastHeartbeatTime is when a gateway last reported, updated at most
+	// once a minute while the revision does not change. A time older than
+	// a few poll intervals means the gateways no longer reach the cache
+	// server.
+	//
+	// +required
+	LastHeartbeatTime metav1.Time `json:"lastHeartbeatTime,omitzero"`
+}
+
+// EngineControllerName is the controller name the operator reports in the
+// ancestor statuses of Engines.
+const EngineControllerName = Group + "/engine-controller"
+
+// PolicyAncestorStatus is the status of a policy for one of its ancestors,
+// following the Gateway API PolicyAncestorStatus.
+type PolicyAncestorStatus struct {
+	// ancestorRef identifies the ancestor the status applies to.
+	//
+	// +required
+	AncestorRef AncestorReference `json:"ancestorRef,omitzero"`
+
+	// controllerName is the name of the controller that wrote the status.
+	//
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=253
+	ControllerName string `json:"controllerName,omitempty"`
+
+	// conditions describe the status of the policy with respect to the
+	// ancestor.
+	//
+	// +listType=map
+	// +listMapKey=type
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=8
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// AncestorReference identifies an ancestor of a policy, such as a Gateway.
+type AncestorReference struct {
+	// group is the API group of the ancestor.
+	//
+	// +required
+	// +kubebuilder:validation:MaxLength=253
+	Group string `json:"group,omitempty"`
+
+	// kind is the kind of the ancestor.
+	//
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=63
+	Kind string `json:"kind,omitempty"`
+
+	// namespace is the namespace of the ancestor.
+	//
+	// +optional
+	// +kubebuilder:validation:MaxLength=63
+	Namespace string `json:"namespace,omitempty"`
+
+	// name is the name of the ancestor.
+	//
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=253
+	Name string `json:"name,omitempty"`
+
+	// sectionName is the name of the listener of the ancestor the policy
+	// is scoped to.
+	//
+	// +optional
+	// +kubebuilder:validation:MaxLength=253
+	SectionName string `json:"sectionName,omitempty"`
 }
 
 // -----------------------------------------------------------------------------
@@ -169,7 +651,7 @@ type EngineStatus struct {
 
 // FailurePolicy describes the failure policy for the Engine.
 //
-// +kubebuilder:validation:Enum=fail;allow
+// +kubebuilder:validation:Enum=fail;allow;degrade
 type FailurePolicy string
 
 const (
@@ -180,8 +662,108 @@ const (
 	// FailurePolicyAllow allows traffic through when the Engine is not ready or
 	// encounters errors.
 	FailurePolicyAllow FailurePolicy = "allow"
+
+	// FailurePolicyDegrade loads the rules of the fallback RuleSet when those
+	// of the RuleSet cannot be loaded, and blocks traffic when neither can.
+	FailurePolicyDegrade FailurePolicy = "degrade"
+)
+
+// -----------------------------------------------------------------------------
+// Engine - Mode
+// -----------------------------------------------------------------------------
+
+// EngineMode describes whether an Engine blocks the requests matching its
+// rules.
+//
+// +kubebuilder:validation:Enum=Enforce;DetectionOnly
+type EngineMode string
+
+const (
+	// EngineModeEnforce blocks the requests matching the rules.
+	EngineModeEnforce EngineMode = "Enforce"
+
+	// EngineModeDetectionOnly evaluates and logs the rules, but never
+	// blocks.
+	EngineModeDetectionOnly EngineMode = "DetectionOnly"
 )
 
+// -----------------------------------------------------------------------------
+// Engine - Schedule
+// -----------------------------------------------------------------------------
+
+// EngineSchedule switches the mode of an Engine during recurring time
+// windows.
+type EngineSchedule struct {
+	// timeZone is the IANA name of the time zone of the cron expressions of
+	// the windows, such as Europe/Oslo.
+	//
+	// When omitted, the cron expressions are in UTC.
+	//
+	// +optional
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=64
+	TimeZone string `json:"timeZone,omitempty"`
+
+	// windows are the time windows of the schedule. When windows overlap,
+	// the first one in the list applies.
+	//
+	// +required
+	// +listType=atomic
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=16
+	Windows []ScheduleWindow `json:"windows,omitempty"`
+}
+
+// ScheduleWindow is a recurring time window in which an Engine uses a mode.
+type ScheduleWindow struct {
+	// cron is when the window starts, as a standard cron expression of five
+	// fields: minute, hour, day of month, month and day of week, such as
+	// "0 9 * * 1-5" for 9:00 on weekdays. Fields accept *, lists, ranges
+	// and steps, and the day of week and month fields accept the
+	// three-letter English names.
+	//
+	// +required
+	// +kubebuilder:validation:MinLength=9
+	// +kubebuilder:validation:MaxLength=128
+	Cron string `json:"cron,omitempty"`
+
+	// durationSeconds is how long the window lasts, from one minute up to a
+	// week.
+	//
+	// +required
+	// +kubebuilder:validation:Minimum=60
+	// +kubebuilder:validation:Maximum=604800
+	DurationSeconds int32 `json:"durationSeconds,omitempty"`
+
+	// mode is the mode of the Engine during the window. DetectionOnly is
+	// rejected, as in spec.mode.
+	//
+	// +required
+	// +kubebuilder:validation:XValidation:rule="self != 'DetectionOnly'",message="DetectionOnly is not supported yet: no qualified WASM plugin release supports switching the rule engine mode"
+	Mode EngineMode `json:"mode,omitempty"`
+}
+
+// ScheduleStatus reports the state of the schedule of an Engine.
+type ScheduleStatus struct {
+	// mode is the mode the schedule selects: that of the current window, or
+	// spec.mode outside of the windows.
+	//
+	// +required
+	Mode EngineMode `json:"mode,omitempty"`
+
+	// window is the index in spec.schedule.windows of the current window,
+	// or omitted outside of the windows.
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	Window *int32 `json:"window,omitempty"`
+
+	// nextTransitionTime is when a window next starts or ends.
+	//
+	// +optional
+	NextTransitionTime *metav1.Time `json:"nextTransitionTime,omitempty"`
+}
+
 // -----------------------------------------------------------------------------
 // Engine - Reference Types
 // -----------------------------------------------------------------------------
@@ -195,3 +777,626 @@ type RuleSetReference struct {
 	// +kubebuilder:validation:MaxLength=253
 	Name string `json:"name,omitempty"`
 }
+
+// -----------------------------------------------------------------------------
+// Engine - Response Inspection
+// -----------------------------------------------------------------------------
+
+// ResponseInspection configures the inspection of responses. The response
+// headers are inspected whenever it is set.
+type ResponseInspection struct {
+	// body enables the inspection of response bodies (phase 4). When
+	// omitted, only the response headers are inspected.
+	//
+	// +optional
+	Body *ResponseBodyInspection `json:"body,omitempty"`
+}
+
+// ResponseBodyInspection configures the inspection of response bodies.
+type ResponseBodyInspection struct {
+	// limitBytes is the maximum number of response body bytes buffered for
+	// inspection. The remainder of larger bodies is not inspected.
+	//
+	// +optional
+	// +default=524288
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=1073741824
+	LimitBytes int32 `json:"limitBytes,omitempty"`
+
+	// mimeTypes lists the MIME types of the response bodies that are
+	// inspected. Bodies of other types are not buffered.
+	//
+	// When omitted, text/plain and text/html bodies are inspected.
+	//
+	// +optional
+	// +listType=set
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=32
+	// +kubebuilder:validation:items:MaxLength=127
+	// +kubebuilder:validation:items:Pattern=`^[a-z0-9][a-z0-9!#$&^_.+-]*/[a-z0-9][a-z0-9!#$&^_.+-]*$`
+	MIMETypes []string `json:"mimeTypes,omitempty"`
+}
+
+// -----------------------------------------------------------------------------
+// Engine - Inspection Bypass
+// -----------------------------------------------------------------------------
+
+// InspectionBypass lists the requests that are not inspected.
+//
+// +kubebuilder:validation:MinProperties=1
+type InspectionBypass struct {
+	// contentTypes are the media types of the request bodies of the requests
+	// that are not inspected, matched case-insensitively against the
+	// Content-Type header, ignoring its parameters. A type ending with "/*"
+	// matches every subtype, such as video/*.
+	//
+	// +optional
+	// +listType=set
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=32
+	// +kubebuilder:validation:items:MaxLength=127
+	// +kubebuilder:validation:items:Pattern=`^[a-z0-9][a-z0-9!#$&^_.+-]*/(\*|[a-z0-9][a-z0-9!#$&^_.+-]*)$`
+	ContentTypes []string `json:"contentTypes,omitempty"`
+
+	// bodyLargerThanBytes skips the inspection of requests whose
+	// Content-Length header is larger than this number of bytes, such as
+	// large file uploads. Requests without a Content-Length header, such as
+	// chunked uploads, are still inspected.
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	BodyLargerThanBytes *int64 `json:"bodyLargerThanBytes,omitempty"`
+
+	// paths are the paths, and optionally methods, of the requests that are
+	// not inspected, such as /healthz or an upload endpoint.
+	//
+	// +optional
+	// +listType=atomic
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=64
+	Paths []InspectionBypassPath `json:"paths,omitempty"`
+}
+
+// InspectionBypassPath matches requests by path, and optionally method.
+//
+// +kubebuilder:validation:XValidation:rule="has(self.prefix) != has(self.regex)",message="exactly one of prefix and regex must be set"
+type InspectionBypassPath struct {
+	// prefix matches the requests whose path starts with this prefix.
+	//
+	// +optional
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=1024
+	// +kubebuilder:validation:Pattern=`^/[^\s"'\\%]*$`
+	Prefix string `json:"prefix,omitempty"`
+
+	// regex matches the requests whose path, without the query string,
+	// matches this RE2 regular expression.
+	//
+	// +optional
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=256
+	// +kubebuilder:validation:Pattern=`^[^\s"']+$`
+	Regex string `json:"regex,omitempty"`
+
+	// methods restricts the bypass to the requests with these methods. When
+	// omitted, requests of every method are bypassed.
+	//
+	// +optional
+	// +listType=set
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=9
+	// +kubebuilder:validation:items:Enum=GET;HEAD;POST;PUT;PATCH;DELETE;OPTIONS;CONNECT;TRACE
+	Methods []string `json:"methods,omitempty"`
+}
+
+// -----------------------------------------------------------------------------
+// Engine - Rule Exclusions
+// -----------------------------------------------------------------------------
+
+// RuleExclusion removes the rule with an ID, or the rules with a tag, from
+// the transactions of an Engine.
+//
+// +kubebuilder:validation:XValidation:rule="has(self.ruleID) != has(self.tag)",message="exactly one of ruleID and tag must be set"
+type RuleExclusion struct {
+	// ruleID is the ID of the rule to remove, as reported in the audit log.
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	RuleID int32 `json:"ruleID,omitempty"`
+
+	// tag removes every rule with this tag, such as "attack-sqli" for the
+	// SQL injection rules of the Core Rule Set.
+	//
+	// +optional
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=128
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9][A-Za-z0-9._/-]*$`
+	Tag string `json:"tag,omitempty"`
+
+	// target is a variable of the request, such as "ARGS:password" or
+	// "REQUEST_COOKIES:session". When set, only this target is removed from
+	// the rules, which keep inspecting the rest of the request. When
+	// omitted, the whole rules are removed.
+	//
+	// +optional
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=256
+	// +kubebuilder:validation:Pattern=`^[A-Z_]+(:[^\s"'\\,;|]+)?$`
+	Target string `json:"target,omitempty"`
+}
+
+// -----------------------------------------------------------------------------
+// Engine - Redaction
+// -----------------------------------------------------------------------------
+
+// Redaction lists the request data masked in the audit log.
+//
+// +kubebuilder:validation:MinProperties=1
+type Redaction struct {
+	// headers are the names of the request headers whose values are
+	// masked, matched case-insensitively, such as Authorization.
+	//
+	// +optional
+	// +listType=set
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=64
+	// +kubebuilder:validation:items:MinLength=1
+	// +kubebuilder:validation:items:MaxLength=256
+	// +kubebuilder:validation:items:Pattern=`^[A-Za-z0-9!#$%&'*+.^_|~-]+$`
+	Headers []string `json:"headers,omitempty"`
+
+	// cookies are the names of the request cookies whose values are
+	// masked, such as session.
+	//
+	// +optional
+	// +listType=set
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=64
+	// +kubebuilder:validation:items:MinLength=1
+	// +kubebuilder:validation:items:MaxLength=256
+	// +kubebuilder:validation:items:Pattern=`^[A-Za-z0-9!#$%&'*+.^_|~-]+$`
+	Cookies []string `json:"cookies,omitempty"`
+
+	// bodyFields are RE2 regular expressions matched against the names of
+	// the request body fields whose values are masked: form fields, and the
+	// keys of JSON bodies, prefixed with "json." as in the rules, such as
+	// ^json\.password$.
+	//
+	// +optional
+	// +listType=set
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=64
+	// +kubebuilder:validation:items:MinLength=1
+	// +kubebuilder:validation:items:MaxLength=256
+	BodyFields []string `json:"bodyFields,omitempty"`
+}
+
+// -----------------------------------------------------------------------------
+// Engine - Probe
+// -----------------------------------------------------------------------------
+
+// EngineProbe configures the enforcement probe of an Engine.
+type EngineProbe struct {
+	// intervalSeconds is how often the gateway pods are probed.
+	//
+	// +optional
+	// +default=300
+	// +kubebuilder:validation:Minimum=30
+	// +kubebuilder:validation:Maximum=86400
+	IntervalSeconds int32 `json:"intervalSeconds,omitempty"`
+
+	// port is the port of the gateway pods the canary request is sent to.
+	//
+	// When omitted, this means the user has no opinion and the platform
+	// will choose a reasonable default, which is subject to change over time.
+	// The current default is 80 for Gateway targets and 8080 for
+	// IngressGateway targets.
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port,omitempty"`
+
+	// hostname is the Host header of the canary request, for gateways that
+	// only inspect the traffic of some hostnames.
+	//
+	// +optional
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`
+	Hostname string `json:"hostname,omitempty"`
+
+	// path is the request path of the canary request.
+	//
+	// +optional
+	// +default="/"
+	// +kubebuilder:validation:MaxLength=256
+	// +kubebuilder:validation:Pattern=`^/[^\s"'\\]*$`
+	Path string `json:"path,omitempty"`
+}
+
+// ProbeResult is the outcome of an enforcement probe.
+//
+// +kubebuilder:validation:Enum=Enforcing;NotEnforcing;Unreachable
+type ProbeResult string
+
+const (
+	// ProbeResultEnforcing means every probed gateway pod blocked the
+	// canary request.
+	ProbeResultEnforcing ProbeResult = "Enforcing"
+
+	// ProbeResultNotEnforcing means a gateway pod let the canary request
+	// through, or answered it with an unexpected status.
+	ProbeResultNotEnforcing ProbeResult = "NotEnforcing"
+
+	// ProbeResultUnreachable means no gateway pod could be probed.
+	ProbeResultUnreachable ProbeResult = "Unreachable"
+)
+
+// ProbeStatus reports the last enforcement probe of an Engine.
+type ProbeStatus struct {
+	// result is the outcome of the last probe.
+	//
+	// +optional
+	Result ProbeResult `json:"result,omitempty"`
+
+	// lastProbeTime is when the gateway pods were last probed.
+	//
+	// +optional
+	LastProbeTime metav1.Time `json:"lastProbeTime,omitzero"`
+
+	// podsProbed is the number of gateway pods that answered the canary
+	// request.
+	//
+	// +optional
+	PodsProbed int32 `json:"podsProbed,omitempty"`
+
+	// podsEnforcing is the number of gateway pods that blocked the canary
+	// request.
+	//
+	// +optional
+	PodsEnforcing int32 `json:"podsEnforcing,omitempty"`
+
+	// message describes the result, such as the pods that let the canary
+	// request through.
+	//
+	// +optional
+	// +kubebuilder:validation:MaxLength=1024
+	Message string `json:"message,omitempty"`
+}
+
+// -----------------------------------------------------------------------------
+// Engine - Access Log
+// -----------------------------------------------------------------------------
+
+// AccessLog configures the access log of the targeted gateway.
+type AccessLog struct {
+	// providers are the names of the access log providers of the mesh the
+	// gateway logs to, such as an OpenTelemetry provider defined in the
+	// extensionProviders of the mesh configuration.
+	//
+	// When omitted, the built-in envoy provider, which logs to the standard
+	// output of the gateway.
+	//
+	// +optional
+	// +listType=set
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=8
+	// +kubebuilder:validation:items:MinLength=1
+	// +kubebuilder:validation:items:MaxLength=253
+	Providers []string `json:"providers,omitempty"`
+}
+
+// -----------------------------------------------------------------------------
+// Engine - Verdict Metadata
+// -----------------------------------------------------------------------------
+
+// VerdictMetadata configures the Envoy dynamic metadata the WASM plugin
+// writes for each request. The plugin writes the keys "verdict" (allowed,
+// blocked or detected), "rule_ids" (the comma-separated IDs of the rules
+// that matched) and "status" (the status code of the deny action, for
+// blocked requests).
+type VerdictMetadata struct {
+	// namespace is the dynamic metadata namespace the keys are written to,
+	// referenced as %DYNAMIC_METADATA(<namespace>:verdict)% in Envoy access
+	// log formats.
+	//
+	// +optional
+	// +default="coraza"
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9._]*[a-z0-9])?$`
+	Namespace string `json:"namespace,omitempty"`
+
+	// metricLabel, when set, is the label of the request metrics of the
+	// gateway holding the verdict, added through the generated Istio
+	// Telemetry. Requires the Istio Telemetry API.
+	//
+	// +optional
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z_][a-z0-9_]*$`
+	MetricLabel string `json:"metricLabel,omitempty"`
+}
+
+// -----------------------------------------------------------------------------
+// Engine - Block Response
+// -----------------------------------------------------------------------------
+
+// BlockResponse configures the response body of blocked requests. Bodies
+// may use the placeholders {{request_id}}, the x-request-id header of the
+// request, {{timestamp}}, the time of the request in RFC 3339, {{status}},
+// the HTTP status of the response, and {{language}}, the language of the
+// body.
+type BlockResponse struct {
+	// contentType is the Content-Type of the response.
+	//
+	// +optional
+	// +kubebuilder:default="text/html; charset=utf-8"
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=256
+	ContentType string `json:"contentType,omitempty"`
+
+	// body is the template of the response body, used when no localized
+	// body matches the Accept-Language header of the request.
+	//
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=16384
+	Body string `json:"body,omitempty"`
+
+	// localized are the templates of the response body in other languages.
+	// The plugin picks the one whose language best matches the
+	// Accept-Language header of the request, a language matching the
+	// languages of its region, such as de for de-CH.
+	//
+	// +optional
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=16
+	// +listType=map
+	// +listMapKey=language
+	Localized []LocalizedBlockResponse `json:"localized,omitempty"`
+}
+
+// LocalizedBlockResponse is the response body of blocked requests in one
+// language.
+type LocalizedBlockResponse struct {
+	// language is the BCP 47 language tag of the body, such as de or fr-CA.
+	//
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=35
+	// +kubebuilder:validation:Pattern=`^[A-Za-z]{1,8}(-[A-Za-z0-9]{1,8})*$`
+	Language string `json:"language,omitempty"`
+
+	// body is the template of the response body in the language.
+	//
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=16384
+	Body string `json:"body,omitempty"`
+}
+
+// -----------------------------------------------------------------------------
+// Engine - Rate Limit
+// -----------------------------------------------------------------------------
+
+// RateLimit limits the requests of each client within a fixed window.
+//
+// +kubebuilder:validation:XValidation:rule="(has(self.key) && self.key == 'Header') == has(self.header)",message="header must be set if and only if key is Header"
+type RateLimit struct {
+	// requests is the number of requests a client may send within a window.
+	//
+	// +required
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=1000000
+	Requests int32 `json:"requests,omitempty"`
+
+	// windowSeconds is the length of the window, in seconds.
+	//
+	// +optional
+	// +kubebuilder:default=60
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=86400
+	WindowSeconds int32 `json:"windowSeconds,omitempty"`
+
+	// key identifies the clients whose requests are counted together:
+	//
+	// - "ClientIP": the client address of the request
+	// - "Header": the value of the request header named by header; requests
+	//   without it are not limited
+	//
+	// +optional
+	// +kubebuilder:default="ClientIP"
+	Key RateLimitKey `json:"key,omitempty"`
+
+	// header is the name of the request header identifying the client,
+	// matched case-insensitively, when key is Header.
+	//
+	// +optional
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=256
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9!#$%&'*+.^_|~-]+$`
+	Header string `json:"header,omitempty"`
+
+	// action is applied to the requests over the limit:
+	//
+	// - "Deny": answer with status 429
+	// - "Log": log the request and let it through, to size the limit
+	//
+	// +optional
+	// +kubebuilder:default="Deny"
+	Action RateLimitAction `json:"action,omitempty"`
+}
+
+// RateLimitKey identifies the clients of a rate limit.
+//
+// +kubebuilder:validation:Enum=ClientIP;Header
+type RateLimitKey string
+
+const (
+	// RateLimitKeyClientIP counts the requests of each client address.
+	RateLimitKeyClientIP RateLimitKey = "ClientIP"
+
+	// RateLimitKeyHeader counts the requests of each value of a header.
+	RateLimitKeyHeader RateLimitKey = "Header"
+)
+
+// RateLimitAction is the action applied to the requests over a rate limit.
+//
+// +kubebuilder:validation:Enum=Deny;Log
+type RateLimitAction string
+
+const (
+	// RateLimitActionDeny answers the requests over the limit with status
+	// 429.
+	RateLimitActionDeny RateLimitAction = "Deny"
+
+	// RateLimitActionLog logs the requests over the limit.
+	RateLimitActionLog RateLimitAction = "Log"
+)
+
+// -----------------------------------------------------------------------------
+// Engine - Request Correlation
+// -----------------------------------------------------------------------------
+
+// RequestCorrelation configures the request headers that correlate the
+// audit log entries of the WASM plugin with distributed traces and
+// application logs.
+type RequestCorrelation struct {
+	// headers are the names of the request headers whose values the WASM
+	// plugin adds to each of its audit log entries, matched
+	// case-insensitively, such as the request ID Envoy generates and the
+	// W3C trace context. When the access log is enabled, the traces of the
+	// gateway are tagged with them too, as coraza.correlation.<header>.
+	//
+	// When omitted, x-request-id and traceparent.
+	//
+	// +optional
+	// +listType=set
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=8
+	// +kubebuilder:validation:items:MinLength=1
+	// +kubebuilder:validation:items:MaxLength=256
+	// +kubebuilder:validation:items:Pattern=`^[A-Za-z0-9!#$%&'*+.^_|~-]+$`
+	Headers []string `json:"headers,omitempty"`
+}
+
+// -----------------------------------------------------------------------------
+// Engine - Failure Policy Downgrade
+// -----------------------------------------------------------------------------
+
+// FailurePolicyDowngrade configures the temporary downgrade of the failure
+// policy of a Degraded Engine.
+type FailurePolicyDowngrade struct {
+	// ttlSeconds is how long the failure policy is downgraded at most, from
+	// 1 minute to 24 hours.
+	//
+	// +optional
+	// +default=3600
+	// +kubebuilder:validation:Minimum=60
+	// +kubebuilder:validation:Maximum=86400
+	TTLSeconds int32 `json:"ttlSeconds,omitempty"`
+}
+
+// FailurePolicyDowngradeStatus reports a downgrade of the failure policy.
+type FailurePolicyDowngradeStatus struct {
+	// startTime is when the failure policy was downgraded to allow.
+	//
+	// +required
+	StartTime metav1.Time `json:"startTime,omitzero"`
+
+	// expireTime is when the failure policy is restored to fail, unless the
+	// Engine recovers first.
+	//
+	// +required
+	ExpireTime metav1.Time `json:"expireTime,omitzero"`
+}
+
+// -----------------------------------------------------------------------------
+// Engine - Learning
+// -----------------------------------------------------------------------------
+
+// LearningConfig configures learning mode.
+type LearningConfig struct {
+	// durationSeconds is how long the Engine learns, from 10 minutes to
+	// 14 days.
+	//
+	// +optional
+	// +default=86400
+	// +kubebuilder:validation:Minimum=600
+	// +kubebuilder:validation:Maximum=1209600
+	DurationSeconds int32 `json:"durationSeconds,omitempty"`
+
+	// minMatches is the number of matches from which a rule is included in
+	// the candidate exclusions. Rules that matched less often are left
+	// enabled.
+	//
+	// +optional
+	// +default=10
+	// +kubebuilder:validation:Minimum=1
+	MinMatches int32 `json:"minMatches,omitempty"`
+}
+
+// LearningPhase is the phase of learning mode.
+//
+// +kubebuilder:validation:Enum=Learning;Completed
+type LearningPhase string
+
+const (
+	// LearningPhaseLearning is the phase in which rules only log and the
+	// Engine reports their matches.
+	LearningPhaseLearning LearningPhase = "Learning"
+
+	// LearningPhaseCompleted is the phase after the learning period, once the
+	// candidate exclusions have been generated. Rules are enforced again.
+	LearningPhaseCompleted LearningPhase = "Completed"
+)
+
+// LearningStatus reports the progress of learning mode.
+type LearningStatus struct {
+	// phase is the current phase of learning mode.
+	//
+	// +required
+	Phase LearningPhase `json:"phase,omitempty"`
+
+	// startTime is when learning started.
+	//
+	// +required
+	StartTime metav1.Time `json:"startTime,omitzero"`
+
+	// completionTime is when learning completed.
+	//
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// ruleMatches counts the matches of each rule reported while learning.
+	// Only the most frequently matching rules are kept.
+	//
+	// +listType=map
+	// +listMapKey=ruleId
+	// +kubebuilder:validation:MaxItems=256
+	// +optional
+	RuleMatches []RuleMatchCount `json:"ruleMatches,omitempty"`
+
+	// candidateRuleSource is the name of the draft RuleSource generated with
+	// the candidate exclusions when learning completed.
+	//
+	// +optional
+	// +kubebuilder:validation:MaxLength=253
+	CandidateRuleSource string `json:"candidateRuleSource,omitempty"`
+}
+
+// RuleMatchCount is the number of matches of a rule.
+type RuleMatchCount struct {
+	// ruleId is the ID of the rule.
+	//
+	// +required
+	// +kubebuilder:validation:Minimum=1
+	RuleID int32 `json:"ruleId,omitempty"`
+
+	// count is the number of matches.
+	//
+	// +required
+	// +kubebuilder:validation:Minimum=1
+	Count int64 `json:"count,omitempty"`
+}
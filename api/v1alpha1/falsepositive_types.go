@@ -0,0 +1,196 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// -----------------------------------------------------------------------------
+// FalsePositive - Schema Registration
+// -----------------------------------------------------------------------------
+
+func init() {
+	SchemeBuilder.Register(&FalsePositive{}, &FalsePositiveList{})
+}
+
+// -----------------------------------------------------------------------------
+// FalsePositive - Constants
+// -----------------------------------------------------------------------------
+
+// FalsePositiveExclusionSuffix is the suffix of the name of the draft
+// RuleSource the operator generates for each FalsePositive.
+const FalsePositiveExclusionSuffix = "-exclusion"
+
+// -----------------------------------------------------------------------------
+// FalsePositive
+// -----------------------------------------------------------------------------
+
+// FalsePositive marks a request blocked by a rule as legitimate. The
+// operator suggests a narrowly-scoped exclusion, removing the rule (or one
+// of its targets) for the path of the request only, as a draft RuleSource
+// named "<name>-exclusion". The exclusion takes effect once the draft is
+// approved and referenced by a RuleSet; deleting the FalsePositive deletes
+// it.
+//
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:shortName=fp
+// +kubebuilder:printcolumn:name="Rule",type=integer,JSONPath=`.spec.ruleID`
+// +kubebuilder:printcolumn:name="Path",type=string,JSONPath=`.spec.path`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// +kubebuilder:validation:XValidation:rule="size(self.metadata.name) <= 243",message="name must be at most 243 characters, so that the name of the generated RuleSource is valid"
+type FalsePositive struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata.
+	//
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitzero"`
+
+	// spec identifies the blocked request.
+	//
+	// +required
+	Spec FalsePositiveSpec `json:"spec,omitzero"`
+
+	// status defines the observed state of FalsePositive.
+	//
+	// +optional
+	Status FalsePositiveStatus `json:"status,omitzero"`
+}
+
+// FalsePositiveList contains a list of FalsePositive resources.
+//
+// +kubebuilder:object:root=true
+type FalsePositiveList struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// ListMeta is standard list metadata.
+	//
+	// +optional
+	metav1.ListMeta `json:"metadata,omitzero"`
+
+	// Items is the list of FalsePositives.
+	//
+	// +required
+	Items []FalsePositive `json:"items"`
+}
+
+// -----------------------------------------------------------------------------
+// FalsePositive - Spec
+// -----------------------------------------------------------------------------
+
+// FalsePositiveSpec identifies a request that a rule blocked although it
+// was legitimate. It is immutable: mark the request again with a new
+// FalsePositive to change the exclusion.
+//
+// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="spec is immutable"
+type FalsePositiveSpec struct {
+	// ruleID is the ID of the rule that blocked the request, as reported in
+	// the audit log.
+	//
+	// +required
+	// +kubebuilder:validation:Minimum=1
+	RuleID int32 `json:"ruleID,omitempty"`
+
+	// path is the path of the blocked request, without the query string.
+	// The exclusion only applies to requests for exactly this path.
+	//
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=1024
+	// +kubebuilder:validation:Pattern=`^/[^\s"'\\%]*$`
+	Path string `json:"path,omitempty"`
+
+	// target is the variable of the request the rule matched, such as
+	// "ARGS:q" or "REQUEST_COOKIES:session". When set, only this target is
+	// removed from the rule, which keeps inspecting the rest of the
+	// request. When omitted, the whole rule is removed for the path.
+	//
+	// +optional
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=256
+	// +kubebuilder:validation:Pattern=`^[A-Z_]+(:[^\s"'\\,;|]+)?$`
+	Target string `json:"target,omitempty"`
+
+	// requestID is the unique ID of the blocked request in the audit log,
+	// recorded in the generated RuleSource for reference.
+	//
+	// +optional
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=128
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9._:-]+$`
+	RequestID string `json:"requestID,omitempty"`
+
+	// note explains why the request is legitimate, for the reviewer of the
+	// exclusion.
+	//
+	// +optional
+	// +kubebuilder:validation:MaxLength=1024
+	Note string `json:"note,omitempty"`
+}
+
+// -----------------------------------------------------------------------------
+// FalsePositive - Status
+// -----------------------------------------------------------------------------
+
+// FalsePositivePhase is the phase of the exclusion suggested for a
+// FalsePositive.
+//
+// +kubebuilder:validation:Enum=Pending;Approved
+type FalsePositivePhase string
+
+const (
+	// FalsePositivePhasePending is the phase in which the generated
+	// RuleSource is a draft awaiting approval.
+	FalsePositivePhasePending FalsePositivePhase = "Pending"
+
+	// FalsePositivePhaseApproved is the phase after the draft annotation
+	// was removed from the generated RuleSource.
+	FalsePositivePhaseApproved FalsePositivePhase = "Approved"
+)
+
+// FalsePositiveStatus defines the observed state of FalsePositive.
+// +kubebuilder:validation:MinProperties=0
+type FalsePositiveStatus struct {
+	// conditions represent the current state of the FalsePositive resource.
+	//
+	// Standard condition types include:
+	// - "Ready": the exclusion RuleSource has been generated
+	// - "Degraded": the exclusion RuleSource could not be generated
+	//
+	// +listType=map
+	// +listMapKey=type
+	// +patchStrategy=merge
+	// +patchMergeKey=type
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=16
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+
+	// phase is whether the suggested exclusion awaits approval.
+	//
+	// +optional
+	Phase FalsePositivePhase `json:"phase,omitempty"`
+
+	// ruleSource is the name of the generated RuleSource.
+	//
+	// +optional
+	// +kubebuilder:validation:MaxLength=253
+	RuleSource string `json:"ruleSource,omitempty"`
+}
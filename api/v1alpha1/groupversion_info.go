@@ -41,3 +41,77 @@ var (
 	// AddToScheme adds the types in this group-version to the given scheme.
 	AddToScheme = SchemeBuilder.AddToScheme
 )
+
+// -----------------------------------------------------------------------------
+// Multi-Cluster Labels and Annotations
+// -----------------------------------------------------------------------------
+
+const (
+	// LabelPropagate set to "true" on a RuleSet or Engine propagates it to
+	// every member cluster when the operator runs with --enable-multicluster.
+	// A RuleSet carries its RuleSources and RuleData along.
+	LabelPropagate = Group + "/propagate"
+
+	// LabelMemberCluster set to "true" on a Secret in the operator namespace
+	// registers a member cluster. The Secret name is the cluster name and its
+	// "kubeconfig" key holds the credentials to reach it.
+	LabelMemberCluster = Group + "/member-cluster"
+
+	// AnnotationPlacement names the Open Cluster Management Placement, in the
+	// namespace of a propagated RuleSet or Engine, that selects the member
+	// clusters it is propagated to. It is required with the "ocm" fleet
+	// backend and ignored otherwise.
+	AnnotationPlacement = Group + "/placement"
+)
+
+// -----------------------------------------------------------------------------
+// Cross-Namespace Reference Annotations
+// -----------------------------------------------------------------------------
+
+const (
+	// AnnotationAllowReferencesFrom on a referenced object lists, comma
+	// separated, the namespaces whose objects may reference it across
+	// namespaces, or "*" for all namespaces. It grants the same access as a
+	// Gateway API ReferenceGrant, for clusters without the ReferenceGrant API.
+	AnnotationAllowReferencesFrom = Group + "/allow-references-from"
+)
+
+// -----------------------------------------------------------------------------
+// Backup and Restore Labels and Annotations
+// -----------------------------------------------------------------------------
+
+const (
+	// LabelExcludeFromBackup is set to "true" on the resources the operator
+	// generates, such as WasmPlugins and the RuleData of ThreatFeeds, which
+	// are recreated from the resources that own them. Velero skips resources
+	// with this label, and "kubectl coraza export" leaves them out.
+	LabelExcludeFromBackup = "velero.io/exclude-from-backup"
+
+	// AnnotationCreatedAt records, in RFC 3339 format, when an Engine was
+	// first created. The operator sets it from metadata.creationTimestamp.
+	// Unlike the creation timestamp, it is kept when the Engine is restored
+	// from a backup or applied again, so that the oldest Engine keeps
+	// winning when several Engines target the same Gateway. The Engines
+	// created for a target selector carry the time of the selecting Engine.
+	AnnotationCreatedAt = Group + "/created-at"
+)
+
+// -----------------------------------------------------------------------------
+// Rule Approval Annotations
+// -----------------------------------------------------------------------------
+
+const (
+	// AnnotationModifiedBy records the user who last changed the spec of a
+	// RuleSet, RuleSource or RuleData. The admission webhooks of the
+	// operator set it from the identity of the request, and keep it when a
+	// request changes the annotation without changing the spec, so that it
+	// cannot be forged. The authors of a revision of the rules awaiting
+	// approval cannot approve it.
+	AnnotationModifiedBy = Group + "/modified-by"
+
+	// AnnotationApprovedBy records the user who created a RuleSetApproval.
+	// The admission webhooks of the operator set it from the identity of the
+	// request, and keep it on updates. An approval without it approves
+	// nothing.
+	AnnotationApprovedBy = Group + "/approved-by"
+)
@@ -0,0 +1,342 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// -----------------------------------------------------------------------------
+// OperatorConfig - Schema Registration
+// -----------------------------------------------------------------------------
+
+func init() {
+	SchemeBuilder.Register(&OperatorConfig{}, &OperatorConfigList{})
+}
+
+// -----------------------------------------------------------------------------
+// OperatorConfig - Constants
+// -----------------------------------------------------------------------------
+
+// OperatorConfigName is the only OperatorConfig name the operator honors. The
+// OperatorConfig must live in the operator's namespace.
+const OperatorConfigName = "default"
+
+// -----------------------------------------------------------------------------
+// OperatorConfig
+// -----------------------------------------------------------------------------
+
+// OperatorConfig holds runtime configuration for the operator. Fields that
+// are set override the corresponding command-line flags and are applied
+// without restarting the operator; fields that are omitted fall back to the
+// flag values.
+//
+// The operator only reads the OperatorConfig named "default" in its own
+// namespace.
+//
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// +kubebuilder:validation:XValidation:rule="self.metadata.name == 'default'",message="OperatorConfig must be named 'default'"
+type OperatorConfig struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata.
+	//
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitzero"`
+
+	// spec defines the desired runtime configuration of the operator.
+	//
+	// +optional
+	Spec OperatorConfigSpec `json:"spec,omitzero"`
+
+	// status defines the observed state of OperatorConfig.
+	//
+	// +optional
+	Status OperatorConfigStatus `json:"status,omitempty,omitzero"`
+}
+
+// OperatorConfigList contains a list of OperatorConfig resources.
+//
+// +kubebuilder:object:root=true
+type OperatorConfigList struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// ListMeta is standard list metadata.
+	//
+	// +optional
+	metav1.ListMeta `json:"metadata,omitzero"`
+
+	// Items is the list of OperatorConfigs.
+	//
+	// +required
+	Items []OperatorConfig `json:"items"`
+}
+
+// -----------------------------------------------------------------------------
+// OperatorConfig - Spec
+// -----------------------------------------------------------------------------
+
+// OperatorConfigSpec defines the runtime configuration of the operator.
+type OperatorConfigSpec struct {
+	// defaultWasmImage is the OCI image reference for the Coraza WASM plugin
+	// used by Engines that omit spec.driver.wasm.image. Overrides
+	// --default-wasm-image. Changing it rolls affected Engines to the new
+	// image.
+	//
+	// +optional
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=1024
+	// +kubebuilder:validation:XValidation:rule="self.startsWith('oci://')",message="defaultWasmImage must be an OCI reference starting with oci://"
+	DefaultWasmImage string `json:"defaultWasmImage,omitempty"`
+
+	// ruleSourceDebounceWindow is how long RuleSource and RuleData changes
+	// are coalesced before the referencing RuleSets are recomposed. Zero
+	// disables debouncing. Overrides --rulesource-debounce-window.
+	//
+	// +optional
+	// +kubebuilder:validation:XValidation:rule="duration(self) >= duration('0s')",message="ruleSourceDebounceWindow must not be negative"
+	RuleSourceDebounceWindow *metav1.Duration `json:"ruleSourceDebounceWindow,omitempty"`
+
+	// imageMirrors rewrites WASM plugin image references, both the default
+	// and those set on Engines, to pull them from a mirror registry. The
+	// longest matching source applies. Overrides --image-mirrors. Changing
+	// it rolls affected Engines to the rewritten images.
+	//
+	// +optional
+	// +listType=map
+	// +listMapKey=source
+	// +kubebuilder:validation:MaxItems=16
+	ImageMirrors []ImageMirror `json:"imageMirrors,omitempty"`
+
+	// namespaceQuota limits the WAF resources of every namespace, to protect
+	// shared gateways and the RuleSet cache server in multi-tenant clusters.
+	// Resources over quota are not provisioned and report the QuotaExceeded
+	// reason. Unset limits are unlimited.
+	//
+	// +optional
+	NamespaceQuota *NamespaceQuota `json:"namespaceQuota,omitempty"`
+
+	// ruleApproval requires the rule changes of RuleSets in the listed
+	// namespaces to be approved by a RuleSetApproval before they are served.
+	//
+	// +optional
+	RuleApproval *RuleApproval `json:"ruleApproval,omitempty"`
+
+	// engineGuardrails constrain the Engines that namespace teams create, so
+	// that platform teams can delegate WAF configuration without losing
+	// control of the plugin images, failure policies and baseline rules.
+	// Engines violating them are Degraded with the GuardrailViolation reason
+	// and their WasmPlugin keeps its previous configuration.
+	//
+	// +optional
+	EngineGuardrails *EngineGuardrails `json:"engineGuardrails,omitempty"`
+
+	// gatewayCoverage reports the Gateways that no Engine protects, so that
+	// platform teams can enforce that every ingress has a WAF. Each of them
+	// gets a Warning event and is counted in the
+	// coraza_gateway_unprotected metric.
+	//
+	// +optional
+	GatewayCoverage *GatewayCoverage `json:"gatewayCoverage,omitempty"`
+}
+
+// GatewayCoverage selects the Gateways that must be protected by an Engine.
+// +kubebuilder:validation:MinProperties=0
+type GatewayCoverage struct {
+	// namespaces are the namespaces whose Gateways must be protected. When
+	// omitted, the Gateways of every namespace must be.
+	//
+	// +optional
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=256
+	// +kubebuilder:validation:items:MaxLength=63
+	// +kubebuilder:validation:items:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +listType=set
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// gatewayClassNames are the GatewayClasses whose Gateways must be
+	// protected, such as the class of the internet-facing Gateways. When
+	// omitted, the Gateways of every class must be.
+	//
+	// +optional
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=16
+	// +kubebuilder:validation:items:MaxLength=253
+	// +kubebuilder:validation:items:Pattern=`^[a-z0-9]([-.a-z0-9]*[a-z0-9])?$`
+	// +listType=set
+	GatewayClassNames []string `json:"gatewayClassNames,omitempty"`
+}
+
+// EngineGuardrails constrain the Engines of a set of namespaces.
+//
+// +kubebuilder:validation:XValidation:rule="has(self.allowedWasmImages) || has(self.allowedFailurePolicies) || has(self.baselineRuleSources)",message="at least one guardrail must be set"
+type EngineGuardrails struct {
+	// namespaces are the namespaces whose Engines are constrained. When
+	// omitted, the Engines of every namespace are.
+	//
+	// +optional
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=256
+	// +kubebuilder:validation:items:MaxLength=63
+	// +kubebuilder:validation:items:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +listType=set
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// allowedWasmImages are the registry hosts, optionally followed by a
+	// repository path, that the WASM plugin image an Engine sets must come
+	// from, matched on whole path components before the image mirrors
+	// apply. Engines using the default image are always allowed.
+	//
+	// +optional
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=16
+	// +kubebuilder:validation:items:MinLength=1
+	// +kubebuilder:validation:items:MaxLength=255
+	// +kubebuilder:validation:items:Pattern=`^[a-zA-Z0-9.-]+(:[0-9]+)?(/[a-z0-9._-]+)*$`
+	// +listType=set
+	AllowedWasmImages []string `json:"allowedWasmImages,omitempty"`
+
+	// allowedFailurePolicies are the failure policies Engines may set, for
+	// example only fail, so that no team can let traffic through an
+	// unavailable WAF.
+	//
+	// +optional
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=3
+	// +listType=set
+	AllowedFailurePolicies []FailurePolicy `json:"allowedFailurePolicies,omitempty"`
+
+	// baselineRuleSources are the RuleSources the RuleSet of every Engine
+	// must include in its spec.sources, such as a platform-maintained CRS
+	// baseline. RuleSources in another namespace than the RuleSet must be
+	// shared with it through a ReferenceGrant.
+	//
+	// +optional
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=16
+	// +listType=atomic
+	BaselineRuleSources []BaselineRuleSource `json:"baselineRuleSources,omitempty"`
+}
+
+// BaselineRuleSource identifies a RuleSource every RuleSet must include.
+type BaselineRuleSource struct {
+	// namespace is the namespace of the RuleSource. When omitted, the
+	// namespace of each RuleSet.
+	//
+	// +optional
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Namespace string `json:"namespace,omitempty"`
+
+	// name is the name of the RuleSource.
+	//
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=253
+	Name string `json:"name"`
+}
+
+// RuleApproval lists the namespaces whose rule changes must be approved.
+type RuleApproval struct {
+	// namespaces are the protected namespaces. A revision of the rules of a
+	// RuleSet in one of them is served only once a RuleSetApproval in the
+	// same namespace approves it; until then, the RuleSet keeps serving its
+	// previous revision. The approval must be created by another user than
+	// the authors of the revision, which requires the admission webhooks of
+	// the operator.
+	//
+	// +required
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=256
+	// +kubebuilder:validation:items:MaxLength=63
+	// +kubebuilder:validation:items:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	// +listType=set
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+// NamespaceQuota limits the WAF resources of a namespace.
+//
+// +kubebuilder:validation:MinProperties=1
+type NamespaceQuota struct {
+	// maxEngines is the maximum number of Engines in a namespace. The oldest
+	// Engines, by creation timestamp, are within quota; the others are not
+	// accepted.
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MaxEngines *int32 `json:"maxEngines,omitempty"`
+
+	// maxRuleSetSize is the maximum total size of the composed rules and data
+	// files of all RuleSets in a namespace. A RuleSet whose rules would
+	// exceed it is degraded, and the cache keeps serving its previous rules.
+	//
+	// +optional
+	// +kubebuilder:validation:XValidation:rule="type(self) == int ? self > 0 : quantity(self).isGreaterThan(quantity('0'))",message="maxRuleSetSize must be positive"
+	MaxRuleSetSize *resource.Quantity `json:"maxRuleSetSize,omitempty"`
+}
+
+// ImageMirror rewrites the OCI image references under source to the same
+// path under mirror. For example, with source "ghcr.io/networking-incubator"
+// and mirror "registry.internal/coraza", the image
+// "oci://ghcr.io/networking-incubator/coraza-proxy-wasm:v1" is pulled as
+// "oci://registry.internal/coraza/coraza-proxy-wasm:v1".
+type ImageMirror struct {
+	// source is a registry host, optionally followed by a repository path,
+	// without the oci:// scheme. It matches whole path components only.
+	//
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=255
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9.-]+(:[0-9]+)?(/[a-z0-9._-]+)*$`
+	Source string `json:"source"`
+
+	// mirror replaces source in matching image references. It has the same
+	// format as source.
+	//
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=255
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9.-]+(:[0-9]+)?(/[a-z0-9._-]+)*$`
+	Mirror string `json:"mirror"`
+}
+
+// -----------------------------------------------------------------------------
+// OperatorConfig - Status
+// -----------------------------------------------------------------------------
+
+// OperatorConfigStatus defines the observed state of OperatorConfig.
+// +kubebuilder:validation:MinProperties=1
+type OperatorConfigStatus struct {
+	// conditions represent the current state of the OperatorConfig resource.
+	//
+	// Standard condition types include:
+	// - "Ready": the configuration has been applied
+	// - "Degraded": the configuration could not be applied
+	//
+	// +listType=map
+	// +listMapKey=type
+	// +patchStrategy=merge
+	// +patchMergeKey=type
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=16
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+}
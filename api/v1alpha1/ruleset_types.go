@@ -45,6 +45,7 @@ const (
 // RuleSet represents a set of Web Application Firewall (WAF) rules.
 //
 // +kubebuilder:object:root=true
+// +kubebuilder:storageversion
 // +kubebuilder:subresource:status
 // +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
 // +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
@@ -90,9 +91,9 @@ type RuleSetList struct {
 
 // RuleSetSpec defines the desired state of RuleSet.
 type RuleSetSpec struct {
-	// sources is an ordered list of references to RuleSource objects in the
-	// same namespace as the RuleSet. Sources are concatenated in list order
-	// to form the aggregated SecLang string.
+	// sources is an ordered list of references to RuleSource objects, by
+	// default in the same namespace as the RuleSet. Sources are concatenated
+	// in list order to form the aggregated SecLang string.
 	//
 	// +required
 	// +kubebuilder:validation:MinItems=1
@@ -100,15 +101,339 @@ type RuleSetSpec struct {
 	// +listType=atomic
 	Sources []SourceReference `json:"sources,omitempty"`
 
-	// data is an optional list of references to RuleData objects in the same
-	// namespace as the RuleSet. Data entries are merged to provide the
-	// filesystem for @pmFromFile directives (last-listed wins on duplicate keys).
+	// data is an optional list of references to RuleData objects, by default
+	// in the same namespace as the RuleSet. Data entries are merged to provide
+	// the filesystem for @pmFromFile directives (last-listed wins on duplicate
+	// keys).
 	//
 	// +optional
 	// +kubebuilder:validation:MinItems=1
 	// +kubebuilder:validation:MaxItems=256
 	// +listType=atomic
 	Data []DataReference `json:"data,omitempty"`
+
+	// exemptions relax inspection for requests from trusted callers, such as
+	// internal tooling and health checkers that would otherwise trip the
+	// rules. Each exemption is compiled into SecRules that run before the
+	// rules of the sources, in phase 1.
+	//
+	// +optional
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=64
+	// +listType=map
+	// +listMapKey=name
+	Exemptions []Exemption `json:"exemptions,omitempty"`
+
+	// routeOverlays change the inspection of the requests matched by one
+	// rule of an HTTPRoute, such as detection only for /static and a higher
+	// paranoia level for /api on the same route. Each overlay is compiled
+	// into SecRules matching the hostnames, path, method, headers and query
+	// parameters of the route rule, which run before the rules of the
+	// sources, in phase 1. Exemptions take precedence over overlays.
+	//
+	// +optional
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=32
+	// +listType=map
+	// +listMapKey=name
+	RouteOverlays []RouteOverlay `json:"routeOverlays,omitempty"`
+
+	// routeScope restricts inspection to the requests matched by the listed
+	// HTTPRoutes and GRPCRoutes, so that the Engines of a Gateway shared by
+	// several routes only protect some of them. The hostnames of each route
+	// and the matches of its rules are compiled into SecRules, in phase 1,
+	// which turn the rule engine off for the requests matched by none of
+	// them. Emergency blocks still apply to every
+	// request, and route overlays are applied after the scope.
+	//
+	// +optional
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=16
+	// +listType=atomic
+	RouteScope []RouteScope `json:"routeScope,omitempty"`
+
+	// threatFeeds lists ThreatFeeds in the same namespace as the RuleSet
+	// whose addresses are matched against the client address of requests.
+	// Each feed is compiled into a SecRule that runs before the rules of
+	// the sources, in phase 1, using @ipMatchFromFile on the RuleData the
+	// operator generates for the feed.
+	//
+	// +optional
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=16
+	// +listType=map
+	// +listMapKey=name
+	ThreatFeeds []ThreatFeedReference `json:"threatFeeds,omitempty"`
+
+	// honeypot adds decoy paths, such as /wp-login.php, that no legitimate
+	// client requests. Requests for them are logged with the "honeypot" tag
+	// and, unless disabled, the client addresses are blocked for a while on
+	// every gateway using the RuleSet, turning the WAF into an early warning
+	// sensor. The rules run before the rules of the sources, in phase 1.
+	//
+	// +optional
+	Honeypot *Honeypot `json:"honeypot,omitempty"`
+
+	// botManagement classifies clients by their User-Agent: known bad bots
+	// are blocked, verified crawlers whose address is listed in a data file
+	// are allowed, and other automated clients are challenged. It is
+	// compiled into SecRules and data files that run before the rules of the
+	// sources, in phase 1, after the honeypot.
+	//
+	// +optional
+	BotManagement *BotManagement `json:"botManagement,omitempty"`
+
+	// crs tunes the OWASP Core Rule Set (CRS) of the sources without
+	// hand-written SecLang. It is compiled into a SecAction setting the CRS
+	// transaction variables, which runs before the rules of the sources, in
+	// phase 1, so that CRS uses these values instead of its defaults. Route
+	// overlays override the paranoia level for the requests they match.
+	//
+	// +optional
+	CRS *CRSTuning `json:"crs,omitempty"`
+
+	// ipAccessControl allows or denies requests by client address, without
+	// hand-written SecLang. It is compiled into SecRules using
+	// @ipMatchFromFile, which run before every other rule of the RuleSet but
+	// the emergency blocks, in phase 1.
+	//
+	// +optional
+	IPAccessControl *IPAccessControl `json:"ipAccessControl,omitempty"`
+
+	// lint configures the linter that checks the rules of the sources for
+	// problems that do not prevent them from compiling, such as deprecated
+	// actions, missing metadata, overly broad variables and variables read
+	// in a phase where they are not populated yet. Findings are reported in
+	// status.lintFindings and never block the rules. When omitted, findings
+	// of severity Warning and above are reported.
+	//
+	// +optional
+	Lint *RuleLint `json:"lint,omitempty"`
+}
+
+// -----------------------------------------------------------------------------
+// RuleSet - Exemptions
+// -----------------------------------------------------------------------------
+
+// ExemptionAction is how inspection is relaxed for an exempted request.
+//
+// +kubebuilder:validation:Enum=Bypass;DetectionOnly
+type ExemptionAction string
+
+const (
+	// ExemptionActionBypass turns the rule engine off for the request.
+	ExemptionActionBypass ExemptionAction = "Bypass"
+
+	// ExemptionActionDetectionOnly evaluates and logs the rules for the
+	// request without blocking it.
+	ExemptionActionDetectionOnly ExemptionAction = "DetectionOnly"
+)
+
+// Exemption relaxes inspection for the requests bearing verified JWT claims,
+// or sent by allow-listed ServiceAccounts.
+//
+// +kubebuilder:validation:XValidation:rule="has(self.jwtClaims) != has(self.serviceAccounts)",message="exactly one of jwtClaims or serviceAccounts must be set"
+type Exemption struct {
+	// name identifies the exemption in the generated rules.
+	//
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name,omitempty"`
+
+	// action is how inspection is relaxed for exempted requests.
+	//
+	// When omitted, this means the user has no opinion and the platform
+	// will choose a reasonable default, which is subject to change over time.
+	// The current default is DetectionOnly.
+	//
+	// +optional
+	// +default="DetectionOnly"
+	Action ExemptionAction `json:"action,omitempty"`
+
+	// jwtClaims exempts requests bearing a token whose verified claims
+	// match all of the entries. The claims are read from the request headers
+	// the gateway copies them to once the token is verified, for example with
+	// the outputClaimToHeaders of an Istio RequestAuthentication. The request
+	// must also carry a bearer token.
+	//
+	// +optional
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=8
+	// +listType=atomic
+	JWTClaims []ClaimMatch `json:"jwtClaims,omitempty"`
+
+	// serviceAccounts exempts requests sent over mutual TLS by workloads
+	// running as one of these ServiceAccounts, as identified by the SPIFFE
+	// URI of the X-Forwarded-Client-Cert header the gateway sets.
+	//
+	// +optional
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=32
+	// +listType=atomic
+	ServiceAccounts []ServiceAccountReference `json:"serviceAccounts,omitempty"`
+}
+
+// ClaimMatch matches a verified JWT claim copied to a request header.
+type ClaimMatch struct {
+	// header is the request header the gateway copies the verified claim to.
+	//
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=256
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9-]+$`
+	Header string `json:"header,omitempty"`
+
+	// values are the accepted claim values. The header must equal one of
+	// them.
+	//
+	// +required
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=32
+	// +kubebuilder:validation:items:MinLength=1
+	// +kubebuilder:validation:items:MaxLength=256
+	// +kubebuilder:validation:items:Pattern=`^[^\s"'\\]+$`
+	// +listType=set
+	Values []string `json:"values,omitempty"`
+}
+
+// ServiceAccountReference identifies a ServiceAccount.
+type ServiceAccountReference struct {
+	// namespace is the namespace of the ServiceAccount.
+	//
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Namespace string `json:"namespace,omitempty"`
+
+	// name is the name of the ServiceAccount.
+	//
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-.a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name,omitempty"`
+}
+
+// -----------------------------------------------------------------------------
+// RuleSet - Route Overlays
+// -----------------------------------------------------------------------------
+
+// RouteOverlayRuleEngine is the SecRuleEngine mode of the requests matched
+// by a route overlay.
+//
+// +kubebuilder:validation:Enum=On;DetectionOnly;Off
+type RouteOverlayRuleEngine string
+
+const (
+	// RouteOverlayRuleEngineOn evaluates the rules and blocks the request.
+	RouteOverlayRuleEngineOn RouteOverlayRuleEngine = "On"
+
+	// RouteOverlayRuleEngineDetectionOnly evaluates and logs the rules
+	// without blocking the request.
+	RouteOverlayRuleEngineDetectionOnly RouteOverlayRuleEngine = "DetectionOnly"
+
+	// RouteOverlayRuleEngineOff does not evaluate the rules.
+	RouteOverlayRuleEngineOff RouteOverlayRuleEngine = "Off"
+)
+
+// RouteOverlay changes the inspection of the requests matched by a rule of
+// an HTTPRoute.
+//
+// +kubebuilder:validation:XValidation:rule="has(self.ruleEngine) || has(self.paranoiaLevel)",message="at least one of ruleEngine or paranoiaLevel must be set"
+type RouteOverlay struct {
+	// name identifies the overlay in the generated rules.
+	//
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name,omitempty"`
+
+	// httpRoute is the rule of an HTTPRoute, in the same namespace as the
+	// RuleSet, whose requests the overlay applies to.
+	//
+	// +required
+	HTTPRoute HTTPRouteRuleReference `json:"httpRoute,omitzero"`
+
+	// ruleEngine is the SecRuleEngine mode of the matched requests. When
+	// omitted, the mode of the rules of the sources applies.
+	//
+	// +optional
+	RuleEngine RouteOverlayRuleEngine `json:"ruleEngine,omitempty"`
+
+	// paranoiaLevel sets the blocking paranoia level of the OWASP Core Rule
+	// Set (tx.blocking_paranoia_level) for the matched requests. When
+	// omitted, the paranoia level of the rules of the sources applies.
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=4
+	ParanoiaLevel int32 `json:"paranoiaLevel,omitempty"`
+}
+
+// RouteScopeKind is the kind of route of a RuleSet route scope entry.
+//
+// +kubebuilder:validation:Enum=HTTPRoute;GRPCRoute
+type RouteScopeKind string
+
+const (
+	// RouteScopeKindHTTPRoute selects the requests of a Gateway API
+	// HTTPRoute.
+	RouteScopeKindHTTPRoute RouteScopeKind = "HTTPRoute"
+
+	// RouteScopeKindGRPCRoute selects the requests of a Gateway API
+	// GRPCRoute.
+	RouteScopeKindGRPCRoute RouteScopeKind = "GRPCRoute"
+)
+
+// RouteScope selects the requests of an HTTPRoute or a GRPCRoute that a
+// RuleSet inspects.
+type RouteScope struct {
+	// kind is the kind of the route. TCPRoutes and TLSRoutes are not
+	// supported: their traffic is not HTTP, which the WAF inspects.
+	//
+	// +optional
+	// +kubebuilder:default=HTTPRoute
+	Kind RouteScopeKind `json:"kind,omitempty"`
+
+	// name is the name of the route, in the same namespace as the RuleSet.
+	//
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-.a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name,omitempty"`
+
+	// sectionName is the name of a rule in spec.rules of the route. When
+	// omitted, the requests matched by every rule of the route are in scope.
+	//
+	// +optional
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-.a-z0-9]*[a-z0-9])?$`
+	SectionName string `json:"sectionName,omitempty"`
+}
+
+// HTTPRouteRuleReference identifies a named rule of an HTTPRoute.
+type HTTPRouteRuleReference struct {
+	// name is the name of the HTTPRoute.
+	//
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-.a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name,omitempty"`
+
+	// sectionName is the name of the rule in spec.rules of the HTTPRoute.
+	//
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-.a-z0-9]*[a-z0-9])?$`
+	SectionName string `json:"sectionName,omitempty"`
 }
 
 // -----------------------------------------------------------------------------
@@ -136,26 +461,467 @@ type RuleSetCacheServerConfig struct {
 // RuleSet - References
 // -----------------------------------------------------------------------------
 
-// SourceReference is a reference to a RuleSource object in the same namespace
-// as the RuleSet.
+// SourceReference is a reference to a RuleSource object, by default in the
+// same namespace as the RuleSet.
 type SourceReference struct {
-	// name is the name of the RuleSource in the same namespace as the RuleSet.
+	// kind is the kind of the source, which selects the provider fetching its
+	// rules. When omitted, the source is a RuleSource, the only kind built
+	// into the operator; other kinds are served by providers registered in
+	// custom builds of the operator.
+	//
+	// +optional
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[A-Z][A-Za-z0-9]*$`
+	Kind string `json:"kind,omitempty"`
+
+	// name is the name of the RuleSource.
 	//
 	// +required
 	// +kubebuilder:validation:MinLength=1
 	// +kubebuilder:validation:MaxLength=253
 	Name string `json:"name,omitempty"`
+
+	// namespace is the namespace of the RuleSource. When omitted, the
+	// RuleSource is in the same namespace as the RuleSet.
+	//
+	// A RuleSource in another namespace can only be referenced when a
+	// ReferenceGrant in its namespace, or its
+	// waf.k8s.coraza.io/allow-references-from annotation, allows RuleSets of
+	// the RuleSet namespace to reference it.
+	//
+	// +optional
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Namespace string `json:"namespace,omitempty"`
 }
 
-// DataReference is a reference to a RuleData object in the same namespace
-// as the RuleSet.
+// DataReference is a reference to a RuleData object, by default in the same
+// namespace as the RuleSet.
 type DataReference struct {
-	// name is the name of the RuleData in the same namespace as the RuleSet.
+	// name is the name of the RuleData.
 	//
 	// +required
 	// +kubebuilder:validation:MinLength=1
 	// +kubebuilder:validation:MaxLength=253
 	Name string `json:"name,omitempty"`
+
+	// namespace is the namespace of the RuleData. When omitted, the RuleData
+	// is in the same namespace as the RuleSet.
+	//
+	// A RuleData in another namespace can only be referenced when a
+	// ReferenceGrant in its namespace, or its
+	// waf.k8s.coraza.io/allow-references-from annotation, allows RuleSets of
+	// the RuleSet namespace to reference it.
+	//
+	// +optional
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// ThreatFeedReference is a reference to a ThreatFeed in the same namespace
+// as the RuleSet.
+type ThreatFeedReference struct {
+	// name is the name of the ThreatFeed.
+	//
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=242
+	Name string `json:"name,omitempty"`
+
+	// action is applied to requests whose client address is in the feed:
+	//
+	// - "Deny": block the request with status 403
+	// - "Log": log the match and let the request through
+	//
+	// +optional
+	// +default="Deny"
+	Action ThreatFeedAction `json:"action,omitempty"`
+}
+
+// ThreatFeedAction is the action applied to requests matching a ThreatFeed.
+//
+// +kubebuilder:validation:Enum=Deny;Log
+type ThreatFeedAction string
+
+const (
+	// ThreatFeedActionDeny blocks the matching requests.
+	ThreatFeedActionDeny ThreatFeedAction = "Deny"
+
+	// ThreatFeedActionLog logs the matching requests.
+	ThreatFeedActionLog ThreatFeedAction = "Log"
+)
+
+// -----------------------------------------------------------------------------
+// RuleSet - Honeypot
+// -----------------------------------------------------------------------------
+
+// Honeypot configures the decoy paths of a RuleSet.
+type Honeypot struct {
+	// paths are the decoy request paths. A path ending with "/" also
+	// matches every path below it.
+	//
+	// When omitted, this means the user has no opinion and the platform
+	// will choose a reasonable default, which is subject to change over time.
+	// The current default is a list of paths commonly probed by scanners,
+	// such as /wp-login.php, /.env and /.git/config.
+	//
+	// +optional
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=64
+	// +kubebuilder:validation:items:MinLength=2
+	// +kubebuilder:validation:items:MaxLength=256
+	// +kubebuilder:validation:items:Pattern=`^/[^\s"'\\%]+$`
+	// +listType=set
+	Paths []string `json:"paths,omitempty"`
+
+	// action is applied to requests for a decoy path:
+	//
+	// - "Deny": answer with status 404, as for a missing page
+	// - "Log": log the request and let it through
+	//
+	// +optional
+	// +default="Deny"
+	Action HoneypotAction `json:"action,omitempty"`
+
+	// blockSeconds is how long the requests of a client address that
+	// requested a decoy path are denied with status 403. Hits are reported
+	// by the gateways to the ruleset cache server, so the Engines using the
+	// RuleSet must use ruleSetCacheServer. Zero disables blocking.
+	//
+	// Blocking is reserved: values other than zero are rejected until a
+	// qualified WASM plugin release reports honeypot hits.
+	//
+	// When omitted, this means the user has no opinion and the platform
+	// will choose a reasonable default, which is subject to change over time.
+	// The current default is 0 seconds.
+	//
+	// +optional
+	// +default=0
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:validation:Maximum=2592000
+	// +kubebuilder:validation:XValidation:rule="self == 0",message="blocking honeypot clients is not supported yet: no qualified WASM plugin release reports honeypot hits"
+	BlockSeconds *int32 `json:"blockSeconds,omitempty"`
+}
+
+// HoneypotAction is the action applied to requests for a decoy path.
+//
+// +kubebuilder:validation:Enum=Deny;Log
+type HoneypotAction string
+
+const (
+	// HoneypotActionDeny answers requests for a decoy path with status 404.
+	HoneypotActionDeny HoneypotAction = "Deny"
+
+	// HoneypotActionLog logs requests for a decoy path.
+	HoneypotActionLog HoneypotAction = "Log"
+)
+
+// -----------------------------------------------------------------------------
+// RuleSet - CRS Tuning
+// -----------------------------------------------------------------------------
+
+// CRSTuning sets the paranoia level and anomaly score thresholds of the
+// OWASP Core Rule Set.
+//
+// +kubebuilder:validation:MinProperties=1
+type CRSTuning struct {
+	// paranoiaLevel is the blocking and detection paranoia level of CRS
+	// (tx.blocking_paranoia_level and tx.detection_paranoia_level). Higher
+	// levels enable more rules, which catch more attacks and raise more
+	// false positives.
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=4
+	ParanoiaLevel int32 `json:"paranoiaLevel,omitempty"`
+
+	// inboundAnomalyThreshold is the anomaly score of a request at which
+	// CRS blocks it (tx.inbound_anomaly_score_threshold). CRS defaults to 5,
+	// blocking on a single critical rule match.
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=10000
+	InboundAnomalyThreshold int32 `json:"inboundAnomalyThreshold,omitempty"`
+
+	// outboundAnomalyThreshold is the anomaly score of a response at which
+	// CRS blocks it (tx.outbound_anomaly_score_threshold). CRS defaults
+	// to 4.
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=10000
+	OutboundAnomalyThreshold int32 `json:"outboundAnomalyThreshold,omitempty"`
+}
+
+// -----------------------------------------------------------------------------
+// RuleSet - IP Access Control
+// -----------------------------------------------------------------------------
+
+// IPAccessControl lists the client addresses and CIDR ranges whose requests
+// are allowed or denied. Denied addresses are checked first: an address in
+// both lists is denied.
+//
+// +kubebuilder:validation:MinProperties=1
+type IPAccessControl struct {
+	// allow lists client addresses and CIDR ranges, such as internal
+	// scanners and partners, whose requests skip the other rules of the
+	// RuleSet.
+	//
+	// +optional
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=1024
+	// +kubebuilder:validation:items:MaxLength=43
+	// +kubebuilder:validation:items:Pattern=`^[0-9A-Fa-f:.]+(/[0-9]{1,3})?$`
+	// +listType=set
+	Allow []string `json:"allow,omitempty"`
+
+	// allowFile is the name of a data file from spec.data listing more
+	// allowed addresses and CIDR ranges, one per line, such as a list
+	// maintained outside of the RuleSet.
+	//
+	// +optional
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=253
+	AllowFile string `json:"allowFile,omitempty"`
+
+	// deny lists client addresses and CIDR ranges whose requests are
+	// answered with status 403.
+	//
+	// +optional
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=1024
+	// +kubebuilder:validation:items:MaxLength=43
+	// +kubebuilder:validation:items:Pattern=`^[0-9A-Fa-f:.]+(/[0-9]{1,3})?$`
+	// +listType=set
+	Deny []string `json:"deny,omitempty"`
+
+	// denyFile is the name of a data file from spec.data listing more
+	// denied addresses and CIDR ranges, one per line.
+	//
+	// +optional
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=253
+	DenyFile string `json:"denyFile,omitempty"`
+}
+
+// -----------------------------------------------------------------------------
+// RuleSet - Bot Management
+// -----------------------------------------------------------------------------
+
+// BotManagement configures how the requests of bots are handled.
+type BotManagement struct {
+	// badBots configures the blocking of known bad bots, such as
+	// vulnerability scanners and scrapers.
+	//
+	// When omitted, this means the user has no opinion and the platform
+	// will choose a reasonable default, which is subject to change over time.
+	// The current default denies a built-in list of scanner User-Agents.
+	//
+	// +optional
+	BadBots *BadBots `json:"badBots,omitempty"`
+
+	// unknownBots is the action applied to the requests of automated
+	// clients, identified by a User-Agent such as "bot", "crawler", "curl"
+	// or "python-requests", that are neither a known bad bot nor a verified
+	// crawler:
+	//
+	// - "Allow": let them through without logging
+	// - "Log": log them and let them through
+	// - "Challenge": answer with status 429, asking them to slow down
+	// - "Deny": answer with status 403
+	//
+	// +optional
+	// +default="Challenge"
+	UnknownBots UnknownBotAction `json:"unknownBots,omitempty"`
+
+	// verifiedCrawlers are the crawlers, such as search engines, allowed
+	// through when both their User-Agent and client address match. A
+	// request with the User-Agent of a verified crawler from another address
+	// impersonates it and is handled as a bad bot.
+	//
+	// +optional
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=16
+	// +listType=map
+	// +listMapKey=name
+	VerifiedCrawlers []VerifiedCrawler `json:"verifiedCrawlers,omitempty"`
+}
+
+// BadBots configures the blocking of known bad bots.
+type BadBots struct {
+	// userAgents are case-insensitive substrings of the User-Agents of bad
+	// bots, added to the built-in list.
+	//
+	// +optional
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=256
+	// +kubebuilder:validation:items:MinLength=2
+	// +kubebuilder:validation:items:MaxLength=128
+	// +kubebuilder:validation:items:Pattern=`^[^\s"'\\]+$`
+	// +listType=set
+	UserAgents []string `json:"userAgents,omitempty"`
+
+	// userAgentsFile is the name of a data file from spec.data listing more
+	// User-Agent substrings of bad bots, one per line, such as a list
+	// maintained by a security team.
+	//
+	// +optional
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=253
+	UserAgentsFile string `json:"userAgentsFile,omitempty"`
+
+	// skipBuiltIn disables the built-in list of bad bot User-Agents, so that
+	// only userAgents and userAgentsFile are matched.
+	//
+	// +optional
+	SkipBuiltIn bool `json:"skipBuiltIn,omitempty"`
+
+	// action is applied to the requests of bad bots:
+	//
+	// - "Deny": answer with status 403
+	// - "Log": log the request and let it through
+	//
+	// +optional
+	// +default="Deny"
+	Action BadBotAction `json:"action,omitempty"`
+}
+
+// VerifiedCrawler identifies a crawler by its User-Agent and the addresses
+// it crawls from.
+type VerifiedCrawler struct {
+	// name identifies the crawler in the generated rules.
+	//
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=63
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name,omitempty"`
+
+	// userAgents are case-insensitive substrings of the User-Agent of the
+	// crawler, such as "googlebot".
+	//
+	// +required
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=16
+	// +kubebuilder:validation:items:MinLength=2
+	// +kubebuilder:validation:items:MaxLength=128
+	// +kubebuilder:validation:items:Pattern=`^[^\s"'\\]+$`
+	// +listType=set
+	UserAgents []string `json:"userAgents,omitempty"`
+
+	// addressesFile is the name of a data file from spec.data listing the
+	// addresses and CIDR ranges the crawler connects from, one per line,
+	// such as the ranges whose reverse DNS resolves to the domain of the
+	// crawler, as published by its operator.
+	//
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=253
+	AddressesFile string `json:"addressesFile,omitempty"`
+}
+
+// BadBotAction is the action applied to the requests of bad bots.
+//
+// +kubebuilder:validation:Enum=Deny;Log
+type BadBotAction string
+
+const (
+	// BadBotActionDeny answers the requests of bad bots with status 403.
+	BadBotActionDeny BadBotAction = "Deny"
+
+	// BadBotActionLog logs the requests of bad bots.
+	BadBotActionLog BadBotAction = "Log"
+)
+
+// UnknownBotAction is the action applied to the requests of unknown bots.
+//
+// +kubebuilder:validation:Enum=Allow;Log;Challenge;Deny
+type UnknownBotAction string
+
+const (
+	// UnknownBotActionAllow lets the requests of unknown bots through.
+	UnknownBotActionAllow UnknownBotAction = "Allow"
+
+	// UnknownBotActionLog logs the requests of unknown bots.
+	UnknownBotActionLog UnknownBotAction = "Log"
+
+	// UnknownBotActionChallenge answers the requests of unknown bots with
+	// status 429.
+	UnknownBotActionChallenge UnknownBotAction = "Challenge"
+
+	// UnknownBotActionDeny answers the requests of unknown bots with status
+	// 403.
+	UnknownBotActionDeny UnknownBotAction = "Deny"
+)
+
+// -----------------------------------------------------------------------------
+// RuleSet - Lint
+// -----------------------------------------------------------------------------
+
+// LintSeverity grades a lint finding.
+//
+// +kubebuilder:validation:Enum=Info;Warning;Error
+type LintSeverity string
+
+const (
+	// LintSeverityInfo marks findings that are matters of style, such as a
+	// missing tag.
+	LintSeverityInfo LintSeverity = "Info"
+
+	// LintSeverityWarning marks findings that make rules harder to operate
+	// or likely to misbehave, such as a deprecated action or a blocking rule
+	// without a message.
+	LintSeverityWarning LintSeverity = "Warning"
+
+	// LintSeverityError marks rules that can never behave as intended, such
+	// as a rule reading the request body before it is available.
+	LintSeverityError LintSeverity = "Error"
+)
+
+// RuleLint configures the rule linter of a RuleSet.
+type RuleLint struct {
+	// minSeverity is the lowest severity of the findings reported in the
+	// status.
+	//
+	// When omitted, this means the user has no opinion and the platform
+	// will choose a reasonable default, which is subject to change over time.
+	// The current default is Warning.
+	//
+	// +optional
+	// +default="Warning"
+	MinSeverity LintSeverity `json:"minSeverity,omitempty"`
+}
+
+// LintFinding is a problem the rule linter found in the rules of a RuleSet.
+type LintFinding struct {
+	// ruleID is the id of the rule, or 0 for a directive without one.
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	RuleID int64 `json:"ruleID,omitempty"`
+
+	// check names the lint check that reported the finding, such as
+	// "deprecated-action".
+	//
+	// +required
+	// +kubebuilder:validation:MaxLength=64
+	Check string `json:"check"`
+
+	// severity grades the finding.
+	//
+	// +required
+	Severity LintSeverity `json:"severity"`
+
+	// message describes the finding.
+	//
+	// +required
+	// +kubebuilder:validation:MaxLength=512
+	Message string `json:"message"`
 }
 
 // -----------------------------------------------------------------------------
@@ -172,6 +938,10 @@ type RuleSetStatus struct {
 	// - "Ready": the RuleSet has been processed and the rules have been cached
 	// - "Progressing": the resource is being created or updated
 	// - "Degraded": the resource failed to reach or maintain its desired state
+	// - "RollbackPerformed": the gateways failed to load the latest revision
+	//   of the rules, and the cache server serves the previous one again
+	//
+	// The Degraded reason "PendingApproval" reports rules awaiting approval.
 	//
 	// The status of each condition is one of True, False, or Unknown.
 	//
@@ -183,4 +953,94 @@ type RuleSetStatus struct {
 	// +kubebuilder:validation:MaxItems=16
 	// +optional
 	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+
+	// revision is the revision of the rules published to the cache server,
+	// and when a gateway first reported loading it.
+	//
+	// +optional
+	Revision *RuleSetRevision `json:"revision,omitempty"`
+
+	// pendingRevision is the revision of the rules awaiting approval, when
+	// the OperatorConfig requires the rule changes of the namespace to be
+	// approved. The previous revision is served until a RuleSetApproval
+	// approves it.
+	//
+	// +optional
+	// +kubebuilder:validation:MaxLength=36
+	PendingRevision string `json:"pendingRevision,omitempty"`
+
+	// pendingRevisionAuthors are the users who changed the RuleSet, or the
+	// RuleSources and RuleData it references, since the last revision was
+	// published, as recorded in their waf.k8s.coraza.io/modified-by
+	// annotation. They cannot approve the pending revision.
+	//
+	// +optional
+	// +listType=set
+	// +kubebuilder:validation:MaxItems=32
+	// +kubebuilder:validation:items:MaxLength=512
+	PendingRevisionAuthors []string `json:"pendingRevisionAuthors,omitempty"`
+
+	// rejectedRevisions lists the revisions of the rules the gateways failed
+	// to load, oldest first. A rejected revision is never served again: the
+	// cache server keeps serving the previous revision until the rules
+	// change.
+	//
+	// +optional
+	// +kubebuilder:validation:MaxItems=8
+	// +listType=atomic
+	RejectedRevisions []RejectedRevision `json:"rejectedRevisions,omitempty"`
+
+	// lintFindings lists the problems the rule linter found in the rules of
+	// the sources, at or above spec.lint.minSeverity, most severe first.
+	// Only the first 50 findings are listed.
+	//
+	// +optional
+	// +kubebuilder:validation:MaxItems=50
+	// +listType=atomic
+	LintFindings []LintFinding `json:"lintFindings,omitempty"`
+}
+
+// RuleSetRevision is a revision of the rules published to the cache server.
+type RuleSetRevision struct {
+	// uuid identifies the revision in the cache server.
+	//
+	// +required
+	// +kubebuilder:validation:MaxLength=36
+	UUID string `json:"uuid"`
+
+	// publishTime is when the revision was published.
+	//
+	// +required
+	PublishTime metav1.Time `json:"publishTime"`
+
+	// loadedTime is when a gateway first reported loading the revision.
+	//
+	// +optional
+	LoadedTime *metav1.Time `json:"loadedTime,omitempty"`
+
+	// snapshot is the name of the RuleSetSnapshot recording the revision.
+	//
+	// +optional
+	// +kubebuilder:validation:MaxLength=253
+	Snapshot string `json:"snapshot,omitempty"`
+}
+
+// RejectedRevision is a revision of the rules the gateways failed to load.
+type RejectedRevision struct {
+	// uuid identifies the revision in the cache server.
+	//
+	// +required
+	// +kubebuilder:validation:MaxLength=36
+	UUID string `json:"uuid"`
+
+	// reason explains why the revision was rejected.
+	//
+	// +required
+	// +kubebuilder:validation:MaxLength=1024
+	Reason string `json:"reason"`
+
+	// rejectTime is when the revision was rejected.
+	//
+	// +required
+	RejectTime metav1.Time `json:"rejectTime"`
 }
@@ -0,0 +1,104 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// -----------------------------------------------------------------------------
+// RuleSetApproval - Schema Registration
+// -----------------------------------------------------------------------------
+
+func init() {
+	SchemeBuilder.Register(&RuleSetApproval{}, &RuleSetApprovalList{})
+}
+
+// -----------------------------------------------------------------------------
+// RuleSetApproval
+// -----------------------------------------------------------------------------
+
+// RuleSetApproval approves a revision of the rules of a RuleSet in a
+// namespace where the OperatorConfig requires rule changes to be approved.
+// The RuleSet keeps serving its previous rules until an approval for the
+// revision of its new rules exists. The admission webhooks of the operator
+// record who changed the rules and who created the approval: an approval
+// created by one of the authors of the revision is rejected, and approvals
+// approve nothing while the webhooks are disabled.
+//
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=rsa
+// +kubebuilder:printcolumn:name="RuleSet",type=string,JSONPath=`.spec.ruleSet.name`
+// +kubebuilder:printcolumn:name="Revision",type=string,JSONPath=`.spec.revision`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type RuleSetApproval struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata.
+	//
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitzero"`
+
+	// spec identifies the approved revision.
+	//
+	// +required
+	Spec RuleSetApprovalSpec `json:"spec,omitzero"`
+}
+
+// RuleSetApprovalList contains a list of RuleSetApproval resources.
+//
+// +kubebuilder:object:root=true
+type RuleSetApprovalList struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// ListMeta is standard list metadata.
+	//
+	// +optional
+	metav1.ListMeta `json:"metadata,omitzero"`
+
+	// Items is the list of RuleSetApprovals.
+	//
+	// +required
+	Items []RuleSetApproval `json:"items"`
+}
+
+// -----------------------------------------------------------------------------
+// RuleSetApproval - Spec
+// -----------------------------------------------------------------------------
+
+// RuleSetApprovalSpec identifies the approved revision of the rules of a
+// RuleSet. It cannot be changed: create another RuleSetApproval to approve
+// another revision.
+//
+// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="spec is immutable"
+type RuleSetApprovalSpec struct {
+	// ruleSet is the RuleSet in the same namespace whose rules are approved.
+	//
+	// +required
+	RuleSet RuleSetReference `json:"ruleSet,omitzero"`
+
+	// revision is the UUID of the approved revision of the rules, as shown
+	// in the status.pendingRevision of the RuleSet. Revisions are derived
+	// from the content of the composed rules and data files, so an approval
+	// covers exactly the rules that were reviewed.
+	//
+	// +required
+	// +kubebuilder:validation:MinLength=36
+	// +kubebuilder:validation:MaxLength=36
+	// +kubebuilder:validation:Pattern=`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`
+	Revision string `json:"revision,omitempty"`
+}
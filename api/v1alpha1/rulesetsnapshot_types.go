@@ -0,0 +1,146 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// -----------------------------------------------------------------------------
+// RuleSetSnapshot - Schema Registration
+// -----------------------------------------------------------------------------
+
+func init() {
+	SchemeBuilder.Register(&RuleSetSnapshot{}, &RuleSetSnapshotList{})
+}
+
+// -----------------------------------------------------------------------------
+// RuleSetSnapshot
+// -----------------------------------------------------------------------------
+
+// RuleSetSnapshot records a revision of the rules of a RuleSet published to
+// the cache server: the hash and size of the composed rules, and the sources
+// and data they were composed from. The operator creates one for each
+// revision it publishes, named after the RuleSet and the revision, and keeps
+// the most recent ones. Snapshots are owned by their RuleSet and cannot be
+// changed.
+//
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=rss
+// +kubebuilder:printcolumn:name="RuleSet",type=string,JSONPath=`.spec.ruleSet.name`
+// +kubebuilder:printcolumn:name="Revision",type=string,JSONPath=`.spec.revision`
+// +kubebuilder:printcolumn:name="CRS",type=string,JSONPath=`.spec.crsVersion`
+// +kubebuilder:printcolumn:name="Size",type=integer,JSONPath=`.spec.sizeBytes`
+// +kubebuilder:printcolumn:name="Published",type=date,JSONPath=`.spec.publishTime`
+type RuleSetSnapshot struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata.
+	//
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitzero"`
+
+	// spec describes the published revision.
+	//
+	// +required
+	Spec RuleSetSnapshotSpec `json:"spec,omitzero"`
+}
+
+// RuleSetSnapshotList contains a list of RuleSetSnapshot resources.
+//
+// +kubebuilder:object:root=true
+type RuleSetSnapshotList struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// ListMeta is standard list metadata.
+	//
+	// +optional
+	metav1.ListMeta `json:"metadata,omitzero"`
+
+	// Items is the list of RuleSetSnapshots.
+	//
+	// +required
+	Items []RuleSetSnapshot `json:"items"`
+}
+
+// -----------------------------------------------------------------------------
+// RuleSetSnapshot - Spec
+// -----------------------------------------------------------------------------
+
+// RuleSetSnapshotSpec describes a revision of the rules of a RuleSet
+// published to the cache server. It cannot be changed.
+//
+// +kubebuilder:validation:XValidation:rule="self == oldSelf",message="spec is immutable"
+type RuleSetSnapshotSpec struct {
+	// ruleSet is the RuleSet in the same namespace whose rules were
+	// published.
+	//
+	// +required
+	RuleSet RuleSetReference `json:"ruleSet,omitzero"`
+
+	// revision is the UUID of the revision in the cache server, as shown in
+	// the status.revision of the RuleSet and the status.dataPlane of its
+	// Engines.
+	//
+	// +required
+	// +kubebuilder:validation:MinLength=36
+	// +kubebuilder:validation:MaxLength=36
+	// +kubebuilder:validation:Pattern=`^[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`
+	Revision string `json:"revision,omitempty"`
+
+	// contentHash is the SHA-256 of the composed rules and data files,
+	// which is the same for the same content in every RuleSet.
+	//
+	// +required
+	// +kubebuilder:validation:MaxLength=71
+	// +kubebuilder:validation:Pattern=`^sha256:[0-9a-f]{64}$`
+	ContentHash string `json:"contentHash,omitempty"`
+
+	// sources are the sources of the RuleSet when the revision was
+	// published.
+	//
+	// +optional
+	// +kubebuilder:validation:MaxItems=2048
+	// +listType=atomic
+	Sources []SourceReference `json:"sources,omitempty"`
+
+	// data are the RuleData of the RuleSet when the revision was published.
+	//
+	// +optional
+	// +kubebuilder:validation:MaxItems=256
+	// +listType=atomic
+	Data []DataReference `json:"data,omitempty"`
+
+	// crsVersion is the version of the OWASP CoreRuleSet in the composed
+	// rules, from the ver action of its rules, or empty when they do not
+	// include it.
+	//
+	// +optional
+	// +kubebuilder:validation:MaxLength=64
+	CRSVersion string `json:"crsVersion,omitempty"`
+
+	// sizeBytes is the size of the composed rules and data files.
+	//
+	// +required
+	// +kubebuilder:validation:Minimum=0
+	SizeBytes int64 `json:"sizeBytes"`
+
+	// publishTime is when the revision was published.
+	//
+	// +required
+	PublishTime metav1.Time `json:"publishTime,omitzero"`
+}
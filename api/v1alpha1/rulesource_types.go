@@ -37,6 +37,11 @@ const (
 	// a RuleSource. When set to "false", per-source validation is skipped
 	// (the aggregated RuleSet validation still runs).
 	AnnotationSkipValidation = Group + "/rule-validation"
+
+	// AnnotationDraft marks a RuleSource as a draft awaiting approval, such
+	// as the candidate exclusions produced by an Engine in learning mode.
+	// RuleSets refuse to load a RuleSource while it is set to "true".
+	AnnotationDraft = Group + "/draft"
 )
 
 // -----------------------------------------------------------------------------
@@ -47,7 +52,7 @@ const (
 //
 // +kubebuilder:object:root=true
 // +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
-// +kubebuilder:validation:XValidation:rule="has(self.spec.rules) && self.spec.rules != \"\"",message="rules must be set and non-empty"
+// +kubebuilder:validation:XValidation:rule="(has(self.spec.rules) && self.spec.rules != \"\") || has(self.spec.remoteRules)",message="rules or remoteRules must be set"
 type RuleSource struct {
 	metav1.TypeMeta `json:",inline"`
 
@@ -85,10 +90,69 @@ type RuleSourceList struct {
 
 // RuleSourceSpec defines the content of a RuleSource.
 type RuleSourceSpec struct {
-	// rules contains SecLang rule text.
+	// rules contains SecLang rule text. It is required unless remoteRules
+	// is set, and follows the remote rules, so that it can tune them.
 	//
-	// +required
+	// +optional
 	// +kubebuilder:validation:MinLength=1
 	// +kubebuilder:validation:MaxLength=1572864
 	Rules string `json:"rules,omitempty"`
+
+	// remoteRules includes SecLang rule text downloaded from remote servers,
+	// in list order, like the SecRemoteRules directive. The operator
+	// downloads the rules and verifies them against their SHA-256 before
+	// serving them to the gateways, which never reach the remote servers.
+	//
+	// +optional
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=16
+	// +listType=atomic
+	RemoteRules []RemoteRules `json:"remoteRules,omitempty"`
+}
+
+// RemoteRulesFailAction is what happens when remote rules cannot be
+// downloaded or do not match their SHA-256.
+//
+// +kubebuilder:validation:Enum=Abort;Warn
+type RemoteRulesFailAction string
+
+const (
+	// RemoteRulesFailActionAbort stops the RuleSets using the RuleSource
+	// from publishing new rules: the gateways keep the rules they loaded.
+	RemoteRulesFailActionAbort RemoteRulesFailAction = "Abort"
+
+	// RemoteRulesFailActionWarn leaves the remote rules out, records a
+	// warning event on the RuleSets using the RuleSource, and downloads
+	// them again later.
+	RemoteRulesFailActionWarn RemoteRulesFailAction = "Warn"
+)
+
+// RemoteRules is SecLang rule text on a remote server, pinned to its
+// SHA-256.
+type RemoteRules struct {
+	// url is the HTTP or HTTPS URL of the rules.
+	//
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=2048
+	// +kubebuilder:validation:Pattern=`^https?://`
+	URL string `json:"url,omitempty"`
+
+	// sha256 is the hex-encoded SHA-256 of the rules. Downloaded rules that
+	// do not match it are never served: update it together with the rules
+	// on the remote server.
+	//
+	// +required
+	// +kubebuilder:validation:MinLength=64
+	// +kubebuilder:validation:MaxLength=64
+	// +kubebuilder:validation:Pattern=`^[0-9a-f]{64}$`
+	SHA256 string `json:"sha256,omitempty"`
+
+	// failAction is what happens when the rules cannot be downloaded or do
+	// not match sha256: Abort, the default, keeps serving the previous rules
+	// of the RuleSets, while Warn serves them without these rules.
+	//
+	// +optional
+	// +kubebuilder:default=Abort
+	FailAction RemoteRulesFailAction `json:"failAction,omitempty"`
 }
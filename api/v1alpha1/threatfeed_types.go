@@ -0,0 +1,196 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// -----------------------------------------------------------------------------
+// ThreatFeed - Schema Registration
+// -----------------------------------------------------------------------------
+
+func init() {
+	SchemeBuilder.Register(&ThreatFeed{}, &ThreatFeedList{})
+}
+
+// -----------------------------------------------------------------------------
+// ThreatFeed - Constants
+// -----------------------------------------------------------------------------
+
+// ThreatFeedDataPrefix is the prefix of the name of the RuleData the
+// operator generates for each ThreatFeed, and of its data file.
+const ThreatFeedDataPrefix = "threatfeed-"
+
+// ThreatFeedAuthorizationKey is the key of the Secret referenced by a
+// ThreatFeed whose value is sent as the Authorization header.
+const ThreatFeedAuthorizationKey = "authorization"
+
+// LabelThreatFeedCredentials set to "true" on a Secret lets ThreatFeeds in
+// its namespace use it as their authSecretRef. The operator refuses to send
+// the content of other Secrets to a feed URL.
+const LabelThreatFeedCredentials = Group + "/threatfeed-credentials"
+
+// -----------------------------------------------------------------------------
+// ThreatFeed
+// -----------------------------------------------------------------------------
+
+// ThreatFeed periodically downloads a list of IP addresses and CIDR ranges,
+// such as an IP reputation feed, into a RuleData named
+// "threatfeed-<name>". RuleSets that list the ThreatFeed in
+// spec.threatFeeds match the client address of requests against it.
+//
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Entries",type=integer,JSONPath=`.status.entries`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+// +kubebuilder:validation:XValidation:rule="size(self.metadata.name) <= 242",message="name must be at most 242 characters, so that the name of the generated RuleData is valid"
+type ThreatFeed struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata.
+	//
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitzero"`
+
+	// spec defines the feed to download.
+	//
+	// +required
+	Spec ThreatFeedSpec `json:"spec,omitzero"`
+
+	// status defines the observed state of ThreatFeed.
+	//
+	// +optional
+	Status ThreatFeedStatus `json:"status,omitzero"`
+}
+
+// ThreatFeedList contains a list of ThreatFeed resources.
+//
+// +kubebuilder:object:root=true
+type ThreatFeedList struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// ListMeta is standard list metadata.
+	//
+	// +optional
+	metav1.ListMeta `json:"metadata,omitzero"`
+
+	// Items is the list of ThreatFeeds.
+	//
+	// +required
+	Items []ThreatFeed `json:"items"`
+}
+
+// -----------------------------------------------------------------------------
+// ThreatFeed - Spec
+// -----------------------------------------------------------------------------
+
+// ThreatFeedSpec defines the feed to download.
+type ThreatFeedSpec struct {
+	// url is the HTTPS URL the feed is downloaded from. Its host must be a
+	// public DNS name or address: loopback, private, link-local and
+	// cluster-internal hosts, such as Services, are refused, and so are
+	// redirects to them.
+	//
+	// +required
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=2048
+	// +kubebuilder:validation:Pattern=`^https://[^\s]+$`
+	URL string `json:"url,omitempty"`
+
+	// format is the format of the feed:
+	//
+	// - "PlainText": one IP address or CIDR range per line
+	// - "CSV": comma-separated values with the IP address or CIDR range in
+	//   the first column
+	//
+	// In both formats, blank lines, lines starting with "#" or ";", and
+	// text after the address are ignored, as are lines that do not start
+	// with a valid address, such as CSV headers.
+	//
+	// +optional
+	// +default="PlainText"
+	Format ThreatFeedFormat `json:"format,omitempty"`
+
+	// refreshIntervalSeconds is how often the feed is downloaded again.
+	//
+	// +optional
+	// +default=3600
+	// +kubebuilder:validation:Minimum=300
+	// +kubebuilder:validation:Maximum=604800
+	RefreshIntervalSeconds int32 `json:"refreshIntervalSeconds,omitempty"`
+
+	// authSecretRef is the name of a Secret in the same namespace whose
+	// "authorization" key is sent as the Authorization header, for example
+	// "Bearer <token>". The Secret must be labeled
+	// waf.k8s.coraza.io/threatfeed-credentials=true, and the operator must
+	// be allowed to read Secrets in the namespace.
+	//
+	// +optional
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:MaxLength=253
+	AuthSecretRef string `json:"authSecretRef,omitempty"`
+}
+
+// ThreatFeedFormat is the format of a ThreatFeed.
+//
+// +kubebuilder:validation:Enum=PlainText;CSV
+type ThreatFeedFormat string
+
+const (
+	// ThreatFeedFormatPlainText is a feed with one address per line.
+	ThreatFeedFormatPlainText ThreatFeedFormat = "PlainText"
+
+	// ThreatFeedFormatCSV is a feed with the address in the first column.
+	ThreatFeedFormatCSV ThreatFeedFormat = "CSV"
+)
+
+// -----------------------------------------------------------------------------
+// ThreatFeed - Status
+// -----------------------------------------------------------------------------
+
+// ThreatFeedStatus defines the observed state of ThreatFeed.
+// +kubebuilder:validation:MinProperties=0
+type ThreatFeedStatus struct {
+	// conditions represent the current state of the ThreatFeed resource.
+	//
+	// Standard condition types include:
+	// - "Ready": the feed has been downloaded into its RuleData
+	// - "Degraded": the last download failed; the RuleData keeps the
+	//   entries of the last successful download
+	//
+	// +listType=map
+	// +listMapKey=type
+	// +patchStrategy=merge
+	// +patchMergeKey=type
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=16
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+
+	// lastFetchTime is the time of the last successful download.
+	//
+	// +optional
+	LastFetchTime *metav1.Time `json:"lastFetchTime,omitempty"`
+
+	// entries is the number of addresses and ranges in the RuleData.
+	//
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	Entries int32 `json:"entries,omitempty"`
+}
@@ -25,6 +25,174 @@ import (
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AccessLog) DeepCopyInto(out *AccessLog) {
+	*out = *in
+	if in.Providers != nil {
+		in, out := &in.Providers, &out.Providers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AccessLog.
+func (in *AccessLog) DeepCopy() *AccessLog {
+	if in == nil {
+		return nil
+	}
+	out := new(AccessLog)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AncestorReference) DeepCopyInto(out *AncestorReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AncestorReference.
+func (in *AncestorReference) DeepCopy() *AncestorReference {
+	if in == nil {
+		return nil
+	}
+	out := new(AncestorReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BadBots) DeepCopyInto(out *BadBots) {
+	*out = *in
+	if in.UserAgents != nil {
+		in, out := &in.UserAgents, &out.UserAgents
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BadBots.
+func (in *BadBots) DeepCopy() *BadBots {
+	if in == nil {
+		return nil
+	}
+	out := new(BadBots)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BaselineRuleSource) DeepCopyInto(out *BaselineRuleSource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BaselineRuleSource.
+func (in *BaselineRuleSource) DeepCopy() *BaselineRuleSource {
+	if in == nil {
+		return nil
+	}
+	out := new(BaselineRuleSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BlockResponse) DeepCopyInto(out *BlockResponse) {
+	*out = *in
+	if in.Localized != nil {
+		in, out := &in.Localized, &out.Localized
+		*out = make([]LocalizedBlockResponse, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BlockResponse.
+func (in *BlockResponse) DeepCopy() *BlockResponse {
+	if in == nil {
+		return nil
+	}
+	out := new(BlockResponse)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BotManagement) DeepCopyInto(out *BotManagement) {
+	*out = *in
+	if in.BadBots != nil {
+		in, out := &in.BadBots, &out.BadBots
+		*out = new(BadBots)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.VerifiedCrawlers != nil {
+		in, out := &in.VerifiedCrawlers, &out.VerifiedCrawlers
+		*out = make([]VerifiedCrawler, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BotManagement.
+func (in *BotManagement) DeepCopy() *BotManagement {
+	if in == nil {
+		return nil
+	}
+	out := new(BotManagement)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CRSTuning) DeepCopyInto(out *CRSTuning) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CRSTuning.
+func (in *CRSTuning) DeepCopy() *CRSTuning {
+	if in == nil {
+		return nil
+	}
+	out := new(CRSTuning)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClaimMatch) DeepCopyInto(out *ClaimMatch) {
+	*out = *in
+	if in.Values != nil {
+		in, out := &in.Values, &out.Values
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClaimMatch.
+func (in *ClaimMatch) DeepCopy() *ClaimMatch {
+	if in == nil {
+		return nil
+	}
+	out := new(ClaimMatch)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DataPlaneStatus) DeepCopyInto(out *DataPlaneStatus) {
+	*out = *in
+	in.LastHeartbeatTime.DeepCopyInto(&out.LastHeartbeatTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DataPlaneStatus.
+func (in *DataPlaneStatus) DeepCopy() *DataPlaneStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(DataPlaneStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DataReference) DeepCopyInto(out *DataReference) {
 	*out = *in
@@ -35,56 +203,1764 @@ func (in *DataReference) DeepCopy() *DataReference {
 	if in == nil {
 		return nil
 	}
-	out := new(DataReference)
+	out := new(DataReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DriverConfig) DeepCopyInto(out *DriverConfig) {
+	*out = *in
+	if in.Wasm != nil {
+		in, out := &in.Wasm, &out.Wasm
+		*out = new(WasmDriverConfig)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DriverConfig.
+func (in *DriverConfig) DeepCopy() *DriverConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(DriverConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EffectiveConfig) DeepCopyInto(out *EffectiveConfig) {
+	*out = *in
+	if in.PluginConfigKeys != nil {
+		in, out := &in.PluginConfigKeys, &out.PluginConfigKeys
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EffectiveConfig.
+func (in *EffectiveConfig) DeepCopy() *EffectiveConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(EffectiveConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EmergencyBlock) DeepCopyInto(out *EmergencyBlock) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EmergencyBlock.
+func (in *EmergencyBlock) DeepCopy() *EmergencyBlock {
+	if in == nil {
+		return nil
+	}
+	out := new(EmergencyBlock)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *EmergencyBlock) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EmergencyBlockList) DeepCopyInto(out *EmergencyBlockList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]EmergencyBlock, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EmergencyBlockList.
+func (in *EmergencyBlockList) DeepCopy() *EmergencyBlockList {
+	if in == nil {
+		return nil
+	}
+	out := new(EmergencyBlockList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *EmergencyBlockList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EmergencyBlockSpec) DeepCopyInto(out *EmergencyBlockSpec) {
+	*out = *in
+	if in.EngineSelector != nil {
+		in, out := &in.EngineSelector, &out.EngineSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ClientIPs != nil {
+		in, out := &in.ClientIPs, &out.ClientIPs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EmergencyBlockSpec.
+func (in *EmergencyBlockSpec) DeepCopy() *EmergencyBlockSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(EmergencyBlockSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EmergencyBlockStatus) DeepCopyInto(out *EmergencyBlockStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ExpiresAt != nil {
+		in, out := &in.ExpiresAt, &out.ExpiresAt
+		*out = (*in).DeepCopy()
+	}
+	if in.RuleSets != nil {
+		in, out := &in.RuleSets, &out.RuleSets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EmergencyBlockStatus.
+func (in *EmergencyBlockStatus) DeepCopy() *EmergencyBlockStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(EmergencyBlockStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Engine) DeepCopyInto(out *Engine) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	if in.Status != nil {
+		in, out := &in.Status, &out.Status
+		*out = new(EngineStatus)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Engine.
+func (in *Engine) DeepCopy() *Engine {
+	if in == nil {
+		return nil
+	}
+	out := new(Engine)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Engine) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EngineGuardrails) DeepCopyInto(out *EngineGuardrails) {
+	*out = *in
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllowedWasmImages != nil {
+		in, out := &in.AllowedWasmImages, &out.AllowedWasmImages
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AllowedFailurePolicies != nil {
+		in, out := &in.AllowedFailurePolicies, &out.AllowedFailurePolicies
+		*out = make([]FailurePolicy, len(*in))
+		copy(*out, *in)
+	}
+	if in.BaselineRuleSources != nil {
+		in, out := &in.BaselineRuleSources, &out.BaselineRuleSources
+		*out = make([]BaselineRuleSource, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EngineGuardrails.
+func (in *EngineGuardrails) DeepCopy() *EngineGuardrails {
+	if in == nil {
+		return nil
+	}
+	out := new(EngineGuardrails)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EngineList) DeepCopyInto(out *EngineList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Engine, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EngineList.
+func (in *EngineList) DeepCopy() *EngineList {
+	if in == nil {
+		return nil
+	}
+	out := new(EngineList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *EngineList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EngineProbe) DeepCopyInto(out *EngineProbe) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EngineProbe.
+func (in *EngineProbe) DeepCopy() *EngineProbe {
+	if in == nil {
+		return nil
+	}
+	out := new(EngineProbe)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EngineSchedule) DeepCopyInto(out *EngineSchedule) {
+	*out = *in
+	if in.Windows != nil {
+		in, out := &in.Windows, &out.Windows
+		*out = make([]ScheduleWindow, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EngineSchedule.
+func (in *EngineSchedule) DeepCopy() *EngineSchedule {
+	if in == nil {
+		return nil
+	}
+	out := new(EngineSchedule)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EngineSpec) DeepCopyInto(out *EngineSpec) {
+	*out = *in
+	out.RuleSet = in.RuleSet
+	in.Target.DeepCopyInto(&out.Target)
+	if in.FallbackRuleSet != nil {
+		in, out := &in.FallbackRuleSet, &out.FallbackRuleSet
+		*out = new(RuleSetReference)
+		**out = **in
+	}
+	if in.FailurePolicyDowngrade != nil {
+		in, out := &in.FailurePolicyDowngrade, &out.FailurePolicyDowngrade
+		*out = new(FailurePolicyDowngrade)
+		**out = **in
+	}
+	if in.Schedule != nil {
+		in, out := &in.Schedule, &out.Schedule
+		*out = new(EngineSchedule)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RuleSetCacheServer != nil {
+		in, out := &in.RuleSetCacheServer, &out.RuleSetCacheServer
+		*out = new(RuleSetCacheServerConfig)
+		**out = **in
+	}
+	in.Driver.DeepCopyInto(&out.Driver)
+	if in.ResponseInspection != nil {
+		in, out := &in.ResponseInspection, &out.ResponseInspection
+		*out = new(ResponseInspection)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.InspectionBypass != nil {
+		in, out := &in.InspectionBypass, &out.InspectionBypass
+		*out = new(InspectionBypass)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RuleExclusions != nil {
+		in, out := &in.RuleExclusions, &out.RuleExclusions
+		*out = make([]RuleExclusion, len(*in))
+		copy(*out, *in)
+	}
+	if in.Learning != nil {
+		in, out := &in.Learning, &out.Learning
+		*out = new(LearningConfig)
+		**out = **in
+	}
+	if in.Redaction != nil {
+		in, out := &in.Redaction, &out.Redaction
+		*out = new(Redaction)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AccessLog != nil {
+		in, out := &in.AccessLog, &out.AccessLog
+		*out = new(AccessLog)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.VerdictMetadata != nil {
+		in, out := &in.VerdictMetadata, &out.VerdictMetadata
+		*out = new(VerdictMetadata)
+		**out = **in
+	}
+	if in.RequestCorrelation != nil {
+		in, out := &in.RequestCorrelation, &out.RequestCorrelation
+		*out = new(RequestCorrelation)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BlockResponse != nil {
+		in, out := &in.BlockResponse, &out.BlockResponse
+		*out = new(BlockResponse)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RateLimit != nil {
+		in, out := &in.RateLimit, &out.RateLimit
+		*out = new(RateLimit)
+		**out = **in
+	}
+	if in.Probe != nil {
+		in, out := &in.Probe, &out.Probe
+		*out = new(EngineProbe)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EngineSpec.
+func (in *EngineSpec) DeepCopy() *EngineSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(EngineSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EngineStatus) DeepCopyInto(out *EngineStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Ancestors != nil {
+		in, out := &in.Ancestors, &out.Ancestors
+		*out = make([]PolicyAncestorStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Learning != nil {
+		in, out := &in.Learning, &out.Learning
+		*out = new(LearningStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Schedule != nil {
+		in, out := &in.Schedule, &out.Schedule
+		*out = new(ScheduleStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.FailurePolicyDowngrade != nil {
+		in, out := &in.FailurePolicyDowngrade, &out.FailurePolicyDowngrade
+		*out = new(FailurePolicyDowngradeStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.EffectiveConfig != nil {
+		in, out := &in.EffectiveConfig, &out.EffectiveConfig
+		*out = new(EffectiveConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DataPlane != nil {
+		in, out := &in.DataPlane, &out.DataPlane
+		*out = new(DataPlaneStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Probe != nil {
+		in, out := &in.Probe, &out.Probe
+		*out = new(ProbeStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Targets != nil {
+		in, out := &in.Targets, &out.Targets
+		*out = make([]SelectedTargetStatus, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EngineStatus.
+func (in *EngineStatus) DeepCopy() *EngineStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(EngineStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EngineTarget) DeepCopyInto(out *EngineTarget) {
+	*out = *in
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EngineTarget.
+func (in *EngineTarget) DeepCopy() *EngineTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(EngineTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Exemption) DeepCopyInto(out *Exemption) {
+	*out = *in
+	if in.JWTClaims != nil {
+		in, out := &in.JWTClaims, &out.JWTClaims
+		*out = make([]ClaimMatch, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ServiceAccounts != nil {
+		in, out := &in.ServiceAccounts, &out.ServiceAccounts
+		*out = make([]ServiceAccountReference, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Exemption.
+func (in *Exemption) DeepCopy() *Exemption {
+	if in == nil {
+		return nil
+	}
+	out := new(Exemption)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FailurePolicyDowngrade) DeepCopyInto(out *FailurePolicyDowngrade) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FailurePolicyDowngrade.
+func (in *FailurePolicyDowngrade) DeepCopy() *FailurePolicyDowngrade {
+	if in == nil {
+		return nil
+	}
+	out := new(FailurePolicyDowngrade)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FailurePolicyDowngradeStatus) DeepCopyInto(out *FailurePolicyDowngradeStatus) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	in.ExpireTime.DeepCopyInto(&out.ExpireTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FailurePolicyDowngradeStatus.
+func (in *FailurePolicyDowngradeStatus) DeepCopy() *FailurePolicyDowngradeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(FailurePolicyDowngradeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FalsePositive) DeepCopyInto(out *FalsePositive) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FalsePositive.
+func (in *FalsePositive) DeepCopy() *FalsePositive {
+	if in == nil {
+		return nil
+	}
+	out := new(FalsePositive)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *FalsePositive) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FalsePositiveList) DeepCopyInto(out *FalsePositiveList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]FalsePositive, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FalsePositiveList.
+func (in *FalsePositiveList) DeepCopy() *FalsePositiveList {
+	if in == nil {
+		return nil
+	}
+	out := new(FalsePositiveList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *FalsePositiveList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FalsePositiveSpec) DeepCopyInto(out *FalsePositiveSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FalsePositiveSpec.
+func (in *FalsePositiveSpec) DeepCopy() *FalsePositiveSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(FalsePositiveSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FalsePositiveStatus) DeepCopyInto(out *FalsePositiveStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FalsePositiveStatus.
+func (in *FalsePositiveStatus) DeepCopy() *FalsePositiveStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(FalsePositiveStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GatewayCoverage) DeepCopyInto(out *GatewayCoverage) {
+	*out = *in
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.GatewayClassNames != nil {
+		in, out := &in.GatewayClassNames, &out.GatewayClassNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GatewayCoverage.
+func (in *GatewayCoverage) DeepCopy() *GatewayCoverage {
+	if in == nil {
+		return nil
+	}
+	out := new(GatewayCoverage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPRouteRuleReference) DeepCopyInto(out *HTTPRouteRuleReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPRouteRuleReference.
+func (in *HTTPRouteRuleReference) DeepCopy() *HTTPRouteRuleReference {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPRouteRuleReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Honeypot) DeepCopyInto(out *Honeypot) {
+	*out = *in
+	if in.Paths != nil {
+		in, out := &in.Paths, &out.Paths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.BlockSeconds != nil {
+		in, out := &in.BlockSeconds, &out.BlockSeconds
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Honeypot.
+func (in *Honeypot) DeepCopy() *Honeypot {
+	if in == nil {
+		return nil
+	}
+	out := new(Honeypot)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IPAccessControl) DeepCopyInto(out *IPAccessControl) {
+	*out = *in
+	if in.Allow != nil {
+		in, out := &in.Allow, &out.Allow
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Deny != nil {
+		in, out := &in.Deny, &out.Deny
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IPAccessControl.
+func (in *IPAccessControl) DeepCopy() *IPAccessControl {
+	if in == nil {
+		return nil
+	}
+	out := new(IPAccessControl)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageMirror) DeepCopyInto(out *ImageMirror) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageMirror.
+func (in *ImageMirror) DeepCopy() *ImageMirror {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageMirror)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InspectionBypass) DeepCopyInto(out *InspectionBypass) {
+	*out = *in
+	if in.ContentTypes != nil {
+		in, out := &in.ContentTypes, &out.ContentTypes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.BodyLargerThanBytes != nil {
+		in, out := &in.BodyLargerThanBytes, &out.BodyLargerThanBytes
+		*out = new(int64)
+		**out = **in
+	}
+	if in.Paths != nil {
+		in, out := &in.Paths, &out.Paths
+		*out = make([]InspectionBypassPath, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InspectionBypass.
+func (in *InspectionBypass) DeepCopy() *InspectionBypass {
+	if in == nil {
+		return nil
+	}
+	out := new(InspectionBypass)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InspectionBypassPath) DeepCopyInto(out *InspectionBypassPath) {
+	*out = *in
+	if in.Methods != nil {
+		in, out := &in.Methods, &out.Methods
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InspectionBypassPath.
+func (in *InspectionBypassPath) DeepCopy() *InspectionBypassPath {
+	if in == nil {
+		return nil
+	}
+	out := new(InspectionBypassPath)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LearningConfig) DeepCopyInto(out *LearningConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LearningConfig.
+func (in *LearningConfig) DeepCopy() *LearningConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(LearningConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LearningStatus) DeepCopyInto(out *LearningStatus) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.RuleMatches != nil {
+		in, out := &in.RuleMatches, &out.RuleMatches
+		*out = make([]RuleMatchCount, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LearningStatus.
+func (in *LearningStatus) DeepCopy() *LearningStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(LearningStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LintFinding) DeepCopyInto(out *LintFinding) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LintFinding.
+func (in *LintFinding) DeepCopy() *LintFinding {
+	if in == nil {
+		return nil
+	}
+	out := new(LintFinding)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LocalizedBlockResponse) DeepCopyInto(out *LocalizedBlockResponse) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LocalizedBlockResponse.
+func (in *LocalizedBlockResponse) DeepCopy() *LocalizedBlockResponse {
+	if in == nil {
+		return nil
+	}
+	out := new(LocalizedBlockResponse)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NamespaceQuota) DeepCopyInto(out *NamespaceQuota) {
+	*out = *in
+	if in.MaxEngines != nil {
+		in, out := &in.MaxEngines, &out.MaxEngines
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxRuleSetSize != nil {
+		in, out := &in.MaxRuleSetSize, &out.MaxRuleSetSize
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NamespaceQuota.
+func (in *NamespaceQuota) DeepCopy() *NamespaceQuota {
+	if in == nil {
+		return nil
+	}
+	out := new(NamespaceQuota)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OperatorConfig) DeepCopyInto(out *OperatorConfig) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperatorConfig.
+func (in *OperatorConfig) DeepCopy() *OperatorConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(OperatorConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *OperatorConfig) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OperatorConfigList) DeepCopyInto(out *OperatorConfigList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]OperatorConfig, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperatorConfigList.
+func (in *OperatorConfigList) DeepCopy() *OperatorConfigList {
+	if in == nil {
+		return nil
+	}
+	out := new(OperatorConfigList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *OperatorConfigList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OperatorConfigSpec) DeepCopyInto(out *OperatorConfigSpec) {
+	*out = *in
+	if in.RuleSourceDebounceWindow != nil {
+		in, out := &in.RuleSourceDebounceWindow, &out.RuleSourceDebounceWindow
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.ImageMirrors != nil {
+		in, out := &in.ImageMirrors, &out.ImageMirrors
+		*out = make([]ImageMirror, len(*in))
+		copy(*out, *in)
+	}
+	if in.NamespaceQuota != nil {
+		in, out := &in.NamespaceQuota, &out.NamespaceQuota
+		*out = new(NamespaceQuota)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RuleApproval != nil {
+		in, out := &in.RuleApproval, &out.RuleApproval
+		*out = new(RuleApproval)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.EngineGuardrails != nil {
+		in, out := &in.EngineGuardrails, &out.EngineGuardrails
+		*out = new(EngineGuardrails)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GatewayCoverage != nil {
+		in, out := &in.GatewayCoverage, &out.GatewayCoverage
+		*out = new(GatewayCoverage)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperatorConfigSpec.
+func (in *OperatorConfigSpec) DeepCopy() *OperatorConfigSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(OperatorConfigSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OperatorConfigStatus) DeepCopyInto(out *OperatorConfigStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperatorConfigStatus.
+func (in *OperatorConfigStatus) DeepCopy() *OperatorConfigStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(OperatorConfigStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PolicyAncestorStatus) DeepCopyInto(out *PolicyAncestorStatus) {
+	*out = *in
+	out.AncestorRef = in.AncestorRef
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PolicyAncestorStatus.
+func (in *PolicyAncestorStatus) DeepCopy() *PolicyAncestorStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PolicyAncestorStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProbeStatus) DeepCopyInto(out *ProbeStatus) {
+	*out = *in
+	in.LastProbeTime.DeepCopyInto(&out.LastProbeTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProbeStatus.
+func (in *ProbeStatus) DeepCopy() *ProbeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ProbeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RateLimit) DeepCopyInto(out *RateLimit) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RateLimit.
+func (in *RateLimit) DeepCopy() *RateLimit {
+	if in == nil {
+		return nil
+	}
+	out := new(RateLimit)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Redaction) DeepCopyInto(out *Redaction) {
+	*out = *in
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Cookies != nil {
+		in, out := &in.Cookies, &out.Cookies
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.BodyFields != nil {
+		in, out := &in.BodyFields, &out.BodyFields
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Redaction.
+func (in *Redaction) DeepCopy() *Redaction {
+	if in == nil {
+		return nil
+	}
+	out := new(Redaction)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RejectedRevision) DeepCopyInto(out *RejectedRevision) {
+	*out = *in
+	in.RejectTime.DeepCopyInto(&out.RejectTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RejectedRevision.
+func (in *RejectedRevision) DeepCopy() *RejectedRevision {
+	if in == nil {
+		return nil
+	}
+	out := new(RejectedRevision)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RemoteRules) DeepCopyInto(out *RemoteRules) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RemoteRules.
+func (in *RemoteRules) DeepCopy() *RemoteRules {
+	if in == nil {
+		return nil
+	}
+	out := new(RemoteRules)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RequestCorrelation) DeepCopyInto(out *RequestCorrelation) {
+	*out = *in
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RequestCorrelation.
+func (in *RequestCorrelation) DeepCopy() *RequestCorrelation {
+	if in == nil {
+		return nil
+	}
+	out := new(RequestCorrelation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResponseBodyInspection) DeepCopyInto(out *ResponseBodyInspection) {
+	*out = *in
+	if in.MIMETypes != nil {
+		in, out := &in.MIMETypes, &out.MIMETypes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResponseBodyInspection.
+func (in *ResponseBodyInspection) DeepCopy() *ResponseBodyInspection {
+	if in == nil {
+		return nil
+	}
+	out := new(ResponseBodyInspection)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResponseInspection) DeepCopyInto(out *ResponseInspection) {
+	*out = *in
+	if in.Body != nil {
+		in, out := &in.Body, &out.Body
+		*out = new(ResponseBodyInspection)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResponseInspection.
+func (in *ResponseInspection) DeepCopy() *ResponseInspection {
+	if in == nil {
+		return nil
+	}
+	out := new(ResponseInspection)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RouteOverlay) DeepCopyInto(out *RouteOverlay) {
+	*out = *in
+	out.HTTPRoute = in.HTTPRoute
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RouteOverlay.
+func (in *RouteOverlay) DeepCopy() *RouteOverlay {
+	if in == nil {
+		return nil
+	}
+	out := new(RouteOverlay)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RouteScope) DeepCopyInto(out *RouteScope) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RouteScope.
+func (in *RouteScope) DeepCopy() *RouteScope {
+	if in == nil {
+		return nil
+	}
+	out := new(RouteScope)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RuleApproval) DeepCopyInto(out *RuleApproval) {
+	*out = *in
+	if in.Namespaces != nil {
+		in, out := &in.Namespaces, &out.Namespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleApproval.
+func (in *RuleApproval) DeepCopy() *RuleApproval {
+	if in == nil {
+		return nil
+	}
+	out := new(RuleApproval)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RuleData) DeepCopyInto(out *RuleData) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleData.
+func (in *RuleData) DeepCopy() *RuleData {
+	if in == nil {
+		return nil
+	}
+	out := new(RuleData)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RuleData) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RuleDataList) DeepCopyInto(out *RuleDataList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]RuleData, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleDataList.
+func (in *RuleDataList) DeepCopy() *RuleDataList {
+	if in == nil {
+		return nil
+	}
+	out := new(RuleDataList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RuleDataList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RuleDataSpec) DeepCopyInto(out *RuleDataSpec) {
+	*out = *in
+	if in.Files != nil {
+		in, out := &in.Files, &out.Files
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleDataSpec.
+func (in *RuleDataSpec) DeepCopy() *RuleDataSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RuleDataSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RuleExclusion) DeepCopyInto(out *RuleExclusion) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleExclusion.
+func (in *RuleExclusion) DeepCopy() *RuleExclusion {
+	if in == nil {
+		return nil
+	}
+	out := new(RuleExclusion)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RuleLint) DeepCopyInto(out *RuleLint) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleLint.
+func (in *RuleLint) DeepCopy() *RuleLint {
+	if in == nil {
+		return nil
+	}
+	out := new(RuleLint)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RuleMatchCount) DeepCopyInto(out *RuleMatchCount) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleMatchCount.
+func (in *RuleMatchCount) DeepCopy() *RuleMatchCount {
+	if in == nil {
+		return nil
+	}
+	out := new(RuleMatchCount)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RuleSet) DeepCopyInto(out *RuleSet) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleSet.
+func (in *RuleSet) DeepCopy() *RuleSet {
+	if in == nil {
+		return nil
+	}
+	out := new(RuleSet)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RuleSet) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RuleSetApproval) DeepCopyInto(out *RuleSetApproval) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleSetApproval.
+func (in *RuleSetApproval) DeepCopy() *RuleSetApproval {
+	if in == nil {
+		return nil
+	}
+	out := new(RuleSetApproval)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RuleSetApproval) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RuleSetApprovalList) DeepCopyInto(out *RuleSetApprovalList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]RuleSetApproval, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleSetApprovalList.
+func (in *RuleSetApprovalList) DeepCopy() *RuleSetApprovalList {
+	if in == nil {
+		return nil
+	}
+	out := new(RuleSetApprovalList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RuleSetApprovalList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RuleSetApprovalSpec) DeepCopyInto(out *RuleSetApprovalSpec) {
+	*out = *in
+	out.RuleSet = in.RuleSet
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleSetApprovalSpec.
+func (in *RuleSetApprovalSpec) DeepCopy() *RuleSetApprovalSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RuleSetApprovalSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RuleSetCacheServerConfig) DeepCopyInto(out *RuleSetCacheServerConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleSetCacheServerConfig.
+func (in *RuleSetCacheServerConfig) DeepCopy() *RuleSetCacheServerConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(RuleSetCacheServerConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RuleSetList) DeepCopyInto(out *RuleSetList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]RuleSet, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleSetList.
+func (in *RuleSetList) DeepCopy() *RuleSetList {
+	if in == nil {
+		return nil
+	}
+	out := new(RuleSetList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RuleSetList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RuleSetReference) DeepCopyInto(out *RuleSetReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleSetReference.
+func (in *RuleSetReference) DeepCopy() *RuleSetReference {
+	if in == nil {
+		return nil
+	}
+	out := new(RuleSetReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RuleSetRevision) DeepCopyInto(out *RuleSetRevision) {
+	*out = *in
+	in.PublishTime.DeepCopyInto(&out.PublishTime)
+	if in.LoadedTime != nil {
+		in, out := &in.LoadedTime, &out.LoadedTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleSetRevision.
+func (in *RuleSetRevision) DeepCopy() *RuleSetRevision {
+	if in == nil {
+		return nil
+	}
+	out := new(RuleSetRevision)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RuleSetSnapshot) DeepCopyInto(out *RuleSetSnapshot) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleSetSnapshot.
+func (in *RuleSetSnapshot) DeepCopy() *RuleSetSnapshot {
+	if in == nil {
+		return nil
+	}
+	out := new(RuleSetSnapshot)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RuleSetSnapshot) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RuleSetSnapshotList) DeepCopyInto(out *RuleSetSnapshotList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]RuleSetSnapshot, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleSetSnapshotList.
+func (in *RuleSetSnapshotList) DeepCopy() *RuleSetSnapshotList {
+	if in == nil {
+		return nil
+	}
+	out := new(RuleSetSnapshotList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *RuleSetSnapshotList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RuleSetSnapshotSpec) DeepCopyInto(out *RuleSetSnapshotSpec) {
+	*out = *in
+	out.RuleSet = in.RuleSet
+	if in.Sources != nil {
+		in, out := &in.Sources, &out.Sources
+		*out = make([]SourceReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.Data != nil {
+		in, out := &in.Data, &out.Data
+		*out = make([]DataReference, len(*in))
+		copy(*out, *in)
+	}
+	in.PublishTime.DeepCopyInto(&out.PublishTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleSetSnapshotSpec.
+func (in *RuleSetSnapshotSpec) DeepCopy() *RuleSetSnapshotSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RuleSetSnapshotSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RuleSetSpec) DeepCopyInto(out *RuleSetSpec) {
+	*out = *in
+	if in.Sources != nil {
+		in, out := &in.Sources, &out.Sources
+		*out = make([]SourceReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.Data != nil {
+		in, out := &in.Data, &out.Data
+		*out = make([]DataReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.Exemptions != nil {
+		in, out := &in.Exemptions, &out.Exemptions
+		*out = make([]Exemption, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.RouteOverlays != nil {
+		in, out := &in.RouteOverlays, &out.RouteOverlays
+		*out = make([]RouteOverlay, len(*in))
+		copy(*out, *in)
+	}
+	if in.RouteScope != nil {
+		in, out := &in.RouteScope, &out.RouteScope
+		*out = make([]RouteScope, len(*in))
+		copy(*out, *in)
+	}
+	if in.ThreatFeeds != nil {
+		in, out := &in.ThreatFeeds, &out.ThreatFeeds
+		*out = make([]ThreatFeedReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.Honeypot != nil {
+		in, out := &in.Honeypot, &out.Honeypot
+		*out = new(Honeypot)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.BotManagement != nil {
+		in, out := &in.BotManagement, &out.BotManagement
+		*out = new(BotManagement)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CRS != nil {
+		in, out := &in.CRS, &out.CRS
+		*out = new(CRSTuning)
+		**out = **in
+	}
+	if in.IPAccessControl != nil {
+		in, out := &in.IPAccessControl, &out.IPAccessControl
+		*out = new(IPAccessControl)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Lint != nil {
+		in, out := &in.Lint, &out.Lint
+		*out = new(RuleLint)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleSetSpec.
+func (in *RuleSetSpec) DeepCopy() *RuleSetSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RuleSetSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *DriverConfig) DeepCopyInto(out *DriverConfig) {
+func (in *RuleSetStatus) DeepCopyInto(out *RuleSetStatus) {
 	*out = *in
-	if in.Wasm != nil {
-		in, out := &in.Wasm, &out.Wasm
-		*out = new(WasmDriverConfig)
-		**out = **in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Revision != nil {
+		in, out := &in.Revision, &out.Revision
+		*out = new(RuleSetRevision)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PendingRevisionAuthors != nil {
+		in, out := &in.PendingRevisionAuthors, &out.PendingRevisionAuthors
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.RejectedRevisions != nil {
+		in, out := &in.RejectedRevisions, &out.RejectedRevisions
+		*out = make([]RejectedRevision, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LintFindings != nil {
+		in, out := &in.LintFindings, &out.LintFindings
+		*out = make([]LintFinding, len(*in))
+		copy(*out, *in)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DriverConfig.
-func (in *DriverConfig) DeepCopy() *DriverConfig {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleSetStatus.
+func (in *RuleSetStatus) DeepCopy() *RuleSetStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(DriverConfig)
+	out := new(RuleSetStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Engine) DeepCopyInto(out *Engine) {
+func (in *RuleSource) DeepCopyInto(out *RuleSource) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	if in.Status != nil {
-		in, out := &in.Status, &out.Status
-		*out = new(EngineStatus)
-		(*in).DeepCopyInto(*out)
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Engine.
-func (in *Engine) DeepCopy() *Engine {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleSource.
+func (in *RuleSource) DeepCopy() *RuleSource {
 	if in == nil {
 		return nil
 	}
-	out := new(Engine)
+	out := new(RuleSource)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *Engine) DeepCopyObject() runtime.Object {
+func (in *RuleSource) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -92,31 +1968,31 @@ func (in *Engine) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *EngineList) DeepCopyInto(out *EngineList) {
+func (in *RuleSourceList) DeepCopyInto(out *RuleSourceList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]Engine, len(*in))
+		*out = make([]RuleSource, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EngineList.
-func (in *EngineList) DeepCopy() *EngineList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleSourceList.
+func (in *RuleSourceList) DeepCopy() *RuleSourceList {
 	if in == nil {
 		return nil
 	}
-	out := new(EngineList)
+	out := new(RuleSourceList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *EngineList) DeepCopyObject() runtime.Object {
+func (in *RuleSourceList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -124,166 +2000,130 @@ func (in *EngineList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *EngineSpec) DeepCopyInto(out *EngineSpec) {
+func (in *RuleSourceSpec) DeepCopyInto(out *RuleSourceSpec) {
 	*out = *in
-	out.RuleSet = in.RuleSet
-	out.Target = in.Target
-	if in.RuleSetCacheServer != nil {
-		in, out := &in.RuleSetCacheServer, &out.RuleSetCacheServer
-		*out = new(RuleSetCacheServerConfig)
-		**out = **in
+	if in.RemoteRules != nil {
+		in, out := &in.RemoteRules, &out.RemoteRules
+		*out = make([]RemoteRules, len(*in))
+		copy(*out, *in)
 	}
-	in.Driver.DeepCopyInto(&out.Driver)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EngineSpec.
-func (in *EngineSpec) DeepCopy() *EngineSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleSourceSpec.
+func (in *RuleSourceSpec) DeepCopy() *RuleSourceSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(EngineSpec)
+	out := new(RuleSourceSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *EngineStatus) DeepCopyInto(out *EngineStatus) {
+func (in *ScheduleStatus) DeepCopyInto(out *ScheduleStatus) {
 	*out = *in
-	if in.Conditions != nil {
-		in, out := &in.Conditions, &out.Conditions
-		*out = make([]v1.Condition, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	if in.Window != nil {
+		in, out := &in.Window, &out.Window
+		*out = new(int32)
+		**out = **in
+	}
+	if in.NextTransitionTime != nil {
+		in, out := &in.NextTransitionTime, &out.NextTransitionTime
+		*out = (*in).DeepCopy()
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EngineStatus.
-func (in *EngineStatus) DeepCopy() *EngineStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScheduleStatus.
+func (in *ScheduleStatus) DeepCopy() *ScheduleStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(EngineStatus)
+	out := new(ScheduleStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *EngineTarget) DeepCopyInto(out *EngineTarget) {
+func (in *ScheduleWindow) DeepCopyInto(out *ScheduleWindow) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EngineTarget.
-func (in *EngineTarget) DeepCopy() *EngineTarget {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ScheduleWindow.
+func (in *ScheduleWindow) DeepCopy() *ScheduleWindow {
 	if in == nil {
 		return nil
 	}
-	out := new(EngineTarget)
+	out := new(ScheduleWindow)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *RuleData) DeepCopyInto(out *RuleData) {
+func (in *SelectedTargetStatus) DeepCopyInto(out *SelectedTargetStatus) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleData.
-func (in *RuleData) DeepCopy() *RuleData {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SelectedTargetStatus.
+func (in *SelectedTargetStatus) DeepCopy() *SelectedTargetStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(RuleData)
+	out := new(SelectedTargetStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *RuleData) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *RuleDataList) DeepCopyInto(out *RuleDataList) {
+func (in *ServiceAccountReference) DeepCopyInto(out *ServiceAccountReference) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]RuleData, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleDataList.
-func (in *RuleDataList) DeepCopy() *RuleDataList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceAccountReference.
+func (in *ServiceAccountReference) DeepCopy() *ServiceAccountReference {
 	if in == nil {
 		return nil
 	}
-	out := new(RuleDataList)
+	out := new(ServiceAccountReference)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *RuleDataList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *RuleDataSpec) DeepCopyInto(out *RuleDataSpec) {
+func (in *SourceReference) DeepCopyInto(out *SourceReference) {
 	*out = *in
-	if in.Files != nil {
-		in, out := &in.Files, &out.Files
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleDataSpec.
-func (in *RuleDataSpec) DeepCopy() *RuleDataSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SourceReference.
+func (in *SourceReference) DeepCopy() *SourceReference {
 	if in == nil {
 		return nil
 	}
-	out := new(RuleDataSpec)
+	out := new(SourceReference)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *RuleSet) DeepCopyInto(out *RuleSet) {
+func (in *ThreatFeed) DeepCopyInto(out *ThreatFeed) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
+	out.Spec = in.Spec
 	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleSet.
-func (in *RuleSet) DeepCopy() *RuleSet {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThreatFeed.
+func (in *ThreatFeed) DeepCopy() *ThreatFeed {
 	if in == nil {
 		return nil
 	}
-	out := new(RuleSet)
+	out := new(ThreatFeed)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *RuleSet) DeepCopyObject() runtime.Object {
+func (in *ThreatFeed) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -291,46 +2131,31 @@ func (in *RuleSet) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *RuleSetCacheServerConfig) DeepCopyInto(out *RuleSetCacheServerConfig) {
-	*out = *in
-}
-
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleSetCacheServerConfig.
-func (in *RuleSetCacheServerConfig) DeepCopy() *RuleSetCacheServerConfig {
-	if in == nil {
-		return nil
-	}
-	out := new(RuleSetCacheServerConfig)
-	in.DeepCopyInto(out)
-	return out
-}
-
-// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *RuleSetList) DeepCopyInto(out *RuleSetList) {
+func (in *ThreatFeedList) DeepCopyInto(out *ThreatFeedList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]RuleSet, len(*in))
+		*out = make([]ThreatFeed, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleSetList.
-func (in *RuleSetList) DeepCopy() *RuleSetList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThreatFeedList.
+func (in *ThreatFeedList) DeepCopy() *ThreatFeedList {
 	if in == nil {
 		return nil
 	}
-	out := new(RuleSetList)
+	out := new(ThreatFeedList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *RuleSetList) DeepCopyObject() runtime.Object {
+func (in *ThreatFeedList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -338,47 +2163,37 @@ func (in *RuleSetList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *RuleSetReference) DeepCopyInto(out *RuleSetReference) {
+func (in *ThreatFeedReference) DeepCopyInto(out *ThreatFeedReference) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleSetReference.
-func (in *RuleSetReference) DeepCopy() *RuleSetReference {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThreatFeedReference.
+func (in *ThreatFeedReference) DeepCopy() *ThreatFeedReference {
 	if in == nil {
 		return nil
 	}
-	out := new(RuleSetReference)
+	out := new(ThreatFeedReference)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *RuleSetSpec) DeepCopyInto(out *RuleSetSpec) {
+func (in *ThreatFeedSpec) DeepCopyInto(out *ThreatFeedSpec) {
 	*out = *in
-	if in.Sources != nil {
-		in, out := &in.Sources, &out.Sources
-		*out = make([]SourceReference, len(*in))
-		copy(*out, *in)
-	}
-	if in.Data != nil {
-		in, out := &in.Data, &out.Data
-		*out = make([]DataReference, len(*in))
-		copy(*out, *in)
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleSetSpec.
-func (in *RuleSetSpec) DeepCopy() *RuleSetSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThreatFeedSpec.
+func (in *ThreatFeedSpec) DeepCopy() *ThreatFeedSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(RuleSetSpec)
+	out := new(ThreatFeedSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *RuleSetStatus) DeepCopyInto(out *RuleSetStatus) {
+func (in *ThreatFeedStatus) DeepCopyInto(out *ThreatFeedStatus) {
 	*out = *in
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
@@ -387,102 +2202,53 @@ func (in *RuleSetStatus) DeepCopyInto(out *RuleSetStatus) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
-}
-
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleSetStatus.
-func (in *RuleSetStatus) DeepCopy() *RuleSetStatus {
-	if in == nil {
-		return nil
+	if in.LastFetchTime != nil {
+		in, out := &in.LastFetchTime, &out.LastFetchTime
+		*out = (*in).DeepCopy()
 	}
-	out := new(RuleSetStatus)
-	in.DeepCopyInto(out)
-	return out
-}
-
-// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *RuleSource) DeepCopyInto(out *RuleSource) {
-	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleSource.
-func (in *RuleSource) DeepCopy() *RuleSource {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ThreatFeedStatus.
+func (in *ThreatFeedStatus) DeepCopy() *ThreatFeedStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(RuleSource)
+	out := new(ThreatFeedStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *RuleSource) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *RuleSourceList) DeepCopyInto(out *RuleSourceList) {
+func (in *VerdictMetadata) DeepCopyInto(out *VerdictMetadata) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]RuleSource, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleSourceList.
-func (in *RuleSourceList) DeepCopy() *RuleSourceList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VerdictMetadata.
+func (in *VerdictMetadata) DeepCopy() *VerdictMetadata {
 	if in == nil {
 		return nil
 	}
-	out := new(RuleSourceList)
+	out := new(VerdictMetadata)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *RuleSourceList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
-	}
-	return nil
-}
-
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *RuleSourceSpec) DeepCopyInto(out *RuleSourceSpec) {
+func (in *VerifiedCrawler) DeepCopyInto(out *VerifiedCrawler) {
 	*out = *in
-}
-
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RuleSourceSpec.
-func (in *RuleSourceSpec) DeepCopy() *RuleSourceSpec {
-	if in == nil {
-		return nil
+	if in.UserAgents != nil {
+		in, out := &in.UserAgents, &out.UserAgents
+		*out = make([]string, len(*in))
+		copy(*out, *in)
 	}
-	out := new(RuleSourceSpec)
-	in.DeepCopyInto(out)
-	return out
 }
 
-// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *SourceReference) DeepCopyInto(out *SourceReference) {
-	*out = *in
-}
-
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SourceReference.
-func (in *SourceReference) DeepCopy() *SourceReference {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VerifiedCrawler.
+func (in *VerifiedCrawler) DeepCopy() *VerifiedCrawler {
 	if in == nil {
 		return nil
 	}
-	out := new(SourceReference)
+	out := new(VerifiedCrawler)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -0,0 +1,84 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"fmt"
+
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// Engine - Conversion
+// -----------------------------------------------------------------------------
+
+// ConvertTo converts the Engine to the hub version, v1alpha1. The spec
+// converts as a whole while both versions have the same fields, so that
+// adding a field to one version only fails to compile until the conversion
+// handles it.
+func (src *Engine) ConvertTo(dstRaw conversion.Hub) error {
+	dst, ok := dstRaw.(*wafv1alpha1.Engine)
+	if !ok {
+		return fmt.Errorf("cannot convert Engine to %T", dstRaw)
+	}
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = wafv1alpha1.EngineSpec(src.Spec)
+	dst.Status = src.Status
+	return nil
+}
+
+// ConvertFrom converts the Engine from the hub version, v1alpha1.
+func (dst *Engine) ConvertFrom(srcRaw conversion.Hub) error {
+	src, ok := srcRaw.(*wafv1alpha1.Engine)
+	if !ok {
+		return fmt.Errorf("cannot convert Engine from %T", srcRaw)
+	}
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = EngineSpec(src.Spec)
+	dst.Status = src.Status
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// RuleSet - Conversion
+// -----------------------------------------------------------------------------
+
+// ConvertTo converts the RuleSet to the hub version, v1alpha1.
+func (src *RuleSet) ConvertTo(dstRaw conversion.Hub) error {
+	dst, ok := dstRaw.(*wafv1alpha1.RuleSet)
+	if !ok {
+		return fmt.Errorf("cannot convert RuleSet to %T", dstRaw)
+	}
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = wafv1alpha1.RuleSetSpec(src.Spec)
+	dst.Status = src.Status
+	return nil
+}
+
+// ConvertFrom converts the RuleSet from the hub version, v1alpha1.
+func (dst *RuleSet) ConvertFrom(srcRaw conversion.Hub) error {
+	src, ok := srcRaw.(*wafv1alpha1.RuleSet)
+	if !ok {
+		return fmt.Errorf("cannot convert RuleSet from %T", srcRaw)
+	}
+	dst.ObjectMeta = src.ObjectMeta
+	dst.Spec = RuleSetSpec(src.Spec)
+	dst.Status = src.Status
+	return nil
+}
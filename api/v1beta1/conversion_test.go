@@ -0,0 +1,123 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/conversion"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// assertSameJSON asserts that a and b serialize to the same object, apart
+// from their apiVersion, so that the CRD can serve both versions of a
+// stored object without a conversion webhook.
+func assertSameJSON(t *testing.T, a, b any) {
+	t.Helper()
+	var objects [2]map[string]any
+	for i, obj := range []any{a, b} {
+		data, err := json.Marshal(obj)
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(data, &objects[i]))
+		delete(objects[i], "apiVersion")
+	}
+	assert.Equal(t, objects[0], objects[1])
+}
+
+func TestEngineConversion(t *testing.T) {
+	hub := &wafv1alpha1.Engine{
+		TypeMeta:   metav1.TypeMeta{APIVersion: wafv1alpha1.GroupVersion.String(), Kind: "Engine"},
+		ObjectMeta: metav1.ObjectMeta{Name: "waf", Namespace: "team-a", Labels: map[string]string{"app": "shop"}, Generation: 3},
+		Spec: wafv1alpha1.EngineSpec{
+			RuleSet:         wafv1alpha1.RuleSetReference{Name: "rules"},
+			Target:          wafv1alpha1.EngineTarget{Type: "Gateway", Name: "gateway"},
+			FailurePolicy:   wafv1alpha1.FailurePolicyDegrade,
+			FallbackRuleSet: &wafv1alpha1.RuleSetReference{Name: "emergency"},
+			Mode:            wafv1alpha1.EngineModeDetectionOnly,
+			RuleSetCacheServer: &wafv1alpha1.RuleSetCacheServerConfig{
+				PollIntervalSeconds: 30,
+			},
+		},
+		Status: &wafv1alpha1.EngineStatus{
+			Conditions:    []metav1.Condition{{Type: "Ready", Status: metav1.ConditionTrue, Reason: "Configured"}},
+			ActiveRuleSet: "rules",
+		},
+	}
+
+	var spoke Engine
+	require.NoError(t, spoke.ConvertFrom(hub))
+	assert.Equal(t, hub.ObjectMeta, spoke.ObjectMeta)
+	spoke.APIVersion = GroupVersion.String()
+	spoke.Kind = "Engine"
+	assertSameJSON(t, hub, &spoke)
+
+	var roundTripped wafv1alpha1.Engine
+	require.NoError(t, spoke.ConvertTo(&roundTripped))
+	roundTripped.TypeMeta = hub.TypeMeta
+	assert.Equal(t, hub, &roundTripped)
+
+	assert.Error(t, spoke.ConvertTo(&wafv1alpha1.RuleSet{}))
+	assert.Error(t, spoke.ConvertFrom(&wafv1alpha1.RuleSet{}))
+}
+
+func TestRuleSetConversion(t *testing.T) {
+	hub := &wafv1alpha1.RuleSet{
+		TypeMeta:   metav1.TypeMeta{APIVersion: wafv1alpha1.GroupVersion.String(), Kind: "RuleSet"},
+		ObjectMeta: metav1.ObjectMeta{Name: "rules", Namespace: "team-a", Annotations: map[string]string{wafv1alpha1.AnnotationSkipUnsupportedRulesCheck: "true"}},
+		Spec: wafv1alpha1.RuleSetSpec{
+			Sources: []wafv1alpha1.SourceReference{{Name: "crs-setup"}, {Name: "crs", Namespace: "shared"}},
+			Data:    []wafv1alpha1.DataReference{{Name: "blocklist"}},
+			Lint:    &wafv1alpha1.RuleLint{MinSeverity: wafv1alpha1.LintSeverityWarning},
+		},
+		Status: wafv1alpha1.RuleSetStatus{
+			Conditions:      []metav1.Condition{{Type: "Degraded", Status: metav1.ConditionTrue, Reason: "PendingApproval"}},
+			PendingRevision: "0b6b8a61-3c3e-5b8e-9a53-3b1f2f7a9e10",
+		},
+	}
+
+	var spoke RuleSet
+	require.NoError(t, spoke.ConvertFrom(hub))
+	spoke.APIVersion = GroupVersion.String()
+	spoke.Kind = "RuleSet"
+	assertSameJSON(t, hub, &spoke)
+
+	var roundTripped wafv1alpha1.RuleSet
+	require.NoError(t, spoke.ConvertTo(&roundTripped))
+	roundTripped.TypeMeta = hub.TypeMeta
+	assert.Equal(t, hub, &roundTripped)
+
+	assert.Error(t, spoke.ConvertTo(&wafv1alpha1.Engine{}))
+	assert.Error(t, spoke.ConvertFrom(&wafv1alpha1.Engine{}))
+}
+
+func TestConvertible(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+	require.NoError(t, AddToScheme(scheme))
+
+	for _, obj := range []runtime.Object{&Engine{}, &RuleSet{}} {
+		convertible, err := conversion.IsConvertible(scheme, obj)
+		require.NoError(t, err)
+		assert.True(t, convertible, "%T", obj)
+	}
+}
@@ -0,0 +1,346 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// Engine - Schema Registration
+// -----------------------------------------------------------------------------
+
+func init() {
+	SchemeBuilder.Register(&Engine{}, &EngineList{})
+}
+
+// -----------------------------------------------------------------------------
+// Engine
+// -----------------------------------------------------------------------------
+
+// Engine represents an instance of a Web Application Firewall (WAF) engine.
+//
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="RuleSet",type=string,JSONPath=`.spec.ruleSet.name`
+// +kubebuilder:printcolumn:name="Provider",type=string,JSONPath=`.spec.target.provider`
+// +kubebuilder:printcolumn:name="Target Type",type=string,JSONPath=`.spec.target.type`
+// +kubebuilder:printcolumn:name="Target Name",type=string,JSONPath=`.spec.target.name`
+// +kubebuilder:printcolumn:name="Failure Policy",type=string,JSONPath=`.spec.failurePolicy`
+// +kubebuilder:printcolumn:name="Active RuleSet",type=string,JSONPath=`.status.activeRuleSet`,priority=1
+// +kubebuilder:printcolumn:name="Mode",type=string,JSONPath=`.status.enforcementMode`
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Revision",type=string,JSONPath=`.status.dataPlane.revision`,priority=1
+// +kubebuilder:printcolumn:name="Last Heartbeat",type=date,JSONPath=`.status.dataPlane.lastHeartbeatTime`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type Engine struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata.
+	//
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitzero"`
+
+	// spec defines the desired state of Engine.
+	//
+	// +required
+	Spec EngineSpec `json:"spec,omitzero"`
+
+	// status defines the observed state of Engine.
+	//
+	// +optional
+	Status *wafv1alpha1.EngineStatus `json:"status,omitempty"`
+}
+
+// EngineList contains a list of Engine resources.
+//
+// +kubebuilder:object:root=true
+type EngineList struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// ListMeta is standard list metadata.
+	//
+	// +optional
+	metav1.ListMeta `json:"metadata,omitzero"`
+
+	// Items is the list of Engines.
+	//
+	// +required
+	Items []Engine `json:"items"`
+}
+
+// -----------------------------------------------------------------------------
+// Engine - Spec
+// -----------------------------------------------------------------------------
+
+// EngineSpec defines the desired state of an Engine.
+//
+// +kubebuilder:validation:XValidation:rule="!has(self.driver) || !has(self.driver.type) || (self.target.provider == 'Istio' && self.driver.type == 'wasm')",message="driver type must be compatible with the target provider (Istio supports wasm)"
+// +kubebuilder:validation:XValidation:rule="!has(self.learning) || has(self.ruleSetCacheServer)",message="learning requires ruleSetCacheServer"
+// +kubebuilder:validation:XValidation:rule="(has(self.failurePolicy) && self.failurePolicy == 'degrade') == has(self.fallbackRuleSet)",message="fallbackRuleSet must be set if and only if failurePolicy is degrade"
+// +kubebuilder:validation:XValidation:rule="!has(self.responseInspection)",message="responseInspection is not supported yet: no qualified WASM plugin release supports response inspection"
+// +kubebuilder:validation:XValidation:rule="!has(self.redaction)",message="redaction is not supported yet: no qualified WASM plugin release supports audit log redaction"
+// +kubebuilder:validation:XValidation:rule="!has(self.verdictMetadata)",message="verdictMetadata is not supported yet: no qualified WASM plugin release writes the verdict into the dynamic metadata"
+// +kubebuilder:validation:XValidation:rule="!has(self.requestCorrelation)",message="requestCorrelation is not supported yet: no qualified WASM plugin release records correlation headers in its audit log"
+// +kubebuilder:validation:XValidation:rule="!has(self.blockResponse)",message="blockResponse is not supported yet: no qualified WASM plugin release renders block responses"
+// +kubebuilder:validation:XValidation:rule="!has(self.rateLimit)",message="rateLimit is not supported yet: no qualified WASM plugin release supports rate limiting"
+// +kubebuilder:validation:XValidation:rule="!has(self.learning)",message="learning is not supported yet: no qualified WASM plugin release reports rule matches or switches the rule engine mode"
+type EngineSpec struct {
+	// ruleSet specifies the RuleSet resource that will be used to load rules
+	// into the Engine. The referenced RuleSet must be in the same namespace
+	// as the Engine.
+	//
+	// +required
+	RuleSet wafv1alpha1.RuleSetReference `json:"ruleSet,omitzero"`
+
+	// target identifies the workload that the Engine protects. The operator
+	// derives the workload selector from this reference (e.g., for Gateway
+	// targets, the GEP-1762 gateway-name label is used).
+	//
+	// +required
+	Target wafv1alpha1.EngineTarget `json:"target,omitzero"`
+
+	// failurePolicy determines the behavior when the WAF is not ready or
+	// encounters errors. Valid values are:
+	//
+	// - "Fail": Block traffic when the WAF is not ready or encounters errors
+	// - "Allow": Allow traffic through when the WAF is not ready or encounters errors
+	// - "Degrade": Load the rules of fallbackRuleSet when those of ruleSet
+	//   cannot be loaded, and block traffic when neither can
+	//
+	// When omitted, this means the user has no opinion and the platform
+	// will choose a reasonable default, which is subject to change over time.
+	//
+	// The current default is fail.
+	//
+	// +optional
+	// +default="fail"
+	FailurePolicy wafv1alpha1.FailurePolicy `json:"failurePolicy,omitempty"`
+
+	// fallbackRuleSet specifies the RuleSet, such as a minimal set of
+	// emergency rules, whose rules the Engine loads with failurePolicy
+	// degrade while those of ruleSet cannot be loaded: while the RuleSet
+	// does not exist, or is Degraded before it ever published a revision of
+	// its rules. A Degraded RuleSet that published a revision keeps serving
+	// it, so the Engine keeps using it. The referenced RuleSet must be in
+	// the same namespace as the Engine. status.activeRuleSet reports which
+	// RuleSet the Engine uses.
+	//
+	// +optional
+	FallbackRuleSet *wafv1alpha1.RuleSetReference `json:"fallbackRuleSet,omitempty"`
+
+	// failurePolicyDowngrade temporarily switches a failurePolicy of fail
+	// to allow while the Engine is Degraded, so that a broken WAF does not
+	// block the traffic of the gateway for long. The failure policy is
+	// restored when the Engine recovers or when the downgrade expires,
+	// whichever comes first, and is not downgraded again until the Engine
+	// has recovered.
+	//
+	// When omitted, the Engine keeps failing closed for as long as it is
+	// Degraded.
+	//
+	// +optional
+	FailurePolicyDowngrade *wafv1alpha1.FailurePolicyDowngrade `json:"failurePolicyDowngrade,omitempty"`
+
+	// mode determines whether the Engine blocks the requests matching its
+	// rules. Valid values are:
+	//
+	// - "Enforce": Block the requests matching the rules
+	// - "DetectionOnly": Evaluate and log the rules, but never block, so that
+	//   new rules can be audited against real traffic before they are enforced
+	//
+	// DetectionOnly is reserved: it is rejected until a qualified WASM plugin
+	// release supports switching the rule engine mode.
+	//
+	// When omitted, this means the user has no opinion and the platform
+	// will choose a reasonable default, which is subject to change over time.
+	//
+	// The current default is Enforce.
+	//
+	// +optional
+	// +default="Enforce"
+	// +kubebuilder:validation:XValidation:rule="self != 'DetectionOnly'",message="DetectionOnly is not supported yet: no qualified WASM plugin release supports switching the rule engine mode"
+	Mode wafv1alpha1.EngineMode `json:"mode,omitempty"`
+
+	// schedule switches the mode of the Engine during recurring time
+	// windows, such as DetectionOnly during business hours while a
+	// migration is rolled out and Enforce overnight. Outside of its windows,
+	// the Engine uses mode. Learning mode takes precedence over the
+	// schedule.
+	//
+	// +optional
+	Schedule *wafv1alpha1.EngineSchedule `json:"schedule,omitempty"`
+
+	// ruleSetCacheServer contains configuration for the ruleset cache server.
+	//
+	// When omitted, no cache server will be used and no rulesets will be
+	// dynamically loaded. This implies that your Engine will be deployed with
+	// all rules statically embedded.
+	//
+	// +optional
+	RuleSetCacheServer *wafv1alpha1.RuleSetCacheServerConfig `json:"ruleSetCacheServer,omitempty"`
+
+	// driver configures the mechanism used to deploy the WAF filter into the
+	// target workload. When omitted, the operator uses a default driver for the
+	// underlying Engine (eg.: WASM for Istio)
+	//
+	// +optional
+	Driver wafv1alpha1.DriverConfig `json:"driver,omitempty,omitzero"`
+
+	// responseInspection enables the inspection of responses, so that rules
+	// in the response headers (3) and response body (4) phases take effect,
+	// for example to detect data leaks.
+	//
+	// When omitted, responses are not inspected and rules in these phases
+	// never match.
+	//
+	// responseInspection is reserved: it is rejected until a qualified WASM
+	// plugin release supports response inspection.
+	//
+	// +optional
+	ResponseInspection *wafv1alpha1.ResponseInspection `json:"responseInspection,omitempty"`
+
+	// inspectionBypass turns the rule engine off for requests that are
+	// expensive to inspect and rarely carry attacks, such as video uploads
+	// and large files, or that must never be blocked, such as health
+	// checks, so that they do not pay the inspection latency of the WASM
+	// plugin. The bypass is compiled into SecRules that are composed into
+	// the RuleSet of the Engine, before its rules, and run in phase 1.
+	//
+	// The bypass applies to every Engine loading the RuleSet, so the Engines
+	// using a RuleSet must configure the same bypass; otherwise the RuleSet
+	// is degraded and keeps serving its previous rules.
+	//
+	// +optional
+	InspectionBypass *wafv1alpha1.InspectionBypass `json:"inspectionBypass,omitempty"`
+
+	// ruleExclusions remove rules, or some of the request variables they
+	// inspect, from every transaction of this Engine, so that the team
+	// owning the Engine can suppress false positives without editing the
+	// RuleSources of the RuleSet. They are compiled into SecRules that are
+	// composed into the RuleSet of the Engine, before its rules, and run in
+	// phase 1.
+	//
+	// The exclusions apply to every Engine loading the RuleSet, so the
+	// Engines using a RuleSet must configure the same exclusions; otherwise
+	// the RuleSet is degraded and keeps serving its previous rules.
+	//
+	// +optional
+	// +listType=atomic
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=64
+	RuleExclusions []wafv1alpha1.RuleExclusion `json:"ruleExclusions,omitempty"`
+
+	// learning runs the Engine in learning mode: for the configured
+	// duration, rules only log (detection mode) and the Engine reports which
+	// rules match. All traffic seen while learning is presumed legitimate;
+	// at the end, the operator generates a draft RuleSource removing the
+	// rules that matched it, for review and approval.
+	//
+	// Learning reports matches to the ruleset cache server, so it requires
+	// ruleSetCacheServer.
+	//
+	// learning is reserved: it is rejected until a qualified WASM plugin
+	// release reports rule matches and switches the rule engine mode.
+	//
+	// +optional
+	Learning *wafv1alpha1.LearningConfig `json:"learning,omitempty"`
+
+	// redaction masks sensitive request data in the audit log the Engine
+	// writes, so that it can be enabled where credentials and personal data
+	// must not be logged. The masked values are still inspected by the
+	// rules.
+	//
+	// redaction is reserved: it is rejected until a qualified WASM plugin
+	// release supports it, so that no Engine appears to redact an audit log
+	// that is written in full.
+	//
+	// +optional
+	Redaction *wafv1alpha1.Redaction `json:"redaction,omitempty"`
+
+	// accessLog enables the access log of the targeted gateway through a
+	// generated Istio Telemetry resource, and tags the traces of the gateway
+	// with the Engine, RuleSet and rules revision, so that existing log
+	// pipelines get the WAF context without manual mesh configuration.
+	//
+	// When omitted, the operator does not change the telemetry of the
+	// gateway. Requires the Istio Telemetry API.
+	//
+	// +optional
+	AccessLog *wafv1alpha1.AccessLog `json:"accessLog,omitempty"`
+
+	// verdictMetadata has the WASM plugin write its verdict and the IDs of
+	// the rules that matched into the Envoy dynamic metadata of each
+	// request, so that the filters running after it, such as rate limiters
+	// and external authorization, and the access log can react to WAF
+	// decisions. With metricLabel, the generated Istio Telemetry also labels
+	// the request metrics of the gateway with the verdict.
+	//
+	// verdictMetadata is reserved: it is rejected until a qualified WASM
+	// plugin release writes the verdict into the dynamic metadata.
+	//
+	// +optional
+	VerdictMetadata *wafv1alpha1.VerdictMetadata `json:"verdictMetadata,omitempty"`
+
+	// requestCorrelation has the WASM plugin record the request ID and
+	// trace context headers of each request in its audit log entries, so
+	// that a blocked request can be found in the distributed traces and
+	// application logs of the same request during an investigation.
+	//
+	// requestCorrelation is reserved: it is rejected until a qualified WASM
+	// plugin release records the headers in its audit log.
+	//
+	// +optional
+	RequestCorrelation *wafv1alpha1.RequestCorrelation `json:"requestCorrelation,omitempty"`
+
+	// blockResponse is the response body the WASM plugin sends for blocked
+	// requests, instead of an empty body, so that users get an informative
+	// page without a separate error service. The body is a template whose
+	// placeholders the plugin renders for each request, and can be
+	// localized after the Accept-Language header of the request.
+	//
+	// blockResponse is reserved: it is rejected until a qualified WASM
+	// plugin release renders block responses.
+	//
+	// +optional
+	BlockResponse *wafv1alpha1.BlockResponse `json:"blockResponse,omitempty"`
+
+	// rateLimit limits the number of requests each client may send within a
+	// window, keyed by client address or by a request header, such as an
+	// API key, for basic abuse protection without hand-written SecLang.
+	// Requests over the limit are answered with status 429, or only logged.
+	// Counters are kept by the WASM plugin of each gateway pod, so that the
+	// limit applies to every pod separately.
+	//
+	// rateLimit is reserved: it is rejected until a qualified WASM plugin
+	// release supports rate limiting.
+	//
+	// +optional
+	RateLimit *wafv1alpha1.RateLimit `json:"rateLimit,omitempty"`
+
+	// probe periodically sends a canary request carrying a marker the
+	// Engine blocks to each gateway pod, and reports in status.probe whether
+	// it was blocked: end-to-end proof that the WAF is enforcing, not just
+	// configured. The operator must be able to reach the gateway pods. The
+	// rule blocking the canary requests is composed into the RuleSet of the
+	// Engine, before its rules.
+	//
+	// +optional
+	Probe *wafv1alpha1.EngineProbe `json:"probe,omitempty"`
+}
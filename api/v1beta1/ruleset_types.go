@@ -0,0 +1,203 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// RuleSet - Schema Registration
+// -----------------------------------------------------------------------------
+
+func init() {
+	SchemeBuilder.Register(&RuleSet{}, &RuleSetList{})
+}
+
+// -----------------------------------------------------------------------------
+// RuleSet
+// -----------------------------------------------------------------------------
+
+// RuleSet represents a set of Web Application Firewall (WAF) rules.
+//
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Ready",type=string,JSONPath=`.status.conditions[?(@.type=="Ready")].status`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+type RuleSet struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// metadata is a standard object metadata.
+	//
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitzero"`
+
+	// spec defines the desired state of RuleSet.
+	//
+	// +required
+	Spec RuleSetSpec `json:"spec,omitzero"`
+
+	// status defines the observed state of RuleSet.
+	//
+	// +optional
+	Status wafv1alpha1.RuleSetStatus `json:"status,omitempty,omitzero"`
+}
+
+// RuleSetList contains a list of RuleSet resources.
+//
+// +kubebuilder:object:root=true
+type RuleSetList struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// ListMeta is standard list metadata.
+	//
+	// +optional
+	metav1.ListMeta `json:"metadata,omitzero"`
+
+	// Items is the list of RuleSets.
+	//
+	// +required
+	Items []RuleSet `json:"items"`
+}
+
+// -----------------------------------------------------------------------------
+// RuleSet - Spec
+// -----------------------------------------------------------------------------
+
+// RuleSetSpec defines the desired state of RuleSet.
+type RuleSetSpec struct {
+	// sources is an ordered list of references to RuleSource objects, by
+	// default in the same namespace as the RuleSet. Sources are concatenated
+	// in list order to form the aggregated SecLang string.
+	//
+	// +required
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=2048
+	// +listType=atomic
+	Sources []wafv1alpha1.SourceReference `json:"sources,omitempty"`
+
+	// data is an optional list of references to RuleData objects, by default
+	// in the same namespace as the RuleSet. Data entries are merged to provide
+	// the filesystem for @pmFromFile directives (last-listed wins on duplicate
+	// keys).
+	//
+	// +optional
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=256
+	// +listType=atomic
+	Data []wafv1alpha1.DataReference `json:"data,omitempty"`
+
+	// exemptions relax inspection for requests from trusted callers, such as
+	// internal tooling and health checkers that would otherwise trip the
+	// rules. Each exemption is compiled into SecRules that run before the
+	// rules of the sources, in phase 1.
+	//
+	// +optional
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=64
+	// +listType=map
+	// +listMapKey=name
+	Exemptions []wafv1alpha1.Exemption `json:"exemptions,omitempty"`
+
+	// routeOverlays change the inspection of the requests matched by one
+	// rule of an HTTPRoute, such as detection only for /static and a higher
+	// paranoia level for /api on the same route. Each overlay is compiled
+	// into SecRules matching the hostnames, path, method, headers and query
+	// parameters of the route rule, which run before the rules of the
+	// sources, in phase 1. Exemptions take precedence over overlays.
+	//
+	// +optional
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=32
+	// +listType=map
+	// +listMapKey=name
+	RouteOverlays []wafv1alpha1.RouteOverlay `json:"routeOverlays,omitempty"`
+
+	// routeScope restricts inspection to the requests matched by the listed
+	// HTTPRoutes and GRPCRoutes, so that the Engines of a Gateway shared by
+	// several routes only protect some of them. The hostnames of each route
+	// and the matches of its rules are compiled into SecRules, in phase 1,
+	// which turn the rule engine off for the requests matched by none of
+	// them. Emergency blocks still apply to every
+	// request, and route overlays are applied after the scope.
+	//
+	// +optional
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=16
+	// +listType=atomic
+	RouteScope []wafv1alpha1.RouteScope `json:"routeScope,omitempty"`
+
+	// threatFeeds lists ThreatFeeds in the same namespace as the RuleSet
+	// whose addresses are matched against the client address of requests.
+	// Each feed is compiled into a SecRule that runs before the rules of
+	// the sources, in phase 1, using @ipMatchFromFile on the RuleData the
+	// operator generates for the feed.
+	//
+	// +optional
+	// +kubebuilder:validation:MinItems=1
+	// +kubebuilder:validation:MaxItems=16
+	// +listType=map
+	// +listMapKey=name
+	ThreatFeeds []wafv1alpha1.ThreatFeedReference `json:"threatFeeds,omitempty"`
+
+	// honeypot adds decoy paths, such as /wp-login.php, that no legitimate
+	// client requests. Requests for them are logged with the "honeypot" tag
+	// and, unless disabled, the client addresses are blocked for a while on
+	// every gateway using the RuleSet, turning the WAF into an early warning
+	// sensor. The rules run before the rules of the sources, in phase 1.
+	//
+	// +optional
+	Honeypot *wafv1alpha1.Honeypot `json:"honeypot,omitempty"`
+
+	// botManagement classifies clients by their User-Agent: known bad bots
+	// are blocked, verified crawlers whose address is listed in a data file
+	// are allowed, and other automated clients are challenged. It is
+	// compiled into SecRules and data files that run before the rules of the
+	// sources, in phase 1, after the honeypot.
+	//
+	// +optional
+	BotManagement *wafv1alpha1.BotManagement `json:"botManagement,omitempty"`
+
+	// crs tunes the OWASP Core Rule Set (CRS) of the sources without
+	// hand-written SecLang. It is compiled into a SecAction setting the CRS
+	// transaction variables, which runs before the rules of the sources, in
+	// phase 1, so that CRS uses these values instead of its defaults. Route
+	// overlays override the paranoia level for the requests they match.
+	//
+	// +optional
+	CRS *wafv1alpha1.CRSTuning `json:"crs,omitempty"`
+
+	// ipAccessControl allows or denies requests by client address, without
+	// hand-written SecLang. It is compiled into SecRules using
+	// @ipMatchFromFile, which run before every other rule of the RuleSet but
+	// the emergency blocks, in phase 1.
+	//
+	// +optional
+	IPAccessControl *wafv1alpha1.IPAccessControl `json:"ipAccessControl,omitempty"`
+
+	// lint configures the linter that checks the rules of the sources for
+	// problems that do not prevent them from compiling, such as deprecated
+	// actions, missing metadata, overly broad variables and variables read
+	// in a phase where they are not populated yet. Findings are reported in
+	// status.lintFindings and never block the rules. When omitted, findings
+	// of severity Warning and above are reported.
+	//
+	// +optional
+	Lint *wafv1alpha1.RuleLint `json:"lint,omitempty"`
+}
@@ -15,19 +15,27 @@ limitations under the License.
 */
 
 // kubectl-coraza is a kubectl plugin (kubectl coraza …) for generating RuleSet-related
-// manifests from OWASP CoreRuleSet files on disk. It does not compile rules; the
-// operator validates and compiles after apply.
+// manifests from OWASP CoreRuleSet files on disk, for exporting the WAF resources
+// of a cluster, and for reporting the revisions of the rules live on its gateways. It does not compile rules; the operator validates and compiles after
+// apply.
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"sort"
 	"strings"
 
 	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
 	"github.com/networking-incubator/coraza-kubernetes-operator/tools/corerulesetgen"
+	"github.com/networking-incubator/coraza-kubernetes-operator/tools/wafexport"
+	"github.com/networking-incubator/coraza-kubernetes-operator/tools/wafskew"
 )
 
 // -----------------------------------------------------------------------------
@@ -63,7 +71,11 @@ Validation and Coraza compilation happen in the operator after you apply resourc
 former Makefile Python generator. Output is a multi-document YAML stream on stdout.
 
 RuleSet references RuleSource objects (for rules) and RuleData objects (for data files) in the same
-namespace as the RuleSet; set --namespace when you need metadata.namespace on every object.`,
+namespace as the RuleSet; set --namespace when you need metadata.namespace on every object.
+
+To migrate a raw coraza-proxy-wasm deployment, pass its crs-setup.conf with --crs-setup and its
+exclusion files with --exclusions: they are converted into RuleSources placed in the RuleSet where
+the raw configuration included them.`,
 		RunE: genCRS,
 	}
 
@@ -83,9 +95,45 @@ namespace as the RuleSet; set --namespace when you need metadata.namespace on ev
 	flags.String("dry-run", "", "if set to client, print the same manifests and annotate stderr (no cluster access is performed either way)")
 	flags.Bool("skip-size-check", false, "allow very large rules payloads (not recommended; etcd limits may still reject applies)")
 	flags.String("ignore-unsupported-rules", "wasm", "unsupported-rule profile to exclude (e.g. wasm); set to \"none\" to emit the full CRS (see LIMITATIONS.md)")
+	flags.String("crs-setup", "", "existing crs-setup.conf to convert into the crs-setup RuleSource, loaded after base-rules")
+	flags.StringSlice("exclusions", nil, "existing rule exclusion files to convert into RuleSources, loaded before the CRS rules (after them for *AFTER-CRS* files)")
+
+	export := &cobra.Command{
+		Use:   "export",
+		Short: "Export WAF resources as a restore-ordered multi-document YAML stream",
+		Long: `Lists the OperatorConfig, RuleData, RuleSource, ThreatFeed, FalsePositive, RuleSetApproval, RuleSet and Engine
+resources and writes them to stdout in that order, so that applying the output restores every resource after
+the resources it references. Resources generated by the operator, status, and server-populated
+metadata are left out. Engines keep their original creation time in the
+waf.k8s.coraza.io/created-at annotation, so that the same Engine wins a Gateway after a restore.`,
+		RunE: runExport,
+	}
+	exportFlags := export.Flags()
+	exportFlags.StringP("namespace", "n", "", "namespace to export (default: the namespace of the kubeconfig context)")
+	exportFlags.BoolP("all-namespaces", "A", false, "export every namespace")
+	exportFlags.String("kubeconfig", "", "path to the kubeconfig file (default: $KUBECONFIG or ~/.kube/config)")
+	exportFlags.String("context", "", "kubeconfig context to use")
+
+	skew := &cobra.Command{
+		Use:   "skew",
+		Short: "Report the revisions of the rules and CRS versions live on the gateways of each Engine",
+		Long: `Lists the Engines with the revision of the rules published for their RuleSet, the revision their
+gateways last reported enforcing, the OWASP CoreRuleSet version of both, and the time of the last
+heartbeat. Engines whose gateways do not enforce the published revision, such as after a partial
+rollout, are marked SKEWED. Exits with an error when --fail-on-skew is set and an Engine is skewed.`,
+		RunE: runSkew,
+	}
+	skewFlags := skew.Flags()
+	skewFlags.StringP("namespace", "n", "", "namespace to report (default: the namespace of the kubeconfig context)")
+	skewFlags.BoolP("all-namespaces", "A", false, "report every namespace")
+	skewFlags.String("kubeconfig", "", "path to the kubeconfig file (default: $KUBECONFIG or ~/.kube/config)")
+	skewFlags.String("context", "", "kubeconfig context to use")
+	skewFlags.Bool("fail-on-skew", false, "exit with an error when an Engine is skewed")
 
 	root.AddCommand(generate)
 	generate.AddCommand(coreruleset)
+	root.AddCommand(export)
+	root.AddCommand(skew)
 
 	root.InitDefaultVersionFlag()
 	if err := root.Execute(); err != nil {
@@ -112,6 +160,8 @@ func genCRS(cmd *cobra.Command, _ []string) error {
 	dry, _ := flags.GetString("dry-run")
 	skipSize, _ := flags.GetBool("skip-size-check")
 	ignoreUnsupported, _ := flags.GetString("ignore-unsupported-rules")
+	crsSetup, _ := flags.GetString("crs-setup")
+	exclusions, _ := flags.GetStringSlice("exclusions")
 
 	ignoreSet := map[string]struct{}{}
 	if strings.TrimSpace(ignoreCSV) != "" {
@@ -145,9 +195,99 @@ func genCRS(cmd *cobra.Command, _ []string) error {
 		DryRun:                 strings.EqualFold(strings.TrimSpace(dry), "client"),
 		SkipSizeCheck:          skipSize,
 		IgnoreUnsupportedRules: ignoreUnsupported,
+		CRSSetupFile:           crsSetup,
+		ExclusionFiles:         exclusions,
 		Stderr:                 cmd.ErrOrStderr(),
 	}
 
 	_, err := corerulesetgen.Generate(cmd.OutOrStdout(), opts)
 	return err
 }
+
+// -----------------------------------------------------------------------------
+// Export
+// -----------------------------------------------------------------------------
+
+func runExport(cmd *cobra.Command, _ []string) error {
+	c, namespace, err := newClusterClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	objects, err := wafexport.Export(cmd.Context(), c, wafexport.Options{Namespace: namespace})
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Exported %d resources\n", len(objects))
+	return wafexport.WriteManifests(cmd.OutOrStdout(), objects)
+}
+
+// -----------------------------------------------------------------------------
+// Skew
+// -----------------------------------------------------------------------------
+
+func runSkew(cmd *cobra.Command, _ []string) error {
+	failOnSkew, _ := cmd.Flags().GetBool("fail-on-skew")
+	c, namespace, err := newClusterClient(cmd)
+	if err != nil {
+		return err
+	}
+
+	rows, err := wafskew.Report(cmd.Context(), c, wafskew.Options{Namespace: namespace})
+	if err != nil {
+		return err
+	}
+	if err := wafskew.WriteTable(cmd.OutOrStdout(), rows); err != nil {
+		return err
+	}
+	skewed := wafskew.Skewed(rows)
+	_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "%d of %d Engines skewed\n", skewed, len(rows))
+	if failOnSkew && skewed > 0 {
+		return fmt.Errorf("%d Engines do not enforce the published revision of their RuleSet", skewed)
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Cluster Client
+// -----------------------------------------------------------------------------
+
+// newClusterClient returns a client of the cluster of the kubeconfig flags
+// of cmd, and the namespace of its --namespace and --all-namespaces flags:
+// empty for every namespace.
+func newClusterClient(cmd *cobra.Command) (client.Client, string, error) {
+	flags := cmd.Flags()
+	namespace, _ := flags.GetString("namespace")
+	allNamespaces, _ := flags.GetBool("all-namespaces")
+	kubeconfig, _ := flags.GetString("kubeconfig")
+	kubeContext, _ := flags.GetString("context")
+
+	if allNamespaces && namespace != "" {
+		return nil, "", errors.New("--namespace and --all-namespaces are mutually exclusive")
+	}
+
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kubeconfig
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{CurrentContext: kubeContext})
+	if !allNamespaces && namespace == "" {
+		ns, _, err := clientConfig.Namespace()
+		if err != nil {
+			return nil, "", fmt.Errorf("reading the namespace of the kubeconfig context: %w", err)
+		}
+		namespace = ns
+	}
+
+	restConfig, err := clientConfig.ClientConfig()
+	if err != nil {
+		return nil, "", fmt.Errorf("loading kubeconfig: %w", err)
+	}
+	scheme := runtime.NewScheme()
+	if err := wafv1alpha1.AddToScheme(scheme); err != nil {
+		return nil, "", err
+	}
+	c, err := client.New(restConfig, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, "", fmt.Errorf("creating client: %w", err)
+	}
+	return c, namespace, nil
+}
@@ -17,11 +17,16 @@ limitations under the License.
 package main
 
 import (
+	"crypto/fips140"
 	"crypto/tls"
 	"errors"
 	"flag"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -29,11 +34,17 @@ import (
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	ctrlcache "sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -42,8 +53,10 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/metrics/filters"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	wafv1beta1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1beta1"
 	"github.com/networking-incubator/coraza-kubernetes-operator/internal/controller"
 	"github.com/networking-incubator/coraza-kubernetes-operator/internal/defaults"
 	"github.com/networking-incubator/coraza-kubernetes-operator/internal/rulesets/cache"
@@ -57,6 +70,7 @@ import (
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 	utilruntime.Must(wafv1alpha1.AddToScheme(scheme))
+	utilruntime.Must(wafv1beta1.AddToScheme(scheme))
 	// +kubebuilder:scaffold:scheme
 }
 
@@ -69,6 +83,10 @@ var (
 	setupLog = ctrl.Log.WithName("setup")
 )
 
+// defaultGracefulShutdownTimeout matches the controller-runtime default for
+// how long runnables get to stop.
+const defaultGracefulShutdownTimeout = 30 * time.Second
+
 // -----------------------------------------------------------------------------
 // Main
 // -----------------------------------------------------------------------------
@@ -77,9 +95,9 @@ func main() {
 	cfg := parseFlags()
 	setupLog = ctrl.Log.WithName("setup")
 	logFlags()
-	validateFlags(cfg)
+	validateFlags(&cfg)
 
-	tlsOpts := buildTLSOpts()
+	tlsOpts := buildTLSOpts(cfg.tlsMinVersion, cfg.tlsCipherSuites)
 
 	podNamespace := os.Getenv("POD_NAMESPACE")
 	if podNamespace == "" {
@@ -87,36 +105,80 @@ func main() {
 		os.Exit(1)
 	}
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	restConfig := ctrl.GetConfigOrDie()
+	var dryRunReport *controller.DryRunReport
+	if cfg.dryRunReconcile {
+		setupLog.Info("dry-run reconcile mode enabled: no change is applied to the cluster", "report", cfg.dryRunReport)
+		dryRunReport = controller.NewDryRunReport(ctrl.Log.WithName("dry-run"), cfg.dryRunReport)
+		restConfig.Wrap(controller.DryRunEventsTransport)
+	}
+	kubeClient, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		setupLog.Error(err, "unable to create kubernetes clientset")
+		os.Exit(1)
+	}
+
+	capabilities, err := controller.DetectCapabilities(kubeClient.Discovery())
+	if err != nil {
+		setupLog.Error(err, "unable to detect optional APIs")
+		os.Exit(1)
+	}
+	setupLog.Info("detected optional APIs", "capabilities", capabilities.String())
+
+	mgr, err := ctrl.NewManager(restConfig, ctrl.Options{
 		Scheme:                 scheme,
 		Metrics:                buildMetricsServerOptions(cfg, tlsOpts),
+		WebhookServer:          buildWebhookServer(cfg, tlsOpts),
 		HealthProbeBindAddress: cfg.probeAddr,
+		PprofBindAddress:       cfg.pprofAddr,
 		LeaderElection:         cfg.enableLeaderElect,
 		LeaderElectionID:       "waf.k8s.coraza.io",
-		Cache:                  buildCacheOptions(podNamespace),
+		Cache:                  buildCacheOptions(podNamespace, cfg.watchNamespaces, capabilities),
+		NewClient:              newClientFunc(dryRunReport),
+		// Leave room for the cache server to drain on top of the default
+		// time runnables get to stop.
+		GracefulShutdownTimeout: ptr.To(cfg.cacheDrainPeriod + defaultGracefulShutdownTimeout),
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
-	kubeClient, err := kubernetes.NewForConfig(mgr.GetConfig())
-	if err != nil {
-		setupLog.Error(err, "unable to create kubernetes clientset")
-		os.Exit(1)
-	}
-
 	rulesetCache := setupCacheServer(mgr, cfg, kubeClient)
-	setupIstioPrerequisites(mgr, cfg, podNamespace)
-
-	if err := controller.SetupControllers(mgr, rulesetCache, cfg.envoyClusterName, cfg.istioRevision, cfg.defaultWasmImage, podNamespace, kubeClient); err != nil {
+	setupIstioPrerequisites(mgr, cfg, podNamespace, capabilities)
+	setupStorageVersionMigration(mgr, cfg)
+	setupConversionWebhook(mgr, cfg, podNamespace)
+	setupAdmissionWebhooks(mgr, cfg, podNamespace)
+	setupCapabilityMonitor(mgr, kubeClient, capabilities)
+	setupOrphanSweeper(mgr, cfg, podNamespace, capabilities)
+
+	if cfg.ruleSetHookURL != "" {
+		setupLog.Info("RuleSet hook enabled", "url", cfg.ruleSetHookURL)
+		controller.RegisterRuleSetHook("ruleset-hook-url", controller.NewHTTPRuleSetHook(cfg.ruleSetHookURL, cfg.ruleSetHookTimeout))
+	}
+	if err := controller.SetupControllers(mgr, controller.SetupOptions{
+		RuleSetCache:         rulesetCache,
+		EnvoyClusterName:     cfg.envoyClusterName,
+		IstioRevision:        cfg.istioRevision,
+		DefaultWasmImage:     cfg.defaultWasmImage,
+		OperatorNamespace:    podNamespace,
+		KubeClient:           kubeClient,
+		RuleSourceDebounce:   cfg.ruleSourceDebounce,
+		FleetBackend:         activeFleetBackend(cfg),
+		EnabledControllers:   cfg.controllers,
+		ImageMirrors:         cfg.imageMirrors,
+		VerifyMirroredImages: cfg.verifyMirroredImages,
+		VerifyImagePlatforms: cfg.verifyImagePlatforms,
+		Capabilities:         capabilities,
+		AdmissionWebhooks:    cfg.enableAdmissionWebhooks,
+	}); err != nil {
 		setupLog.Error(err, "unable to setup controllers")
 		os.Exit(1)
 	}
 
 	// +kubebuilder:scaffold:builder
 
-	setupHealthChecks(mgr)
+	setupHealthChecks(mgr, rulesetCache)
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
@@ -130,20 +192,52 @@ func main() {
 // -----------------------------------------------------------------------------
 
 type config struct {
-	metricsAddr       string
-	probeAddr         string
-	enableLeaderElect bool
-	metricsCertPath   string
-	metricsCertName   string
-	metricsCertKey    string
-	cacheGCInterval   time.Duration
-	cacheMaxAge       time.Duration
-	cacheMaxSize      int
-	cacheServerPort   int
-	envoyClusterName  string
-	istioRevision     string
-	defaultWasmImage  string
-	operatorName      string
+	metricsAddr             string
+	probeAddr               string
+	pprofAddr               string
+	enableLeaderElect       bool
+	metricsCertPath         string
+	metricsCertName         string
+	metricsCertKey          string
+	webhookCertPath         string
+	webhookCertName         string
+	webhookCertKey          string
+	webhookCAName           string
+	webhookServiceName      string
+	enableAdmissionWebhooks bool
+	cacheGCInterval         time.Duration
+	cacheMaxAge             time.Duration
+	cacheMaxSize            int
+	cacheServerPort         int
+	cacheDrainPeriod        time.Duration
+	cacheSPIFFEDomain       string
+	cacheSPIFFECertDir      string
+	envoyClusterName        string
+	istioRevision           string
+	defaultWasmImage        string
+	operatorName            string
+	ruleSourceDebounce      time.Duration
+	watchNamespacesRaw      string
+	watchNamespaces         []string
+	tlsMinVersionRaw        string
+	tlsMinVersion           uint16
+	tlsCipherSuitesRaw      string
+	tlsCipherSuites         []uint16
+	enableMulticluster      bool
+	fleetBackend            string
+	controllersRaw          string
+	controllers             []string
+	migrateStorage          bool
+	imageMirrorsRaw         string
+	imageMirrors            []wafv1alpha1.ImageMirror
+	verifyMirroredImages    bool
+	verifyImagePlatforms    bool
+	orphanSweepInterval     time.Duration
+	orphanSweepDryRun       bool
+	dryRunReconcile         bool
+	dryRunReport            string
+	ruleSetHookURL          string
+	ruleSetHookTimeout      time.Duration
 }
 
 func parseFlags() config {
@@ -152,19 +246,69 @@ func parseFlags() config {
 	flag.StringVar(&cfg.metricsAddr, "metrics-bind-address", "0", "The address the metrics endpoint binds to. "+
 		"Use :8443 for HTTPS or leave as 0 to disable the metrics service.")
 	flag.StringVar(&cfg.probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
+	flag.StringVar(&cfg.pprofAddr, "pprof-bind-address", "", "The address the pprof endpoint binds to (e.g. :6060). "+
+		"Only loopback addresses are accepted; a missing host binds to 127.0.0.1. Leave empty or 0 to disable.")
 	flag.BoolVar(&cfg.enableLeaderElect, "leader-elect", false, "Enable leader election for controller manager. "+
 		"Enabling this will ensure there is only one active controller manager.")
 	flag.StringVar(&cfg.metricsCertPath, "metrics-cert-path", "", "The directory that contains the metrics server certificate.")
 	flag.StringVar(&cfg.metricsCertName, "metrics-cert-name", "tls.crt", "The name of the metrics server certificate file.")
 	flag.StringVar(&cfg.metricsCertKey, "metrics-cert-key", "tls.key", "The name of the metrics server key file.")
+	flag.StringVar(&cfg.webhookCertPath, "webhook-cert-path", "", "The directory that contains the serving certificate of the conversion webhook. "+
+		"When empty, the conversion webhook is disabled and the CRDs keep converting between API versions without it")
+	flag.StringVar(&cfg.webhookCertName, "webhook-cert-name", "tls.crt", "The name of the conversion webhook certificate file.")
+	flag.StringVar(&cfg.webhookCertKey, "webhook-cert-key", "tls.key", "The name of the conversion webhook key file.")
+	flag.StringVar(&cfg.webhookCAName, "webhook-ca-name", "ca.crt", "The name of the file of the CA that signed the conversion webhook certificate, "+
+		"which the API server is configured to trust")
+	flag.StringVar(&cfg.webhookServiceName, "webhook-service-name", "", "The name of the Service in the operator namespace routing port 443 to the conversion webhook "+
+		"(required with --webhook-cert-path)")
+	flag.BoolVar(&cfg.enableAdmissionWebhooks, "enable-admission-webhooks", false, "Serve the admission webhooks recording the authors of rule changes and the approvers "+
+		"of RuleSetApprovals, and rejecting Engines that load a missing RuleSet, target an already targeted Gateway or a Gateway of an unsupported GatewayClass, "+
+		"on the webhook server, and have the leader configure them at startup. Required for rule approval (requires --webhook-cert-path)")
 	flag.DurationVar(&cfg.cacheGCInterval, "cache-gc-interval", cache.CacheGCInterval, "How often to check for and remove stale cache entries in the RuleSet cache")
 	flag.DurationVar(&cfg.cacheMaxAge, "cache-max-age", cache.CacheMaxAge, "Maximum age of a cache entry before it's considered stale in the RuleSet cache")
 	flag.IntVar(&cfg.cacheMaxSize, "cache-max-size", cache.CacheMaxSize, fmt.Sprintf("Maximum total size of all cached rules in the RuleSet cache in bytes (default %dMB)", cache.CacheMaxSize/(1024*1024)))
 	flag.IntVar(&cfg.cacheServerPort, "cache-server-port", controller.DefaultRuleSetCacheServerPort, fmt.Sprintf("Port number for the RuleSet cache server to listen on (default %d)", controller.DefaultRuleSetCacheServerPort))
+	flag.DurationVar(&cfg.cacheDrainPeriod, "cache-drain-period", cache.DefaultDrainPeriod, "How long the RuleSet cache server keeps serving after a shutdown signal before it stops "+
+		"(should cover the WASM plugin poll interval; 0 stops immediately)")
+	flag.StringVar(&cfg.cacheSPIFFEDomain, "cache-spiffe-trust-domain", "", "SPIFFE trust domain of the gateways. When set, the RuleSet cache server requires mutual TLS "+
+		"and authorizes each client by the SPIFFE ID of its SVID against the gateways of the Engine its token belongs to")
+	flag.StringVar(&cfg.cacheSPIFFECertDir, "cache-spiffe-cert-dir", "/var/run/secrets/spiffe.io", "The directory that contains the SVID of the RuleSet cache server "+
+		"("+cache.SVIDCertFile+", "+cache.SVIDKeyFile+") and the trust bundle ("+cache.SVIDBundleFile+"), used with --cache-spiffe-trust-domain")
 	flag.StringVar(&cfg.envoyClusterName, "envoy-cluster-name", "", "The Envoy cluster name pointing to the RuleSet cache server (required)")
 	flag.StringVar(&cfg.istioRevision, "istio-revision", "", "The Istio revision label value for managed Istio resources")
 	flag.StringVar(&cfg.defaultWasmImage, "default-wasm-image", resolveDefaultWasmImage(),
 		"Default OCI reference for the Coraza WASM plugin when an Engine omits spec.driver.wasm.image")
+	flag.StringVar(&cfg.imageMirrorsRaw, "image-mirrors", "", "Comma-separated list of source=mirror pairs that rewrite WASM plugin image references, "+
+		"e.g. ghcr.io/networking-incubator=registry.internal/coraza. The longest matching source applies")
+	flag.BoolVar(&cfg.verifyMirroredImages, "verify-mirrored-images", false, "Look up mirrored WASM plugin images in their registry, and degrade Engines whose image is missing")
+	flag.BoolVar(&cfg.verifyImagePlatforms, "verify-image-platforms", false, "Look up the platforms of multi-platform WASM plugin images in their registry, "+
+		"and degrade Engines whose image has no variant for the architecture of a node running their gateway")
+	flag.DurationVar(&cfg.ruleSourceDebounce, "rulesource-debounce-window", controller.DefaultRuleSourceDebounceWindow,
+		"How long to coalesce RuleSource and RuleData changes before recomposing the referencing RuleSets (0 disables debouncing)")
+	flag.StringVar(&cfg.watchNamespacesRaw, "watch-namespaces", "", "Comma-separated list of namespaces whose WAF resources the operator manages. "+
+		"When empty, all namespaces are watched (requires cluster-wide RBAC).")
+	flag.StringVar(&cfg.tlsMinVersionRaw, "tls-min-version", "VersionTLS13", "Minimum TLS version for the metrics endpoint and the conversion webhook (VersionTLS12 or VersionTLS13)")
+	flag.StringVar(&cfg.tlsCipherSuitesRaw, "tls-cipher-suites", "", "Comma-separated list of TLS 1.2 cipher suites (IANA names) for the metrics endpoint. "+
+		"When empty, Go's secure defaults are used. Requires --tls-min-version=VersionTLS12")
+	flag.BoolVar(&cfg.enableMulticluster, "enable-multicluster", false, "Propagate RuleSets and Engines labeled "+wafv1alpha1.LabelPropagate+"=true to the member clusters "+
+		"selected by the fleet backend")
+	flag.StringVar(&cfg.fleetBackend, "fleet-backend", controller.FleetBackendKubeconfig, "How member clusters are selected with --enable-multicluster: "+
+		controller.FleetBackendKubeconfig+" (Secrets labeled "+wafv1alpha1.LabelMemberCluster+"=true in the operator namespace) or "+
+		controller.FleetBackendOCM+" (Open Cluster Management Placements, via ManifestWorks)")
+	flag.StringVar(&cfg.controllersRaw, "enable-controllers", strings.Join(controller.Controllers, ","), "Comma-separated list of controllers to run ("+
+		strings.Join(controller.Controllers, ", ")+"). The fleet controllers are enabled with --enable-multicluster")
+	flag.BoolVar(&cfg.migrateStorage, "migrate-storage-versions", false, "Rewrite stored WAF resources in the current storage version of their CRD at startup, "+
+		"and prune older versions from the CRD status.storedVersions (requires cluster-wide RBAC)")
+	flag.DurationVar(&cfg.orphanSweepInterval, "orphan-sweep-interval", controller.DefaultOrphanSweepInterval, "How often the leader deletes the resources it generated "+
+		"for Engines, RuleSets and ThreatFeeds that no longer exist (0 disables the sweep)")
+	flag.BoolVar(&cfg.orphanSweepDryRun, "orphan-sweep-dry-run", false, "Only log the orphaned resources the sweep finds, and report them in metrics, without deleting them")
+	flag.BoolVar(&cfg.dryRunReconcile, "dry-run-reconcile", false, "Compute the changes the controllers would make to the cluster and report them, "+
+		"sending every write to the API server in dry-run mode so nothing is applied. Disables leader election")
+	flag.StringVar(&cfg.dryRunReport, "dry-run-report", "", "The file the changes computed with --dry-run-reconcile are written to, as JSON. "+
+		"When empty, they are only logged")
+	flag.StringVar(&cfg.ruleSetHookURL, "ruleset-hook-url", "", "The http or https URL of an external policy engine reviewing the rules composed for every RuleSet, "+
+		"which may deny or replace them before they are cached. When empty, no external hook is called")
+	flag.DurationVar(&cfg.ruleSetHookTimeout, "ruleset-hook-timeout", controller.DefaultRuleSetHookTimeout, "How long to wait for the response of --ruleset-hook-url")
 	flag.StringVar(&cfg.operatorName, "operator-name", "", "The operator release name used to derive managed resource names (when unset, Istio prerequisites are skipped)")
 
 	opts := zap.Options{Development: false}
@@ -177,10 +321,24 @@ func parseFlags() config {
 	return cfg
 }
 
+// relatedImageWasmEnv is set by the OLM bundle to the plain (scheme-less)
+// reference of the WASM plugin image listed in the CSV relatedImages, which
+// disconnected installs rewrite to point at their mirror.
+const relatedImageWasmEnv = "RELATED_IMAGE_CORAZA_WASM"
+
+// resolveDefaultWasmImage returns the default for --default-wasm-image:
+// CORAZA_DEFAULT_WASM_IMAGE, then RELATED_IMAGE_CORAZA_WASM, then the
+// built-in default.
 func resolveDefaultWasmImage() string {
 	if v := os.Getenv("CORAZA_DEFAULT_WASM_IMAGE"); v != "" {
 		return v
 	}
+	if v := os.Getenv(relatedImageWasmEnv); v != "" {
+		if strings.HasPrefix(v, "oci://") {
+			return v
+		}
+		return "oci://" + v
+	}
 	return defaults.DefaultCorazaWasmOCIReference
 }
 
@@ -192,10 +350,11 @@ func logFlags() {
 	setupLog.Info("configuration", kvs...)
 }
 
-func buildTLSOpts() []func(*tls.Config) {
+func buildTLSOpts(minVersion uint16, cipherSuites []uint16) []func(*tls.Config) {
 	return []func(*tls.Config){
 		func(c *tls.Config) {
-			c.MinVersion = tls.VersionTLS13
+			c.MinVersion = minVersion
+			c.CipherSuites = cipherSuites
 			// Disable HTTP/2 to mitigate HTTP/2 Rapid Reset (CVE-2023-44487)
 			// and related stream-cancellation DoS attacks.
 			c.NextProtos = []string{"http/1.1"}
@@ -203,6 +362,20 @@ func buildTLSOpts() []func(*tls.Config) {
 	}
 }
 
+// buildWebhookServer returns the server of the conversion webhook, or nil
+// when it is disabled.
+func buildWebhookServer(cfg config, tlsOpts []func(*tls.Config)) webhook.Server {
+	if cfg.webhookCertPath == "" {
+		return nil
+	}
+	return webhook.NewServer(webhook.Options{
+		CertDir:  cfg.webhookCertPath,
+		CertName: cfg.webhookCertName,
+		KeyName:  cfg.webhookCertKey,
+		TLSOpts:  tlsOpts,
+	})
+}
+
 func buildMetricsServerOptions(cfg config, tlsOpts []func(*tls.Config)) metricsserver.Options {
 	opts := metricsserver.Options{
 		BindAddress:    cfg.metricsAddr,
@@ -223,20 +396,73 @@ func buildMetricsServerOptions(cfg config, tlsOpts []func(*tls.Config)) metricss
 	return opts
 }
 
-// buildCacheOptions returns cache options that scope the NetworkPolicy informer
-// to the operator namespace. Without this, the controller would require
-// cluster-wide list/watch on NetworkPolicies.
-func buildCacheOptions(operatorNamespace string) ctrlcache.Options {
-	return ctrlcache.Options{
-		DefaultTransform: ctrlcache.TransformStripManagedFields(),
+// buildCacheOptions returns cache options that scope the NetworkPolicy,
+// OperatorConfig and member cluster Secret informers to the operator
+// namespace. Without this, the controller would require cluster-wide
+// list/watch on NetworkPolicies and Secrets. When watchNamespaces is set, all
+// other informers are limited to those namespaces. Optional kinds missing
+// from capabilities are left out, as the cache fails to start on them.
+func buildCacheOptions(operatorNamespace string, watchNamespaces []string, capabilities controller.Capabilities) ctrlcache.Options {
+	// In namespace-scoped mode every other informer is restricted to the
+	// watched namespaces, so only namespaced RBAC is needed for them.
+	var defaultNamespaces map[string]ctrlcache.Config
+	if len(watchNamespaces) > 0 {
+		defaultNamespaces = make(map[string]ctrlcache.Config, len(watchNamespaces))
+		for _, ns := range watchNamespaces {
+			defaultNamespaces[ns] = ctrlcache.Config{}
+		}
+	}
+
+	opts := ctrlcache.Options{
+		DefaultTransform:  ctrlcache.TransformStripManagedFields(),
+		DefaultNamespaces: defaultNamespaces,
 		ByObject: map[client.Object]ctrlcache.ByObject{
 			&networkingv1.NetworkPolicy{}: {
 				Namespaces: map[string]ctrlcache.Config{
 					operatorNamespace: {},
 				},
 			},
+			&wafv1alpha1.OperatorConfig{}: {
+				Namespaces: map[string]ctrlcache.Config{
+					operatorNamespace: {},
+				},
+			},
+			&corev1.Secret{}: {
+				Namespaces: map[string]ctrlcache.Config{
+					operatorNamespace: {},
+				},
+				Label: labels.SelectorFromSet(labels.Set{
+					wafv1alpha1.LabelMemberCluster: "true",
+				}),
+			},
 		},
 	}
+
+	// Only operator-generated WasmPlugins, Telemetries and ManifestWorks are
+	// watched, so unrelated ones in the cluster neither occupy the cache nor
+	// trigger reconciles.
+	managed := ctrlcache.ByObject{
+		Label: labels.SelectorFromSet(labels.Set{
+			controller.ManagedByLabel: controller.ManagedByValue,
+		}),
+	}
+	if capabilities.Has(controller.CapabilityWasmPlugin) {
+		wasmPlugin := &unstructured.Unstructured{}
+		wasmPlugin.SetGroupVersionKind(controller.WasmPluginGVK)
+		opts.ByObject[wasmPlugin] = managed
+	}
+	if capabilities.Has(controller.CapabilityIstioTelemetry) {
+		telemetry := &unstructured.Unstructured{}
+		telemetry.SetGroupVersionKind(controller.TelemetryGVK)
+		opts.ByObject[telemetry] = managed
+	}
+	if capabilities.Has(controller.CapabilityOCM) {
+		manifestWork := &unstructured.Unstructured{}
+		manifestWork.SetGroupVersionKind(controller.ManifestWorkGVK)
+		opts.ByObject[manifestWork] = managed
+	}
+
+	return opts
 }
 
 func setupCacheServer(mgr ctrl.Manager, cfg config, kubeClient *kubernetes.Clientset) *cache.RuleSetCache {
@@ -255,27 +481,136 @@ func setupCacheServer(mgr ctrl.Manager, cfg config, kubeClient *kubernetes.Clien
 
 	tokenReview := kubeClient.AuthenticationV1().TokenReviews()
 	cacheServer := cache.NewServer(rulesetCache, fmt.Sprintf(":%d", cfg.cacheServerPort), ctrl.Log, gcConfig, tokenReview)
+	cacheServer.SetDrainPeriod(cfg.cacheDrainPeriod)
+	cacheServer.SetMatchReporter(controller.NewLearningReporter(mgr.GetClient(), mgr.GetAPIReader()))
+	cacheServer.SetHoneypotReporter(controller.NewHoneypotReporter(mgr.GetClient(), mgr.GetAPIReader(), mgr.GetEventRecorder("honeypot-reporter")))
+	cacheServer.SetHeartbeatReporter(controller.NewRevisionReporter(mgr.GetClient(), mgr.GetAPIReader()))
+	if cfg.cacheSPIFFEDomain != "" {
+		svids, err := cache.NewSVIDSource(cfg.cacheSPIFFECertDir)
+		if err != nil {
+			setupLog.Error(err, "unable to load the SVID of the cache server", "dir", cfg.cacheSPIFFECertDir)
+			os.Exit(1)
+		}
+		cacheServer.SetSPIFFEAuth(svids, cfg.cacheSPIFFEDomain, controller.NewWorkloadIdentityAuthorizer(mgr.GetClient(), mgr.GetAPIReader()))
+		setupLog.Info("RuleSet cache server authenticates clients by SPIFFE ID", "trustDomain", cfg.cacheSPIFFEDomain)
+	}
 	if err := mgr.Add(cacheServer); err != nil {
 		setupLog.Error(err, "unable to add cache server to manager")
 		os.Exit(1)
 	}
+	if err := mgr.AddReadyzCheck("ruleset-cache-server", cacheServer.ReadyzCheck); err != nil {
+		setupLog.Error(err, "unable to set up ruleset cache server ready check")
+		os.Exit(1)
+	}
 	return rulesetCache
 }
 
-func setupIstioPrerequisites(mgr ctrl.Manager, cfg config, podNamespace string) {
+func setupIstioPrerequisites(mgr ctrl.Manager, cfg config, podNamespace string, capabilities controller.Capabilities) {
 	if cfg.operatorName == "" {
 		setupLog.Info("Skipping Istio prerequisites: --operator-name not set")
 		return
 	}
+	if !capabilities.Has(controller.CapabilityIstioNetworking) {
+		setupLog.Info("Skipping Istio prerequisites: the Istio ServiceEntry and DestinationRule APIs are not installed")
+		return
+	}
 
 	istioPrereqs := controller.NewIstioPrerequisites(mgr.GetClient(), mgr.GetAPIReader(), cfg.operatorName, podNamespace, cfg.istioRevision)
+	istioPrereqs.SetMutualTLS(cfg.cacheSPIFFEDomain != "")
 	if err := mgr.Add(istioPrereqs); err != nil {
 		setupLog.Error(err, "unable to add Istio prerequisites runnable to manager")
 		os.Exit(1)
 	}
 }
 
-func setupHealthChecks(mgr ctrl.Manager) {
+func setupStorageVersionMigration(mgr ctrl.Manager, cfg config) {
+	if !cfg.migrateStorage {
+		return
+	}
+
+	if err := mgr.Add(controller.NewStorageVersionMigrator(mgr.GetClient(), mgr.GetAPIReader())); err != nil {
+		setupLog.Error(err, "unable to add storage version migration runnable to manager")
+		os.Exit(1)
+	}
+}
+
+// setupConversionWebhook serves the conversion of Engines and RuleSets
+// between their API versions, and has the leader point their CRDs to it.
+func setupConversionWebhook(mgr ctrl.Manager, cfg config, podNamespace string) {
+	if cfg.webhookCertPath == "" {
+		return
+	}
+
+	for _, obj := range []client.Object{&wafv1beta1.Engine{}, &wafv1beta1.RuleSet{}} {
+		if err := ctrl.NewWebhookManagedBy(mgr, obj).Complete(); err != nil {
+			setupLog.Error(err, "unable to create conversion webhook", "kind", fmt.Sprintf("%T", obj))
+			os.Exit(1)
+		}
+	}
+
+	caBundle := readWebhookCABundle(cfg)
+	configurer := controller.NewConversionWebhookConfigurer(mgr.GetClient(), podNamespace, cfg.webhookServiceName, caBundle)
+	if err := mgr.Add(configurer); err != nil {
+		setupLog.Error(err, "unable to add conversion webhook configuration runnable to manager")
+		os.Exit(1)
+	}
+}
+
+// setupAdmissionWebhooks serves the admission webhooks recording the
+// authors and approvers of rule changes and validating Engines, and has the
+// leader configure them. Engines are validated against the API server
+// rather than the cache, so that an Engine created right after its RuleSet
+// is not rejected.
+func setupAdmissionWebhooks(mgr ctrl.Manager, cfg config, podNamespace string) {
+	if !cfg.enableAdmissionWebhooks {
+		return
+	}
+
+	server := mgr.GetWebhookServer()
+	server.Register(controller.ModifiedByWebhookPath, &webhook.Admission{Handler: controller.NewModifiedByWebhook()})
+	server.Register(controller.ApprovedByWebhookPath, &webhook.Admission{Handler: controller.NewApprovedByWebhook()})
+	server.Register(controller.ApprovalWebhookPath, &webhook.Admission{Handler: controller.NewApprovalWebhook(mgr.GetClient())})
+	server.Register(controller.EngineWebhookPath, &webhook.Admission{Handler: controller.NewEngineWebhook(mgr.GetAPIReader())})
+
+	caBundle := readWebhookCABundle(cfg)
+	configurer := controller.NewAdmissionWebhookConfigurer(mgr.GetClient(), podNamespace, cfg.webhookServiceName, caBundle, cfg.watchNamespaces)
+	if err := mgr.Add(configurer); err != nil {
+		setupLog.Error(err, "unable to add admission webhook configuration runnable to manager")
+		os.Exit(1)
+	}
+}
+
+// readWebhookCABundle returns the CA that signed the certificate of the
+// webhook server, which the API server is configured to trust.
+func readWebhookCABundle(cfg config) []byte {
+	caBundle, err := os.ReadFile(filepath.Join(cfg.webhookCertPath, cfg.webhookCAName))
+	if err != nil {
+		setupLog.Error(err, "unable to read the CA of the webhook certificate")
+		os.Exit(1)
+	}
+	return caBundle
+}
+
+func setupOrphanSweeper(mgr ctrl.Manager, cfg config, podNamespace string, capabilities controller.Capabilities) {
+	if cfg.orphanSweepInterval <= 0 {
+		return
+	}
+
+	sweeper := controller.NewOrphanSweeper(mgr.GetClient(), mgr.GetAPIReader(), capabilities, podNamespace, cfg.watchNamespaces, cfg.orphanSweepInterval, cfg.orphanSweepDryRun)
+	if err := mgr.Add(sweeper); err != nil {
+		setupLog.Error(err, "unable to add orphan sweeper runnable to manager")
+		os.Exit(1)
+	}
+}
+
+func setupCapabilityMonitor(mgr ctrl.Manager, kubeClient *kubernetes.Clientset, capabilities controller.Capabilities) {
+	if err := mgr.Add(controller.NewCapabilityMonitor(kubeClient.Discovery(), capabilities)); err != nil {
+		setupLog.Error(err, "unable to add capability monitor runnable to manager")
+		os.Exit(1)
+	}
+}
+
+func setupHealthChecks(mgr ctrl.Manager, rulesetCache *cache.RuleSetCache) {
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
@@ -284,6 +619,12 @@ func setupHealthChecks(mgr ctrl.Manager) {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	// Keep a starting replica out of the cache Service until it can serve the
+	// RuleSets the leader has already reported as ready.
+	if err := mgr.AddReadyzCheck("ruleset-cache", controller.NewRuleSetCacheReadyCheck(mgr.GetClient(), rulesetCache)); err != nil {
+		setupLog.Error(err, "unable to set up ruleset cache ready check")
+		os.Exit(1)
+	}
 }
 
 // -----------------------------------------------------------------------------
@@ -303,7 +644,129 @@ func validateDefaultWasmImage(ref string) error {
 	return nil
 }
 
-func validateFlags(cfg config) {
+// resolvePprofBindAddress guards the pprof endpoint, which exposes heap,
+// allocation, and goroutine profiles that may contain sensitive data. An
+// address without a host is bound to 127.0.0.1, and any non-loopback host is
+// rejected. Profiles remain reachable through kubectl port-forward.
+func resolvePprofBindAddress(addr string) (string, error) {
+	if addr == "" || addr == "0" {
+		return "", nil
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid address %q: %w", addr, err)
+	}
+	if port == "" {
+		return "", fmt.Errorf("invalid address %q: port is required", addr)
+	}
+	if host == "" {
+		return net.JoinHostPort("127.0.0.1", port), nil
+	}
+	if host == "localhost" {
+		return addr, nil
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return "", fmt.Errorf("host %q is not a loopback address", host)
+	}
+	return addr, nil
+}
+
+// parseWatchNamespaces splits a comma-separated namespace list, trimming
+// whitespace and dropping duplicates. An empty string means all namespaces.
+func parseWatchNamespaces(raw string) ([]string, error) {
+	var namespaces []string
+	seen := map[string]bool{}
+	for _, ns := range strings.Split(raw, ",") {
+		ns = strings.TrimSpace(ns)
+		if ns == "" || seen[ns] {
+			continue
+		}
+		if errs := validation.IsDNS1123Label(ns); len(errs) > 0 {
+			return nil, fmt.Errorf("namespace %q: %s", ns, strings.Join(errs, "; "))
+		}
+		seen[ns] = true
+		namespaces = append(namespaces, ns)
+	}
+	return namespaces, nil
+}
+
+// parseEnableControllers splits the --enable-controllers value into the
+// controllers to run, rejecting unknown names and an empty selection.
+func parseEnableControllers(raw string) ([]string, error) {
+	var controllers []string
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || slices.Contains(controllers, name) {
+			continue
+		}
+		if !slices.Contains(controller.Controllers, name) {
+			return nil, fmt.Errorf("unknown controller %q: must be one of %s", name, strings.Join(controller.Controllers, ", "))
+		}
+		controllers = append(controllers, name)
+	}
+	if len(controllers) == 0 {
+		return nil, errors.New("at least one controller must be enabled")
+	}
+	return controllers, nil
+}
+
+// tlsVersions are the accepted --tls-min-version values. Versions older than
+// TLS 1.2 are not offered.
+var tlsVersions = map[string]uint16{
+	"VersionTLS12": tls.VersionTLS12,
+	"VersionTLS13": tls.VersionTLS13,
+}
+
+// fipsCipherSuites are the TLS 1.2 cipher suites approved for FIPS 140-3,
+// which are the only ones crypto/tls negotiates in FIPS mode.
+var fipsCipherSuites = map[uint16]bool{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256: true,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384: true,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256:   true,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384:   true,
+}
+
+func parseTLSMinVersion(raw string) (uint16, error) {
+	v, ok := tlsVersions[raw]
+	if !ok {
+		return 0, fmt.Errorf("unsupported version %q (must be VersionTLS12 or VersionTLS13)", raw)
+	}
+	return v, nil
+}
+
+// parseTLSCipherSuites parses a comma-separated list of IANA cipher suite
+// names. Only suites Go considers secure are accepted, and in FIPS mode only
+// the approved ones. Go does not allow TLS 1.3 suites to be configured, so a
+// list is only valid when TLS 1.2 is allowed. An empty string keeps Go's
+// defaults.
+func parseTLSCipherSuites(raw string, minVersion uint16, fips bool) ([]uint16, error) {
+	secure := map[string]uint16{}
+	for _, cs := range tls.CipherSuites() {
+		secure[cs.Name] = cs.ID
+	}
+
+	var suites []uint16
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		id, ok := secure[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		if fips && !fipsCipherSuites[id] {
+			return nil, fmt.Errorf("cipher suite %q is not FIPS 140-3 approved", name)
+		}
+		suites = append(suites, id)
+	}
+	if len(suites) > 0 && minVersion == tls.VersionTLS13 {
+		return nil, errors.New("cipher suites only apply to TLS 1.2; set tls-min-version to VersionTLS12")
+	}
+	return suites, nil
+}
+
+func validateFlags(cfg *config) {
 	if cfg.envoyClusterName == "" {
 		setupLog.Error(errors.New("missing required flag"), "envoy-cluster-name is required")
 		os.Exit(1)
@@ -312,4 +775,172 @@ func validateFlags(cfg config) {
 		setupLog.Error(err, "invalid default-wasm-image")
 		os.Exit(1)
 	}
+	pprofAddr, err := resolvePprofBindAddress(cfg.pprofAddr)
+	if err != nil {
+		setupLog.Error(err, "invalid pprof-bind-address")
+		os.Exit(1)
+	}
+	if pprofAddr != "" {
+		setupLog.Info("pprof endpoint enabled", "address", pprofAddr)
+	}
+	cfg.pprofAddr = pprofAddr
+	watchNamespaces, err := parseWatchNamespaces(cfg.watchNamespacesRaw)
+	if err != nil {
+		setupLog.Error(err, "invalid watch-namespaces")
+		os.Exit(1)
+	}
+	if len(watchNamespaces) > 0 {
+		setupLog.Info("namespace-scoped mode enabled", "namespaces", watchNamespaces)
+	}
+	cfg.watchNamespaces = watchNamespaces
+	if cfg.ruleSourceDebounce < 0 {
+		setupLog.Error(errors.New("negative duration"), "rulesource-debounce-window must not be negative")
+		os.Exit(1)
+	}
+	if cfg.webhookCertPath != "" && cfg.webhookServiceName == "" {
+		setupLog.Error(errors.New("missing required flag"), "webhook-service-name is required with webhook-cert-path")
+		os.Exit(1)
+	}
+	if cfg.enableAdmissionWebhooks && cfg.webhookCertPath == "" {
+		setupLog.Error(errors.New("missing required flag"), "webhook-cert-path is required with enable-admission-webhooks")
+		os.Exit(1)
+	}
+	if cfg.cacheDrainPeriod < 0 {
+		setupLog.Error(errors.New("negative duration"), "cache-drain-period must not be negative")
+		os.Exit(1)
+	}
+	if cfg.cacheSPIFFEDomain != "" {
+		if err := cache.ValidateTrustDomain(cfg.cacheSPIFFEDomain); err != nil {
+			setupLog.Error(err, "invalid cache-spiffe-trust-domain")
+			os.Exit(1)
+		}
+	}
+	tlsMinVersion, err := parseTLSMinVersion(cfg.tlsMinVersionRaw)
+	if err != nil {
+		setupLog.Error(err, "invalid tls-min-version")
+		os.Exit(1)
+	}
+	cfg.tlsMinVersion = tlsMinVersion
+	tlsCipherSuites, err := parseTLSCipherSuites(cfg.tlsCipherSuitesRaw, tlsMinVersion, fips140.Enabled())
+	if err != nil {
+		setupLog.Error(err, "invalid tls-cipher-suites")
+		os.Exit(1)
+	}
+	cfg.tlsCipherSuites = tlsCipherSuites
+	if fips140.Enabled() {
+		setupLog.Info("FIPS 140-3 mode enabled")
+	}
+	imageMirrors, err := controller.ParseImageMirrors(cfg.imageMirrorsRaw)
+	if err != nil {
+		setupLog.Error(err, "invalid image-mirrors")
+		os.Exit(1)
+	}
+	cfg.imageMirrors = imageMirrors
+	controllers, err := parseEnableControllers(cfg.controllersRaw)
+	if err != nil {
+		setupLog.Error(err, "invalid enable-controllers")
+		os.Exit(1)
+	}
+	if len(controllers) < len(controller.Controllers) {
+		setupLog.Info("running a subset of controllers", "controllers", controllers)
+	}
+	cfg.controllers = controllers
+	if cfg.migrateStorage && len(cfg.watchNamespaces) > 0 {
+		setupLog.Error(errors.New("incompatible flags"), "migrate-storage-versions rewrites resources in all namespaces and cannot be combined with watch-namespaces")
+		os.Exit(1)
+	}
+	if err := validateFleetBackend(cfg.fleetBackend, cfg.watchNamespaces); err != nil {
+		setupLog.Error(err, "invalid fleet-backend")
+		os.Exit(1)
+	}
+	if err := validateDryRunReconcile(cfg); err != nil {
+		setupLog.Error(err, "invalid dry-run-reconcile")
+		os.Exit(1)
+	}
+	if err := validateRuleSetHook(cfg.ruleSetHookURL, cfg.ruleSetHookTimeout); err != nil {
+		setupLog.Error(err, "invalid ruleset-hook-url")
+		os.Exit(1)
+	}
+	if cfg.dryRunReconcile && cfg.enableLeaderElect {
+		// A dry-run replica must not take the lease from the operator
+		// running in write mode.
+		setupLog.Info("leader election disabled by dry-run-reconcile")
+		cfg.enableLeaderElect = false
+	}
+}
+
+// validateRuleSetHook checks the --ruleset-hook-url and
+// --ruleset-hook-timeout flags.
+func validateRuleSetHook(rawURL string, timeout time.Duration) error {
+	if rawURL == "" {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%q is not an absolute http or https URL", rawURL)
+	}
+	if timeout <= 0 {
+		return errors.New("--ruleset-hook-timeout must be positive")
+	}
+	return nil
+}
+
+// validateDryRunReconcile checks the --dry-run-reconcile flags. The fleet
+// controllers write to member clusters through their own clients, which are
+// not covered by the dry-run client.
+func validateDryRunReconcile(cfg *config) error {
+	if !cfg.dryRunReconcile {
+		if cfg.dryRunReport != "" {
+			return errors.New("--dry-run-report requires --dry-run-reconcile")
+		}
+		return nil
+	}
+	if cfg.enableMulticluster {
+		return errors.New("--dry-run-reconcile cannot be combined with --enable-multicluster")
+	}
+	return nil
+}
+
+// newClientFunc returns the function creating the manager client: the
+// default one, or one sending every write in dry-run mode and recording the
+// changes in report when it is set.
+func newClientFunc(report *controller.DryRunReport) client.NewClientFunc {
+	if report == nil {
+		return nil
+	}
+	return func(config *rest.Config, options client.Options) (client.Client, error) {
+		c, err := client.New(config, options)
+		if err != nil {
+			return nil, err
+		}
+		return controller.NewDryRunReconcileClient(c, report), nil
+	}
+}
+
+// validateFleetBackend checks the --fleet-backend value. The OCM backend
+// writes ManifestWorks to the namespace of each managed cluster, so it cannot
+// be restricted to the watched namespaces.
+func validateFleetBackend(backend string, watchNamespaces []string) error {
+	switch backend {
+	case controller.FleetBackendKubeconfig:
+		return nil
+	case controller.FleetBackendOCM:
+		if len(watchNamespaces) > 0 {
+			return fmt.Errorf("fleet backend %q cannot be combined with --watch-namespaces", backend)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown fleet backend %q: must be %q or %q", backend, controller.FleetBackendKubeconfig, controller.FleetBackendOCM)
+}
+
+// activeFleetBackend returns the fleet backend to set up, or "" when
+// multi-cluster propagation is disabled.
+func activeFleetBackend(cfg config) string {
+	if !cfg.enableMulticluster {
+		return ""
+	}
+	return cfg.fleetBackend
 }
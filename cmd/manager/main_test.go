@@ -18,13 +18,22 @@ package main
 
 import (
 	"crypto/tls"
+	"maps"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
 	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/internal/controller"
 	"github.com/networking-incubator/coraza-kubernetes-operator/internal/defaults"
 )
 
@@ -76,26 +85,163 @@ func TestResolveDefaultWasmImage(t *testing.T) {
 		assert.Equal(t, "oci://custom/img:v1", resolveDefaultWasmImage())
 	})
 
+	t.Run("related image env var gets the oci scheme", func(t *testing.T) {
+		t.Setenv(relatedImageWasmEnv, "mirror.example.com/coraza-proxy-wasm@sha256:abc")
+		assert.Equal(t, "oci://mirror.example.com/coraza-proxy-wasm@sha256:abc", resolveDefaultWasmImage())
+	})
+
+	t.Run("explicit env var wins over related image", func(t *testing.T) {
+		t.Setenv("CORAZA_DEFAULT_WASM_IMAGE", "oci://custom/img:v1")
+		t.Setenv(relatedImageWasmEnv, "mirror.example.com/coraza-proxy-wasm:v1")
+		assert.Equal(t, "oci://custom/img:v1", resolveDefaultWasmImage())
+	})
+
 	t.Run("falls back to hardcoded default when env var unset", func(t *testing.T) {
 		assert.Equal(t, defaults.DefaultCorazaWasmOCIReference, resolveDefaultWasmImage())
 	})
 }
 
+// -----------------------------------------------------------------------------
+// resolvePprofBindAddress Tests
+// -----------------------------------------------------------------------------
+
+func TestResolvePprofBindAddress(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		addr    string
+		want    string
+		wantErr bool
+	}{
+		{name: "empty disables", addr: "", want: ""},
+		{name: "zero disables", addr: "0", want: ""},
+		{name: "missing host binds loopback", addr: ":6060", want: "127.0.0.1:6060"},
+		{name: "explicit ipv4 loopback", addr: "127.0.0.1:6060", want: "127.0.0.1:6060"},
+		{name: "explicit ipv6 loopback", addr: "[::1]:6060", want: "[::1]:6060"},
+		{name: "localhost", addr: "localhost:6060", want: "localhost:6060"},
+		{name: "wildcard rejected", addr: "0.0.0.0:6060", wantErr: true},
+		{name: "pod ip rejected", addr: "10.0.0.5:6060", wantErr: true},
+		{name: "hostname rejected", addr: "example.com:6060", wantErr: true},
+		{name: "missing port", addr: "127.0.0.1", wantErr: true},
+		{name: "empty port", addr: "127.0.0.1:", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			got, err := resolvePprofBindAddress(tt.addr)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
 // -----------------------------------------------------------------------------
 // buildTLSOpts Tests
 // -----------------------------------------------------------------------------
 
 func TestBuildTLSOpts_EnforcesTLS13AndDisablesHTTP2(t *testing.T) {
-	opts := buildTLSOpts()
+	opts := buildTLSOpts(tls.VersionTLS13, nil)
 	require.Len(t, opts, 1)
 
 	tlsCfg := &tls.Config{}
 	opts[0](tlsCfg)
 	assert.Equal(t, uint16(tls.VersionTLS13), tlsCfg.MinVersion)
+	assert.Nil(t, tlsCfg.CipherSuites)
 	assert.Equal(t, []string{"http/1.1"}, tlsCfg.NextProtos,
 		"HTTP/2 should be disabled to mitigate Rapid Reset (CVE-2023-44487)")
 }
 
+func TestBuildTLSOpts_AppliesPolicy(t *testing.T) {
+	suites := []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}
+	tlsCfg := &tls.Config{}
+	buildTLSOpts(tls.VersionTLS12, suites)[0](tlsCfg)
+	assert.Equal(t, uint16(tls.VersionTLS12), tlsCfg.MinVersion)
+	assert.Equal(t, suites, tlsCfg.CipherSuites)
+}
+
+// -----------------------------------------------------------------------------
+// TLS Policy Tests
+// -----------------------------------------------------------------------------
+
+func TestParseTLSMinVersion(t *testing.T) {
+	v, err := parseTLSMinVersion("VersionTLS12")
+	require.NoError(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), v)
+
+	_, err = parseTLSMinVersion("VersionTLS11")
+	assert.Error(t, err)
+}
+
+func TestValidateFleetBackend(t *testing.T) {
+	assert.NoError(t, validateFleetBackend(controller.FleetBackendKubeconfig, []string{"team-a"}))
+	assert.NoError(t, validateFleetBackend(controller.FleetBackendOCM, nil))
+	assert.Error(t, validateFleetBackend(controller.FleetBackendOCM, []string{"team-a"}), "ManifestWorks live outside the watched namespaces")
+	assert.Error(t, validateFleetBackend("argocd", nil))
+}
+
+func TestValidateDryRunReconcile(t *testing.T) {
+	assert.NoError(t, validateDryRunReconcile(&config{}))
+	assert.NoError(t, validateDryRunReconcile(&config{dryRunReconcile: true, dryRunReport: "/tmp/report.json"}))
+	assert.Error(t, validateDryRunReconcile(&config{dryRunReport: "/tmp/report.json"}))
+	assert.Error(t, validateDryRunReconcile(&config{dryRunReconcile: true, enableMulticluster: true}), "member clusters are written through their own clients")
+}
+
+func TestValidateRuleSetHook(t *testing.T) {
+	assert.NoError(t, validateRuleSetHook("", 0))
+	assert.NoError(t, validateRuleSetHook("https://opa.policy.svc:8181/v1/data/coraza/review", time.Second))
+	assert.Error(t, validateRuleSetHook("opa.policy.svc:8181", time.Second), "the URL must be absolute")
+	assert.Error(t, validateRuleSetHook("grpc://opa.policy.svc:8181", time.Second))
+	assert.Error(t, validateRuleSetHook("http://opa.policy.svc:8181", 0))
+}
+
+func TestParseTLSCipherSuites(t *testing.T) {
+	tests := []struct {
+		name       string
+		raw        string
+		minVersion uint16
+		fips       bool
+		want       []uint16
+		wantErr    bool
+	}{
+		{name: "empty keeps defaults", raw: "", minVersion: tls.VersionTLS13},
+		{
+			name:       "trims names",
+			raw:        " TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256 ,",
+			minVersion: tls.VersionTLS12,
+			want:       []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256},
+		},
+		{name: "rejects unknown suite", raw: "TLS_FOO", minVersion: tls.VersionTLS12, wantErr: true},
+		{name: "rejects insecure suite", raw: "TLS_RSA_WITH_RC4_128_SHA", minVersion: tls.VersionTLS12, wantErr: true},
+		{name: "rejects suites with TLS 1.3 minimum", raw: "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", minVersion: tls.VersionTLS13, wantErr: true},
+		{
+			name:       "FIPS accepts approved suite",
+			raw:        "TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384",
+			minVersion: tls.VersionTLS12,
+			fips:       true,
+			want:       []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384},
+		},
+		{name: "FIPS rejects ChaCha20", raw: "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256", minVersion: tls.VersionTLS12, fips: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTLSCipherSuites(tt.raw, tt.minVersion, tt.fips)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
 // -----------------------------------------------------------------------------
 // buildMetricsServerOptions Tests
 // -----------------------------------------------------------------------------
@@ -142,3 +288,146 @@ func TestBuildMetricsServerOptions_SelfSignedWhenNoCert(t *testing.T) {
 	assert.Empty(t, opts.CertName)
 	assert.Empty(t, opts.KeyName)
 }
+
+// -----------------------------------------------------------------------------
+// buildWebhookServer Tests
+// -----------------------------------------------------------------------------
+
+func TestBuildWebhookServer_DisabledWithoutCertPath(t *testing.T) {
+	assert.Nil(t, buildWebhookServer(config{}, nil))
+}
+
+func TestBuildWebhookServer_WithCertPath(t *testing.T) {
+	cfg := config{
+		webhookCertPath: "/certs",
+		webhookCertName: "server.crt",
+		webhookCertKey:  "server.key",
+	}
+
+	server, ok := buildWebhookServer(cfg, nil).(*webhook.DefaultServer)
+	require.True(t, ok)
+	assert.Equal(t, "/certs", server.Options.CertDir)
+	assert.Equal(t, "server.crt", server.Options.CertName)
+	assert.Equal(t, "server.key", server.Options.KeyName)
+}
+
+// -----------------------------------------------------------------------------
+// buildCacheOptions Tests
+// -----------------------------------------------------------------------------
+
+func TestBuildCacheOptions_WasmPluginsScopedToManagedLabel(t *testing.T) {
+	opts := buildCacheOptions("operator-ns", nil, controller.Capabilities{controller.CapabilityWasmPlugin: true})
+
+	var selector labels.Selector
+	for obj, byObject := range opts.ByObject {
+		u, ok := obj.(*unstructured.Unstructured)
+		if ok && u.GroupVersionKind() == controller.WasmPluginGVK {
+			selector = byObject.Label
+		}
+	}
+	require.NotNil(t, selector, "WasmPlugin cache must be restricted by a label selector")
+
+	assert.True(t, selector.Matches(labels.Set{controller.ManagedByLabel: controller.ManagedByValue}))
+	assert.False(t, selector.Matches(labels.Set{}), "unlabelled WasmPlugins must not be cached")
+	assert.False(t, selector.Matches(labels.Set{controller.ManagedByLabel: "someone-else"}))
+}
+
+func TestBuildCacheOptions_MissingKindsLeftOut(t *testing.T) {
+	opts := buildCacheOptions("operator-ns", nil, controller.Capabilities{controller.CapabilityWasmPlugin: false})
+
+	for obj := range opts.ByObject {
+		_, ok := obj.(*unstructured.Unstructured)
+		assert.False(t, ok, "kinds that are not installed must not be cached")
+	}
+}
+
+func TestBuildCacheOptions_MemberClusterSecretsScoped(t *testing.T) {
+	opts := buildCacheOptions("operator-ns", []string{"team-a"}, nil)
+
+	var found bool
+	for obj, byObject := range opts.ByObject {
+		if _, ok := obj.(*corev1.Secret); !ok {
+			continue
+		}
+		found = true
+		assert.Equal(t, []string{"operator-ns"}, slices.Collect(maps.Keys(byObject.Namespaces)))
+		require.NotNil(t, byObject.Label)
+		assert.True(t, byObject.Label.Matches(labels.Set{wafv1alpha1.LabelMemberCluster: "true"}))
+		assert.False(t, byObject.Label.Matches(labels.Set{}), "unrelated Secrets must not be cached")
+	}
+	assert.True(t, found, "Secret cache must be scoped")
+}
+
+func TestBuildCacheOptions_WatchNamespaces(t *testing.T) {
+	opts := buildCacheOptions("operator-ns", nil, nil)
+	assert.Empty(t, opts.DefaultNamespaces, "cluster-wide mode must not restrict namespaces")
+
+	opts = buildCacheOptions("operator-ns", []string{"team-a", "team-b"}, nil)
+	assert.Len(t, opts.DefaultNamespaces, 2)
+	assert.Contains(t, opts.DefaultNamespaces, "team-a")
+	assert.Contains(t, opts.DefaultNamespaces, "team-b")
+
+	for obj, byObject := range opts.ByObject {
+		if _, ok := obj.(*networkingv1.NetworkPolicy); ok {
+			assert.Equal(t, []string{"operator-ns"}, slices.Collect(maps.Keys(byObject.Namespaces)),
+				"NetworkPolicies always live in the operator namespace")
+		}
+	}
+}
+
+// -----------------------------------------------------------------------------
+// parseWatchNamespaces Tests
+// -----------------------------------------------------------------------------
+
+func TestParseWatchNamespaces(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    []string
+		wantErr bool
+	}{
+		{name: "empty means all namespaces", raw: "", want: nil},
+		{name: "single namespace", raw: "team-a", want: []string{"team-a"}},
+		{name: "trims and deduplicates", raw: " team-a, team-b ,team-a,,", want: []string{"team-a", "team-b"}},
+		{name: "rejects invalid name", raw: "team-a,Team_B", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseWatchNamespaces(tt.raw)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseEnableControllers(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    []string
+		wantErr bool
+	}{
+		{name: "default enables all", raw: strings.Join(controller.Controllers, ","), want: controller.Controllers},
+		{name: "subset", raw: "ruleset", want: []string{controller.ControllerRuleSet}},
+		{name: "trims, lowercases and deduplicates", raw: " RuleSet, engine ,ruleset,,", want: []string{controller.ControllerRuleSet, controller.ControllerEngine}},
+		{name: "rejects unknown controller", raw: "ruleset,wafpolicy", wantErr: true},
+		{name: "rejects empty selection", raw: " , ", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseEnableControllers(tt.raw)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
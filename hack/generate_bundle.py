@@ -6,12 +6,16 @@ Runs helm template, extracts the Deployment spec, ClusterRole rules,
 and ServiceAccount name, then injects them into the CSV template.
 Populates metadata.annotations.alm-examples from owned CRs in config/samples.
 Additional manifests (Service, CRDs) are copied into bundle/manifests/.
+The operator and Coraza WASM plugin images are listed in spec.relatedImages
+and passed to the operator through RELATED_IMAGE_* environment variables, so
+disconnected installs can mirror and rewrite them.
 """
 
 import argparse
 import copy
 import json
 import os
+import re
 import shutil
 import sys
 from pathlib import Path
@@ -48,6 +52,13 @@ DEFAULT_MIN_KUBE_VERSION = "1.32.0"
 # CRD apiVersion prefix for OLM alm-examples (owned APIs only).
 OWNED_API_PREFIX = "waf.k8s.coraza.io/"
 
+# Go source holding the built-in default WASM plugin OCI reference.
+DEFAULTS_GO = Path(__file__).resolve().parent.parent / "internal/defaults/defaults.go"
+
+# Environment variable the operator reads the WASM plugin image from (see
+# resolveDefaultWasmImage in cmd/manager/main.go).
+WASM_RELATED_IMAGE_ENV = "RELATED_IMAGE_CORAZA_WASM"
+
 # Display order for alm-examples (RuleSet before Engine: engine references the ruleset).
 _ALM_EXAMPLE_KIND_ORDER = {"RuleSet": 0, "Engine": 1}
 
@@ -139,9 +150,26 @@ def override_container_image(deployment: dict, image: str):
         container["image"] = image
 
 
+def default_wasm_image() -> str:
+    """Return the built-in default WASM plugin image without the oci:// scheme."""
+    source = DEFAULTS_GO.read_text()
+    match = re.search(r'DefaultCorazaWasmOCIReference\s*=\s*"oci://([^"]+)"', source)
+    if not match:
+        die(f"DefaultCorazaWasmOCIReference not found in {DEFAULTS_GO}")
+    return match.group(1)
+
+
+def set_related_image_env(deployment: dict, wasm_image: str):
+    """Pass the WASM plugin image to the operator via its RELATED_IMAGE_* variable."""
+    for container in deployment["spec"]["template"]["spec"]["containers"]:
+        env = [e for e in container.get("env", []) if e.get("name") != WASM_RELATED_IMAGE_ENV]
+        env.append({"name": WASM_RELATED_IMAGE_ENV, "value": wasm_image})
+        container["env"] = env
+
+
 def build_csv(template_path: str, deployment: dict, cluster_role: dict,
-              sa_name: str, version: str, image: str,
-              replaces: str, channels: str, default_channel: str,
+              sa_name: str, version: str, image: str, wasm_image: str,
+              replaces: str, skip_range: str, channels: str, default_channel: str,
               package_name: str, min_kube_version: str,
               alm_examples: list) -> dict:
     """Build the CSV by injecting Helm-rendered resources into the template."""
@@ -158,6 +186,13 @@ def build_csv(template_path: str, deployment: dict, cluster_role: dict,
 
     if replaces:
         csv["spec"]["replaces"] = replaces
+    if skip_range:
+        csv["metadata"]["annotations"]["olm.skipRange"] = skip_range
+
+    csv["spec"]["relatedImages"] = [
+        {"name": "manager", "image": image},
+        {"name": "coraza-wasm", "image": wasm_image},
+    ]
 
     deploy_spec = copy.deepcopy(deployment["spec"])
     csv["spec"]["install"]["spec"]["deployments"] = [
@@ -294,7 +329,11 @@ def main():
     parser.add_argument("--image", required=True, help="Operator container image ref (repo:tag or repo@sha256:digest)")
     parser.add_argument("--channels", default="alpha", help="Comma-separated OLM channel list")
     parser.add_argument("--default-channel", default="alpha", help="Default OLM channel")
+    parser.add_argument("--wasm-image", default="",
+                        help="Coraza WASM plugin image ref without oci:// (default: internal/defaults)")
     parser.add_argument("--replaces", default="", help="CSV version this replaces (e.g. coraza-kubernetes-operator.v0.2.0)")
+    parser.add_argument("--skip-range", default="",
+                        help="olm.skipRange annotation, e.g. '>=0.1.0 <0.3.0', so upgrades can skip intermediate versions")
     parser.add_argument("--package-name", default="coraza-kubernetes-operator", help="OLM package name")
     parser.add_argument("--release-name", default="coraza-kubernetes-operator", help="Helm release name for rendering")
     parser.add_argument("--namespace", default="coraza-system", help="Namespace for Helm rendering")
@@ -341,6 +380,8 @@ def main():
         die("No ServiceAccount found in Helm output")
 
     override_container_image(deployment, args.image)
+    wasm_image = args.wasm_image.removeprefix("oci://") or default_wasm_image()
+    set_related_image_env(deployment, wasm_image)
     sa_name = service_account["metadata"]["name"]
 
     extra_manifests = [strip_helm_labels(d) for d in docs if d.get("kind") in BUNDLE_RESOURCE_KINDS]
@@ -357,7 +398,9 @@ def main():
         sa_name=sa_name,
         version=version,
         image=args.image,
+        wasm_image=wasm_image,
         replaces=args.replaces,
+        skip_range=args.skip_range,
         channels=args.channels,
         default_channel=args.default_channel,
         package_name=args.package_name,
@@ -2,7 +2,9 @@
 """
 Add a new version to the OLM file-based catalog channel entries.
 
-Sets `replaces` to the previous latest entry for OLM upgrade path.
+Sets `replaces` to the previous latest entry for OLM upgrade path, and a
+`skipRange` so that clusters on any earlier version upgrade directly to the
+new one instead of walking every intermediate release.
 Idempotent: skips if the version already exists.
 
 Usage: update_catalog.py <catalog-file> <version> [package-name] [channel]
@@ -56,7 +58,7 @@ def main():
 
     # Set replaces to the current latest entry for upgrade path
     previous = entries[-1]["name"] if entries else None
-    new_entry = {"name": entry_name}
+    new_entry = {"name": entry_name, "skipRange": f"<{version}"}
     if previous:
         new_entry["replaces"] = previous
     entries.append(new_entry)
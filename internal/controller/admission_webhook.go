@@ -0,0 +1,411 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// Admission Webhooks - RBAC
+// -----------------------------------------------------------------------------
+
+// +kubebuilder:rbac:groups=admissionregistration.k8s.io,resources=mutatingwebhookconfigurations;validatingwebhookconfigurations,verbs=create
+// +kubebuilder:rbac:groups=admissionregistration.k8s.io,resources=mutatingwebhookconfigurations;validatingwebhookconfigurations,verbs=patch,resourceNames=waf.k8s.coraza.io
+
+// -----------------------------------------------------------------------------
+// Admission Webhooks - Vars
+// -----------------------------------------------------------------------------
+
+const (
+	// AdmissionWebhookConfigurationName is the name of the
+	// MutatingWebhookConfiguration and of the ValidatingWebhookConfiguration
+	// of the admission webhooks.
+	AdmissionWebhookConfigurationName = "waf.k8s.coraza.io"
+
+	// ModifiedByWebhookPath is the path the webhook recording the authors of
+	// RuleSets, RuleSources and RuleData is served at.
+	ModifiedByWebhookPath = "/mutate-modified-by"
+
+	// ApprovedByWebhookPath is the path the webhook recording the approvers
+	// of RuleSetApprovals is served at.
+	ApprovedByWebhookPath = "/mutate-approved-by"
+
+	// ApprovalWebhookPath is the path the webhook validating RuleSetApprovals
+	// is served at.
+	ApprovalWebhookPath = "/validate-rulesetapproval"
+
+	// EngineWebhookPath is the path the webhook validating Engines is served
+	// at.
+	EngineWebhookPath = "/validate-engine"
+)
+
+// -----------------------------------------------------------------------------
+// Admission Webhooks - Authorship
+// -----------------------------------------------------------------------------
+
+// NewModifiedByWebhook returns the handler recording, in the
+// AnnotationModifiedBy of RuleSets, RuleSources and RuleData, the user who
+// last changed their spec. Requests that leave the spec unchanged keep the
+// previous value, so that the annotation cannot be forged.
+func NewModifiedByWebhook() admission.Handler {
+	return admission.HandlerFunc(func(_ context.Context, req admission.Request) admission.Response {
+		return recordUser(req, wafv1alpha1.AnnotationModifiedBy, func(obj, old *unstructured.Unstructured) bool {
+			return !equality.Semantic.DeepEqual(obj.Object["spec"], old.Object["spec"])
+		})
+	})
+}
+
+// NewApprovedByWebhook returns the handler recording, in the
+// AnnotationApprovedBy of RuleSetApprovals, the user who created them. The
+// annotation is kept on updates, so that it cannot be forged.
+func NewApprovedByWebhook() admission.Handler {
+	return admission.HandlerFunc(func(_ context.Context, req admission.Request) admission.Response {
+		return recordUser(req, wafv1alpha1.AnnotationApprovedBy, func(_, _ *unstructured.Unstructured) bool { return false })
+	})
+}
+
+// recordUser sets the annotation key of the object of req to the user of
+// req on creation, and on the updates for which changed reports true. Other
+// updates keep the value of the previous object.
+func recordUser(req admission.Request, key string, changed func(obj, old *unstructured.Unstructured) bool) admission.Response {
+	obj := &unstructured.Unstructured{}
+	if err := json.Unmarshal(req.Object.Raw, &obj.Object); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	user := req.UserInfo.Username
+	if req.Operation == admissionv1.Update {
+		old := &unstructured.Unstructured{}
+		if err := json.Unmarshal(req.OldObject.Raw, &old.Object); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		if !changed(obj, old) {
+			user = old.GetAnnotations()[key]
+		}
+	}
+
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	if user == "" {
+		delete(annotations, key)
+	} else {
+		annotations[key] = user
+	}
+	obj.SetAnnotations(annotations)
+
+	current, err := json.Marshal(obj.Object)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, current)
+}
+
+// -----------------------------------------------------------------------------
+// Admission Webhooks - RuleSetApprovals
+// -----------------------------------------------------------------------------
+
+// NewApprovalWebhook returns the handler rejecting the RuleSetApprovals
+// created by one of the authors of the pending revision they approve, as
+// recorded in the status of its RuleSet read from reader. The RuleSet
+// controller ignores such approvals too; the webhook reports the mistake to
+// the approver.
+func NewApprovalWebhook(reader client.Reader) admission.Handler {
+	return admission.HandlerFunc(func(ctx context.Context, req admission.Request) admission.Response {
+		var approval wafv1alpha1.RuleSetApproval
+		if err := json.Unmarshal(req.Object.Raw, &approval); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+
+		var ruleset wafv1alpha1.RuleSet
+		if err := reader.Get(ctx, client.ObjectKey{Namespace: req.Namespace, Name: approval.Spec.RuleSet.Name}, &ruleset); err != nil {
+			if apierrors.IsNotFound(err) {
+				return admission.Allowed("")
+			}
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+		if ruleset.Status.PendingRevision == approval.Spec.Revision && slices.Contains(ruleset.Status.PendingRevisionAuthors, req.UserInfo.Username) {
+			return admission.Denied(fmt.Sprintf("%s changed the rules of revision %s of RuleSet %s: it must be approved by another user",
+				req.UserInfo.Username, approval.Spec.Revision, ruleset.Name))
+		}
+		return admission.Allowed("")
+	})
+}
+
+// -----------------------------------------------------------------------------
+// Admission Webhooks - Engines
+// -----------------------------------------------------------------------------
+
+// NewEngineWebhook returns the handler rejecting the Engines whose spec the
+// CRD schema accepts but the operator would not: Engines loading a RuleSet
+// that does not exist, targeting a Gateway or ingress gateway that another
+// Engine already targets, or targeting a Gateway that their provider does
+// not program. On updates, only the RuleSets and the target that change are
+// checked, so that Engines whose RuleSet was deleted can still be fixed or
+// deleted. The Engines created for a target selector are not checked for
+// their target: the Engine that selects the Gateways reports the conflicts
+// in its status.
+func NewEngineWebhook(reader client.Reader) admission.Handler {
+	return admission.HandlerFunc(func(ctx context.Context, req admission.Request) admission.Response {
+		var engine wafv1alpha1.Engine
+		if err := json.Unmarshal(req.Object.Raw, &engine); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		engine.Namespace = req.Namespace
+		var old *wafv1alpha1.Engine
+		if req.Operation == admissionv1.Update {
+			old = &wafv1alpha1.Engine{}
+			if err := json.Unmarshal(req.OldObject.Raw, old); err != nil {
+				return admission.Errored(http.StatusBadRequest, err)
+			}
+			if !engine.DeletionTimestamp.IsZero() || equality.Semantic.DeepEqual(engine.Spec, old.Spec) {
+				return admission.Allowed("")
+			}
+		}
+
+		problems, err := engineProblems(ctx, reader, &engine, old)
+		if err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+		if len(problems) > 0 {
+			return admission.Denied(strings.Join(problems, "; "))
+		}
+		return admission.Allowed("")
+	})
+}
+
+// engineProblems returns why engine, replacing old when not nil, must be
+// rejected, read from reader.
+func engineProblems(ctx context.Context, reader client.Reader, engine, old *wafv1alpha1.Engine) ([]string, error) {
+	var problems []string
+
+	for _, name := range engineRuleSets(engine) {
+		if old != nil && slices.Contains(engineRuleSets(old), name) {
+			continue
+		}
+		var ruleset wafv1alpha1.RuleSet
+		if err := reader.Get(ctx, client.ObjectKey{Namespace: engine.Namespace, Name: name}, &ruleset); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return nil, fmt.Errorf("failed to get RuleSet %s/%s: %w", engine.Namespace, name, err)
+			}
+			problems = append(problems, fmt.Sprintf("RuleSet %q not found in namespace %q", name, engine.Namespace))
+		}
+	}
+
+	if !hasTarget(engine) || metav1.GetControllerOf(engine) != nil {
+		return problems, nil
+	}
+	if old != nil && old.Spec.Target.Type == engine.Spec.Target.Type && old.Spec.Target.Name == engine.Spec.Target.Name &&
+		old.Spec.Target.Provider == engine.Spec.Target.Provider {
+		return problems, nil
+	}
+
+	var engines wafv1alpha1.EngineList
+	if err := reader.List(ctx, &engines, client.InNamespace(engine.Namespace)); err != nil {
+		return nil, fmt.Errorf("failed to list Engines in namespace %s: %w", engine.Namespace, err)
+	}
+	for _, other := range engines.Items {
+		if other.Name != engine.Name && other.DeletionTimestamp.IsZero() && hasTarget(&other) &&
+			other.Spec.Target.Type == engine.Spec.Target.Type && other.Spec.Target.Name == engine.Spec.Target.Name {
+			problems = append(problems, fmt.Sprintf("Target %s %q is already claimed by Engine %q", engine.Spec.Target.Type, engine.Spec.Target.Name, other.Name))
+			break
+		}
+	}
+
+	_, msg, err := checkTargetGatewayClass(ctx, reader, engine)
+	if err != nil {
+		return nil, err
+	}
+	if msg != "" {
+		problems = append(problems, msg)
+	}
+	return problems, nil
+}
+
+// -----------------------------------------------------------------------------
+// Admission Webhooks - Configuration
+// -----------------------------------------------------------------------------
+
+// AdmissionWebhookConfigurer applies the MutatingWebhookConfiguration and
+// the ValidatingWebhookConfiguration of the admission webhooks of the
+// operator, behind a Service in the operator namespace, trusting caBundle
+// to verify its serving certificate. The webhooks fail closed: the WAF
+// resources they cover cannot be changed while the operator is down. When
+// the operator only watches some namespaces, the webhooks only cover those,
+// whose resources the operator can read. It runs once, on the leader, at
+// startup.
+type AdmissionWebhookConfigurer struct {
+	client          client.Client
+	namespace       string
+	service         string
+	caBundle        []byte
+	watchNamespaces []string
+}
+
+// NewAdmissionWebhookConfigurer returns a new AdmissionWebhookConfigurer
+// runnable for the admission webhooks behind Service service in namespace,
+// covering watchNamespaces, or every namespace when empty.
+func NewAdmissionWebhookConfigurer(c client.Client, namespace, service string, caBundle []byte, watchNamespaces []string) *AdmissionWebhookConfigurer {
+	return &AdmissionWebhookConfigurer{client: c, namespace: namespace, service: service, caBundle: caBundle, watchNamespaces: watchNamespaces}
+}
+
+// NeedLeaderElection makes only the leader configure the webhooks.
+func (c *AdmissionWebhookConfigurer) NeedLeaderElection() bool {
+	return true
+}
+
+// Start applies the webhook configurations. It satisfies the
+// manager.Runnable interface.
+//
+// Failures are logged and do not stop the manager: without the webhooks,
+// the authors and approvers of rule changes are not recorded, and
+// RuleSetApprovals approve nothing. The configurations are applied again on
+// the next start.
+func (c *AdmissionWebhookConfigurer) Start(ctx context.Context) error {
+	log := ctrl.Log.WithName("admission-webhooks")
+
+	if len(c.caBundle) == 0 {
+		log.Error(fmt.Errorf("no CA bundle to verify the admission webhooks of Service %s/%s", c.namespace, c.service),
+			"Failed to configure the admission webhooks")
+		return nil
+	}
+	mutating, validating := c.configurations()
+	for _, obj := range []client.Object{mutating, validating} {
+		if err := c.client.Patch(ctx, obj, client.Apply, client.FieldOwner(fieldManager), client.ForceOwnership); err != nil {
+			log.Error(err, "Failed to configure the admission webhooks", "kind", obj.GetObjectKind().GroupVersionKind().Kind)
+			continue
+		}
+		log.Info("Configured the admission webhooks", "kind", obj.GetObjectKind().GroupVersionKind().Kind, "service", c.namespace+"/"+c.service)
+	}
+	return nil
+}
+
+// configurations returns the MutatingWebhookConfiguration and the
+// ValidatingWebhookConfiguration of the admission webhooks.
+func (c *AdmissionWebhookConfigurer) configurations() (*admissionregistrationv1.MutatingWebhookConfiguration, *admissionregistrationv1.ValidatingWebhookConfiguration) {
+	mutating := &admissionregistrationv1.MutatingWebhookConfiguration{
+		TypeMeta:   metav1.TypeMeta{APIVersion: admissionregistrationv1.SchemeGroupVersion.String(), Kind: "MutatingWebhookConfiguration"},
+		ObjectMeta: metav1.ObjectMeta{Name: AdmissionWebhookConfigurationName},
+		Webhooks: []admissionregistrationv1.MutatingWebhook{
+			{
+				Name:                    "modified-by." + wafv1alpha1.Group,
+				ClientConfig:            c.clientConfig(ModifiedByWebhookPath),
+				Rules:                   admissionRules([]admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update}, "rulesets", "rulesources", "ruledata"),
+				NamespaceSelector:       c.namespaceSelector(),
+				FailurePolicy:           ptr.To(admissionregistrationv1.Fail),
+				SideEffects:             ptr.To(admissionregistrationv1.SideEffectClassNone),
+				AdmissionReviewVersions: []string{"v1"},
+			},
+			{
+				Name:                    "approved-by." + wafv1alpha1.Group,
+				ClientConfig:            c.clientConfig(ApprovedByWebhookPath),
+				Rules:                   admissionRules([]admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update}, "rulesetapprovals"),
+				NamespaceSelector:       c.namespaceSelector(),
+				FailurePolicy:           ptr.To(admissionregistrationv1.Fail),
+				SideEffects:             ptr.To(admissionregistrationv1.SideEffectClassNone),
+				AdmissionReviewVersions: []string{"v1"},
+			},
+		},
+	}
+	validating := &admissionregistrationv1.ValidatingWebhookConfiguration{
+		TypeMeta:   metav1.TypeMeta{APIVersion: admissionregistrationv1.SchemeGroupVersion.String(), Kind: "ValidatingWebhookConfiguration"},
+		ObjectMeta: metav1.ObjectMeta{Name: AdmissionWebhookConfigurationName},
+		Webhooks: []admissionregistrationv1.ValidatingWebhook{
+			{
+				Name:                    "rulesetapprovals." + wafv1alpha1.Group,
+				ClientConfig:            c.clientConfig(ApprovalWebhookPath),
+				Rules:                   admissionRules([]admissionregistrationv1.OperationType{admissionregistrationv1.Create}, "rulesetapprovals"),
+				NamespaceSelector:       c.namespaceSelector(),
+				FailurePolicy:           ptr.To(admissionregistrationv1.Fail),
+				SideEffects:             ptr.To(admissionregistrationv1.SideEffectClassNone),
+				AdmissionReviewVersions: []string{"v1"},
+			},
+			{
+				Name:                    "engines." + wafv1alpha1.Group,
+				ClientConfig:            c.clientConfig(EngineWebhookPath),
+				Rules:                   admissionRules([]admissionregistrationv1.OperationType{admissionregistrationv1.Create, admissionregistrationv1.Update}, "engines"),
+				NamespaceSelector:       c.namespaceSelector(),
+				FailurePolicy:           ptr.To(admissionregistrationv1.Fail),
+				SideEffects:             ptr.To(admissionregistrationv1.SideEffectClassNone),
+				AdmissionReviewVersions: []string{"v1"},
+			},
+		},
+	}
+	return mutating, validating
+}
+
+// clientConfig returns the client configuration of the webhook served at
+// path.
+func (c *AdmissionWebhookConfigurer) clientConfig(path string) admissionregistrationv1.WebhookClientConfig {
+	return admissionregistrationv1.WebhookClientConfig{
+		Service: &admissionregistrationv1.ServiceReference{
+			Namespace: c.namespace,
+			Name:      c.service,
+			Path:      ptr.To(path),
+			Port:      ptr.To[int32](443),
+		},
+		CABundle: c.caBundle,
+	}
+}
+
+// namespaceSelector returns the selector of the namespaces the webhooks
+// cover: the watched namespaces, or nil for every namespace.
+func (c *AdmissionWebhookConfigurer) namespaceSelector() *metav1.LabelSelector {
+	if len(c.watchNamespaces) == 0 {
+		return nil
+	}
+	return &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{
+		Key:      corev1.LabelMetadataName,
+		Operator: metav1.LabelSelectorOpIn,
+		Values:   c.watchNamespaces,
+	}}}
+}
+
+// admissionRules returns the rules matching operations on resources of the
+// API group of the operator, in every version.
+func admissionRules(operations []admissionregistrationv1.OperationType, resources ...string) []admissionregistrationv1.RuleWithOperations {
+	return []admissionregistrationv1.RuleWithOperations{{
+		Operations: operations,
+		Rule: admissionregistrationv1.Rule{
+			APIGroups:   []string{wafv1alpha1.Group},
+			APIVersions: []string{"*"},
+			Resources:   resources,
+			Scope:       ptr.To(admissionregistrationv1.NamespacedScope),
+		},
+	}}
+}
@@ -0,0 +1,416 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gomodules.xyz/jsonpatch/v2"
+	admissionv1 "k8s.io/api/admission/v1"
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/test/utils"
+)
+
+// admissionRequest returns the admission request of user for operation on
+// obj, replacing old.
+func admissionRequest(t *testing.T, operation admissionv1.Operation, user string, obj, old client.Object) admission.Request {
+	t.Helper()
+	req := admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+		Operation: operation,
+		Namespace: obj.GetNamespace(),
+		UserInfo:  authenticationv1.UserInfo{Username: user},
+	}}
+	raw, err := json.Marshal(obj)
+	require.NoError(t, err)
+	req.Object.Raw = raw
+	if old != nil {
+		raw, err := json.Marshal(old)
+		require.NoError(t, err)
+		req.OldObject.Raw = raw
+	}
+	return req
+}
+
+func TestModifiedByWebhook(t *testing.T) {
+	ruleset := func(rules, modifiedBy string) *wafv1alpha1.RuleSet {
+		ruleset := &wafv1alpha1.RuleSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "ruleset", Namespace: "prod"},
+			Spec:       wafv1alpha1.RuleSetSpec{Sources: []wafv1alpha1.SourceReference{{Name: rules}}},
+		}
+		if modifiedBy != "" {
+			ruleset.Annotations = map[string]string{wafv1alpha1.AnnotationModifiedBy: modifiedBy}
+		}
+		return ruleset
+	}
+
+	tests := []struct {
+		name        string
+		operation   admissionv1.Operation
+		obj, old    client.Object
+		wantPatches []jsonpatch.JsonPatchOperation
+	}{
+		{
+			name:      "creation records the user",
+			operation: admissionv1.Create,
+			obj:       ruleset("rules", ""),
+			wantPatches: []jsonpatch.JsonPatchOperation{
+				{Operation: "add", Path: "/metadata/annotations", Value: map[string]any{wafv1alpha1.AnnotationModifiedBy: "alice"}},
+			},
+		},
+		{
+			name:      "creation overwrites a forged user",
+			operation: admissionv1.Create,
+			obj:       ruleset("rules", "carol"),
+			wantPatches: []jsonpatch.JsonPatchOperation{
+				{Operation: "replace", Path: "/metadata/annotations/waf.k8s.coraza.io~1modified-by", Value: "alice"},
+			},
+		},
+		{
+			name:      "spec change records the user",
+			operation: admissionv1.Update,
+			obj:       ruleset("other", "bob"),
+			old:       ruleset("rules", "bob"),
+			wantPatches: []jsonpatch.JsonPatchOperation{
+				{Operation: "replace", Path: "/metadata/annotations/waf.k8s.coraza.io~1modified-by", Value: "alice"},
+			},
+		},
+		{
+			name:      "metadata change keeps the previous user",
+			operation: admissionv1.Update,
+			obj:       ruleset("rules", "bob"),
+			old:       ruleset("rules", "bob"),
+		},
+		{
+			name:      "forging the annotation restores it",
+			operation: admissionv1.Update,
+			obj:       ruleset("rules", "carol"),
+			old:       ruleset("rules", "bob"),
+			wantPatches: []jsonpatch.JsonPatchOperation{
+				{Operation: "replace", Path: "/metadata/annotations/waf.k8s.coraza.io~1modified-by", Value: "bob"},
+			},
+		},
+		{
+			name:      "adding the annotation without a previous user removes it",
+			operation: admissionv1.Update,
+			obj:       ruleset("rules", "carol"),
+			old:       ruleset("rules", ""),
+			wantPatches: []jsonpatch.JsonPatchOperation{
+				{Operation: "remove", Path: "/metadata/annotations/waf.k8s.coraza.io~1modified-by"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := NewModifiedByWebhook().Handle(t.Context(), admissionRequest(t, tt.operation, "alice", tt.obj, tt.old))
+			require.True(t, resp.Allowed, resp.Result)
+			assert.ElementsMatch(t, tt.wantPatches, resp.Patches)
+		})
+	}
+}
+
+func TestApprovedByWebhook(t *testing.T) {
+	approval := func(approvedBy string) *wafv1alpha1.RuleSetApproval {
+		return &wafv1alpha1.RuleSetApproval{ObjectMeta: metav1.ObjectMeta{
+			Name: "approval", Namespace: "prod",
+			Annotations: map[string]string{wafv1alpha1.AnnotationApprovedBy: approvedBy, "note": "reviewed"},
+		}}
+	}
+
+	t.Log("Recording the creator, overwriting a forged approver")
+	resp := NewApprovedByWebhook().Handle(t.Context(), admissionRequest(t, admissionv1.Create, "carol", approval("bob"), nil))
+	require.True(t, resp.Allowed)
+	assert.Equal(t, []jsonpatch.JsonPatchOperation{
+		{Operation: "replace", Path: "/metadata/annotations/waf.k8s.coraza.io~1approved-by", Value: "carol"},
+	}, resp.Patches)
+
+	t.Log("Keeping the approver on updates")
+	resp = NewApprovedByWebhook().Handle(t.Context(), admissionRequest(t, admissionv1.Update, "alice", approval("alice"), approval("carol")))
+	require.True(t, resp.Allowed)
+	assert.Equal(t, []jsonpatch.JsonPatchOperation{
+		{Operation: "replace", Path: "/metadata/annotations/waf.k8s.coraza.io~1approved-by", Value: "carol"},
+	}, resp.Patches)
+}
+
+func TestApprovalWebhook(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+
+	const candidate = "22222222-2222-2222-2222-222222222222"
+	ruleset := &wafv1alpha1.RuleSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "ruleset", Namespace: "prod"},
+		Status: wafv1alpha1.RuleSetStatus{
+			PendingRevision:        candidate,
+			PendingRevisionAuthors: []string{"alice", "bob"},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(ruleset).Build()
+	approval := func(ruleSet, revision string) *wafv1alpha1.RuleSetApproval {
+		return &wafv1alpha1.RuleSetApproval{
+			ObjectMeta: metav1.ObjectMeta{Name: "approval", Namespace: "prod"},
+			Spec: wafv1alpha1.RuleSetApprovalSpec{
+				RuleSet:  wafv1alpha1.RuleSetReference{Name: ruleSet},
+				Revision: revision,
+			},
+		}
+	}
+
+	tests := []struct {
+		name        string
+		user        string
+		approval    *wafv1alpha1.RuleSetApproval
+		wantAllowed bool
+	}{
+		{
+			name:        "approved by another user",
+			user:        "carol",
+			approval:    approval("ruleset", candidate),
+			wantAllowed: true,
+		},
+		{
+			name:     "approved by an author",
+			user:     "bob",
+			approval: approval("ruleset", candidate),
+		},
+		{
+			name:        "approval of another revision",
+			user:        "bob",
+			approval:    approval("ruleset", "11111111-1111-1111-1111-111111111111"),
+			wantAllowed: true,
+		},
+		{
+			name:        "approval of a missing RuleSet",
+			user:        "bob",
+			approval:    approval("missing", candidate),
+			wantAllowed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := NewApprovalWebhook(c).Handle(t.Context(), admissionRequest(t, admissionv1.Create, tt.user, tt.approval, nil))
+			assert.Equal(t, tt.wantAllowed, resp.Allowed, resp.Result)
+			if !tt.wantAllowed {
+				assert.Contains(t, resp.Result.Message, "must be approved by another user")
+			}
+		})
+	}
+}
+
+func TestEngineWebhook(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+
+	gateway := func(name, className string) *unstructured.Unstructured {
+		gw := &unstructured.Unstructured{}
+		gw.SetGroupVersionKind(schema.GroupVersionKind{Group: gatewayGroup, Version: "v1", Kind: "Gateway"})
+		gw.SetName(name)
+		gw.SetNamespace("team-a")
+		gw.Object["spec"] = map[string]any{"gatewayClassName": className}
+		return gw
+	}
+	gatewayClass := func(name, controllerName string) *unstructured.Unstructured {
+		class := &unstructured.Unstructured{}
+		class.SetGroupVersionKind(schema.GroupVersionKind{Group: gatewayGroup, Version: "v1", Kind: "GatewayClass"})
+		class.SetName(name)
+		class.Object["spec"] = map[string]any{"controllerName": controllerName}
+		return class
+	}
+	engine := func(name, ruleSet, gatewayName string) *wafv1alpha1.Engine {
+		return utils.NewTestEngine(utils.EngineOptions{Name: name, Namespace: "team-a", RuleSetName: ruleSet, GatewayName: gatewayName})
+	}
+	// allowing returns engine with another failure policy, a spec change
+	// leaving its RuleSets and target alone.
+	allowing := func(engine *wafv1alpha1.Engine) *wafv1alpha1.Engine {
+		engine.Spec.FailurePolicy = wafv1alpha1.FailurePolicyAllow
+		return engine
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&wafv1alpha1.RuleSet{ObjectMeta: metav1.ObjectMeta{Name: "rules", Namespace: "team-a"}},
+		engine("existing", "rules", "claimed"),
+		gateway("istio", "istio"),
+		gateway("claimed", "istio"),
+		gateway("envoy", "eg"),
+		gateway("forbidden", "forbidden"),
+		gatewayClass("istio", "istio.io/gateway-controller"),
+		gatewayClass("eg", "gateway.envoyproxy.io/gatewayclass-controller"),
+	).WithInterceptorFuncs(interceptor.Funcs{
+		Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			if obj.GetObjectKind().GroupVersionKind().Kind == "GatewayClass" && key.Name == "forbidden" {
+				return apierrors.NewForbidden(schema.GroupResource{Group: gatewayGroup, Resource: "gatewayclasses"}, key.Name, errors.New("not allowed"))
+			}
+			return c.Get(ctx, key, obj, opts...)
+		},
+	}).Build()
+
+	child := engine("child", "rules", "claimed")
+	child.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: wafv1alpha1.GroupVersion.String(), Kind: "Engine", Name: "parent", UID: "uid", Controller: ptr.To(true),
+	}}
+	deleting := engine("waf", "missing", "envoy")
+	deleting.DeletionTimestamp = &metav1.Time{Time: time.Now()}
+	deleting.Finalizers = []string{"finalizer"}
+
+	tests := []struct {
+		name      string
+		operation admissionv1.Operation
+		obj, old  *wafv1alpha1.Engine
+		wantMsg   string
+	}{
+		{
+			name:      "valid Engine",
+			operation: admissionv1.Create,
+			obj:       engine("waf", "rules", "istio"),
+		},
+		{
+			name:      "missing RuleSet",
+			operation: admissionv1.Create,
+			obj:       engine("waf", "missing", "istio"),
+			wantMsg:   `RuleSet "missing" not found in namespace "team-a"`,
+		},
+		{
+			name:      "Gateway claimed by another Engine",
+			operation: admissionv1.Create,
+			obj:       engine("waf", "rules", "claimed"),
+			wantMsg:   `Target Gateway "claimed" is already claimed by Engine "existing"`,
+		},
+		{
+			name:      "Gateway claimed by the Engine itself",
+			operation: admissionv1.Update,
+			obj:       allowing(engine("existing", "rules", "claimed")),
+			old:       engine("existing", "rules", "claimed"),
+		},
+		{
+			name:      "Engine created for a target selector",
+			operation: admissionv1.Create,
+			obj:       child,
+		},
+		{
+			name:      "unsupported GatewayClass",
+			operation: admissionv1.Create,
+			obj:       engine("waf", "rules", "envoy"),
+			wantMsg:   `Gateway "envoy" has GatewayClass "eg" of controller "gateway.envoyproxy.io/gatewayclass-controller", which the Istio provider does not program`,
+		},
+		{
+			name:      "unreadable GatewayClass",
+			operation: admissionv1.Create,
+			obj:       engine("waf", "rules", "forbidden"),
+			wantMsg:   `GatewayClass "forbidden" of Gateway "forbidden" cannot be verified: the operator is not allowed to read GatewayClasses`,
+		},
+		{
+			name:      "every problem is reported",
+			operation: admissionv1.Create,
+			obj:       engine("waf", "missing", "envoy"),
+			wantMsg:   `RuleSet "missing" not found in namespace "team-a"; Gateway "envoy" has GatewayClass "eg"`,
+		},
+		{
+			name:      "update keeping a missing RuleSet and an unsupported target",
+			operation: admissionv1.Update,
+			obj:       allowing(engine("waf", "missing", "envoy")),
+			old:       engine("waf", "missing", "envoy"),
+		},
+		{
+			name:      "update changing the target",
+			operation: admissionv1.Update,
+			obj:       engine("waf", "missing", "envoy"),
+			old:       engine("waf", "missing", "istio"),
+			wantMsg:   `Gateway "envoy" has GatewayClass "eg"`,
+		},
+		{
+			name:      "update of an Engine being deleted",
+			operation: admissionv1.Update,
+			obj:       deleting,
+			old:       engine("waf", "rules", "istio"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var old client.Object
+			if tt.old != nil {
+				old = tt.old
+			}
+			resp := NewEngineWebhook(c).Handle(t.Context(), admissionRequest(t, tt.operation, "alice", tt.obj, old))
+			if tt.wantMsg == "" {
+				assert.True(t, resp.Allowed, resp.Result)
+				return
+			}
+			require.False(t, resp.Allowed)
+			assert.Contains(t, resp.Result.Message, tt.wantMsg)
+		})
+	}
+}
+
+func TestAdmissionWebhookConfigurer(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	c := fake.NewClientBuilder().WithScheme(scheme).Build()
+	key := client.ObjectKey{Name: AdmissionWebhookConfigurationName}
+
+	t.Log("Configuring nothing without a CA bundle")
+	require.NoError(t, NewAdmissionWebhookConfigurer(c, "coraza-system", "coraza", nil, nil).Start(t.Context()))
+	var mutating admissionregistrationv1.MutatingWebhookConfiguration
+	require.Error(t, c.Get(t.Context(), key, &mutating))
+
+	t.Log("Applying the webhook configurations")
+	require.NoError(t, NewAdmissionWebhookConfigurer(c, "coraza-system", "coraza", []byte("ca"), nil).Start(t.Context()))
+	require.NoError(t, c.Get(t.Context(), key, &mutating))
+	require.Len(t, mutating.Webhooks, 2)
+	assert.Equal(t, "modified-by.waf.k8s.coraza.io", mutating.Webhooks[0].Name)
+	assert.Equal(t, []string{"rulesets", "rulesources", "ruledata"}, mutating.Webhooks[0].Rules[0].Resources)
+	assert.Equal(t, ModifiedByWebhookPath, *mutating.Webhooks[0].ClientConfig.Service.Path)
+	assert.Equal(t, "coraza-system", mutating.Webhooks[0].ClientConfig.Service.Namespace)
+	assert.Equal(t, []byte("ca"), mutating.Webhooks[0].ClientConfig.CABundle)
+	assert.Equal(t, admissionregistrationv1.Fail, *mutating.Webhooks[0].FailurePolicy)
+	assert.Nil(t, mutating.Webhooks[0].NamespaceSelector, "every namespace is covered")
+	assert.Equal(t, ApprovedByWebhookPath, *mutating.Webhooks[1].ClientConfig.Service.Path)
+
+	var validating admissionregistrationv1.ValidatingWebhookConfiguration
+	require.NoError(t, c.Get(t.Context(), key, &validating))
+	require.Len(t, validating.Webhooks, 2)
+	assert.Equal(t, ApprovalWebhookPath, *validating.Webhooks[0].ClientConfig.Service.Path)
+	assert.Equal(t, []admissionregistrationv1.OperationType{admissionregistrationv1.Create}, validating.Webhooks[0].Rules[0].Operations)
+	assert.Equal(t, EngineWebhookPath, *validating.Webhooks[1].ClientConfig.Service.Path)
+	assert.Equal(t, []string{"engines"}, validating.Webhooks[1].Rules[0].Resources)
+
+	t.Log("Covering only the watched namespaces")
+	require.NoError(t, NewAdmissionWebhookConfigurer(c, "coraza-system", "coraza", []byte("ca"), []string{"team-a", "team-b"}).Start(t.Context()))
+	require.NoError(t, c.Get(t.Context(), key, &validating))
+	assert.Equal(t, &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{
+		Key: "kubernetes.io/metadata.name", Operator: metav1.LabelSelectorOpIn, Values: []string{"team-a", "team-b"},
+	}}}, validating.Webhooks[0].NamespaceSelector)
+}
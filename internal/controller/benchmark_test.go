@@ -0,0 +1,262 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/internal/defaults"
+	"github.com/networking-incubator/coraza-kubernetes-operator/internal/rulesets/cache"
+	"github.com/networking-incubator/coraza-kubernetes-operator/test/utils"
+)
+
+// -----------------------------------------------------------------------------
+// Benchmarks - Configuration
+// -----------------------------------------------------------------------------
+
+// benchScaleEnv sets the number of Engines/RuleSets created by the scale
+// benchmarks (default defaultBenchScale).
+const benchScaleEnv = "BENCH_SCALE"
+
+// benchRuleSetPathEnv points at a rendered CoreRuleSet manifest (as produced
+// by `make coraza.generaterules`). The CRS benchmark is skipped when unset.
+const benchRuleSetPathEnv = "RULESET_PATH"
+
+const defaultBenchScale = 1000
+
+func benchScale(b *testing.B) int {
+	b.Helper()
+	v := os.Getenv(benchScaleEnv)
+	if v == "" {
+		return defaultBenchScale
+	}
+	n, err := strconv.Atoi(v)
+	require.NoError(b, err, "invalid %s", benchScaleEnv)
+	require.Positive(b, n, "%s must be positive", benchScaleEnv)
+	return n
+}
+
+// createBenchNamespace creates an isolated namespace for a benchmark and
+// deletes it on cleanup.
+func createBenchNamespace(b *testing.B, ctx context.Context) string {
+	b.Helper()
+	ns := &corev1.Namespace{}
+	ns.Name = fmt.Sprintf("bench-%s", uuid.New().String()[:8])
+	require.NoError(b, k8sClient.Create(ctx, ns))
+	b.Cleanup(func() {
+		if err := k8sClient.Delete(ctx, ns); err != nil {
+			b.Logf("Failed to delete bench namespace: %v", err)
+		}
+	})
+	return ns.Name
+}
+
+// reportThroughput records reconciles per second for the timed section.
+func reportThroughput(b *testing.B, start time.Time) {
+	b.Helper()
+	if elapsed := time.Since(start).Seconds(); elapsed > 0 {
+		b.ReportMetric(float64(b.N)/elapsed, "reconciles/s")
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Benchmarks - RuleSet
+// -----------------------------------------------------------------------------
+
+// BenchmarkRuleSetReconcile_Scale measures RuleSet reconcile throughput with
+// BENCH_SCALE RuleSets sharing one RuleSource, cycling through all of them.
+func BenchmarkRuleSetReconcile_Scale(b *testing.B) {
+	ctx := context.Background()
+	ns := createBenchNamespace(b, ctx)
+	n := benchScale(b)
+
+	source := utils.NewTestRuleSource("bench-rules", ns, "SecRuleEngine On\nSecRule REQUEST_URI \"@contains /admin\" \"id:1,phase:1,deny,status:403\"")
+	require.NoError(b, k8sClient.Create(ctx, source))
+
+	reqs := make([]ctrl.Request, n)
+	for i := range n {
+		rs := utils.NewTestRuleSet(utils.RuleSetOptions{
+			Name:      fmt.Sprintf("bench-ruleset-%d", i),
+			Namespace: ns,
+			Sources:   []wafv1alpha1.SourceReference{{Name: source.Name}},
+		})
+		require.NoError(b, k8sClient.Create(ctx, rs))
+		reqs[i] = ctrl.Request{NamespacedName: types.NamespacedName{Name: rs.Name, Namespace: ns}}
+	}
+
+	reconciler := &RuleSetReconciler{
+		Client:   k8sClient,
+		Scheme:   scheme,
+		Recorder: utils.NewTestRecorder(),
+		Cache:    cache.NewRuleSetCache(),
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	start := time.Now()
+	for i := range b.N {
+		if _, err := reconciler.Reconcile(ctx, reqs[i%n]); err != nil {
+			b.Fatalf("reconcile %s: %v", reqs[i%n], err)
+		}
+	}
+	b.StopTimer()
+	reportThroughput(b, start)
+}
+
+// BenchmarkRuleSetReconcile_CoreRuleSet measures the time to compose,
+// validate and cache the full OWASP CoreRuleSet. Render the manifest with
+// `make coraza.generaterules` and point RULESET_PATH at it.
+func BenchmarkRuleSetReconcile_CoreRuleSet(b *testing.B) {
+	path := os.Getenv(benchRuleSetPathEnv)
+	if path == "" {
+		b.Skipf("%s not set; run `make bench.coreruleset`", benchRuleSetPathEnv)
+	}
+
+	ctx := context.Background()
+	ns := createBenchNamespace(b, ctx)
+	ruleSetName := createManifestObjects(b, ctx, path, ns)
+	require.NotEmpty(b, ruleSetName, "manifest %s contains no RuleSet", path)
+
+	reconciler := &RuleSetReconciler{
+		Client:   k8sClient,
+		Scheme:   scheme,
+		Recorder: utils.NewTestRecorder(),
+		Cache:    cache.NewRuleSetCache(),
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: ruleSetName, Namespace: ns}}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		if _, err := reconciler.Reconcile(ctx, req); err != nil {
+			b.Fatalf("reconcile: %v", err)
+		}
+	}
+	b.StopTimer()
+
+	_, ok := reconciler.Cache.Get(ns + "/" + ruleSetName)
+	require.True(b, ok, "CoreRuleSet was not cached; check the RuleSet status for errors")
+}
+
+// createManifestObjects creates every object in a multi-document YAML
+// manifest in namespace ns and returns the name of the last RuleSet found.
+func createManifestObjects(b *testing.B, ctx context.Context, path, ns string) string {
+	b.Helper()
+	f, err := os.Open(path)
+	require.NoError(b, err)
+	defer func() { _ = f.Close() }()
+
+	var ruleSetName string
+	decoder := utilyaml.NewYAMLOrJSONDecoder(f, 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			require.NoError(b, err)
+		}
+		if len(obj.Object) == 0 {
+			continue
+		}
+		obj.SetNamespace(ns)
+		require.NoError(b, k8sClient.Create(ctx, obj), "create %s %s", obj.GetKind(), obj.GetName())
+		if obj.GetKind() == "RuleSet" {
+			ruleSetName = obj.GetName()
+		}
+	}
+	return ruleSetName
+}
+
+// -----------------------------------------------------------------------------
+// Benchmarks - Engine
+// -----------------------------------------------------------------------------
+
+// BenchmarkEngineReconcile_Scale measures steady-state Engine reconcile
+// throughput with BENCH_SCALE Engines, each targeting its own Gateway. All
+// Engines are provisioned once before timing starts.
+func BenchmarkEngineReconcile_Scale(b *testing.B) {
+	ctx := context.Background()
+	ns := createBenchNamespace(b, ctx)
+	n := benchScale(b)
+
+	source := utils.NewTestRuleSource("bench-rules", ns, "SecRule REQUEST_URI \"@contains /admin\" \"id:1,phase:1,deny,status:403\"")
+	require.NoError(b, k8sClient.Create(ctx, source))
+	ruleset := utils.NewTestRuleSet(utils.RuleSetOptions{
+		Name:      "bench-ruleset",
+		Namespace: ns,
+		Sources:   []wafv1alpha1.SourceReference{{Name: source.Name}},
+	})
+	require.NoError(b, k8sClient.Create(ctx, ruleset))
+
+	reconciler := &EngineReconciler{
+		Client:                    k8sClient,
+		Scheme:                    scheme,
+		Recorder:                  utils.NewTestRecorder(),
+		kubeClient:                testKubeClient,
+		ruleSetCacheServerCluster: "bench-cluster",
+		defaultWasmImage:          defaults.DefaultCorazaWasmOCIReference,
+		operatorNamespace:         testNamespace,
+	}
+
+	reqs := make([]ctrl.Request, n)
+	for i := range n {
+		gwName := fmt.Sprintf("bench-gw-%d", i)
+		createTestGateway(b, ctx, k8sClient, gwName, ns)
+		engine := utils.NewTestEngine(utils.EngineOptions{
+			Name:        fmt.Sprintf("bench-engine-%d", i),
+			Namespace:   ns,
+			RuleSetName: ruleset.Name,
+			GatewayName: gwName,
+		})
+		require.NoError(b, k8sClient.Create(ctx, engine))
+		reqs[i] = ctrl.Request{NamespacedName: types.NamespacedName{Name: engine.Name, Namespace: ns}}
+
+		// Add the finalizer, then provision.
+		for range 2 {
+			_, err := reconciler.Reconcile(ctx, reqs[i])
+			require.NoError(b, err)
+		}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	start := time.Now()
+	for i := range b.N {
+		if _, err := reconciler.Reconcile(ctx, reqs[i%n]); err != nil {
+			b.Fatalf("reconcile %s: %v", reqs[i%n], err)
+		}
+	}
+	b.StopTimer()
+	reportThroughput(b, start)
+}
@@ -0,0 +1,237 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/discovery"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// -----------------------------------------------------------------------------
+// Capabilities - Vars
+// -----------------------------------------------------------------------------
+
+// Capability names an optional API the operator integrates with.
+type Capability string
+
+// Optional APIs detected at startup. Features that depend on a missing API
+// are disabled instead of failing on the missing kind.
+const (
+	// CapabilityWasmPlugin is the Istio WasmPlugin API the WASM driver
+	// provisions.
+	CapabilityWasmPlugin Capability = "WasmPlugin"
+	// CapabilityIstioNetworking is the Istio ServiceEntry and DestinationRule
+	// API used to route WASM plugins to the RuleSet cache server.
+	CapabilityIstioNetworking Capability = "IstioNetworking"
+	// CapabilityIstioTelemetry is the Istio Telemetry API used to enable the
+	// access log of the gateways of Engines.
+	CapabilityIstioTelemetry Capability = "IstioTelemetry"
+	// CapabilityGatewayV1 is the Gateway API v1 Gateway that Engines target.
+	CapabilityGatewayV1 Capability = "GatewayV1"
+	// CapabilityGatewayV1beta1 is the Gateway API v1beta1 Gateway, reported
+	// for clusters with an older Gateway API installation.
+	CapabilityGatewayV1beta1 Capability = "GatewayV1beta1"
+	// CapabilityReferenceGrant is the Gateway API ReferenceGrant.
+	CapabilityReferenceGrant Capability = "ReferenceGrant"
+	// CapabilityHTTPRoute is the Gateway API v1 HTTPRoute that RuleSet
+	// route overlays target.
+	CapabilityHTTPRoute Capability = "HTTPRoute"
+	// CapabilityGRPCRoute is the Gateway API v1 GRPCRoute that RuleSet
+	// route scopes may select.
+	CapabilityGRPCRoute Capability = "GRPCRoute"
+	// CapabilityOCM is the Open Cluster Management ManifestWork and
+	// PlacementDecision API used by FleetBackendOCM.
+	CapabilityOCM Capability = "OCM"
+)
+
+// capabilityProbe is a resource whose presence in API discovery provides a
+// Capability. Every probe of a Capability must be present.
+type capabilityProbe struct {
+	groupVersion string
+	resource     string
+}
+
+var capabilityProbes = map[Capability][]capabilityProbe{
+	CapabilityWasmPlugin: {{WasmPluginGVK.GroupVersion().String(), "wasmplugins"}},
+	CapabilityIstioNetworking: {
+		{"networking.istio.io/v1", "serviceentries"},
+		{"networking.istio.io/v1", "destinationrules"},
+	},
+	CapabilityIstioTelemetry: {{TelemetryGVK.GroupVersion().String(), "telemetries"}},
+	CapabilityGatewayV1:      {{"gateway.networking.k8s.io/v1", "gateways"}},
+	CapabilityGatewayV1beta1: {{"gateway.networking.k8s.io/v1beta1", "gateways"}},
+	CapabilityReferenceGrant: {{"gateway.networking.k8s.io/v1beta1", "referencegrants"}},
+	CapabilityHTTPRoute:      {{"gateway.networking.k8s.io/v1", "httproutes"}},
+	CapabilityGRPCRoute:      {{"gateway.networking.k8s.io/v1", "grpcroutes"}},
+	CapabilityOCM: {
+		{ManifestWorkGVK.GroupVersion().String(), "manifestworks"},
+		{placementDecisionGVK.GroupVersion().String(), "placementdecisions"},
+	},
+}
+
+// capabilityDetectionInterval is how often CapabilityMonitor re-runs the
+// detection.
+const capabilityDetectionInterval = 5 * time.Minute
+
+var capabilityAvailable = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "coraza_operator_capability_available",
+		Help: "Whether an optional API the operator integrates with is installed (1) or not (0).",
+	},
+	[]string{"capability"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(capabilityAvailable)
+}
+
+// -----------------------------------------------------------------------------
+// Capabilities
+// -----------------------------------------------------------------------------
+
+// Capabilities reports which optional APIs are installed in the cluster.
+type Capabilities map[Capability]bool
+
+// Has reports whether the API named by c is installed.
+func (c Capabilities) Has(name Capability) bool {
+	return c[name]
+}
+
+// String lists the capabilities as name=bool pairs, sorted by name.
+func (c Capabilities) String() string {
+	pairs := make([]string, 0, len(c))
+	for _, name := range slices.Sorted(maps.Keys(c)) {
+		pairs = append(pairs, fmt.Sprintf("%s=%t", name, c[name]))
+	}
+	return strings.Join(pairs, ",")
+}
+
+// record exports the capabilities as the coraza_operator_capability_available
+// metric.
+func (c Capabilities) record() {
+	for name, available := range c {
+		value := 0.0
+		if available {
+			value = 1
+		}
+		capabilityAvailable.WithLabelValues(string(name)).Set(value)
+	}
+}
+
+// DetectCapabilities queries API discovery for every optional API. A group
+// version that is not served means the capability is missing; any other
+// discovery error is returned.
+func DetectCapabilities(client discovery.DiscoveryInterface) (Capabilities, error) {
+	served := map[string]map[string]bool{}
+	resources := func(groupVersion string) (map[string]bool, error) {
+		if names, ok := served[groupVersion]; ok {
+			return names, nil
+		}
+		names := map[string]bool{}
+		list, err := client.ServerResourcesForGroupVersion(groupVersion)
+		if err != nil && !apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("discovering %s: %w", groupVersion, err)
+		}
+		if list != nil {
+			for _, r := range list.APIResources {
+				names[r.Name] = true
+			}
+		}
+		served[groupVersion] = names
+		return names, nil
+	}
+
+	caps := Capabilities{}
+	for name, probes := range capabilityProbes {
+		caps[name] = true
+		for _, p := range probes {
+			names, err := resources(p.groupVersion)
+			if err != nil {
+				return nil, err
+			}
+			caps[name] = caps[name] && names[p.resource]
+		}
+	}
+	caps.record()
+	return caps, nil
+}
+
+// -----------------------------------------------------------------------------
+// Capabilities - Monitor
+// -----------------------------------------------------------------------------
+
+// CapabilityMonitor periodically re-runs capability detection. Watches on
+// optional kinds are only set up at startup, so when an API is installed or
+// removed, the monitor stops the manager and the restarted operator enables
+// or disables the dependent features. It runs on every replica.
+type CapabilityMonitor struct {
+	client   discovery.DiscoveryInterface
+	detected Capabilities
+	interval time.Duration
+}
+
+// NewCapabilityMonitor returns a new CapabilityMonitor runnable that compares
+// the installed APIs against detected, the capabilities found at startup.
+func NewCapabilityMonitor(client discovery.DiscoveryInterface, detected Capabilities) *CapabilityMonitor {
+	return &CapabilityMonitor{client: client, detected: detected, interval: capabilityDetectionInterval}
+}
+
+// NeedLeaderElection makes the monitor run on every replica: each one sets
+// up its own watches.
+func (m *CapabilityMonitor) NeedLeaderElection() bool {
+	return false
+}
+
+// Start re-runs the detection every interval until ctx is done. It satisfies
+// the manager.Runnable interface.
+//
+// Returning an error shuts down the manager, which is how a change in the
+// installed APIs is applied. Discovery failures are logged and retried on
+// the next tick.
+func (m *CapabilityMonitor) Start(ctx context.Context) error {
+	log := ctrl.Log.WithName("capabilities")
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		caps, err := DetectCapabilities(m.client)
+		if err != nil {
+			log.Error(err, "Failed to detect optional APIs")
+			continue
+		}
+		if !maps.Equal(caps, m.detected) {
+			log.Info("Optional APIs changed, restarting", "detected", m.detected.String(), "current", caps.String())
+			return fmt.Errorf("optional APIs changed from %s to %s", m.detected, caps)
+		}
+	}
+}
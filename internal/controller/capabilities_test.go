@@ -0,0 +1,134 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	clienttesting "k8s.io/client-go/testing"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/test/utils"
+)
+
+func fakeDiscovery(resources ...*metav1.APIResourceList) *fakediscovery.FakeDiscovery {
+	return &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{Resources: resources}}
+}
+
+func apiResources(groupVersion string, names ...string) *metav1.APIResourceList {
+	list := &metav1.APIResourceList{GroupVersion: groupVersion}
+	for _, name := range names {
+		list.APIResources = append(list.APIResources, metav1.APIResource{Name: name})
+	}
+	return list
+}
+
+func TestDetectCapabilities(t *testing.T) {
+	caps, err := DetectCapabilities(fakeDiscovery(
+		apiResources("gateway.networking.k8s.io/v1", "gateways", "httproutes"),
+		apiResources("gateway.networking.k8s.io/v1beta1", "referencegrants"),
+		apiResources("networking.istio.io/v1", "serviceentries"),
+		apiResources("telemetry.istio.io/v1", "telemetries"),
+	))
+	require.NoError(t, err)
+
+	assert.Equal(t, Capabilities{
+		CapabilityWasmPlugin:      false,
+		CapabilityIstioNetworking: false,
+		CapabilityIstioTelemetry:  true,
+		CapabilityGatewayV1:       true,
+		CapabilityGatewayV1beta1:  false,
+		CapabilityReferenceGrant:  true,
+		CapabilityHTTPRoute:       true,
+		CapabilityGRPCRoute:       false,
+		CapabilityOCM:             false,
+	}, caps, "a capability needs every one of its resources")
+	assert.Equal(t, "GRPCRoute=false,GatewayV1=true,GatewayV1beta1=false,HTTPRoute=true,IstioNetworking=false,IstioTelemetry=true,OCM=false,ReferenceGrant=true,WasmPlugin=false", caps.String())
+}
+
+func TestCapabilityMonitor(t *testing.T) {
+	client := fakeDiscovery(apiResources("gateway.networking.k8s.io/v1", "gateways"))
+	detected, err := DetectCapabilities(client)
+	require.NoError(t, err)
+
+	m := NewCapabilityMonitor(client, detected)
+	m.interval = 10 * time.Millisecond
+
+	client.Resources = append(client.Resources, apiResources(WasmPluginGVK.GroupVersion().String(), "wasmplugins"))
+
+	errs := make(chan error, 1)
+	go func() { errs <- m.Start(t.Context()) }()
+	select {
+	case err := <-errs:
+		require.Error(t, err, "an installed API restarts the manager")
+		assert.Contains(t, err.Error(), "WasmPlugin=true")
+	case <-time.After(5 * time.Second):
+		t.Fatal("the monitor did not notice the installed API")
+	}
+}
+
+func TestEngineReconciler_MissingCapabilities(t *testing.T) {
+	scheme := newFleetTestScheme(t)
+	engine := utils.NewTestEngine(utils.EngineOptions{Name: "waf", Namespace: "team-a"})
+	engine.Finalizers = []string{networkPolicyFinalizer}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(engine)}
+
+	condition := func(t *testing.T, c client.Client, conditionType string) *metav1.Condition {
+		var got wafv1alpha1.Engine
+		require.NoError(t, c.Get(t.Context(), req.NamespacedName, &got))
+		require.NotNil(t, got.Status)
+		return apimeta.FindStatusCondition(got.Status.Conditions, conditionType)
+	}
+
+	t.Run("Gateway API not installed", func(t *testing.T) {
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(engine.DeepCopy()).WithStatusSubresource(engine).Build()
+		r := &EngineReconciler{Client: c, Scheme: scheme, Recorder: utils.NewTestRecorder(), targets: newTargetCache(), capabilities: Capabilities{}}
+
+		_, err := r.Reconcile(t.Context(), req)
+		require.NoError(t, err)
+
+		cond := condition(t, c, conditionAccepted)
+		require.NotNil(t, cond)
+		assert.Equal(t, metav1.ConditionFalse, cond.Status)
+		assert.Equal(t, "GatewayAPINotInstalled", cond.Reason)
+	})
+
+	t.Run("Istio not installed", func(t *testing.T) {
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(engine.DeepCopy()).WithStatusSubresource(engine).Build()
+		r := &EngineReconciler{Client: c, Scheme: scheme, Recorder: utils.NewTestRecorder(), capabilities: Capabilities{CapabilityGatewayV1: true}}
+
+		var got wafv1alpha1.Engine
+		require.NoError(t, c.Get(t.Context(), req.NamespacedName, &got))
+		got.Status = &wafv1alpha1.EngineStatus{}
+		_, err := r.selectDriver(t.Context(), ctrl.Log, req, &got)
+		require.NoError(t, err)
+
+		cond := condition(t, c, conditionDegraded)
+		require.NotNil(t, cond)
+		assert.Equal(t, metav1.ConditionTrue, cond.Status)
+		assert.Equal(t, "IstioNotInstalled", cond.Reason)
+	})
+}
@@ -0,0 +1,126 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// -----------------------------------------------------------------------------
+// Conversion Webhook - RBAC
+// -----------------------------------------------------------------------------
+
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=patch,resourceNames=engines.waf.k8s.coraza.io;rulesets.waf.k8s.coraza.io
+
+// -----------------------------------------------------------------------------
+// Conversion Webhook - Vars
+// -----------------------------------------------------------------------------
+
+// ConversionWebhookPath is the path the conversion webhook is served at.
+const ConversionWebhookPath = "/convert"
+
+// ConvertedCRDs are the CRDs served in more than one version, which the
+// conversion webhook converts between.
+var ConvertedCRDs = []string{"engines.waf.k8s.coraza.io", "rulesets.waf.k8s.coraza.io"}
+
+// -----------------------------------------------------------------------------
+// Conversion Webhook
+// -----------------------------------------------------------------------------
+
+// ConversionWebhookConfigurer points the conversion of the ConvertedCRDs to
+// the conversion webhook of the operator, behind a Service in the operator
+// namespace, trusting caBundle to verify its serving certificate. The CRDs
+// are shipped with the None conversion strategy, which serves every version
+// of an object unchanged and only holds while the versions share their
+// schema. It runs once, on the leader, at startup.
+type ConversionWebhookConfigurer struct {
+	client    client.Client
+	namespace string
+	service   string
+	caBundle  []byte
+}
+
+// NewConversionWebhookConfigurer returns a new ConversionWebhookConfigurer
+// runnable for the conversion webhook behind Service service in namespace.
+func NewConversionWebhookConfigurer(c client.Client, namespace, service string, caBundle []byte) *ConversionWebhookConfigurer {
+	return &ConversionWebhookConfigurer{client: c, namespace: namespace, service: service, caBundle: caBundle}
+}
+
+// NeedLeaderElection makes only the leader configure the CRDs.
+func (c *ConversionWebhookConfigurer) NeedLeaderElection() bool {
+	return true
+}
+
+// Start patches the conversion of every CRD in ConvertedCRDs. It satisfies
+// the manager.Runnable interface.
+//
+// Failures are logged and do not stop the manager: the CRDs keep their
+// previous conversion, and the patch is retried on the next start.
+func (c *ConversionWebhookConfigurer) Start(ctx context.Context) error {
+	log := ctrl.Log.WithName("conversion-webhook")
+
+	patch, err := c.patch()
+	if err != nil {
+		log.Error(err, "Failed to build the conversion of the CustomResourceDefinitions")
+		return nil
+	}
+	for _, name := range ConvertedCRDs {
+		crd := &unstructured.Unstructured{}
+		crd.SetGroupVersionKind(customResourceDefinitionGVK)
+		crd.SetName(name)
+		if err := c.client.Patch(ctx, crd, client.RawPatch(types.MergePatchType, patch)); err != nil {
+			log.Error(err, "Failed to configure the conversion webhook", "crd", name)
+			continue
+		}
+		log.Info("Configured the conversion webhook", "crd", name, "service", c.namespace+"/"+c.service)
+	}
+	return nil
+}
+
+// patch returns the merge patch setting the conversion of a CRD to the
+// webhook.
+func (c *ConversionWebhookConfigurer) patch() ([]byte, error) {
+	if len(c.caBundle) == 0 {
+		return nil, fmt.Errorf("no CA bundle to verify the conversion webhook of Service %s/%s", c.namespace, c.service)
+	}
+	return json.Marshal(map[string]any{
+		"spec": map[string]any{
+			"conversion": map[string]any{
+				"strategy": "Webhook",
+				"webhook": map[string]any{
+					"clientConfig": map[string]any{
+						"service": map[string]any{
+							"namespace": c.namespace,
+							"name":      c.service,
+							"path":      ConversionWebhookPath,
+							"port":      443,
+						},
+						"caBundle": c.caBundle,
+					},
+					"conversionReviewVersions": []string{"v1"},
+				},
+			},
+		},
+	})
+}
@@ -0,0 +1,62 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+func TestConversionWebhookConfigurer(t *testing.T) {
+	scheme := newFleetTestScheme(t)
+
+	rulesets := testCRD("rulesets.waf.k8s.coraza.io", wafv1alpha1.Group, "RuleSet", "v1alpha1")
+	engines := testCRD("engines.waf.k8s.coraza.io", wafv1alpha1.Group, "Engine", "v1alpha1")
+	sources := testCRD("rulesources.waf.k8s.coraza.io", wafv1alpha1.Group, "RuleSource", "v1alpha1")
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(rulesets, engines, sources).Build()
+
+	conversion := func(crd *unstructured.Unstructured) map[string]any {
+		got := &unstructured.Unstructured{}
+		got.SetGroupVersionKind(customResourceDefinitionGVK)
+		require.NoError(t, c.Get(t.Context(), client.ObjectKeyFromObject(crd), got))
+		conversion, _, _ := unstructured.NestedMap(got.Object, "spec", "conversion")
+		return conversion
+	}
+
+	t.Log("Leaving the CRDs alone without a CA bundle")
+	require.NoError(t, NewConversionWebhookConfigurer(c, "coraza-system", "coraza", nil).Start(t.Context()))
+	assert.Empty(t, conversion(engines))
+
+	t.Log("Pointing the converted CRDs to the webhook")
+	require.NoError(t, NewConversionWebhookConfigurer(c, "coraza-system", "coraza", []byte("ca")).Start(t.Context()))
+	for _, crd := range []*unstructured.Unstructured{rulesets, engines} {
+		got := conversion(crd)
+		assert.Equal(t, "Webhook", got["strategy"], crd.GetName())
+		service, _, _ := unstructured.NestedMap(got, "webhook", "clientConfig", "service")
+		assert.Equal(t, map[string]any{"namespace": "coraza-system", "name": "coraza", "path": ConversionWebhookPath, "port": int64(443)}, service)
+		caBundle, _, _ := unstructured.NestedString(got, "webhook", "clientConfig", "caBundle")
+		assert.Equal(t, "Y2E=", caBundle)
+	}
+	assert.Empty(t, conversion(sources), "CRDs served in one version are left alone")
+}
@@ -0,0 +1,381 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// -----------------------------------------------------------------------------
+// Dry-Run Reconcile - Report
+// -----------------------------------------------------------------------------
+
+// DryRunChange is a change a controller would have made to the cluster.
+type DryRunChange struct {
+	// Action is create, update, delete or deleteAllOf.
+	Action string `json:"action"`
+
+	Kind        string `json:"kind"`
+	Namespace   string `json:"namespace,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Subresource string `json:"subresource,omitempty"`
+
+	// Patch is the JSON merge patch from the live object to the object the
+	// API server returned for the dry-run write, or the whole object for a
+	// create. Server-managed metadata is left out.
+	Patch json.RawMessage `json:"patch,omitempty"`
+
+	// LastSeen is when the change was last computed.
+	LastSeen metav1.Time `json:"lastSeen"`
+}
+
+// key identifies the object, or subresource, a change applies to.
+func (c DryRunChange) key() string {
+	return strings.Join([]string{c.Kind, c.Namespace, c.Name, c.Subresource}, "/")
+}
+
+// DryRunReport collects the changes computed in --dry-run-reconcile mode,
+// keeping the latest one per object and subresource.
+type DryRunReport struct {
+	mu      sync.Mutex
+	log     logr.Logger
+	path    string
+	changes map[string]DryRunChange
+}
+
+// NewDryRunReport returns a DryRunReport that logs each new change and, when
+// path is not empty, writes all of them as JSON to path.
+func NewDryRunReport(log logr.Logger, path string) *DryRunReport {
+	return &DryRunReport{log: log, path: path, changes: map[string]DryRunChange{}}
+}
+
+// Changes returns the collected changes, sorted by kind, namespace, name and
+// subresource.
+func (r *DryRunReport) Changes() []DryRunChange {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sortedLocked()
+}
+
+func (r *DryRunReport) sortedLocked() []DryRunChange {
+	changes := make([]DryRunChange, 0, len(r.changes))
+	for _, c := range r.changes {
+		changes = append(changes, c)
+	}
+	slices.SortFunc(changes, func(a, b DryRunChange) int { return strings.Compare(a.key(), b.key()) })
+	return changes
+}
+
+// record adds change to the report. Recomputing the same change, as the
+// controllers do on every resync since nothing is applied, only refreshes
+// its LastSeen time.
+func (r *DryRunReport) record(change DryRunChange) {
+	change.LastSeen = metav1.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	prev, seen := r.changes[change.key()]
+	r.changes[change.key()] = change
+	if seen && prev.Action == change.Action && string(prev.Patch) == string(change.Patch) {
+		return
+	}
+
+	r.log.Info("DryRun: would apply change",
+		"action", change.Action, "kind", change.Kind, "namespace", change.Namespace, "name", change.Name,
+		"subresource", change.Subresource, "patch", string(change.Patch))
+	if r.path == "" {
+		return
+	}
+	if err := r.writeLocked(); err != nil {
+		r.log.Error(err, "DryRun: Failed to write report", "path", r.path)
+	}
+}
+
+// writeLocked replaces the report file, through a temporary file in the same
+// directory so that readers never see a partial report.
+func (r *DryRunReport) writeLocked() error {
+	data, err := json.MarshalIndent(struct {
+		GeneratedAt metav1.Time    `json:"generatedAt"`
+		Changes     []DryRunChange `json:"changes"`
+	}{GeneratedAt: metav1.Now(), Changes: r.sortedLocked()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(r.path), ".dry-run-report-*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), r.path)
+}
+
+// -----------------------------------------------------------------------------
+// Dry-Run Reconcile - Client
+// -----------------------------------------------------------------------------
+
+// NewDryRunReconcileClient wraps c so that every write is sent to the API
+// server in dry-run mode, which validates and defaults it without persisting
+// it, and the resulting change is recorded in report. Writes that would not
+// change the live object are not recorded.
+func NewDryRunReconcileClient(c client.Client, report *DryRunReport) client.Client {
+	return &dryRunReconcileClient{Client: client.NewDryRunClient(c), report: report}
+}
+
+type dryRunReconcileClient struct {
+	client.Client
+	report *DryRunReport
+}
+
+func (c *dryRunReconcileClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if err := c.Client.Create(ctx, obj, opts...); err != nil {
+		return err
+	}
+	c.recordWrite(nil, obj, "")
+	return nil
+}
+
+func (c *dryRunReconcileClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	live := c.live(ctx, obj)
+	if err := c.Client.Update(ctx, obj, opts...); err != nil {
+		return err
+	}
+	c.recordWrite(live, obj, "")
+	return nil
+}
+
+func (c *dryRunReconcileClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	live := c.live(ctx, obj)
+	if err := c.Client.Patch(ctx, obj, patch, opts...); err != nil {
+		return err
+	}
+	c.recordWrite(live, obj, "")
+	return nil
+}
+
+func (c *dryRunReconcileClient) Apply(ctx context.Context, obj runtime.ApplyConfiguration, opts ...client.ApplyOption) error {
+	if err := c.Client.Apply(ctx, obj, opts...); err != nil {
+		return err
+	}
+	// An apply configuration carries no object to compare with the live
+	// one, so the change is reported as a whole.
+	data, _ := json.Marshal(obj)
+	u := &unstructured.Unstructured{}
+	if err := json.Unmarshal(data, &u.Object); err == nil {
+		c.report.record(DryRunChange{Action: "update", Kind: u.GetKind(), Namespace: u.GetNamespace(), Name: u.GetName(), Patch: data})
+	}
+	return nil
+}
+
+func (c *dryRunReconcileClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	if err := c.Client.Delete(ctx, obj, opts...); err != nil {
+		return err
+	}
+	c.report.record(DryRunChange{Action: "delete", Kind: c.kind(obj), Namespace: obj.GetNamespace(), Name: obj.GetName()})
+	return nil
+}
+
+func (c *dryRunReconcileClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	if err := c.Client.DeleteAllOf(ctx, obj, opts...); err != nil {
+		return err
+	}
+	listOpts := &client.DeleteAllOfOptions{}
+	listOpts.ApplyOptions(opts)
+	c.report.record(DryRunChange{Action: "deleteAllOf", Kind: c.kind(obj), Namespace: listOpts.Namespace})
+	return nil
+}
+
+func (c *dryRunReconcileClient) Status() client.SubResourceWriter {
+	return c.SubResource("status")
+}
+
+func (c *dryRunReconcileClient) SubResource(subResource string) client.SubResourceClient {
+	return &dryRunReconcileSubResourceClient{SubResourceClient: c.Client.SubResource(subResource), parent: c, subResource: subResource}
+}
+
+type dryRunReconcileSubResourceClient struct {
+	client.SubResourceClient
+	parent      *dryRunReconcileClient
+	subResource string
+}
+
+func (sc *dryRunReconcileSubResourceClient) Create(ctx context.Context, obj, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+	if err := sc.SubResourceClient.Create(ctx, obj, subResource, opts...); err != nil {
+		return err
+	}
+	sc.parent.recordWrite(nil, obj, sc.subResource)
+	return nil
+}
+
+func (sc *dryRunReconcileSubResourceClient) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	live := sc.parent.live(ctx, obj)
+	if err := sc.SubResourceClient.Update(ctx, obj, opts...); err != nil {
+		return err
+	}
+	sc.parent.recordWrite(live, obj, sc.subResource)
+	return nil
+}
+
+func (sc *dryRunReconcileSubResourceClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+	live := sc.parent.live(ctx, obj)
+	if err := sc.SubResourceClient.Patch(ctx, obj, patch, opts...); err != nil {
+		return err
+	}
+	sc.parent.recordWrite(live, obj, sc.subResource)
+	return nil
+}
+
+// live returns the current state of obj, or nil when it does not exist or
+// cannot be read, in which case the write is reported as a create.
+func (c *dryRunReconcileClient) live(ctx context.Context, obj client.Object) client.Object {
+	var live client.Object
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		current := &unstructured.Unstructured{}
+		current.SetGroupVersionKind(u.GroupVersionKind())
+		live = current
+	} else {
+		gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+		if err != nil {
+			return nil
+		}
+		o, err := c.Scheme().New(gvk)
+		if err != nil {
+			return nil
+		}
+		live, ok = o.(client.Object)
+		if !ok {
+			return nil
+		}
+	}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(obj), live); err != nil {
+		if !apierrors.IsNotFound(err) {
+			c.report.log.V(debugLevel).Info("DryRun: Failed to read live object", "kind", c.kind(obj), "namespace", obj.GetNamespace(), "name", obj.GetName(), "error", err.Error())
+		}
+		return nil
+	}
+	return live
+}
+
+// recordWrite records the change from live to result, the object returned by
+// the dry-run write, unless it changes nothing. A write to an object that
+// does not exist yet is a create.
+func (c *dryRunReconcileClient) recordWrite(live, result client.Object, subResource string) {
+	after, err := dryRunComparable(result)
+	if err != nil {
+		return
+	}
+	action, before := "create", []byte("{}")
+	if live != nil {
+		if before, err = dryRunComparable(live); err != nil {
+			return
+		}
+		action = "update"
+	}
+	patch, err := jsonpatch.CreateMergePatch(before, after)
+	if err != nil || string(patch) == "{}" {
+		return
+	}
+	c.report.record(DryRunChange{
+		Action:      action,
+		Kind:        c.kind(result),
+		Namespace:   result.GetNamespace(),
+		Name:        result.GetName(),
+		Subresource: subResource,
+		Patch:       patch,
+	})
+}
+
+func (c *dryRunReconcileClient) kind(obj client.Object) string {
+	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
+	if err != nil {
+		return fmt.Sprintf("%T", obj)
+	}
+	return gvk.Kind
+}
+
+// dryRunComparable returns obj as JSON without the type and the metadata
+// the API server manages, which differ between a live object and the result
+// of a dry-run write even when nothing else changes.
+func dryRunComparable(obj client.Object) ([]byte, error) {
+	m, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	delete(m, "apiVersion")
+	delete(m, "kind")
+	for _, field := range []string{"resourceVersion", "managedFields", "generation", "uid", "creationTimestamp"} {
+		unstructured.RemoveNestedField(m, "metadata", field)
+	}
+	return json.Marshal(m)
+}
+
+// -----------------------------------------------------------------------------
+// Dry-Run Reconcile - Events
+// -----------------------------------------------------------------------------
+
+// DryRunEventsTransport wraps rt so that the Events the controllers record
+// are sent in dry-run mode. The event recorders do not use the manager
+// client, so they are not covered by NewDryRunReconcileClient.
+func DryRunEventsTransport(rt http.RoundTripper) http.RoundTripper {
+	return dryRunEventsRoundTripper{next: rt}
+}
+
+type dryRunEventsRoundTripper struct {
+	next http.RoundTripper
+}
+
+func (t dryRunEventsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == http.MethodGet || !isEventsPath(req.URL.Path) {
+		return t.next.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	q := req.URL.Query()
+	q.Set("dryRun", metav1.DryRunAll)
+	req.URL.RawQuery = q.Encode()
+	return t.next.RoundTrip(req)
+}
+
+// isEventsPath reports whether path is a core or events.k8s.io Event, or a
+// collection of them.
+func isEventsPath(path string) bool {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	n := len(parts)
+	return parts[n-1] == "events" || (n >= 2 && parts[n-2] == "events")
+}
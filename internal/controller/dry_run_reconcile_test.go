@@ -0,0 +1,133 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+func TestDryRunReconcileClient(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "rules", Namespace: "team-a"}, Data: map[string]string{"level": "1"}}
+	old := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "old", Namespace: "team-a"}}
+	ruleset := &wafv1alpha1.RuleSet{ObjectMeta: metav1.ObjectMeta{Name: "ruleset", Namespace: "team-a", Generation: 1}}
+	fc := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(cm, old, ruleset).
+		WithStatusSubresource(ruleset).
+		Build()
+	path := filepath.Join(t.TempDir(), "report.json")
+	report := NewDryRunReport(logr.Discard(), path)
+	c := NewDryRunReconcileClient(fc, report)
+	ctx := t.Context()
+
+	t.Log("Writing objects through the dry-run client")
+	var current corev1.ConfigMap
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(cm), &current))
+	require.NoError(t, c.Update(ctx, current.DeepCopy()), "an unchanged update is not a change")
+	current.Data["level"] = "2"
+	require.NoError(t, c.Update(ctx, &current))
+	require.NoError(t, c.Create(ctx, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "new", Namespace: "team-a"}}))
+	require.NoError(t, c.Delete(ctx, old.DeepCopy()))
+
+	var rs wafv1alpha1.RuleSet
+	require.NoError(t, c.Get(ctx, client.ObjectKeyFromObject(ruleset), &rs))
+	base := rs.DeepCopy()
+	applyStatusReady(&rs.Status.Conditions, rs.Generation, "RulesCached", "cached")
+	require.NoError(t, c.Status().Patch(ctx, &rs, client.MergeFrom(base)))
+
+	t.Log("Verifying nothing was applied")
+	var got corev1.ConfigMap
+	require.NoError(t, fc.Get(ctx, client.ObjectKeyFromObject(cm), &got))
+	assert.Equal(t, "1", got.Data["level"])
+	require.NoError(t, fc.Get(ctx, client.ObjectKeyFromObject(old), &got))
+	require.NoError(t, fc.Get(ctx, client.ObjectKeyFromObject(ruleset), &rs))
+	assert.Empty(t, rs.Status.Conditions)
+
+	t.Log("Verifying the report")
+	changes := report.Changes()
+	require.Len(t, changes, 4)
+	assert.Equal(t, "create", changes[0].Action)
+	assert.Equal(t, "new", changes[0].Name)
+	assert.Equal(t, "delete", changes[1].Action)
+	assert.Equal(t, "old", changes[1].Name)
+	assert.Equal(t, "update", changes[2].Action)
+	assert.Equal(t, "rules", changes[2].Name)
+	assert.JSONEq(t, `{"data":{"level":"2"}}`, string(changes[2].Patch))
+	assert.Equal(t, "update", changes[3].Action)
+	assert.Equal(t, "RuleSet", changes[3].Kind)
+	assert.Equal(t, "status", changes[3].Subresource)
+	assert.Contains(t, string(changes[3].Patch), "RulesCached")
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var written struct {
+		Changes []DryRunChange `json:"changes"`
+	}
+	require.NoError(t, json.Unmarshal(data, &written))
+	assert.Len(t, written.Changes, 4)
+}
+
+func TestDryRunEventsTransport(t *testing.T) {
+	var got []string
+	rt := DryRunEventsTransport(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		got = append(got, req.Method+" "+req.URL.String())
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}))
+
+	for _, r := range []struct{ method, url string }{
+		{http.MethodPost, "https://api/apis/events.k8s.io/v1/namespaces/team-a/events"},
+		{http.MethodPatch, "https://api/api/v1/namespaces/team-a/events/waf.1"},
+		{http.MethodGet, "https://api/api/v1/namespaces/team-a/events"},
+		{http.MethodPatch, "https://api/apis/waf.k8s.coraza.io/v1alpha1/namespaces/team-a/engines/waf/status"},
+	} {
+		req, err := http.NewRequest(r.method, r.url, nil)
+		require.NoError(t, err)
+		resp, err := rt.RoundTrip(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+	}
+
+	assert.Equal(t, []string{
+		"POST https://api/apis/events.k8s.io/v1/namespaces/team-a/events?dryRun=All",
+		"PATCH https://api/api/v1/namespaces/team-a/events/waf.1?dryRun=All",
+		"GET https://api/api/v1/namespaces/team-a/events",
+		"PATCH https://api/apis/waf.k8s.coraza.io/v1alpha1/namespaces/team-a/engines/waf/status",
+	}, got)
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
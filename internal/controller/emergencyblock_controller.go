@@ -0,0 +1,245 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// EmergencyBlockReconciler - RBAC
+// -----------------------------------------------------------------------------
+
+// +kubebuilder:rbac:groups=waf.k8s.coraza.io,resources=emergencyblocks,verbs=get;list;watch
+// +kubebuilder:rbac:groups=waf.k8s.coraza.io,resources=emergencyblocks/status,verbs=get;update;patch
+
+// -----------------------------------------------------------------------------
+// EmergencyBlockReconciler
+// -----------------------------------------------------------------------------
+
+// EmergencyBlockReconciler reports which RuleSets EmergencyBlocks are added
+// to, and when they expire. The blocks themselves are added to the rules
+// by the RuleSet controller, which does not depend on this status.
+type EmergencyBlockReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder events.EventRecorder
+}
+
+// SetupWithManager sets up the controller with the Manager. Engines are
+// watched so that the RuleSets in the status follow the Engines selected.
+func (r *EmergencyBlockReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&wafv1alpha1.EmergencyBlock{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&wafv1alpha1.Engine{},
+			handler.EnqueueRequestsFromMapFunc(r.findEmergencyBlocksForEngine),
+			builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.LabelChangedPredicate{})),
+		).
+		Named("emergencyblock").
+		Complete(r)
+}
+
+// findEmergencyBlocksForEngine maps an Engine to the EmergencyBlocks in its
+// namespace.
+func (r *EmergencyBlockReconciler) findEmergencyBlocksForEngine(ctx context.Context, engine client.Object) []reconcile.Request {
+	log := logf.FromContext(ctx)
+
+	var blocks wafv1alpha1.EmergencyBlockList
+	if err := r.List(ctx, &blocks, client.InNamespace(engine.GetNamespace())); err != nil {
+		log.Error(err, "EmergencyBlock: Failed to list EmergencyBlocks", "namespace", engine.GetNamespace())
+		return nil
+	}
+	return collectRequests(blocks.Items, func(*wafv1alpha1.EmergencyBlock) bool { return true })
+}
+
+// -----------------------------------------------------------------------------
+// EmergencyBlockReconciler - Reconcile
+// -----------------------------------------------------------------------------
+
+// Reconcile records the RuleSets the EmergencyBlock is added to until it
+// expires, and requeues at its expiry to record it.
+func (r *EmergencyBlockReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	var block wafv1alpha1.EmergencyBlock
+	if err := r.Get(ctx, req.NamespacedName, &block); err != nil {
+		if apierrors.IsNotFound(err) {
+			logDebug(log, req, "EmergencyBlock", "Resource not found, the RuleSets drop the block")
+			return ctrl.Result{}, nil
+		}
+		logAPIError(log, req, "EmergencyBlock", err, "Failed to GET", nil)
+		return ctrl.Result{}, err
+	}
+
+	if err := validateEmergencyBlock(&block); err != nil {
+		logInfo(log, req, "EmergencyBlock", "Invalid block", "error", err.Error())
+		return ctrl.Result{}, patchDegraded(ctx, r.Status(), r.Recorder, log, req, "EmergencyBlock", &block, &block.Status.Conditions, block.Generation, "InvalidBlock", err.Error())
+	}
+
+	expiresAt := emergencyBlockExpiry(&block)
+	remaining := time.Until(expiresAt)
+
+	var ruleSets []string
+	phase, reason := wafv1alpha1.EmergencyBlockPhaseExpired, "Expired"
+	msg := fmt.Sprintf("Block expired at %s", expiresAt.UTC().Format(time.RFC3339))
+	if remaining > 0 {
+		var engines wafv1alpha1.EngineList
+		if err := r.List(ctx, &engines, client.InNamespace(block.Namespace)); err != nil {
+			logAPIError(log, req, "EmergencyBlock", err, "Failed to list Engines", nil)
+			return ctrl.Result{}, err
+		}
+		ruleSets = emergencyBlockRuleSets(&block, engines.Items)
+		phase, reason = wafv1alpha1.EmergencyBlockPhaseActive, "Active"
+		msg = fmt.Sprintf("Blocking %s on RuleSets %s until %s", emergencyBlockTarget(&block), strings.Join(ruleSets, ", "), expiresAt.UTC().Format(time.RFC3339))
+		if len(ruleSets) == 0 {
+			reason = "NoEnginesSelected"
+			msg = fmt.Sprintf("engineSelector selects no Engine using a RuleSet; the block has no effect until %s", expiresAt.UTC().Format(time.RFC3339))
+		}
+	}
+
+	ready := apimeta.FindStatusCondition(block.Status.Conditions, conditionReady)
+	unchanged := block.Status.Phase == phase && slices.Equal(block.Status.RuleSets, ruleSets) &&
+		block.Status.ExpiresAt != nil && block.Status.ExpiresAt.Time.Equal(expiresAt) &&
+		ready != nil && ready.Reason == reason && ready.Message == msg && ready.ObservedGeneration == block.Generation
+	if !unchanged {
+		patch := client.MergeFrom(block.DeepCopy())
+		previous := block.Status.Phase
+		block.Status.Phase = phase
+		block.Status.RuleSets = ruleSets
+		block.Status.ExpiresAt = &metav1.Time{Time: expiresAt}
+		if phase == wafv1alpha1.EmergencyBlockPhaseActive {
+			applyStatusReady(&block.Status.Conditions, block.Generation, reason, msg)
+		} else {
+			setConditionFalse(&block.Status.Conditions, block.Generation, conditionReady, reason, msg)
+			apimeta.RemoveStatusCondition(&block.Status.Conditions, conditionDegraded)
+		}
+		if err := r.Status().Patch(ctx, &block, patch); err != nil {
+			logAPIError(log, req, "EmergencyBlock", err, "Failed to patch status", &block)
+			return ctrl.Result{}, err
+		}
+
+		if previous != phase {
+			logInfo(log, req, "EmergencyBlock", "Block "+strings.ToLower(string(phase)), "expiresAt", expiresAt)
+			if phase == wafv1alpha1.EmergencyBlockPhaseActive {
+				r.Recorder.Eventf(&block, nil, "Warning", "BlockActivated", "Reconcile", truncateEventNote(msg))
+			} else {
+				r.Recorder.Eventf(&block, nil, "Normal", "BlockExpired", "Reconcile", msg)
+			}
+		}
+	}
+
+	if remaining > 0 {
+		return ctrl.Result{RequeueAfter: remaining}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+// -----------------------------------------------------------------------------
+// EmergencyBlocks
+// -----------------------------------------------------------------------------
+
+// emergencyBlockExpiry returns when the TTL of block elapses.
+func emergencyBlockExpiry(block *wafv1alpha1.EmergencyBlock) time.Time {
+	return block.CreationTimestamp.Add(time.Duration(block.Spec.TTLSeconds) * time.Second)
+}
+
+// validateEmergencyBlock checks what the CRD schema cannot: that the client
+// addresses parse, and that the pattern compiles. An invalid block would
+// otherwise fail the rules of every RuleSet it is added to.
+func validateEmergencyBlock(block *wafv1alpha1.EmergencyBlock) error {
+	for _, ip := range block.Spec.ClientIPs {
+		if _, err := netip.ParsePrefix(ip); err == nil {
+			continue
+		}
+		if _, err := netip.ParseAddr(ip); err != nil {
+			return fmt.Errorf("clientIPs: %q is not an IP address or CIDR range", ip)
+		}
+	}
+	if block.Spec.Pattern != "" {
+		if _, err := regexp.Compile(block.Spec.Pattern); err != nil {
+			return fmt.Errorf("pattern: %w", err)
+		}
+	}
+	if _, err := metav1.LabelSelectorAsSelector(block.Spec.EngineSelector); err != nil {
+		return fmt.Errorf("engineSelector: %w", err)
+	}
+	return nil
+}
+
+// emergencyBlockSelects reports whether block selects engine.
+func emergencyBlockSelects(block *wafv1alpha1.EmergencyBlock, engine *wafv1alpha1.Engine) bool {
+	if block.Spec.EngineSelector == nil || block.Namespace != engine.Namespace {
+		return false
+	}
+	selector, err := metav1.LabelSelectorAsSelector(block.Spec.EngineSelector)
+	if err != nil {
+		return false
+	}
+	return selector.Matches(labels.Set(engine.Labels))
+}
+
+// emergencyBlockRuleSets returns the sorted names of the RuleSets of the
+// engines block selects.
+func emergencyBlockRuleSets(block *wafv1alpha1.EmergencyBlock, engines []wafv1alpha1.Engine) []string {
+	var ruleSets []string
+	for i := range engines {
+		if engines[i].Spec.RuleSet.Name == "" || !emergencyBlockSelects(block, &engines[i]) {
+			continue
+		}
+		for _, name := range engineRuleSets(&engines[i]) {
+			if !slices.Contains(ruleSets, name) {
+				ruleSets = append(ruleSets, name)
+			}
+		}
+	}
+	slices.Sort(ruleSets)
+	return ruleSets
+}
+
+// emergencyBlockTarget describes the requests block blocks.
+func emergencyBlockTarget(block *wafv1alpha1.EmergencyBlock) string {
+	switch {
+	case len(block.Spec.ClientIPs) > 0:
+		return "client addresses " + strings.Join(block.Spec.ClientIPs, ", ")
+	case block.Spec.Path != "":
+		return "paths starting with " + block.Spec.Path
+	default:
+		return "URIs matching " + block.Spec.Pattern
+	}
+}
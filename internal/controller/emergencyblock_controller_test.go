@@ -0,0 +1,252 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/corazawaf/coraza/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/test/utils"
+)
+
+func TestEmergencyBlockRules(t *testing.T) {
+	blocks := []wafv1alpha1.EmergencyBlock{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "botnet"},
+			Spec:       wafv1alpha1.EmergencyBlockSpec{ClientIPs: []string{"203.0.113.7", "198.51.100.0/24"}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "export"},
+			Spec:       wafv1alpha1.EmergencyBlockSpec{Path: "/api/export"},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "injection"},
+			Spec:       wafv1alpha1.EmergencyBlockSpec{Pattern: `^/search\?.*union.*select`},
+		},
+	}
+	rules := emergencyBlockRules(blocks)
+	assert.Contains(t, rules, "id:89400000,")
+	assert.Contains(t, rules, "id:89400002,")
+	assert.Empty(t, emergencyBlockRules(nil))
+
+	waf, err := coraza.NewWAF(coraza.NewWAFConfig().WithDirectives("SecRuleEngine On\n" + rules))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		addr    string
+		uri     string
+		blocked bool
+	}{
+		{name: "blocked address", addr: "203.0.113.7", uri: "/", blocked: true},
+		{name: "blocked range", addr: "198.51.100.42", uri: "/", blocked: true},
+		{name: "other address", addr: "192.0.2.1", uri: "/", blocked: false},
+		{name: "blocked path", addr: "192.0.2.1", uri: "/api/export/all?format=csv", blocked: true},
+		{name: "other path", addr: "192.0.2.1", uri: "/api/import", blocked: false},
+		{name: "matching pattern", addr: "192.0.2.1", uri: "/search?q=1+union+select+1", blocked: true},
+		{name: "other query", addr: "192.0.2.1", uri: "/search?q=unions", blocked: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := waf.NewTransaction()
+			defer func() { _ = tx.Close() }()
+			tx.ProcessConnection(tt.addr, 40000, "10.0.0.1", 8080)
+			tx.ProcessURI(tt.uri, "GET", "HTTP/1.1")
+			interruption := tx.ProcessRequestHeaders()
+			assert.Equal(t, tt.blocked, interruption != nil)
+		})
+	}
+}
+
+func TestValidateEmergencyBlock(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    wafv1alpha1.EmergencyBlockSpec
+		wantErr string
+	}{
+		{name: "addresses", spec: wafv1alpha1.EmergencyBlockSpec{ClientIPs: []string{"203.0.113.7", "2001:db8::/32"}}},
+		{name: "invalid address", spec: wafv1alpha1.EmergencyBlockSpec{ClientIPs: []string{"203.0.113.300"}}, wantErr: "clientIPs"},
+		{name: "pattern", spec: wafv1alpha1.EmergencyBlockSpec{Pattern: `^/admin`}},
+		{name: "invalid pattern", spec: wafv1alpha1.EmergencyBlockSpec{Pattern: `^/(admin`}, wantErr: "pattern"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.spec.EngineSelector = &metav1.LabelSelector{}
+			err := validateEmergencyBlock(&wafv1alpha1.EmergencyBlock{Spec: tt.spec})
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestEmergencyBlockReconciler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+
+	now := time.Now()
+	newBlock := func(name string, created time.Time, spec wafv1alpha1.EmergencyBlockSpec) *wafv1alpha1.EmergencyBlock {
+		spec.TTLSeconds = 3600
+		return &wafv1alpha1.EmergencyBlock{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "team-a", Generation: 1, CreationTimestamp: metav1.NewTime(created)},
+			Spec:       spec,
+		}
+	}
+	public := &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "public"}}
+	active := newBlock("active", now, wafv1alpha1.EmergencyBlockSpec{EngineSelector: public, ClientIPs: []string{"203.0.113.7"}})
+	unselected := newBlock("unselected", now, wafv1alpha1.EmergencyBlockSpec{
+		EngineSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "internal"}},
+		Path:           "/admin",
+	})
+	expired := newBlock("expired", now.Add(-2*time.Hour), wafv1alpha1.EmergencyBlockSpec{EngineSelector: public, Path: "/admin"})
+	invalid := newBlock("invalid", now, wafv1alpha1.EmergencyBlockSpec{EngineSelector: public, Pattern: `^/(admin`})
+	engines := []*wafv1alpha1.Engine{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "gateway-a", Namespace: "team-a", Labels: map[string]string{"tier": "public"}},
+			Spec:       wafv1alpha1.EngineSpec{RuleSet: wafv1alpha1.RuleSetReference{Name: "ruleset-a"}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "gateway-b", Namespace: "team-a", Labels: map[string]string{"tier": "public"}},
+			Spec:       wafv1alpha1.EngineSpec{RuleSet: wafv1alpha1.RuleSetReference{Name: "ruleset-a"}},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "gateway-c", Namespace: "team-b", Labels: map[string]string{"tier": "public"}},
+			Spec:       wafv1alpha1.EngineSpec{RuleSet: wafv1alpha1.RuleSetReference{Name: "ruleset-b"}},
+		},
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(active, unselected, expired, invalid, engines[0], engines[1], engines[2]).
+		WithStatusSubresource(active, unselected, expired, invalid).
+		Build()
+	recorder := utils.NewFakeRecorder()
+	r := &EmergencyBlockReconciler{Client: c, Scheme: scheme, Recorder: recorder}
+
+	reconcile := func(t *testing.T, block *wafv1alpha1.EmergencyBlock) ctrl.Result {
+		t.Helper()
+		req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: block.Namespace, Name: block.Name}}
+		result, err := r.Reconcile(t.Context(), req)
+		require.NoError(t, err)
+		require.NoError(t, c.Get(t.Context(), req.NamespacedName, block))
+		return result
+	}
+
+	t.Run("active", func(t *testing.T) {
+		result := reconcile(t, active)
+		assert.Equal(t, wafv1alpha1.EmergencyBlockPhaseActive, active.Status.Phase)
+		assert.Equal(t, []string{"ruleset-a"}, active.Status.RuleSets)
+		require.NotNil(t, active.Status.ExpiresAt)
+		assert.Greater(t, result.RequeueAfter, 59*time.Minute)
+		ready := apimeta.FindStatusCondition(active.Status.Conditions, conditionReady)
+		require.NotNil(t, ready)
+		assert.Equal(t, "Active", ready.Reason)
+		require.Len(t, recorder.Events, 1)
+		assert.Equal(t, "BlockActivated", recorder.Events[0].Reason)
+
+		events := len(recorder.Events)
+		reconcile(t, active)
+		assert.Len(t, recorder.Events, events, "an unchanged block is not reported again")
+	})
+
+	t.Run("no Engines selected", func(t *testing.T) {
+		reconcile(t, unselected)
+		assert.Equal(t, wafv1alpha1.EmergencyBlockPhaseActive, unselected.Status.Phase)
+		assert.Empty(t, unselected.Status.RuleSets)
+		ready := apimeta.FindStatusCondition(unselected.Status.Conditions, conditionReady)
+		require.NotNil(t, ready)
+		assert.Equal(t, "NoEnginesSelected", ready.Reason)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		result := reconcile(t, expired)
+		assert.Zero(t, result.RequeueAfter)
+		assert.Equal(t, wafv1alpha1.EmergencyBlockPhaseExpired, expired.Status.Phase)
+		assert.Empty(t, expired.Status.RuleSets)
+		ready := apimeta.FindStatusCondition(expired.Status.Conditions, conditionReady)
+		require.NotNil(t, ready)
+		assert.Equal(t, metav1.ConditionFalse, ready.Status)
+		assert.Equal(t, "Expired", ready.Reason)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		reconcile(t, invalid)
+		degraded := apimeta.FindStatusCondition(invalid.Status.Conditions, conditionDegraded)
+		require.NotNil(t, degraded)
+		assert.Equal(t, "InvalidBlock", degraded.Reason)
+	})
+}
+
+func TestRuleSetReconciler_LoadEmergencyBlocks(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+
+	now := time.Now()
+	all := &metav1.LabelSelector{}
+	newBlock := func(name string, created time.Time, spec wafv1alpha1.EmergencyBlockSpec) *wafv1alpha1.EmergencyBlock {
+		spec.TTLSeconds = 3600
+		return &wafv1alpha1.EmergencyBlock{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "team-a", CreationTimestamp: metav1.NewTime(created)},
+			Spec:       spec,
+		}
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(
+			newBlock("b-path", now, wafv1alpha1.EmergencyBlockSpec{EngineSelector: all, Path: "/admin"}),
+			newBlock("a-ips", now.Add(-30*time.Minute), wafv1alpha1.EmergencyBlockSpec{EngineSelector: all, ClientIPs: []string{"203.0.113.7"}}),
+			newBlock("expired", now.Add(-2*time.Hour), wafv1alpha1.EmergencyBlockSpec{EngineSelector: all, Path: "/old"}),
+			newBlock("invalid", now, wafv1alpha1.EmergencyBlockSpec{EngineSelector: all, Pattern: `^/(admin`}),
+			newBlock("other", now, wafv1alpha1.EmergencyBlockSpec{
+				EngineSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "internal"}},
+				Path:           "/internal",
+			}),
+			&wafv1alpha1.Engine{
+				ObjectMeta: metav1.ObjectMeta{Name: "gateway", Namespace: "team-a", Labels: map[string]string{"tier": "public"}},
+				Spec:       wafv1alpha1.EngineSpec{RuleSet: wafv1alpha1.RuleSetReference{Name: "ruleset"}},
+			},
+		).
+		Build()
+	r := &RuleSetReconciler{Client: c, Scheme: scheme}
+	ruleset := &wafv1alpha1.RuleSet{ObjectMeta: metav1.ObjectMeta{Name: "ruleset", Namespace: "team-a"}}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "team-a", Name: "ruleset"}}
+
+	blocks, nextExpiry, err := r.loadEmergencyBlocks(t.Context(), ctrl.Log, req, ruleset)
+	require.NoError(t, err)
+	require.Len(t, blocks, 2)
+	assert.Equal(t, "a-ips", blocks[0].Name)
+	assert.Equal(t, "b-path", blocks[1].Name)
+	assert.InDelta(t, 30*time.Minute, nextExpiry, float64(time.Minute))
+
+	other := &wafv1alpha1.RuleSet{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "team-a"}}
+	blocks, nextExpiry, err = r.loadEmergencyBlocks(t.Context(), ctrl.Log, req, other)
+	require.NoError(t, err)
+	assert.Empty(t, blocks, "no Engine using the RuleSet is selected")
+	assert.Zero(t, nextExpiry)
+}
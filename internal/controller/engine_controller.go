@@ -43,6 +43,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
 )
@@ -57,6 +58,7 @@ import (
 // +kubebuilder:rbac:groups=waf.k8s.coraza.io,resources=rulesets,verbs=get;list;watch
 // +kubebuilder:rbac:groups=waf.k8s.coraza.io,resources=rulesets/status,verbs=get
 // +kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=gateways,verbs=get;list;watch
+// +kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=gatewayclasses,verbs=get
 
 // -----------------------------------------------------------------------------
 // EngineReconciler
@@ -75,6 +77,26 @@ type EngineReconciler struct {
 	// Engine omits spec.driver.wasm.image.
 	defaultWasmImage  string
 	operatorNamespace string
+	// imageMirrors rewrite WASM plugin image references, unless the
+	// OperatorConfig overrides them.
+	imageMirrors []wafv1alpha1.ImageMirror
+	// imageResolver looks up mirrored images in their registry. Nil disables
+	// the lookup.
+	imageResolver imageResolver
+	// platformResolver looks up the platforms of WASM plugin images, which
+	// must include the architectures of the gateway nodes read with
+	// apiReader. Nil disables the check.
+	platformResolver platformResolver
+	apiReader        client.Reader
+
+	// capabilities are the optional APIs detected at startup. Features that
+	// depend on a missing API are disabled. Nil assumes every API is
+	// installed.
+	capabilities Capabilities
+
+	// runtimeConfig carries OperatorConfig overrides, such as the default
+	// WASM image, that take precedence over the fields above.
+	runtimeConfig *RuntimeConfig
 
 	// tokenStore is a thread-safe store for cache client tokens, keyed by
 	// "namespace/engineName/rulesetName". Uses sync.Map for simple concurrent access.
@@ -82,10 +104,22 @@ type EngineReconciler struct {
 	// Including the rulesetName in the key ensures that changing an Engine's
 	// spec.ruleSet.name invalidates the cached token (which encodes the audience).
 	tokenStore sync.Map
+
+	// targets caches target Gateway existence between reconciles. It is set
+	// up together with the Gateway watch that invalidates it.
+	targets *targetCache
 }
 
 const engineTargetIndex = "spec.target"
 
+// ruleSetFanOutPerEngine and ruleSetFanOutMaxSpread bound the jitter applied
+// when a RuleSet change requeues every Engine that references it: 1000
+// Engines are spread over 10s, and no fan-out is spread over more than 30s.
+const (
+	ruleSetFanOutPerEngine = 10 * time.Millisecond
+	ruleSetFanOutMaxSpread = 30 * time.Second
+)
+
 // engineTargetKey returns the composite index key for an Engine's target.
 func engineTargetKey(targetType wafv1alpha1.EngineTargetType, name string) string {
 	return string(targetType) + "/" + name
@@ -95,6 +129,9 @@ func engineTargetKey(targetType wafv1alpha1.EngineTargetType, name string) strin
 func (r *EngineReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &wafv1alpha1.Engine{}, engineTargetIndex, func(obj client.Object) []string {
 		engine := obj.(*wafv1alpha1.Engine)
+		if hasTargetSelector(engine) {
+			return []string{engineTargetKey(engine.Spec.Target.Type, "")}
+		}
 		if engine.Spec.Target.Name == "" {
 			return nil
 		}
@@ -103,25 +140,21 @@ func (r *EngineReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		return fmt.Errorf("index %s: %w", engineTargetIndex, err)
 	}
 
+	r.targets = newTargetCache()
+
 	wasmPlugin := &unstructured.Unstructured{}
-	wasmPlugin.SetGroupVersionKind(schema.GroupVersionKind{
-		Group:   "extensions.istio.io",
-		Version: "v1alpha1",
-		Kind:    "WasmPlugin",
-	})
+	wasmPlugin.SetGroupVersionKind(WasmPluginGVK)
 
 	gateway := &unstructured.Unstructured{}
 	gateway.SetGroupVersionKind(schema.GroupVersionKind{
-		Group:   "gateway.networking.k8s.io",
+		Group:   gatewayGroup,
 		Version: "v1",
 		Kind:    "Gateway",
 	})
 
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&wafv1alpha1.Engine{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
-		Owns(wasmPlugin).
-		Watches(gateway, handler.EnqueueRequestsFromMapFunc(r.findEnginesForGateway)).
-		Watches(&wafv1alpha1.RuleSet{}, handler.EnqueueRequestsFromMapFunc(r.findEnginesForRuleSet)).
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&wafv1alpha1.Engine{}, builder.WithPredicates(predicate.Or[client.Object](predicate.GenerationChangedPredicate{}, dataPlaneChangedPredicate()))).
+		Watches(&wafv1alpha1.RuleSet{}, jitteredEnqueueRequestsFromMapFunc(r.findEnginesForRuleSet, ruleSetFanOutPerEngine, ruleSetFanOutMaxSpread)).
 		Watches(&wafv1alpha1.Engine{}, r.competingEngineHandler(), builder.WithPredicates(
 			predicate.Funcs{
 				CreateFunc: func(event.CreateEvent) bool { return true },
@@ -136,10 +169,7 @@ func (r *EngineReconciler) SetupWithManager(mgr ctrl.Manager) error {
 			},
 		)).
 		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(r.findEnginesForPod), builder.WithPredicates(
-			predicate.NewPredicateFuncs(func(object client.Object) bool {
-				_, hasGWAPI := object.GetLabels()[gatewayNameLabel]
-				return hasGWAPI
-			}),
+			gatewayPodPredicate(),
 		)).
 		Watches(&networkingv1.NetworkPolicy{}, handler.EnqueueRequestsFromMapFunc(r.findEnginesForNetworkPolicy), builder.WithPredicates(
 			networkPolicyPredicate(),
@@ -150,8 +180,38 @@ func (r *EngineReconciler) SetupWithManager(mgr ctrl.Manager) error {
 				1*time.Minute,
 			),
 		}).
-		Named("engine").
-		Complete(r)
+		Named("engine")
+
+	// Watching a kind that is not installed fails the controller start, so
+	// optional kinds are only watched when detected.
+	if r.hasCapability(CapabilityWasmPlugin) {
+		b = b.Owns(wasmPlugin)
+	}
+	if r.hasCapability(CapabilityIstioTelemetry) {
+		telemetry := &unstructured.Unstructured{}
+		telemetry.SetGroupVersionKind(TelemetryGVK)
+		b = b.Owns(telemetry)
+	}
+	if r.hasCapability(CapabilityGatewayV1) {
+		b = b.Watches(gateway, handler.EnqueueRequestsFromMapFunc(r.findEnginesForGateway))
+	}
+	// Engines with a target selector report the status of the Engines they
+	// create for the selected Gateways.
+	b = b.Owns(&wafv1alpha1.Engine{})
+
+	// Engines relying on the default WASM image are rolled when the
+	// OperatorConfig changes it, and every Engine when it changes the image
+	// mirrors or the namespace quota.
+	if r.runtimeConfig != nil {
+		b = b.WatchesRawSource(source.Channel(r.runtimeConfig.engineEvents,
+			jitteredEnqueueRequestsFromMapFunc(r.findEnginesUsingDefaultImage, ruleSetFanOutPerEngine, ruleSetFanOutMaxSpread)))
+		b = b.WatchesRawSource(source.Channel(r.runtimeConfig.mirrorEvents,
+			jitteredEnqueueRequestsFromMapFunc(r.findAllEngines, ruleSetFanOutPerEngine, ruleSetFanOutMaxSpread)))
+		b = b.WatchesRawSource(source.Channel(r.runtimeConfig.quotaEvents,
+			jitteredEnqueueRequestsFromMapFunc(r.findAllEngines, ruleSetFanOutPerEngine, ruleSetFanOutMaxSpread)))
+	}
+
+	return b.Complete(r)
 }
 
 // -----------------------------------------------------------------------------
@@ -167,6 +227,8 @@ func (r *EngineReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	if err := r.Get(ctx, req.NamespacedName, &engine); err != nil {
 		if apierrors.IsNotFound(err) {
 			logDebug(log, req, "Engine", "Resource not found")
+			failingClosedEngines.DeleteLabelValues(req.Namespace, req.Name)
+			engineRevisionSkew.DeleteLabelValues(req.Namespace, req.Name)
 			// Best-effort cleanup: remove any orphaned NetworkPolicy that may
 			// remain if the Engine was deleted before the finalizer was added
 			// (e.g., race during upgrade or legacy Engine without finalizer).
@@ -198,6 +260,12 @@ func (r *EngineReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		return ctrl.Result{RequeueAfter: 100 * time.Millisecond}, nil
 	}
 
+	// Record the creation time in an annotation, which survives backup and
+	// restore, so that target conflicts keep the same winner afterwards.
+	if err := r.ensureCreatedAtAnnotation(ctx, log, req, &engine); err != nil {
+		return ctrl.Result{}, err
+	}
+
 	logDebug(log, req, "Engine", "Applying conditions")
 	if engine.Status == nil {
 		engine.Status = &wafv1alpha1.EngineStatus{}
@@ -213,17 +281,55 @@ func (r *EngineReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		logConditionTransitions(log, req, "Engine", before, engine.Status.Conditions)
 	}
 
+	if (hasGatewayTarget(&engine) || hasTargetSelector(&engine)) && !r.hasCapability(CapabilityGatewayV1) {
+		msg := "The Gateway API (gateway.networking.k8s.io/v1 Gateway) is not installed in the cluster"
+		if err := r.rejectTarget(ctx, log, req, &engine, "GatewayAPINotInstalled", msg); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if hasTargetSelector(&engine) {
+		logDebug(log, req, "Engine", "Fanning out to the selected Gateways")
+		return r.reconcileTargetSelector(ctx, log, req, &engine)
+	}
+
 	logDebug(log, req, "Engine", "Checking target availability")
 	if notFound, err := r.isTargetNotFound(ctx, log, req, &engine); err != nil {
 		return ctrl.Result{}, err
 	} else if notFound {
 		msg := fmt.Sprintf("Gateway %q not found in namespace %q", engine.Spec.Target.Name, engine.Namespace)
+		if hasIngressGatewayTarget(&engine) {
+			msg = fmt.Sprintf("No pod labeled %s=%s found in namespace %q", ingressGatewayLabel, engine.Spec.Target.Name, engine.Namespace)
+		}
+		if err := r.rejectTarget(ctx, log, req, &engine, "TargetNotFound", msg); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+	if _, found, err := r.targetListenerPort(ctx, log, req, &engine); err != nil {
+		return ctrl.Result{}, err
+	} else if !found {
+		msg := fmt.Sprintf("Listener %q not found on Gateway %q", engine.Spec.Target.SectionName, engine.Spec.Target.Name)
 		if err := r.rejectTarget(ctx, log, req, &engine, "TargetNotFound", msg); err != nil {
 			return ctrl.Result{}, err
 		}
 		return ctrl.Result{}, nil
 	}
 
+	logDebug(log, req, "Engine", "Checking target GatewayClass")
+	if reason, msg, err := r.unsupportedGatewayClass(ctx, log, req, &engine); err != nil {
+		return ctrl.Result{}, err
+	} else if reason != "" {
+		if err := r.rejectTarget(ctx, log, req, &engine, reason, msg); err != nil {
+			return ctrl.Result{}, err
+		}
+		if reason == reasonGatewayClassNotVerified {
+			return ctrl.Result{RequeueAfter: gatewayClassRecheckInterval}, nil
+		}
+		return ctrl.Result{}, nil
+	}
+
 	logDebug(log, req, "Engine", "Checking target conflict")
 	if conflict, winnerName, err := r.hasTargetConflict(ctx, log, req, &engine); err != nil {
 		return ctrl.Result{}, err
@@ -235,28 +341,81 @@ func (r *EngineReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		return ctrl.Result{}, nil
 	}
 
+	logDebug(log, req, "Engine", "Checking namespace quota")
+	if over, msg, err := r.isOverEngineQuota(ctx, log, req, &engine); err != nil {
+		return ctrl.Result{}, err
+	} else if over {
+		if err := r.rejectTarget(ctx, log, req, &engine, "QuotaExceeded", msg); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: quotaRecheckInterval}, nil
+	}
+
 	// Target is valid and uncontested — ensure Accepted=True. This clears any
 	// stale Accepted=False from a prior TargetNotFound or TargetConflict state.
-	if needsAcceptedUpdate(engine.Status.Conditions, engine.Generation) {
-		patch := client.MergeFrom(engine.DeepCopy())
-		before := snapshotConditions(engine.Status.Conditions)
-		setConditionTrue(&engine.Status.Conditions, engine.Generation, conditionAccepted, "Accepted", "Target is available and not conflicting")
-		if err := r.Status().Patch(ctx, &engine, patch); err != nil {
-			logAPIError(log, req, "Engine", err, "Failed to patch Accepted status", &engine)
+	// Engines accepted before ancestor statuses were reported get them too.
+	if needsAcceptedUpdate(engine.Status.Conditions, engine.Generation) || len(engine.Status.Ancestors) == 0 {
+		if err := r.patchAccepted(ctx, log, req, &engine, func() {
+			setConditionTrue(&engine.Status.Conditions, engine.Generation, conditionAccepted, "Accepted", "Target is available and not conflicting")
+		}); err != nil {
 			return ctrl.Result{}, err
 		}
-		logConditionTransitions(log, req, "Engine", before, engine.Status.Conditions)
+	}
+
+	result, err := r.provision(ctx, log, req, &engine)
+
+	// Whatever the outcome of provisioning, report and escalate an Engine
+	// that fails closed.
+	logDebug(log, req, "Engine", "Reconciling failure policy")
+	downgradeRemaining, fpErr := r.reconcileFailurePolicy(ctx, log, req, &engine)
+	if err == nil {
+		err = fpErr
+	}
+	// Requeue at the end of the downgrade, unless reconciliation requeues
+	// earlier.
+	if downgradeRemaining > 0 && (result.RequeueAfter == 0 || downgradeRemaining < result.RequeueAfter) {
+		result.RequeueAfter = downgradeRemaining
+	}
+	return result, err
+}
+
+// provision checks the RuleSet of an accepted Engine and provisions it with
+// its driver.
+func (r *EngineReconciler) provision(ctx context.Context, log logr.Logger, req ctrl.Request, engine *wafv1alpha1.Engine) (ctrl.Result, error) {
+	logDebug(log, req, "Engine", "Selecting active RuleSet")
+	if err := r.reconcileActiveRuleSet(ctx, log, req, engine); err != nil {
+		return ctrl.Result{}, err
 	}
 
 	logDebug(log, req, "Engine", "Checking referenced RuleSet status")
-	if degraded, err := r.isRuleSetDegraded(ctx, log, req, &engine); err != nil {
+	if degraded, err := r.isRuleSetDegraded(ctx, log, req, engine); err != nil {
 		return ctrl.Result{}, err
 	} else if degraded {
 		return ctrl.Result{}, nil
 	}
 
+	logDebug(log, req, "Engine", "Reconciling learning mode")
+	learningRemaining, err := r.reconcileLearning(ctx, log, req, engine)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	logDebug(log, req, "Engine", "Reconciling schedule")
+	scheduleRemaining, err := r.reconcileSchedule(ctx, log, req, engine)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
 	logInfo(log, req, "Engine", "Selecting driver and provisioning")
-	return r.selectDriver(ctx, log, req, engine)
+	result, err := r.selectDriver(ctx, log, req, engine)
+	// Requeue at the end of the learning period, or when a window of the
+	// schedule starts or ends, unless provisioning requeues earlier.
+	for _, remaining := range []time.Duration{learningRemaining, scheduleRemaining} {
+		if remaining > 0 && (result.RequeueAfter == 0 || remaining < result.RequeueAfter) {
+			result.RequeueAfter = remaining
+		}
+	}
+	return result, err
 }
 
 // -----------------------------------------------------------------------------
@@ -283,7 +442,7 @@ func (r *EngineReconciler) handleInvalidDriverConfiguration(ctx context.Context,
 // EngineReconciler - Driver Provisioning
 // -----------------------------------------------------------------------------
 
-func (r *EngineReconciler) selectDriver(ctx context.Context, log logr.Logger, req ctrl.Request, engine wafv1alpha1.Engine) (ctrl.Result, error) {
+func (r *EngineReconciler) selectDriver(ctx context.Context, log logr.Logger, req ctrl.Request, engine *wafv1alpha1.Engine) (ctrl.Result, error) {
 	driverType := engine.Spec.Driver.Type
 	if driverType == "" {
 		driverType = defaultDriverTypeForProvider(engine.Spec.Target.Provider)
@@ -291,13 +450,23 @@ func (r *EngineReconciler) selectDriver(ctx context.Context, log logr.Logger, re
 
 	switch driverType {
 	case wafv1alpha1.DriverTypeWasm:
+		if !r.hasCapability(CapabilityWasmPlugin) {
+			msg := "Istio is not installed in the cluster: the WasmPlugin API (extensions.istio.io/v1alpha1) is not served"
+			return ctrl.Result{}, patchDegraded(ctx, r.Status(), r.Recorder, log, req, "Engine", engine, &engine.Status.Conditions, engine.Generation, "IstioNotInstalled", msg)
+		}
 		logDebug(log, req, "Engine", "Using WASM driver")
 		return r.provisionWasmDriver(ctx, log, req, engine)
 	default:
-		return ctrl.Result{}, r.handleInvalidDriverConfiguration(ctx, log, req, &engine)
+		return ctrl.Result{}, r.handleInvalidDriverConfiguration(ctx, log, req, engine)
 	}
 }
 
+// hasCapability reports whether the optional API name was detected, or true
+// when capabilities were not detected.
+func (r *EngineReconciler) hasCapability(name Capability) bool {
+	return r.capabilities == nil || r.capabilities.Has(name)
+}
+
 func defaultDriverTypeForProvider(provider wafv1alpha1.EngineTargetProvider) wafv1alpha1.DriverType {
 	switch provider {
 	case wafv1alpha1.EngineTargetProviderIstio, "":
@@ -311,22 +480,23 @@ func defaultDriverTypeForProvider(provider wafv1alpha1.EngineTargetProvider) waf
 // EngineReconciler - RuleSet Status Check
 // -----------------------------------------------------------------------------
 
-// isRuleSetDegraded fetches the Engine's referenced RuleSet and returns true if
+// isRuleSetDegraded fetches the RuleSet the Engine loads and returns true if
 // it is currently Degraded. When degraded, it marks the Engine Degraded and
 // returns (true, nil). A retriable system error returns (false, err).
 func (r *EngineReconciler) isRuleSetDegraded(ctx context.Context, log logr.Logger, req ctrl.Request, engine *wafv1alpha1.Engine) (bool, error) {
+	name := activeRuleSetName(engine)
 	var ruleSet wafv1alpha1.RuleSet
-	if err := r.Get(ctx, types.NamespacedName{Name: engine.Spec.RuleSet.Name, Namespace: engine.Namespace}, &ruleSet); err != nil {
+	if err := r.Get(ctx, types.NamespacedName{Name: name, Namespace: engine.Namespace}, &ruleSet); err != nil {
 		if apierrors.IsNotFound(err) {
-			msg := fmt.Sprintf("RuleSet %s not found", engine.Spec.RuleSet.Name)
-			logInfo(log, req, "Engine", "RuleSet not found; marking Engine degraded", "ruleSet", engine.Spec.RuleSet.Name)
-			if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "Engine", engine, &engine.Status.Conditions, engine.Generation, "RuleSetNotFound", msg); patchErr != nil {
+			msg := fmt.Sprintf("RuleSet %s not found", name)
+			logInfo(log, req, "Engine", "RuleSet not found; marking Engine degraded", "ruleSet", name)
+			if patchErr := patchDegradedOnChange(ctx, r.Status(), r.Recorder, log, req, "Engine", engine, &engine.Status.Conditions, engine.Generation, "RuleSetNotFound", msg); patchErr != nil {
 				return true, patchErr
 			}
 			return true, nil
 		}
 		logAPIError(log, req, "Engine", err, "Failed to get RuleSet", nil)
-		return false, fmt.Errorf("failed to get RuleSet %s: %w", engine.Spec.RuleSet.Name, err)
+		return false, fmt.Errorf("failed to get RuleSet %s: %w", name, err)
 	}
 
 	degradedCond := apimeta.FindStatusCondition(ruleSet.Status.Conditions, conditionDegraded)
@@ -334,9 +504,13 @@ func (r *EngineReconciler) isRuleSetDegraded(ctx context.Context, log logr.Logge
 		return false, nil
 	}
 
-	msg := fmt.Sprintf("RuleSet %s is degraded: %s", engine.Spec.RuleSet.Name, degradedCond.Message)
-	logInfo(log, req, "Engine", "RuleSet is degraded; marking Engine degraded", "ruleSet", engine.Spec.RuleSet.Name)
-	if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "Engine", engine, &engine.Status.Conditions, engine.Generation, "RuleSetDegraded", msg); patchErr != nil {
+	// The root cause lives on the RuleSet's Degraded condition. Engines only
+	// reference it by reason, so that a shared RuleSet going invalid does not
+	// copy its detail into every dependent, and a change in that detail does
+	// not rewrite their status or record another event on each of them.
+	msg := fmt.Sprintf("RuleSet %s is degraded (%s); see its Degraded condition for the cause", name, degradedCond.Reason)
+	logInfo(log, req, "Engine", "RuleSet is degraded; marking Engine degraded", "ruleSet", name, "reason", degradedCond.Reason)
+	if patchErr := patchDegradedOnChange(ctx, r.Status(), r.Recorder, log, req, "Engine", engine, &engine.Status.Conditions, engine.Generation, "RuleSetDegraded", msg); patchErr != nil {
 		return true, patchErr
 	}
 
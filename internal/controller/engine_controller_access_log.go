@@ -0,0 +1,257 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// Engine Controller - Access Log RBAC
+// -----------------------------------------------------------------------------
+
+// +kubebuilder:rbac:groups=telemetry.istio.io,resources=telemetries,verbs=get;list;watch;create;update;patch;delete
+
+// -----------------------------------------------------------------------------
+// Engine Controller - Access Log Consts
+// -----------------------------------------------------------------------------
+
+const (
+	// TelemetryNamePrefix is the prefix of the names of the Telemetry
+	// resources generated for Engines.
+	TelemetryNamePrefix = "coraza-engine-"
+
+	// defaultAccessLogProvider is the built-in Istio access log provider,
+	// which logs to the standard output of the gateway.
+	defaultAccessLogProvider = "envoy"
+
+	// The trace tags the Telemetry adds to the spans of the gateway.
+	accessLogTagEngine   = "coraza.engine"
+	accessLogTagRuleSet  = "coraza.ruleset"
+	accessLogTagRevision = "coraza.ruleset.revision"
+
+	// accessLogTagCorrelationPrefix prefixes the trace tags holding the
+	// request correlation headers.
+	accessLogTagCorrelationPrefix = "coraza.correlation."
+
+	// defaultVerdictMetadataNamespace is the dynamic metadata namespace the
+	// WASM plugin writes its verdict to when the Engine does not set one.
+	defaultVerdictMetadataNamespace = "coraza"
+)
+
+// verdictMetadataNamespace returns the dynamic metadata namespace the WASM
+// plugin of engine writes its verdict to.
+func verdictMetadataNamespace(engine *wafv1alpha1.Engine) string {
+	if engine.Spec.VerdictMetadata == nil || engine.Spec.VerdictMetadata.Namespace == "" {
+		return defaultVerdictMetadataNamespace
+	}
+	return engine.Spec.VerdictMetadata.Namespace
+}
+
+// defaultCorrelationHeaders are the request correlation headers of an Engine
+// that does not list them: the request ID Envoy generates and the W3C trace
+// context.
+var defaultCorrelationHeaders = []string{"x-request-id", "traceparent"}
+
+// correlationHeaders returns the sorted, lowercased request correlation
+// headers of engine, or nil when request correlation is not enabled.
+func correlationHeaders(engine *wafv1alpha1.Engine) []string {
+	correlation := engine.Spec.RequestCorrelation
+	if correlation == nil {
+		return nil
+	}
+	headers := correlation.Headers
+	if len(headers) == 0 {
+		headers = defaultCorrelationHeaders
+	}
+	lowered := make([]string, 0, len(headers))
+	for _, header := range headers {
+		lowered = append(lowered, strings.ToLower(header))
+	}
+	slices.Sort(lowered)
+	return slices.Compact(lowered)
+}
+
+// telemetryEnabled reports whether engine needs a Telemetry: to enable the
+// access log of its gateway, or to label the request metrics with the
+// verdict of the WAF.
+func telemetryEnabled(engine *wafv1alpha1.Engine) bool {
+	return engine.Spec.AccessLog != nil ||
+		(engine.Spec.VerdictMetadata != nil && engine.Spec.VerdictMetadata.MetricLabel != "")
+}
+
+// telemetryName returns the name of the Telemetry generated for the Engine
+// named engineName.
+func telemetryName(engineName string) string {
+	return TelemetryNamePrefix + engineName
+}
+
+// -----------------------------------------------------------------------------
+// Engine Controller - Access Log
+// -----------------------------------------------------------------------------
+
+// reconcileAccessLog applies the Telemetry enabling the access log and the
+// verdict metric label of the gateway of engine, or deletes it when the
+// Engine no longer enables either. Without the Telemetry API, an Engine
+// enabling them is only reported with an event, since the WAF itself is not
+// affected.
+func (r *EngineReconciler) reconcileAccessLog(ctx context.Context, log logr.Logger, req ctrl.Request, engine *wafv1alpha1.Engine, ruleSet *wafv1alpha1.RuleSet) error {
+	if !r.hasCapability(CapabilityIstioTelemetry) {
+		if telemetryEnabled(engine) {
+			r.Recorder.Eventf(engine, nil, "Warning", "TelemetryNotInstalled", "Provision",
+				"The Istio Telemetry API is not installed; the access log and verdict metric label of the gateway are not enabled")
+		}
+		return nil
+	}
+
+	if !telemetryEnabled(engine) {
+		telemetry := &unstructured.Unstructured{}
+		telemetry.SetGroupVersionKind(TelemetryGVK)
+		key := types.NamespacedName{Namespace: engine.Namespace, Name: telemetryName(engine.Name)}
+		if err := r.Get(ctx, key, telemetry); err != nil {
+			if apierrors.IsNotFound(err) {
+				return nil
+			}
+			logAPIError(log, req, "Engine", err, "Failed to get Telemetry", nil)
+			return err
+		}
+		if err := r.Delete(ctx, telemetry); client.IgnoreNotFound(err) != nil {
+			logAPIError(log, req, "Engine", err, "Failed to delete Telemetry", telemetry)
+			return err
+		}
+		logInfo(log, req, "Engine", "Telemetry deleted", "telemetryName", telemetry.GetName())
+		return nil
+	}
+
+	telemetry := r.buildTelemetry(engine, ruleSet)
+	if err := controllerutil.SetControllerReference(engine, telemetry, r.Scheme); err != nil {
+		logError(log, req, "Engine", err, "Failed to set owner reference on Telemetry")
+		return err
+	}
+	if err := serverSideApply(ctx, r.Client, telemetry); err != nil {
+		logAPIError(log, req, "Engine", err, "Failed to create or update Telemetry", telemetry)
+		return err
+	}
+	logDebug(log, req, "Engine", "Telemetry applied", "telemetryName", telemetry.GetName())
+	return nil
+}
+
+// buildTelemetry returns the Telemetry enabling the access log of the
+// gateway of engine, which uses ruleSet, and tagging its traces with the
+// WAF context, and labelling its request metrics with the verdict of the
+// WAF.
+func (r *EngineReconciler) buildTelemetry(engine *wafv1alpha1.Engine, ruleSet *wafv1alpha1.RuleSet) *unstructured.Unstructured {
+
+	matchLabels := map[string]any{}
+	if ws := targetLabelSelector(engine); ws != nil {
+		for k, v := range ws.MatchLabels {
+			matchLabels[k] = v
+		}
+	}
+
+	labels := map[string]any{
+		ManagedByLabel:                     ManagedByValue,
+		wafv1alpha1.LabelExcludeFromBackup: "true",
+	}
+	if r.istioRevision != "" {
+		labels["istio.io/rev"] = r.istioRevision
+	}
+
+	spec := map[string]any{
+		"selector": map[string]any{
+			"matchLabels": matchLabels,
+		},
+	}
+
+	if accessLog := engine.Spec.AccessLog; accessLog != nil {
+		providerNames := accessLog.Providers
+		if len(providerNames) == 0 {
+			providerNames = []string{defaultAccessLogProvider}
+		}
+		providers := make([]any, 0, len(providerNames))
+		for _, name := range providerNames {
+			providers = append(providers, map[string]any{"name": name})
+		}
+
+		literal := func(value string) map[string]any {
+			return map[string]any{"literal": map[string]any{"value": value}}
+		}
+		customTags := map[string]any{
+			accessLogTagEngine:  literal(engine.Namespace + "/" + engine.Name),
+			accessLogTagRuleSet: literal(engine.Namespace + "/" + activeRuleSetName(engine)),
+		}
+		if ruleSet != nil && ruleSet.Status.Revision != nil {
+			customTags[accessLogTagRevision] = literal(ruleSet.Status.Revision.UUID)
+		}
+		for _, header := range correlationHeaders(engine) {
+			customTags[accessLogTagCorrelationPrefix+header] = map[string]any{"header": map[string]any{"name": header}}
+		}
+
+		spec["accessLogging"] = []any{
+			map[string]any{"providers": providers},
+		}
+		spec["tracing"] = []any{
+			map[string]any{"customTags": customTags},
+		}
+	}
+
+	if verdict := engine.Spec.VerdictMetadata; verdict != nil && verdict.MetricLabel != "" {
+		// The label is evaluated by the stats filter from the dynamic
+		// metadata the WASM plugin wrote; requests the plugin did not see
+		// are labelled "unknown".
+		spec["metrics"] = []any{
+			map[string]any{
+				"overrides": []any{
+					map[string]any{
+						"match": map[string]any{"metric": "REQUEST_COUNT"},
+						"tagOverrides": map[string]any{
+							verdict.MetricLabel: map[string]any{
+								"value": fmt.Sprintf("metadata.filter_metadata['%s'].verdict", verdictMetadataNamespace(engine)),
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	telemetry := &unstructured.Unstructured{
+		Object: map[string]any{
+			"metadata": map[string]any{
+				"name":      telemetryName(engine.Name),
+				"namespace": engine.Namespace,
+				"labels":    labels,
+			},
+			"spec": spec,
+		},
+	}
+	telemetry.SetGroupVersionKind(TelemetryGVK)
+	return telemetry
+}
@@ -0,0 +1,136 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/test/utils"
+)
+
+func TestEngineReconciler_TelemetryGolden(t *testing.T) {
+	tests := []struct {
+		name               string
+		accessLog          *wafv1alpha1.AccessLog
+		verdictMetadata    *wafv1alpha1.VerdictMetadata
+		requestCorrelation *wafv1alpha1.RequestCorrelation
+		ruleSet            *wafv1alpha1.RuleSet
+	}{
+		{
+			name:      "default-provider",
+			accessLog: &wafv1alpha1.AccessLog{},
+		},
+		{
+			name:      "providers-and-revision",
+			accessLog: &wafv1alpha1.AccessLog{Providers: []string{"otel", "envoy"}},
+			ruleSet: &wafv1alpha1.RuleSet{Status: wafv1alpha1.RuleSetStatus{
+				Revision: &wafv1alpha1.RuleSetRevision{UUID: "2f1c5c6e-8d0b-4c55-9a7e-3c2f5b1d9e40"},
+			}},
+		},
+		{
+			name:               "request-correlation",
+			accessLog:          &wafv1alpha1.AccessLog{},
+			requestCorrelation: &wafv1alpha1.RequestCorrelation{},
+		},
+		{
+			name:            "verdict-metric-label",
+			verdictMetadata: &wafv1alpha1.VerdictMetadata{MetricLabel: "waf_verdict"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := utils.NewTestEngine(utils.EngineOptions{Name: "waf", Namespace: "team-a", GatewayName: "gateway"})
+			engine.Spec.AccessLog = tt.accessLog
+			engine.Spec.VerdictMetadata = tt.verdictMetadata
+			engine.Spec.RequestCorrelation = tt.requestCorrelation
+
+			r := &EngineReconciler{istioRevision: "canary"}
+			telemetry := r.buildTelemetry(engine, tt.ruleSet)
+
+			utils.AssertGoldenYAML(t, filepath.Join("testdata", "telemetry", tt.name+".yaml"), telemetry.Object)
+		})
+	}
+}
+
+func TestEngineReconciler_ReconcileAccessLog(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+
+	engine := utils.NewTestEngine(utils.EngineOptions{Name: "waf", Namespace: "team-a", GatewayName: "gateway"})
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "team-a", Name: "waf"}}
+	key := types.NamespacedName{Namespace: "team-a", Name: telemetryName("waf")}
+
+	t.Run("Telemetry API not installed", func(t *testing.T) {
+		engine := engine.DeepCopy()
+		engine.Spec.AccessLog = &wafv1alpha1.AccessLog{}
+		recorder := utils.NewFakeRecorder()
+		r := &EngineReconciler{
+			Client:       fake.NewClientBuilder().WithScheme(scheme).Build(),
+			Scheme:       scheme,
+			Recorder:     recorder,
+			capabilities: Capabilities{CapabilityIstioTelemetry: false},
+		}
+
+		require.NoError(t, r.reconcileAccessLog(t.Context(), ctrl.Log, req, engine, nil))
+		require.Len(t, recorder.Events, 1)
+		assert.Equal(t, "TelemetryNotInstalled", recorder.Events[0].Reason)
+	})
+
+	t.Run("verdict metadata without metric label", func(t *testing.T) {
+		engine := engine.DeepCopy()
+		engine.Spec.VerdictMetadata = &wafv1alpha1.VerdictMetadata{}
+		assert.False(t, telemetryEnabled(engine))
+		assert.Equal(t, "coraza", (&EngineReconciler{}).wasmPluginConfig(engine, nil, "")["verdict_metadata_namespace"])
+
+		engine.Spec.VerdictMetadata.MetricLabel = "waf_verdict"
+		assert.True(t, telemetryEnabled(engine))
+	})
+
+	t.Run("access log disabled", func(t *testing.T) {
+		existing := &unstructured.Unstructured{}
+		existing.SetGroupVersionKind(TelemetryGVK)
+		existing.SetNamespace(key.Namespace)
+		existing.SetName(key.Name)
+		existing.SetLabels(map[string]string{ManagedByLabel: ManagedByValue})
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(existing).Build()
+		r := &EngineReconciler{
+			Client:       c,
+			Scheme:       scheme,
+			Recorder:     utils.NewTestRecorder(),
+			capabilities: Capabilities{CapabilityIstioTelemetry: true},
+		}
+
+		require.NoError(t, r.reconcileAccessLog(t.Context(), ctrl.Log, req, engine, nil))
+		telemetry := &unstructured.Unstructured{}
+		telemetry.SetGroupVersionKind(TelemetryGVK)
+		err := c.Get(t.Context(), key, telemetry)
+		assert.True(t, apierrors.IsNotFound(err), "the Telemetry is deleted, got %v", err)
+
+		require.NoError(t, r.reconcileAccessLog(t.Context(), ctrl.Log, req, engine, nil), "a missing Telemetry is not an error")
+	})
+}
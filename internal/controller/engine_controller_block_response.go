@@ -0,0 +1,107 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// Engine Controller - Block Response Vars
+// -----------------------------------------------------------------------------
+
+// blockResponsePlaceholders are the placeholders the WASM plugin renders in
+// the block response templates.
+var blockResponsePlaceholders = []string{"request_id", "timestamp", "status", "language"}
+
+// blockResponsePlaceholderPattern matches a placeholder of a block response
+// template, and an unterminated one.
+var blockResponsePlaceholderPattern = regexp.MustCompile(`\{\{([^{}]*)(\}\})?`)
+
+// -----------------------------------------------------------------------------
+// Engine Controller - Block Response
+// -----------------------------------------------------------------------------
+
+// blockResponseConfig returns the pluginConfig of the block response of
+// engine, with the localized bodies keyed by lowercased language, or nil
+// when it has none.
+func blockResponseConfig(engine *wafv1alpha1.Engine) map[string]any {
+	response := engine.Spec.BlockResponse
+	if response == nil {
+		return nil
+	}
+
+	contentType := response.ContentType
+	if contentType == "" {
+		contentType = "text/html; charset=utf-8"
+	}
+	config := map[string]any{
+		"content_type": contentType,
+		"body":         response.Body,
+	}
+	if len(response.Localized) > 0 {
+		localized := make(map[string]any, len(response.Localized))
+		for _, l := range response.Localized {
+			localized[strings.ToLower(l.Language)] = l.Body
+		}
+		config["localized_bodies"] = localized
+	}
+	return config
+}
+
+// validateBlockResponse checks what the CRD schema cannot: that the block
+// response templates only use known placeholders, and that no two localized
+// bodies have the same language, which is matched case-insensitively.
+func validateBlockResponse(response *wafv1alpha1.BlockResponse) error {
+	if response == nil {
+		return nil
+	}
+	if err := validateBlockResponseTemplate(response.Body); err != nil {
+		return fmt.Errorf("blockResponse.body: %w", err)
+	}
+	languages := make(map[string]bool, len(response.Localized))
+	for _, l := range response.Localized {
+		language := strings.ToLower(l.Language)
+		if languages[language] {
+			return fmt.Errorf("blockResponse.localized: language %s is listed more than once", l.Language)
+		}
+		languages[language] = true
+		if err := validateBlockResponseTemplate(l.Body); err != nil {
+			return fmt.Errorf("blockResponse.localized[%s].body: %w", l.Language, err)
+		}
+	}
+	return nil
+}
+
+// validateBlockResponseTemplate checks that the placeholders of body are
+// terminated and known.
+func validateBlockResponseTemplate(body string) error {
+	for _, m := range blockResponsePlaceholderPattern.FindAllStringSubmatch(body, -1) {
+		if m[2] == "" {
+			return fmt.Errorf("unterminated placeholder %q", m[0])
+		}
+		if name := strings.TrimSpace(m[1]); !slices.Contains(blockResponsePlaceholders, name) {
+			return fmt.Errorf("unknown placeholder %q, expected one of %s", m[0], strings.Join(blockResponsePlaceholders, ", "))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+func TestValidateBlockResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		response *wafv1alpha1.BlockResponse
+		wantErr  string
+	}{
+		{name: "unset"},
+		{
+			name:     "every placeholder",
+			response: &wafv1alpha1.BlockResponse{Body: "{{request_id}} {{ timestamp }} {{status}} {{language}} {not a placeholder}"},
+		},
+		{
+			name:     "unknown placeholder",
+			response: &wafv1alpha1.BlockResponse{Body: "Blocked {{client_ip}}"},
+			wantErr:  `blockResponse.body: unknown placeholder "{{client_ip}}"`,
+		},
+		{
+			name:     "unterminated placeholder",
+			response: &wafv1alpha1.BlockResponse{Body: "Blocked {{request_id"},
+			wantErr:  "blockResponse.body: unterminated placeholder",
+		},
+		{
+			name: "unknown placeholder in localized body",
+			response: &wafv1alpha1.BlockResponse{
+				Body:      "Blocked",
+				Localized: []wafv1alpha1.LocalizedBlockResponse{{Language: "fr", Body: "Bloqué {{raison}}"}},
+			},
+			wantErr: "blockResponse.localized[fr].body",
+		},
+		{
+			name: "language listed twice",
+			response: &wafv1alpha1.BlockResponse{
+				Body: "Blocked",
+				Localized: []wafv1alpha1.LocalizedBlockResponse{
+					{Language: "de-CH", Body: "Blockiert"},
+					{Language: "de-ch", Body: "Gesperrt"},
+				},
+			},
+			wantErr: "language de-ch is listed more than once",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateBlockResponse(tt.response)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
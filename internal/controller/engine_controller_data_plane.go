@@ -0,0 +1,194 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// Engine Controller - Data Plane Vars
+// -----------------------------------------------------------------------------
+
+// dataPlaneHeartbeatTimeout is how long after the last heartbeat of its
+// gateways an Engine is no longer DataPlaneReady. Gateways send a heartbeat
+// every poll interval, recorded at most every dataPlaneHeartbeatInterval.
+const dataPlaneHeartbeatTimeout = 5 * dataPlaneHeartbeatInterval
+
+// -----------------------------------------------------------------------------
+// Engine Controller - Data Plane
+// -----------------------------------------------------------------------------
+
+// wasmPluginRejection returns why Istio rejected wasmPlugin, from the
+// validation messages of level ERROR istiod writes to its status, or an
+// empty string when it reports none.
+func wasmPluginRejection(wasmPlugin *unstructured.Unstructured) string {
+	if wasmPlugin == nil {
+		return ""
+	}
+	messages, _, _ := unstructured.NestedSlice(wasmPlugin.Object, "status", "validationMessages")
+	var errs []string
+	for _, m := range messages {
+		message, ok := m.(map[string]any)
+		if !ok {
+			continue
+		}
+		if level, _, _ := unstructured.NestedString(message, "level"); !strings.EqualFold(level, "error") {
+			continue
+		}
+		code, _, _ := unstructured.NestedString(message, "type", "code")
+		name, _, _ := unstructured.NestedString(message, "type", "name")
+		switch {
+		case code != "" && name != "":
+			errs = append(errs, fmt.Sprintf("%s (%s)", code, name))
+		case code != "" || name != "":
+			errs = append(errs, code+name)
+		default:
+			errs = append(errs, "unknown error")
+		}
+	}
+	return strings.Join(errs, ", ")
+}
+
+// dataPlaneReadiness returns the status, reason and message of the
+// DataPlaneReady condition of engine: whether Istio accepted wasmPlugin,
+// whether a running gateway pod is ready, which the istio-proxy readiness
+// probe only reports once Envoy received its configuration, and
+// whether the WASM plugin on the gateways reports to the cache server that
+// it loaded the rules. Loading is not verified for WASM plugin images that
+// do not send heartbeats.
+func dataPlaneReadiness(engine *wafv1alpha1.Engine, wasmPlugin *unstructured.Unstructured, running, ready int, now time.Time) (metav1.ConditionStatus, string, string) {
+	target := engine.Spec.Target.Name
+	if rejection := wasmPluginRejection(wasmPlugin); rejection != "" {
+		return metav1.ConditionFalse, "WasmPluginRejected",
+			fmt.Sprintf("Istio rejected WasmPlugin %s/%s: %s", engine.Namespace, wasmPluginName(engine.Name), rejection)
+	}
+	if running == 0 {
+		return metav1.ConditionFalse, "NoWorkloadsSelected",
+			fmt.Sprintf("No running pod matches the workload selector of target %s", target)
+	}
+	if ready == 0 {
+		return metav1.ConditionFalse, "GatewayPodsNotReady",
+			fmt.Sprintf("None of the %d running gateway pods of target %s is ready", running, target)
+	}
+
+	var dataPlane *wafv1alpha1.DataPlaneStatus
+	if engine.Status != nil {
+		dataPlane = engine.Status.DataPlane
+	}
+	switch {
+	case dataPlane == nil:
+		return metav1.ConditionTrue, "GatewayPodsReady",
+			fmt.Sprintf("%d of %d gateway pods of target %s are ready; their WASM plugin does not report loading the rules", ready, running, target)
+	case now.Sub(dataPlane.LastHeartbeatTime.Time) > dataPlaneHeartbeatTimeout:
+		return metav1.ConditionFalse, "HeartbeatStale",
+			fmt.Sprintf("The gateways of target %s last reported to the ruleset cache server at %s", target, dataPlane.LastHeartbeatTime.UTC().Format(time.RFC3339))
+	case dataPlane.Revision == "":
+		return metav1.ConditionFalse, "RulesNotLoaded",
+			fmt.Sprintf("The gateways of target %s report that they have not loaded the rules of RuleSet %s", target, activeRuleSetName(engine))
+	}
+	return metav1.ConditionTrue, "RulesLoaded",
+		fmt.Sprintf("%d of %d gateway pods of target %s are ready and enforce revision %s of the rules of RuleSet %s", ready, running, target, dataPlane.Revision, activeRuleSetName(engine))
+}
+
+// patchDataPlaneReady records in the DataPlaneReady condition whether the
+// gateways of the Engine run wasmPlugin, as applied, and records an event
+// when they stop. It returns when the last heartbeat of the gateways times
+// out, or zero when the condition does not depend on it: the WasmPlugin and
+// the gateway pods are watched, and the Engine is reconciled again when its
+// gateways start or stop loading the rules.
+func (r *EngineReconciler) patchDataPlaneReady(ctx context.Context, log logr.Logger, req ctrl.Request, engine *wafv1alpha1.Engine, wasmPlugin *unstructured.Unstructured) (time.Duration, error) {
+	running, ready, err := r.countSelectedWorkloads(ctx, log, req, engine)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	status, reason, message := dataPlaneReadiness(engine, wasmPlugin, running, ready, now)
+	cond := apimeta.FindStatusCondition(engine.Status.Conditions, conditionDataPlaneReady)
+	// NoWorkloadsSelected is already reported by the WorkloadsSelected
+	// condition.
+	if status == metav1.ConditionFalse && reason != "NoWorkloadsSelected" && (cond == nil || cond.Status == metav1.ConditionTrue) {
+		r.Recorder.Eventf(engine, nil, "Warning", reason, "Reconcile", "%s", message)
+	}
+	if err := patchConditions(ctx, r.Status(), log, req, "Engine", engine, &engine.Status.Conditions, func() {
+		if status == metav1.ConditionTrue {
+			setConditionTrue(&engine.Status.Conditions, engine.Generation, conditionDataPlaneReady, reason, message)
+			return
+		}
+		setConditionFalse(&engine.Status.Conditions, engine.Generation, conditionDataPlaneReady, reason, message)
+	}); err != nil {
+		return 0, err
+	}
+
+	if reason != "RulesLoaded" {
+		return 0, nil
+	}
+	return max(engine.Status.DataPlane.LastHeartbeatTime.Add(dataPlaneHeartbeatTimeout).Sub(now), time.Second), nil
+}
+
+// dataPlaneLoaded reports whether the gateways of engine last reported
+// loading its rules.
+func dataPlaneLoaded(engine *wafv1alpha1.Engine) bool {
+	return engine.Status != nil && engine.Status.DataPlane != nil && engine.Status.DataPlane.Revision != ""
+}
+
+// dataPlaneChangedPredicate triggers a reconcile when the heartbeats
+// recorded in the status of an Engine change its DataPlaneReady condition:
+// when its gateways start or stop loading its rules, or report again after
+// their heartbeat timed out. Other heartbeats, recorded every minute, are
+// ignored.
+func dataPlaneChangedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc:  func(event.CreateEvent) bool { return false },
+		DeleteFunc:  func(event.DeleteEvent) bool { return false },
+		GenericFunc: func(event.GenericEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldEngine, ok := e.ObjectOld.(*wafv1alpha1.Engine)
+			if !ok {
+				return false
+			}
+			newEngine, ok := e.ObjectNew.(*wafv1alpha1.Engine)
+			if !ok {
+				return false
+			}
+			if dataPlaneLoaded(oldEngine) != dataPlaneLoaded(newEngine) {
+				return true
+			}
+			if newEngine.Status == nil || newEngine.Status.DataPlane == nil {
+				return false
+			}
+			if oldEngine.Status == nil || oldEngine.Status.DataPlane == nil {
+				return true
+			}
+			return newEngine.Status.DataPlane.LastHeartbeatTime.Sub(oldEngine.Status.DataPlane.LastHeartbeatTime.Time) > dataPlaneHeartbeatTimeout
+		},
+	}
+}
@@ -0,0 +1,219 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/test/utils"
+)
+
+// rejectedWasmPlugin returns a WasmPlugin whose status reports the
+// validation messages of the given levels.
+func rejectedWasmPlugin(levels ...string) *unstructured.Unstructured {
+	var messages []any
+	for _, level := range levels {
+		messages = append(messages, map[string]any{
+			"level": level,
+			"type":  map[string]any{"code": "IST0101", "name": "ReferencedResourceNotFound"},
+		})
+	}
+	return &unstructured.Unstructured{Object: map[string]any{
+		"status": map[string]any{"validationMessages": messages},
+	}}
+}
+
+func TestWasmPluginRejection(t *testing.T) {
+	assert.Empty(t, wasmPluginRejection(nil))
+	assert.Empty(t, wasmPluginRejection(&unstructured.Unstructured{Object: map[string]any{}}))
+	assert.Empty(t, wasmPluginRejection(rejectedWasmPlugin("WARNING", "Info")))
+	assert.Equal(t, "IST0101 (ReferencedResourceNotFound)", wasmPluginRejection(rejectedWasmPlugin("WARNING", "ERROR")))
+	assert.Equal(t, "IST0101 (ReferencedResourceNotFound), IST0101 (ReferencedResourceNotFound)", wasmPluginRejection(rejectedWasmPlugin("Error", "ERROR")))
+}
+
+func TestDataPlaneReadiness(t *testing.T) {
+	now := time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC)
+	heartbeat := func(revision string, age time.Duration) *wafv1alpha1.DataPlaneStatus {
+		return &wafv1alpha1.DataPlaneStatus{Revision: revision, LastHeartbeatTime: metav1.NewTime(now.Add(-age))}
+	}
+
+	tests := []struct {
+		name       string
+		wasmPlugin *unstructured.Unstructured
+		running    int
+		ready      int
+		dataPlane  *wafv1alpha1.DataPlaneStatus
+		wantStatus metav1.ConditionStatus
+		wantReason string
+	}{
+		{
+			name:       "rules loaded",
+			running:    2,
+			ready:      2,
+			dataPlane:  heartbeat("0b6b8a61-3c3e-5b8e-9a53-3b1f2f7a9e10", time.Minute),
+			wantStatus: metav1.ConditionTrue,
+			wantReason: "RulesLoaded",
+		},
+		{
+			name:       "rolling update",
+			running:    3,
+			ready:      1,
+			dataPlane:  heartbeat("0b6b8a61-3c3e-5b8e-9a53-3b1f2f7a9e10", time.Minute),
+			wantStatus: metav1.ConditionTrue,
+			wantReason: "RulesLoaded",
+		},
+		{
+			name:       "no heartbeats",
+			running:    1,
+			ready:      1,
+			wantStatus: metav1.ConditionTrue,
+			wantReason: "GatewayPodsReady",
+		},
+		{
+			name:       "rejected by Istio",
+			wasmPlugin: rejectedWasmPlugin("ERROR"),
+			running:    1,
+			ready:      1,
+			dataPlane:  heartbeat("0b6b8a61-3c3e-5b8e-9a53-3b1f2f7a9e10", time.Minute),
+			wantStatus: metav1.ConditionFalse,
+			wantReason: "WasmPluginRejected",
+		},
+		{
+			name:       "no gateway pod",
+			wantStatus: metav1.ConditionFalse,
+			wantReason: "NoWorkloadsSelected",
+		},
+		{
+			name:       "gateway pods not ready",
+			running:    2,
+			wantStatus: metav1.ConditionFalse,
+			wantReason: "GatewayPodsNotReady",
+		},
+		{
+			name:       "heartbeat timed out",
+			running:    1,
+			ready:      1,
+			dataPlane:  heartbeat("0b6b8a61-3c3e-5b8e-9a53-3b1f2f7a9e10", dataPlaneHeartbeatTimeout+time.Second),
+			wantStatus: metav1.ConditionFalse,
+			wantReason: "HeartbeatStale",
+		},
+		{
+			name:       "rules not loaded",
+			running:    1,
+			ready:      1,
+			dataPlane:  heartbeat("", time.Minute),
+			wantStatus: metav1.ConditionFalse,
+			wantReason: "RulesNotLoaded",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := utils.NewTestEngine(utils.EngineOptions{Name: "waf", Namespace: "team-a", RuleSetName: "rules", GatewayName: "gateway"})
+			engine.Status = &wafv1alpha1.EngineStatus{DataPlane: tt.dataPlane}
+			status, reason, message := dataPlaneReadiness(engine, tt.wasmPlugin, tt.running, tt.ready, now)
+			assert.Equal(t, tt.wantStatus, status)
+			assert.Equal(t, tt.wantReason, reason)
+			assert.NotEmpty(t, message)
+		})
+	}
+}
+
+func TestEngineReconciler_PatchDataPlaneReady(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	engine := utils.NewTestEngine(utils.EngineOptions{Name: "waf", Namespace: "team-a", RuleSetName: "rules", GatewayName: "gateway"})
+	engine.Status = &wafv1alpha1.EngineStatus{DataPlane: &wafv1alpha1.DataPlaneStatus{
+		Revision:          "0b6b8a61-3c3e-5b8e-9a53-3b1f2f7a9e10",
+		LastHeartbeatTime: metav1.Now(),
+	}}
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "gateway-1", Namespace: "team-a", Labels: map[string]string{gatewayNameLabel: "gateway"}},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+		},
+	}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(engine, pod).WithStatusSubresource(engine).Build()
+	recorder := utils.NewFakeRecorder()
+	r := &EngineReconciler{Client: c, Scheme: scheme, Recorder: recorder}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(engine)}
+
+	t.Log("Reporting the gateways loading the rules")
+	timeout, err := r.patchDataPlaneReady(t.Context(), ctrl.Log, req, engine, nil)
+	require.NoError(t, err)
+	assert.Greater(t, timeout, dataPlaneHeartbeatTimeout-time.Minute)
+	assert.LessOrEqual(t, timeout, dataPlaneHeartbeatTimeout)
+	var got wafv1alpha1.Engine
+	require.NoError(t, c.Get(t.Context(), req.NamespacedName, &got))
+	assert.True(t, apimeta.IsStatusConditionTrue(got.Status.Conditions, conditionDataPlaneReady))
+	assert.Empty(t, recorder.Events)
+
+	t.Log("Reporting the WasmPlugin rejected by Istio once")
+	for range 2 {
+		timeout, err = r.patchDataPlaneReady(t.Context(), ctrl.Log, req, engine, rejectedWasmPlugin("ERROR"))
+		require.NoError(t, err)
+		assert.Zero(t, timeout)
+	}
+	require.NoError(t, c.Get(t.Context(), req.NamespacedName, &got))
+	cond := apimeta.FindStatusCondition(got.Status.Conditions, conditionDataPlaneReady)
+	require.NotNil(t, cond)
+	assert.Equal(t, metav1.ConditionFalse, cond.Status)
+	assert.Equal(t, "WasmPluginRejected", cond.Reason)
+	require.Len(t, recorder.Events, 1)
+	assert.Equal(t, "WasmPluginRejected", recorder.Events[0].Reason)
+}
+
+func TestDataPlaneChangedPredicate(t *testing.T) {
+	now := time.Now()
+	withDataPlane := func(revision string, heartbeat time.Time) *wafv1alpha1.Engine {
+		engine := utils.NewTestEngine(utils.EngineOptions{Name: "waf", Namespace: "team-a", GatewayName: "gateway"})
+		engine.Status = &wafv1alpha1.EngineStatus{}
+		if !heartbeat.IsZero() {
+			engine.Status.DataPlane = &wafv1alpha1.DataPlaneStatus{Revision: revision, LastHeartbeatTime: metav1.NewTime(heartbeat)}
+		}
+		return engine
+	}
+	const revision = "0b6b8a61-3c3e-5b8e-9a53-3b1f2f7a9e10"
+	update := func(oldEngine, newEngine *wafv1alpha1.Engine) bool {
+		return dataPlaneChangedPredicate().Update(event.UpdateEvent{ObjectOld: oldEngine, ObjectNew: newEngine})
+	}
+
+	assert.True(t, update(withDataPlane("", time.Time{}), withDataPlane("", now)), "first heartbeat")
+	assert.True(t, update(withDataPlane("", now), withDataPlane(revision, now.Add(time.Minute))), "rules loaded")
+	assert.True(t, update(withDataPlane(revision, now), withDataPlane("", now.Add(time.Minute))), "rules no longer loaded")
+	assert.False(t, update(withDataPlane(revision, now), withDataPlane(revision, now.Add(time.Minute))), "periodic heartbeat")
+	assert.True(t, update(withDataPlane(revision, now), withDataPlane(revision, now.Add(dataPlaneHeartbeatTimeout+time.Minute))), "heartbeat after a timeout")
+	assert.False(t, dataPlaneChangedPredicate().Create(event.CreateEvent{Object: withDataPlane(revision, now)}))
+}
@@ -0,0 +1,72 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"maps"
+	"slices"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/equality"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// Engine Controller - Effective Config
+// -----------------------------------------------------------------------------
+
+// effectiveConfig summarizes pluginConfig, the pluginConfig written to a
+// WasmPlugin for the image wasmURL, restricted to listenerPort unless it is
+// 0. The value of the cache token is left out.
+func effectiveConfig(wasmURL string, pluginConfig map[string]any, listenerPort int64) *wafv1alpha1.EffectiveConfig {
+	config := &wafv1alpha1.EffectiveConfig{
+		Image:            wasmURL,
+		ListenerPort:     int32(listenerPort),
+		PluginConfigKeys: slices.Sorted(maps.Keys(pluginConfig)),
+	}
+	config.PluginConfigVersion = 1
+	if version, ok := pluginConfig[pluginConfigVersionKey].(int); ok {
+		config.PluginConfigVersion = int32(version)
+	}
+	if policy, ok := pluginConfig["failure_policy"].(string); ok {
+		config.FailurePolicy = wafv1alpha1.FailurePolicy(policy)
+	}
+	if interval, ok := pluginConfig["rule_reload_interval_seconds"].(int32); ok {
+		config.PollIntervalSeconds = interval
+	}
+	return config
+}
+
+// patchEffectiveConfig records config, the effective configuration of the
+// provisioned WasmPlugin, in the Engine status.
+func (r *EngineReconciler) patchEffectiveConfig(ctx context.Context, log logr.Logger, req ctrl.Request, engine *wafv1alpha1.Engine, config *wafv1alpha1.EffectiveConfig) error {
+	if equality.Semantic.DeepEqual(engine.Status.EffectiveConfig, config) {
+		return nil
+	}
+	patch := client.MergeFrom(engine.DeepCopy())
+	engine.Status.EffectiveConfig = config
+	if err := r.Status().Patch(ctx, engine, patch); err != nil {
+		logAPIError(log, req, "Engine", err, "Failed to patch effective config", engine)
+		return err
+	}
+	logDebug(log, req, "Engine", "Effective config changed", "image", config.Image, "failurePolicy", config.FailurePolicy)
+	return nil
+}
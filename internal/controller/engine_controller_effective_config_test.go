@@ -0,0 +1,50 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/internal/defaults"
+	"github.com/networking-incubator/coraza-kubernetes-operator/test/utils"
+)
+
+func TestEffectiveConfig(t *testing.T) {
+	engine := utils.NewTestEngine(utils.EngineOptions{Name: "waf", Namespace: "team-a", GatewayName: "gateway"})
+	engine.Spec.RuleSetCacheServer = &wafv1alpha1.RuleSetCacheServerConfig{PollIntervalSeconds: 30}
+	engine.Spec.RuleExclusions = []wafv1alpha1.RuleExclusion{{RuleID: 942100}}
+	r := &EngineReconciler{}
+
+	config := effectiveConfig("oci://registry.internal/coraza-proxy-wasm:v2", r.wasmPluginConfig(engine, nil, "secret-token"), 8443)
+	assert.Equal(t, "oci://registry.internal/coraza-proxy-wasm:v2", config.Image)
+	assert.Equal(t, wafv1alpha1.FailurePolicyFail, config.FailurePolicy)
+	assert.Equal(t, int32(30), config.PollIntervalSeconds)
+	assert.Equal(t, int32(8443), config.ListenerPort)
+	assert.Equal(t, int32(wasmPluginConfigVersion), config.PluginConfigVersion)
+	assert.NotContains(t, config.PluginConfigKeys, "engine_directives")
+	assert.NotContains(t, fmt.Sprint(config), "secret-token")
+
+	t.Log("Summarizing the pluginConfig migrated for an older image")
+	config = effectiveConfig(defaults.DefaultCorazaWasmOCIReference, migratePluginConfig(r.wasmPluginConfig(engine, nil, ""), 1), 0)
+	assert.Equal(t, int32(1), config.PluginConfigVersion)
+	assert.NotContains(t, config.PluginConfigKeys, pluginConfigVersionKey)
+	assert.Zero(t, config.ListenerPort)
+}
@@ -0,0 +1,219 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// Engine Controller - Failure Policy Vars
+// -----------------------------------------------------------------------------
+
+// defaultFailurePolicyDowngradeTTL is the ttlSeconds of a failure policy
+// downgrade when it is not set.
+const defaultFailurePolicyDowngradeTTL = time.Hour
+
+// failingClosedEngines is 1 for the Engines that are Degraded with
+// failurePolicy fail and not downgraded, and 0 for the others.
+var failingClosedEngines = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "coraza_engine_failing_closed",
+		Help: "Whether the Engine is Degraded with failurePolicy fail, so that the traffic of its gateway may be blocked (1) or not (0).",
+	},
+	[]string{"namespace", "engine"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(failingClosedEngines)
+}
+
+// -----------------------------------------------------------------------------
+// Engine Controller - Failure Policy
+// -----------------------------------------------------------------------------
+
+// failurePolicy returns the failure policy of the WasmPlugin of engine: its
+// spec, fail by default, or allow while it is downgraded.
+func failurePolicy(engine *wafv1alpha1.Engine, now time.Time) wafv1alpha1.FailurePolicy {
+	if failurePolicyDowngraded(engine, now) {
+		return wafv1alpha1.FailurePolicyAllow
+	}
+	return specFailurePolicy(engine)
+}
+
+// specFailurePolicy returns the failure policy of the WasmPlugin of engine
+// from its spec: fail by default, and with degrade, under which the plugin
+// fails closed when neither the RuleSet nor its fallback can be loaded.
+func specFailurePolicy(engine *wafv1alpha1.Engine) wafv1alpha1.FailurePolicy {
+	switch engine.Spec.FailurePolicy {
+	case "", wafv1alpha1.FailurePolicyDegrade:
+		return wafv1alpha1.FailurePolicyFail
+	default:
+		return engine.Spec.FailurePolicy
+	}
+}
+
+// failurePolicyDowngraded reports whether the failure policy of engine is
+// downgraded to allow at now.
+func failurePolicyDowngraded(engine *wafv1alpha1.Engine, now time.Time) bool {
+	return engine.Spec.FailurePolicyDowngrade != nil &&
+		engine.Status != nil &&
+		engine.Status.FailurePolicyDowngrade != nil &&
+		now.Before(engine.Status.FailurePolicyDowngrade.ExpireTime.Time)
+}
+
+// failurePolicyDowngradeTTL returns how long the failure policy is
+// downgraded at most.
+func failurePolicyDowngradeTTL(downgrade *wafv1alpha1.FailurePolicyDowngrade) time.Duration {
+	if downgrade.TTLSeconds == 0 {
+		return defaultFailurePolicyDowngradeTTL
+	}
+	return time.Duration(downgrade.TTLSeconds) * time.Second
+}
+
+// reconcileFailurePolicy reports in the FailingClosed condition and metric
+// whether the Engine is Degraded with failurePolicy fail, and records an
+// event explaining what broke when it starts failing closed. When
+// spec.failurePolicyDowngrade is set, it downgrades the failure policy of
+// the WasmPlugin to allow until the Engine recovers or the downgrade
+// expires. It returns how long until the downgrade expires, or zero when the
+// failure policy is not downgraded.
+func (r *EngineReconciler) reconcileFailurePolicy(ctx context.Context, log logr.Logger, req ctrl.Request, engine *wafv1alpha1.Engine) (time.Duration, error) {
+	now := time.Now()
+	specPolicy := specFailurePolicy(engine)
+	degraded := apimeta.FindStatusCondition(engine.Status.Conditions, conditionDegraded)
+	failingClosed := specPolicy == wafv1alpha1.FailurePolicyFail && degraded != nil && degraded.Status == metav1.ConditionTrue
+	downgrade := engine.Status.FailurePolicyDowngrade
+	previous := apimeta.FindStatusCondition(engine.Status.Conditions, conditionFailingClosed)
+
+	// The Engine recovered, or its failure policy changed: restore the
+	// failure policy of the spec.
+	if !failingClosed {
+		failingClosedEngines.WithLabelValues(engine.Namespace, engine.Name).Set(0)
+		if downgrade != nil {
+			logInfo(log, req, "Engine", "Engine recovered, restoring failure policy", "failurePolicy", specPolicy)
+			if err := r.patchWasmPluginFailurePolicy(ctx, log, req, engine, specPolicy); err != nil {
+				return 0, err
+			}
+			r.Recorder.Eventf(engine, nil, "Normal", "FailurePolicyRestored", "Reconcile", "Engine is no longer Degraded: failurePolicy %s restored", specPolicy)
+		}
+		reason, msg := "NotDegraded", "Engine is not Degraded"
+		if specPolicy == wafv1alpha1.FailurePolicyAllow {
+			reason, msg = "FailurePolicyAllow", "Traffic is allowed through when the WAF is not ready"
+		}
+		return 0, patchConditions(ctx, r.Status(), log, req, "Engine", engine, &engine.Status.Conditions, func() {
+			engine.Status.FailurePolicyDowngrade = nil
+			setConditionFalse(&engine.Status.Conditions, engine.Generation, conditionFailingClosed, reason, msg)
+		})
+	}
+
+	cause := fmt.Sprintf("Engine is Degraded with failurePolicy fail (%s: %s)", degraded.Reason, degraded.Message)
+
+	if engine.Spec.FailurePolicyDowngrade != nil && downgrade == nil {
+		expire := now.Add(failurePolicyDowngradeTTL(engine.Spec.FailurePolicyDowngrade))
+		logInfo(log, req, "Engine", "Downgrading failure policy to allow", "reason", degraded.Reason, "expireTime", expire)
+		if err := r.patchWasmPluginFailurePolicy(ctx, log, req, engine, wafv1alpha1.FailurePolicyAllow); err != nil {
+			return 0, err
+		}
+		downgrade = &wafv1alpha1.FailurePolicyDowngradeStatus{StartTime: metav1.NewTime(now), ExpireTime: metav1.NewTime(expire)}
+		r.Recorder.Eventf(engine, nil, "Warning", "FailurePolicyDowngraded", "Reconcile", truncateEventNote(fmt.Sprintf(
+			"%s: traffic is allowed through without the WAF until %s or until the Engine recovers", cause, expire.UTC().Format(time.RFC3339))))
+	}
+
+	if engine.Spec.FailurePolicyDowngrade != nil && downgrade != nil && now.Before(downgrade.ExpireTime.Time) {
+		failingClosedEngines.WithLabelValues(engine.Namespace, engine.Name).Set(0)
+		msg := fmt.Sprintf("%s: failurePolicy downgraded to allow until %s", cause, downgrade.ExpireTime.UTC().Format(time.RFC3339))
+		return time.Until(downgrade.ExpireTime.Time), patchConditions(ctx, r.Status(), log, req, "Engine", engine, &engine.Status.Conditions, func() {
+			engine.Status.FailurePolicyDowngrade = downgrade
+			setConditionFalse(&engine.Status.Conditions, engine.Generation, conditionFailingClosed, "FailurePolicyDowngraded", msg)
+		})
+	}
+
+	// The downgrade expired, or was disabled, while the Engine is still
+	// Degraded: fail closed again until it recovers. An expired downgrade
+	// is kept, so that the Engine is not downgraded again.
+	if downgrade != nil {
+		if err := r.patchWasmPluginFailurePolicy(ctx, log, req, engine, wafv1alpha1.FailurePolicyFail); err != nil {
+			return 0, err
+		}
+		if engine.Spec.FailurePolicyDowngrade == nil {
+			downgrade = nil
+		}
+	}
+
+	failingClosedEngines.WithLabelValues(engine.Namespace, engine.Name).Set(1)
+	msg := cause + ": requests through the gateway are blocked whenever the WAF cannot load its rules"
+	if downgrade != nil {
+		msg += fmt.Sprintf("; the failurePolicy downgrade expired at %s", downgrade.ExpireTime.UTC().Format(time.RFC3339))
+	}
+	if previous == nil || previous.Status != metav1.ConditionTrue || previous.Reason != degraded.Reason {
+		r.Recorder.Eventf(engine, nil, "Warning", "FailingClosed", "Reconcile", truncateEventNote(msg))
+	}
+	return 0, patchConditions(ctx, r.Status(), log, req, "Engine", engine, &engine.Status.Conditions, func() {
+		engine.Status.FailurePolicyDowngrade = downgrade
+		setConditionTrue(&engine.Status.Conditions, engine.Generation, conditionFailingClosed, degraded.Reason, msg)
+	})
+}
+
+// patchWasmPluginFailurePolicy sets the failure policy of the WasmPlugin of
+// engine, which provisioning does not update while the Engine is Degraded.
+// A missing WasmPlugin is left alone.
+func (r *EngineReconciler) patchWasmPluginFailurePolicy(ctx context.Context, log logr.Logger, req ctrl.Request, engine *wafv1alpha1.Engine, policy wafv1alpha1.FailurePolicy) error {
+	if !r.hasCapability(CapabilityWasmPlugin) {
+		return nil
+	}
+
+	wasmPlugin := &unstructured.Unstructured{}
+	wasmPlugin.SetGroupVersionKind(WasmPluginGVK)
+	if err := r.Get(ctx, types.NamespacedName{Name: wasmPluginName(engine.Name), Namespace: engine.Namespace}, wasmPlugin); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		logAPIError(log, req, "Engine", err, "Failed to get WasmPlugin", nil)
+		return err
+	}
+
+	current, _, _ := unstructured.NestedString(wasmPlugin.Object, "spec", "pluginConfig", "failure_policy")
+	if current == string(policy) {
+		return nil
+	}
+	patch := client.MergeFrom(wasmPlugin.DeepCopy())
+	if err := unstructured.SetNestedField(wasmPlugin.Object, string(policy), "spec", "pluginConfig", "failure_policy"); err != nil {
+		return err
+	}
+	if err := r.Patch(ctx, wasmPlugin, patch); err != nil {
+		logAPIError(log, req, "Engine", err, "Failed to patch WasmPlugin failure policy", wasmPlugin)
+		return err
+	}
+	logInfo(log, req, "Engine", "WasmPlugin failure policy updated", "failurePolicy", policy)
+	return nil
+}
@@ -0,0 +1,231 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/test/utils"
+)
+
+func TestFailurePolicy(t *testing.T) {
+	now := time.Now()
+	engine := &wafv1alpha1.Engine{}
+	assert.Equal(t, wafv1alpha1.FailurePolicyFail, failurePolicy(engine, now), "fail by default")
+
+	engine.Spec.FailurePolicy = wafv1alpha1.FailurePolicyAllow
+	assert.Equal(t, wafv1alpha1.FailurePolicyAllow, failurePolicy(engine, now))
+
+	engine.Spec.FailurePolicy = wafv1alpha1.FailurePolicyDegrade
+	assert.Equal(t, wafv1alpha1.FailurePolicyFail, failurePolicy(engine, now), "degrade fails closed without rules")
+
+	engine.Spec.FailurePolicy = wafv1alpha1.FailurePolicyFail
+	engine.Spec.FailurePolicyDowngrade = &wafv1alpha1.FailurePolicyDowngrade{}
+	engine.Status = &wafv1alpha1.EngineStatus{FailurePolicyDowngrade: &wafv1alpha1.FailurePolicyDowngradeStatus{
+		StartTime:  metav1.NewTime(now.Add(-time.Minute)),
+		ExpireTime: metav1.NewTime(now.Add(time.Minute)),
+	}}
+	assert.Equal(t, wafv1alpha1.FailurePolicyAllow, failurePolicy(engine, now), "downgraded")
+	assert.Equal(t, wafv1alpha1.FailurePolicyFail, failurePolicy(engine, now.Add(2*time.Minute)), "downgrade expired")
+
+	assert.Equal(t, time.Hour, failurePolicyDowngradeTTL(&wafv1alpha1.FailurePolicyDowngrade{}))
+	assert.Equal(t, 5*time.Minute, failurePolicyDowngradeTTL(&wafv1alpha1.FailurePolicyDowngrade{TTLSeconds: 300}))
+}
+
+func TestReconcileFailurePolicy(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+
+	expired := &wafv1alpha1.FailurePolicyDowngradeStatus{
+		StartTime:  metav1.NewTime(time.Now().Add(-2 * time.Hour)),
+		ExpireTime: metav1.NewTime(time.Now().Add(-time.Hour)),
+	}
+	active := &wafv1alpha1.FailurePolicyDowngradeStatus{
+		StartTime:  metav1.NewTime(time.Now().Add(-time.Minute)),
+		ExpireTime: metav1.NewTime(time.Now().Add(time.Hour)),
+	}
+
+	tests := []struct {
+		name          string
+		policy        wafv1alpha1.FailurePolicy
+		downgrade     *wafv1alpha1.FailurePolicyDowngrade
+		degraded      bool
+		status        *wafv1alpha1.FailurePolicyDowngradeStatus
+		pluginPolicy  string
+		wantStatus    metav1.ConditionStatus
+		wantReason    string
+		wantPolicy    string
+		wantDowngrade bool
+		wantRequeue   bool
+		wantMetric    float64
+		wantEvent     string
+	}{
+		{
+			name:         "ready",
+			pluginPolicy: "fail",
+			wantStatus:   metav1.ConditionFalse,
+			wantReason:   "NotDegraded",
+			wantPolicy:   "fail",
+		},
+		{
+			name:         "degraded with failurePolicy allow",
+			policy:       wafv1alpha1.FailurePolicyAllow,
+			degraded:     true,
+			pluginPolicy: "allow",
+			wantStatus:   metav1.ConditionFalse,
+			wantReason:   "FailurePolicyAllow",
+			wantPolicy:   "allow",
+		},
+		{
+			name:         "failing closed",
+			degraded:     true,
+			pluginPolicy: "fail",
+			wantStatus:   metav1.ConditionTrue,
+			wantReason:   "RuleSetDegraded",
+			wantPolicy:   "fail",
+			wantMetric:   1,
+			wantEvent:    "FailingClosed",
+		},
+		{
+			name:         "failing closed with failurePolicy degrade",
+			policy:       wafv1alpha1.FailurePolicyDegrade,
+			degraded:     true,
+			pluginPolicy: "fail",
+			wantStatus:   metav1.ConditionTrue,
+			wantReason:   "RuleSetDegraded",
+			wantPolicy:   "fail",
+			wantMetric:   1,
+			wantEvent:    "FailingClosed",
+		},
+		{
+			name:          "downgrade starts",
+			downgrade:     &wafv1alpha1.FailurePolicyDowngrade{TTLSeconds: 600},
+			degraded:      true,
+			pluginPolicy:  "fail",
+			wantStatus:    metav1.ConditionFalse,
+			wantReason:    "FailurePolicyDowngraded",
+			wantPolicy:    "allow",
+			wantDowngrade: true,
+			wantRequeue:   true,
+			wantEvent:     "FailurePolicyDowngraded",
+		},
+		{
+			name:          "downgrade expired",
+			downgrade:     &wafv1alpha1.FailurePolicyDowngrade{TTLSeconds: 600},
+			degraded:      true,
+			status:        expired,
+			pluginPolicy:  "allow",
+			wantStatus:    metav1.ConditionTrue,
+			wantReason:    "RuleSetDegraded",
+			wantPolicy:    "fail",
+			wantDowngrade: true,
+			wantMetric:    1,
+			wantEvent:     "FailingClosed",
+		},
+		{
+			name:         "downgrade disabled",
+			degraded:     true,
+			status:       active,
+			pluginPolicy: "allow",
+			wantStatus:   metav1.ConditionTrue,
+			wantReason:   "RuleSetDegraded",
+			wantPolicy:   "fail",
+			wantMetric:   1,
+			wantEvent:    "FailingClosed",
+		},
+		{
+			name:         "recovered",
+			downgrade:    &wafv1alpha1.FailurePolicyDowngrade{},
+			status:       active,
+			pluginPolicy: "allow",
+			wantStatus:   metav1.ConditionFalse,
+			wantReason:   "NotDegraded",
+			wantPolicy:   "fail",
+			wantEvent:    "FailurePolicyRestored",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := utils.NewTestEngine(utils.EngineOptions{Name: "waf", Namespace: "team-a", GatewayName: "gateway"})
+			engine.Spec.FailurePolicy = tt.policy
+			engine.Spec.FailurePolicyDowngrade = tt.downgrade
+			engine.Status = &wafv1alpha1.EngineStatus{FailurePolicyDowngrade: tt.status.DeepCopy()}
+			if tt.degraded {
+				applyStatusConditionDegraded(&engine.Status.Conditions, engine.Generation, "RuleSetDegraded", "RuleSet ruleset is degraded (RuleDataNotFound); see its Degraded condition for the cause")
+			} else {
+				applyStatusReady(&engine.Status.Conditions, engine.Generation, "Configured", "WasmPlugin successfully created/updated")
+			}
+
+			wasmPlugin := &unstructured.Unstructured{}
+			wasmPlugin.SetGroupVersionKind(WasmPluginGVK)
+			wasmPlugin.SetNamespace(engine.Namespace)
+			wasmPlugin.SetName(wasmPluginName(engine.Name))
+			require.NoError(t, unstructured.SetNestedField(wasmPlugin.Object, tt.pluginPolicy, "spec", "pluginConfig", "failure_policy"))
+
+			c := fake.NewClientBuilder().WithScheme(scheme).
+				WithObjects(engine, wasmPlugin).
+				WithStatusSubresource(engine).
+				Build()
+			recorder := utils.NewFakeRecorder()
+			r := &EngineReconciler{Client: c, Scheme: scheme, Recorder: recorder}
+			req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(engine)}
+
+			requeue, err := r.reconcileFailurePolicy(t.Context(), ctrl.Log, req, engine)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantRequeue, requeue > 0)
+			assert.Equal(t, tt.wantMetric, testutil.ToFloat64(failingClosedEngines.WithLabelValues(engine.Namespace, engine.Name)))
+			var reasons []string
+			for _, e := range recorder.Events {
+				reasons = append(reasons, e.Reason)
+			}
+			if tt.wantEvent != "" {
+				assert.Equal(t, []string{tt.wantEvent}, reasons)
+			} else {
+				assert.Empty(t, reasons)
+			}
+
+			var got wafv1alpha1.Engine
+			require.NoError(t, c.Get(t.Context(), req.NamespacedName, &got))
+			cond := apimeta.FindStatusCondition(got.Status.Conditions, conditionFailingClosed)
+			require.NotNil(t, cond)
+			assert.Equal(t, tt.wantStatus, cond.Status)
+			assert.Equal(t, tt.wantReason, cond.Reason)
+			assert.Equal(t, tt.wantDowngrade, got.Status.FailurePolicyDowngrade != nil)
+
+			var gotPlugin unstructured.Unstructured
+			gotPlugin.SetGroupVersionKind(WasmPluginGVK)
+			require.NoError(t, c.Get(t.Context(), types.NamespacedName{Namespace: engine.Namespace, Name: wasmPluginName(engine.Name)}, &gotPlugin))
+			policy, _, _ := unstructured.NestedString(gotPlugin.Object, "spec", "pluginConfig", "failure_policy")
+			assert.Equal(t, tt.wantPolicy, policy)
+		})
+	}
+}
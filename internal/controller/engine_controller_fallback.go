@@ -0,0 +1,125 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// Engine Controller - Fallback RuleSet
+// -----------------------------------------------------------------------------
+
+// engineRuleSets returns the names of the RuleSets engine may load: its
+// RuleSet and, with failurePolicy degrade, its fallback RuleSet.
+func engineRuleSets(engine *wafv1alpha1.Engine) []string {
+	names := []string{engine.Spec.RuleSet.Name}
+	if fallback := fallbackRuleSetName(engine); fallback != "" && fallback != engine.Spec.RuleSet.Name {
+		names = append(names, fallback)
+	}
+	return names
+}
+
+// fallbackRuleSetName returns the name of the fallback RuleSet of engine, or
+// an empty string when its failure policy is not degrade.
+func fallbackRuleSetName(engine *wafv1alpha1.Engine) string {
+	if engine.Spec.FailurePolicy != wafv1alpha1.FailurePolicyDegrade || engine.Spec.FallbackRuleSet == nil {
+		return ""
+	}
+	return engine.Spec.FallbackRuleSet.Name
+}
+
+// activeRuleSetName returns the name of the RuleSet engine loads, as last
+// selected by reconcileActiveRuleSet.
+func activeRuleSetName(engine *wafv1alpha1.Engine) string {
+	if engine.Status != nil && engine.Status.ActiveRuleSet != "" && engine.Status.ActiveRuleSet == fallbackRuleSetName(engine) {
+		return engine.Status.ActiveRuleSet
+	}
+	return engine.Spec.RuleSet.Name
+}
+
+// ruleSetLoadable reports whether the cache server serves rules for ruleSet:
+// it exists, and is not Degraded or published a revision before it was.
+func ruleSetLoadable(ruleSet *wafv1alpha1.RuleSet) bool {
+	if ruleSet == nil {
+		return false
+	}
+	degraded := apimeta.FindStatusCondition(ruleSet.Status.Conditions, conditionDegraded)
+	return degraded == nil || degraded.Status != metav1.ConditionTrue || ruleSet.Status.Revision != nil
+}
+
+// reconcileActiveRuleSet selects the RuleSet engine loads, records it in
+// status.activeRuleSet, and records an event when the Engine switches to or
+// from its fallback RuleSet. With failurePolicy degrade, the fallback RuleSet
+// is used while the rules of the RuleSet cannot be loaded; whether the
+// fallback RuleSet itself can be is checked like any RuleSet of an Engine.
+func (r *EngineReconciler) reconcileActiveRuleSet(ctx context.Context, log logr.Logger, req ctrl.Request, engine *wafv1alpha1.Engine) error {
+	active := engine.Spec.RuleSet.Name
+	if fallback := fallbackRuleSetName(engine); fallback != "" {
+		var ruleSet wafv1alpha1.RuleSet
+		err := r.Get(ctx, types.NamespacedName{Namespace: engine.Namespace, Name: engine.Spec.RuleSet.Name}, &ruleSet)
+		switch {
+		case apierrors.IsNotFound(err):
+			active = fallback
+		case err != nil:
+			logAPIError(log, req, "Engine", err, "Failed to get RuleSet", nil)
+			return err
+		case !ruleSetLoadable(&ruleSet):
+			active = fallback
+		}
+	}
+
+	previous := ""
+	if engine.Status != nil {
+		previous = engine.Status.ActiveRuleSet
+	}
+	if previous == active {
+		return nil
+	}
+
+	patch := client.MergeFrom(engine.DeepCopy())
+	if engine.Status == nil {
+		engine.Status = &wafv1alpha1.EngineStatus{}
+	}
+	engine.Status.ActiveRuleSet = active
+	if err := r.Status().Patch(ctx, engine, patch); err != nil {
+		logAPIError(log, req, "Engine", err, "Failed to patch active RuleSet", engine)
+		return err
+	}
+
+	switch {
+	case active != engine.Spec.RuleSet.Name:
+		logInfo(log, req, "Engine", "RuleSet cannot be loaded; falling back", "ruleSet", engine.Spec.RuleSet.Name, "fallbackRuleSet", active)
+		r.Recorder.Eventf(engine, nil, "Warning", "FallbackRuleSetActive", "Reconcile",
+			"RuleSet %s cannot be loaded: loading the rules of the fallback RuleSet %s", engine.Spec.RuleSet.Name, active)
+	case previous != "" && previous == fallbackRuleSetName(engine):
+		logInfo(log, req, "Engine", "RuleSet can be loaded again; leaving fallback", "ruleSet", active, "fallbackRuleSet", previous)
+		r.Recorder.Eventf(engine, nil, "Normal", "RuleSetRestored", "Reconcile",
+			"RuleSet %s can be loaded again: no longer loading the rules of the fallback RuleSet %s", active, previous)
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/test/utils"
+)
+
+func TestEngineRuleSets(t *testing.T) {
+	engine := utils.NewTestEngine(utils.EngineOptions{Name: "waf", Namespace: "team-a", RuleSetName: "rules", GatewayName: "gateway"})
+	engine.Spec.FallbackRuleSet = &wafv1alpha1.RuleSetReference{Name: "emergency"}
+	assert.Equal(t, []string{"rules"}, engineRuleSets(engine), "fallback only with failurePolicy degrade")
+
+	engine.Spec.FailurePolicy = wafv1alpha1.FailurePolicyDegrade
+	assert.Equal(t, []string{"rules", "emergency"}, engineRuleSets(engine))
+	assert.Equal(t, "rules", activeRuleSetName(engine))
+
+	engine.Status = &wafv1alpha1.EngineStatus{ActiveRuleSet: "emergency"}
+	assert.Equal(t, "emergency", activeRuleSetName(engine))
+
+	engine.Spec.FallbackRuleSet.Name = "minimal"
+	assert.Equal(t, "rules", activeRuleSetName(engine), "a stale active RuleSet is ignored")
+}
+
+func TestRuleSetLoadable(t *testing.T) {
+	assert.False(t, ruleSetLoadable(nil))
+
+	ruleSet := utils.NewTestRuleSet(utils.RuleSetOptions{Name: "rules", Namespace: "team-a"})
+	assert.True(t, ruleSetLoadable(ruleSet), "not reconciled yet")
+
+	applyStatusConditionDegraded(&ruleSet.Status.Conditions, ruleSet.Generation, "InvalidRules", "invalid rules")
+	assert.False(t, ruleSetLoadable(ruleSet), "degraded before publishing a revision")
+
+	ruleSet.Status.Revision = &wafv1alpha1.RuleSetRevision{UUID: "0b6b8a61-3c3e-5b8e-9a53-3b1f2f7a9e10", PublishTime: metav1.Now()}
+	assert.True(t, ruleSetLoadable(ruleSet), "degraded, but serving its previous revision")
+}
+
+func TestReconcileActiveRuleSet(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+
+	tests := []struct {
+		name       string
+		policy     wafv1alpha1.FailurePolicy
+		ruleSet    func() *wafv1alpha1.RuleSet
+		active     string
+		wantActive string
+		wantEvent  string
+	}{
+		{
+			name:   "loadable",
+			policy: wafv1alpha1.FailurePolicyDegrade,
+			ruleSet: func() *wafv1alpha1.RuleSet {
+				return utils.NewTestRuleSet(utils.RuleSetOptions{Name: "rules", Namespace: "team-a"})
+			},
+			wantActive: "rules",
+		},
+		{
+			name:       "not found",
+			policy:     wafv1alpha1.FailurePolicyDegrade,
+			wantActive: "emergency",
+			wantEvent:  "FallbackRuleSetActive",
+		},
+		{
+			name:   "never loaded",
+			policy: wafv1alpha1.FailurePolicyDegrade,
+			ruleSet: func() *wafv1alpha1.RuleSet {
+				ruleSet := utils.NewTestRuleSet(utils.RuleSetOptions{Name: "rules", Namespace: "team-a"})
+				applyStatusConditionDegraded(&ruleSet.Status.Conditions, ruleSet.Generation, "InvalidRules", "invalid rules")
+				return ruleSet
+			},
+			wantActive: "emergency",
+			wantEvent:  "FallbackRuleSetActive",
+		},
+		{
+			name:       "still falling back",
+			policy:     wafv1alpha1.FailurePolicyDegrade,
+			active:     "emergency",
+			wantActive: "emergency",
+		},
+		{
+			name:   "restored",
+			policy: wafv1alpha1.FailurePolicyDegrade,
+			ruleSet: func() *wafv1alpha1.RuleSet {
+				return utils.NewTestRuleSet(utils.RuleSetOptions{Name: "rules", Namespace: "team-a"})
+			},
+			active:     "emergency",
+			wantActive: "rules",
+			wantEvent:  "RuleSetRestored",
+		},
+		{
+			name:       "failurePolicy fail",
+			policy:     wafv1alpha1.FailurePolicyFail,
+			wantActive: "rules",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := utils.NewTestEngine(utils.EngineOptions{Name: "waf", Namespace: "team-a", RuleSetName: "rules", GatewayName: "gateway"})
+			engine.Spec.FailurePolicy = tt.policy
+			engine.Spec.FallbackRuleSet = &wafv1alpha1.RuleSetReference{Name: "emergency"}
+			engine.Status = &wafv1alpha1.EngineStatus{ActiveRuleSet: tt.active}
+
+			builder := fake.NewClientBuilder().WithScheme(scheme).WithObjects(engine).WithStatusSubresource(engine)
+			if tt.ruleSet != nil {
+				builder = builder.WithObjects(tt.ruleSet())
+			}
+			recorder := utils.NewFakeRecorder()
+			r := &EngineReconciler{Client: builder.Build(), Scheme: scheme, Recorder: recorder}
+			req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(engine)}
+
+			require.NoError(t, r.reconcileActiveRuleSet(t.Context(), ctrl.Log, req, engine))
+			assert.Equal(t, tt.wantActive, engine.Status.ActiveRuleSet)
+			assert.Equal(t, tt.wantActive, activeRuleSetName(engine))
+
+			var got wafv1alpha1.Engine
+			require.NoError(t, r.Get(t.Context(), req.NamespacedName, &got))
+			assert.Equal(t, tt.wantActive, got.Status.ActiveRuleSet)
+
+			var reasons []string
+			for _, e := range recorder.Events {
+				reasons = append(reasons, e.Reason)
+			}
+			if tt.wantEvent != "" {
+				assert.Equal(t, []string{tt.wantEvent}, reasons)
+			} else {
+				assert.Empty(t, reasons)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"path/filepath"
+	"testing"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/test/utils"
+)
+
+// TestEngineReconciler_WasmPluginGolden snapshots the WasmPlugin, including
+// its plugin config, generated for a matrix of Engine specs. Regenerate the
+// files in testdata/wasmplugin with -update-golden.
+func TestEngineReconciler_WasmPluginGolden(t *testing.T) {
+	const wasmURL = "oci://ghcr.io/networking-incubator/coraza-proxy-wasm:v0.1.0"
+
+	tests := []struct {
+		name          string
+		opts          utils.EngineOptions
+		mutate        func(*wafv1alpha1.Engine)
+		istioRevision string
+		cacheToken    string
+		listenerPort  int64
+	}{
+		{
+			name:       "defaults",
+			cacheToken: "token",
+		},
+		{
+			name:       "failure-policy-allow",
+			opts:       utils.EngineOptions{FailurePolicy: wafv1alpha1.FailurePolicyAllow},
+			cacheToken: "token",
+		},
+		{
+			name:       "image-pull-secret",
+			opts:       utils.EngineOptions{ImagePullSecret: "registry-credentials"},
+			cacheToken: "token",
+		},
+		{
+			name:       "poll-interval",
+			opts:       utils.EngineOptions{PollIntervalSeconds: 60},
+			cacheToken: "token",
+		},
+		{
+			name:   "no-cache-server",
+			mutate: func(e *wafv1alpha1.Engine) { e.Spec.RuleSetCacheServer = nil },
+		},
+		{
+			name: "response-inspection-headers",
+			mutate: func(e *wafv1alpha1.Engine) {
+				e.Spec.ResponseInspection = &wafv1alpha1.ResponseInspection{}
+			},
+			cacheToken: "token",
+		},
+		{
+			name: "response-inspection-body",
+			mutate: func(e *wafv1alpha1.Engine) {
+				e.Spec.ResponseInspection = &wafv1alpha1.ResponseInspection{
+					Body: &wafv1alpha1.ResponseBodyInspection{LimitBytes: 1048576, MIMETypes: []string{"application/json"}},
+				}
+			},
+			cacheToken: "token",
+		},
+		{
+			name: "learning",
+			mutate: func(e *wafv1alpha1.Engine) {
+				e.Spec.Learning = &wafv1alpha1.LearningConfig{DurationSeconds: 3600, MinMatches: 10}
+				e.Status = &wafv1alpha1.EngineStatus{Learning: &wafv1alpha1.LearningStatus{Phase: wafv1alpha1.LearningPhaseLearning}}
+			},
+			cacheToken: "token",
+		},
+		{
+			name: "detection-only",
+			mutate: func(e *wafv1alpha1.Engine) {
+				e.Spec.Mode = wafv1alpha1.EngineModeDetectionOnly
+			},
+			cacheToken: "token",
+		},
+		{
+			name: "redaction",
+			mutate: func(e *wafv1alpha1.Engine) {
+				e.Spec.Redaction = &wafv1alpha1.Redaction{
+					Headers:    []string{"X-Api-Key", "Authorization"},
+					Cookies:    []string{"session"},
+					BodyFields: []string{`^json\.password$`, "^card_number$"},
+				}
+			},
+			cacheToken: "token",
+		},
+		{
+			name: "verdict-metadata",
+			mutate: func(e *wafv1alpha1.Engine) {
+				e.Spec.VerdictMetadata = &wafv1alpha1.VerdictMetadata{Namespace: "waf.verdict"}
+			},
+			cacheToken: "token",
+		},
+		{
+			name: "request-correlation",
+			mutate: func(e *wafv1alpha1.Engine) {
+				e.Spec.RequestCorrelation = &wafv1alpha1.RequestCorrelation{Headers: []string{"X-B3-TraceId", "X-Request-ID"}}
+			},
+			cacheToken: "token",
+		},
+		{
+			name: "block-response",
+			mutate: func(e *wafv1alpha1.Engine) {
+				e.Spec.BlockResponse = &wafv1alpha1.BlockResponse{
+					Body:      "<p>Request {{request_id}} was blocked at {{timestamp}}.</p>",
+					Localized: []wafv1alpha1.LocalizedBlockResponse{{Language: "de-CH", Body: "<p>Anfrage {{ request_id }} wurde blockiert.</p>"}},
+				}
+			},
+			cacheToken: "token",
+		},
+		{
+			name: "rate-limit",
+			mutate: func(e *wafv1alpha1.Engine) {
+				e.Spec.RateLimit = &wafv1alpha1.RateLimit{Requests: 100, Key: wafv1alpha1.RateLimitKeyHeader, Header: "X-API-Key"}
+			},
+			cacheToken: "token",
+		},
+		{
+			name:         "listener-port",
+			listenerPort: 8443,
+			cacheToken:   "token",
+		},
+		{
+			name:          "istio-revision",
+			istioRevision: "canary",
+			cacheToken:    "token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Name = "waf"
+			tt.opts.Namespace = "team-a"
+			tt.opts.GatewayName = "gateway"
+			engine := utils.NewTestEngine(tt.opts)
+			if tt.mutate != nil {
+				tt.mutate(engine)
+			}
+
+			r := &EngineReconciler{
+				ruleSetCacheServerCluster: "outbound|80||coraza-operator.coraza-system.svc.cluster.local",
+				istioRevision:             tt.istioRevision,
+			}
+			wasmPlugin := r.buildWasmPlugin(engine, nil, wasmURL, tt.cacheToken)
+			scopeWasmPluginToPort(wasmPlugin, tt.listenerPort)
+
+			utils.AssertGoldenYAML(t, filepath.Join("testdata", "wasmplugin", tt.name+".yaml"), wasmPlugin.Object)
+		})
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// Engine Controller - Guardrails
+// -----------------------------------------------------------------------------
+
+// guardrailViolations returns why engine, which uses ruleSet, violates the
+// OperatorConfig Engine guardrails, or "" when it does not. The baseline
+// RuleSources are not checked while the RuleSet does not exist.
+func guardrailViolations(guardrails *wafv1alpha1.EngineGuardrails, engine *wafv1alpha1.Engine, ruleSet *wafv1alpha1.RuleSet) string {
+	if guardrails == nil {
+		return ""
+	}
+
+	var violations []string
+	if wasm := engine.Spec.Driver.Wasm; wasm != nil && wasm.Image != "" && len(guardrails.AllowedWasmImages) > 0 {
+		name := strings.TrimPrefix(wasm.Image, "oci://")
+		if !slices.ContainsFunc(guardrails.AllowedWasmImages, func(source string) bool { return imageFromSource(name, source) }) {
+			violations = append(violations, fmt.Sprintf("WASM plugin image %s is not from an allowed source (%s)",
+				wasm.Image, strings.Join(guardrails.AllowedWasmImages, ", ")))
+		}
+	}
+
+	if len(guardrails.AllowedFailurePolicies) > 0 {
+		policy := engine.Spec.FailurePolicy
+		if policy == "" {
+			policy = wafv1alpha1.FailurePolicyFail
+		}
+		if !slices.Contains(guardrails.AllowedFailurePolicies, policy) {
+			allowed := make([]string, 0, len(guardrails.AllowedFailurePolicies))
+			for _, p := range guardrails.AllowedFailurePolicies {
+				allowed = append(allowed, string(p))
+			}
+			violations = append(violations, fmt.Sprintf("failure policy %s is not allowed (%s)", policy, strings.Join(allowed, ", ")))
+		}
+	}
+
+	if ruleSet != nil {
+		var missing []string
+		for _, baseline := range guardrails.BaselineRuleSources {
+			namespace := referenceNamespace(ruleSet, baseline.Namespace)
+			if !slices.ContainsFunc(ruleSet.Spec.Sources, func(src wafv1alpha1.SourceReference) bool {
+				return isRuleSourceReference(src) && src.Name == baseline.Name && referenceNamespace(ruleSet, src.Namespace) == namespace
+			}) {
+				missing = append(missing, referenceName(ruleSet, namespace, baseline.Name))
+			}
+		}
+		if len(missing) > 0 {
+			violations = append(violations, fmt.Sprintf("RuleSet %s does not include the baseline RuleSources %s",
+				ruleSet.Name, strings.Join(missing, ", ")))
+		}
+	}
+
+	if len(violations) == 0 {
+		return ""
+	}
+	return "Engine violates the OperatorConfig guardrails: " + strings.Join(violations, "; ")
+}
@@ -0,0 +1,73 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/test/utils"
+)
+
+func TestGuardrailViolations(t *testing.T) {
+	guardrails := &wafv1alpha1.EngineGuardrails{
+		Namespaces:             []string{"team-a"},
+		AllowedWasmImages:      []string{"ghcr.io/networking-incubator"},
+		AllowedFailurePolicies: []wafv1alpha1.FailurePolicy{wafv1alpha1.FailurePolicyFail},
+		BaselineRuleSources:    []wafv1alpha1.BaselineRuleSource{{Namespace: "coraza-system", Name: "baseline"}, {Name: "team-rules"}},
+	}
+	ruleSet := utils.NewTestRuleSet(utils.RuleSetOptions{Name: "rules", Namespace: "team-a", Sources: []wafv1alpha1.SourceReference{
+		{Namespace: "coraza-system", Name: "baseline"},
+		{Name: "team-rules"},
+	}})
+	engine := func(image string, policy wafv1alpha1.FailurePolicy) *wafv1alpha1.Engine {
+		e := utils.NewTestEngine(utils.EngineOptions{Name: "waf", Namespace: "team-a", GatewayName: "gateway"})
+		e.Spec.Driver.Wasm.Image = image
+		e.Spec.FailurePolicy = policy
+		return e
+	}
+
+	assert.Empty(t, guardrailViolations(nil, engine("oci://example.com/wasm:v1", wafv1alpha1.FailurePolicyAllow), nil))
+	assert.Empty(t, guardrailViolations(guardrails, engine("oci://ghcr.io/networking-incubator/coraza-proxy-wasm:v1", ""), ruleSet))
+	assert.Empty(t, guardrailViolations(guardrails, engine("", wafv1alpha1.FailurePolicyFail), nil), "the default image is allowed")
+
+	assert.Equal(t, "Engine violates the OperatorConfig guardrails: "+
+		"WASM plugin image oci://ghcr.io/networking-incubator-fork/wasm:v1 is not from an allowed source (ghcr.io/networking-incubator); "+
+		"failure policy allow is not allowed (fail); "+
+		"RuleSet rules does not include the baseline RuleSources coraza-system/baseline",
+		guardrailViolations(guardrails, engine("oci://ghcr.io/networking-incubator-fork/wasm:v1", wafv1alpha1.FailurePolicyAllow),
+			utils.NewTestRuleSet(utils.RuleSetOptions{Name: "rules", Namespace: "team-a", Sources: []wafv1alpha1.SourceReference{
+				{Name: "baseline"},
+				{Namespace: "team-a", Name: "team-rules"},
+			}})))
+}
+
+func TestRuntimeConfig_EngineGuardrails(t *testing.T) {
+	c := NewRuntimeConfig()
+	assert.Nil(t, c.EngineGuardrails("team-a"))
+
+	c.apply(&wafv1alpha1.OperatorConfig{Spec: wafv1alpha1.OperatorConfigSpec{
+		EngineGuardrails: &wafv1alpha1.EngineGuardrails{
+			Namespaces:             []string{"team-a"},
+			AllowedFailurePolicies: []wafv1alpha1.FailurePolicy{wafv1alpha1.FailurePolicyFail},
+		},
+	}})
+	assert.NotNil(t, c.EngineGuardrails("team-a"))
+	assert.Nil(t, c.EngineGuardrails("team-b"), "guardrails only apply to the listed namespaces")
+}
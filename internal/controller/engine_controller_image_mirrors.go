@@ -0,0 +1,323 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// Engine Controller - Image Mirrors
+// -----------------------------------------------------------------------------
+
+// imageReferencePattern matches an OCI image reference with the oci://
+// scheme: a registry host with an optional port, a repository path, and an
+// optional tag and digest.
+var imageReferencePattern = regexp.MustCompile(`^oci://[a-zA-Z0-9.-]+(:[0-9]+)?(/[a-z0-9]+([._-][a-z0-9]+)*)+(:[a-zA-Z0-9_][a-zA-Z0-9_.-]{0,127})?(@sha256:[a-f0-9]{64})?$`)
+
+// mirrorImage rewrites the OCI reference ref with the mirror whose source is
+// the longest match, on whole path components, and reports whether one
+// applied.
+func mirrorImage(ref string, mirrors []wafv1alpha1.ImageMirror) (string, bool) {
+	name, ok := strings.CutPrefix(ref, "oci://")
+	if !ok {
+		return ref, false
+	}
+
+	var best *wafv1alpha1.ImageMirror
+	for i := range mirrors {
+		m := &mirrors[i]
+		if !imageFromSource(name, m.Source) {
+			continue
+		}
+		if best == nil || len(m.Source) > len(best.Source) {
+			best = m
+		}
+	}
+	if best == nil {
+		return ref, false
+	}
+	return "oci://" + best.Mirror + strings.TrimPrefix(name, best.Source), true
+}
+
+// imageFromSource reports whether the image reference name, without the
+// oci:// scheme, is under source, a registry host optionally followed by a
+// repository path, on whole path components.
+func imageFromSource(name, source string) bool {
+	rest, ok := strings.CutPrefix(name, source)
+	return ok && (rest == "" || strings.ContainsAny(rest[:1], "/:@"))
+}
+
+// validateMirroredImage checks that a rewritten reference is still a valid
+// OCI image reference that fits in an Engine image.
+func validateMirroredImage(ref string) error {
+	if len(ref) > wafv1alpha1.MaxImageLen {
+		return fmt.Errorf("mirrored image %q is longer than %d characters", ref, wafv1alpha1.MaxImageLen)
+	}
+	if !imageReferencePattern.MatchString(ref) {
+		return fmt.Errorf("mirrored image %q is not a valid OCI image reference", ref)
+	}
+	return nil
+}
+
+// ParseImageMirrors parses a comma-separated list of source=mirror pairs, as
+// accepted by --image-mirrors.
+func ParseImageMirrors(raw string) ([]wafv1alpha1.ImageMirror, error) {
+	var mirrors []wafv1alpha1.ImageMirror
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		source, mirror, ok := strings.Cut(pair, "=")
+		source, mirror = strings.TrimSpace(source), strings.TrimSpace(mirror)
+		if !ok || source == "" || mirror == "" {
+			return nil, fmt.Errorf("%q: must be source=mirror", pair)
+		}
+		for _, v := range []string{source, mirror} {
+			if !imageReferencePattern.MatchString("oci://" + v + "/x") {
+				return nil, fmt.Errorf("%q: %q is not a registry host and optional repository path", pair, v)
+			}
+		}
+		mirrors = append(mirrors, wafv1alpha1.ImageMirror{Source: source, Mirror: mirror})
+	}
+	return mirrors, nil
+}
+
+// -----------------------------------------------------------------------------
+// Engine Controller - Image Resolution
+// -----------------------------------------------------------------------------
+
+// errImageNotFound is returned by an imageResolver when the registry reports
+// that the image does not exist.
+var errImageNotFound = errors.New("image not found in registry")
+
+// imageResolver checks that an OCI image reference exists in its registry.
+type imageResolver interface {
+	resolve(ctx context.Context, ref string) error
+}
+
+// imageResolveTimeout bounds a registry lookup.
+const imageResolveTimeout = 10 * time.Second
+
+// imageResolveCacheTTL is how long the result of a registry lookup is reused.
+const imageResolveCacheTTL = 5 * time.Minute
+
+// maxManifestSize bounds the manifest or image index read from a registry.
+const maxManifestSize = 1 << 20
+
+// registryResolver looks up image manifests with the OCI distribution API.
+// It only authenticates anonymously: a registry that requires credentials
+// yields an error other than errImageNotFound, which callers treat as
+// unverified rather than missing.
+type registryResolver struct {
+	client *http.Client
+	now    func() time.Time
+
+	mu    sync.Mutex
+	cache map[string]registryLookup
+}
+
+type registryLookup struct {
+	platforms []string
+	err       error
+	expires   time.Time
+}
+
+// newRegistryResolver returns a registryResolver using client.
+func newRegistryResolver(client *http.Client) *registryResolver {
+	return &registryResolver{client: client, now: time.Now, cache: map[string]registryLookup{}}
+}
+
+func (r *registryResolver) resolve(ctx context.Context, ref string) error {
+	return r.cachedLookup(ctx, ref).err
+}
+
+func (r *registryResolver) platforms(ctx context.Context, ref string) ([]string, error) {
+	lookup := r.cachedLookup(ctx, ref)
+	return lookup.platforms, lookup.err
+}
+
+func (r *registryResolver) cachedLookup(ctx context.Context, ref string) registryLookup {
+	r.mu.Lock()
+	if lookup, ok := r.cache[ref]; ok && r.now().Before(lookup.expires) {
+		r.mu.Unlock()
+		return lookup
+	}
+	r.mu.Unlock()
+
+	platforms, err := r.lookup(ctx, ref)
+	lookup := registryLookup{platforms: platforms, err: err, expires: r.now().Add(imageResolveCacheTTL)}
+
+	r.mu.Lock()
+	r.cache[ref] = lookup
+	r.mu.Unlock()
+	return lookup
+}
+
+// lookup fetches the manifest of ref, retrying once with an anonymous bearer
+// token when the registry asks for one, and returns the platforms of its
+// image index.
+func (r *registryResolver) lookup(ctx context.Context, ref string) ([]string, error) {
+	registry, repository, reference, err := splitImageReference(ref)
+	if err != nil {
+		return nil, err
+	}
+	manifestURL := fmt.Sprintf("https://%s/v2/%s/manifests/%s", registry, repository, reference)
+
+	resp, body, err := r.get(ctx, manifestURL, "")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		token, err := r.anonymousToken(ctx, resp.Header.Get("WWW-Authenticate"))
+		if err != nil {
+			return nil, err
+		}
+		if resp, body, err = r.get(ctx, manifestURL, token); err != nil {
+			return nil, err
+		}
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return indexPlatforms(resp.Header.Get("Content-Type"), body)
+	case http.StatusNotFound:
+		return nil, errImageNotFound
+	}
+	return nil, fmt.Errorf("registry %s answered %s", registry, resp.Status)
+}
+
+func (r *registryResolver) get(ctx context.Context, manifestURL, token string) (*http.Response, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, manifestURL, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", strings.Join([]string{
+		mediaTypeOCIIndex,
+		"application/vnd.oci.image.manifest.v1+json",
+		mediaTypeDockerManifestList,
+		"application/vnd.docker.distribution.manifest.v2+json",
+	}, ", "))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestSize))
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp, body, nil
+}
+
+// anonymousToken requests a token from the realm of a Bearer challenge.
+func (r *registryResolver) anonymousToken(ctx context.Context, challenge string) (string, error) {
+	params, ok := parseBearerChallenge(challenge)
+	if !ok || params["realm"] == "" {
+		return "", fmt.Errorf("registry requires unsupported authentication %q", challenge)
+	}
+	tokenURL, err := url.Parse(params["realm"])
+	if err != nil {
+		return "", fmt.Errorf("invalid token realm %q: %w", params["realm"], err)
+	}
+	query := tokenURL.Query()
+	for _, key := range []string{"service", "scope"} {
+		if params[key] != "" {
+			query.Set(key, params[key])
+		}
+	}
+	tokenURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, tokenURL.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("anonymous token request answered %s", resp.Status)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("decoding token response: %w", err)
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	return body.AccessToken, nil
+}
+
+// parseBearerChallenge parses the parameters of a WWW-Authenticate Bearer
+// challenge, such as realm="https://auth.example",service="registry".
+func parseBearerChallenge(challenge string) (map[string]string, bool) {
+	rest, ok := strings.CutPrefix(challenge, "Bearer ")
+	if !ok {
+		return nil, false
+	}
+	params := map[string]string{}
+	for _, part := range strings.Split(rest, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if ok {
+			params[key] = strings.Trim(value, `"`)
+		}
+	}
+	return params, true
+}
+
+// splitImageReference splits an oci:// reference into its registry,
+// repository and tag or digest. A reference without either uses "latest".
+func splitImageReference(ref string) (registry, repository, reference string, err error) {
+	name, ok := strings.CutPrefix(ref, "oci://")
+	if !ok {
+		return "", "", "", fmt.Errorf("image %q is not an oci:// reference", ref)
+	}
+	registry, repository, ok = strings.Cut(name, "/")
+	if !ok {
+		return "", "", "", fmt.Errorf("image %q has no repository", ref)
+	}
+
+	reference = "latest"
+	if repo, digest, ok := strings.Cut(repository, "@"); ok {
+		repository, reference = repo, digest
+	} else if i := strings.LastIndex(repository, ":"); i > strings.LastIndex(repository, "/") {
+		repository, reference = repository[:i], repository[i+1:]
+	}
+	return registry, repository, reference, nil
+}
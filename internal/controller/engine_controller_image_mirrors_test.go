@@ -0,0 +1,214 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+func TestMirrorImage(t *testing.T) {
+	mirrors := []wafv1alpha1.ImageMirror{
+		{Source: "ghcr.io", Mirror: "registry.internal/ghcr"},
+		{Source: "ghcr.io/networking-incubator", Mirror: "registry.internal/coraza"},
+	}
+
+	tests := []struct {
+		name     string
+		ref      string
+		want     string
+		mirrored bool
+	}{
+		{
+			name:     "longest source wins",
+			ref:      "oci://ghcr.io/networking-incubator/coraza-proxy-wasm:v1",
+			want:     "oci://registry.internal/coraza/coraza-proxy-wasm:v1",
+			mirrored: true,
+		},
+		{
+			name:     "registry-wide mirror",
+			ref:      "oci://ghcr.io/other/plugin@sha256:" + strings.Repeat("a", 64),
+			want:     "oci://registry.internal/ghcr/other/plugin@sha256:" + strings.Repeat("a", 64),
+			mirrored: true,
+		},
+		{
+			name: "matches whole path components only",
+			ref:  "oci://ghcr.io.example.com/plugin:v1",
+			want: "oci://ghcr.io.example.com/plugin:v1",
+		},
+		{
+			name: "unmatched registry",
+			ref:  "oci://quay.io/plugin:v1",
+			want: "oci://quay.io/plugin:v1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, mirrored := mirrorImage(tt.ref, mirrors)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.mirrored, mirrored)
+		})
+	}
+}
+
+func TestValidateMirroredImage(t *testing.T) {
+	assert.NoError(t, validateMirroredImage("oci://registry.internal:5000/coraza/coraza-proxy-wasm:v1"))
+	assert.Error(t, validateMirroredImage("oci://registry.internal/Coraza/wasm:v1"), "repository paths are lowercase")
+	assert.Error(t, validateMirroredImage("oci://registry.internal/"+strings.Repeat("a", wafv1alpha1.MaxImageLen)))
+}
+
+func TestParseImageMirrors(t *testing.T) {
+	got, err := ParseImageMirrors(" ghcr.io/networking-incubator=registry.internal/coraza , docker.io=mirror.internal:5000,")
+	require.NoError(t, err)
+	assert.Equal(t, []wafv1alpha1.ImageMirror{
+		{Source: "ghcr.io/networking-incubator", Mirror: "registry.internal/coraza"},
+		{Source: "docker.io", Mirror: "mirror.internal:5000"},
+	}, got)
+
+	got, err = ParseImageMirrors("")
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	_, err = ParseImageMirrors("ghcr.io")
+	assert.Error(t, err, "a pair needs a mirror")
+	_, err = ParseImageMirrors("oci://ghcr.io=registry.internal")
+	assert.Error(t, err, "the scheme is not part of a source")
+}
+
+func TestRegistryResolver(t *testing.T) {
+	var tokenRequests int
+	var srv *httptest.Server
+	srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			tokenRequests++
+			assert.Equal(t, "repository:coraza/wasm:pull", r.URL.Query().Get("scope"))
+			_, _ = fmt.Fprint(w, `{"token":"anonymous"}`)
+		case r.Header.Get("Authorization") != "Bearer anonymous":
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry",scope="repository:coraza/wasm:pull"`, srv.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+		case r.Method == http.MethodGet && r.URL.Path == "/v2/coraza/wasm/manifests/v1":
+			w.Header().Set("Content-Type", mediaTypeOCIIndex)
+			_, _ = fmt.Fprint(w, `{"manifests":[{"platform":{"os":"linux","architecture":"amd64"}}]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	registry := strings.TrimPrefix(srv.URL, "https://")
+	r := newRegistryResolver(srv.Client())
+
+	assert.NoError(t, r.resolve(t.Context(), "oci://"+registry+"/coraza/wasm:v1"))
+	assert.ErrorIs(t, r.resolve(t.Context(), "oci://"+registry+"/coraza/wasm:v2"), errImageNotFound)
+
+	platforms, err := r.platforms(t.Context(), "oci://"+registry+"/coraza/wasm:v1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"linux/amd64"}, platforms)
+
+	requests := tokenRequests
+	assert.NoError(t, r.resolve(t.Context(), "oci://"+registry+"/coraza/wasm:v1"))
+	assert.Equal(t, requests, tokenRequests, "lookups must be cached")
+}
+
+func TestSplitImageReference(t *testing.T) {
+	tests := []struct {
+		ref, registry, repository, reference string
+	}{
+		{"oci://ghcr.io/org/wasm:v1", "ghcr.io", "org/wasm", "v1"},
+		{"oci://localhost:5000/wasm", "localhost:5000", "wasm", "latest"},
+		{"oci://ghcr.io/org/wasm@sha256:abc", "ghcr.io", "org/wasm", "sha256:abc"},
+	}
+	for _, tt := range tests {
+		registry, repository, reference, err := splitImageReference(tt.ref)
+		require.NoError(t, err, tt.ref)
+		assert.Equal(t, []string{tt.registry, tt.repository, tt.reference}, []string{registry, repository, reference}, tt.ref)
+	}
+}
+
+type fakeImageResolver map[string]error
+
+func (f fakeImageResolver) resolve(_ context.Context, ref string) error {
+	return f[ref]
+}
+
+func TestEngineReconciler_WasmPluginImage(t *testing.T) {
+	engine := &wafv1alpha1.Engine{
+		ObjectMeta: metav1.ObjectMeta{Name: "waf", Namespace: "team-a"},
+		Spec: wafv1alpha1.EngineSpec{Driver: wafv1alpha1.DriverConfig{
+			Type: wafv1alpha1.DriverTypeWasm,
+			Wasm: &wafv1alpha1.WasmDriverConfig{Image: "oci://ghcr.io/org/wasm:v1"},
+		}},
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "waf", Namespace: "team-a"}}
+
+	t.Run("flag mirrors apply", func(t *testing.T) {
+		r := &EngineReconciler{imageMirrors: []wafv1alpha1.ImageMirror{{Source: "ghcr.io", Mirror: "registry.internal"}}}
+		url, _, err := r.wasmPluginImage(t.Context(), logr.Discard(), req, engine)
+		require.NoError(t, err)
+		assert.Equal(t, "oci://registry.internal/org/wasm:v1", url)
+	})
+
+	t.Run("OperatorConfig mirrors override flag mirrors", func(t *testing.T) {
+		runtimeConfig := NewRuntimeConfig()
+		runtimeConfig.apply(&wafv1alpha1.OperatorConfig{Spec: wafv1alpha1.OperatorConfigSpec{
+			ImageMirrors: []wafv1alpha1.ImageMirror{{Source: "ghcr.io/org", Mirror: "mirror.internal/org"}},
+		}})
+		r := &EngineReconciler{
+			imageMirrors:  []wafv1alpha1.ImageMirror{{Source: "ghcr.io", Mirror: "registry.internal"}},
+			runtimeConfig: runtimeConfig,
+		}
+		url, _, err := r.wasmPluginImage(t.Context(), logr.Discard(), req, engine)
+		require.NoError(t, err)
+		assert.Equal(t, "oci://mirror.internal/org/wasm:v1", url)
+	})
+
+	t.Run("missing mirrored image degrades", func(t *testing.T) {
+		r := &EngineReconciler{
+			imageMirrors:  []wafv1alpha1.ImageMirror{{Source: "ghcr.io", Mirror: "registry.internal"}},
+			imageResolver: fakeImageResolver{"oci://registry.internal/org/wasm:v1": errImageNotFound},
+		}
+		_, reason, err := r.wasmPluginImage(t.Context(), logr.Discard(), req, engine)
+		require.Error(t, err)
+		assert.Equal(t, "ImageNotFound", reason)
+	})
+
+	t.Run("unverifiable mirrored image is used", func(t *testing.T) {
+		r := &EngineReconciler{
+			imageMirrors:  []wafv1alpha1.ImageMirror{{Source: "ghcr.io", Mirror: "registry.internal"}},
+			imageResolver: fakeImageResolver{"oci://registry.internal/org/wasm:v1": errors.New("registry answered 401 Unauthorized")},
+		}
+		url, _, err := r.wasmPluginImage(t.Context(), logr.Discard(), req, engine)
+		require.NoError(t, err)
+		assert.Equal(t, "oci://registry.internal/org/wasm:v1", url)
+	})
+}
@@ -0,0 +1,176 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"slices"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// +kubebuilder:rbac:groups="",resources=nodes,verbs=get
+
+// -----------------------------------------------------------------------------
+// Engine Controller - Image Platforms
+// -----------------------------------------------------------------------------
+
+// Media types of multi-platform images.
+const (
+	mediaTypeOCIIndex           = "application/vnd.oci.image.index.v1+json"
+	mediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+)
+
+// errImagePlatformMissing is returned by checkImagePlatforms when the image
+// cannot run on the nodes of the gateway.
+var errImagePlatformMissing = errors.New("image lacks a needed platform")
+
+// platformResolver looks up the platforms an OCI image is built for.
+type platformResolver interface {
+	// platforms returns the os/architecture platforms of the image index
+	// of ref, or nil when ref is a single manifest.
+	platforms(ctx context.Context, ref string) ([]string, error)
+}
+
+// indexPlatforms returns the os/architecture platforms of an image index or
+// manifest list, or nil for any other manifest. Entries of an unknown
+// platform, such as attestations, are skipped.
+func indexPlatforms(contentType string, body []byte) ([]string, error) {
+	var index struct {
+		MediaType string `json:"mediaType"`
+		Manifests []struct {
+			Platform *struct {
+				OS           string `json:"os"`
+				Architecture string `json:"architecture"`
+			} `json:"platform"`
+		} `json:"manifests"`
+	}
+	if err := json.Unmarshal(body, &index); err != nil {
+		return nil, fmt.Errorf("decoding manifest: %w", err)
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType != mediaTypeOCIIndex && mediaType != mediaTypeDockerManifestList &&
+		index.MediaType != mediaTypeOCIIndex && index.MediaType != mediaTypeDockerManifestList {
+		return nil, nil
+	}
+
+	platforms := []string{}
+	for _, m := range index.Manifests {
+		if m.Platform == nil || m.Platform.OS == "unknown" || m.Platform.Architecture == "unknown" {
+			continue
+		}
+		platform := m.Platform.OS + "/" + m.Platform.Architecture
+		if !slices.Contains(platforms, platform) {
+			platforms = append(platforms, platform)
+		}
+	}
+	return platforms, nil
+}
+
+// missingArchitectures returns the architectures of archs that platforms
+// has no linux variant for. An image without linux variants, such as a
+// plain WASM module, runs on every architecture.
+func missingArchitectures(platforms, archs []string) []string {
+	var linux []string
+	for _, p := range platforms {
+		if arch, ok := strings.CutPrefix(p, "linux/"); ok {
+			linux = append(linux, arch)
+		}
+	}
+	if len(linux) == 0 {
+		return nil
+	}
+
+	var missing []string
+	for _, arch := range archs {
+		if !slices.Contains(linux, arch) {
+			missing = append(missing, arch)
+		}
+	}
+	return missing
+}
+
+// gatewayNodeArchitectures returns the sorted architectures of the nodes
+// running the gateway pods of engine. Pods that are not scheduled yet, and
+// nodes without the kubernetes.io/arch label, are skipped. Nodes are
+// cluster-scoped, so a namespace-scoped install may not be allowed to read
+// them; the error then says so, and the platforms are not verified.
+func (r *EngineReconciler) gatewayNodeArchitectures(ctx context.Context, engine *wafv1alpha1.Engine) ([]string, error) {
+	ws := targetLabelSelector(engine)
+	if ws == nil {
+		return nil, nil
+	}
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(engine.Namespace), client.MatchingLabels(ws.MatchLabels)); err != nil {
+		return nil, err
+	}
+
+	var nodes, archs []string
+	for _, pod := range pods.Items {
+		if pod.Spec.NodeName == "" || slices.Contains(nodes, pod.Spec.NodeName) {
+			continue
+		}
+		nodes = append(nodes, pod.Spec.NodeName)
+
+		node := &metav1.PartialObjectMetadata{}
+		node.SetGroupVersionKind(corev1.SchemeGroupVersion.WithKind("Node"))
+		if err := r.apiReader.Get(ctx, client.ObjectKey{Name: pod.Spec.NodeName}, node); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			if apierrors.IsForbidden(err) {
+				return nil, fmt.Errorf("not allowed to read the nodes of the gateway pods, so their architectures are not verified: %w", err)
+			}
+			return nil, err
+		}
+		if arch := node.Labels[corev1.LabelArchStable]; arch != "" && !slices.Contains(archs, arch) {
+			archs = append(archs, arch)
+		}
+	}
+	slices.Sort(archs)
+	return archs, nil
+}
+
+// checkImagePlatforms returns an error wrapping errImagePlatformMissing
+// when the image index of url lacks a linux variant for the architecture of
+// a node running the gateway pods of engine. Other errors mean the platforms
+// could not be verified.
+func (r *EngineReconciler) checkImagePlatforms(ctx context.Context, engine *wafv1alpha1.Engine, url string) error {
+	archs, err := r.gatewayNodeArchitectures(ctx, engine)
+	if err != nil || len(archs) == 0 {
+		return err
+	}
+	platforms, err := r.platformResolver.platforms(ctx, url)
+	if err != nil {
+		return err
+	}
+	if missing := missingArchitectures(platforms, archs); len(missing) > 0 {
+		return fmt.Errorf("%w: %s has no linux/%s variant, needed by the nodes running the pods of Gateway %s (image platforms: %s)",
+			errImagePlatformMissing, url, strings.Join(missing, ", linux/"), engine.Spec.Target.Name, strings.Join(platforms, ", "))
+	}
+	return nil
+}
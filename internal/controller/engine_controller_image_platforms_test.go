@@ -0,0 +1,179 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/test/utils"
+)
+
+func TestIndexPlatforms(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        []string
+	}{
+		{
+			name:        "OCI image index",
+			contentType: mediaTypeOCIIndex,
+			body: `{"manifests":[
+				{"platform":{"os":"linux","architecture":"amd64"}},
+				{"platform":{"os":"linux","architecture":"arm64","variant":"v8"}},
+				{"platform":{"os":"unknown","architecture":"unknown"}}
+			]}`,
+			want: []string{"linux/amd64", "linux/arm64"},
+		},
+		{
+			name: "manifest list identified by its body",
+			body: `{"mediaType":"` + mediaTypeDockerManifestList + `","manifests":[{"platform":{"os":"linux","architecture":"amd64"}}]}`,
+			want: []string{"linux/amd64"},
+		},
+		{
+			name:        "single manifest",
+			contentType: "application/vnd.oci.image.manifest.v1+json",
+			body:        `{"layers":[]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			platforms, err := indexPlatforms(tt.contentType, []byte(tt.body))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, platforms)
+		})
+	}
+
+	_, err := indexPlatforms(mediaTypeOCIIndex, []byte("not json"))
+	assert.Error(t, err)
+}
+
+func TestMissingArchitectures(t *testing.T) {
+	assert.Empty(t, missingArchitectures(nil, []string{"amd64", "arm64"}), "a single manifest fits every architecture")
+	assert.Empty(t, missingArchitectures([]string{"wasip1/wasm"}, []string{"arm64"}), "a WASM only index fits every architecture")
+	assert.Empty(t, missingArchitectures([]string{"linux/amd64", "linux/arm64"}, []string{"amd64", "arm64"}))
+	assert.Equal(t, []string{"arm64"}, missingArchitectures([]string{"linux/amd64"}, []string{"amd64", "arm64"}))
+}
+
+type fakePlatformResolver map[string][]string
+
+func (f fakePlatformResolver) platforms(_ context.Context, ref string) ([]string, error) {
+	platforms, ok := f[ref]
+	if !ok {
+		return nil, errors.New("registry answered 401 Unauthorized")
+	}
+	return platforms, nil
+}
+
+func TestEngineReconciler_CheckImagePlatforms(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	engine := utils.NewTestEngine(utils.EngineOptions{Name: "waf", Namespace: "team-a", GatewayName: "gateway"})
+	engine.Spec.Driver.Wasm.Image = "oci://ghcr.io/org/wasm:v1"
+	gatewayPod := func(name, node string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "team-a", Labels: map[string]string{gatewayNameLabel: "gateway"}},
+			Spec:       corev1.PodSpec{NodeName: node},
+		}
+	}
+	node := func(name, arch string) *corev1.Node {
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{corev1.LabelArchStable: arch}}}
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		gatewayPod("gateway-1", "node-a"),
+		gatewayPod("gateway-2", "node-b"),
+		gatewayPod("gateway-3", ""),
+		node("node-a", "amd64"),
+		node("node-b", "arm64"),
+		node("node-c", "s390x"),
+	).Build()
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(engine)}
+
+	archs, err := (&EngineReconciler{Client: c, apiReader: c}).gatewayNodeArchitectures(t.Context(), engine)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"amd64", "arm64"}, archs, "only the nodes running gateway pods count")
+
+	tests := []struct {
+		name       string
+		platforms  fakePlatformResolver
+		forbidNode bool
+		wantReason string
+	}{
+		{
+			name:      "every gateway architecture",
+			platforms: fakePlatformResolver{"oci://ghcr.io/org/wasm:v1": {"linux/amd64", "linux/arm64"}},
+		},
+		{
+			name:       "missing gateway architecture",
+			platforms:  fakePlatformResolver{"oci://ghcr.io/org/wasm:v1": {"linux/amd64"}},
+			wantReason: "ImagePlatformMissing",
+		},
+		{
+			name:      "single manifest",
+			platforms: fakePlatformResolver{"oci://ghcr.io/org/wasm:v1": nil},
+		},
+		{
+			name:      "unverifiable image is used",
+			platforms: fakePlatformResolver{},
+		},
+		{
+			name:       "unreadable nodes are not verified",
+			platforms:  fakePlatformResolver{"oci://ghcr.io/org/wasm:v1": {"linux/amd64"}},
+			forbidNode: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var apiReader client.Reader = c
+			if tt.forbidNode {
+				// Namespace-scoped installs may not be allowed to read Nodes.
+				apiReader = interceptor.NewClient(c, interceptor.Funcs{
+					Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+						return apierrors.NewForbidden(corev1.Resource("nodes"), key.Name, errors.New("not allowed"))
+					},
+				})
+			}
+			r := &EngineReconciler{Client: c, apiReader: apiReader, platformResolver: tt.platforms}
+			url, reason, err := r.wasmPluginImage(t.Context(), logr.Discard(), req, engine)
+			assert.Equal(t, tt.wantReason, reason)
+			if tt.wantReason != "" {
+				require.ErrorIs(t, err, errImagePlatformMissing)
+				assert.Contains(t, err.Error(), "has no linux/arm64 variant")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "oci://ghcr.io/org/wasm:v1", url)
+		})
+	}
+}
@@ -0,0 +1,106 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// Engine Controller - Inspection Bypass - Vars
+// -----------------------------------------------------------------------------
+
+// inspectionBypassRuleIDBase is the rule ID of the rule bypassing large
+// request bodies. The content type at index i uses the ID
+// inspectionBypassRuleIDBase+1+i, and the path at index i the ID
+// inspectionBypassPathRuleIDBase+i; RuleSources must not use IDs from this
+// range.
+const inspectionBypassRuleIDBase = 89700000
+
+// inspectionBypassPathRuleIDBase is the rule ID of the rule bypassing the
+// first path of the inspection bypass.
+const inspectionBypassPathRuleIDBase = inspectionBypassRuleIDBase + 100
+
+// -----------------------------------------------------------------------------
+// Engine Controller - Inspection Bypass
+// -----------------------------------------------------------------------------
+
+// inspectionBypassRules returns the SecRules turning the rule engine off for
+// the requests the Engine does not inspect, or an empty string when it
+// inspects every request. They are composed into the RuleSets of the Engine,
+// before their rules, and run in phase 1.
+func inspectionBypassRules(bypass *wafv1alpha1.InspectionBypass) string {
+	if bypass == nil {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("# Inspection bypass generated from the Engine spec.inspectionBypass\n")
+	if bypass.BodyLargerThanBytes != nil {
+		fmt.Fprintf(&b, "SecRule REQUEST_HEADERS:Content-Length \"@gt %d\" \"id:%d,phase:1,pass,nolog,t:none,ctl:ruleEngine=Off\"\n",
+			*bypass.BodyLargerThanBytes, inspectionBypassRuleIDBase)
+	}
+	for i, contentType := range bypass.ContentTypes {
+		fmt.Fprintf(&b, "SecRule REQUEST_HEADERS:Content-Type \"@rx %s\" \"id:%d,phase:1,pass,nolog,t:none,t:lowercase,ctl:ruleEngine=Off\"\n",
+			contentTypePattern(contentType), inspectionBypassRuleIDBase+1+i)
+	}
+	for i, path := range bypass.Paths {
+		variable, operator := "REQUEST_FILENAME", "@beginsWith "+path.Prefix
+		if path.Regex != "" {
+			operator = "@rx " + secRulePattern(path.Regex)
+		}
+		id := inspectionBypassPathRuleIDBase + i
+		if len(path.Methods) == 0 {
+			fmt.Fprintf(&b, "SecRule %s \"%s\" \"id:%d,phase:1,pass,nolog,t:none,ctl:ruleEngine=Off\"\n", variable, operator, id)
+			continue
+		}
+		fmt.Fprintf(&b, "SecRule %s \"%s\" \"id:%d,phase:1,pass,nolog,t:none,chain\"\n", variable, operator, id)
+		fmt.Fprintf(&b, "  SecRule REQUEST_METHOD \"@rx ^(?:%s)$\" \"ctl:ruleEngine=Off\"\n", strings.Join(path.Methods, "|"))
+	}
+	return b.String()
+}
+
+// validateInspectionBypass checks what the CRD schema cannot: that the path
+// regular expressions compile.
+func validateInspectionBypass(bypass *wafv1alpha1.InspectionBypass) error {
+	if bypass == nil {
+		return nil
+	}
+	for i, path := range bypass.Paths {
+		if path.Regex == "" {
+			continue
+		}
+		if _, err := regexp.Compile(path.Regex); err != nil {
+			return fmt.Errorf("inspectionBypass.paths[%d].regex: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// contentTypePattern returns the regular expression matching the
+// Content-Type header values of the media type contentType, which may end
+// with "/*".
+func contentTypePattern(contentType string) string {
+	if prefix, ok := strings.CutSuffix(contentType, "*"); ok {
+		return "^" + regexp.QuoteMeta(prefix)
+	}
+	return "^" + regexp.QuoteMeta(contentType) + `(?:\s*;|\s*$)`
+}
@@ -0,0 +1,119 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/corazawaf/coraza/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+func TestInspectionBypassRules(t *testing.T) {
+	assert.Empty(t, inspectionBypassRules(nil))
+
+	rules := inspectionBypassRules(&wafv1alpha1.InspectionBypass{
+		ContentTypes:        []string{"video/*", "application/vnd.ms+zip"},
+		BodyLargerThanBytes: new(int64(1048576)),
+	})
+	conf := coraza.NewWAFConfig().WithDirectives("SecRuleEngine On\n" + rules +
+		`SecRule REQUEST_URI "@contains attack" "id:1,phase:1,deny,status:403"`)
+	waf, err := coraza.NewWAF(conf)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		wantStatus int
+	}{
+		{name: "no body", wantStatus: 403},
+		{name: "small body", headers: map[string]string{"Content-Length": "1048576"}, wantStatus: 403},
+		{name: "large body", headers: map[string]string{"Content-Length": "1048577"}},
+		{name: "wildcard content type", headers: map[string]string{"Content-Type": "Video/MP4"}},
+		{name: "content type with parameters", headers: map[string]string{"Content-Type": "application/vnd.ms+zip; name=a.zip"}},
+		{name: "content type prefix", headers: map[string]string{"Content-Type": "application/vnd.ms+zipx"}, wantStatus: 403},
+		{name: "other content type", headers: map[string]string{"Content-Type": "application/json"}, wantStatus: 403},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := waf.NewTransaction()
+			defer func() { _ = tx.Close() }()
+			tx.ProcessURI("/attack", "POST", "HTTP/1.1")
+			for name, value := range tt.headers {
+				tx.AddRequestHeader(name, value)
+			}
+			interruption := tx.ProcessRequestHeaders()
+			if tt.wantStatus == 0 {
+				assert.Nil(t, interruption)
+				return
+			}
+			require.NotNil(t, interruption)
+			assert.Equal(t, tt.wantStatus, interruption.Status)
+		})
+	}
+}
+
+func TestInspectionBypassRules_Paths(t *testing.T) {
+	rules := inspectionBypassRules(&wafv1alpha1.InspectionBypass{
+		Paths: []wafv1alpha1.InspectionBypassPath{
+			{Prefix: "/healthz"},
+			{Regex: `^/api/v[0-9]+/uploads/`, Methods: []string{"POST", "PUT"}},
+		},
+	})
+	assert.Contains(t, rules, "id:89700100,")
+	conf := coraza.NewWAFConfig().WithDirectives("SecRuleEngine On\n" + rules +
+		`SecRule REQUEST_URI "@contains attack" "id:1,phase:1,deny,status:403"`)
+	waf, err := coraza.NewWAF(conf)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		method  string
+		uri     string
+		blocked bool
+	}{
+		{name: "prefix", method: "GET", uri: "/healthz/ready?attack"},
+		{name: "other path", method: "GET", uri: "/attack", blocked: true},
+		{name: "prefix in the query string", method: "GET", uri: "/attack?/healthz", blocked: true},
+		{name: "regex and method", method: "PUT", uri: "/api/v2/uploads/attack"},
+		{name: "regex and other method", method: "GET", uri: "/api/v2/uploads/attack", blocked: true},
+		{name: "method and other path", method: "POST", uri: "/api/uploads/attack", blocked: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := waf.NewTransaction()
+			defer func() { _ = tx.Close() }()
+			tx.ProcessURI(tt.uri, tt.method, "HTTP/1.1")
+			interruption := tx.ProcessRequestHeaders()
+			assert.Equal(t, tt.blocked, interruption != nil)
+		})
+	}
+}
+
+func TestValidateInspectionBypass(t *testing.T) {
+	assert.NoError(t, validateInspectionBypass(nil))
+	assert.NoError(t, validateInspectionBypass(&wafv1alpha1.InspectionBypass{
+		Paths: []wafv1alpha1.InspectionBypassPath{{Prefix: "/healthz"}, {Regex: `^/files/.+\.iso$`}},
+	}))
+	err := validateInspectionBypass(&wafv1alpha1.InspectionBypass{
+		Paths: []wafv1alpha1.InspectionBypassPath{{Prefix: "/healthz"}, {Regex: `^/files/(`}},
+	})
+	assert.ErrorContains(t, err, "inspectionBypass.paths[1].regex: ")
+}
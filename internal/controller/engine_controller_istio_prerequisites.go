@@ -28,6 +28,8 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
 )
 
 // -----------------------------------------------------------------------------
@@ -44,6 +46,7 @@ type IstioPrerequisites struct {
 	operatorName  string
 	namespace     string
 	istioRevision string
+	mutualTLS     bool
 }
 
 // NewIstioPrerequisites returns a new IstioPrerequisites runnable.
@@ -59,6 +62,13 @@ func NewIstioPrerequisites(c client.Client, reader client.Reader, operatorName,
 	}
 }
 
+// SetMutualTLS makes Envoy connect to the RuleSet cache server with Istio
+// mutual TLS, presenting the SVID of the gateway, for cache servers that
+// authenticate clients by SPIFFE ID. It must be called before Start.
+func (p *IstioPrerequisites) SetMutualTLS(enabled bool) {
+	p.mutualTLS = enabled
+}
+
 // Start applies the Istio ServiceEntry and DestinationRule for the
 // RuleSet cache server. It satisfies the manager.Runnable interface.
 //
@@ -86,8 +96,9 @@ func (p *IstioPrerequisites) apply(ctx context.Context, log logr.Logger) error {
 	resourceName := p.operatorName + "-ruleset-cache"
 
 	labels := map[string]string{
-		"app.kubernetes.io/name":     p.operatorName,
-		"app.kubernetes.io/instance": p.operatorName,
+		"app.kubernetes.io/name":           p.operatorName,
+		"app.kubernetes.io/instance":       p.operatorName,
+		wafv1alpha1.LabelExcludeFromBackup: "true",
 	}
 	if p.istioRevision != "" {
 		labels["istio.io/rev"] = p.istioRevision
@@ -145,11 +156,15 @@ func (p *IstioPrerequisites) buildServiceEntry(name, serviceFQDN string, labels
 }
 
 func (p *IstioPrerequisites) buildDestinationRule(name, serviceFQDN string, labels map[string]string, ownerRef metav1.OwnerReference) *unstructured.Unstructured {
+	tlsMode := "DISABLE"
+	if p.mutualTLS {
+		tlsMode = "ISTIO_MUTUAL"
+	}
 	return p.newIstioObject("DestinationRule", name, labels, ownerRef, map[string]any{
 		"host": serviceFQDN,
 		"trafficPolicy": map[string]any{
 			"tls": map[string]any{
-				"mode": "DISABLE",
+				"mode": tlsMode,
 			},
 		},
 	})
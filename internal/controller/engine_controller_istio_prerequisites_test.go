@@ -111,6 +111,14 @@ func TestBuildDestinationRule_Shape(t *testing.T) {
 	tls, _ := tp["tls"].(map[string]any)
 	require.NotNil(t, tls, "tls should be present")
 	assert.Equal(t, "DISABLE", tls["mode"])
+
+	t.Run("mutual TLS", func(t *testing.T) {
+		p.SetMutualTLS(true)
+		dr := p.buildDestinationRule("my-op-ruleset-cache", "my-op.test-ns.svc.cluster.local", labels, testOwnerRef())
+		tls, _, err := unstructured.NestedString(dr.Object, "spec", "trafficPolicy", "tls", "mode")
+		require.NoError(t, err)
+		assert.Equal(t, "ISTIO_MUTUAL", tls)
+	})
 }
 
 func TestNewIstioObject_IstioRevisionLabel(t *testing.T) {
@@ -0,0 +1,338 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	rcache "github.com/networking-incubator/coraza-kubernetes-operator/internal/rulesets/cache"
+)
+
+// +kubebuilder:rbac:groups=waf.k8s.coraza.io,resources=rulesources,verbs=create
+
+// -----------------------------------------------------------------------------
+// Engine Controller - Learning Vars
+// -----------------------------------------------------------------------------
+
+const (
+	// detectionRuleEngine is the rule engine mode of an Engine in
+	// DetectionOnly mode or learning: rules are evaluated and logged, but
+	// never block.
+	detectionRuleEngine = "DetectionOnly"
+
+	// learningReportIntervalSeconds is how often a learning Engine reports
+	// its rule matches to the cache server.
+	learningReportIntervalSeconds = 60
+
+	// learningMaxRuleMatches is the maximum number of rules whose matches
+	// are kept in the learning status; the least matching are dropped.
+	learningMaxRuleMatches = 256
+
+	// defaultLearningMinMatches is the minMatches of learning mode when it
+	// is not set.
+	defaultLearningMinMatches = 10
+)
+
+// learningExcludedRuleIDs are the ranges of rule IDs never proposed as
+// exclusions: removing the CRS blocking evaluation and reporting rules
+// would disable blocking altogether, and the operator-generated rules for
+// exemptions and threat feeds are configured through the RuleSet instead.
+var learningExcludedRuleIDs = [][2]int32{
+	{949000, 949999},
+	{959000, 959999},
+	{980000, 980999},
+	{exemptionRuleIDBase, 89999999},
+}
+
+// -----------------------------------------------------------------------------
+// Engine Controller - Learning
+// -----------------------------------------------------------------------------
+
+// learningActive reports whether the Engine is in the learning phase, in
+// which its rules only log and it reports their matches.
+func learningActive(engine *wafv1alpha1.Engine) bool {
+	return engine.Spec.Learning != nil &&
+		engine.Status != nil &&
+		engine.Status.Learning != nil &&
+		engine.Status.Learning.Phase == wafv1alpha1.LearningPhaseLearning
+}
+
+// enforcementMode returns whether the WasmPlugin of engine blocks the
+// requests matching its rules, or only logs them in DetectionOnly mode, as
+// set or scheduled, or while learning.
+func enforcementMode(engine *wafv1alpha1.Engine) wafv1alpha1.EnforcementMode {
+	if engineMode(engine) == wafv1alpha1.EngineModeDetectionOnly || learningActive(engine) {
+		return wafv1alpha1.EnforcementModeDetect
+	}
+	return wafv1alpha1.EnforcementModeBlock
+}
+
+// patchEnforcementMode records the enforcement mode of the provisioned
+// WasmPlugin in the Engine status.
+func (r *EngineReconciler) patchEnforcementMode(ctx context.Context, log logr.Logger, req ctrl.Request, engine *wafv1alpha1.Engine) error {
+	mode := enforcementMode(engine)
+	if engine.Status.EnforcementMode == mode {
+		return nil
+	}
+	patch := client.MergeFrom(engine.DeepCopy())
+	engine.Status.EnforcementMode = mode
+	if err := r.Status().Patch(ctx, engine, patch); err != nil {
+		logAPIError(log, req, "Engine", err, "Failed to patch enforcement mode", engine)
+		return err
+	}
+	logInfo(log, req, "Engine", "Enforcement mode changed", "enforcementMode", mode)
+	return nil
+}
+
+// learningDuration returns the duration of learning mode.
+func learningDuration(learning *wafv1alpha1.LearningConfig) time.Duration {
+	seconds := learning.DurationSeconds
+	if seconds == 0 {
+		seconds = 86400
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// reconcileLearning moves the Engine through learning mode: it starts
+// learning when spec.learning is set, generates the candidate exclusions
+// when the learning period ends, and clears the learning status when
+// spec.learning is removed. It returns how long until the learning period
+// ends, or zero when the Engine is not learning.
+func (r *EngineReconciler) reconcileLearning(ctx context.Context, log logr.Logger, req ctrl.Request, engine *wafv1alpha1.Engine) (time.Duration, error) {
+	learning := engine.Status.Learning
+
+	if engine.Spec.Learning == nil {
+		if learning == nil {
+			return 0, nil
+		}
+		logInfo(log, req, "Engine", "Learning mode disabled, clearing learning status")
+		patch := client.MergeFrom(engine.DeepCopy())
+		engine.Status.Learning = nil
+		if err := r.Status().Patch(ctx, engine, patch); err != nil {
+			logAPIError(log, req, "Engine", err, "Failed to clear learning status", engine)
+			return 0, err
+		}
+		return 0, nil
+	}
+
+	if learning == nil {
+		logInfo(log, req, "Engine", "Starting learning mode", "duration", learningDuration(engine.Spec.Learning))
+		patch := client.MergeFrom(engine.DeepCopy())
+		engine.Status.Learning = &wafv1alpha1.LearningStatus{
+			Phase:     wafv1alpha1.LearningPhaseLearning,
+			StartTime: metav1.Now(),
+		}
+		if err := r.Status().Patch(ctx, engine, patch); err != nil {
+			logAPIError(log, req, "Engine", err, "Failed to start learning", engine)
+			return 0, err
+		}
+		r.Recorder.Eventf(engine, nil, "Normal", "LearningStarted", "Learn", "Learning for %s: rules only log until %s",
+			learningDuration(engine.Spec.Learning), engine.Status.Learning.StartTime.Add(learningDuration(engine.Spec.Learning)).UTC().Format(time.RFC3339))
+		return learningDuration(engine.Spec.Learning), nil
+	}
+
+	if learning.Phase != wafv1alpha1.LearningPhaseLearning {
+		return 0, nil
+	}
+
+	if remaining := time.Until(learning.StartTime.Add(learningDuration(engine.Spec.Learning))); remaining > 0 {
+		return remaining, nil
+	}
+
+	return 0, r.completeLearning(ctx, log, req, engine)
+}
+
+// completeLearning generates the draft RuleSource with the candidate
+// exclusions and marks learning as completed, which enforces the rules
+// again.
+func (r *EngineReconciler) completeLearning(ctx context.Context, log logr.Logger, req ctrl.Request, engine *wafv1alpha1.Engine) error {
+	learning := engine.Status.Learning
+	now := metav1.Now()
+
+	minMatches := engine.Spec.Learning.MinMatches
+	if minMatches == 0 {
+		minMatches = defaultLearningMinMatches
+	}
+	candidates := learningCandidates(learning.RuleMatches, minMatches)
+
+	var name string
+	if len(candidates) > 0 {
+		name = learnedRuleSourceName(engine.Name, learning.StartTime.Time)
+		source := &wafv1alpha1.RuleSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: engine.Namespace,
+				Labels: map[string]string{
+					ManagedByLabel:                ManagedByValue,
+					"app.kubernetes.io/component": "learned-exclusions",
+					"app.kubernetes.io/instance":  engine.Name,
+				},
+				Annotations: map[string]string{
+					wafv1alpha1.AnnotationDraft: "true",
+				},
+			},
+			Spec: wafv1alpha1.RuleSourceSpec{
+				Rules: learnedExclusionRules(engine.Name, learning.StartTime.Time, now.Time, candidates),
+			},
+		}
+		// The RuleSource is deliberately not owned by the Engine: once
+		// approved, it must outlive the Engine that learned it.
+		if err := r.Create(ctx, source); err != nil && !apierrors.IsAlreadyExists(err) {
+			logAPIError(log, req, "Engine", err, "Failed to create the candidate exclusions RuleSource", engine)
+			return err
+		}
+	}
+
+	patch := client.MergeFrom(engine.DeepCopy())
+	engine.Status.Learning.Phase = wafv1alpha1.LearningPhaseCompleted
+	engine.Status.Learning.CompletionTime = &now
+	engine.Status.Learning.CandidateRuleSource = name
+	if err := r.Status().Patch(ctx, engine, patch); err != nil {
+		logAPIError(log, req, "Engine", err, "Failed to complete learning", engine)
+		return err
+	}
+
+	if name == "" {
+		logInfo(log, req, "Engine", "Learning completed without candidate exclusions", "minMatches", minMatches)
+		r.Recorder.Eventf(engine, nil, "Normal", "LearningCompleted", "Learn", "Learning completed: no rule matched at least %d times", minMatches)
+		return nil
+	}
+	logInfo(log, req, "Engine", "Learning completed", "ruleSource", name, "candidates", len(candidates))
+	r.Recorder.Eventf(engine, nil, "Normal", "LearningCompleted", "Learn", "Learning completed: %d candidate exclusions in draft RuleSource %s await approval", len(candidates), name)
+	return nil
+}
+
+// learningCandidates returns the rules that matched at least minMatches
+// times, by rule ID, leaving out the rules that are never excluded.
+func learningCandidates(matches []wafv1alpha1.RuleMatchCount, minMatches int32) []wafv1alpha1.RuleMatchCount {
+	var candidates []wafv1alpha1.RuleMatchCount
+	for _, m := range matches {
+		if m.Count < int64(minMatches) {
+			continue
+		}
+		if slices.ContainsFunc(learningExcludedRuleIDs, func(r [2]int32) bool { return m.RuleID >= r[0] && m.RuleID <= r[1] }) {
+			continue
+		}
+		candidates = append(candidates, m)
+	}
+	slices.SortFunc(candidates, func(a, b wafv1alpha1.RuleMatchCount) int { return cmp.Compare(a.RuleID, b.RuleID) })
+	return candidates
+}
+
+// learnedRuleSourceName returns the name of the draft RuleSource of the
+// Engine for the learning period started at start. Each period gets its own
+// RuleSource, so that learning again never overwrites approved exclusions.
+func learnedRuleSourceName(engineName string, start time.Time) string {
+	suffix := "-learned-" + strconv.FormatInt(start.Unix(), 10)
+	if len(engineName)+len(suffix) > 253 {
+		engineName = strings.TrimRight(engineName[:253-len(suffix)], "-.")
+	}
+	return engineName + suffix
+}
+
+// learnedExclusionRules returns the SecLang removing the candidate rules.
+func learnedExclusionRules(engineName string, start, end time.Time, candidates []wafv1alpha1.RuleMatchCount) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Candidate exclusions learned by Engine %s from %s to %s.\n", engineName, start.UTC().Format(time.RFC3339), end.UTC().Format(time.RFC3339))
+	b.WriteString("# Each rule matched traffic presumed legitimate. Review them, then remove\n")
+	fmt.Fprintf(&b, "# the %s annotation to approve.\n", wafv1alpha1.AnnotationDraft)
+	for _, c := range candidates {
+		fmt.Fprintf(&b, "# Rule %d matched %d times\n", c.RuleID, c.Count)
+		fmt.Fprintf(&b, "SecRuleRemoveById %d\n", c.RuleID)
+	}
+	return b.String()
+}
+
+// -----------------------------------------------------------------------------
+// Learning Reporter
+// -----------------------------------------------------------------------------
+
+// LearningReporter records the rule matches reported to the cache server by
+// Engines in learning mode in their status. It implements
+// rcache.MatchReporter.
+type LearningReporter struct {
+	client    client.Client
+	apiReader client.Reader
+}
+
+// NewLearningReporter returns a LearningReporter. Engines and their
+// ServiceAccounts are read with apiReader, so that the reports of every
+// replica are merged into the latest status.
+func NewLearningReporter(c client.Client, apiReader client.Reader) *LearningReporter {
+	return &LearningReporter{client: c, apiReader: apiReader}
+}
+
+// ReportMatches adds matches to the learning status of the Engine owning
+// the cache client ServiceAccount of caller. The report is rejected unless
+// that Engine uses the RuleSet of cacheKey and is learning.
+func (l *LearningReporter) ReportMatches(ctx context.Context, caller rcache.AuthResult, cacheKey string, matches map[int]int64) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		engine, err := cacheClientEngine(ctx, l.apiReader, caller, cacheKey, rcache.ErrMatchReportRejected)
+		if err != nil {
+			return err
+		}
+		if !learningActive(engine) {
+			return fmt.Errorf("%w: Engine %s/%s is not learning", rcache.ErrMatchReportRejected, engine.Namespace, engine.Name)
+		}
+
+		patch := client.MergeFromWithOptions(engine.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		engine.Status.Learning.RuleMatches = mergeRuleMatches(engine.Status.Learning.RuleMatches, matches)
+		return l.client.Status().Patch(ctx, engine, patch)
+	})
+}
+
+// mergeRuleMatches adds matches to counts and returns the result by rule
+// ID, keeping the learningMaxRuleMatches most matching rules.
+func mergeRuleMatches(counts []wafv1alpha1.RuleMatchCount, matches map[int]int64) []wafv1alpha1.RuleMatchCount {
+	merged := make(map[int32]int64, len(counts)+len(matches))
+	for _, c := range counts {
+		merged[c.RuleID] = c.Count
+	}
+	for id, n := range matches {
+		if id <= 0 || id > 1<<31-1 {
+			continue
+		}
+		merged[int32(id)] += n
+	}
+
+	result := make([]wafv1alpha1.RuleMatchCount, 0, len(merged))
+	for id, n := range merged {
+		result = append(result, wafv1alpha1.RuleMatchCount{RuleID: id, Count: n})
+	}
+	if len(result) > learningMaxRuleMatches {
+		slices.SortFunc(result, func(a, b wafv1alpha1.RuleMatchCount) int {
+			return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.RuleID, b.RuleID))
+		})
+		result = result[:learningMaxRuleMatches]
+	}
+	slices.SortFunc(result, func(a, b wafv1alpha1.RuleMatchCount) int { return cmp.Compare(a.RuleID, b.RuleID) })
+	return result
+}
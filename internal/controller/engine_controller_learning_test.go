@@ -0,0 +1,214 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/corazawaf/coraza/v3"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	rcache "github.com/networking-incubator/coraza-kubernetes-operator/internal/rulesets/cache"
+	"github.com/networking-incubator/coraza-kubernetes-operator/test/utils"
+)
+
+func TestMergeRuleMatches(t *testing.T) {
+	counts := []wafv1alpha1.RuleMatchCount{{RuleID: 920350, Count: 4}, {RuleID: 942100, Count: 1}}
+	got := mergeRuleMatches(counts, map[int]int64{942100: 2, 913100: 7, -1: 3})
+	assert.Equal(t, []wafv1alpha1.RuleMatchCount{
+		{RuleID: 913100, Count: 7},
+		{RuleID: 920350, Count: 4},
+		{RuleID: 942100, Count: 3},
+	}, got)
+
+	t.Run("least matching rules are dropped", func(t *testing.T) {
+		matches := make(map[int]int64, learningMaxRuleMatches+1)
+		for i := range learningMaxRuleMatches + 1 {
+			matches[100000+i] = int64(i + 1)
+		}
+		got := mergeRuleMatches(nil, matches)
+		require.Len(t, got, learningMaxRuleMatches)
+		assert.Equal(t, int32(100001), got[0].RuleID)
+	})
+}
+
+func TestLearningCandidates(t *testing.T) {
+	matches := []wafv1alpha1.RuleMatchCount{
+		{RuleID: 942100, Count: 25},
+		{RuleID: 920350, Count: 10},
+		{RuleID: 932160, Count: 9},
+		{RuleID: 949110, Count: 40},
+		{RuleID: 980170, Count: 40},
+		{RuleID: threatFeedRuleIDBase, Count: 40},
+	}
+	candidates := learningCandidates(matches, 10)
+	assert.Equal(t, []wafv1alpha1.RuleMatchCount{{RuleID: 920350, Count: 10}, {RuleID: 942100, Count: 25}}, candidates)
+
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	rules := learnedExclusionRules("waf", start, start.Add(time.Hour), candidates)
+	assert.Contains(t, rules, "SecRuleRemoveById 920350\n")
+	assert.Contains(t, rules, "# Rule 942100 matched 25 times\n")
+
+	t.Run("exclusions load after the rules they remove", func(t *testing.T) {
+		conf := coraza.NewWAFConfig().WithDirectives("SecRuleEngine On\n" +
+			`SecRule ARGS "@contains attack" "id:942100,phase:1,deny,status:403"` + "\n" + rules)
+		waf, err := coraza.NewWAF(conf)
+		require.NoError(t, err)
+		tx := waf.NewTransaction()
+		tx.ProcessURI("/?q=attack", "GET", "HTTP/1.1")
+		assert.Nil(t, tx.ProcessRequestHeaders())
+		_ = tx.Close()
+	})
+}
+
+func TestLearnedRuleSourceName(t *testing.T) {
+	start := time.Unix(1767225600, 0)
+	assert.Equal(t, "waf-learned-1767225600", learnedRuleSourceName("waf", start))
+
+	name := learnedRuleSourceName(strings.Repeat("a", 253), start)
+	assert.Len(t, name, 253)
+	assert.True(t, strings.HasSuffix(name, "-learned-1767225600"))
+}
+
+func newLearningTestEngine() *wafv1alpha1.Engine {
+	engine := utils.NewTestEngine(utils.EngineOptions{Name: "waf", Namespace: "team-a", GatewayName: "gateway"})
+	engine.UID = "engine-uid"
+	engine.Spec.Learning = &wafv1alpha1.LearningConfig{DurationSeconds: 3600, MinMatches: 5}
+	engine.Status = &wafv1alpha1.EngineStatus{}
+	return engine
+}
+
+func TestReconcileLearning(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+
+	engine := newLearningTestEngine()
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(engine).WithStatusSubresource(engine).Build()
+	r := &EngineReconciler{Client: c, Scheme: scheme, Recorder: utils.NewTestRecorder()}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "team-a", Name: "waf"}}
+
+	remaining, err := r.reconcileLearning(t.Context(), logr.Discard(), req, engine)
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, remaining)
+	assert.True(t, learningActive(engine))
+	assert.Equal(t, wafv1alpha1.EnforcementModeDetect, enforcementMode(engine))
+	require.NoError(t, r.patchEnforcementMode(t.Context(), logr.Discard(), req, engine))
+	assert.Equal(t, wafv1alpha1.EnforcementModeDetect, engine.Status.EnforcementMode)
+
+	t.Log("Recording matches and ending the learning period")
+	require.NoError(t, c.Get(t.Context(), req.NamespacedName, engine))
+	patch := client.MergeFrom(engine.DeepCopy())
+	engine.Status.Learning.StartTime = metav1.NewTime(time.Now().Add(-2 * time.Hour))
+	engine.Status.Learning.RuleMatches = []wafv1alpha1.RuleMatchCount{{RuleID: 942100, Count: 12}, {RuleID: 920350, Count: 2}}
+	require.NoError(t, c.Status().Patch(t.Context(), engine, patch))
+
+	remaining, err = r.reconcileLearning(t.Context(), logr.Discard(), req, engine)
+	require.NoError(t, err)
+	assert.Zero(t, remaining)
+	assert.False(t, learningActive(engine))
+	assert.Equal(t, wafv1alpha1.EnforcementModeBlock, enforcementMode(engine))
+	engine.Spec.Mode = wafv1alpha1.EngineModeDetectionOnly
+	assert.Equal(t, wafv1alpha1.EnforcementModeDetect, enforcementMode(engine), "DetectionOnly mode outlasts learning")
+	engine.Spec.Mode = ""
+
+	require.NoError(t, c.Get(t.Context(), req.NamespacedName, engine))
+	learning := engine.Status.Learning
+	require.NotNil(t, learning)
+	assert.Equal(t, wafv1alpha1.LearningPhaseCompleted, learning.Phase)
+	assert.NotNil(t, learning.CompletionTime)
+	require.NotEmpty(t, learning.CandidateRuleSource)
+
+	var source wafv1alpha1.RuleSource
+	require.NoError(t, c.Get(t.Context(), types.NamespacedName{Namespace: "team-a", Name: learning.CandidateRuleSource}, &source))
+	assert.Equal(t, "true", source.Annotations[wafv1alpha1.AnnotationDraft])
+	assert.Empty(t, source.OwnerReferences)
+	assert.Contains(t, source.Spec.Rules, "SecRuleRemoveById 942100\n")
+	assert.NotContains(t, source.Spec.Rules, "920350")
+
+	t.Log("Disabling learning mode")
+	engine.Spec.Learning = nil
+	_, err = r.reconcileLearning(t.Context(), logr.Discard(), req, engine)
+	require.NoError(t, err)
+	require.NoError(t, c.Get(t.Context(), req.NamespacedName, engine))
+	assert.Nil(t, engine.Status.Learning)
+}
+
+func TestLearningReporter(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	engine := newLearningTestEngine()
+	engine.Status.Learning = &wafv1alpha1.LearningStatus{Phase: wafv1alpha1.LearningPhaseLearning, StartTime: metav1.Now()}
+	idle := newLearningTestEngine()
+	idle.Name, idle.UID, idle.Spec.Learning = "idle", "idle-uid", nil
+
+	serviceAccount := func(name string, owner *wafv1alpha1.Engine) *corev1.ServiceAccount {
+		return &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "team-a",
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: wafv1alpha1.GroupVersion.String(),
+				Kind:       "Engine",
+				Name:       owner.Name,
+				UID:        owner.UID,
+				Controller: new(true),
+			}},
+		}}
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(engine, idle, serviceAccount("coraza-engine-abc", engine), serviceAccount("coraza-engine-def", idle)).
+		WithStatusSubresource(engine, idle).
+		Build()
+	reporter := NewLearningReporter(c, c)
+	cacheKey := "team-a/" + engine.Spec.RuleSet.Name
+
+	caller := rcache.AuthResult{Namespace: "team-a", Name: "coraza-engine-abc"}
+	require.NoError(t, reporter.ReportMatches(t.Context(), caller, cacheKey, map[int]int64{942100: 3}))
+	require.NoError(t, reporter.ReportMatches(t.Context(), caller, cacheKey, map[int]int64{942100: 2, 920350: 1}))
+
+	require.NoError(t, c.Get(t.Context(), client.ObjectKeyFromObject(engine), engine))
+	assert.Equal(t, []wafv1alpha1.RuleMatchCount{{RuleID: 920350, Count: 1}, {RuleID: 942100, Count: 5}}, engine.Status.Learning.RuleMatches)
+
+	tests := []struct {
+		name     string
+		caller   rcache.AuthResult
+		cacheKey string
+	}{
+		{name: "engine not learning", caller: rcache.AuthResult{Namespace: "team-a", Name: "coraza-engine-def"}, cacheKey: cacheKey},
+		{name: "other RuleSet", caller: caller, cacheKey: "team-a/other"},
+		{name: "unknown ServiceAccount", caller: rcache.AuthResult{Namespace: "team-a", Name: "default"}, cacheKey: cacheKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := reporter.ReportMatches(t.Context(), tt.caller, tt.cacheKey, map[int]int64{942100: 1})
+			assert.ErrorIs(t, err, rcache.ErrMatchReportRejected)
+		})
+	}
+}
@@ -2,13 +2,16 @@ package controller
 
 import (
 	"context"
+	"slices"
 
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
@@ -29,32 +32,68 @@ func (r *EngineReconciler) findEnginesForRuleSet(ctx context.Context, ruleSet cl
 	}
 
 	return collectRequests(engineList.Items, func(e *wafv1alpha1.Engine) bool {
-		return e.Spec.RuleSet.Name == ruleSet.GetName()
+		return slices.Contains(engineRuleSets(e), ruleSet.GetName())
 	})
 }
 
-// findEnginesForGateway maps a Gateway to the Engines in the same namespace
-// that target this specific Gateway by name. Uses the spec.target index.
-func (r *EngineReconciler) findEnginesForGateway(ctx context.Context, gateway client.Object) []reconcile.Request {
+// findEnginesUsingDefaultImage maps a change of the default WASM image to
+// every Engine that does not pin its own image.
+func (r *EngineReconciler) findEnginesUsingDefaultImage(ctx context.Context, _ client.Object) []reconcile.Request {
 	log := logf.FromContext(ctx)
 
 	var engineList wafv1alpha1.EngineList
-	if err := r.List(ctx, &engineList,
-		client.InNamespace(gateway.GetNamespace()),
-		client.MatchingFields{engineTargetIndex: engineTargetKey(wafv1alpha1.EngineTargetTypeGateway, gateway.GetName())},
-	); err != nil {
-		log.Error(err, "Engine: Failed to list Engines", "namespace", gateway.GetNamespace())
+	if err := r.List(ctx, &engineList); err != nil {
+		log.Error(err, "Engine: Failed to list Engines")
 		return nil
 	}
 
 	return collectRequests(engineList.Items, func(e *wafv1alpha1.Engine) bool {
-		// we collect all of the items, given we are already matching them using the index of 'engineTargetIndex'
-		return true
+		_, fromSpec := r.wasmPluginOCIURLSource(e)
+		return !fromSpec
 	})
 }
 
+// findAllEngines maps a change of the image mirrors to every Engine.
+func (r *EngineReconciler) findAllEngines(ctx context.Context, _ client.Object) []reconcile.Request {
+	log := logf.FromContext(ctx)
+
+	var engineList wafv1alpha1.EngineList
+	if err := r.List(ctx, &engineList); err != nil {
+		log.Error(err, "Engine: Failed to list Engines")
+		return nil
+	}
+
+	return collectRequests(engineList.Items, func(*wafv1alpha1.Engine) bool { return true })
+}
+
+// findEnginesForGateway maps a Gateway to the Engines in the same namespace
+// that target this specific Gateway by name, and to those with a target
+// selector, which may start or stop selecting it. Uses the spec.target index.
+// Every Gateway event also invalidates the cached target resolution.
+func (r *EngineReconciler) findEnginesForGateway(ctx context.Context, gateway client.Object) []reconcile.Request {
+	log := logf.FromContext(ctx)
+
+	r.targets.invalidate(types.NamespacedName{Name: gateway.GetName(), Namespace: gateway.GetNamespace()})
+
+	var requests []reconcile.Request
+	for _, name := range []string{gateway.GetName(), ""} {
+		var engineList wafv1alpha1.EngineList
+		if err := r.List(ctx, &engineList,
+			client.InNamespace(gateway.GetNamespace()),
+			client.MatchingFields{engineTargetIndex: engineTargetKey(wafv1alpha1.EngineTargetTypeGateway, name)},
+		); err != nil {
+			log.Error(err, "Engine: Failed to list Engines", "namespace", gateway.GetNamespace())
+			return nil
+		}
+
+		// we collect all of the items, given we are already matching them using the index of 'engineTargetIndex'
+		requests = append(requests, collectRequests(engineList.Items, func(e *wafv1alpha1.Engine) bool { return true })...)
+	}
+	return requests
+}
+
 // findCompetingEngines maps an Engine to all other Engines in the same
-// namespace that target the same Gateway. Called by competingEngineHandler on
+// namespace that target the same Gateway or ingress gateway. Called by competingEngineHandler on
 // create, delete, and generation-changing updates. Uses the spec.target index.
 func (r *EngineReconciler) findCompetingEngines(ctx context.Context, obj client.Object) []reconcile.Request {
 	log := logf.FromContext(ctx)
@@ -63,7 +102,7 @@ func (r *EngineReconciler) findCompetingEngines(ctx context.Context, obj client.
 	if !ok {
 		return nil
 	}
-	if !hasGatewayTarget(engine) {
+	if !hasTarget(engine) {
 		return nil
 	}
 
@@ -107,6 +146,27 @@ func (r *EngineReconciler) competingEngineHandler() handler.EventHandler {
 	}
 }
 
+// isGatewayPod reports whether a Pod carries a label workload selectors of
+// Engines match on.
+func isGatewayPod(pod client.Object) bool {
+	_, hasGWAPI := pod.GetLabels()[gatewayNameLabel]
+	_, hasIngressGateway := pod.GetLabels()[ingressGatewayLabel]
+	return hasGWAPI || hasIngressGateway
+}
+
+// gatewayPodPredicate filters Pod events down to gateway pods. Updates pass
+// when either the old or the new Pod is a gateway pod, so that an Engine
+// re-resolves its workload selector when the label of a gateway is removed;
+// the map func is called for both.
+func gatewayPodPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc:  func(e event.CreateEvent) bool { return isGatewayPod(e.Object) },
+		DeleteFunc:  func(e event.DeleteEvent) bool { return isGatewayPod(e.Object) },
+		UpdateFunc:  func(e event.UpdateEvent) bool { return isGatewayPod(e.ObjectOld) || isGatewayPod(e.ObjectNew) },
+		GenericFunc: func(e event.GenericEvent) bool { return isGatewayPod(e.Object) },
+	}
+}
+
 // findEnginesForPod maps a Pod to the Engines in the same namespace whose
 // workload selector matches the Pod's labels.
 func (r *EngineReconciler) findEnginesForPod(ctx context.Context, pod client.Object) []reconcile.Request {
@@ -233,9 +233,10 @@ func (r *EngineReconciler) buildNetworkPolicy(engine *wafv1alpha1.Engine) *netwo
 			GenerateName: NetworkPolicyGenerateName,
 			Namespace:    r.operatorNamespace,
 			Labels: map[string]string{
-				"app.kubernetes.io/managed-by":    "coraza-kubernetes-operator",
-				networkPolicyEngineLabelName:      engine.Name,
-				networkPolicyEngineLabelNamespace: engine.Namespace,
+				ManagedByLabel:                     ManagedByValue,
+				networkPolicyEngineLabelName:       engine.Name,
+				networkPolicyEngineLabelNamespace:  engine.Namespace,
+				wafv1alpha1.LabelExcludeFromBackup: "true",
 			},
 		},
 		Spec: networkingv1.NetworkPolicySpec{
@@ -0,0 +1,78 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// Namespace Quota
+// -----------------------------------------------------------------------------
+
+// quotaRecheckInterval is how often a resource over its namespace quota is
+// re-evaluated. Freeing quota, by deleting or shrinking other resources of
+// the namespace, does not trigger a reconcile of the resources waiting for it.
+const quotaRecheckInterval = time.Minute
+
+// isOverEngineQuota reports whether engine is not among the oldest Engines of
+// its namespace allowed by the namespace quota, and if so, why.
+func (r *EngineReconciler) isOverEngineQuota(ctx context.Context, log logr.Logger, req ctrl.Request, engine *wafv1alpha1.Engine) (bool, string, error) {
+	quota := r.runtimeConfig.NamespaceQuota()
+	if quota == nil || quota.MaxEngines == nil {
+		return false, "", nil
+	}
+	maxEngines := int(*quota.MaxEngines)
+
+	var engineList wafv1alpha1.EngineList
+	if err := r.List(ctx, &engineList, client.InNamespace(engine.Namespace)); err != nil {
+		logAPIError(log, req, "Engine", err, "Failed to list Engines for quota", engine)
+		return false, "", fmt.Errorf("failed to list Engines: %w", err)
+	}
+	if len(engineList.Items) <= maxEngines {
+		return false, "", nil
+	}
+
+	var candidates []wafv1alpha1.Engine
+	for i := range engineList.Items {
+		// Engines with a target selector only create other Engines.
+		if engineList.Items[i].DeletionTimestamp.IsZero() && !hasTargetSelector(&engineList.Items[i]) {
+			candidates = append(candidates, engineList.Items[i])
+		}
+	}
+	sortOldestFirst(candidates)
+
+	for i := range candidates {
+		if candidates[i].Name == engine.Name {
+			if i < maxEngines {
+				return false, "", nil
+			}
+			break
+		}
+	}
+
+	logInfo(log, req, "Engine", "Namespace Engine quota exceeded", "maxEngines", maxEngines)
+	return true, fmt.Sprintf("Namespace %q is limited to %d Engines by the OperatorConfig namespace quota", engine.Namespace, maxEngines), nil
+}
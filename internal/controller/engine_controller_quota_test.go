@@ -0,0 +1,70 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/test/utils"
+)
+
+func TestEngineReconciler_IsOverEngineQuota(t *testing.T) {
+	scheme := newFleetTestScheme(t)
+	created := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	engine := func(name string, age time.Duration) *wafv1alpha1.Engine {
+		e := utils.NewTestEngine(utils.EngineOptions{Name: name, Namespace: "team-a"})
+		e.CreationTimestamp = metav1.NewTime(created.Add(-age))
+		return e
+	}
+	oldest, middle, newest := engine("oldest", 2*time.Hour), engine("middle", time.Hour), engine("newest", 0)
+	other := utils.NewTestEngine(utils.EngineOptions{Name: "elsewhere", Namespace: "team-b"})
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(oldest, middle, newest, other).Build()
+	runtimeConfig := NewRuntimeConfig()
+	r := &EngineReconciler{Client: c, Scheme: scheme, runtimeConfig: runtimeConfig}
+
+	overQuota := func(e *wafv1alpha1.Engine) bool {
+		t.Helper()
+		over, _, err := r.isOverEngineQuota(t.Context(), ctrl.Log, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(e)}, e)
+		require.NoError(t, err)
+		return over
+	}
+
+	assert.False(t, overQuota(newest), "without a quota every Engine is accepted")
+
+	runtimeConfig.apply(&wafv1alpha1.OperatorConfig{Spec: wafv1alpha1.OperatorConfigSpec{
+		NamespaceQuota: &wafv1alpha1.NamespaceQuota{MaxEngines: ptr.To[int32](2)},
+	}})
+	assert.False(t, overQuota(oldest))
+	assert.False(t, overQuota(middle))
+	assert.True(t, overQuota(newest), "the newest Engines are over quota")
+	assert.False(t, overQuota(other), "the quota applies per namespace")
+
+	_, msg, err := r.isOverEngineQuota(t.Context(), ctrl.Log, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(newest)}, newest)
+	require.NoError(t, err)
+	assert.Equal(t, `Namespace "team-a" is limited to 2 Engines by the OperatorConfig namespace quota`, msg)
+}
@@ -0,0 +1,73 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"strings"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// Engine Controller - Rate Limit Vars
+// -----------------------------------------------------------------------------
+
+const (
+	// defaultRateLimitWindowSeconds is the windowSeconds of a rate limit
+	// when it is omitted.
+	defaultRateLimitWindowSeconds = 60
+
+	// rateLimitStatus is the status of the response to the requests over a
+	// rate limit.
+	rateLimitStatus = 429
+)
+
+// -----------------------------------------------------------------------------
+// Engine Controller - Rate Limit
+// -----------------------------------------------------------------------------
+
+// rateLimitConfig returns the pluginConfig of the rate limit of engine, or
+// nil when it has none. The rate limit is implemented by the WASM plugin, not
+// compiled into SecLang: Coraza accepts the initcol and expirevar actions of
+// persistent collections, but does not implement them.
+func rateLimitConfig(engine *wafv1alpha1.Engine) map[string]any {
+	limit := engine.Spec.RateLimit
+	if limit == nil {
+		return nil
+	}
+
+	window := limit.WindowSeconds
+	if window == 0 {
+		window = defaultRateLimitWindowSeconds
+	}
+	config := map[string]any{
+		"requests":       limit.Requests,
+		"window_seconds": window,
+		"key":            "client_ip",
+	}
+	if limit.Key == wafv1alpha1.RateLimitKeyHeader {
+		config["key"] = "header"
+		config["header"] = strings.ToLower(limit.Header)
+	}
+	if limit.Action == wafv1alpha1.RateLimitActionLog {
+		config["action"] = "log"
+	} else {
+		config["action"] = "deny"
+		config["status"] = rateLimitStatus
+	}
+	return config
+}
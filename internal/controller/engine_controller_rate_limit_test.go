@@ -0,0 +1,62 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+func TestRateLimitConfig(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit *wafv1alpha1.RateLimit
+		want  map[string]any
+	}{
+		{name: "unset"},
+		{
+			name:  "client address with defaults",
+			limit: &wafv1alpha1.RateLimit{Requests: 100},
+			want:  map[string]any{"requests": int32(100), "window_seconds": int32(60), "key": "client_ip", "action": "deny", "status": 429},
+		},
+		{
+			name: "header, logged",
+			limit: &wafv1alpha1.RateLimit{
+				Requests:      10,
+				WindowSeconds: 1,
+				Key:           wafv1alpha1.RateLimitKeyHeader,
+				Header:        "X-API-Key",
+				Action:        wafv1alpha1.RateLimitActionLog,
+			},
+			want: map[string]any{"requests": int32(10), "window_seconds": int32(1), "key": "header", "header": "x-api-key", "action": "log"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := &wafv1alpha1.Engine{Spec: wafv1alpha1.EngineSpec{RateLimit: tt.limit}}
+			got := rateLimitConfig(engine)
+			if tt.want == nil {
+				assert.Nil(t, got)
+				return
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
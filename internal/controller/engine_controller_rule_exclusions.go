@@ -0,0 +1,67 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// Engine Controller - Rule Exclusions - Vars
+// -----------------------------------------------------------------------------
+
+// ruleExclusionRuleIDBase is the rule ID of the rule applying the rule
+// exclusion at index 0. The exclusion at index i uses the ID
+// ruleExclusionRuleIDBase+i; RuleSources must not use IDs from this range.
+const ruleExclusionRuleIDBase = 89900000
+
+// -----------------------------------------------------------------------------
+// Engine Controller - Rule Exclusions
+// -----------------------------------------------------------------------------
+
+// ruleExclusionRules returns the SecRules removing the excluded rules, or
+// their targets, from every transaction of the Engine, or an empty string
+// when it excludes no rule. They are composed into the RuleSets of the
+// Engine, before their rules, and run in phase 1, so that they apply to the
+// rules of every phase.
+func ruleExclusionRules(exclusions []wafv1alpha1.RuleExclusion) string {
+	if len(exclusions) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("# Rule exclusions generated from the Engine spec.ruleExclusions\n")
+	for i, exclusion := range exclusions {
+		fmt.Fprintf(&b, "SecAction \"id:%d,phase:1,pass,nolog,%s\"\n", ruleExclusionRuleIDBase+i, ruleExclusionAction(exclusion))
+	}
+	return b.String()
+}
+
+// ruleExclusionAction returns the ctl action applying exclusion.
+func ruleExclusionAction(exclusion wafv1alpha1.RuleExclusion) string {
+	by, rule := "Id", fmt.Sprint(exclusion.RuleID)
+	if exclusion.Tag != "" {
+		by, rule = "Tag", exclusion.Tag
+	}
+	if exclusion.Target != "" {
+		return fmt.Sprintf("ctl:ruleRemoveTargetBy%s=%s;%s", by, rule, exclusion.Target)
+	}
+	return fmt.Sprintf("ctl:ruleRemoveBy%s=%s", by, rule)
+}
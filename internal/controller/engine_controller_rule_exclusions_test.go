@@ -0,0 +1,65 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/corazawaf/coraza/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+func TestRuleExclusionRules(t *testing.T) {
+	assert.Empty(t, ruleExclusionRules(nil))
+
+	tests := []struct {
+		name       string
+		exclusions []wafv1alpha1.RuleExclusion
+		uri        string
+		wantStatus int
+	}{
+		{name: "no exclusion", uri: "/?q=attack", wantStatus: 403},
+		{name: "rule removed", exclusions: []wafv1alpha1.RuleExclusion{{RuleID: 1}}, uri: "/?q=attack", wantStatus: 0},
+		{name: "other rule removed", exclusions: []wafv1alpha1.RuleExclusion{{RuleID: 2}}, uri: "/?q=attack", wantStatus: 403},
+		{name: "tag removed", exclusions: []wafv1alpha1.RuleExclusion{{Tag: "attack-test"}}, uri: "/?q=attack", wantStatus: 0},
+		{name: "target removed", exclusions: []wafv1alpha1.RuleExclusion{{RuleID: 1, Target: "ARGS:q"}}, uri: "/?q=attack", wantStatus: 0},
+		{name: "other target inspected", exclusions: []wafv1alpha1.RuleExclusion{{RuleID: 1, Target: "ARGS:q"}}, uri: "/?p=attack", wantStatus: 403},
+		{name: "target removed by tag", exclusions: []wafv1alpha1.RuleExclusion{{Tag: "attack-test", Target: "ARGS:q"}}, uri: "/?q=attack", wantStatus: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := coraza.NewWAFConfig().WithDirectives("SecRuleEngine On\n" + ruleExclusionRules(tt.exclusions) +
+				`SecRule ARGS "@contains attack" "id:1,phase:1,deny,status:403,tag:'attack-test'"`)
+			waf, err := coraza.NewWAF(conf)
+			require.NoError(t, err)
+
+			tx := waf.NewTransaction()
+			defer func() { _ = tx.Close() }()
+			tx.ProcessURI(tt.uri, "GET", "HTTP/1.1")
+			interruption := tx.ProcessRequestHeaders()
+			if tt.wantStatus == 0 {
+				assert.Nil(t, interruption)
+				return
+			}
+			require.NotNil(t, interruption)
+			assert.Equal(t, tt.wantStatus, interruption.Status)
+		})
+	}
+}
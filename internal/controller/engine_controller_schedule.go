@@ -0,0 +1,310 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// Engine Controller - Schedule Vars
+// -----------------------------------------------------------------------------
+
+// cronSearchYears bounds the search for the next start of a window, so that
+// an expression that never fires, such as "0 0 31 2 *", does not loop.
+const cronSearchYears = 5
+
+var (
+	cronMonthNames = map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}
+	cronDayNames = map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}
+)
+
+// -----------------------------------------------------------------------------
+// Engine Controller - Schedule Cron Expressions
+// -----------------------------------------------------------------------------
+
+// cronSchedule is a parsed cron expression of five fields, with one bit set
+// for each value a field matches.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+
+	// domStar and dowStar record whether the day of month and day of week
+	// fields are *: when both are restricted, a day matches either.
+	domStar, dowStar bool
+}
+
+// parseCron parses a standard cron expression of five fields.
+func parseCron(expr string) (*cronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron expression %q must have 5 fields, got %d", expr, len(fields))
+	}
+	var (
+		s   cronSchedule
+		err error
+	)
+	if s.minute, _, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("cron expression %q: minute: %w", expr, err)
+	}
+	if s.hour, _, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("cron expression %q: hour: %w", expr, err)
+	}
+	if s.dom, s.domStar, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("cron expression %q: day of month: %w", expr, err)
+	}
+	if s.month, _, err = parseCronField(fields[3], 1, 12, cronMonthNames); err != nil {
+		return nil, fmt.Errorf("cron expression %q: month: %w", expr, err)
+	}
+	if s.dow, s.dowStar, err = parseCronField(fields[4], 0, 7, cronDayNames); err != nil {
+		return nil, fmt.Errorf("cron expression %q: day of week: %w", expr, err)
+	}
+	// Sunday is both 0 and 7.
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return &s, nil
+}
+
+// parseCronField parses a cron field of comma-separated items, each *, a
+// value, or a range, optionally with a step, and returns the values it
+// matches and whether it is *.
+func parseCronField(field string, lo, hi int, names map[string]int) (uint64, bool, error) {
+	var bits uint64
+	for item := range strings.SplitSeq(field, ",") {
+		expr, stepExpr, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepExpr)
+			if err != nil || n < 1 {
+				return 0, false, fmt.Errorf("invalid step %q", stepExpr)
+			}
+			step = n
+		}
+
+		first, last := lo, hi
+		switch {
+		case expr == "*":
+		case strings.Contains(expr, "-"):
+			from, to, _ := strings.Cut(expr, "-")
+			var err error
+			if first, err = parseCronValue(from, lo, hi, names); err != nil {
+				return 0, false, err
+			}
+			if last, err = parseCronValue(to, lo, hi, names); err != nil {
+				return 0, false, err
+			}
+			if first > last {
+				return 0, false, fmt.Errorf("invalid range %q", expr)
+			}
+		default:
+			var err error
+			if first, err = parseCronValue(expr, lo, hi, names); err != nil {
+				return 0, false, err
+			}
+			// A value with a step, such as 5/15, runs to the end of the
+			// range.
+			if !hasStep {
+				last = first
+			}
+		}
+		for v := first; v <= last; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, field == "*", nil
+}
+
+// parseCronValue parses a value of a cron field, a number or a name.
+func parseCronValue(s string, lo, hi int, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < lo || v > hi {
+		return 0, fmt.Errorf("invalid value %q: must be from %d to %d", s, lo, hi)
+	}
+	return v, nil
+}
+
+// matchesDay reports whether s fires on the day of t.
+func (s *cronSchedule) matchesDay(t time.Time) bool {
+	dom := s.dom&(1<<t.Day()) != 0
+	dow := s.dow&(1<<int(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// next returns the first time after t at which s fires, in the location of
+// t, or the zero time when it does not fire within cronSearchYears.
+func (s *cronSchedule) next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Year() + cronSearchYears
+	for t.Year() <= limit {
+		switch {
+		case s.month&(1<<int(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !s.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case s.hour&(1<<t.Hour()) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case s.minute&(1<<t.Minute()) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// -----------------------------------------------------------------------------
+// Engine Controller - Schedule
+// -----------------------------------------------------------------------------
+
+// validateSchedule checks what the CRD schema cannot: that the time zone
+// and the cron expressions of the schedule are valid.
+func validateSchedule(schedule *wafv1alpha1.EngineSchedule) error {
+	if schedule == nil {
+		return nil
+	}
+	if _, err := time.LoadLocation(schedule.TimeZone); err != nil {
+		return fmt.Errorf("schedule.timeZone: %w", err)
+	}
+	for i, window := range schedule.Windows {
+		if _, err := parseCron(window.Cron); err != nil {
+			return fmt.Errorf("schedule.windows[%d].cron: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// scheduledMode returns the mode schedule selects at now, outside of its
+// windows mode, the index of the current window or -1, and when a window
+// next starts or ends.
+func scheduledMode(schedule *wafv1alpha1.EngineSchedule, mode wafv1alpha1.EngineMode, now time.Time) (wafv1alpha1.EngineMode, int, time.Time, error) {
+	loc, err := time.LoadLocation(schedule.TimeZone)
+	if err != nil {
+		return "", -1, time.Time{}, err
+	}
+	now = now.In(loc)
+
+	current := -1
+	var transition time.Time
+	earliest := func(t time.Time) {
+		if !t.IsZero() && (transition.IsZero() || t.Before(transition)) {
+			transition = t
+		}
+	}
+	for i, window := range schedule.Windows {
+		cron, err := parseCron(window.Cron)
+		if err != nil {
+			return "", -1, time.Time{}, err
+		}
+		duration := time.Duration(window.DurationSeconds) * time.Second
+
+		// The window is open when it last started less than its duration
+		// ago; a start within an open window extends it.
+		var start time.Time
+		for t := cron.next(now.Add(-duration)); !t.IsZero() && !t.After(now); t = cron.next(t) {
+			start = t
+		}
+		if !start.IsZero() {
+			earliest(start.Add(duration))
+			if current < 0 {
+				current = i
+			}
+		}
+		earliest(cron.next(now))
+	}
+
+	if current >= 0 {
+		mode = schedule.Windows[current].Mode
+	}
+	return mode, current, transition, nil
+}
+
+// engineMode returns the mode of engine: the mode its schedule selects
+// while it has one, or spec.mode.
+func engineMode(engine *wafv1alpha1.Engine) wafv1alpha1.EngineMode {
+	if engine.Spec.Schedule != nil && engine.Status != nil && engine.Status.Schedule != nil {
+		return engine.Status.Schedule.Mode
+	}
+	return cmp.Or(engine.Spec.Mode, wafv1alpha1.EngineModeEnforce)
+}
+
+// reconcileSchedule records in the status of engine the mode its schedule
+// selects, records an event when the mode changes, and clears the schedule
+// status when spec.schedule is removed. It returns how long until a window
+// next starts or ends, or zero when the Engine has no schedule. An invalid
+// schedule is left to provisioning to report.
+func (r *EngineReconciler) reconcileSchedule(ctx context.Context, log logr.Logger, req ctrl.Request, engine *wafv1alpha1.Engine) (time.Duration, error) {
+	var status *wafv1alpha1.ScheduleStatus
+	var remaining time.Duration
+	if schedule := engine.Spec.Schedule; schedule != nil {
+		mode, window, transition, err := scheduledMode(schedule, cmp.Or(engine.Spec.Mode, wafv1alpha1.EngineModeEnforce), time.Now())
+		if err != nil {
+			return 0, nil
+		}
+		status = &wafv1alpha1.ScheduleStatus{Mode: mode}
+		if window >= 0 {
+			status.Window = new(int32(window))
+		}
+		if !transition.IsZero() {
+			status.NextTransitionTime = new(metav1.NewTime(transition.Truncate(time.Second)))
+			remaining = max(time.Until(transition), time.Second)
+		}
+	}
+
+	previous := engine.Status.Schedule
+	if equality.Semantic.DeepEqual(previous, status) {
+		return remaining, nil
+	}
+	patch := client.MergeFrom(engine.DeepCopy())
+	engine.Status.Schedule = status
+	if err := r.Status().Patch(ctx, engine, patch); err != nil {
+		logAPIError(log, req, "Engine", err, "Failed to patch schedule status", engine)
+		return 0, err
+	}
+
+	if status != nil && (previous == nil || previous.Mode != status.Mode) {
+		logInfo(log, req, "Engine", "Schedule selected mode", "mode", status.Mode, "nextTransitionTime", status.NextTransitionTime)
+		if previous != nil {
+			r.Recorder.Eventf(engine, nil, "Normal", "ScheduledModeChanged", "Reconcile", "Schedule switched the Engine from %s to %s", previous.Mode, status.Mode)
+		}
+	}
+	return remaining, nil
+}
@@ -0,0 +1,188 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/test/utils"
+)
+
+func TestCronNext(t *testing.T) {
+	// A Wednesday.
+	from := time.Date(2026, 3, 4, 10, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{expr: "* * * * *", want: time.Date(2026, 3, 4, 10, 31, 0, 0, time.UTC)},
+		{expr: "*/15 * * * *", want: time.Date(2026, 3, 4, 10, 45, 0, 0, time.UTC)},
+		{expr: "0 9 * * 1-5", want: time.Date(2026, 3, 5, 9, 0, 0, 0, time.UTC)},
+		{expr: "0 18 * * mon-fri", want: time.Date(2026, 3, 4, 18, 0, 0, 0, time.UTC)},
+		{expr: "0 0 * * sat,sun", want: time.Date(2026, 3, 7, 0, 0, 0, 0, time.UTC)},
+		{expr: "0 0 * * 7", want: time.Date(2026, 3, 8, 0, 0, 0, 0, time.UTC)},
+		{expr: "30 2 1 * *", want: time.Date(2026, 4, 1, 2, 30, 0, 0, time.UTC)},
+		{expr: "0 0 1 jan *", want: time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{expr: "0 0 13 * 5", want: time.Date(2026, 3, 6, 0, 0, 0, 0, time.UTC)},
+		{expr: "0 0 31 2 *"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			cron, err := parseCron(tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, cron.next(from))
+		})
+	}
+}
+
+func TestValidateSchedule(t *testing.T) {
+	window := func(cron string) wafv1alpha1.ScheduleWindow {
+		return wafv1alpha1.ScheduleWindow{Cron: cron, DurationSeconds: 3600, Mode: wafv1alpha1.EngineModeDetectionOnly}
+	}
+	assert.NoError(t, validateSchedule(nil))
+	assert.NoError(t, validateSchedule(&wafv1alpha1.EngineSchedule{TimeZone: "Europe/Oslo", Windows: []wafv1alpha1.ScheduleWindow{window("0 9 * * MON-FRI")}}))
+	assert.ErrorContains(t, validateSchedule(&wafv1alpha1.EngineSchedule{TimeZone: "Mars/Olympus", Windows: []wafv1alpha1.ScheduleWindow{window("0 9 * * *")}}),
+		"schedule.timeZone")
+	assert.EqualError(t, validateSchedule(&wafv1alpha1.EngineSchedule{Windows: []wafv1alpha1.ScheduleWindow{window("0 9 * * *"), window("0 25 * * *")}}),
+		`schedule.windows[1].cron: cron expression "0 25 * * *": hour: invalid value "25": must be from 0 to 23`)
+	assert.EqualError(t, validateSchedule(&wafv1alpha1.EngineSchedule{Windows: []wafv1alpha1.ScheduleWindow{window("@daily now")}}),
+		`schedule.windows[0].cron: cron expression "@daily now" must have 5 fields, got 2`)
+	assert.Error(t, validateSchedule(&wafv1alpha1.EngineSchedule{Windows: []wafv1alpha1.ScheduleWindow{window("0 9-5 * * *")}}))
+	assert.Error(t, validateSchedule(&wafv1alpha1.EngineSchedule{Windows: []wafv1alpha1.ScheduleWindow{window("*/0 * * * *")}}))
+}
+
+func TestScheduledMode(t *testing.T) {
+	// DetectionOnly during business hours in Oslo, Enforce otherwise.
+	schedule := &wafv1alpha1.EngineSchedule{
+		TimeZone: "Europe/Oslo",
+		Windows: []wafv1alpha1.ScheduleWindow{
+			{Cron: "0 9 * * 1-5", DurationSeconds: 8 * 3600, Mode: wafv1alpha1.EngineModeDetectionOnly},
+			{Cron: "0 12 * * *", DurationSeconds: 3600, Mode: wafv1alpha1.EngineModeEnforce},
+		},
+	}
+	oslo, err := time.LoadLocation("Europe/Oslo")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		now            time.Time
+		wantMode       wafv1alpha1.EngineMode
+		wantWindow     int
+		wantTransition time.Time
+	}{
+		{
+			name:           "before the window",
+			now:            time.Date(2026, 3, 4, 8, 0, 0, 0, oslo),
+			wantMode:       wafv1alpha1.EngineModeEnforce,
+			wantWindow:     -1,
+			wantTransition: time.Date(2026, 3, 4, 9, 0, 0, 0, oslo),
+		},
+		{
+			name:           "within the window",
+			now:            time.Date(2026, 3, 4, 10, 0, 0, 0, oslo),
+			wantMode:       wafv1alpha1.EngineModeDetectionOnly,
+			wantWindow:     0,
+			wantTransition: time.Date(2026, 3, 4, 12, 0, 0, 0, oslo),
+		},
+		{
+			name:           "overlapping windows, the first applies",
+			now:            time.Date(2026, 3, 4, 12, 30, 0, 0, oslo),
+			wantMode:       wafv1alpha1.EngineModeDetectionOnly,
+			wantWindow:     0,
+			wantTransition: time.Date(2026, 3, 4, 13, 0, 0, 0, oslo),
+		},
+		{
+			name:           "window ends",
+			now:            time.Date(2026, 3, 4, 17, 0, 0, 0, oslo),
+			wantMode:       wafv1alpha1.EngineModeEnforce,
+			wantWindow:     -1,
+			wantTransition: time.Date(2026, 3, 5, 9, 0, 0, 0, oslo),
+		},
+		{
+			name:           "weekend",
+			now:            time.Date(2026, 3, 7, 12, 15, 0, 0, oslo),
+			wantMode:       wafv1alpha1.EngineModeEnforce,
+			wantWindow:     1,
+			wantTransition: time.Date(2026, 3, 7, 13, 0, 0, 0, oslo),
+		},
+		{
+			name:           "in UTC",
+			now:            time.Date(2026, 3, 4, 9, 30, 0, 0, time.UTC),
+			wantMode:       wafv1alpha1.EngineModeDetectionOnly,
+			wantWindow:     0,
+			wantTransition: time.Date(2026, 3, 4, 12, 0, 0, 0, oslo),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mode, window, transition, err := scheduledMode(schedule, wafv1alpha1.EngineModeEnforce, tt.now)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantMode, mode)
+			assert.Equal(t, tt.wantWindow, window)
+			assert.True(t, tt.wantTransition.Equal(transition), "transition at %s, want %s", transition, tt.wantTransition)
+		})
+	}
+}
+
+func TestReconcileSchedule(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+
+	engine := utils.NewTestEngine(utils.EngineOptions{Name: "waf", Namespace: "team-a", GatewayName: "gateway"})
+	engine.Spec.Schedule = &wafv1alpha1.EngineSchedule{Windows: []wafv1alpha1.ScheduleWindow{
+		// Always open: a window of a week starting every minute.
+		{Cron: "* * * * *", DurationSeconds: 604800, Mode: wafv1alpha1.EngineModeDetectionOnly},
+	}}
+	engine.Status = &wafv1alpha1.EngineStatus{Schedule: &wafv1alpha1.ScheduleStatus{Mode: wafv1alpha1.EngineModeEnforce}}
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(engine).WithStatusSubresource(engine).Build()
+	recorder := utils.NewFakeRecorder()
+	r := &EngineReconciler{Client: c, Scheme: scheme, Recorder: recorder}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(engine)}
+
+	remaining, err := r.reconcileSchedule(t.Context(), ctrl.Log, req, engine)
+	require.NoError(t, err)
+	assert.Greater(t, remaining, time.Duration(0))
+	assert.LessOrEqual(t, remaining, time.Minute)
+	require.NotNil(t, engine.Status.Schedule)
+	assert.Equal(t, wafv1alpha1.EngineModeDetectionOnly, engine.Status.Schedule.Mode)
+	assert.Equal(t, new(int32(0)), engine.Status.Schedule.Window)
+	assert.Equal(t, wafv1alpha1.EnforcementModeDetect, enforcementMode(engine))
+	require.Len(t, recorder.Events, 1)
+	assert.Equal(t, "ScheduledModeChanged", recorder.Events[0].Reason)
+
+	engine.Spec.Schedule = nil
+	remaining, err = r.reconcileSchedule(t.Context(), ctrl.Log, req, engine)
+	require.NoError(t, err)
+	assert.Zero(t, remaining)
+	assert.Nil(t, engine.Status.Schedule)
+	assert.Equal(t, wafv1alpha1.EnforcementModeBlock, enforcementMode(engine))
+
+	var got wafv1alpha1.Engine
+	require.NoError(t, c.Get(t.Context(), req.NamespacedName, &got))
+	assert.Nil(t, got.Status.Schedule)
+}
@@ -3,9 +3,12 @@ package controller
 import (
 	"context"
 	"fmt"
+	"slices"
 	"sort"
+	"time"
 
 	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -23,6 +26,32 @@ import (
 // Target Helpers
 // -----------------------------------------------------------------------------
 
+// gatewayGroup is the API group of the Gateway API.
+const gatewayGroup = "gateway.networking.k8s.io"
+
+// providerGatewayControllers are the GatewayClass controller names of the
+// Gateways each provider programs: for Istio, the upstream controllers and
+// that of OpenShift Service Mesh.
+var providerGatewayControllers = map[wafv1alpha1.EngineTargetProvider][]string{
+	wafv1alpha1.EngineTargetProviderIstio: {
+		"istio.io/gateway-controller",
+		"istio.io/unmanaged-gateway",
+		"openshift.io/gateway-controller/v1",
+	},
+}
+
+// Reasons of the Accepted condition of an Engine whose target Gateway is
+// rejected for its GatewayClass.
+const (
+	reasonUnsupportedGatewayClass = "UnsupportedGatewayClass"
+	reasonGatewayClassNotVerified = "GatewayClassNotVerified"
+)
+
+// gatewayClassRecheckInterval is how often an Engine whose GatewayClass the
+// operator is not allowed to read is checked again: GatewayClasses and RBAC
+// are not watched.
+const gatewayClassRecheckInterval = time.Minute
+
 // hasGatewayTarget reports whether the Engine targets a Gateway resource.
 func hasGatewayTarget(engine *wafv1alpha1.Engine) bool {
 	if engine == nil {
@@ -32,9 +61,25 @@ func hasGatewayTarget(engine *wafv1alpha1.Engine) bool {
 		engine.Spec.Target.Name != ""
 }
 
+// hasIngressGatewayTarget reports whether the Engine targets Istio ingress
+// gateway pods outside of the Gateway API.
+func hasIngressGatewayTarget(engine *wafv1alpha1.Engine) bool {
+	if engine == nil {
+		return false
+	}
+	return engine.Spec.Target.Type == wafv1alpha1.EngineTargetTypeIngressGateway &&
+		engine.Spec.Target.Name != ""
+}
+
+// hasTarget reports whether the Engine targets a Gateway or ingress gateway.
+func hasTarget(engine *wafv1alpha1.Engine) bool {
+	return hasGatewayTarget(engine) || hasIngressGatewayTarget(engine)
+}
+
 // targetLabelSelector returns the workload label selector derived from the
 // Engine's target reference. For Gateway targets, the GEP-1762
-// gateway.networking.k8s.io/gateway-name label is used.
+// gateway.networking.k8s.io/gateway-name label is used; for IngressGateway
+// targets, the istio label.
 //
 // Returns nil if the name is empty or not a valid DNS-1035 label,
 // preventing silent selector mismatches.
@@ -53,6 +98,16 @@ func targetLabelSelector(engine *wafv1alpha1.Engine) *metav1.LabelSelector {
 				gatewayNameLabel: name,
 			},
 		}
+	case wafv1alpha1.EngineTargetTypeIngressGateway:
+		name := engine.Spec.Target.Name
+		if name == "" || len(validation.IsDNS1035Label(name)) > 0 {
+			return nil
+		}
+		return &metav1.LabelSelector{
+			MatchLabels: map[string]string{
+				ingressGatewayLabel: name,
+			},
+		}
 	default:
 		return nil
 	}
@@ -66,6 +121,96 @@ func needsAcceptedUpdate(conditions []metav1.Condition, generation int64) bool {
 	return cond == nil || cond.Status != metav1.ConditionTrue || cond.ObservedGeneration != generation
 }
 
+// -----------------------------------------------------------------------------
+// Target Ancestor Status
+// -----------------------------------------------------------------------------
+
+// ancestorReasons maps the reasons of the Engine Accepted condition to the
+// Gateway API policy reasons of its ancestor status. Other reasons map to
+// "Invalid".
+var ancestorReasons = map[string]string{
+	"Accepted":               "Accepted",
+	"TargetConflict":         "Conflicted",
+	"TargetNotFound":         "TargetNotFound",
+	"GatewayAPINotInstalled": "TargetNotFound",
+}
+
+// applyEngineAncestors derives status.ancestors from the Accepted condition:
+// one entry for the target Gateway, whose Accepted condition carries the
+// Gateway API policy reason. The ancestors are cleared until the Engine has
+// an Accepted condition.
+func applyEngineAncestors(engine *wafv1alpha1.Engine) {
+	accepted := apimeta.FindStatusCondition(engine.Status.Conditions, conditionAccepted)
+	if accepted == nil || !hasGatewayTarget(engine) {
+		engine.Status.Ancestors = nil
+		return
+	}
+
+	ref := wafv1alpha1.AncestorReference{
+		Group:       gatewayGroup,
+		Kind:        string(wafv1alpha1.EngineTargetTypeGateway),
+		Namespace:   engine.Namespace,
+		Name:        engine.Spec.Target.Name,
+		SectionName: engine.Spec.Target.SectionName,
+	}
+	var conditions []metav1.Condition
+	for _, ancestor := range engine.Status.Ancestors {
+		if ancestor.AncestorRef == ref && ancestor.ControllerName == wafv1alpha1.EngineControllerName {
+			conditions = slices.Clone(ancestor.Conditions)
+		}
+	}
+
+	reason, ok := ancestorReasons[accepted.Reason]
+	if !ok {
+		reason = "Invalid"
+	}
+	apimeta.SetStatusCondition(&conditions, metav1.Condition{
+		Type:               conditionAccepted,
+		Status:             accepted.Status,
+		ObservedGeneration: accepted.ObservedGeneration,
+		Reason:             reason,
+		Message:            accepted.Message,
+	})
+	engine.Status.Ancestors = []wafv1alpha1.PolicyAncestorStatus{{
+		AncestorRef:    ref,
+		ControllerName: wafv1alpha1.EngineControllerName,
+		Conditions:     conditions,
+	}}
+}
+
+// ancestorsEqual reports whether two ancestor status lists are semantically
+// equal, ignoring condition transition times.
+func ancestorsEqual(a, b []wafv1alpha1.PolicyAncestorStatus) bool {
+	return slices.EqualFunc(a, b, func(x, y wafv1alpha1.PolicyAncestorStatus) bool {
+		return x.AncestorRef == y.AncestorRef &&
+			x.ControllerName == y.ControllerName &&
+			conditionsEqual(x.Conditions, y.Conditions)
+	})
+}
+
+// patchAccepted applies mutate to the Engine conditions, derives the ancestor
+// statuses from them, and patches the status subresource. Like
+// patchConditions, the API call is skipped when the status is unchanged.
+func (r *EngineReconciler) patchAccepted(ctx context.Context, log logr.Logger, req ctrl.Request, engine *wafv1alpha1.Engine, mutate func()) error {
+	patch := client.MergeFrom(engine.DeepCopy())
+	before := snapshotConditions(engine.Status.Conditions)
+	original := engine.Status.DeepCopy()
+	mutate()
+	applyEngineAncestors(engine)
+	if conditionsEqual(original.Conditions, engine.Status.Conditions) &&
+		ancestorsEqual(original.Ancestors, engine.Status.Ancestors) {
+		engine.Status = original
+		logDebug(log, req, "Engine", "Status unchanged, skipping patch")
+		return nil
+	}
+	if err := r.Status().Patch(ctx, engine, patch); err != nil {
+		logAPIError(log, req, "Engine", err, "Failed to patch status", engine)
+		return err
+	}
+	logConditionTransitions(log, req, "Engine", before, engine.Status.Conditions)
+	return nil
+}
+
 // -----------------------------------------------------------------------------
 // Target Rejection Cleanup
 // -----------------------------------------------------------------------------
@@ -78,7 +223,10 @@ func (r *EngineReconciler) rejectTarget(ctx context.Context, log logr.Logger, re
 	if err := r.cleanupNotAccepted(ctx, log, req, engine); err != nil {
 		return err
 	}
-	return patchNotAccepted(ctx, r.Status(), r.Recorder, log, req, "Engine", engine, &engine.Status.Conditions, engine.Generation, reason, message)
+	r.Recorder.Eventf(engine, nil, "Warning", reason, "Reconcile", truncateEventNote(message))
+	return r.patchAccepted(ctx, log, req, engine, func() {
+		applyStatusNotAccepted(&engine.Status.Conditions, engine.Generation, reason, message)
+	})
 }
 
 // cleanupNotAccepted removes child resources that were created when the Engine
@@ -86,12 +234,30 @@ func (r *EngineReconciler) rejectTarget(ctx context.Context, log logr.Logger, re
 // prevents stale WasmPlugins from enforcing rules for an Engine that is no
 // longer accepted due to TargetNotFound or TargetConflict.
 func (r *EngineReconciler) cleanupNotAccepted(ctx context.Context, log logr.Logger, req ctrl.Request, engine *wafv1alpha1.Engine) error {
+	// Without the WasmPlugin API there is no WasmPlugin to clean up.
+	if r.hasCapability(CapabilityWasmPlugin) {
+		if err := r.cleanupWasmPlugin(ctx, log, req, engine); err != nil {
+			return err
+		}
+	}
+
+	if err := r.cleanupNetworkPolicy(ctx, log, req); err != nil {
+		return err
+	}
+
+	for _, name := range engineRuleSets(engine) {
+		r.tokenStore.Delete(fmt.Sprintf("%s/%s/%s", engine.Namespace, engine.Name, name))
+	}
+	failingClosedEngines.DeleteLabelValues(engine.Namespace, engine.Name)
+	engineRevisionSkew.DeleteLabelValues(engine.Namespace, engine.Name)
+
+	return nil
+}
+
+// cleanupWasmPlugin deletes the WasmPlugin of a not-accepted Engine.
+func (r *EngineReconciler) cleanupWasmPlugin(ctx context.Context, log logr.Logger, req ctrl.Request, engine *wafv1alpha1.Engine) error {
 	wasmPlugin := &unstructured.Unstructured{}
-	wasmPlugin.SetGroupVersionKind(schema.GroupVersionKind{
-		Group:   "extensions.istio.io",
-		Version: "v1alpha1",
-		Kind:    "WasmPlugin",
-	})
+	wasmPlugin.SetGroupVersionKind(WasmPluginGVK)
 	wpName := wasmPluginName(engine.Name)
 	err := r.Get(ctx, types.NamespacedName{Name: wpName, Namespace: engine.Namespace}, wasmPlugin)
 	if err == nil {
@@ -104,14 +270,6 @@ func (r *EngineReconciler) cleanupNotAccepted(ctx context.Context, log logr.Logg
 		logAPIError(log, req, "Engine", err, "Failed to get WasmPlugin for cleanup", nil)
 		return err
 	}
-
-	if err := r.cleanupNetworkPolicy(ctx, log, req); err != nil {
-		return err
-	}
-
-	tokenKey := fmt.Sprintf("%s/%s/%s", engine.Namespace, engine.Name, engine.Spec.RuleSet.Name)
-	r.tokenStore.Delete(tokenKey)
-
 	return nil
 }
 
@@ -120,27 +278,39 @@ func (r *EngineReconciler) cleanupNotAccepted(ctx context.Context, log logr.Logg
 // -----------------------------------------------------------------------------
 
 // isTargetNotFound checks whether the Gateway referenced by spec.target.name
-// exists in the Engine's namespace. Returns true when the Gateway is not found.
-// On transient API errors it returns (false, err) so the caller can retry.
-// This function only detects the condition — it does not patch status.
+// exists in the Engine's namespace, or for IngressGateway targets whether any
+// pod of the ingress gateway runs there. Returns true when the target is not
+// found. On transient API errors it returns (false, err) so the caller can
+// retry. This function only detects the condition — it does not patch status.
 func (r *EngineReconciler) isTargetNotFound(ctx context.Context, log logr.Logger, req ctrl.Request, engine *wafv1alpha1.Engine) (bool, error) {
+	if hasIngressGatewayTarget(engine) {
+		return r.isIngressGatewayNotFound(ctx, log, req, engine)
+	}
 	if !hasGatewayTarget(engine) {
 		return false, nil
 	}
 
+	key := types.NamespacedName{
+		Name:      engine.Spec.Target.Name,
+		Namespace: engine.Namespace,
+	}
+	if exists, ok := r.targets.lookup(key); ok {
+		logDebug(log, req, "Engine", "Target Gateway resolved from cache", "gateway", key.Name, "exists", exists)
+		return !exists, nil
+	}
+
 	gw := &unstructured.Unstructured{}
 	gw.SetGroupVersionKind(schema.GroupVersionKind{
-		Group:   "gateway.networking.k8s.io",
+		Group:   gatewayGroup,
 		Version: "v1",
 		Kind:    "Gateway",
 	})
 
-	err := r.Get(ctx, types.NamespacedName{
-		Name:      engine.Spec.Target.Name,
-		Namespace: engine.Namespace,
-	}, gw)
+	epoch := r.targets.begin()
+	err := r.Get(ctx, key, gw)
 	if err != nil {
 		if apierrors.IsNotFound(err) {
+			r.targets.store(key, false, epoch)
 			logInfo(log, req, "Engine", "Target Gateway not found", "gateway", engine.Spec.Target.Name)
 			return true, nil
 		}
@@ -148,16 +318,140 @@ func (r *EngineReconciler) isTargetNotFound(ctx context.Context, log logr.Logger
 		return false, fmt.Errorf("failed to get Gateway %s/%s: %w", engine.Namespace, engine.Spec.Target.Name, err)
 	}
 
+	r.targets.store(key, true, epoch)
+	return false, nil
+}
+
+// targetListenerPort returns the port of the listener of the target Gateway
+// named by spec.target.sectionName, or 0 when the Engine is not scoped to a
+// listener. It returns false when the Gateway or the listener is not found.
+func (r *EngineReconciler) targetListenerPort(ctx context.Context, log logr.Logger, req ctrl.Request, engine *wafv1alpha1.Engine) (int64, bool, error) {
+	if !hasGatewayTarget(engine) || engine.Spec.Target.SectionName == "" {
+		return 0, true, nil
+	}
+
+	gw := &unstructured.Unstructured{}
+	gw.SetGroupVersionKind(schema.GroupVersionKind{
+		Group:   gatewayGroup,
+		Version: "v1",
+		Kind:    "Gateway",
+	})
+	key := types.NamespacedName{Name: engine.Spec.Target.Name, Namespace: engine.Namespace}
+	if err := r.Get(ctx, key, gw); err != nil {
+		if apierrors.IsNotFound(err) {
+			return 0, false, nil
+		}
+		logAPIError(log, req, "Engine", err, "Failed to get target Gateway", engine)
+		return 0, false, fmt.Errorf("failed to get Gateway %s/%s: %w", engine.Namespace, engine.Spec.Target.Name, err)
+	}
+
+	listeners, _, _ := unstructured.NestedSlice(gw.Object, "spec", "listeners")
+	for _, l := range listeners {
+		listener, ok := l.(map[string]any)
+		if !ok || listener["name"] != engine.Spec.Target.SectionName {
+			continue
+		}
+		port, _, _ := unstructured.NestedInt64(listener, "port")
+		return port, port > 0, nil
+	}
+	logInfo(log, req, "Engine", "Target listener not found", "gateway", engine.Spec.Target.Name, "sectionName", engine.Spec.Target.SectionName)
+	return 0, false, nil
+}
+
+// unsupportedGatewayClass returns the reason and the message rejecting the
+// target Gateway of the Engine for its GatewayClass, or empty strings when
+// the provider of the Engine programs it; see checkTargetGatewayClass.
+func (r *EngineReconciler) unsupportedGatewayClass(ctx context.Context, log logr.Logger, req ctrl.Request, engine *wafv1alpha1.Engine) (string, string, error) {
+	reason, msg, err := checkTargetGatewayClass(ctx, r.Client, engine)
+	if err != nil {
+		logAPIError(log, req, "Engine", err, "Failed to check the GatewayClass of the target Gateway", engine)
+		return "", "", err
+	}
+	if reason != "" {
+		logInfo(log, req, "Engine", "Target Gateway is not programmed by the provider", "reason", reason, "detail", msg)
+	}
+	return reason, msg, nil
+}
+
+// checkTargetGatewayClass returns the reason and the message rejecting the
+// target Gateway of engine when the controller of its GatewayClass is not
+// one of the provider of the Engine ("UnsupportedGatewayClass"), or when
+// the GatewayClass cannot be read because access is forbidden
+// ("GatewayClassNotVerified"), and empty strings otherwise. A Gateway that
+// does not exist yet, or whose GatewayClass does not exist, is given the
+// benefit of the doubt. Neither the GatewayClass of a Gateway nor the
+// controller of a GatewayClass can change, so the result needs no watch.
+func checkTargetGatewayClass(ctx context.Context, reader client.Reader, engine *wafv1alpha1.Engine) (string, string, error) {
+	provider := engine.Spec.Target.Provider
+	if provider == "" {
+		provider = wafv1alpha1.EngineTargetProviderIstio
+	}
+	controllers, ok := providerGatewayControllers[provider]
+	if !hasGatewayTarget(engine) || !ok {
+		return "", "", nil
+	}
+
+	gw := &unstructured.Unstructured{}
+	gw.SetGroupVersionKind(schema.GroupVersionKind{Group: gatewayGroup, Version: "v1", Kind: "Gateway"})
+	if err := reader.Get(ctx, types.NamespacedName{Name: engine.Spec.Target.Name, Namespace: engine.Namespace}, gw); err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", "", nil
+		}
+		return "", "", fmt.Errorf("failed to get Gateway %s/%s: %w", engine.Namespace, engine.Spec.Target.Name, err)
+	}
+	className, _, _ := unstructured.NestedString(gw.Object, "spec", "gatewayClassName")
+	if className == "" {
+		return "", "", nil
+	}
+
+	class := &unstructured.Unstructured{}
+	class.SetGroupVersionKind(schema.GroupVersionKind{Group: gatewayGroup, Version: "v1", Kind: "GatewayClass"})
+	if err := reader.Get(ctx, types.NamespacedName{Name: className}, class); err != nil {
+		switch {
+		case apierrors.IsNotFound(err):
+			return "", "", nil
+		case apierrors.IsForbidden(err):
+			return reasonGatewayClassNotVerified, fmt.Sprintf("GatewayClass %q of Gateway %q cannot be verified: the operator is not allowed to read GatewayClasses",
+				className, engine.Spec.Target.Name), nil
+		}
+		return "", "", fmt.Errorf("failed to get GatewayClass %s: %w", className, err)
+	}
+	controllerName, _, _ := unstructured.NestedString(class.Object, "spec", "controllerName")
+	if slices.Contains(controllers, controllerName) {
+		return "", "", nil
+	}
+	return reasonUnsupportedGatewayClass, fmt.Sprintf("Gateway %q has GatewayClass %q of controller %q, which the %s provider does not program",
+		engine.Spec.Target.Name, className, controllerName, provider), nil
+}
+
+// isIngressGatewayNotFound reports whether no pod of the ingress gateway
+// targeted by the Engine runs in its namespace. Ingress gateways have no
+// resource of their own to look up, so their pods stand for them; the pods
+// are watched, so the Engine is reconciled again when one is created.
+func (r *EngineReconciler) isIngressGatewayNotFound(ctx context.Context, log logr.Logger, req ctrl.Request, engine *wafv1alpha1.Engine) (bool, error) {
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods,
+		client.InNamespace(engine.Namespace),
+		client.MatchingLabels{ingressGatewayLabel: engine.Spec.Target.Name},
+		client.Limit(1),
+	); err != nil {
+		logAPIError(log, req, "Engine", err, "Failed to list ingress gateway pods", engine)
+		return false, fmt.Errorf("failed to list ingress gateway pods %s/%s: %w", engine.Namespace, engine.Spec.Target.Name, err)
+	}
+	if len(pods.Items) == 0 {
+		logInfo(log, req, "Engine", "Target ingress gateway not found", "ingressGateway", engine.Spec.Target.Name)
+		return true, nil
+	}
 	return false, nil
 }
 
 // hasTargetConflict checks whether another Engine in the same namespace already
-// targets the same Gateway. The oldest Engine wins (by creationTimestamp; ties
+// targets the same Gateway or ingress gateway. The oldest Engine wins (by creationTimestamp; ties
 // broken by lexicographic name). Returns (true, winnerName, nil) if this Engine
 // loses the conflict. This function only detects the condition — it does not
 // patch status.
 func (r *EngineReconciler) hasTargetConflict(ctx context.Context, log logr.Logger, req ctrl.Request, engine *wafv1alpha1.Engine) (bool, string, error) {
-	if !hasGatewayTarget(engine) {
+	if !hasTarget(engine) {
 		return false, "", nil
 	}
 
@@ -183,21 +477,117 @@ func (r *EngineReconciler) hasTargetConflict(ctx context.Context, log logr.Logge
 		return false, "", nil
 	}
 
-	// Sort: oldest first, tiebreak by name.
-	sort.Slice(candidates, func(i, j int) bool {
-		ti := candidates[i].CreationTimestamp.Time
-		tj := candidates[j].CreationTimestamp.Time
-		if !ti.Equal(tj) {
-			return ti.Before(tj)
-		}
-		return candidates[i].Name < candidates[j].Name
-	})
+	sortOldestFirst(candidates)
 
 	winnerName := candidates[0].Name
 	if winnerName == engine.Name {
 		return false, "", nil
 	}
 
-	logInfo(log, req, "Engine", "Target conflict detected", "winner", winnerName, "gateway", engine.Spec.Target.Name)
+	logInfo(log, req, "Engine", "Target conflict detected", "winner", winnerName, "target", engine.Spec.Target.Name)
 	return true, winnerName, nil
 }
+
+// sortOldestFirst sorts Engines by creation time, oldest first, breaking
+// ties by name.
+func sortOldestFirst(engines []wafv1alpha1.Engine) {
+	sort.Slice(engines, func(i, j int) bool {
+		ti := engineCreatedAt(&engines[i])
+		tj := engineCreatedAt(&engines[j])
+		if !ti.Equal(tj) {
+			return ti.Before(tj)
+		}
+		return engines[i].Name < engines[j].Name
+	})
+}
+
+// engineCreatedAt returns when the Engine was first created: its
+// AnnotationCreatedAt annotation, which survives backup and restore, or its
+// creation timestamp when the annotation is missing or invalid.
+func engineCreatedAt(engine *wafv1alpha1.Engine) time.Time {
+	if v, ok := engine.Annotations[wafv1alpha1.AnnotationCreatedAt]; ok {
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t
+		}
+	}
+	return engine.CreationTimestamp.Time
+}
+
+// ensureCreatedAtAnnotation records the creation timestamp of the Engine in
+// its AnnotationCreatedAt annotation, unless it is already set.
+func (r *EngineReconciler) ensureCreatedAtAnnotation(ctx context.Context, log logr.Logger, req ctrl.Request, engine *wafv1alpha1.Engine) error {
+	if _, ok := engine.Annotations[wafv1alpha1.AnnotationCreatedAt]; ok {
+		return nil
+	}
+
+	patch := client.MergeFrom(engine.DeepCopy())
+	if engine.Annotations == nil {
+		engine.Annotations = map[string]string{}
+	}
+	engine.Annotations[wafv1alpha1.AnnotationCreatedAt] = engine.CreationTimestamp.UTC().Format(time.RFC3339)
+	if err := r.Patch(ctx, engine, patch); err != nil {
+		logAPIError(log, req, "Engine", err, "Failed to record the creation time", engine)
+		return err
+	}
+	logDebug(log, req, "Engine", "Recorded the creation time")
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Target Workloads
+// -----------------------------------------------------------------------------
+
+// countSelectedWorkloads returns the number of running pods in the Engine's
+// namespace matching its workload selector, and how many of them are ready.
+// Terminating pods are not counted, since they are about to stop serving
+// traffic.
+func (r *EngineReconciler) countSelectedWorkloads(ctx context.Context, log logr.Logger, req ctrl.Request, engine *wafv1alpha1.Engine) (running, ready int, err error) {
+	selector := targetLabelSelector(engine)
+	if selector == nil {
+		return 0, 0, nil
+	}
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(engine.Namespace), client.MatchingLabels(selector.MatchLabels)); err != nil {
+		logAPIError(log, req, "Engine", err, "Failed to list gateway pods", engine)
+		return 0, 0, fmt.Errorf("failed to list gateway pods of %s/%s: %w", engine.Namespace, engine.Spec.Target.Name, err)
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.Phase != corev1.PodRunning || !pod.DeletionTimestamp.IsZero() {
+			continue
+		}
+		running++
+		if slices.ContainsFunc(pod.Status.Conditions, func(c corev1.PodCondition) bool {
+			return c.Type == corev1.PodReady && c.Status == corev1.ConditionTrue
+		}) {
+			ready++
+		}
+	}
+	return running, ready, nil
+}
+
+// patchWorkloadsSelected records in the WorkloadsSelected condition whether
+// the workload selector of the Engine matches a running gateway pod. The
+// WasmPlugin is kept when it matches none, so that the WAF applies as soon
+// as the gateway is deployed again; the gateway pods are watched, so the
+// selector is re-resolved whenever they are created, deleted or relabeled.
+func (r *EngineReconciler) patchWorkloadsSelected(ctx context.Context, log logr.Logger, req ctrl.Request, engine *wafv1alpha1.Engine) error {
+	running, _, err := r.countSelectedWorkloads(ctx, log, req, engine)
+	if err != nil {
+		return err
+	}
+
+	cond := apimeta.FindStatusCondition(engine.Status.Conditions, conditionWorkloadsSelected)
+	if running == 0 && (cond == nil || cond.Status == metav1.ConditionTrue) {
+		r.Recorder.Eventf(engine, nil, "Warning", "NoWorkloadsSelected", "Reconcile", "No running pod matches the workload selector of target %s", engine.Spec.Target.Name)
+	}
+	return patchConditions(ctx, r.Status(), log, req, "Engine", engine, &engine.Status.Conditions, func() {
+		if running == 0 {
+			setConditionFalse(&engine.Status.Conditions, engine.Generation, conditionWorkloadsSelected, "NoWorkloadsSelected",
+				fmt.Sprintf("No running pod matches the workload selector of target %s; the WAF applies once the gateway is deployed", engine.Spec.Target.Name))
+			return
+		}
+		setConditionTrue(&engine.Status.Conditions, engine.Generation, conditionWorkloadsSelected, "WorkloadsSelected",
+			fmt.Sprintf("The workload selector matches running pods of target %s", engine.Spec.Target.Name))
+	})
+}
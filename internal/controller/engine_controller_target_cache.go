@@ -0,0 +1,94 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// -----------------------------------------------------------------------------
+// Engine Controller - Target Resolution Cache
+// -----------------------------------------------------------------------------
+
+// targetCache remembers whether target Gateways exist so that steady-state
+// Engine reconciles don't issue a live API read for the Gateway every time.
+//
+// Entries are invalidated from the Gateway watch (see findEnginesForGateway),
+// which fires on every create, update and delete. To avoid caching a result
+// that an invalidation raced with, callers take an epoch with begin before
+// reading the Gateway and pass it to store; the result is discarded if any
+// invalidation happened in between.
+//
+// A nil *targetCache is valid and caches nothing; reconcilers that are not
+// wired to the Gateway watch (e.g. in tests) must not cache.
+type targetCache struct {
+	mu      sync.Mutex
+	epoch   uint64
+	entries map[types.NamespacedName]bool
+}
+
+func newTargetCache() *targetCache {
+	return &targetCache{entries: make(map[types.NamespacedName]bool)}
+}
+
+// lookup returns whether the Gateway exists and whether the answer is cached.
+func (c *targetCache) lookup(key types.NamespacedName) (exists, ok bool) {
+	if c == nil {
+		return false, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	exists, ok = c.entries[key]
+	return exists, ok
+}
+
+// begin returns the current invalidation epoch, to be passed to store.
+func (c *targetCache) begin() uint64 {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.epoch
+}
+
+// store records the existence of a Gateway unless an invalidation happened
+// since epoch was obtained from begin.
+func (c *targetCache) store(key types.NamespacedName, exists bool, epoch uint64) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.epoch != epoch {
+		return
+	}
+	c.entries[key] = exists
+}
+
+// invalidate drops the cached entry for a Gateway.
+func (c *targetCache) invalidate(key types.NamespacedName) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.epoch++
+	delete(c.entries, key)
+}
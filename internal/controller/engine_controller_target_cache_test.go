@@ -0,0 +1,68 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestTargetCache(t *testing.T) {
+	gw := types.NamespacedName{Name: "gw", Namespace: "ns"}
+
+	t.Run("nil cache caches nothing", func(t *testing.T) {
+		var c *targetCache
+		c.store(gw, true, c.begin())
+		c.invalidate(gw)
+		_, ok := c.lookup(gw)
+		assert.False(t, ok)
+	})
+
+	t.Run("stores positive and negative results", func(t *testing.T) {
+		c := newTargetCache()
+		other := types.NamespacedName{Name: "missing", Namespace: "ns"}
+		c.store(gw, true, c.begin())
+		c.store(other, false, c.begin())
+
+		exists, ok := c.lookup(gw)
+		assert.True(t, ok)
+		assert.True(t, exists)
+		exists, ok = c.lookup(other)
+		assert.True(t, ok)
+		assert.False(t, exists)
+	})
+
+	t.Run("invalidate drops the entry", func(t *testing.T) {
+		c := newTargetCache()
+		c.store(gw, true, c.begin())
+		c.invalidate(gw)
+		_, ok := c.lookup(gw)
+		assert.False(t, ok)
+	})
+
+	t.Run("result racing an invalidation is discarded", func(t *testing.T) {
+		c := newTargetCache()
+		epoch := c.begin()
+		// The Gateway is deleted while the reconciler's read is in flight.
+		c.invalidate(gw)
+		c.store(gw, true, epoch)
+		_, ok := c.lookup(gw)
+		assert.False(t, ok, "a stale read must not be cached")
+	})
+}
@@ -0,0 +1,290 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// Engine Controller - Target Selector - RBAC
+// -----------------------------------------------------------------------------
+
+// +kubebuilder:rbac:groups=waf.k8s.coraza.io,resources=engines,verbs=create;delete
+
+// -----------------------------------------------------------------------------
+// Engine Controller - Target Selector - Vars
+// -----------------------------------------------------------------------------
+
+const (
+	// maxSelectedTargets is the number of Gateways an Engine target selector
+	// selects at most, in name order.
+	maxSelectedTargets = 64
+
+	// maxSelectedEngineName bounds the names of the Engines created for
+	// selected Gateways, which are used as label values.
+	maxSelectedEngineName = 63
+)
+
+// hasTargetSelector reports whether the Engine selects its target Gateways by
+// label instead of name.
+func hasTargetSelector(engine *wafv1alpha1.Engine) bool {
+	if engine == nil {
+		return false
+	}
+	return engine.Spec.Target.Type == wafv1alpha1.EngineTargetTypeGateway &&
+		engine.Spec.Target.Selector != nil
+}
+
+// selectedEngineName returns the name of the Engine created by the Engine
+// named engineName for the Gateway named gatewayName. Names too long for a
+// label value are truncated and suffixed with a hash of both names.
+func selectedEngineName(engineName, gatewayName string) string {
+	name := engineName + "-" + gatewayName
+	if len(name) <= maxSelectedEngineName {
+		return name
+	}
+	sum := sha256.Sum256([]byte(engineName + "/" + gatewayName))
+	suffix := "-" + hex.EncodeToString(sum[:4])
+	return strings.TrimRight(name[:maxSelectedEngineName-len(suffix)], "-.") + suffix
+}
+
+// -----------------------------------------------------------------------------
+// Engine Controller - Target Selector
+// -----------------------------------------------------------------------------
+
+// reconcileTargetSelector fans an Engine with a target selector out to one
+// Engine per selected Gateway, deletes those of the Gateways it no longer
+// selects, and reports them in its status. The Engine itself is never
+// provisioned: each Engine it creates is accepted and provisioned on its own,
+// and reconciling it requeues the Engine that created it.
+func (r *EngineReconciler) reconcileTargetSelector(ctx context.Context, log logr.Logger, req ctrl.Request, engine *wafv1alpha1.Engine) (ctrl.Result, error) {
+	// An Engine that targeted a Gateway by name before gets no more traffic
+	// through its own WasmPlugin.
+	if err := r.cleanupNotAccepted(ctx, log, req, engine); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(engine.Spec.Target.Selector)
+	if err != nil {
+		return ctrl.Result{}, r.rejectTarget(ctx, log, req, engine, "InvalidSelector", fmt.Sprintf("Invalid target selector: %v", err))
+	}
+
+	gateways := &unstructured.UnstructuredList{}
+	gateways.SetGroupVersionKind(schema.GroupVersionKind{Group: gatewayGroup, Version: "v1", Kind: "GatewayList"})
+	if err := r.List(ctx, gateways, client.InNamespace(engine.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		logAPIError(log, req, "Engine", err, "Failed to list Gateways for target selector", engine)
+		return ctrl.Result{}, fmt.Errorf("failed to list Gateways in namespace %s: %w", engine.Namespace, err)
+	}
+	var names []string
+	for _, gateway := range gateways.Items {
+		if gateway.GetDeletionTimestamp().IsZero() {
+			names = append(names, gateway.GetName())
+		}
+	}
+	slices.Sort(names)
+	matched := len(names)
+	names = names[:min(matched, maxSelectedTargets)]
+
+	var engines wafv1alpha1.EngineList
+	if err := r.List(ctx, &engines, client.InNamespace(engine.Namespace)); err != nil {
+		logAPIError(log, req, "Engine", err, "Failed to list Engines for target selector", engine)
+		return ctrl.Result{}, fmt.Errorf("failed to list Engines: %w", err)
+	}
+	existing := make(map[string]*wafv1alpha1.Engine, len(engines.Items))
+	for i := range engines.Items {
+		existing[engines.Items[i].Name] = &engines.Items[i]
+	}
+
+	targets := make([]wafv1alpha1.SelectedTargetStatus, 0, len(names))
+	selected := make(map[string]bool, len(names))
+	for _, gateway := range names {
+		name := selectedEngineName(engine.Name, gateway)
+		selected[name] = true
+		target := wafv1alpha1.SelectedTargetStatus{Gateway: gateway, Engine: name}
+
+		child := existing[name]
+		if child != nil && !metav1.IsControlledBy(child, engine) {
+			target.Accepted = metav1.ConditionFalse
+			target.Ready = metav1.ConditionFalse
+			target.Message = fmt.Sprintf("Engine %q already exists and was not created by this Engine", name)
+			targets = append(targets, target)
+			continue
+		}
+		if err := r.applySelectedEngine(ctx, log, req, engine, name, gateway); err != nil {
+			return ctrl.Result{}, err
+		}
+		applySelectedTargetStatus(&target, child)
+		targets = append(targets, target)
+	}
+
+	for name, child := range existing {
+		if selected[name] || !metav1.IsControlledBy(child, engine) || !child.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if err := r.Delete(ctx, child); client.IgnoreNotFound(err) != nil {
+			logAPIError(log, req, "Engine", err, "Failed to delete Engine of a Gateway no longer selected", child)
+			return ctrl.Result{}, err
+		}
+		logInfo(log, req, "Engine", "Deleted Engine of a Gateway no longer selected", "engine", name)
+	}
+
+	return ctrl.Result{}, r.patchSelectedTargets(ctx, log, req, engine, targets, matched)
+}
+
+// applySelectedEngine creates or updates the Engine named name that targets
+// gateway with the spec of engine. The Engine carries the creation time of
+// engine, so that it wins or loses a conflict over gateway as engine would.
+func (r *EngineReconciler) applySelectedEngine(ctx context.Context, log logr.Logger, req ctrl.Request, engine *wafv1alpha1.Engine, name, gateway string) error {
+	spec := engine.Spec.DeepCopy()
+	spec.Target.Name = gateway
+	spec.Target.Selector = nil
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(spec)
+	if err != nil {
+		return err
+	}
+
+	child := &unstructured.Unstructured{Object: map[string]any{"spec": content}}
+	child.SetGroupVersionKind(wafv1alpha1.GroupVersion.WithKind("Engine"))
+	child.SetName(name)
+	child.SetNamespace(engine.Namespace)
+	child.SetLabels(map[string]string{
+		ManagedByLabel:                ManagedByValue,
+		"app.kubernetes.io/component": "selected-target",
+		"app.kubernetes.io/instance":  engine.Name,
+	})
+	child.SetAnnotations(map[string]string{
+		wafv1alpha1.AnnotationCreatedAt: engineCreatedAt(engine).UTC().Format(time.RFC3339),
+	})
+	if err := controllerutil.SetControllerReference(engine, child, r.Scheme); err != nil {
+		logError(log, req, "Engine", err, "Failed to set owner reference on Engine", "engine", name)
+		return err
+	}
+	if err := serverSideApply(ctx, r.Client, child); err != nil {
+		logAPIError(log, req, "Engine", err, "Failed to apply Engine of a selected Gateway", child)
+		return err
+	}
+	return nil
+}
+
+// applySelectedTargetStatus fills the status of target from the conditions of
+// the Engine created for it, when it has been observed.
+func applySelectedTargetStatus(target *wafv1alpha1.SelectedTargetStatus, child *wafv1alpha1.Engine) {
+	target.Accepted = metav1.ConditionUnknown
+	target.Ready = metav1.ConditionUnknown
+	if child == nil || child.Status == nil {
+		return
+	}
+	if accepted := apimeta.FindStatusCondition(child.Status.Conditions, conditionAccepted); accepted != nil {
+		target.Accepted = accepted.Status
+		if accepted.Status == metav1.ConditionFalse {
+			target.Message = accepted.Message
+		}
+	}
+	if ready := apimeta.FindStatusCondition(child.Status.Conditions, conditionReady); ready != nil {
+		target.Ready = ready.Status
+		if ready.Status != metav1.ConditionTrue && target.Message == "" {
+			target.Message = ready.Message
+		}
+	}
+}
+
+// patchSelectedTargets reports targets in the status of engine, which is
+// accepted when its selector matches a Gateway, Degraded when the Engine of a
+// selected Gateway is not accepted, and Ready when those of every selected
+// Gateway are. The status the Engine reported while it targeted a Gateway by
+// name is cleared. Like patchConditions, the API call is skipped when the
+// status is unchanged.
+func (r *EngineReconciler) patchSelectedTargets(ctx context.Context, log logr.Logger, req ctrl.Request, engine *wafv1alpha1.Engine, targets []wafv1alpha1.SelectedTargetStatus, matched int) error {
+	patch := client.MergeFrom(engine.DeepCopy())
+	before := snapshotConditions(engine.Status.Conditions)
+	original := engine.Status.DeepCopy()
+
+	conditions := &engine.Status.Conditions
+	generation := engine.Generation
+	engine.Status.Targets = targets
+	engine.Status.EnforcementMode = ""
+	engine.Status.ActiveRuleSet = ""
+	engine.Status.Schedule = nil
+	engine.Status.EffectiveConfig = nil
+	apimeta.RemoveStatusCondition(conditions, conditionWorkloadsSelected)
+	apimeta.RemoveStatusCondition(conditions, conditionFailingClosed)
+	apimeta.RemoveStatusCondition(conditions, conditionDataPlaneReady)
+
+	if matched == 0 {
+		applyStatusNotAccepted(conditions, generation, "TargetNotFound", fmt.Sprintf("No Gateway in namespace %q matches the target selector", engine.Namespace))
+	} else {
+		msg := fmt.Sprintf("Target selector matches %d Gateways", matched)
+		if matched > len(targets) {
+			msg += fmt.Sprintf("; only the first %d are protected", len(targets))
+		}
+		setConditionTrue(conditions, generation, conditionAccepted, "Accepted", msg)
+
+		var notAccepted, notReady []string
+		for _, target := range targets {
+			switch {
+			case target.Accepted == metav1.ConditionFalse:
+				notAccepted = append(notAccepted, fmt.Sprintf("%s (%s)", target.Engine, target.Message))
+			case target.Ready != metav1.ConditionTrue:
+				notReady = append(notReady, target.Engine)
+			}
+		}
+		switch {
+		case len(notAccepted) > 0:
+			applyStatusConditionDegraded(conditions, generation, "TargetsNotAccepted", fmt.Sprintf("Engines not accepted: %s", strings.Join(notAccepted, ", ")))
+		case len(notReady) > 0:
+			apimeta.RemoveStatusCondition(conditions, conditionDegraded)
+			applyStatusProgressing(conditions, generation, "TargetsNotReady", fmt.Sprintf("Engines not ready: %s", strings.Join(notReady, ", ")))
+		default:
+			applyStatusReady(conditions, generation, "TargetsReady", fmt.Sprintf("The Engines of the %d selected Gateways are ready", len(targets)))
+		}
+	}
+	applyEngineAncestors(engine)
+
+	if conditionsEqual(original.Conditions, engine.Status.Conditions) &&
+		ancestorsEqual(original.Ancestors, engine.Status.Ancestors) &&
+		slices.Equal(original.Targets, engine.Status.Targets) &&
+		original.EnforcementMode == "" && original.ActiveRuleSet == "" && original.Schedule == nil && original.EffectiveConfig == nil {
+		engine.Status = original
+		logDebug(log, req, "Engine", "Status unchanged, skipping patch")
+		return nil
+	}
+	if err := r.Status().Patch(ctx, engine, patch); err != nil {
+		logAPIError(log, req, "Engine", err, "Failed to patch status", engine)
+		return err
+	}
+	logConditionTransitions(log, req, "Engine", before, engine.Status.Conditions)
+	return nil
+}
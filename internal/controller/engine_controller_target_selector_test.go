@@ -0,0 +1,138 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/test/utils"
+)
+
+func TestSelectedEngineName(t *testing.T) {
+	assert.Equal(t, "waf-gateway", selectedEngineName("waf", "gateway"))
+
+	long := selectedEngineName(strings.Repeat("a", 40), strings.Repeat("b", 40))
+	assert.Len(t, long, maxSelectedEngineName)
+	assert.NotEqual(t, long, selectedEngineName(strings.Repeat("a", 40), strings.Repeat("b", 41)))
+}
+
+func TestEngineReconciler_ReconcileTargetSelector(t *testing.T) {
+	scheme := newFleetTestScheme(t)
+	gateway := func(name string, labels map[string]string) *unstructured.Unstructured {
+		gw := &unstructured.Unstructured{}
+		gw.SetGroupVersionKind(schema.GroupVersionKind{Group: gatewayGroup, Version: "v1", Kind: "Gateway"})
+		gw.SetName(name)
+		gw.SetNamespace("team-a")
+		gw.SetLabels(labels)
+		return gw
+	}
+	edge := map[string]string{"tier": "edge"}
+
+	engine := utils.NewTestEngine(utils.EngineOptions{Name: "waf", Namespace: "team-a"})
+	engine.UID = "waf-uid"
+	engine.Annotations = map[string]string{wafv1alpha1.AnnotationCreatedAt: "2026-01-02T03:04:05Z"}
+	engine.Spec.Target.Name = ""
+	engine.Spec.Target.Selector = &metav1.LabelSelector{MatchLabels: edge}
+	engine.Status = &wafv1alpha1.EngineStatus{}
+	taken := utils.NewTestEngine(utils.EngineOptions{Name: "waf-edge-c", Namespace: "team-a", GatewayName: "other"})
+
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(engine, taken, gateway("edge-a", edge), gateway("edge-b", edge), gateway("edge-c", edge), gateway("internal", nil)).
+		WithStatusSubresource(engine).
+		Build()
+	r := &EngineReconciler{Client: c, Scheme: scheme, Recorder: utils.NewFakeRecorder()}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(engine)}
+
+	t.Log("Creating one Engine per selected Gateway")
+	_, err := r.reconcileTargetSelector(t.Context(), ctrl.Log, req, engine)
+	require.NoError(t, err)
+	for _, name := range []string{"edge-a", "edge-b"} {
+		var child wafv1alpha1.Engine
+		require.NoError(t, c.Get(t.Context(), client.ObjectKey{Namespace: "team-a", Name: "waf-" + name}, &child))
+		assert.Equal(t, name, child.Spec.Target.Name)
+		assert.Nil(t, child.Spec.Target.Selector)
+		assert.Equal(t, engine.Spec.RuleSet, child.Spec.RuleSet)
+		assert.True(t, metav1.IsControlledBy(&child, engine))
+		assert.Equal(t, "2026-01-02T03:04:05Z", child.Annotations[wafv1alpha1.AnnotationCreatedAt], "conflicts are decided on the time of the selecting Engine")
+	}
+	require.NoError(t, c.Get(t.Context(), client.ObjectKeyFromObject(taken), taken))
+	assert.Equal(t, "other", taken.Spec.Target.Name, "an Engine not created by the selector is left alone")
+
+	require.NoError(t, c.Get(t.Context(), req.NamespacedName, engine))
+	require.Len(t, engine.Status.Targets, 3)
+	assert.Equal(t, wafv1alpha1.SelectedTargetStatus{Gateway: "edge-a", Engine: "waf-edge-a", Accepted: metav1.ConditionUnknown, Ready: metav1.ConditionUnknown}, engine.Status.Targets[0])
+	assert.Equal(t, metav1.ConditionFalse, engine.Status.Targets[2].Accepted)
+	assert.True(t, apimeta.IsStatusConditionTrue(engine.Status.Conditions, conditionAccepted))
+	degraded := apimeta.FindStatusCondition(engine.Status.Conditions, conditionDegraded)
+	require.NotNil(t, degraded)
+	assert.Equal(t, "TargetsNotAccepted", degraded.Reason)
+
+	t.Log("Reporting the status of the Engines created")
+	require.NoError(t, c.Delete(t.Context(), taken))
+	_, err = r.reconcileTargetSelector(t.Context(), ctrl.Log, req, engine)
+	require.NoError(t, err)
+	for _, name := range []string{"waf-edge-a", "waf-edge-b", "waf-edge-c"} {
+		var child wafv1alpha1.Engine
+		require.NoError(t, c.Get(t.Context(), client.ObjectKey{Namespace: "team-a", Name: name}, &child))
+		child.Status = &wafv1alpha1.EngineStatus{}
+		setConditionTrue(&child.Status.Conditions, child.Generation, conditionAccepted, "Accepted", "")
+		applyStatusReady(&child.Status.Conditions, child.Generation, "Ready", "")
+		require.NoError(t, c.Status().Update(t.Context(), &child))
+	}
+	_, err = r.reconcileTargetSelector(t.Context(), ctrl.Log, req, engine)
+	require.NoError(t, err)
+	require.NoError(t, c.Get(t.Context(), req.NamespacedName, engine))
+	assert.True(t, apimeta.IsStatusConditionTrue(engine.Status.Conditions, conditionReady))
+	assert.Nil(t, apimeta.FindStatusCondition(engine.Status.Conditions, conditionDegraded))
+
+	t.Log("Deleting the Engine of a Gateway no longer selected")
+	edgeB := gateway("edge-b", nil)
+	require.NoError(t, c.Get(t.Context(), client.ObjectKeyFromObject(edgeB), edgeB))
+	edgeB.SetLabels(nil)
+	require.NoError(t, c.Update(t.Context(), edgeB))
+	_, err = r.reconcileTargetSelector(t.Context(), ctrl.Log, req, engine)
+	require.NoError(t, err)
+	var engines wafv1alpha1.EngineList
+	require.NoError(t, c.List(t.Context(), &engines, client.InNamespace("team-a")))
+	var names []string
+	for _, e := range engines.Items {
+		names = append(names, e.Name)
+	}
+	assert.ElementsMatch(t, []string{"waf", "waf-edge-a", "waf-edge-c"}, names)
+
+	t.Log("Not accepting a selector that matches no Gateway")
+	engine.Spec.Target.Selector = &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "none"}}
+	_, err = r.reconcileTargetSelector(t.Context(), ctrl.Log, req, engine)
+	require.NoError(t, err)
+	require.NoError(t, c.Get(t.Context(), req.NamespacedName, engine))
+	assert.Empty(t, engine.Status.Targets)
+	accepted := apimeta.FindStatusCondition(engine.Status.Conditions, conditionAccepted)
+	require.NotNil(t, accepted)
+	assert.Equal(t, "TargetNotFound", accepted.Reason)
+}
@@ -45,7 +45,7 @@ import (
 // validation tests. The resource is cleaned up via t.Cleanup. The returned
 // object can be used for manual deletion in tests that need to remove the
 // Gateway mid-test (the cleanup will log but not fail on NotFound).
-func createTestGateway(t *testing.T, ctx context.Context, c client.Client, name, namespace string) *unstructured.Unstructured {
+func createTestGateway(t testing.TB, ctx context.Context, c client.Client, name, namespace string) *unstructured.Unstructured {
 	t.Helper()
 	gw := &unstructured.Unstructured{}
 	gw.SetGroupVersionKind(schema.GroupVersionKind{
@@ -111,16 +111,18 @@ func TestEngineReconciler_BuildWasmPlugin_IstioRevisionLabel(t *testing.T) {
 		ruleSetCacheServerCluster: "test-cluster",
 		istioRevision:             "canary",
 	}
-	w := withRev.buildWasmPlugin(engine, testWasmOCI, "test-token")
+	w := withRev.buildWasmPlugin(engine, nil, testWasmOCI, "test-token")
 	assert.Equal(t, "canary", w.GetLabels()["istio.io/rev"])
+	assert.Equal(t, ManagedByValue, w.GetLabels()[ManagedByLabel])
 
 	noRev := &EngineReconciler{
 		ruleSetCacheServerCluster: "test-cluster",
 		operatorNamespace:         testNamespace,
 	}
-	w2 := noRev.buildWasmPlugin(engine, testWasmOCI, "test-token")
+	w2 := noRev.buildWasmPlugin(engine, nil, testWasmOCI, "test-token")
 	_, has := w2.GetLabels()["istio.io/rev"]
 	assert.False(t, has, "istio.io/rev should not be set when revision is empty")
+	assert.Equal(t, ManagedByValue, w2.GetLabels()[ManagedByLabel], "managed-by label must always be set")
 }
 
 func TestEngineReconciler_BuildWasmPlugin_CacheToken(t *testing.T) {
@@ -134,7 +136,7 @@ func TestEngineReconciler_BuildWasmPlugin_CacheToken(t *testing.T) {
 	}
 
 	t.Run("cache_token is set in pluginConfig", func(t *testing.T) {
-		w := reconciler.buildWasmPlugin(engine, "oci://test.example/wasm:latest", "my-jwt-token")
+		w := reconciler.buildWasmPlugin(engine, nil, "oci://test.example/wasm:latest", "my-jwt-token")
 
 		spec, found, err := getNestedMap(w.Object, "spec")
 		require.NoError(t, err)
@@ -151,7 +153,7 @@ func TestEngineReconciler_BuildWasmPlugin_CacheToken(t *testing.T) {
 	})
 
 	t.Run("empty token is still set", func(t *testing.T) {
-		w := reconciler.buildWasmPlugin(engine, "oci://test.example/wasm:latest", "")
+		w := reconciler.buildWasmPlugin(engine, nil, "oci://test.example/wasm:latest", "")
 
 		spec, found, err := getNestedMap(w.Object, "spec")
 		require.NoError(t, err)
@@ -284,7 +286,7 @@ func TestEngineReconciler_ReconcileIstioDriver(t *testing.T) {
 		Namespace: engine.Namespace,
 	}, &updated)
 	require.NoError(t, err)
-	assert.Len(t, updated.Status.Conditions, 2, "should have Ready and Accepted conditions")
+	assert.Len(t, updated.Status.Conditions, 4, "should have Ready, Accepted, WorkloadsSelected and FailingClosed conditions")
 	readyCond := apimeta.FindStatusCondition(updated.Status.Conditions, "Ready")
 	require.NotNil(t, readyCond)
 	assert.Equal(t, metav1.ConditionTrue, readyCond.Status)
@@ -292,6 +294,13 @@ func TestEngineReconciler_ReconcileIstioDriver(t *testing.T) {
 	acceptedCond := apimeta.FindStatusCondition(updated.Status.Conditions, "Accepted")
 	require.NotNil(t, acceptedCond)
 	assert.Equal(t, metav1.ConditionTrue, acceptedCond.Status)
+	workloadsCond := apimeta.FindStatusCondition(updated.Status.Conditions, conditionWorkloadsSelected)
+	require.NotNil(t, workloadsCond)
+	assert.Equal(t, metav1.ConditionFalse, workloadsCond.Status, "no gateway pod runs in envtest")
+	assert.Equal(t, "NoWorkloadsSelected", workloadsCond.Reason)
+	require.Len(t, updated.Status.Ancestors, 1, "should report the target Gateway as ancestor")
+	assert.Equal(t, wafv1alpha1.EngineControllerName, updated.Status.Ancestors[0].ControllerName)
+	assert.Equal(t, engine.Spec.Target.Name, updated.Status.Ancestors[0].AncestorRef.Name)
 
 	assert.True(t, recorder.HasEvent("Normal", "WasmPluginCreated"),
 		"expected Normal/WasmPluginCreated event; got: %v", recorder.Events)
@@ -435,7 +444,7 @@ func TestEngineReconciler_FailurePolicyInWasmPluginConfig(t *testing.T) {
 
 			t.Log("Fetching created WasmPlugin")
 			wasmURL, _ := reconciler.wasmPluginOCIURLSource(engine)
-			wasmPlugin := reconciler.buildWasmPlugin(engine, wasmURL, "test-token")
+			wasmPlugin := reconciler.buildWasmPlugin(engine, nil, wasmURL, "test-token")
 			err = k8sClient.Get(ctx, types.NamespacedName{
 				Name:      wasmPlugin.GetName(),
 				Namespace: wasmPlugin.GetNamespace(),
@@ -506,7 +515,7 @@ func TestEngineReconciler_ImagePullSecretInWasmPlugin(t *testing.T) {
 			ImagePullSecret: "my-registry-secret",
 		})
 
-		wasmPlugin := reconciler.buildWasmPlugin(engine, "", "")
+		wasmPlugin := reconciler.buildWasmPlugin(engine, nil, "", "")
 
 		spec, found, err := getNestedMap(wasmPlugin.Object, "spec")
 		require.NoError(t, err)
@@ -524,7 +533,7 @@ func TestEngineReconciler_ImagePullSecretInWasmPlugin(t *testing.T) {
 			Namespace: testNamespace,
 		})
 
-		wasmPlugin := reconciler.buildWasmPlugin(engine, "", "")
+		wasmPlugin := reconciler.buildWasmPlugin(engine, nil, "", "")
 
 		spec, found, err := getNestedMap(wasmPlugin.Object, "spec")
 		require.NoError(t, err)
@@ -756,7 +765,7 @@ func TestEngineReconciler_SelectDriver_NilDriverDefaultsToWasm(t *testing.T) {
 			Name:      fetched.Name,
 			Namespace: fetched.Namespace,
 		},
-	}, fetched)
+	}, &fetched)
 	require.NoError(t, err)
 }
 
@@ -805,7 +814,7 @@ func TestEngineReconciler_NilTargetRef_MarksDegraded(t *testing.T) {
 	// Call provisionWasmDriver directly — it should detect the empty
 	// TargetRef and mark the Engine Degraded instead of creating a
 	// WasmPlugin that matches all workloads.
-	_, err := reconciler.provisionWasmDriver(ctx, ctrl.Log, engineReq, fetched)
+	_, err := reconciler.provisionWasmDriver(ctx, ctrl.Log, engineReq, &fetched)
 	require.Error(t, err)
 	assert.Contains(t, err.Error(), "target is required")
 
@@ -920,6 +929,79 @@ func TestEngineReconciler_ValidationRejection(t *testing.T) {
 			},
 			expectedError: "spec.target.provider",
 		},
+		{
+			name: "DetectionOnly mode rejected",
+			engineFunc: func() *wafv1alpha1.Engine {
+				engine := utils.NewTestEngine(utils.EngineOptions{})
+				engine.Spec.Mode = wafv1alpha1.EngineModeDetectionOnly
+				return engine
+			},
+			expectedError: "DetectionOnly is not supported yet",
+		},
+		{
+			name: "responseInspection rejected",
+			engineFunc: func() *wafv1alpha1.Engine {
+				engine := utils.NewTestEngine(utils.EngineOptions{})
+				engine.Spec.ResponseInspection = &wafv1alpha1.ResponseInspection{}
+				return engine
+			},
+			expectedError: "responseInspection is not supported yet",
+		},
+		{
+			name: "redaction rejected",
+			engineFunc: func() *wafv1alpha1.Engine {
+				engine := utils.NewTestEngine(utils.EngineOptions{})
+				engine.Spec.Redaction = &wafv1alpha1.Redaction{Headers: []string{"Authorization"}}
+				return engine
+			},
+			expectedError: "redaction is not supported yet",
+		},
+		{
+			name: "verdictMetadata rejected",
+			engineFunc: func() *wafv1alpha1.Engine {
+				engine := utils.NewTestEngine(utils.EngineOptions{})
+				engine.Spec.VerdictMetadata = &wafv1alpha1.VerdictMetadata{}
+				return engine
+			},
+			expectedError: "verdictMetadata is not supported yet",
+		},
+		{
+			name: "requestCorrelation rejected",
+			engineFunc: func() *wafv1alpha1.Engine {
+				engine := utils.NewTestEngine(utils.EngineOptions{})
+				engine.Spec.RequestCorrelation = &wafv1alpha1.RequestCorrelation{}
+				return engine
+			},
+			expectedError: "requestCorrelation is not supported yet",
+		},
+		{
+			name: "blockResponse rejected",
+			engineFunc: func() *wafv1alpha1.Engine {
+				engine := utils.NewTestEngine(utils.EngineOptions{})
+				engine.Spec.BlockResponse = &wafv1alpha1.BlockResponse{Body: "Request blocked"}
+				return engine
+			},
+			expectedError: "blockResponse is not supported yet",
+		},
+		{
+			name: "rateLimit rejected",
+			engineFunc: func() *wafv1alpha1.Engine {
+				engine := utils.NewTestEngine(utils.EngineOptions{})
+				engine.Spec.RateLimit = &wafv1alpha1.RateLimit{Requests: 100}
+				return engine
+			},
+			expectedError: "rateLimit is not supported yet",
+		},
+		{
+			name: "learning rejected",
+			engineFunc: func() *wafv1alpha1.Engine {
+				engine := utils.NewTestEngine(utils.EngineOptions{})
+				engine.Spec.RuleSetCacheServer = &wafv1alpha1.RuleSetCacheServerConfig{PollIntervalSeconds: 15}
+				engine.Spec.Learning = &wafv1alpha1.LearningConfig{DurationSeconds: 3600, MinMatches: 10}
+				return engine
+			},
+			expectedError: "learning is not supported yet",
+		},
 		{
 			name: "provider Istio accepted with Gateway target type",
 			engineFunc: func() *wafv1alpha1.Engine {
@@ -1100,7 +1182,7 @@ func TestEngineReconciler_BuildWasmPlugin_WasmImageResolution(t *testing.T) {
 		engine.Spec.Driver.Wasm.Image = ""
 		r := &EngineReconciler{defaultWasmImage: operatorDefault}
 		wasmURL, _ := r.wasmPluginOCIURLSource(engine)
-		wp := r.buildWasmPlugin(engine, wasmURL, "")
+		wp := r.buildWasmPlugin(engine, nil, wasmURL, "")
 		spec, found, err := getNestedMap(wp.Object, "spec")
 		require.NoError(t, err)
 		require.True(t, found)
@@ -1124,7 +1206,7 @@ func TestEngineReconciler_BuildWasmPlugin_WasmImageResolution(t *testing.T) {
 		engine.Spec.Driver.Wasm.Image = custom
 		r := &EngineReconciler{defaultWasmImage: operatorDefault}
 		wasmURL, _ := r.wasmPluginOCIURLSource(engine)
-		wp := r.buildWasmPlugin(engine, wasmURL, "")
+		wp := r.buildWasmPlugin(engine, nil, wasmURL, "")
 		spec, found, err := getNestedMap(wp.Object, "spec")
 		require.NoError(t, err)
 		require.True(t, found)
@@ -1678,6 +1760,12 @@ func TestEngineReconciler_TargetConflict(t *testing.T) {
 	assert.Contains(t, acceptedB.Message, engineA.Name,
 		"conflict message should mention the winning Engine's name")
 
+	require.Len(t, updatedB.Status.Ancestors, 1, "Engine B should report its target Gateway as ancestor")
+	ancestorB := apimeta.FindStatusCondition(updatedB.Status.Ancestors[0].Conditions, "Accepted")
+	require.NotNil(t, ancestorB)
+	assert.Equal(t, metav1.ConditionFalse, ancestorB.Status)
+	assert.Equal(t, "Conflicted", ancestorB.Reason)
+
 	readyB := apimeta.FindStatusCondition(updatedB.Status.Conditions, "Ready")
 	require.NotNil(t, readyB)
 	assert.Equal(t, metav1.ConditionFalse, readyB.Status)
@@ -2125,3 +2213,25 @@ func TestEngineReconciler_ReadyToTargetNotFound(t *testing.T) {
 	_, found := reconciler.tokenStore.Load(tokenKey)
 	assert.False(t, found, "token store entry should be removed when Engine is not accepted")
 }
+
+func TestValidateRedaction(t *testing.T) {
+	tests := []struct {
+		name      string
+		redaction *wafv1alpha1.Redaction
+		wantErr   string
+	}{
+		{name: "unset"},
+		{name: "valid", redaction: &wafv1alpha1.Redaction{Headers: []string{"Authorization"}, BodyFields: []string{`^json\.password$`}}},
+		{name: "invalid body field", redaction: &wafv1alpha1.Redaction{BodyFields: []string{`^json\.(password$`}}, wantErr: "redaction.bodyFields"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateRedaction(tt.redaction)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
@@ -19,13 +19,17 @@ package controller
 import (
 	"context"
 	"fmt"
+	"maps"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
 	authv1 "k8s.io/api/authentication/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
@@ -87,9 +91,9 @@ func (e *TokenEntry) NeedsRenewal() bool {
 // ServiceAccount owned by a specific Engine.
 func cacheClientSALabels(engineName string) map[string]string {
 	return map[string]string{
-		"app.kubernetes.io/managed-by": "coraza-kubernetes-operator",
-		"app.kubernetes.io/component":  "cache-client",
-		"app.kubernetes.io/instance":   engineName,
+		ManagedByLabel:                ManagedByValue,
+		"app.kubernetes.io/component": "cache-client",
+		"app.kubernetes.io/instance":  engineName,
 	}
 }
 
@@ -124,11 +128,16 @@ func (r *EngineReconciler) ensureCacheClientServiceAccount(ctx context.Context,
 		return saList.Items[0].Name, nil
 	}
 
+	// The backup exclusion label is left out of the discovery selector, which
+	// must keep matching ServiceAccounts created before it was introduced.
+	saLabels := maps.Clone(labels)
+	saLabels[wafv1alpha1.LabelExcludeFromBackup] = "true"
+
 	sa := &corev1.ServiceAccount{
 		ObjectMeta: metav1.ObjectMeta{
 			GenerateName: rcache.CacheEngineSAPrefix,
 			Namespace:    req.Namespace,
-			Labels:       labels,
+			Labels:       saLabels,
 			OwnerReferences: []metav1.OwnerReference{
 				{
 					APIVersion: wafv1alpha1.GroupVersion.String(),
@@ -149,6 +158,37 @@ func (r *EngineReconciler) ensureCacheClientServiceAccount(ctx context.Context,
 	return sa.Name, nil
 }
 
+// cacheClientEngine returns the Engine owning the cache client
+// ServiceAccount of caller, read with reader. It fails with rejected unless
+// caller is the cache client of an existing Engine using the RuleSet of
+// cacheKey.
+func cacheClientEngine(ctx context.Context, reader client.Reader, caller rcache.AuthResult, cacheKey string, rejected error) (*wafv1alpha1.Engine, error) {
+	var sa corev1.ServiceAccount
+	if err := reader.Get(ctx, types.NamespacedName{Namespace: caller.Namespace, Name: caller.Name}, &sa); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("%w: ServiceAccount %s/%s not found", rejected, caller.Namespace, caller.Name)
+		}
+		return nil, err
+	}
+	owner := metav1.GetControllerOf(&sa)
+	if owner == nil || owner.Kind != "Engine" || owner.APIVersion != wafv1alpha1.GroupVersion.String() {
+		return nil, fmt.Errorf("%w: ServiceAccount %s/%s does not belong to an Engine", rejected, caller.Namespace, caller.Name)
+	}
+
+	var engine wafv1alpha1.Engine
+	if err := reader.Get(ctx, types.NamespacedName{Namespace: sa.Namespace, Name: owner.Name}, &engine); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("%w: Engine %s/%s not found", rejected, sa.Namespace, owner.Name)
+		}
+		return nil, err
+	}
+	_, ruleSetName, _ := strings.Cut(cacheKey, "/")
+	if engine.UID != owner.UID || !slices.Contains(engineRuleSets(&engine), ruleSetName) {
+		return nil, fmt.Errorf("%w: Engine %s/%s does not use RuleSet %s", rejected, engine.Namespace, engine.Name, cacheKey)
+	}
+	return &engine, nil
+}
+
 // ensureCacheToken returns a valid cache client token for the given Engine and
 // the deadline at which the token should be renewed.
 // If the stored token is missing or near expiry, a new one is generated via
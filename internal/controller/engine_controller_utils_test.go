@@ -17,11 +17,29 @@ limitations under the License.
 package controller
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 
+	"github.com/go-logr/logr"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 
 	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/test/utils"
 )
 
 func TestEngineMatchesLabels(t *testing.T) {
@@ -77,6 +95,18 @@ func TestEngineMatchesLabels(t *testing.T) {
 		assert.False(t, engineMatchesLabels(engine, nil))
 	})
 
+	t.Run("ingress gateway matches the istio label", func(t *testing.T) {
+		engine := &wafv1alpha1.Engine{
+			Spec: wafv1alpha1.EngineSpec{Target: wafv1alpha1.EngineTarget{
+				Type: wafv1alpha1.EngineTargetTypeIngressGateway,
+				Name: "ingressgateway",
+			}},
+		}
+		assert.True(t, engineMatchesLabels(engine, map[string]string{"istio": "ingressgateway"}))
+		assert.False(t, engineMatchesLabels(engine, map[string]string{"istio": "eastwestgateway"}))
+		assert.False(t, engineMatchesLabels(engine, map[string]string{gatewayNameLabel: "ingressgateway"}))
+	})
+
 	t.Run("pod without gateway label returns false", func(t *testing.T) {
 		engine := &wafv1alpha1.Engine{
 			Spec: wafv1alpha1.EngineSpec{Target: wafv1alpha1.EngineTarget{
@@ -186,3 +216,451 @@ func TestTargetLabelSelector_SecurityRegressions(t *testing.T) {
 		assert.Nil(t, targetLabelSelector(nil))
 	})
 }
+
+func TestEngineReconciler_IngressGatewayTarget(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	newEngine := func(name string, created time.Time) *wafv1alpha1.Engine {
+		return &wafv1alpha1.Engine{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "istio-system", CreationTimestamp: metav1.NewTime(created)},
+			Spec: wafv1alpha1.EngineSpec{Target: wafv1alpha1.EngineTarget{
+				Type: wafv1alpha1.EngineTargetTypeIngressGateway,
+				Name: "ingressgateway",
+			}},
+		}
+	}
+	older := newEngine("older", time.Unix(100, 0))
+	newer := newEngine("newer", time.Unix(200, 0))
+	gatewayPod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Name: "istio-ingressgateway-1", Namespace: "istio-system", Labels: map[string]string{"istio": "ingressgateway"},
+	}}
+	index := func(obj client.Object) []string {
+		engine := obj.(*wafv1alpha1.Engine)
+		return []string{engineTargetKey(engine.Spec.Target.Type, engine.Spec.Target.Name)}
+	}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(newer)}
+
+	t.Run("target not found without gateway pods", func(t *testing.T) {
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(newer).Build()
+		r := &EngineReconciler{Client: c}
+		notFound, err := r.isTargetNotFound(t.Context(), logr.Discard(), req, newer)
+		require.NoError(t, err)
+		assert.True(t, notFound)
+	})
+
+	t.Run("gateway pods and conflicts", func(t *testing.T) {
+		c := fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(older, newer, gatewayPod).
+			WithIndex(&wafv1alpha1.Engine{}, engineTargetIndex, index).
+			Build()
+		r := &EngineReconciler{Client: c}
+		notFound, err := r.isTargetNotFound(t.Context(), logr.Discard(), req, newer)
+		require.NoError(t, err)
+		assert.False(t, notFound)
+
+		conflict, winner, err := r.hasTargetConflict(t.Context(), logr.Discard(), req, newer)
+		require.NoError(t, err)
+		assert.True(t, conflict)
+		assert.Equal(t, "older", winner)
+	})
+
+	t.Run("no Gateway API ancestor", func(t *testing.T) {
+		engine := newer.DeepCopy()
+		engine.Status = &wafv1alpha1.EngineStatus{}
+		setConditionTrue(&engine.Status.Conditions, 1, conditionAccepted, "Accepted", "ok")
+		applyEngineAncestors(engine)
+		assert.Empty(t, engine.Status.Ancestors)
+	})
+}
+
+func TestEngineReconciler_TargetListenerPort(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+
+	gw := &unstructured.Unstructured{}
+	gw.SetGroupVersionKind(schema.GroupVersionKind{Group: gatewayGroup, Version: "v1", Kind: "Gateway"})
+	gw.SetName("gateway")
+	gw.SetNamespace("team-a")
+	gw.Object["spec"] = map[string]any{
+		"listeners": []any{
+			map[string]any{"name": "http", "port": int64(80), "protocol": "HTTP"},
+			map[string]any{"name": "admin", "port": int64(8443), "protocol": "HTTPS"},
+		},
+	}
+	r := &EngineReconciler{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(gw).Build()}
+	engine := utils.NewTestEngine(utils.EngineOptions{Name: "waf", Namespace: "team-a", GatewayName: "gateway"})
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(engine)}
+
+	tests := []struct {
+		name        string
+		gateway     string
+		sectionName string
+		wantPort    int64
+		wantFound   bool
+	}{
+		{name: "whole gateway", gateway: "gateway", wantFound: true},
+		{name: "listener", gateway: "gateway", sectionName: "admin", wantPort: 8443, wantFound: true},
+		{name: "missing listener", gateway: "gateway", sectionName: "grpc"},
+		{name: "missing gateway", gateway: "other", sectionName: "http"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := engine.DeepCopy()
+			engine.Spec.Target.Name = tt.gateway
+			engine.Spec.Target.SectionName = tt.sectionName
+			port, found, err := r.targetListenerPort(t.Context(), logr.Discard(), req, engine)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantPort, port)
+			assert.Equal(t, tt.wantFound, found)
+		})
+	}
+
+	t.Run("ancestor scoped to the listener", func(t *testing.T) {
+		engine := engine.DeepCopy()
+		engine.Spec.Target.SectionName = "admin"
+		engine.Status = &wafv1alpha1.EngineStatus{}
+		setConditionTrue(&engine.Status.Conditions, 1, conditionAccepted, "Accepted", "ok")
+		applyEngineAncestors(engine)
+		require.Len(t, engine.Status.Ancestors, 1)
+		assert.Equal(t, "admin", engine.Status.Ancestors[0].AncestorRef.SectionName)
+	})
+}
+
+func TestEngineReconciler_UnsupportedGatewayClass(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+
+	newGateway := func(name, className string) *unstructured.Unstructured {
+		gw := &unstructured.Unstructured{}
+		gw.SetGroupVersionKind(schema.GroupVersionKind{Group: gatewayGroup, Version: "v1", Kind: "Gateway"})
+		gw.SetName(name)
+		gw.SetNamespace("team-a")
+		gw.Object["spec"] = map[string]any{"gatewayClassName": className}
+		return gw
+	}
+	newGatewayClass := func(name, controllerName string) *unstructured.Unstructured {
+		class := &unstructured.Unstructured{}
+		class.SetGroupVersionKind(schema.GroupVersionKind{Group: gatewayGroup, Version: "v1", Kind: "GatewayClass"})
+		class.SetName(name)
+		class.Object["spec"] = map[string]any{"controllerName": controllerName}
+		return class
+	}
+	r := &EngineReconciler{Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		newGateway("istio", "istio"),
+		newGateway("openshift", "openshift-default"),
+		newGateway("envoy", "eg"),
+		newGateway("unknown-class", "missing"),
+		newGatewayClass("istio", "istio.io/gateway-controller"),
+		newGatewayClass("openshift-default", "openshift.io/gateway-controller/v1"),
+		newGatewayClass("eg", "gateway.envoyproxy.io/gatewayclass-controller"),
+		newGateway("forbidden", "forbidden"),
+		newGatewayClass("forbidden", "gateway.envoyproxy.io/gatewayclass-controller"),
+	).WithInterceptorFuncs(interceptor.Funcs{
+		// Namespace-scoped installs may not be allowed to read GatewayClasses.
+		Get: func(ctx context.Context, c client.WithWatch, key client.ObjectKey, obj client.Object, opts ...client.GetOption) error {
+			if obj.GetObjectKind().GroupVersionKind().Kind == "GatewayClass" && key.Name == "forbidden" {
+				return apierrors.NewForbidden(schema.GroupResource{Group: gatewayGroup, Resource: "gatewayclasses"}, key.Name, errors.New("not allowed"))
+			}
+			return c.Get(ctx, key, obj, opts...)
+		},
+	}).Build()}
+	engine := utils.NewTestEngine(utils.EngineOptions{Name: "waf", Namespace: "team-a", GatewayName: "istio"})
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(engine)}
+
+	tests := []struct {
+		gateway    string
+		wantReason string
+		wantMsg    string
+	}{
+		{gateway: "istio"},
+		{gateway: "openshift"},
+		{
+			gateway:    "envoy",
+			wantReason: "UnsupportedGatewayClass",
+			wantMsg:    `Gateway "envoy" has GatewayClass "eg" of controller "gateway.envoyproxy.io/gatewayclass-controller", which the Istio provider does not program`,
+		},
+		{gateway: "unknown-class"},
+		{
+			gateway:    "forbidden",
+			wantReason: "GatewayClassNotVerified",
+			wantMsg:    `GatewayClass "forbidden" of Gateway "forbidden" cannot be verified: the operator is not allowed to read GatewayClasses`,
+		},
+		{gateway: "missing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.gateway, func(t *testing.T) {
+			engine := engine.DeepCopy()
+			engine.Spec.Target.Name = tt.gateway
+			reason, msg, err := r.unsupportedGatewayClass(t.Context(), logr.Discard(), req, engine)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantReason, reason)
+			assert.Equal(t, tt.wantMsg, msg)
+		})
+	}
+}
+
+func TestEngineReconciler_PatchWorkloadsSelected(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	engine := &wafv1alpha1.Engine{
+		ObjectMeta: metav1.ObjectMeta{Name: "waf", Namespace: "team-a", Generation: 2},
+		Spec: wafv1alpha1.EngineSpec{Target: wafv1alpha1.EngineTarget{
+			Type: wafv1alpha1.EngineTargetTypeGateway,
+			Name: "my-gw",
+		}},
+		Status: &wafv1alpha1.EngineStatus{},
+	}
+	gatewayPod := func(name string, phase corev1.PodPhase, labels map[string]string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "team-a", Labels: labels},
+			Status:     corev1.PodStatus{Phase: phase},
+		}
+	}
+	selected := map[string]string{gatewayNameLabel: "my-gw"}
+
+	tests := []struct {
+		name       string
+		pods       []client.Object
+		wantStatus metav1.ConditionStatus
+		wantReason string
+		wantEvent  bool
+	}{
+		{
+			name:       "running gateway pod",
+			pods:       []client.Object{gatewayPod("gw-1", corev1.PodRunning, selected)},
+			wantStatus: metav1.ConditionTrue,
+			wantReason: "WorkloadsSelected",
+		},
+		{
+			name:       "no gateway pod",
+			wantStatus: metav1.ConditionFalse,
+			wantReason: "NoWorkloadsSelected",
+			wantEvent:  true,
+		},
+		{
+			name: "only pending pods and pods of other gateways",
+			pods: []client.Object{
+				gatewayPod("gw-1", corev1.PodPending, selected),
+				gatewayPod("other-1", corev1.PodRunning, map[string]string{gatewayNameLabel: "other-gw"}),
+			},
+			wantStatus: metav1.ConditionFalse,
+			wantReason: "NoWorkloadsSelected",
+			wantEvent:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := engine.DeepCopy()
+			c := fake.NewClientBuilder().WithScheme(scheme).
+				WithObjects(append(tt.pods, e.DeepCopy())...).
+				WithStatusSubresource(e).
+				Build()
+			recorder := utils.NewFakeRecorder()
+			r := &EngineReconciler{Client: c, Recorder: recorder}
+			req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(e)}
+
+			require.NoError(t, r.patchWorkloadsSelected(t.Context(), logr.Discard(), req, e))
+
+			var got wafv1alpha1.Engine
+			require.NoError(t, c.Get(t.Context(), req.NamespacedName, &got))
+			cond := apimeta.FindStatusCondition(got.Status.Conditions, conditionWorkloadsSelected)
+			require.NotNil(t, cond)
+			assert.Equal(t, tt.wantStatus, cond.Status)
+			assert.Equal(t, tt.wantReason, cond.Reason)
+			if tt.wantEvent {
+				require.Len(t, recorder.Events, 1)
+				assert.Equal(t, "NoWorkloadsSelected", recorder.Events[0].Reason)
+			} else {
+				assert.Empty(t, recorder.Events)
+			}
+
+			// The warning is recorded once, when the selector stops matching.
+			recorded := len(recorder.Events)
+			require.NoError(t, r.patchWorkloadsSelected(t.Context(), logr.Discard(), req, e))
+			assert.Len(t, recorder.Events, recorded)
+		})
+	}
+}
+
+func TestGatewayPodPredicate(t *testing.T) {
+	gatewayPod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "gw-1", Labels: map[string]string{gatewayNameLabel: "my-gw"}}}
+	ingressPod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "ingress-1", Labels: map[string]string{ingressGatewayLabel: "ingressgateway"}}}
+	otherPod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "app-1", Labels: map[string]string{"app": "web"}}}
+	p := gatewayPodPredicate()
+
+	assert.True(t, p.Create(event.CreateEvent{Object: gatewayPod}))
+	assert.True(t, p.Create(event.CreateEvent{Object: ingressPod}))
+	assert.False(t, p.Create(event.CreateEvent{Object: otherPod}))
+	assert.True(t, p.Delete(event.DeleteEvent{Object: gatewayPod}))
+	assert.True(t, p.Update(event.UpdateEvent{ObjectOld: gatewayPod, ObjectNew: otherPod}), "removing the gateway label re-resolves the selector")
+	assert.True(t, p.Update(event.UpdateEvent{ObjectOld: otherPod, ObjectNew: gatewayPod}), "adding the gateway label re-resolves the selector")
+	assert.False(t, p.Update(event.UpdateEvent{ObjectOld: otherPod, ObjectNew: otherPod}))
+}
+
+func TestApplyEngineAncestors(t *testing.T) {
+	newEngine := func(reason string, status metav1.ConditionStatus) *wafv1alpha1.Engine {
+		engine := &wafv1alpha1.Engine{
+			ObjectMeta: metav1.ObjectMeta{Name: "waf", Namespace: "team-a", Generation: 3},
+			Spec: wafv1alpha1.EngineSpec{Target: wafv1alpha1.EngineTarget{
+				Type: wafv1alpha1.EngineTargetTypeGateway,
+				Name: "my-gw",
+			}},
+			Status: &wafv1alpha1.EngineStatus{},
+		}
+		if reason != "" {
+			apimeta.SetStatusCondition(&engine.Status.Conditions, metav1.Condition{
+				Type:               conditionAccepted,
+				Status:             status,
+				ObservedGeneration: engine.Generation,
+				Reason:             reason,
+				Message:            "detail",
+			})
+		}
+		return engine
+	}
+
+	tests := []struct {
+		name       string
+		reason     string
+		status     metav1.ConditionStatus
+		wantReason string
+	}{
+		{name: "accepted", reason: "Accepted", status: metav1.ConditionTrue, wantReason: "Accepted"},
+		{name: "target conflict", reason: "TargetConflict", status: metav1.ConditionFalse, wantReason: "Conflicted"},
+		{name: "target not found", reason: "TargetNotFound", status: metav1.ConditionFalse, wantReason: "TargetNotFound"},
+		{name: "gateway API not installed", reason: "GatewayAPINotInstalled", status: metav1.ConditionFalse, wantReason: "TargetNotFound"},
+		{name: "other reasons are invalid", reason: "QuotaExceeded", status: metav1.ConditionFalse, wantReason: "Invalid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := newEngine(tt.reason, tt.status)
+			applyEngineAncestors(engine)
+
+			require.Len(t, engine.Status.Ancestors, 1)
+			ancestor := engine.Status.Ancestors[0]
+			assert.Equal(t, wafv1alpha1.AncestorReference{
+				Group:     "gateway.networking.k8s.io",
+				Kind:      "Gateway",
+				Namespace: "team-a",
+				Name:      "my-gw",
+			}, ancestor.AncestorRef)
+			assert.Equal(t, wafv1alpha1.EngineControllerName, ancestor.ControllerName)
+
+			cond := apimeta.FindStatusCondition(ancestor.Conditions, conditionAccepted)
+			require.NotNil(t, cond)
+			assert.Equal(t, tt.status, cond.Status)
+			assert.Equal(t, tt.wantReason, cond.Reason)
+			assert.Equal(t, "detail", cond.Message)
+			assert.Equal(t, int64(3), cond.ObservedGeneration)
+		})
+	}
+
+	t.Run("no Accepted condition clears the ancestors", func(t *testing.T) {
+		engine := newEngine("Accepted", metav1.ConditionTrue)
+		applyEngineAncestors(engine)
+		engine.Status.Conditions = nil
+		applyEngineAncestors(engine)
+		assert.Empty(t, engine.Status.Ancestors)
+	})
+
+	t.Run("retargeting replaces the ancestor", func(t *testing.T) {
+		engine := newEngine("Accepted", metav1.ConditionTrue)
+		applyEngineAncestors(engine)
+		engine.Spec.Target.Name = "other-gw"
+		applyEngineAncestors(engine)
+		require.Len(t, engine.Status.Ancestors, 1)
+		assert.Equal(t, "other-gw", engine.Status.Ancestors[0].AncestorRef.Name)
+	})
+
+	t.Run("unchanged status keeps the transition time", func(t *testing.T) {
+		engine := newEngine("Accepted", metav1.ConditionTrue)
+		applyEngineAncestors(engine)
+		before := engine.Status.DeepCopy()
+		engine.Status.Ancestors[0].Conditions[0].LastTransitionTime = metav1.Unix(0, 0)
+		before.Ancestors[0].Conditions[0].LastTransitionTime = metav1.Unix(0, 0)
+		applyEngineAncestors(engine)
+		assert.Equal(t, before.Ancestors, engine.Status.Ancestors)
+		assert.True(t, ancestorsEqual(before.Ancestors, engine.Status.Ancestors))
+	})
+}
+
+func TestSortOldestFirst(t *testing.T) {
+	engine := func(name string, created time.Time, createdAt string) wafv1alpha1.Engine {
+		e := wafv1alpha1.Engine{ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: metav1.NewTime(created)}}
+		if createdAt != "" {
+			e.Annotations = map[string]string{wafv1alpha1.AnnotationCreatedAt: createdAt}
+		}
+		return e
+	}
+	restored := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	engines := []wafv1alpha1.Engine{
+		engine("restored-first", restored, "2026-03-01T00:00:00Z"),
+		engine("restored-second", restored.Add(time.Second), "2026-01-01T00:00:00Z"),
+		engine("new", restored, ""),
+		engine("invalid-annotation", restored.Add(-time.Hour), "yesterday"),
+	}
+
+	sortOldestFirst(engines)
+
+	var names []string
+	for _, e := range engines {
+		names = append(names, e.Name)
+	}
+	assert.Equal(t, []string{"restored-second", "restored-first", "invalid-annotation", "new"}, names)
+}
+
+func TestEngineReconciler_SharedRuleSetDegraded(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+
+	ruleSet := &wafv1alpha1.RuleSet{ObjectMeta: metav1.ObjectMeta{Name: "shared", Namespace: "team-a"}}
+	applyStatusConditionDegraded(&ruleSet.Status.Conditions, 1, "RuleDataNotFound", "RuleData crs-setup not found")
+
+	var engines []*wafv1alpha1.Engine
+	objs := []client.Object{ruleSet}
+	for _, name := range []string{"waf-a", "waf-b", "waf-c"} {
+		engine := utils.NewTestEngine(utils.EngineOptions{Name: name, Namespace: "team-a", GatewayName: name, RuleSetName: "shared"})
+		engine.Status = &wafv1alpha1.EngineStatus{}
+		engines = append(engines, engine)
+		objs = append(objs, engine)
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(objs...).
+		WithStatusSubresource(objs...).
+		Build()
+	recorder := utils.NewFakeRecorder()
+	r := &EngineReconciler{Client: c, Scheme: scheme, Recorder: recorder}
+
+	reconcileAll := func() {
+		for _, engine := range engines {
+			req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(engine)}
+			degraded, err := r.isRuleSetDegraded(t.Context(), logr.Discard(), req, engine)
+			require.NoError(t, err)
+			require.True(t, degraded)
+		}
+	}
+
+	reconcileAll()
+	reconcileAll()
+	require.Len(t, recorder.Events, len(engines), "one event per dependent, not per reconcile")
+
+	t.Log("Changing the RuleSet's detail without changing its reason")
+	apimeta.FindStatusCondition(ruleSet.Status.Conditions, conditionDegraded).Message = "RuleData crs-setup and crs-rules not found"
+	require.NoError(t, c.Status().Update(t.Context(), ruleSet))
+	reconcileAll()
+	assert.Len(t, recorder.Events, len(engines), "a change in the root cause detail must not fan out")
+
+	for _, engine := range engines {
+		var updated wafv1alpha1.Engine
+		require.NoError(t, c.Get(t.Context(), client.ObjectKeyFromObject(engine), &updated))
+		cond := apimeta.FindStatusCondition(updated.Status.Conditions, conditionDegraded)
+		require.NotNil(t, cond)
+		assert.Equal(t, "RuleSetDegraded", cond.Reason)
+		assert.Equal(t, "RuleSet shared is degraded (RuleDataNotFound); see its Degraded condition for the cause", cond.Message)
+	}
+}
@@ -0,0 +1,181 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/networking-incubator/coraza-kubernetes-operator/internal/defaults"
+	"github.com/networking-incubator/coraza-kubernetes-operator/internal/rulesets/cache"
+)
+
+// -----------------------------------------------------------------------------
+// Engine Controller - WASM Compatibility - Vars
+// -----------------------------------------------------------------------------
+
+// wasmPluginConfigVersion is the version of the pluginConfig schema the
+// operator generates. Version 1 is the unversioned schema of the first
+// plugin builds. Version 2 adds the config_version key, so that a plugin
+// refuses a schema it does not know instead of misreading it.
+const wasmPluginConfigVersion = 2
+
+// pluginConfigVersionKey is the pluginConfig key holding the version of its
+// schema, from version 2.
+const pluginConfigVersionKey = "config_version"
+
+// pluginConfigMigrations migrate a pluginConfig from the version they are
+// keyed by to the previous version, for plugins that only parse older
+// versions. Every version but the first must have one.
+var pluginConfigMigrations = map[int]func(pluginConfig map[string]any){
+	2: func(pluginConfig map[string]any) { delete(pluginConfig, pluginConfigVersionKey) },
+}
+
+// wasmPluginRelease describes what a coraza-proxy-wasm build supports.
+type wasmPluginRelease struct {
+	// cacheProtocols are the RuleSet cache server protocol versions the
+	// plugin speaks. The plugin and the cache server negotiate the newest
+	// one they both speak.
+	cacheProtocols []int
+
+	// configVersions are the pluginConfig schema versions the plugin
+	// parses.
+	configVersions []int
+
+	// configKeys are the pluginConfig keys the plugin understands.
+	configKeys []string
+}
+
+// wasmPluginReleases is the compatibility table of the coraza-proxy-wasm
+// builds the operator is qualified against, keyed by image tag or digest.
+// Images that are not listed are used as is, with a warning, but no image
+// is configured with keys that no listed release understands: a feature is
+// only available once a qualified release supports it.
+var wasmPluginReleases = map[string]wasmPluginRelease{
+	imageVersion(defaults.DefaultCorazaWasmOCIReference): {
+		cacheProtocols: []int{1},
+		configVersions: []int{1},
+		configKeys: []string{
+			"cache_server_instance",
+			"cache_server_cluster",
+			"failure_policy",
+			"cache_token",
+			"rule_reload_interval_seconds",
+		},
+	},
+}
+
+// -----------------------------------------------------------------------------
+// Engine Controller - WASM Compatibility
+// -----------------------------------------------------------------------------
+
+// imageVersion returns the tag or digest of the OCI reference ref, or ""
+// when it is not a valid reference.
+func imageVersion(ref string) string {
+	_, _, version, err := splitImageReference(ref)
+	if err != nil {
+		return ""
+	}
+	return version
+}
+
+// pluginConfigVersion returns the newest pluginConfig version the WASM
+// plugin image wasmURL parses, or the latest version for images that are
+// not in the compatibility table. It returns false when the image parses no
+// version the operator generates.
+func pluginConfigVersion(wasmURL string) (int, bool) {
+	release, known := wasmPluginReleases[imageVersion(wasmURL)]
+	if !known {
+		return wasmPluginConfigVersion, true
+	}
+	for version := wasmPluginConfigVersion; version >= 1; version-- {
+		if slices.Contains(release.configVersions, version) {
+			return version, true
+		}
+	}
+	return wasmPluginConfigVersion, false
+}
+
+// migratePluginConfig migrates pluginConfig, of the latest version, down to
+// version in place, and returns it.
+func migratePluginConfig(pluginConfig map[string]any, version int) map[string]any {
+	for v := wasmPluginConfigVersion; v > version; v-- {
+		pluginConfigMigrations[v](pluginConfig)
+	}
+	return pluginConfig
+}
+
+// unqualifiedConfigKeys returns the keys of pluginConfig, sorted, that no
+// release of the compatibility table understands. A plugin ignores the keys
+// it does not understand, so the features they configure would only exist
+// in the Engine spec.
+func unqualifiedConfigKeys(pluginConfig map[string]any) []string {
+	var unqualified []string
+	for _, key := range slices.Sorted(maps.Keys(pluginConfig)) {
+		if key == pluginConfigVersionKey {
+			continue
+		}
+		qualified := false
+		for _, release := range wasmPluginReleases {
+			if slices.Contains(release.configKeys, key) {
+				qualified = true
+				break
+			}
+		}
+		if !qualified {
+			unqualified = append(unqualified, key)
+		}
+	}
+	return unqualified
+}
+
+// checkWasmCompatibility checks the WASM plugin image wasmURL against the
+// compatibility table for the pluginConfig the operator generates, of the
+// latest version. It returns an error when the image is known to be
+// incompatible, and reports whether the image is known at all.
+func checkWasmCompatibility(wasmURL string, pluginConfig map[string]any) (known bool, err error) {
+	release, known := wasmPluginReleases[imageVersion(wasmURL)]
+	if !known {
+		return false, nil
+	}
+
+	if !slices.ContainsFunc(release.cacheProtocols, func(v int) bool { return slices.Contains(cache.ProtocolVersions, v) }) {
+		return true, fmt.Errorf("WASM plugin image %s speaks cache protocol versions %s, but the operator serves versions %s",
+			wasmURL, cache.FormatVersions(release.cacheProtocols), cache.FormatVersions(cache.ProtocolVersions))
+	}
+
+	configVersion, ok := pluginConfigVersion(wasmURL)
+	if !ok {
+		return true, fmt.Errorf("WASM plugin image %s parses pluginConfig versions %s, but the operator generates versions 1 to %d",
+			wasmURL, cache.FormatVersions(release.configVersions), wasmPluginConfigVersion)
+	}
+	pluginConfig = migratePluginConfig(maps.Clone(pluginConfig), configVersion)
+
+	var unsupported []string
+	for _, key := range slices.Sorted(maps.Keys(pluginConfig)) {
+		if !slices.Contains(release.configKeys, key) {
+			unsupported = append(unsupported, key)
+		}
+	}
+	if len(unsupported) > 0 {
+		return true, fmt.Errorf("WASM plugin image %s does not support the configuration keys %s required by this Engine",
+			wasmURL, strings.Join(unsupported, ", "))
+	}
+	return true, nil
+}
@@ -0,0 +1,189 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/internal/defaults"
+	"github.com/networking-incubator/coraza-kubernetes-operator/test/utils"
+)
+
+func TestCheckWasmCompatibility(t *testing.T) {
+	wasmPluginReleases["v0-no-reload"] = wasmPluginRelease{
+		cacheProtocols: []int{1},
+		configVersions: []int{1},
+		configKeys:     []string{"cache_server_instance", "cache_server_cluster", "failure_policy", "cache_token"},
+	}
+	wasmPluginReleases["v0-old-protocol"] = wasmPluginRelease{cacheProtocols: []int{0}, configVersions: []int{1}}
+	wasmPluginReleases["v3-new-config"] = wasmPluginRelease{cacheProtocols: []int{1, 2}, configVersions: []int{3}}
+	t.Cleanup(func() {
+		delete(wasmPluginReleases, "v0-no-reload")
+		delete(wasmPluginReleases, "v0-old-protocol")
+		delete(wasmPluginReleases, "v3-new-config")
+	})
+
+	r := &EngineReconciler{ruleSetCacheServerCluster: "cache"}
+	engine := &wafv1alpha1.Engine{
+		ObjectMeta: metav1.ObjectMeta{Name: "engine", Namespace: "ns"},
+		Spec:       wafv1alpha1.EngineSpec{RuleSet: wafv1alpha1.RuleSetReference{Name: "ruleset"}},
+	}
+	polling := engine.DeepCopy()
+	polling.Spec.RuleSetCacheServer = &wafv1alpha1.RuleSetCacheServerConfig{PollIntervalSeconds: 10}
+	inspecting := engine.DeepCopy()
+	inspecting.Spec.ResponseInspection = &wafv1alpha1.ResponseInspection{Body: &wafv1alpha1.ResponseBodyInspection{}}
+
+	tests := []struct {
+		name      string
+		url       string
+		engine    *wafv1alpha1.Engine
+		wantKnown bool
+		wantErr   string
+	}{
+		{
+			name:      "default image",
+			url:       defaults.DefaultCorazaWasmOCIReference,
+			engine:    polling,
+			wantKnown: true,
+		},
+		{
+			name:      "default image mirrored",
+			url:       "oci://registry.internal/coraza-proxy-wasm:" + imageVersion(defaults.DefaultCorazaWasmOCIReference),
+			engine:    polling,
+			wantKnown: true,
+		},
+		{
+			name:      "default image without response inspection",
+			url:       defaults.DefaultCorazaWasmOCIReference,
+			engine:    inspecting,
+			wantKnown: true,
+			wantErr:   "process_response_body, process_response_headers, response_body_limit_bytes, response_body_mime_types",
+		},
+		{
+			name:   "unknown image",
+			url:    "oci://ghcr.io/example/coraza-proxy-wasm:custom",
+			engine: polling,
+		},
+		{
+			name:      "missing configuration key",
+			url:       "oci://ghcr.io/example/coraza-proxy-wasm:v0-no-reload",
+			engine:    polling,
+			wantKnown: true,
+			wantErr:   "does not support the configuration keys rule_reload_interval_seconds",
+		},
+		{
+			name:      "configuration key not generated",
+			url:       "oci://ghcr.io/example/coraza-proxy-wasm:v0-no-reload",
+			engine:    engine,
+			wantKnown: true,
+		},
+		{
+			name:      "cache protocol mismatch",
+			url:       "oci://ghcr.io/example/coraza-proxy-wasm:v0-old-protocol",
+			engine:    engine,
+			wantKnown: true,
+			wantErr:   "speaks cache protocol versions 0, but the operator serves versions 1",
+		},
+		{
+			name:      "pluginConfig version mismatch",
+			url:       "oci://ghcr.io/example/coraza-proxy-wasm:v3-new-config",
+			engine:    engine,
+			wantKnown: true,
+			wantErr:   "parses pluginConfig versions 3, but the operator generates versions 1 to 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			known, err := checkWasmCompatibility(tt.url, r.wasmPluginConfig(tt.engine, nil, ""))
+			assert.Equal(t, tt.wantKnown, known)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestUnqualifiedConfigKeys(t *testing.T) {
+	r := &EngineReconciler{ruleSetCacheServerCluster: "cache"}
+	engine := &wafv1alpha1.Engine{
+		ObjectMeta: metav1.ObjectMeta{Name: "engine", Namespace: "ns"},
+		Spec: wafv1alpha1.EngineSpec{
+			RuleSet:            wafv1alpha1.RuleSetReference{Name: "ruleset"},
+			RuleSetCacheServer: &wafv1alpha1.RuleSetCacheServerConfig{PollIntervalSeconds: 10},
+		},
+	}
+	assert.Empty(t, unqualifiedConfigKeys(r.wasmPluginConfig(engine, nil, "")))
+
+	detecting := engine.DeepCopy()
+	detecting.Spec.Mode = wafv1alpha1.EngineModeDetectionOnly
+	assert.Equal(t, []string{"rule_engine"}, unqualifiedConfigKeys(r.wasmPluginConfig(detecting, nil, "")))
+
+	wasmPluginReleases["v2-rule-engine"] = wasmPluginRelease{cacheProtocols: []int{1}, configVersions: []int{2}, configKeys: []string{"rule_engine"}}
+	t.Cleanup(func() { delete(wasmPluginReleases, "v2-rule-engine") })
+	assert.Empty(t, unqualifiedConfigKeys(r.wasmPluginConfig(detecting, nil, "")), "a qualified release supports the key")
+}
+
+func TestPluginConfigVersion(t *testing.T) {
+	wasmPluginReleases["v2-config"] = wasmPluginRelease{cacheProtocols: []int{1}, configVersions: []int{1, 2, 3}}
+	t.Cleanup(func() { delete(wasmPluginReleases, "v2-config") })
+
+	version, ok := pluginConfigVersion(defaults.DefaultCorazaWasmOCIReference)
+	assert.True(t, ok)
+	assert.Equal(t, 1, version, "the default image parses the unversioned schema")
+
+	version, ok = pluginConfigVersion("oci://ghcr.io/example/coraza-proxy-wasm:v2-config")
+	assert.True(t, ok)
+	assert.Equal(t, wasmPluginConfigVersion, version, "the newest version both parse")
+
+	version, ok = pluginConfigVersion("oci://ghcr.io/example/coraza-proxy-wasm:custom")
+	assert.True(t, ok)
+	assert.Equal(t, wasmPluginConfigVersion, version, "unknown images get the latest version")
+
+	for v := 2; v <= wasmPluginConfigVersion; v++ {
+		assert.Contains(t, pluginConfigMigrations, v, "every version but the first has a migration")
+	}
+
+	r := &EngineReconciler{ruleSetCacheServerCluster: "cache"}
+	engine := utils.NewTestEngine(utils.EngineOptions{Name: "waf", Namespace: "team-a", GatewayName: "gateway"})
+	latest := r.buildWasmPlugin(engine, nil, "oci://ghcr.io/example/coraza-proxy-wasm:custom", "")
+	got, found, err := unstructured.NestedFieldNoCopy(latest.Object, "spec", "pluginConfig", pluginConfigVersionKey)
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, wasmPluginConfigVersion, got)
+
+	migrated := r.buildWasmPlugin(engine, nil, defaults.DefaultCorazaWasmOCIReference, "")
+	_, found, err = unstructured.NestedFieldNoCopy(migrated.Object, "spec", "pluginConfig", pluginConfigVersionKey)
+	require.NoError(t, err)
+	assert.False(t, found, "version 1 is unversioned")
+}
+
+func TestImageVersion(t *testing.T) {
+	assert.Equal(t, "v1", imageVersion("oci://ghcr.io/org/plugin:v1"))
+	assert.Equal(t, "sha256:abc", imageVersion("oci://ghcr.io/org/plugin:v1@sha256:abc"))
+	assert.Equal(t, "latest", imageVersion("oci://localhost:5000/org/plugin"))
+	assert.Empty(t, imageVersion("ghcr.io/org/plugin:v1"))
+}
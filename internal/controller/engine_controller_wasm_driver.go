@@ -18,12 +18,17 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
@@ -46,6 +51,14 @@ import (
 // WasmPluginNamePrefix is the prefix used for all created WasmPlugin resources
 const WasmPluginNamePrefix = "coraza-engine-"
 
+// defaultResponseBodyLimitBytes is the response body inspection limit used
+// when the Engine does not set one.
+const defaultResponseBodyLimitBytes = 524288
+
+// defaultResponseBodyMIMETypes are the MIME types of the response bodies
+// inspected when the Engine does not list any.
+var defaultResponseBodyMIMETypes = []string{"text/plain", "text/html"}
+
 // wasmPluginName returns the deterministic name for the WasmPlugin child
 // resource derived from the given Engine name. All call sites MUST use this
 // helper to keep the naming scheme consistent.
@@ -58,81 +71,171 @@ func wasmPluginName(engineName string) string {
 // -----------------------------------------------------------------------------
 
 // provisionWasmDriver provisions the Istio WasmPlugin resource for the Engine.
-func (r *EngineReconciler) provisionWasmDriver(ctx context.Context, log logr.Logger, req ctrl.Request, engine wafv1alpha1.Engine) (ctrl.Result, error) {
-	ws := targetLabelSelector(&engine)
+func (r *EngineReconciler) provisionWasmDriver(ctx context.Context, log logr.Logger, req ctrl.Request, engine *wafv1alpha1.Engine) (ctrl.Result, error) {
+	ws := targetLabelSelector(engine)
 	if ws == nil {
 		err := fmt.Errorf("target is required: cannot derive workload selector")
 		logError(log, req, "Engine", err, "Invalid target configuration")
-		if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "Engine", &engine, &engine.Status.Conditions, engine.Generation, "InvalidConfiguration", err.Error()); patchErr != nil {
+		if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "Engine", engine, &engine.Status.Conditions, engine.Generation, "InvalidConfiguration", err.Error()); patchErr != nil {
+			return ctrl.Result{}, patchErr
+		}
+		return ctrl.Result{}, err
+	}
+
+	if err := validateRedaction(engine.Spec.Redaction); err != nil {
+		logError(log, req, "Engine", err, "Invalid redaction configuration")
+		return ctrl.Result{}, patchDegraded(ctx, r.Status(), r.Recorder, log, req, "Engine", engine, &engine.Status.Conditions, engine.Generation, "InvalidConfiguration", err.Error())
+	}
+
+	if err := validateInspectionBypass(engine.Spec.InspectionBypass); err != nil {
+		logError(log, req, "Engine", err, "Invalid inspection bypass configuration")
+		return ctrl.Result{}, patchDegraded(ctx, r.Status(), r.Recorder, log, req, "Engine", engine, &engine.Status.Conditions, engine.Generation, "InvalidConfiguration", err.Error())
+	}
+
+	if err := validateSchedule(engine.Spec.Schedule); err != nil {
+		logError(log, req, "Engine", err, "Invalid schedule")
+		return ctrl.Result{}, patchDegraded(ctx, r.Status(), r.Recorder, log, req, "Engine", engine, &engine.Status.Conditions, engine.Generation, "InvalidConfiguration", err.Error())
+	}
+
+	if err := validateBlockResponse(engine.Spec.BlockResponse); err != nil {
+		logError(log, req, "Engine", err, "Invalid block response configuration")
+		return ctrl.Result{}, patchDegraded(ctx, r.Status(), r.Recorder, log, req, "Engine", engine, &engine.Status.Conditions, engine.Generation, "InvalidConfiguration", err.Error())
+	}
+
+	wasmURL, reason, err := r.wasmPluginImage(ctx, log, req, engine)
+	if err != nil {
+		if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "Engine", engine, &engine.Status.Conditions, engine.Generation, reason, err.Error()); patchErr != nil {
 			return ctrl.Result{}, patchErr
 		}
 		return ctrl.Result{}, err
 	}
 
+	ruleSet, err := r.engineRuleSet(ctx, engine)
+	if err != nil {
+		logAPIError(log, req, "Engine", err, "Failed to get RuleSet", nil)
+		return ctrl.Result{}, err
+	}
+
+	// Violations are re-evaluated when the Engine, its RuleSet or the
+	// OperatorConfig change; until then the WasmPlugin keeps its previous
+	// configuration.
+	if msg := guardrailViolations(r.runtimeConfig.EngineGuardrails(engine.Namespace), engine, ruleSet); msg != "" {
+		logInfo(log, req, "Engine", "Engine violates the guardrails", "detail", msg)
+		return ctrl.Result{}, patchDegraded(ctx, r.Status(), r.Recorder, log, req, "Engine", engine, &engine.Status.Conditions, engine.Generation, "GuardrailViolation", msg)
+	}
+
+	// Whatever the image, the features that no qualified release supports
+	// are refused rather than configured for a plugin that ignores them.
+	if keys := unqualifiedConfigKeys(r.wasmPluginConfig(engine, ruleSet, "")); len(keys) > 0 {
+		msg := fmt.Sprintf("no qualified WASM plugin release supports the configuration keys %s required by this Engine", strings.Join(keys, ", "))
+		logInfo(log, req, "Engine", "Engine requires unsupported WASM plugin configuration", "detail", msg)
+		return ctrl.Result{}, patchDegraded(ctx, r.Status(), r.Recorder, log, req, "Engine", engine, &engine.Status.Conditions, engine.Generation, "UnsupportedConfiguration", msg)
+	}
+
+	known, err := checkWasmCompatibility(wasmURL, r.wasmPluginConfig(engine, ruleSet, ""))
+	if err != nil {
+		logError(log, req, "Engine", err, "Incompatible WASM plugin image")
+		if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "Engine", engine, &engine.Status.Conditions, engine.Generation, "IncompatibleWasmImage", err.Error()); patchErr != nil {
+			return ctrl.Result{}, patchErr
+		}
+		return ctrl.Result{}, nil
+	}
+	if !known {
+		logInfo(log, req, "Engine", "WASM plugin image not in the compatibility table, using it anyway", "url", wasmURL)
+		r.Recorder.Eventf(engine, nil, "Warning", "UnknownWasmImage", "Provision", "WASM plugin image %s is not in the operator's compatibility table; its compatibility is not verified", wasmURL)
+	}
+
 	// Apply NetworkPolicy first to ensure network restrictions are in place
 	// before the WasmPlugin starts running. This prevents a partially-provisioned
 	// state where the plugin is active without the intended cache-server network
 	// restrictions.
-	if err := r.applyNetworkPolicy(ctx, log, req, &engine); err != nil {
-		if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "Engine", &engine, &engine.Status.Conditions, engine.Generation, "NetworkPolicyFailed", fmt.Sprintf("Failed to apply NetworkPolicy: %v", err)); patchErr != nil {
+	if err := r.applyNetworkPolicy(ctx, log, req, engine); err != nil {
+		if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "Engine", engine, &engine.Status.Conditions, engine.Generation, "NetworkPolicyFailed", fmt.Sprintf("Failed to apply NetworkPolicy: %v", err)); patchErr != nil {
 			return ctrl.Result{}, patchErr
 		}
 		return ctrl.Result{}, err
 	}
 
 	logDebug(log, req, "Engine", "Ensuring cache client ServiceAccount")
-	saName, err := r.ensureCacheClientServiceAccount(ctx, log, req, &engine)
+	saName, err := r.ensureCacheClientServiceAccount(ctx, log, req, engine)
 	if err != nil {
-		if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "Engine", &engine, &engine.Status.Conditions, engine.Generation, "ServiceAccountFailed", fmt.Sprintf("Failed to ensure cache client ServiceAccount: %v", err)); patchErr != nil {
+		if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "Engine", engine, &engine.Status.Conditions, engine.Generation, "ServiceAccountFailed", fmt.Sprintf("Failed to ensure cache client ServiceAccount: %v", err)); patchErr != nil {
 			return ctrl.Result{}, patchErr
 		}
 		return ctrl.Result{}, err
 	}
 
-	r.cleanupStaleTokens(req.Namespace, req.Name, engine.Spec.RuleSet.Name)
+	r.cleanupStaleTokens(req.Namespace, req.Name, activeRuleSetName(engine))
 
 	logDebug(log, req, "Engine", "Ensuring cache client token")
-	cacheToken, renewAt, err := r.ensureCacheToken(ctx, log, req, saName, engine.Spec.RuleSet.Name)
+	cacheToken, renewAt, err := r.ensureCacheToken(ctx, log, req, saName, activeRuleSetName(engine))
 	if err != nil {
-		if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "Engine", &engine, &engine.Status.Conditions, engine.Generation, "TokenFailed", fmt.Sprintf("Failed to ensure cache client token: %v", err)); patchErr != nil {
+		if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "Engine", engine, &engine.Status.Conditions, engine.Generation, "TokenFailed", fmt.Sprintf("Failed to ensure cache client token: %v", err)); patchErr != nil {
 			return ctrl.Result{}, patchErr
 		}
 		return ctrl.Result{}, err
 	}
 
-	wasmPlugin, err := r.applyWasmPlugin(ctx, log, req, &engine, cacheToken)
+	listenerPort, _, err := r.targetListenerPort(ctx, log, req, engine)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	wasmPlugin, err := r.applyWasmPlugin(ctx, log, req, engine, ruleSet, wasmURL, cacheToken, listenerPort)
 	if err != nil {
-		if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "Engine", &engine, &engine.Status.Conditions, engine.Generation, "ProvisioningFailed", fmt.Sprintf("Failed to create or update WasmPlugin: %v", err)); patchErr != nil {
+		if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "Engine", engine, &engine.Status.Conditions, engine.Generation, "ProvisioningFailed", fmt.Sprintf("Failed to create or update WasmPlugin: %v", err)); patchErr != nil {
+			return ctrl.Result{}, patchErr
+		}
+		return ctrl.Result{}, err
+	}
+
+	if err := r.reconcileAccessLog(ctx, log, req, engine, ruleSet); err != nil {
+		if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "Engine", engine, &engine.Status.Conditions, engine.Generation, "TelemetryFailed", fmt.Sprintf("Failed to create or update Telemetry: %v", err)); patchErr != nil {
 			return ctrl.Result{}, patchErr
 		}
 		return ctrl.Result{}, err
 	}
 
 	logDebug(log, req, "Engine", "Updating status after successful provisioning")
-	if patchErr := patchReady(ctx, r.Status(), r.Recorder, log, req, "Engine", &engine, &engine.Status.Conditions, engine.Generation, "Configured", "WasmPlugin successfully created/updated"); patchErr != nil {
+	if patchErr := patchReady(ctx, r.Status(), r.Recorder, log, req, "Engine", engine, &engine.Status.Conditions, engine.Generation, "Configured", "WasmPlugin successfully created/updated"); patchErr != nil {
 		return ctrl.Result{}, patchErr
 	}
-	r.Recorder.Eventf(&engine, nil, "Normal", "WasmPluginCreated", "Provision", "Created WasmPlugin %s/%s", wasmPlugin.GetNamespace(), wasmPlugin.GetName())
+	if err := r.patchEnforcementMode(ctx, log, req, engine); err != nil {
+		return ctrl.Result{}, err
+	}
+	configVersion, _ := pluginConfigVersion(wasmURL)
+	effective := effectiveConfig(wasmURL, migratePluginConfig(r.wasmPluginConfig(engine, ruleSet, ""), configVersion), listenerPort)
+	effective.Directives = probeRules([]*wafv1alpha1.Engine{engine}) + engineRules(engine)
+	if err := r.patchEffectiveConfig(ctx, log, req, engine, effective); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.patchWorkloadsSelected(ctx, log, req, engine); err != nil {
+		return ctrl.Result{}, err
+	}
+	heartbeatTimeout, err := r.patchDataPlaneReady(ctx, log, req, engine, wasmPlugin)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	r.Recorder.Eventf(engine, nil, "Normal", "WasmPluginCreated", "Provision", "Created WasmPlugin %s/%s", wasmPlugin.GetNamespace(), wasmPlugin.GetName())
 
 	// Schedule re-reconciliation at the token's renewal deadline. This is a
 	// single requeue that fires exactly when the token needs refreshing,
-	// avoiding repeated intermediate reconciliations.
+	// avoiding repeated intermediate reconciliations, or earlier when the
+	// heartbeat of the gateways times out before.
 	requeueAfter := max(time.Until(renewAt), time.Second)
+	if heartbeatTimeout > 0 {
+		requeueAfter = min(requeueAfter, heartbeatTimeout)
+	}
 	logDebug(log, req, "Engine", "Scheduling token renewal", "requeueAfter", requeueAfter)
 	return ctrl.Result{RequeueAfter: requeueAfter}, nil
 }
 
 // applyWasmPlugin builds the WasmPlugin resource, sets the controller reference,
 // and applies it via server-side apply.
-func (r *EngineReconciler) applyWasmPlugin(ctx context.Context, log logr.Logger, req ctrl.Request, engine *wafv1alpha1.Engine, cacheToken string) (*unstructured.Unstructured, error) {
+func (r *EngineReconciler) applyWasmPlugin(ctx context.Context, log logr.Logger, req ctrl.Request, engine *wafv1alpha1.Engine, ruleSet *wafv1alpha1.RuleSet, wasmURL, cacheToken string, listenerPort int64) (*unstructured.Unstructured, error) {
 	logDebug(log, req, "Engine", "Building WasmPlugin resource")
-	wasmURL, fromSpec := r.wasmPluginOCIURLSource(engine)
-	if fromSpec {
-		logDebug(log, req, "Engine", "WasmPlugin OCI URL from Engine spec", "url", wasmURL)
-	} else {
-		logDebug(log, req, "Engine", "WasmPlugin OCI URL from operator default", "url", wasmURL)
-	}
-	wasmPlugin := r.buildWasmPlugin(engine, wasmURL, cacheToken)
+	wasmPlugin := r.buildWasmPlugin(engine, ruleSet, wasmURL, cacheToken)
+	scopeWasmPluginToPort(wasmPlugin, listenerPort)
 
 	logDebug(log, req, "Engine", "Setting controller reference on WasmPlugin")
 	if err := controllerutil.SetControllerReference(engine, wasmPlugin, r.Scheme); err != nil {
@@ -154,25 +257,99 @@ func (r *EngineReconciler) applyWasmPlugin(ctx context.Context, log logr.Logger,
 // Engine Controller - WASM Driver - WasmPlugin Builder
 // -----------------------------------------------------------------------------
 
+// scopeWasmPluginToPort restricts wasmPlugin to the traffic the gateway
+// receives on port, the port of the listener the Engine is scoped to. A
+// port of 0 leaves the plugin inspecting every listener.
+func scopeWasmPluginToPort(wasmPlugin *unstructured.Unstructured, port int64) {
+	if port == 0 {
+		return
+	}
+	spec := wasmPlugin.Object["spec"].(map[string]any)
+	spec["match"] = []any{
+		map[string]any{
+			"ports": []any{map[string]any{"number": port}},
+		},
+	}
+}
+
 func (r *EngineReconciler) wasmPluginOCIURLSource(engine *wafv1alpha1.Engine) (url string, fromSpec bool) {
 	if engine.Spec.Driver.Wasm != nil && engine.Spec.Driver.Wasm.Image != "" {
 		return engine.Spec.Driver.Wasm.Image, true
 	}
+	if image := r.runtimeConfig.DefaultWasmImage(); image != "" {
+		return image, false
+	}
 	return r.defaultWasmImage, false
 }
 
-func (r *EngineReconciler) buildWasmPlugin(engine *wafv1alpha1.Engine, wasmURL string, cacheToken string) *unstructured.Unstructured {
-	rulesetKey := fmt.Sprintf("%s/%s", engine.Namespace, engine.Spec.RuleSet.Name)
+// wasmPluginImage returns the WASM plugin image for engine with the image
+// mirrors applied. A mirrored image is validated and, when image
+// verification is enabled, looked up in its registry. When platform
+// verification is enabled, the image must have a variant for the nodes of
+// the gateway. On error, it also returns the reason to report.
+func (r *EngineReconciler) wasmPluginImage(ctx context.Context, log logr.Logger, req ctrl.Request, engine *wafv1alpha1.Engine) (url, reason string, err error) {
+	wasmURL, fromSpec := r.wasmPluginOCIURLSource(engine)
+	if fromSpec {
+		logDebug(log, req, "Engine", "WasmPlugin OCI URL from Engine spec", "url", wasmURL)
+	} else {
+		logDebug(log, req, "Engine", "WasmPlugin OCI URL from operator default", "url", wasmURL)
+	}
+
+	mirrors := r.runtimeConfig.ImageMirrors()
+	if mirrors == nil {
+		mirrors = r.imageMirrors
+	}
+	url, mirrored := mirrorImage(wasmURL, mirrors)
+	if mirrored {
+		logDebug(log, req, "Engine", "WasmPlugin OCI URL rewritten by image mirror", "url", wasmURL, "mirroredURL", url)
+
+		if err := validateMirroredImage(url); err != nil {
+			return "", "InvalidImage", err
+		}
+		if r.imageResolver != nil {
+			switch err := r.imageResolver.resolve(ctx, url); {
+			case errors.Is(err, errImageNotFound):
+				return "", "ImageNotFound", fmt.Errorf("mirrored image %s (for %s) not found in registry", url, wasmURL)
+			case err != nil:
+				logInfo(log, req, "Engine", "Could not verify mirrored image, using it anyway", "url", url, "error", err.Error())
+			}
+		}
+	}
+
+	if r.platformResolver != nil {
+		switch err := r.checkImagePlatforms(ctx, engine, url); {
+		case errors.Is(err, errImagePlatformMissing):
+			return "", "ImagePlatformMissing", err
+		case err != nil:
+			logInfo(log, req, "Engine", "Could not verify image platforms, using it anyway", "url", url, "error", err.Error())
+		}
+	}
+	return url, "", nil
+}
 
-	failurePolicy := wafv1alpha1.FailurePolicyFail
-	if engine.Spec.FailurePolicy != "" {
-		failurePolicy = engine.Spec.FailurePolicy
+// engineRuleSet returns the RuleSet engine loads, or nil when it does not
+// exist.
+func (r *EngineReconciler) engineRuleSet(ctx context.Context, engine *wafv1alpha1.Engine) (*wafv1alpha1.RuleSet, error) {
+	var ruleSet wafv1alpha1.RuleSet
+	if err := r.Get(ctx, types.NamespacedName{Namespace: engine.Namespace, Name: activeRuleSetName(engine)}, &ruleSet); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
 	}
+	return &ruleSet, nil
+}
+
+// wasmPluginConfig returns the WasmPlugin pluginConfig for engine, of the
+// latest version, which uses ruleSet, or nil when the RuleSet does not exist.
+func (r *EngineReconciler) wasmPluginConfig(engine *wafv1alpha1.Engine, ruleSet *wafv1alpha1.RuleSet, cacheToken string) map[string]any {
+	rulesetKey := fmt.Sprintf("%s/%s", engine.Namespace, activeRuleSetName(engine))
 
 	pluginConfig := map[string]any{
+		pluginConfigVersionKey:  wasmPluginConfigVersion,
 		"cache_server_instance": rulesetKey,
 		"cache_server_cluster":  r.ruleSetCacheServerCluster,
-		"failure_policy":        string(failurePolicy),
+		"failure_policy":        string(failurePolicy(engine, time.Now())),
 		"cache_token":           cacheToken,
 	}
 
@@ -180,6 +357,111 @@ func (r *EngineReconciler) buildWasmPlugin(engine *wafv1alpha1.Engine, wasmURL s
 		pluginConfig["rule_reload_interval_seconds"] = engine.Spec.RuleSetCacheServer.PollIntervalSeconds
 	}
 
+	if inspection := engine.Spec.ResponseInspection; inspection != nil {
+		pluginConfig["process_response_headers"] = true
+		if body := inspection.Body; body != nil {
+			limit := body.LimitBytes
+			if limit == 0 {
+				limit = defaultResponseBodyLimitBytes
+			}
+			mimeTypes := body.MIMETypes
+			if len(mimeTypes) == 0 {
+				mimeTypes = defaultResponseBodyMIMETypes
+			}
+			types := make([]any, 0, len(mimeTypes))
+			for _, t := range mimeTypes {
+				types = append(types, t)
+			}
+			pluginConfig["process_response_body"] = true
+			pluginConfig["response_body_limit_bytes"] = limit
+			pluginConfig["response_body_mime_types"] = types
+		}
+	}
+
+	if redaction := engine.Spec.Redaction; redaction != nil {
+		if len(redaction.Headers) > 0 {
+			pluginConfig["redact_request_headers"] = redactionNames(redaction.Headers, true)
+		}
+		if len(redaction.Cookies) > 0 {
+			pluginConfig["redact_cookies"] = redactionNames(redaction.Cookies, false)
+		}
+		if len(redaction.BodyFields) > 0 {
+			pluginConfig["redact_body_fields"] = redactionNames(redaction.BodyFields, false)
+		}
+	}
+
+	if engine.Spec.VerdictMetadata != nil {
+		pluginConfig["verdict_metadata_namespace"] = verdictMetadataNamespace(engine)
+	}
+
+	if headers := correlationHeaders(engine); len(headers) > 0 {
+		values := make([]any, 0, len(headers))
+		for _, header := range headers {
+			values = append(values, header)
+		}
+		pluginConfig["audit_log_correlation_headers"] = values
+	}
+
+	if blockResponse := blockResponseConfig(engine); blockResponse != nil {
+		pluginConfig["block_response"] = blockResponse
+	}
+
+	if rateLimit := rateLimitConfig(engine); rateLimit != nil {
+		pluginConfig["rate_limit"] = rateLimit
+	}
+
+	if enforcementMode(engine) == wafv1alpha1.EnforcementModeDetect {
+		pluginConfig["rule_engine"] = detectionRuleEngine
+	}
+
+	if learningActive(engine) {
+		pluginConfig["match_report_interval_seconds"] = learningReportIntervalSeconds
+	}
+
+	if ruleSet != nil && engine.Spec.RuleSetCacheServer != nil && honeypotBlockDuration(ruleSet) > 0 {
+		pluginConfig["honeypot_report_interval_seconds"] = honeypotReportIntervalSeconds
+	}
+	return pluginConfig
+}
+
+// redactionNames returns the sorted names of a redaction list for the
+// pluginConfig, lowercased when they are matched case-insensitively.
+func redactionNames(names []string, lower bool) []any {
+	sorted := slices.Clone(names)
+	if lower {
+		for i, name := range sorted {
+			sorted[i] = strings.ToLower(name)
+		}
+	}
+	slices.Sort(sorted)
+	sorted = slices.Compact(sorted)
+	values := make([]any, 0, len(sorted))
+	for _, name := range sorted {
+		values = append(values, name)
+	}
+	return values
+}
+
+// validateRedaction checks what the CRD schema cannot: that the body field
+// patterns compile.
+func validateRedaction(redaction *wafv1alpha1.Redaction) error {
+	if redaction == nil {
+		return nil
+	}
+	for _, pattern := range redaction.BodyFields {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("redaction.bodyFields: %w", err)
+		}
+	}
+	return nil
+}
+
+func (r *EngineReconciler) buildWasmPlugin(engine *wafv1alpha1.Engine, ruleSet *wafv1alpha1.RuleSet, wasmURL string, cacheToken string) *unstructured.Unstructured {
+	// Plugins that only parse older pluginConfig versions are refused by
+	// checkWasmCompatibility, so the version is ignored here.
+	configVersion, _ := pluginConfigVersion(wasmURL)
+	pluginConfig := migratePluginConfig(r.wasmPluginConfig(engine, ruleSet, cacheToken), configVersion)
+
 	ws := targetLabelSelector(engine)
 	matchLabels := map[string]string{}
 	if ws != nil && ws.MatchLabels != nil {
@@ -193,6 +475,10 @@ func (r *EngineReconciler) buildWasmPlugin(engine *wafv1alpha1.Engine, wasmURL s
 			"metadata": map[string]any{
 				"name":      wasmPluginName(engine.Name),
 				"namespace": engine.Namespace,
+				"labels": map[string]any{
+					ManagedByLabel:                     ManagedByValue,
+					wafv1alpha1.LabelExcludeFromBackup: "true",
+				},
 			},
 			"spec": map[string]any{
 				"url":          wasmURL,
@@ -209,17 +495,10 @@ func (r *EngineReconciler) buildWasmPlugin(engine *wafv1alpha1.Engine, wasmURL s
 		spec["imagePullSecret"] = engine.Spec.Driver.Wasm.ImagePullSecret
 	}
 
-	wasmPlugin.SetGroupVersionKind(schema.GroupVersionKind{
-		Group:   "extensions.istio.io",
-		Version: "v1alpha1",
-		Kind:    "WasmPlugin",
-	})
+	wasmPlugin.SetGroupVersionKind(WasmPluginGVK)
 
 	if r.istioRevision != "" {
 		labels := wasmPlugin.GetLabels()
-		if labels == nil {
-			labels = map[string]string{}
-		}
 		labels["istio.io/rev"] = r.istioRevision
 		wasmPlugin.SetLabels(labels)
 	}
@@ -234,3 +513,7 @@ func (r *EngineReconciler) buildWasmPlugin(engine *wafv1alpha1.Engine, wasmURL s
 // gatewayNameLabel is the well-known label that Istio applies to Gateway pods
 // to identify which Gateway resource they belong to.
 const gatewayNameLabel = "gateway.networking.k8s.io/gateway-name"
+
+// ingressGatewayLabel is the label of the Istio ingress gateway pods that
+// are not managed through the Gateway API, such as istio=ingressgateway.
+const ingressGatewayLabel = "istio"
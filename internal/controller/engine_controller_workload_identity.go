@@ -0,0 +1,107 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	rcache "github.com/networking-incubator/coraza-kubernetes-operator/internal/rulesets/cache"
+)
+
+// -----------------------------------------------------------------------------
+// Engine Workload Identity
+// -----------------------------------------------------------------------------
+
+// WorkloadIdentityAuthorizer authorizes cache clients by the SPIFFE ID of
+// their SVID. The expected workload identity of an Engine is the one Istio
+// and SPIRE give its gateway pods, "/ns/<namespace>/sa/<service account>":
+// a client is authorized when its token belongs to an Engine using the
+// RuleSet, and a pod targeted by that Engine runs with the ServiceAccount of
+// its SPIFFE ID. It implements rcache.WorkloadAuthorizer.
+type WorkloadIdentityAuthorizer struct {
+	client    client.Reader
+	apiReader client.Reader
+}
+
+// NewWorkloadIdentityAuthorizer returns a WorkloadIdentityAuthorizer. The
+// gateway pods are listed with c, from the cache watched by the Engine
+// controller, and Engines and their ServiceAccounts are read with
+// apiReader.
+func NewWorkloadIdentityAuthorizer(c client.Reader, apiReader client.Reader) *WorkloadIdentityAuthorizer {
+	return &WorkloadIdentityAuthorizer{client: c, apiReader: apiReader}
+}
+
+// AuthorizeWorkload accepts id when caller is the cache client of an Engine
+// using the RuleSet of cacheKey, and id is the workload identity of a pod
+// targeted by that Engine.
+func (a *WorkloadIdentityAuthorizer) AuthorizeWorkload(ctx context.Context, caller rcache.AuthResult, id rcache.SPIFFEID, cacheKey string) error {
+	namespace, serviceAccount, ok := parseWorkloadPath(id.Path)
+	if !ok {
+		return fmt.Errorf("%w: SPIFFE ID %s does not identify a Kubernetes ServiceAccount", rcache.ErrWorkloadNotAuthorized, id)
+	}
+
+	engine, err := cacheClientEngine(ctx, a.apiReader, caller, cacheKey, rcache.ErrWorkloadNotAuthorized)
+	if err != nil {
+		return err
+	}
+	if namespace != engine.Namespace {
+		return fmt.Errorf("%w: SPIFFE ID %s is not in the namespace of Engine %s/%s", rcache.ErrWorkloadNotAuthorized, id, engine.Namespace, engine.Name)
+	}
+
+	labelSelector := targetLabelSelector(engine)
+	if labelSelector == nil {
+		return fmt.Errorf("%w: Engine %s/%s has no target", rcache.ErrWorkloadNotAuthorized, engine.Namespace, engine.Name)
+	}
+	selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+	if err != nil {
+		return err
+	}
+	var pods corev1.PodList
+	if err := a.client.List(ctx, &pods, client.InNamespace(engine.Namespace), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return err
+	}
+	for i := range pods.Items {
+		if podServiceAccount(&pods.Items[i]) == serviceAccount {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: SPIFFE ID %s is not the identity of a gateway of Engine %s/%s", rcache.ErrWorkloadNotAuthorized, id, engine.Namespace, engine.Name)
+}
+
+// parseWorkloadPath returns the namespace and ServiceAccount of a SPIFFE ID
+// path in the Kubernetes form "/ns/<namespace>/sa/<service account>".
+func parseWorkloadPath(path string) (string, string, bool) {
+	parts := strings.Split(path, "/")
+	if len(parts) != 5 || parts[0] != "" || parts[1] != "ns" || parts[3] != "sa" || parts[2] == "" || parts[4] == "" {
+		return "", "", false
+	}
+	return parts[2], parts[4], true
+}
+
+// podServiceAccount returns the ServiceAccount a pod runs with.
+func podServiceAccount(pod *corev1.Pod) string {
+	if pod.Spec.ServiceAccountName == "" {
+		return "default"
+	}
+	return pod.Spec.ServiceAccountName
+}
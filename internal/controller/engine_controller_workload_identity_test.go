@@ -0,0 +1,93 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	rcache "github.com/networking-incubator/coraza-kubernetes-operator/internal/rulesets/cache"
+	"github.com/networking-incubator/coraza-kubernetes-operator/test/utils"
+)
+
+func TestWorkloadIdentityAuthorizer(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	engine := utils.NewTestEngine(utils.EngineOptions{Name: "waf", Namespace: "team-a", GatewayName: "gateway"})
+	engine.UID = "engine-uid"
+	serviceAccount := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{
+		Name:      "coraza-engine-waf",
+		Namespace: "team-a",
+		OwnerReferences: []metav1.OwnerReference{{
+			APIVersion: wafv1alpha1.GroupVersion.String(),
+			Kind:       "Engine",
+			Name:       engine.Name,
+			UID:        engine.UID,
+			Controller: new(true),
+		}},
+	}}
+	gatewayPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "gateway-abc", Namespace: "team-a", Labels: map[string]string{gatewayNameLabel: "gateway"}},
+		Spec:       corev1.PodSpec{ServiceAccountName: "gateway-istio"},
+	}
+	otherPod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "team-a"},
+		Spec:       corev1.PodSpec{ServiceAccountName: "app"},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(engine, serviceAccount, gatewayPod, otherPod).Build()
+	authorizer := NewWorkloadIdentityAuthorizer(c, c)
+
+	caller := rcache.AuthResult{Namespace: "team-a", Name: "coraza-engine-waf"}
+	cacheKey := "team-a/" + engine.Spec.RuleSet.Name
+	spiffeID := func(path string) rcache.SPIFFEID {
+		return rcache.SPIFFEID{TrustDomain: "cluster.local", Path: path}
+	}
+
+	tests := []struct {
+		name       string
+		caller     rcache.AuthResult
+		id         rcache.SPIFFEID
+		cacheKey   string
+		authorized bool
+	}{
+		{name: "gateway workload", caller: caller, id: spiffeID("/ns/team-a/sa/gateway-istio"), cacheKey: cacheKey, authorized: true},
+		{name: "workload not targeted by the Engine", caller: caller, id: spiffeID("/ns/team-a/sa/app"), cacheKey: cacheKey},
+		{name: "workload in another namespace", caller: caller, id: spiffeID("/ns/team-b/sa/gateway-istio"), cacheKey: cacheKey},
+		{name: "not a Kubernetes workload", caller: caller, id: spiffeID("/gateway-istio"), cacheKey: cacheKey},
+		{name: "RuleSet not used by the Engine", caller: caller, id: spiffeID("/ns/team-a/sa/gateway-istio"), cacheKey: "team-a/other"},
+		{name: "caller not an Engine", caller: rcache.AuthResult{Namespace: "team-a", Name: "app"}, id: spiffeID("/ns/team-a/sa/gateway-istio"), cacheKey: cacheKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := authorizer.AuthorizeWorkload(t.Context(), tt.caller, tt.id, tt.cacheKey)
+			if tt.authorized {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, rcache.ErrWorkloadNotAuthorized)
+			}
+		})
+	}
+}
@@ -0,0 +1,331 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// EngineProbeReconciler - Vars
+// -----------------------------------------------------------------------------
+
+const (
+	// probeRuleID is the rule ID of the rule blocking the canary requests
+	// of the enforcement probe; RuleSources must not use it.
+	probeRuleID = 89800000
+
+	// probeHeader is the request header carrying the probe marker.
+	probeHeader = "X-Coraza-Probe"
+
+	// probeBlockStatus is the status the probe rule answers canary requests
+	// with. It is unlikely to be returned by anything else on the path, so
+	// that it proves the WAF blocked the request.
+	probeBlockStatus = http.StatusTeapot
+
+	// probeTimeout bounds the canary request sent to a gateway pod.
+	probeTimeout = 5 * time.Second
+
+	// defaultProbeInterval is the interval of a probe without
+	// intervalSeconds.
+	defaultProbeInterval = 5 * time.Minute
+
+	// probeMaxMessagePods is the maximum number of pods named in the probe
+	// status message.
+	probeMaxMessagePods = 5
+)
+
+// probeEnforcing is 1 for the Engines whose last probe was blocked by every
+// gateway pod, and 0 for the others.
+var probeEnforcing = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "coraza_engine_probe_enforcing",
+		Help: "Whether every gateway pod of the Engine blocked the last enforcement probe (1) or not (0).",
+	},
+	[]string{"namespace", "engine"},
+)
+
+// probesTotal counts the enforcement probes of the Engines by result.
+var probesTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "coraza_engine_probes_total",
+		Help: "Total number of enforcement probes of the Engine, by result.",
+	},
+	[]string{"namespace", "engine", "result"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(probeEnforcing, probesTotal)
+}
+
+// -----------------------------------------------------------------------------
+// EngineProbeReconciler - Rules
+// -----------------------------------------------------------------------------
+
+// probeMarker returns the value of the probe header the Engine blocks.
+func probeMarker(engine *wafv1alpha1.Engine) string {
+	return string(engine.UID)
+}
+
+// probeRules returns the SecRule blocking the canary requests of the
+// enforcement probes of engines, or an empty string when none has a probe.
+// The Engines sharing a RuleSet share the rule, which matches the marker of
+// each of them.
+func probeRules(engines []*wafv1alpha1.Engine) string {
+	var markers []string
+	for _, engine := range engines {
+		if engine.Spec.Probe != nil {
+			markers = append(markers, regexp.QuoteMeta(probeMarker(engine)))
+		}
+	}
+	if len(markers) == 0 {
+		return ""
+	}
+	slices.Sort(markers)
+	return fmt.Sprintf("# Enforcement probe generated from the Engine spec.probe\n"+
+		"SecRule REQUEST_HEADERS:%s \"@rx ^(?:%s)$\" \"id:%d,phase:1,deny,status:%d,nolog,t:none,msg:'Enforcement probe'\"\n",
+		probeHeader, strings.Join(markers, "|"), probeRuleID, probeBlockStatus)
+}
+
+// -----------------------------------------------------------------------------
+// EngineProbeReconciler
+// -----------------------------------------------------------------------------
+
+// EngineProbeReconciler periodically sends a canary request to the gateway
+// pods of the Engines with a probe, and reports whether they blocked it.
+type EngineProbeReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder events.EventRecorder
+
+	// httpClient sends the canary requests.
+	httpClient *http.Client
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *EngineProbeReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&wafv1alpha1.Engine{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Named("engine-probe").
+		Complete(r)
+}
+
+// -----------------------------------------------------------------------------
+// EngineProbeReconciler - Reconcile
+// -----------------------------------------------------------------------------
+
+// Reconcile probes the gateway pods of the Engine when its probe is due, and
+// schedules the next probe.
+func (r *EngineProbeReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	var engine wafv1alpha1.Engine
+	if err := r.Get(ctx, req.NamespacedName, &engine); err != nil {
+		if apierrors.IsNotFound(err) {
+			deleteProbeMetrics(req.Namespace, req.Name)
+			return ctrl.Result{}, nil
+		}
+		logAPIError(log, req, "Engine", err, "Failed to GET", nil)
+		return ctrl.Result{}, err
+	}
+
+	if engine.Spec.Probe == nil {
+		deleteProbeMetrics(engine.Namespace, engine.Name)
+		if engine.Status == nil || engine.Status.Probe == nil {
+			return ctrl.Result{}, nil
+		}
+		patch := client.MergeFrom(engine.DeepCopy())
+		engine.Status.Probe = nil
+		if err := r.Status().Patch(ctx, &engine, patch); err != nil {
+			logAPIError(log, req, "Engine", err, "Failed to clear probe status", &engine)
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{}, nil
+	}
+
+	interval := probeInterval(engine.Spec.Probe)
+	if engine.Status != nil && engine.Status.Probe != nil {
+		if due := time.Until(engine.Status.Probe.LastProbeTime.Add(interval)); due > 0 {
+			logDebug(log, req, "Engine", "Probe is not due", "requeueAfter", due)
+			return ctrl.Result{RequeueAfter: due}, nil
+		}
+	}
+
+	ws := targetLabelSelector(&engine)
+	if ws == nil {
+		return ctrl.Result{}, nil
+	}
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(engine.Namespace), client.MatchingLabels(ws.MatchLabels)); err != nil {
+		logAPIError(log, req, "Engine", err, "Failed to list gateway pods", nil)
+		return ctrl.Result{}, err
+	}
+
+	logDebug(log, req, "Engine", "Probing gateway pods", "podCount", len(pods.Items))
+	status := r.probe(ctx, &engine, pods.Items)
+	probesTotal.WithLabelValues(engine.Namespace, engine.Name, string(status.Result)).Inc()
+	if status.Result == wafv1alpha1.ProbeResultEnforcing {
+		probeEnforcing.WithLabelValues(engine.Namespace, engine.Name).Set(1)
+	} else {
+		probeEnforcing.WithLabelValues(engine.Namespace, engine.Name).Set(0)
+	}
+
+	patch := client.MergeFrom(engine.DeepCopy())
+	if engine.Status == nil {
+		engine.Status = &wafv1alpha1.EngineStatus{}
+	}
+	previous := engine.Status.Probe
+	engine.Status.Probe = status
+	if err := r.Status().Patch(ctx, &engine, patch); err != nil {
+		logAPIError(log, req, "Engine", err, "Failed to patch probe status", &engine)
+		return ctrl.Result{}, err
+	}
+	if status.Result != wafv1alpha1.ProbeResultEnforcing && (previous == nil || previous.Result != status.Result) {
+		r.Recorder.Eventf(&engine, nil, "Warning", "ProbeFailed", "Probe", "Enforcement probe result %s: %s", status.Result, status.Message)
+	}
+
+	logInfo(log, req, "Engine", "Probed gateway pods", "result", status.Result, "requeueAfter", interval)
+	return ctrl.Result{RequeueAfter: interval}, nil
+}
+
+// probeInterval returns the interval of probe.
+func probeInterval(probe *wafv1alpha1.EngineProbe) time.Duration {
+	if probe.IntervalSeconds > 0 {
+		return time.Duration(probe.IntervalSeconds) * time.Second
+	}
+	return defaultProbeInterval
+}
+
+// deleteProbeMetrics removes the probe metrics of an Engine.
+func deleteProbeMetrics(namespace, name string) {
+	probeEnforcing.DeleteLabelValues(namespace, name)
+	probesTotal.DeletePartialMatch(prometheus.Labels{"namespace": namespace, "engine": name})
+}
+
+// -----------------------------------------------------------------------------
+// EngineProbeReconciler - Probe
+// -----------------------------------------------------------------------------
+
+// probe sends the canary request to the running pods among pods, and
+// returns the resulting status.
+func (r *EngineProbeReconciler) probe(ctx context.Context, engine *wafv1alpha1.Engine, pods []corev1.Pod) *wafv1alpha1.ProbeStatus {
+	status := &wafv1alpha1.ProbeStatus{LastProbeTime: metav1.Now()}
+
+	port := engine.Spec.Probe.Port
+	if port == 0 {
+		port = 80
+		if hasIngressGatewayTarget(engine) {
+			port = 8080
+		}
+	}
+
+	var notEnforcing, unreachable []string
+	for _, pod := range pods {
+		if pod.Status.Phase != corev1.PodRunning || pod.Status.PodIP == "" || pod.DeletionTimestamp != nil {
+			continue
+		}
+		code, err := r.sendProbe(ctx, engine, net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(int(port))))
+		switch {
+		case err != nil:
+			unreachable = append(unreachable, pod.Name)
+		case code == probeBlockStatus:
+			status.PodsProbed++
+			status.PodsEnforcing++
+		default:
+			status.PodsProbed++
+			notEnforcing = append(notEnforcing, fmt.Sprintf("%s (status %d)", pod.Name, code))
+		}
+	}
+
+	switch {
+	case len(notEnforcing) > 0:
+		status.Result = wafv1alpha1.ProbeResultNotEnforcing
+		status.Message = "Gateway pods let the canary request through: " + joinProbePods(notEnforcing)
+	case status.PodsProbed == 0 && len(unreachable) == 0:
+		status.Result = wafv1alpha1.ProbeResultUnreachable
+		status.Message = "No running gateway pod to probe"
+	case status.PodsProbed == 0:
+		status.Result = wafv1alpha1.ProbeResultUnreachable
+		status.Message = "Gateway pods unreachable: " + joinProbePods(unreachable)
+	default:
+		status.Result = wafv1alpha1.ProbeResultEnforcing
+		status.Message = fmt.Sprintf("%d gateway pods blocked the canary request", status.PodsEnforcing)
+		if len(unreachable) > 0 {
+			status.Message += "; unreachable: " + joinProbePods(unreachable)
+		}
+	}
+	return status
+}
+
+// sendProbe sends the canary request of the Engine to the gateway pod at
+// addr, and returns the status it was answered with.
+func (r *EngineProbeReconciler) sendProbe(ctx context.Context, engine *wafv1alpha1.Engine, addr string) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	path := engine.Spec.Probe.Path
+	if path == "" {
+		path = "/"
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+addr+path, nil)
+	if err != nil {
+		return 0, err
+	}
+	if engine.Spec.Probe.Hostname != "" {
+		httpReq.Host = engine.Spec.Probe.Hostname
+	}
+	httpReq.Header.Set(probeHeader, probeMarker(engine))
+
+	resp, err := r.httpClient.Do(httpReq)
+	if err != nil {
+		return 0, err
+	}
+	_ = resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// joinProbePods joins the first pods named in a probe status message.
+func joinProbePods(pods []string) string {
+	slices.Sort(pods)
+	if len(pods) > probeMaxMessagePods {
+		return fmt.Sprintf("%s and %d more", strings.Join(pods[:probeMaxMessagePods], ", "), len(pods)-probeMaxMessagePods)
+	}
+	return strings.Join(pods, ", ")
+}
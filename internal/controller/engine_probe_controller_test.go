@@ -0,0 +1,167 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/corazawaf/coraza/v3"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/test/utils"
+)
+
+func TestProbeRules(t *testing.T) {
+	engine := utils.NewTestEngine(utils.EngineOptions{Name: "waf", Namespace: "team-a", GatewayName: "gateway"})
+	engine.UID = "engine-uid"
+	other := utils.NewTestEngine(utils.EngineOptions{Name: "other", Namespace: "team-a", GatewayName: "gateway"})
+	other.UID = "other-uid"
+	unprobed := utils.NewTestEngine(utils.EngineOptions{Name: "unprobed", Namespace: "team-a", GatewayName: "gateway"})
+	unprobed.UID = "unprobed-uid"
+	assert.Empty(t, probeRules([]*wafv1alpha1.Engine{engine, other}))
+
+	engine.Spec.Probe = &wafv1alpha1.EngineProbe{}
+	other.Spec.Probe = &wafv1alpha1.EngineProbe{}
+	waf, err := coraza.NewWAF(coraza.NewWAFConfig().WithDirectives("SecRuleEngine On\n" + probeRules([]*wafv1alpha1.Engine{engine, other, unprobed})))
+	require.NoError(t, err)
+
+	for marker, wantStatus := range map[string]int{"engine-uid": probeBlockStatus, "other-uid": probeBlockStatus, "unprobed-uid": 0, "engine-uid-2": 0} {
+		tx := waf.NewTransaction()
+		tx.ProcessURI("/", "GET", "HTTP/1.1")
+		tx.AddRequestHeader(probeHeader, marker)
+		interruption := tx.ProcessRequestHeaders()
+		if wantStatus == 0 {
+			assert.Nil(t, interruption, marker)
+		} else {
+			require.NotNil(t, interruption, marker)
+			assert.Equal(t, wantStatus, interruption.Status)
+		}
+		require.NoError(t, tx.Close())
+	}
+
+	r := &EngineReconciler{}
+	assert.NotContains(t, fmt.Sprint(r.wasmPluginConfig(engine, nil, "")), "89800000", "the probe rule is composed into the RuleSet")
+}
+
+func TestEngineProbeReconciler(t *testing.T) {
+	var blocking atomic.Bool
+	blocking.Store(true)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if blocking.Load() && r.Header.Get(probeHeader) == "engine-uid" && r.Host == "app.example.com" {
+			w.WriteHeader(probeBlockStatus)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	require.NoError(t, err)
+	portNumber, err := strconv.Atoi(port)
+	require.NoError(t, err)
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	engine := utils.NewTestEngine(utils.EngineOptions{Name: "waf", Namespace: "team-a", GatewayName: "gateway"})
+	engine.UID = "engine-uid"
+	engine.Spec.Probe = &wafv1alpha1.EngineProbe{IntervalSeconds: 60, Port: int32(portNumber), Hostname: "app.example.com"}
+	pod := func(name, ip string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "team-a", Labels: targetLabelSelector(engine).MatchLabels},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning, PodIP: ip},
+		}
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(engine, pod("gateway-a", "127.0.0.1")).
+		WithStatusSubresource(engine).
+		Build()
+	recorder := utils.NewFakeRecorder()
+	r := &EngineProbeReconciler{Client: c, Scheme: scheme, Recorder: recorder, httpClient: &http.Client{Timeout: probeTimeout}}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(engine)}
+
+	t.Log("Probing a gateway pod blocking the canary request")
+	result, err := r.Reconcile(t.Context(), req)
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, result.RequeueAfter)
+	require.NoError(t, c.Get(t.Context(), req.NamespacedName, engine))
+	require.NotNil(t, engine.Status.Probe)
+	assert.Equal(t, wafv1alpha1.ProbeResultEnforcing, engine.Status.Probe.Result)
+	assert.Equal(t, int32(1), engine.Status.Probe.PodsEnforcing)
+	assert.InDelta(t, 1, testutil.ToFloat64(probeEnforcing.WithLabelValues("team-a", "waf")), 0)
+
+	t.Log("Skipping the probe until it is due")
+	result, err = r.Reconcile(t.Context(), req)
+	require.NoError(t, err)
+	assert.InDelta(t, time.Minute, result.RequeueAfter, float64(5*time.Second))
+	assert.InDelta(t, 1, testutil.ToFloat64(probesTotal.WithLabelValues("team-a", "waf", "Enforcing")), 0)
+
+	t.Log("Probing gateway pods letting the canary request through")
+	blocking.Store(false)
+	require.NoError(t, c.Create(t.Context(), pod("gateway-b", "127.0.0.2")))
+	engine.Status.Probe.LastProbeTime = metav1.NewTime(time.Now().Add(-time.Hour))
+	require.NoError(t, c.Status().Update(t.Context(), engine))
+	_, err = r.Reconcile(t.Context(), req)
+	require.NoError(t, err)
+	require.NoError(t, c.Get(t.Context(), req.NamespacedName, engine))
+	assert.Equal(t, wafv1alpha1.ProbeResultNotEnforcing, engine.Status.Probe.Result)
+	assert.Equal(t, "Gateway pods let the canary request through: gateway-a (status 404)", engine.Status.Probe.Message)
+	assert.InDelta(t, 0, testutil.ToFloat64(probeEnforcing.WithLabelValues("team-a", "waf")), 0)
+	assert.True(t, recorder.HasEvent("Warning", "ProbeFailed"))
+
+	t.Log("Clearing the status when the probe is removed")
+	engine.Spec.Probe = nil
+	require.NoError(t, c.Update(t.Context(), engine))
+	_, err = r.Reconcile(t.Context(), req)
+	require.NoError(t, err)
+	require.NoError(t, c.Get(t.Context(), req.NamespacedName, engine))
+	assert.Nil(t, engine.Status.Probe)
+	assert.Zero(t, testutil.CollectAndCount(probeEnforcing))
+}
+
+func TestEngineProbeUnreachable(t *testing.T) {
+	engine := utils.NewTestEngine(utils.EngineOptions{Name: "waf", Namespace: "team-a", GatewayName: "gateway"})
+	engine.Spec.Probe = &wafv1alpha1.EngineProbe{Port: 1}
+	r := &EngineProbeReconciler{httpClient: &http.Client{Timeout: time.Second}}
+
+	status := r.probe(t.Context(), engine, nil)
+	assert.Equal(t, wafv1alpha1.ProbeResultUnreachable, status.Result)
+	assert.Equal(t, "No running gateway pod to probe", status.Message)
+
+	status = r.probe(t.Context(), engine, []corev1.Pod{
+		{ObjectMeta: metav1.ObjectMeta{Name: "gateway-a"}, Status: corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "127.0.0.1"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "gateway-pending"}, Status: corev1.PodStatus{Phase: corev1.PodPending}},
+	})
+	assert.Equal(t, wafv1alpha1.ProbeResultUnreachable, status.Result)
+	assert.Equal(t, "Gateway pods unreachable: gateway-a", status.Message)
+}
@@ -0,0 +1,263 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// FalsePositiveReconciler - RBAC
+// -----------------------------------------------------------------------------
+
+// +kubebuilder:rbac:groups=waf.k8s.coraza.io,resources=falsepositives,verbs=get;list;watch
+// +kubebuilder:rbac:groups=waf.k8s.coraza.io,resources=falsepositives/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=waf.k8s.coraza.io,resources=rulesources,verbs=create;patch
+
+// -----------------------------------------------------------------------------
+// FalsePositiveReconciler - Vars
+// -----------------------------------------------------------------------------
+
+const (
+	// falsePositiveRuleIDBase is the first rule ID of the exclusion rules
+	// generated for FalsePositives, which use IDs up to
+	// falsePositiveRuleIDBase+falsePositiveRuleIDRange-1; RuleSources must
+	// not use IDs from this range.
+	falsePositiveRuleIDBase = 89300000
+
+	// falsePositiveRuleIDRange is the number of rule IDs reserved for the
+	// exclusion rules of FalsePositives.
+	falsePositiveRuleIDRange = 100000
+
+	// falsePositiveComponent is the component label of the RuleSources
+	// generated for FalsePositives.
+	falsePositiveComponent = "false-positive-exclusion"
+)
+
+// falsePositiveRuleSourceName returns the name of the RuleSource generated
+// for the FalsePositive name.
+func falsePositiveRuleSourceName(name string) string {
+	return name + wafv1alpha1.FalsePositiveExclusionSuffix
+}
+
+// -----------------------------------------------------------------------------
+// FalsePositiveReconciler
+// -----------------------------------------------------------------------------
+
+// FalsePositiveReconciler generates the draft exclusion RuleSources of
+// FalsePositives and reports whether they were approved.
+type FalsePositiveReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder events.EventRecorder
+}
+
+// SetupWithManager sets up the controller with the Manager. The generated
+// RuleSources are watched to report their approval, which removes the
+// draft annotation.
+func (r *FalsePositiveReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&wafv1alpha1.FalsePositive{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&wafv1alpha1.RuleSource{},
+			handler.EnqueueRequestForOwner(mgr.GetScheme(), mgr.GetRESTMapper(), &wafv1alpha1.FalsePositive{}),
+			builder.WithPredicates(predicate.AnnotationChangedPredicate{}),
+		).
+		Named("falsepositive").
+		Complete(r)
+}
+
+// -----------------------------------------------------------------------------
+// FalsePositiveReconciler - Reconcile
+// -----------------------------------------------------------------------------
+
+// Reconcile generates the draft exclusion RuleSource of the FalsePositive
+// when it does not exist, and records whether it was approved.
+func (r *FalsePositiveReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	var fp wafv1alpha1.FalsePositive
+	if err := r.Get(ctx, req.NamespacedName, &fp); err != nil {
+		if apierrors.IsNotFound(err) {
+			logDebug(log, req, "FalsePositive", "Resource not found, the RuleSource is garbage collected")
+			return ctrl.Result{}, nil
+		}
+		logAPIError(log, req, "FalsePositive", err, "Failed to GET", nil)
+		return ctrl.Result{}, err
+	}
+
+	source, reason, err := r.ensureRuleSource(ctx, log, req, &fp)
+	if err != nil {
+		msg := fmt.Sprintf("Failed to generate RuleSource %s: %v", falsePositiveRuleSourceName(fp.Name), err)
+		if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "FalsePositive", &fp, &fp.Status.Conditions, fp.Generation, reason, msg); patchErr != nil {
+			return ctrl.Result{}, patchErr
+		}
+		if reason == "RuleSourceConflict" {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	phase, reason := wafv1alpha1.FalsePositivePhasePending, "PendingApproval"
+	msg := fmt.Sprintf("Exclusion suggested in draft RuleSource %s, remove its %s annotation to approve it", source.Name, wafv1alpha1.AnnotationDraft)
+	if source.Annotations[wafv1alpha1.AnnotationDraft] != "true" {
+		phase, reason = wafv1alpha1.FalsePositivePhaseApproved, "Approved"
+		msg = fmt.Sprintf("Exclusion approved in RuleSource %s; reference it in a RuleSet before the rules it excludes", source.Name)
+	}
+	ready := apimeta.FindStatusCondition(fp.Status.Conditions, conditionReady)
+	if fp.Status.Phase == phase && fp.Status.RuleSource == source.Name && ready != nil && ready.Status == metav1.ConditionTrue && ready.ObservedGeneration == fp.Generation {
+		return ctrl.Result{}, nil
+	}
+
+	patch := client.MergeFrom(fp.DeepCopy())
+	fp.Status.Phase = phase
+	fp.Status.RuleSource = source.Name
+	applyStatusReady(&fp.Status.Conditions, fp.Generation, reason, msg)
+	if err := r.Status().Patch(ctx, &fp, patch); err != nil {
+		logAPIError(log, req, "FalsePositive", err, "Failed to patch status", &fp)
+		return ctrl.Result{}, err
+	}
+
+	logInfo(log, req, "FalsePositive", "Exclusion "+strings.ToLower(string(phase)), "ruleSource", source.Name)
+	if phase == wafv1alpha1.FalsePositivePhaseApproved {
+		r.Recorder.Eventf(&fp, nil, "Normal", "ExclusionApproved", "Reconcile", msg)
+	} else {
+		r.Recorder.Eventf(&fp, nil, "Normal", "ExclusionSuggested", "Reconcile", msg)
+	}
+	return ctrl.Result{}, nil
+}
+
+// ensureRuleSource creates the draft exclusion RuleSource of fp when it
+// does not exist, and returns it. An existing RuleSource is never
+// overwritten, so that the edits of the reviewer and the approval are
+// kept; it is adopted when it was generated for a FalsePositive of the same
+// name, for example before a restore. On error, it also returns the reason
+// to report.
+func (r *FalsePositiveReconciler) ensureRuleSource(ctx context.Context, log logr.Logger, req ctrl.Request, fp *wafv1alpha1.FalsePositive) (*wafv1alpha1.RuleSource, string, error) {
+	name := falsePositiveRuleSourceName(fp.Name)
+	var source wafv1alpha1.RuleSource
+	err := r.Get(ctx, types.NamespacedName{Namespace: fp.Namespace, Name: name}, &source)
+	if apierrors.IsNotFound(err) {
+		source = wafv1alpha1.RuleSource{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: fp.Namespace,
+				Labels: map[string]string{
+					ManagedByLabel:                ManagedByValue,
+					"app.kubernetes.io/component": falsePositiveComponent,
+					"app.kubernetes.io/instance":  fp.Name,
+				},
+				Annotations: map[string]string{
+					wafv1alpha1.AnnotationDraft: "true",
+				},
+			},
+			Spec: wafv1alpha1.RuleSourceSpec{
+				Rules: falsePositiveExclusionRules(fp),
+			},
+		}
+		// The FalsePositive is an owner, but not the controller: the
+		// RuleSource is deleted with it, but is backed up with its
+		// approval rather than regenerated as a draft on restore.
+		if err := controllerutil.SetOwnerReference(fp, &source, r.Scheme); err != nil {
+			logError(log, req, "FalsePositive", err, "Failed to set owner reference on RuleSource")
+			return nil, "RuleSourceFailed", err
+		}
+		if err := r.Create(ctx, &source); err != nil {
+			logAPIError(log, req, "FalsePositive", err, "Failed to create the exclusion RuleSource", fp)
+			return nil, "RuleSourceFailed", err
+		}
+		return &source, "", nil
+	}
+	if err != nil {
+		logAPIError(log, req, "FalsePositive", err, "Failed to GET RuleSource", nil)
+		return nil, "RuleSourceFailed", err
+	}
+
+	if source.Labels[ManagedByLabel] != ManagedByValue || source.Labels["app.kubernetes.io/component"] != falsePositiveComponent {
+		return nil, "RuleSourceConflict", fmt.Errorf("RuleSource %s already exists and was not generated for a FalsePositive", name)
+	}
+	owned, err := controllerutil.HasOwnerReference(source.OwnerReferences, fp, r.Scheme)
+	if err != nil {
+		return nil, "RuleSourceFailed", err
+	}
+	if !owned {
+		patch := client.MergeFrom(source.DeepCopy())
+		if err := controllerutil.SetOwnerReference(fp, &source, r.Scheme); err != nil {
+			return nil, "RuleSourceFailed", err
+		}
+		if err := r.Patch(ctx, &source, patch); err != nil {
+			logAPIError(log, req, "FalsePositive", err, "Failed to adopt the exclusion RuleSource", fp)
+			return nil, "RuleSourceFailed", err
+		}
+	}
+	return &source, "", nil
+}
+
+// -----------------------------------------------------------------------------
+// FalsePositiveReconciler - Rules
+// -----------------------------------------------------------------------------
+
+// falsePositiveRuleID returns the ID of the exclusion rule of fp, derived
+// from its namespace and name so that the exclusions of FalsePositives
+// referenced by the same RuleSet are unlikely to collide.
+func falsePositiveRuleID(fp *wafv1alpha1.FalsePositive) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(fp.Namespace + "/" + fp.Name))
+	return falsePositiveRuleIDBase + int(h.Sum32()%falsePositiveRuleIDRange)
+}
+
+// falsePositiveExclusionRules returns the SecLang excluding the rule of fp
+// for its path only: a phase 1 rule matching the path that removes the rule,
+// or its target, from the rest of the transaction.
+func falsePositiveExclusionRules(fp *wafv1alpha1.FalsePositive) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Exclusion suggested by FalsePositive %s for rule %d on %s.\n", fp.Name, fp.Spec.RuleID, fp.Spec.Path)
+	if fp.Spec.RequestID != "" {
+		fmt.Fprintf(&b, "# Blocked request: %s\n", fp.Spec.RequestID)
+	}
+	if note := strings.Join(strings.Fields(fp.Spec.Note), " "); note != "" {
+		fmt.Fprintf(&b, "# Note: %s\n", note)
+	}
+	fmt.Fprintf(&b, "# Review it, then remove the %s annotation to approve.\n", wafv1alpha1.AnnotationDraft)
+	fmt.Fprintf(&b, "# Reference it in spec.sources before the RuleSource defining rule %d.\n", fp.Spec.RuleID)
+
+	ctl := fmt.Sprintf("ctl:ruleRemoveById=%d", fp.Spec.RuleID)
+	if fp.Spec.Target != "" {
+		ctl = fmt.Sprintf("ctl:ruleRemoveTargetById=%d;%s", fp.Spec.RuleID, fp.Spec.Target)
+	}
+	fmt.Fprintf(&b, "SecRule REQUEST_FILENAME \"@streq %s\" \"id:%d,phase:1,pass,nolog,%s\"\n", fp.Spec.Path, falsePositiveRuleID(fp), ctl)
+	return b.String()
+}
@@ -0,0 +1,186 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/corazawaf/coraza/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/test/utils"
+)
+
+func TestFalsePositiveExclusionRules(t *testing.T) {
+	const rule = `SecRule ARGS "@contains attack" "id:1001,phase:2,deny,status:403"`
+
+	tests := []struct {
+		name   string
+		target string
+		// blocked maps request URIs to whether rule 1001 blocks them.
+		blocked map[string]bool
+	}{
+		{
+			name: "whole rule",
+			blocked: map[string]bool{
+				"/search?q=attack":          false,
+				"/search?q=ok&page=attack":  false,
+				"/search/more?q=attack":     true,
+				"/other?q=attack":           true,
+				"/other?q=ok&page=no-block": false,
+			},
+		},
+		{
+			name:   "single target",
+			target: "ARGS:q",
+			blocked: map[string]bool{
+				"/search?q=attack":         false,
+				"/search?q=ok&page=attack": true,
+				"/other?q=attack":          true,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fp := &wafv1alpha1.FalsePositive{
+				ObjectMeta: metav1.ObjectMeta{Name: "search-query", Namespace: "team-a"},
+				Spec: wafv1alpha1.FalsePositiveSpec{
+					RuleID:    1001,
+					Path:      "/search",
+					Target:    tt.target,
+					RequestID: "aBcD123",
+					Note:      "Users search\nfor security topics",
+				},
+			}
+			rules := falsePositiveExclusionRules(fp)
+			assert.Contains(t, rules, "# Blocked request: aBcD123\n")
+			assert.Contains(t, rules, "# Note: Users search for security topics\n")
+
+			id := falsePositiveRuleID(fp)
+			assert.GreaterOrEqual(t, id, falsePositiveRuleIDBase)
+			assert.Less(t, id, falsePositiveRuleIDBase+falsePositiveRuleIDRange)
+
+			waf, err := coraza.NewWAF(coraza.NewWAFConfig().WithDirectives("SecRuleEngine On\n" + rules + rule))
+			require.NoError(t, err)
+			for uri, blocked := range tt.blocked {
+				tx := waf.NewTransaction()
+				tx.ProcessURI(uri, "GET", "HTTP/1.1")
+				tx.ProcessRequestHeaders()
+				interruption, err := tx.ProcessRequestBody()
+				require.NoError(t, err)
+				assert.Equal(t, blocked, interruption != nil, "request %s", uri)
+				_ = tx.Close()
+			}
+		})
+	}
+}
+
+func TestFalsePositiveReconciler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+
+	fp := &wafv1alpha1.FalsePositive{
+		ObjectMeta: metav1.ObjectMeta{Name: "search-query", Namespace: "team-a", Generation: 1, UID: "fp-uid"},
+		Spec:       wafv1alpha1.FalsePositiveSpec{RuleID: 942100, Path: "/search", Target: "ARGS:q"},
+	}
+	conflicting := &wafv1alpha1.FalsePositive{
+		ObjectMeta: metav1.ObjectMeta{Name: "custom", Namespace: "team-a", Generation: 1},
+		Spec:       wafv1alpha1.FalsePositiveSpec{RuleID: 942100, Path: "/"},
+	}
+	userSource := &wafv1alpha1.RuleSource{
+		ObjectMeta: metav1.ObjectMeta{Name: "custom-exclusion", Namespace: "team-a"},
+		Spec:       wafv1alpha1.RuleSourceSpec{Rules: "SecRuleRemoveById 942100"},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(fp, conflicting, userSource).
+		WithStatusSubresource(fp, conflicting).
+		Build()
+	recorder := utils.NewFakeRecorder()
+	r := &FalsePositiveReconciler{Client: c, Scheme: scheme, Recorder: recorder}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "team-a", Name: "search-query"}}
+	sourceKey := types.NamespacedName{Namespace: "team-a", Name: "search-query-exclusion"}
+
+	_, err := r.Reconcile(t.Context(), req)
+	require.NoError(t, err)
+
+	var source wafv1alpha1.RuleSource
+	require.NoError(t, c.Get(t.Context(), sourceKey, &source))
+	assert.Equal(t, "true", source.Annotations[wafv1alpha1.AnnotationDraft])
+	assert.Contains(t, source.Spec.Rules, "ctl:ruleRemoveTargetById=942100;ARGS:q")
+	require.Len(t, source.OwnerReferences, 1)
+	assert.Equal(t, "search-query", source.OwnerReferences[0].Name)
+	assert.Nil(t, source.OwnerReferences[0].Controller, "the RuleSource is backed up with its approval")
+
+	require.NoError(t, c.Get(t.Context(), req.NamespacedName, fp))
+	assert.Equal(t, wafv1alpha1.FalsePositivePhasePending, fp.Status.Phase)
+	assert.Equal(t, "search-query-exclusion", fp.Status.RuleSource)
+	assert.True(t, apimeta.IsStatusConditionTrue(fp.Status.Conditions, conditionReady))
+
+	t.Run("unchanged exclusion is not reported again", func(t *testing.T) {
+		events := len(recorder.Events)
+		_, err := r.Reconcile(t.Context(), req)
+		require.NoError(t, err)
+		assert.Len(t, recorder.Events, events)
+	})
+
+	t.Run("approval", func(t *testing.T) {
+		source.Spec.Rules += "# reviewed\n"
+		delete(source.Annotations, wafv1alpha1.AnnotationDraft)
+		require.NoError(t, c.Update(t.Context(), &source))
+
+		_, err := r.Reconcile(t.Context(), req)
+		require.NoError(t, err)
+		require.NoError(t, c.Get(t.Context(), req.NamespacedName, fp))
+		assert.Equal(t, wafv1alpha1.FalsePositivePhaseApproved, fp.Status.Phase)
+
+		require.NoError(t, c.Get(t.Context(), sourceKey, &source))
+		assert.Contains(t, source.Spec.Rules, "# reviewed\n", "the reviewed RuleSource is not overwritten")
+	})
+
+	t.Run("restored RuleSource is adopted", func(t *testing.T) {
+		source.OwnerReferences = nil
+		require.NoError(t, c.Update(t.Context(), &source))
+
+		_, err := r.Reconcile(t.Context(), req)
+		require.NoError(t, err)
+		require.NoError(t, c.Get(t.Context(), sourceKey, &source))
+		require.Len(t, source.OwnerReferences, 1)
+		assert.Equal(t, fp.UID, source.OwnerReferences[0].UID)
+	})
+
+	t.Run("RuleSource not generated for a FalsePositive", func(t *testing.T) {
+		req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "team-a", Name: "custom"}}
+		_, err := r.Reconcile(t.Context(), req)
+		require.NoError(t, err)
+
+		require.NoError(t, c.Get(t.Context(), req.NamespacedName, conflicting))
+		degraded := apimeta.FindStatusCondition(conflicting.Status.Conditions, conditionDegraded)
+		require.NotNil(t, degraded)
+		assert.Equal(t, "RuleSourceConflict", degraded.Reason)
+
+		require.NoError(t, c.Get(t.Context(), types.NamespacedName{Namespace: "team-a", Name: "custom-exclusion"}, userSource))
+		assert.Equal(t, "SecRuleRemoveById 942100", userSource.Spec.Rules)
+	})
+}
@@ -0,0 +1,479 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// FleetReconciler - RBAC
+// -----------------------------------------------------------------------------
+
+// +kubebuilder:rbac:groups="",namespace=system,resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups=waf.k8s.coraza.io,resources=rulesets/finalizers,verbs=update
+
+// -----------------------------------------------------------------------------
+// FleetReconciler - Vars
+// -----------------------------------------------------------------------------
+
+const (
+	// fleetFinalizer is added to propagated resources so that their copies
+	// are removed from the member clusters before they are deleted.
+	fleetFinalizer = "waf.k8s.coraza.io/fleet-cleanup"
+
+	// conditionClustersReady aggregates the Ready condition of the copies of
+	// a propagated resource across all member clusters.
+	conditionClustersReady = "ClustersReady"
+
+	// fleetResyncPeriod is how often the status of member cluster copies is
+	// polled. Member clusters are not watched.
+	fleetResyncPeriod = 30 * time.Second
+)
+
+// fleetKind describes a resource kind that the FleetReconciler propagates.
+type fleetKind struct {
+	name string
+	// resource is the plural resource name of the kind.
+	resource   string
+	newObject  func() client.Object
+	newList    func() client.ObjectList
+	conditions func(client.Object) *[]metav1.Condition
+
+	// dependencies returns the objects propagated along with obj. Only the
+	// name and namespace of the returned objects are set.
+	dependencies func(obj client.Object) []client.Object
+
+	// dependencyIndexes maps each dependency type to the field index that
+	// finds the resources referencing it by its "namespace/name" key.
+	dependencyIndexes map[client.Object]string
+}
+
+// ruleSetFleetKind propagates RuleSets together with the RuleSources and
+// RuleData they reference, including the RuleData of their ThreatFeeds.
+var ruleSetFleetKind = fleetKind{
+	name:      "RuleSet",
+	resource:  "rulesets",
+	newObject: func() client.Object { return &wafv1alpha1.RuleSet{} },
+	newList:   func() client.ObjectList { return &wafv1alpha1.RuleSetList{} },
+	conditions: func(obj client.Object) *[]metav1.Condition {
+		return &obj.(*wafv1alpha1.RuleSet).Status.Conditions
+	},
+	dependencies: func(obj client.Object) []client.Object {
+		rs := obj.(*wafv1alpha1.RuleSet)
+		deps := make([]client.Object, 0, len(rs.Spec.Sources)+len(rs.Spec.Data)+len(rs.Spec.ThreatFeeds))
+		for _, src := range rs.Spec.Sources {
+			if !isRuleSourceReference(src) {
+				continue
+			}
+			deps = append(deps, &wafv1alpha1.RuleSource{ObjectMeta: metav1.ObjectMeta{Name: src.Name, Namespace: referenceNamespace(rs, src.Namespace)}})
+		}
+		for _, d := range rs.Spec.Data {
+			deps = append(deps, &wafv1alpha1.RuleData{ObjectMeta: metav1.ObjectMeta{Name: d.Name, Namespace: referenceNamespace(rs, d.Namespace)}})
+		}
+		for _, feed := range rs.Spec.ThreatFeeds {
+			deps = append(deps, &wafv1alpha1.RuleData{ObjectMeta: metav1.ObjectMeta{Name: threatFeedDataName(feed.Name), Namespace: rs.Namespace}})
+		}
+		return deps
+	},
+	dependencyIndexes: map[client.Object]string{
+		&wafv1alpha1.RuleSource{}: ruleSetSourcesIndex,
+		&wafv1alpha1.RuleData{}:   ruleSetDataIndex,
+	},
+}
+
+// engineFleetKind propagates Engines. The RuleSet an Engine references must
+// be propagated separately.
+var engineFleetKind = fleetKind{
+	name:      "Engine",
+	resource:  "engines",
+	newObject: func() client.Object { return &wafv1alpha1.Engine{} },
+	newList:   func() client.ObjectList { return &wafv1alpha1.EngineList{} },
+	conditions: func(obj client.Object) *[]metav1.Condition {
+		engine := obj.(*wafv1alpha1.Engine)
+		if engine.Status == nil {
+			engine.Status = &wafv1alpha1.EngineStatus{}
+		}
+		return &engine.Status.Conditions
+	},
+	dependencies: func(client.Object) []client.Object { return nil },
+}
+
+// -----------------------------------------------------------------------------
+// Fleet Propagation
+// -----------------------------------------------------------------------------
+
+// fleetPropagator delivers propagated resources to member clusters. The
+// kubeconfig propagator writes to member clusters directly; the Open Cluster
+// Management propagator leaves that to ManifestWorks.
+type fleetPropagator interface {
+	// propagate delivers objects to the member clusters selected for owner,
+	// which is the last of objects, and returns its rollout in each of them.
+	propagate(ctx context.Context, kind fleetKind, owner client.Object, objects []client.Object) ([]clusterRollout, error)
+
+	// withdraw removes the copies of owner and of dependencies from all
+	// member clusters.
+	withdraw(ctx context.Context, kind fleetKind, owner client.Object, dependencies []client.Object) error
+
+	// watches adds the watches that keep propagation up to date to b.
+	watches(b *builder.Builder, r *FleetReconciler) *builder.Builder
+}
+
+// clusterRollout is the state of a propagated resource in one member cluster.
+type clusterRollout struct {
+	cluster string
+	// notReady says why the copy is not ready, or is empty when it is.
+	notReady string
+}
+
+// fleetConfigError is returned by a fleetPropagator when the resource itself
+// does not say where to propagate it. It is reported in the ClustersReady
+// condition rather than retried.
+type fleetConfigError struct {
+	reason  string
+	message string
+}
+
+func (e *fleetConfigError) Error() string { return e.message }
+
+// -----------------------------------------------------------------------------
+// FleetReconciler
+// -----------------------------------------------------------------------------
+
+// FleetReconciler propagates resources labeled with
+// wafv1alpha1.LabelPropagate to member clusters and aggregates the Ready
+// condition of their copies into the ClustersReady condition. The operator in
+// each member cluster reconciles the copies.
+type FleetReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// Propagator delivers the resources to the member clusters.
+	Propagator fleetPropagator
+
+	kind fleetKind
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *FleetReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(r.kind.newObject(), builder.WithPredicates(predicate.Or(
+			predicate.GenerationChangedPredicate{},
+			predicate.LabelChangedPredicate{},
+			predicate.AnnotationChangedPredicate{},
+		)))
+	for obj, index := range r.kind.dependencyIndexes {
+		b = b.Watches(
+			obj,
+			handler.EnqueueRequestsFromMapFunc(r.findDependents(index)),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}),
+		)
+	}
+	return r.Propagator.watches(b, r).Named("fleet-" + strings.ToLower(r.kind.name)).Complete(r)
+}
+
+// -----------------------------------------------------------------------------
+// FleetReconciler - Reconcile
+// -----------------------------------------------------------------------------
+
+// Reconcile propagates one resource to the member clusters, or withdraws it
+// when it is no longer labeled for propagation or is being deleted.
+func (r *FleetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+	kind := r.kind.name
+
+	obj := r.kind.newObject()
+	if err := r.Get(ctx, req.NamespacedName, obj); err != nil {
+		if apierrors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		logAPIError(log, req, kind, err, "Failed to GET", nil)
+		return ctrl.Result{}, err
+	}
+
+	if !isPropagated(obj) {
+		return ctrl.Result{}, r.withdraw(ctx, req, obj)
+	}
+
+	if !controllerutil.ContainsFinalizer(obj, fleetFinalizer) {
+		patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+		controllerutil.AddFinalizer(obj, fleetFinalizer)
+		if err := r.Patch(ctx, obj, patch); err != nil {
+			logAPIError(log, req, kind, err, "Failed to add fleet finalizer", obj)
+			return ctrl.Result{}, err
+		}
+	}
+
+	objects, err := r.propagatedObjects(ctx, obj)
+	if err != nil {
+		logError(log, req, kind, err, "Failed to load dependencies")
+		return ctrl.Result{}, err
+	}
+
+	rollouts, err := r.Propagator.propagate(ctx, r.kind, obj, objects)
+	var configErr *fleetConfigError
+	if err != nil && !errors.As(err, &configErr) {
+		logError(log, req, kind, err, "Failed to propagate to member clusters")
+		return ctrl.Result{}, err
+	}
+
+	var notReady []string
+	for _, rollout := range rollouts {
+		if rollout.notReady != "" {
+			notReady = append(notReady, rollout.cluster+": "+rollout.notReady)
+		}
+	}
+	logDebug(log, req, kind, "Propagated to member clusters", "members", len(rollouts), "notReady", len(notReady))
+
+	conditions := r.kind.conditions(obj)
+	generation := obj.GetGeneration()
+	err = patchConditions(ctx, r.Status(), log, req, kind, obj, conditions, func() {
+		switch {
+		case configErr != nil:
+			setConditionFalse(conditions, generation, conditionClustersReady, configErr.reason, configErr.message)
+		case len(rollouts) == 0:
+			setConditionFalse(conditions, generation, conditionClustersReady, "NoMemberClusters", "No member clusters to propagate to")
+		case len(notReady) == 0:
+			setConditionTrue(conditions, generation, conditionClustersReady, "AllClustersReady", fmt.Sprintf("Ready in %d member clusters", len(rollouts)))
+		default:
+			setConditionFalse(conditions, generation, conditionClustersReady, "ClustersNotReady",
+				fmt.Sprintf("%d of %d member clusters not ready: %s", len(notReady), len(rollouts), strings.Join(notReady, "; ")))
+		}
+	})
+	return ctrl.Result{RequeueAfter: fleetResyncPeriod}, err
+}
+
+// isPropagated reports whether obj is labeled for propagation and not being
+// deleted.
+func isPropagated(obj client.Object) bool {
+	return obj.GetLabels()[wafv1alpha1.LabelPropagate] == "true" && obj.GetDeletionTimestamp().IsZero()
+}
+
+// propagatedObjects returns the dependencies of obj that exist in this
+// cluster followed by obj itself. Missing dependencies are skipped; the
+// resource reports them in its own status.
+func (r *FleetReconciler) propagatedObjects(ctx context.Context, obj client.Object) ([]client.Object, error) {
+	var objects []client.Object
+	for _, dep := range r.kind.dependencies(obj) {
+		if err := r.Get(ctx, client.ObjectKeyFromObject(dep), dep); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		objects = append(objects, dep)
+	}
+	return append(objects, obj), nil
+}
+
+// -----------------------------------------------------------------------------
+// FleetReconciler - Withdrawal
+// -----------------------------------------------------------------------------
+
+// withdraw removes the copies of obj, and of the dependencies no other
+// propagated resource needs, from the member clusters and then releases the
+// finalizer.
+func (r *FleetReconciler) withdraw(ctx context.Context, req ctrl.Request, obj client.Object) error {
+	log := logf.FromContext(ctx)
+	kind := r.kind.name
+
+	if !controllerutil.ContainsFinalizer(obj, fleetFinalizer) {
+		return nil
+	}
+
+	retained, err := r.retainedDependencies(ctx, obj)
+	if err != nil {
+		logError(log, req, kind, err, "Failed to list propagated resources")
+		return err
+	}
+	var dependencies []client.Object
+	for _, dep := range r.kind.dependencies(obj) {
+		if !retained[dependencyKey(dep)] {
+			dependencies = append(dependencies, dep)
+		}
+	}
+	if err := r.Propagator.withdraw(ctx, r.kind, obj, dependencies); err != nil {
+		logError(log, req, kind, err, "Failed to withdraw from member clusters")
+		return err
+	}
+
+	if obj.GetDeletionTimestamp().IsZero() {
+		conditions := r.kind.conditions(obj)
+		if err := patchConditions(ctx, r.Status(), log, req, kind, obj, conditions, func() {
+			apimeta.RemoveStatusCondition(conditions, conditionClustersReady)
+		}); err != nil {
+			return err
+		}
+	}
+
+	patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+	controllerutil.RemoveFinalizer(obj, fleetFinalizer)
+	if err := r.Patch(ctx, obj, patch); err != nil {
+		logAPIError(log, req, kind, err, "Failed to remove fleet finalizer", obj)
+		return err
+	}
+	logInfo(log, req, kind, "Withdrawn from member clusters")
+	return nil
+}
+
+// retainedDependencies returns the keys of the dependencies that propagated
+// resources other than obj still need.
+func (r *FleetReconciler) retainedDependencies(ctx context.Context, obj client.Object) (map[string]bool, error) {
+	retained := map[string]bool{}
+	if len(r.kind.dependencyIndexes) == 0 {
+		return retained, nil
+	}
+
+	// Dependencies may be shared by resources in other namespaces through
+	// cross-namespace references.
+	others, err := r.listPropagated(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, other := range others {
+		if client.ObjectKeyFromObject(other) == client.ObjectKeyFromObject(obj) || !isPropagated(other) {
+			continue
+		}
+		for _, dep := range r.kind.dependencies(other) {
+			retained[dependencyKey(dep)] = true
+		}
+	}
+	return retained, nil
+}
+
+// dependencyKey identifies a dependency.
+func dependencyKey(obj client.Object) string {
+	return fmt.Sprintf("%T/%s/%s", obj, obj.GetNamespace(), obj.GetName())
+}
+
+// -----------------------------------------------------------------------------
+// Fleet Propagation - Member Copies
+// -----------------------------------------------------------------------------
+
+// memberCopy returns the copy of obj to create in a member cluster: its spec,
+// labels and annotations, marked as managed by the operator.
+func memberCopy(scheme *runtime.Scheme, obj client.Object) (*unstructured.Unstructured, error) {
+	gvk, err := apiutil.GVKForObject(obj, scheme)
+	if err != nil {
+		return nil, err
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &unstructured.Unstructured{Object: map[string]any{}}
+	if spec, ok := content["spec"]; ok {
+		u.Object["spec"] = spec
+	}
+	u.SetGroupVersionKind(gvk)
+	u.SetName(obj.GetName())
+	u.SetNamespace(obj.GetNamespace())
+
+	labels := maps.Clone(obj.GetLabels())
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	delete(labels, wafv1alpha1.LabelPropagate)
+	labels[ManagedByLabel] = ManagedByValue
+	u.SetLabels(labels)
+
+	annotations := maps.Clone(obj.GetAnnotations())
+	delete(annotations, corev1.LastAppliedConfigAnnotation)
+	delete(annotations, wafv1alpha1.AnnotationPlacement)
+	if len(annotations) > 0 {
+		u.SetAnnotations(annotations)
+	}
+	return u, nil
+}
+
+// -----------------------------------------------------------------------------
+// FleetReconciler - Watch Mappers
+// -----------------------------------------------------------------------------
+
+// listPropagated lists the resources of the reconciled kind that carry the
+// propagation label.
+func (r *FleetReconciler) listPropagated(ctx context.Context, opts ...client.ListOption) ([]client.Object, error) {
+	list := r.kind.newList()
+	opts = append(opts, client.MatchingLabels{wafv1alpha1.LabelPropagate: "true"})
+	if err := r.List(ctx, list, opts...); err != nil {
+		return nil, err
+	}
+	var objects []client.Object
+	err := apimeta.EachListItem(list, func(o runtime.Object) error {
+		objects = append(objects, o.(client.Object))
+		return nil
+	})
+	return objects, err
+}
+
+// findPropagated maps an event to every propagated resource, so that a new or
+// changed member cluster is brought up to date.
+func (r *FleetReconciler) findPropagated(ctx context.Context, _ client.Object) []reconcile.Request {
+	objects, err := r.listPropagated(ctx)
+	if err != nil {
+		logf.FromContext(ctx).Error(err, r.kind.name+": Failed to list propagated resources")
+		return nil
+	}
+	return fleetRequests(objects)
+}
+
+// findDependents returns a mapper from a dependency to the propagated
+// resources that reference it through the given field index.
+func (r *FleetReconciler) findDependents(index string) handler.MapFunc {
+	return func(ctx context.Context, dep client.Object) []reconcile.Request {
+		key := referenceIndexKey(dep.GetNamespace(), dep.GetName())
+		objects, err := r.listPropagated(ctx, client.MatchingFields{index: key})
+		if err != nil {
+			logf.FromContext(ctx).Error(err, r.kind.name+": Failed to list propagated resources", "index", index, "value", key)
+			return nil
+		}
+		return fleetRequests(objects)
+	}
+}
+
+// fleetRequests returns a reconcile request for each object.
+func fleetRequests(objects []client.Object) []reconcile.Request {
+	requests := make([]reconcile.Request, 0, len(objects))
+	for _, obj := range objects {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(obj)})
+	}
+	return requests
+}
@@ -0,0 +1,236 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+func newFleetTestScheme(t *testing.T) *runtime.Scheme {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+	return scheme
+}
+
+func memberClusterSecret(name, kubeconfig string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "operator",
+			Labels:    map[string]string{wafv1alpha1.LabelMemberCluster: "true"},
+		},
+		Data: map[string][]byte{memberClusterKubeconfigKey: []byte(kubeconfig)},
+	}
+}
+
+func TestFleetReconciler_RuleSet(t *testing.T) {
+	scheme := newFleetTestScheme(t)
+
+	source := &wafv1alpha1.RuleSource{
+		ObjectMeta: metav1.ObjectMeta{Name: "base", Namespace: "team-a"},
+		Spec:       wafv1alpha1.RuleSourceSpec{Rules: "SecRuleEngine On"},
+	}
+	ruleset := &wafv1alpha1.RuleSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "rules",
+			Namespace:  "team-a",
+			Generation: 1,
+			Labels:     map[string]string{wafv1alpha1.LabelPropagate: "true"},
+		},
+		Spec: wafv1alpha1.RuleSetSpec{Sources: []wafv1alpha1.SourceReference{{Name: "base"}}},
+	}
+	hub := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(memberClusterSecret("east", "east"), source, ruleset).
+		WithStatusSubresource(ruleset).
+		Build()
+	member := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithStatusSubresource(&wafv1alpha1.RuleSet{}).
+		Build()
+
+	r := &FleetReconciler{
+		Client: hub,
+		Scheme: scheme,
+		Propagator: &kubeconfigPropagator{
+			reader: hub,
+			scheme: scheme,
+			members: &MemberClusters{
+				entries: map[string]memberClusterEntry{},
+				newClient: func(kubeconfig []byte) (client.Client, error) {
+					if string(kubeconfig) != "east" {
+						return nil, errors.New("unknown cluster")
+					}
+					return member, nil
+				},
+			},
+			operatorNamespace: "operator",
+		},
+		kind: ruleSetFleetKind,
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "rules", Namespace: "team-a"}}
+	clustersReady := func() *metav1.Condition {
+		var rs wafv1alpha1.RuleSet
+		require.NoError(t, hub.Get(t.Context(), req.NamespacedName, &rs))
+		return apimeta.FindStatusCondition(rs.Status.Conditions, conditionClustersReady)
+	}
+
+	t.Run("propagates with dependencies", func(t *testing.T) {
+		result, err := r.Reconcile(t.Context(), req)
+		require.NoError(t, err)
+		assert.Equal(t, fleetResyncPeriod, result.RequeueAfter)
+
+		var copied wafv1alpha1.RuleSet
+		require.NoError(t, member.Get(t.Context(), req.NamespacedName, &copied))
+		assert.Equal(t, ruleset.Spec, copied.Spec)
+		assert.Equal(t, ManagedByValue, copied.Labels[ManagedByLabel])
+		assert.NotContains(t, copied.Labels, wafv1alpha1.LabelPropagate, "copies must not propagate further")
+
+		var copiedSource wafv1alpha1.RuleSource
+		require.NoError(t, member.Get(t.Context(), client.ObjectKeyFromObject(source), &copiedSource))
+		assert.Equal(t, source.Spec, copiedSource.Spec)
+
+		var rs wafv1alpha1.RuleSet
+		require.NoError(t, hub.Get(t.Context(), req.NamespacedName, &rs))
+		assert.True(t, controllerutil.ContainsFinalizer(&rs, fleetFinalizer))
+
+		cond := clustersReady()
+		require.NotNil(t, cond)
+		assert.Equal(t, metav1.ConditionFalse, cond.Status)
+		assert.Equal(t, "ClustersNotReady", cond.Reason)
+		assert.Contains(t, cond.Message, "east: not yet reconciled")
+	})
+
+	t.Run("aggregates member readiness", func(t *testing.T) {
+		var copied wafv1alpha1.RuleSet
+		require.NoError(t, member.Get(t.Context(), req.NamespacedName, &copied))
+		applyStatusReady(&copied.Status.Conditions, copied.Generation, "RulesCached", "cached")
+		require.NoError(t, member.Status().Update(t.Context(), &copied))
+
+		_, err := r.Reconcile(t.Context(), req)
+		require.NoError(t, err)
+
+		cond := clustersReady()
+		require.NotNil(t, cond)
+		assert.Equal(t, metav1.ConditionTrue, cond.Status)
+		assert.Equal(t, "Ready in 1 member clusters", cond.Message)
+	})
+
+	t.Run("reports unusable member clusters", func(t *testing.T) {
+		broken := memberClusterSecret("west", "garbage")
+		require.NoError(t, hub.Create(t.Context(), broken))
+
+		_, err := r.Reconcile(t.Context(), req)
+		require.NoError(t, err)
+
+		cond := clustersReady()
+		require.NotNil(t, cond)
+		assert.Equal(t, metav1.ConditionFalse, cond.Status)
+		assert.Contains(t, cond.Message, "1 of 2 member clusters not ready: west: invalid kubeconfig")
+
+		require.NoError(t, hub.Delete(t.Context(), broken))
+	})
+
+	t.Run("withdraws when unlabeled", func(t *testing.T) {
+		var rs wafv1alpha1.RuleSet
+		require.NoError(t, hub.Get(t.Context(), req.NamespacedName, &rs))
+		delete(rs.Labels, wafv1alpha1.LabelPropagate)
+		require.NoError(t, hub.Update(t.Context(), &rs))
+
+		_, err := r.Reconcile(t.Context(), req)
+		require.NoError(t, err)
+
+		err = member.Get(t.Context(), req.NamespacedName, &wafv1alpha1.RuleSet{})
+		assert.True(t, apierrors.IsNotFound(err), "the RuleSet copy must be removed")
+		err = member.Get(t.Context(), client.ObjectKeyFromObject(source), &wafv1alpha1.RuleSource{})
+		assert.True(t, apierrors.IsNotFound(err), "unreferenced dependencies must be removed")
+
+		require.NoError(t, hub.Get(t.Context(), req.NamespacedName, &rs))
+		assert.False(t, controllerutil.ContainsFinalizer(&rs, fleetFinalizer))
+		assert.Nil(t, clustersReady())
+	})
+}
+
+func TestFleetReconciler_LeavesUnmanagedObjects(t *testing.T) {
+	scheme := newFleetTestScheme(t)
+
+	engine := &wafv1alpha1.Engine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "waf",
+			Namespace: "team-a",
+			Labels:    map[string]string{wafv1alpha1.LabelPropagate: "true"},
+		},
+	}
+	hub := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(memberClusterSecret("east", "east"), engine).
+		WithStatusSubresource(engine).
+		Build()
+	local := &wafv1alpha1.Engine{ObjectMeta: metav1.ObjectMeta{Name: "waf", Namespace: "team-a"}}
+	member := fake.NewClientBuilder().WithScheme(scheme).WithObjects(local).Build()
+
+	r := &FleetReconciler{
+		Client: hub,
+		Scheme: scheme,
+		Propagator: &kubeconfigPropagator{
+			reader: hub,
+			scheme: scheme,
+			members: &MemberClusters{
+				entries:   map[string]memberClusterEntry{},
+				newClient: func([]byte) (client.Client, error) { return member, nil },
+			},
+			operatorNamespace: "operator",
+		},
+		kind: engineFleetKind,
+	}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(engine)}
+
+	_, err := r.Reconcile(t.Context(), req)
+	require.NoError(t, err)
+
+	var got wafv1alpha1.Engine
+	require.NoError(t, hub.Get(t.Context(), req.NamespacedName, &got))
+	cond := apimeta.FindStatusCondition(got.Status.Conditions, conditionClustersReady)
+	require.NotNil(t, cond)
+	assert.Contains(t, cond.Message, "exists and is not managed by the operator")
+
+	require.NoError(t, hub.Delete(t.Context(), &got))
+	_, err = r.Reconcile(t.Context(), req)
+	require.NoError(t, err)
+	assert.NoError(t, member.Get(t.Context(), req.NamespacedName, &wafv1alpha1.Engine{}), "unmanaged objects must never be deleted")
+	err = hub.Get(t.Context(), req.NamespacedName, &got)
+	assert.True(t, apierrors.IsNotFound(err), "the finalizer must be released")
+}
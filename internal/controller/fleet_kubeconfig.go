@@ -0,0 +1,265 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// Member Clusters
+// -----------------------------------------------------------------------------
+
+// memberClusterKubeconfigKey is the key of a member cluster Secret that holds
+// the kubeconfig.
+const memberClusterKubeconfigKey = "kubeconfig"
+
+// memberCluster is a member cluster as registered by its Secret. Exactly one
+// of client and err is set.
+type memberCluster struct {
+	name   string
+	client client.Client
+	err    error
+}
+
+// memberClusterEntry caches the client built from one revision of a member
+// cluster Secret.
+type memberClusterEntry struct {
+	resourceVersion string
+	client          client.Client
+	err             error
+}
+
+// MemberClusters builds and caches clients for the member clusters registered
+// by kubeconfig Secrets in the operator namespace. A client is rebuilt only
+// when its Secret changes.
+type MemberClusters struct {
+	mu        sync.Mutex
+	entries   map[string]memberClusterEntry
+	newClient func(kubeconfig []byte) (client.Client, error)
+}
+
+// NewMemberClusters returns a MemberClusters whose clients use scheme.
+func NewMemberClusters(scheme *runtime.Scheme) *MemberClusters {
+	return &MemberClusters{
+		entries: map[string]memberClusterEntry{},
+		newClient: func(kubeconfig []byte) (client.Client, error) {
+			cfg, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+			if err != nil {
+				return nil, err
+			}
+			return client.New(cfg, client.Options{Scheme: scheme})
+		},
+	}
+}
+
+// list returns the member clusters registered in namespace, sorted by name.
+// A Secret without a usable kubeconfig yields a member with err set, so that
+// the problem is reported on every propagated resource.
+func (m *MemberClusters) list(ctx context.Context, reader client.Reader, namespace string) ([]memberCluster, error) {
+	var secrets corev1.SecretList
+	if err := reader.List(ctx, &secrets,
+		client.InNamespace(namespace),
+		client.MatchingLabels{wafv1alpha1.LabelMemberCluster: "true"},
+	); err != nil {
+		return nil, fmt.Errorf("listing member cluster Secrets: %w", err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	seen := make(map[string]bool, len(secrets.Items))
+	members := make([]memberCluster, 0, len(secrets.Items))
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		seen[secret.Name] = true
+
+		entry, ok := m.entries[secret.Name]
+		if !ok || entry.resourceVersion != secret.ResourceVersion {
+			entry = memberClusterEntry{resourceVersion: secret.ResourceVersion}
+			if kubeconfig := secret.Data[memberClusterKubeconfigKey]; len(kubeconfig) == 0 {
+				entry.err = fmt.Errorf("secret has no %q key", memberClusterKubeconfigKey)
+			} else if c, err := m.newClient(kubeconfig); err != nil {
+				entry.err = fmt.Errorf("invalid kubeconfig: %w", err)
+			} else {
+				entry.client = c
+			}
+			m.entries[secret.Name] = entry
+		}
+		members = append(members, memberCluster{name: secret.Name, client: entry.client, err: entry.err})
+	}
+
+	for name := range m.entries {
+		if !seen[name] {
+			delete(m.entries, name)
+		}
+	}
+
+	slices.SortFunc(members, func(a, b memberCluster) int { return strings.Compare(a.name, b.name) })
+	return members, nil
+}
+
+// -----------------------------------------------------------------------------
+// Kubeconfig Propagator
+// -----------------------------------------------------------------------------
+
+// kubeconfigPropagator writes propagated resources directly to every member
+// cluster registered by a kubeconfig Secret and polls their status.
+type kubeconfigPropagator struct {
+	reader            client.Reader
+	scheme            *runtime.Scheme
+	members           *MemberClusters
+	operatorNamespace string
+}
+
+func (p *kubeconfigPropagator) propagate(ctx context.Context, kind fleetKind, owner client.Object, objects []client.Object) ([]clusterRollout, error) {
+	members, err := p.members.list(ctx, p.reader, p.operatorNamespace)
+	if err != nil {
+		return nil, err
+	}
+	rollouts := make([]clusterRollout, 0, len(members))
+	for _, m := range members {
+		rollouts = append(rollouts, clusterRollout{cluster: m.name, notReady: p.syncMember(ctx, kind, m, owner, objects)})
+	}
+	return rollouts, nil
+}
+
+// syncMember applies the copies of objects to one member cluster and returns
+// why the copy of owner is not ready there, or "" when it is.
+func (p *kubeconfigPropagator) syncMember(ctx context.Context, kind fleetKind, m memberCluster, owner client.Object, objects []client.Object) string {
+	if m.err != nil {
+		return m.err.Error()
+	}
+	for _, obj := range objects {
+		if err := p.applyMemberCopy(ctx, m.client, obj); err != nil {
+			return err.Error()
+		}
+	}
+
+	copied := kind.newObject()
+	if err := m.client.Get(ctx, client.ObjectKeyFromObject(owner), copied); err != nil {
+		return fmt.Sprintf("reading status: %v", err)
+	}
+	cond := apimeta.FindStatusCondition(*kind.conditions(copied), conditionReady)
+	switch {
+	case cond == nil || cond.ObservedGeneration != copied.GetGeneration():
+		return "not yet reconciled"
+	case cond.Status != metav1.ConditionTrue:
+		return fmt.Sprintf("%s: %s", cond.Reason, cond.Message)
+	}
+	return ""
+}
+
+// withdraw deletes the copies from every reachable member cluster. Copies in
+// member clusters whose Secret is invalid or was removed are left behind.
+func (p *kubeconfigPropagator) withdraw(ctx context.Context, _ fleetKind, owner client.Object, dependencies []client.Object) error {
+	members, err := p.members.list(ctx, p.reader, p.operatorNamespace)
+	if err != nil {
+		return err
+	}
+	targets := append([]client.Object{owner}, dependencies...)
+	for _, m := range members {
+		if m.err != nil {
+			continue
+		}
+		for _, target := range targets {
+			if err := p.deleteMemberCopy(ctx, m.client, target); err != nil {
+				return fmt.Errorf("member cluster %s: %w", m.name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (p *kubeconfigPropagator) watches(b *builder.Builder, r *FleetReconciler) *builder.Builder {
+	return b.Watches(
+		&corev1.Secret{},
+		handler.EnqueueRequestsFromMapFunc(r.findPropagated),
+		builder.WithPredicates(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+			return obj.GetNamespace() == p.operatorNamespace && obj.GetLabels()[wafv1alpha1.LabelMemberCluster] == "true"
+		})),
+	)
+}
+
+// applyMemberCopy creates or updates the copy of obj in a member cluster. An
+// existing object that the operator does not manage is left untouched.
+func (p *kubeconfigPropagator) applyMemberCopy(ctx context.Context, c client.Client, obj client.Object) error {
+	desired, err := memberCopy(p.scheme, obj)
+	if err != nil {
+		return err
+	}
+	gvk := desired.GroupVersionKind()
+
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(gvk)
+	if err := c.Get(ctx, client.ObjectKeyFromObject(desired), existing); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("getting %s %s/%s: %w", gvk.Kind, desired.GetNamespace(), desired.GetName(), err)
+		}
+		if err := c.Create(ctx, desired); err != nil {
+			return fmt.Errorf("creating %s %s/%s: %w", gvk.Kind, desired.GetNamespace(), desired.GetName(), err)
+		}
+		return nil
+	}
+
+	if existing.GetLabels()[ManagedByLabel] != ManagedByValue {
+		return fmt.Errorf("%s %s/%s exists and is not managed by the operator", gvk.Kind, desired.GetNamespace(), desired.GetName())
+	}
+	desired.SetResourceVersion(existing.GetResourceVersion())
+	desired.SetFinalizers(existing.GetFinalizers())
+	if err := c.Update(ctx, desired); err != nil {
+		return fmt.Errorf("updating %s %s/%s: %w", gvk.Kind, desired.GetNamespace(), desired.GetName(), err)
+	}
+	return nil
+}
+
+// deleteMemberCopy deletes the copy of obj from a member cluster if it
+// exists and is managed by the operator.
+func (p *kubeconfigPropagator) deleteMemberCopy(ctx context.Context, c client.Client, obj client.Object) error {
+	gvk, err := apiutil.GVKForObject(obj, p.scheme)
+	if err != nil {
+		return err
+	}
+	existing := &unstructured.Unstructured{}
+	existing.SetGroupVersionKind(gvk)
+	if err := c.Get(ctx, client.ObjectKeyFromObject(obj), existing); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	if existing.GetLabels()[ManagedByLabel] != ManagedByValue {
+		return nil
+	}
+	return client.IgnoreNotFound(c.Delete(ctx, existing))
+}
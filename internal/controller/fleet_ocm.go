@@ -0,0 +1,369 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// Open Cluster Management Propagator - RBAC
+// -----------------------------------------------------------------------------
+
+// +kubebuilder:rbac:groups=work.open-cluster-management.io,resources=manifestworks,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=cluster.open-cluster-management.io,resources=placementdecisions,verbs=get;list;watch
+
+// -----------------------------------------------------------------------------
+// Open Cluster Management Propagator - Vars
+// -----------------------------------------------------------------------------
+
+// Fleet backends accepted by SetupControllers.
+const (
+	// FleetBackendKubeconfig propagates to the member clusters registered by
+	// kubeconfig Secrets in the operator namespace.
+	FleetBackendKubeconfig = "kubeconfig"
+
+	// FleetBackendOCM propagates through Open Cluster Management
+	// ManifestWorks to the clusters selected by a Placement.
+	FleetBackendOCM = "ocm"
+)
+
+var (
+	// ManifestWorkGVK is the GroupVersionKind of the Open Cluster Management
+	// ManifestWorks generated for propagated resources.
+	ManifestWorkGVK = schema.GroupVersionKind{
+		Group:   "work.open-cluster-management.io",
+		Version: "v1",
+		Kind:    "ManifestWork",
+	}
+
+	placementDecisionGVK = schema.GroupVersionKind{
+		Group:   "cluster.open-cluster-management.io",
+		Version: "v1beta1",
+		Kind:    "PlacementDecision",
+	}
+)
+
+const (
+	// placementLabel links a PlacementDecision to its Placement.
+	placementLabel = "cluster.open-cluster-management.io/placement"
+
+	// fleetSourceLabel carries a hash of the kind, namespace and name of the
+	// resource a ManifestWork propagates, to find all of its ManifestWorks.
+	fleetSourceLabel = wafv1alpha1.Group + "/fleet-source"
+
+	// fleetSourceAnnotation carries the kind, namespace and name of the
+	// resource a ManifestWork propagates, as "Kind/namespace/name".
+	fleetSourceAnnotation = wafv1alpha1.Group + "/fleet-source"
+)
+
+// -----------------------------------------------------------------------------
+// Open Cluster Management Propagator
+// -----------------------------------------------------------------------------
+
+// ocmPropagator propagates a resource, together with its dependencies, as one
+// ManifestWork in the namespace of each cluster that the Placement named by
+// its wafv1alpha1.AnnotationPlacement annotation selects. The work agent
+// applies it, and reports the Ready condition of the copy back through
+// status feedback. Dependencies shared by several ManifestWorks are owned by
+// all of them and removed with the last one.
+type ocmPropagator struct {
+	client client.Client
+	scheme *runtime.Scheme
+}
+
+func (p *ocmPropagator) propagate(ctx context.Context, kind fleetKind, owner client.Object, objects []client.Object) ([]clusterRollout, error) {
+	placement := owner.GetAnnotations()[wafv1alpha1.AnnotationPlacement]
+	if placement == "" {
+		return nil, &fleetConfigError{
+			reason:  "PlacementNotSet",
+			message: fmt.Sprintf("The %s annotation must name the Placement that selects the member clusters", wafv1alpha1.AnnotationPlacement),
+		}
+	}
+
+	clusters, err := p.placementDecisions(ctx, owner.GetNamespace(), placement)
+	if err != nil {
+		return nil, err
+	}
+
+	manifests := make([]any, 0, len(objects))
+	for _, obj := range objects {
+		u, err := memberCopy(p.scheme, obj)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, u.Object)
+	}
+
+	source := fleetSourceKey(kind, owner)
+	existing, err := p.listManifestWorks(ctx, source)
+	if err != nil {
+		return nil, err
+	}
+	for i := range existing {
+		if !slices.Contains(clusters, existing[i].GetNamespace()) {
+			if err := client.IgnoreNotFound(p.client.Delete(ctx, &existing[i])); err != nil {
+				return nil, fmt.Errorf("deleting ManifestWork %s/%s: %w", existing[i].GetNamespace(), existing[i].GetName(), err)
+			}
+		}
+	}
+
+	rollouts := make([]clusterRollout, 0, len(clusters))
+	for _, cluster := range clusters {
+		work := buildManifestWork(kind, owner, cluster, source, manifests)
+		if err := serverSideApply(ctx, p.client, work); err != nil {
+			rollouts = append(rollouts, clusterRollout{cluster: cluster, notReady: err.Error()})
+			continue
+		}
+		rollouts = append(rollouts, clusterRollout{cluster: cluster, notReady: manifestWorkRollout(work, kind, owner)})
+	}
+	return rollouts, nil
+}
+
+// withdraw deletes every ManifestWork of owner. The work agents remove the
+// copies, and the dependencies no other ManifestWork owns.
+func (p *ocmPropagator) withdraw(ctx context.Context, kind fleetKind, owner client.Object, _ []client.Object) error {
+	works, err := p.listManifestWorks(ctx, fleetSourceKey(kind, owner))
+	if err != nil {
+		return err
+	}
+	for i := range works {
+		if err := client.IgnoreNotFound(p.client.Delete(ctx, &works[i])); err != nil {
+			return fmt.Errorf("deleting ManifestWork %s/%s: %w", works[i].GetNamespace(), works[i].GetName(), err)
+		}
+	}
+	return nil
+}
+
+func (p *ocmPropagator) watches(b *builder.Builder, r *FleetReconciler) *builder.Builder {
+	work := &unstructured.Unstructured{}
+	work.SetGroupVersionKind(ManifestWorkGVK)
+	decision := &unstructured.Unstructured{}
+	decision.SetGroupVersionKind(placementDecisionGVK)
+
+	return b.
+		Watches(work, handler.EnqueueRequestsFromMapFunc(func(_ context.Context, obj client.Object) []reconcile.Request {
+			return manifestWorkSource(obj, r.kind)
+		}), builder.WithPredicates(predicate.NewPredicateFuncs(func(obj client.Object) bool {
+			return obj.GetLabels()[ManagedByLabel] == ManagedByValue
+		}))).
+		Watches(decision, handler.EnqueueRequestsFromMapFunc(func(ctx context.Context, obj client.Object) []reconcile.Request {
+			return findPlacedResources(ctx, r, obj)
+		}))
+}
+
+// placementDecisions returns the sorted names of the clusters selected by
+// the named Placement in namespace.
+func (p *ocmPropagator) placementDecisions(ctx context.Context, namespace, placement string) ([]string, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(placementDecisionGVK.GroupVersion().WithKind(placementDecisionGVK.Kind + "List"))
+	if err := p.client.List(ctx, list, client.InNamespace(namespace), client.MatchingLabels{placementLabel: placement}); err != nil {
+		return nil, fmt.Errorf("listing PlacementDecisions of Placement %s/%s: %w", namespace, placement, err)
+	}
+
+	var clusters []string
+	for _, item := range list.Items {
+		decisions, _, _ := unstructured.NestedSlice(item.Object, "status", "decisions")
+		for _, d := range decisions {
+			decision, _ := d.(map[string]any)
+			if name, _, _ := unstructured.NestedString(decision, "clusterName"); name != "" && !slices.Contains(clusters, name) {
+				clusters = append(clusters, name)
+			}
+		}
+	}
+	slices.Sort(clusters)
+	return clusters, nil
+}
+
+// listManifestWorks returns the ManifestWorks of the resource identified by
+// source in all cluster namespaces.
+func (p *ocmPropagator) listManifestWorks(ctx context.Context, source string) ([]unstructured.Unstructured, error) {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(ManifestWorkGVK.GroupVersion().WithKind(ManifestWorkGVK.Kind + "List"))
+	if err := p.client.List(ctx, list, client.MatchingLabels{
+		ManagedByLabel:   ManagedByValue,
+		fleetSourceLabel: source,
+	}); err != nil {
+		return nil, fmt.Errorf("listing ManifestWorks: %w", err)
+	}
+	return list.Items, nil
+}
+
+// -----------------------------------------------------------------------------
+// Open Cluster Management Propagator - ManifestWorks
+// -----------------------------------------------------------------------------
+
+// fleetSourceKey returns a label-safe identifier of a propagated resource.
+func fleetSourceKey(kind fleetKind, owner client.Object) string {
+	sum := sha256.Sum256([]byte(kind.name + "/" + owner.GetNamespace() + "/" + owner.GetName()))
+	return hex.EncodeToString(sum[:10])
+}
+
+// buildManifestWork returns the ManifestWork that delivers manifests to
+// cluster. It asks the work agent to report the Ready condition of the copy
+// of owner, and the generation it was observed at.
+func buildManifestWork(kind fleetKind, owner client.Object, cluster, source string, manifests []any) *unstructured.Unstructured {
+	readyPath := func(field string) string {
+		return `.status.conditions[?(@.type=="Ready")].` + field
+	}
+
+	work := &unstructured.Unstructured{
+		Object: map[string]any{
+			"metadata": map[string]any{
+				"name":      "coraza-" + strings.ToLower(kind.name) + "-" + source,
+				"namespace": cluster,
+				"labels": map[string]any{
+					ManagedByLabel:                     ManagedByValue,
+					fleetSourceLabel:                   source,
+					wafv1alpha1.LabelExcludeFromBackup: "true",
+				},
+				"annotations": map[string]any{
+					fleetSourceAnnotation: kind.name + "/" + owner.GetNamespace() + "/" + owner.GetName(),
+				},
+			},
+			"spec": map[string]any{
+				"workload": map[string]any{
+					"manifests": manifests,
+				},
+				"manifestConfigs": []any{
+					map[string]any{
+						"resourceIdentifier": map[string]any{
+							"group":     wafv1alpha1.Group,
+							"resource":  kind.resource,
+							"namespace": owner.GetNamespace(),
+							"name":      owner.GetName(),
+						},
+						"feedbackRules": []any{
+							map[string]any{
+								"type": "JSONPaths",
+								"jsonPaths": []any{
+									map[string]any{"name": "ready", "path": readyPath("status")},
+									map[string]any{"name": "reason", "path": readyPath("reason")},
+									map[string]any{"name": "observedGeneration", "path": readyPath("observedGeneration")},
+									map[string]any{"name": "generation", "path": ".metadata.generation"},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	work.SetGroupVersionKind(ManifestWorkGVK)
+	return work
+}
+
+// manifestWorkRollout returns why the copy of owner delivered by work is not
+// ready, or "" when it is.
+func manifestWorkRollout(work *unstructured.Unstructured, kind fleetKind, owner client.Object) string {
+	if cond := unstructuredCondition(work, "Applied"); cond != nil && cond["status"] == "False" {
+		return fmt.Sprintf("not applied: %v", cond["message"])
+	}
+
+	manifests, _, _ := unstructured.NestedSlice(work.Object, "status", "resourceStatus", "manifests")
+	for _, m := range manifests {
+		manifest, _ := m.(map[string]any)
+		meta, _, _ := unstructured.NestedStringMap(manifest, "resourceMeta")
+		if meta["resource"] != kind.resource || meta["namespace"] != owner.GetNamespace() || meta["name"] != owner.GetName() {
+			continue
+		}
+
+		feedback := map[string]string{}
+		values, _, _ := unstructured.NestedSlice(manifest, "statusFeedback", "values")
+		for _, v := range values {
+			value, _ := v.(map[string]any)
+			name, _, _ := unstructured.NestedString(value, "name")
+			field, _, _ := unstructured.NestedMap(value, "fieldValue")
+			for _, key := range []string{"string", "integer", "boolean"} {
+				if fv, ok := field[key]; ok {
+					feedback[name] = fmt.Sprint(fv)
+				}
+			}
+		}
+
+		switch {
+		case feedback["ready"] == "" || feedback["observedGeneration"] != feedback["generation"]:
+			return "not yet reconciled"
+		case feedback["ready"] != "True":
+			return "not ready: " + feedback["reason"]
+		}
+		return ""
+	}
+	return "not yet applied"
+}
+
+// unstructuredCondition returns the status condition of type condType of
+// obj, or nil.
+func unstructuredCondition(obj *unstructured.Unstructured, condType string) map[string]any {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, c := range conditions {
+		if cond, ok := c.(map[string]any); ok && cond["type"] == condType {
+			return cond
+		}
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// Open Cluster Management Propagator - Watch Mappers
+// -----------------------------------------------------------------------------
+
+// manifestWorkSource maps a ManifestWork to the propagated resource it was
+// generated for, if that resource is of kind.
+func manifestWorkSource(work client.Object, kind fleetKind) []reconcile.Request {
+	parts := strings.SplitN(work.GetAnnotations()[fleetSourceAnnotation], "/", 3)
+	if len(parts) != 3 || parts[0] != kind.name {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: parts[1], Name: parts[2]}}}
+}
+
+// findPlacedResources maps a PlacementDecision to the propagated resources
+// that name its Placement, so that they follow changes in cluster selection.
+func findPlacedResources(ctx context.Context, r *FleetReconciler, decision client.Object) []reconcile.Request {
+	placement := decision.GetLabels()[placementLabel]
+	if placement == "" {
+		return nil
+	}
+	objects, err := r.listPropagated(ctx, client.InNamespace(decision.GetNamespace()))
+	if err != nil {
+		logf.FromContext(ctx).Error(err, r.kind.name+": Failed to list propagated resources", "placement", placement)
+		return nil
+	}
+	objects = slices.DeleteFunc(objects, func(obj client.Object) bool {
+		return obj.GetAnnotations()[wafv1alpha1.AnnotationPlacement] != placement
+	})
+	return fleetRequests(objects)
+}
@@ -0,0 +1,246 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+func placementDecision(name, placement string, clusters ...string) *unstructured.Unstructured {
+	decisions := make([]any, 0, len(clusters))
+	for _, c := range clusters {
+		decisions = append(decisions, map[string]any{"clusterName": c, "reason": ""})
+	}
+	u := &unstructured.Unstructured{Object: map[string]any{
+		"metadata": map[string]any{
+			"name":      name,
+			"namespace": "team-a",
+			"labels":    map[string]any{placementLabel: placement},
+		},
+		"status": map[string]any{"decisions": decisions},
+	}}
+	u.SetGroupVersionKind(placementDecisionGVK)
+	return u
+}
+
+func listManifestWorks(t *testing.T, c client.Client) []unstructured.Unstructured {
+	t.Helper()
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(ManifestWorkGVK.GroupVersion().WithKind("ManifestWorkList"))
+	require.NoError(t, c.List(t.Context(), list))
+	return list.Items
+}
+
+func TestFleetReconciler_OCM(t *testing.T) {
+	scheme := newFleetTestScheme(t)
+
+	source := &wafv1alpha1.RuleSource{
+		ObjectMeta: metav1.ObjectMeta{Name: "base", Namespace: "team-a"},
+		Spec:       wafv1alpha1.RuleSourceSpec{Rules: "SecRuleEngine On"},
+	}
+	ruleset := &wafv1alpha1.RuleSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "rules",
+			Namespace:   "team-a",
+			Labels:      map[string]string{wafv1alpha1.LabelPropagate: "true"},
+			Annotations: map[string]string{wafv1alpha1.AnnotationPlacement: "edge"},
+		},
+		Spec: wafv1alpha1.RuleSetSpec{Sources: []wafv1alpha1.SourceReference{{Name: "base"}}},
+	}
+	hub := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(source, ruleset, placementDecision("edge-1", "edge", "east", "west"), placementDecision("other-1", "other", "north")).
+		WithStatusSubresource(ruleset).
+		Build()
+
+	r := &FleetReconciler{
+		Client:     hub,
+		Scheme:     scheme,
+		Propagator: &ocmPropagator{client: hub, scheme: scheme},
+		kind:       ruleSetFleetKind,
+	}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(ruleset)}
+	clustersReady := func() *metav1.Condition {
+		var rs wafv1alpha1.RuleSet
+		require.NoError(t, hub.Get(t.Context(), req.NamespacedName, &rs))
+		return apimeta.FindStatusCondition(rs.Status.Conditions, conditionClustersReady)
+	}
+
+	t.Run("generates a ManifestWork per selected cluster", func(t *testing.T) {
+		_, err := r.Reconcile(t.Context(), req)
+		require.NoError(t, err)
+
+		works := listManifestWorks(t, hub)
+		require.Len(t, works, 2)
+		namespaces := []string{works[0].GetNamespace(), works[1].GetNamespace()}
+		assert.ElementsMatch(t, []string{"east", "west"}, namespaces)
+
+		manifests, _, err := unstructured.NestedSlice(works[0].Object, "spec", "workload", "manifests")
+		require.NoError(t, err)
+		require.Len(t, manifests, 2, "the RuleSet carries its RuleSource")
+		owner, _ := manifests[1].(map[string]any)
+		assert.Equal(t, "RuleSet", owner["kind"])
+		metadata, _ := owner["metadata"].(map[string]any)
+		assert.NotContains(t, metadata, "annotations", "the placement annotation is not copied")
+
+		cond := clustersReady()
+		require.NotNil(t, cond)
+		assert.Equal(t, metav1.ConditionFalse, cond.Status)
+		assert.Contains(t, cond.Message, "east: not yet applied")
+	})
+
+	t.Run("reports status feedback", func(t *testing.T) {
+		for _, work := range listManifestWorks(t, hub) {
+			require.NoError(t, unstructured.SetNestedSlice(work.Object, []any{
+				map[string]any{
+					"resourceMeta": map[string]any{
+						"group": wafv1alpha1.Group, "resource": "rulesets", "namespace": "team-a", "name": "rules",
+					},
+					"statusFeedback": map[string]any{"values": []any{
+						map[string]any{"name": "ready", "fieldValue": map[string]any{"type": "String", "string": "True"}},
+						map[string]any{"name": "generation", "fieldValue": map[string]any{"type": "Integer", "integer": int64(2)}},
+						map[string]any{"name": "observedGeneration", "fieldValue": map[string]any{"type": "Integer", "integer": int64(2)}},
+					}},
+				},
+			}, "status", "resourceStatus", "manifests"))
+			require.NoError(t, hub.Update(t.Context(), &work))
+		}
+
+		_, err := r.Reconcile(t.Context(), req)
+		require.NoError(t, err)
+
+		cond := clustersReady()
+		require.NotNil(t, cond)
+		assert.Equal(t, metav1.ConditionTrue, cond.Status)
+		assert.Equal(t, "Ready in 2 member clusters", cond.Message)
+	})
+
+	t.Run("follows the placement", func(t *testing.T) {
+		var rs wafv1alpha1.RuleSet
+		require.NoError(t, hub.Get(t.Context(), req.NamespacedName, &rs))
+		rs.Annotations[wafv1alpha1.AnnotationPlacement] = "other"
+		require.NoError(t, hub.Update(t.Context(), &rs))
+
+		_, err := r.Reconcile(t.Context(), req)
+		require.NoError(t, err)
+
+		works := listManifestWorks(t, hub)
+		require.Len(t, works, 1)
+		assert.Equal(t, "north", works[0].GetNamespace())
+	})
+
+	t.Run("requires a placement", func(t *testing.T) {
+		var rs wafv1alpha1.RuleSet
+		require.NoError(t, hub.Get(t.Context(), req.NamespacedName, &rs))
+		delete(rs.Annotations, wafv1alpha1.AnnotationPlacement)
+		require.NoError(t, hub.Update(t.Context(), &rs))
+
+		_, err := r.Reconcile(t.Context(), req)
+		require.NoError(t, err)
+
+		cond := clustersReady()
+		require.NotNil(t, cond)
+		assert.Equal(t, metav1.ConditionFalse, cond.Status)
+		assert.Equal(t, "PlacementNotSet", cond.Reason)
+	})
+
+	t.Run("withdraws when unlabeled", func(t *testing.T) {
+		var rs wafv1alpha1.RuleSet
+		require.NoError(t, hub.Get(t.Context(), req.NamespacedName, &rs))
+		delete(rs.Labels, wafv1alpha1.LabelPropagate)
+		require.NoError(t, hub.Update(t.Context(), &rs))
+
+		_, err := r.Reconcile(t.Context(), req)
+		require.NoError(t, err)
+
+		assert.Empty(t, listManifestWorks(t, hub))
+	})
+}
+
+func TestManifestWorkRollout(t *testing.T) {
+	owner := &wafv1alpha1.Engine{ObjectMeta: metav1.ObjectMeta{Name: "waf", Namespace: "team-a"}}
+	feedback := func(values ...map[string]any) *unstructured.Unstructured {
+		vals := make([]any, 0, len(values))
+		for _, v := range values {
+			vals = append(vals, v)
+		}
+		return &unstructured.Unstructured{Object: map[string]any{"status": map[string]any{
+			"resourceStatus": map[string]any{"manifests": []any{map[string]any{
+				"resourceMeta": map[string]any{
+					"group": wafv1alpha1.Group, "resource": "engines", "namespace": "team-a", "name": "waf",
+				},
+				"statusFeedback": map[string]any{"values": vals},
+			}}},
+		}}}
+	}
+	str := func(name, value string) map[string]any {
+		return map[string]any{"name": name, "fieldValue": map[string]any{"type": "String", "string": value}}
+	}
+	integer := func(name string, value int64) map[string]any {
+		return map[string]any{"name": name, "fieldValue": map[string]any{"type": "Integer", "integer": value}}
+	}
+
+	tests := []struct {
+		name string
+		work *unstructured.Unstructured
+		want string
+	}{
+		{
+			name: "no status",
+			work: &unstructured.Unstructured{Object: map[string]any{}},
+			want: "not yet applied",
+		},
+		{
+			name: "apply failed",
+			work: &unstructured.Unstructured{Object: map[string]any{"status": map[string]any{"conditions": []any{
+				map[string]any{"type": "Applied", "status": "False", "message": "forbidden"},
+			}}}},
+			want: "not applied: forbidden",
+		},
+		{
+			name: "stale generation",
+			work: feedback(str("ready", "True"), integer("generation", 3), integer("observedGeneration", 2)),
+			want: "not yet reconciled",
+		},
+		{
+			name: "degraded",
+			work: feedback(str("ready", "False"), str("reason", "InvalidConfiguration"), integer("generation", 1), integer("observedGeneration", 1)),
+			want: "not ready: InvalidConfiguration",
+		},
+		{
+			name: "ready",
+			work: feedback(str("ready", "True"), integer("generation", 1), integer("observedGeneration", 1)),
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, manifestWorkRollout(tt.work, engineFleetKind, owner))
+		})
+	}
+}
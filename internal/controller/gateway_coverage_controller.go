@@ -0,0 +1,195 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"slices"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// GatewayCoverageReconciler - Vars
+// -----------------------------------------------------------------------------
+
+// unprotectedGateways is 1 for the Gateways that must be protected by an
+// Engine and are not.
+var unprotectedGateways = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "coraza_gateway_unprotected",
+		Help: "Whether the Gateway must be protected by an Engine, according to the OperatorConfig spec.gatewayCoverage, and no Engine targets it (1).",
+	},
+	[]string{"namespace", "gateway"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(unprotectedGateways)
+}
+
+// -----------------------------------------------------------------------------
+// GatewayCoverageReconciler
+// -----------------------------------------------------------------------------
+
+// GatewayCoverageReconciler reports the Gateways selected by the
+// OperatorConfig spec.gatewayCoverage that no Engine targets. It only
+// reports them: the operator serves no admission webhook to reject them.
+type GatewayCoverageReconciler struct {
+	client.Client
+	Recorder events.EventRecorder
+	Runtime  *RuntimeConfig
+
+	// unprotected holds the Gateways reported as unprotected, so that the
+	// Warning event is only recorded when a Gateway becomes unprotected.
+	mu          sync.Mutex
+	unprotected map[types.NamespacedName]bool
+}
+
+// SetupWithManager sets up the controller with the Manager. It relies on the
+// Engine spec.target index set up by the Engine controller.
+func (r *GatewayCoverageReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	gateway := &unstructured.Unstructured{}
+	gateway.SetGroupVersionKind(schema.GroupVersionKind{Group: gatewayGroup, Version: "v1", Kind: "Gateway"})
+
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(gateway).
+		Watches(&wafv1alpha1.Engine{}, handler.EnqueueRequestsFromMapFunc(findGatewayForEngine)).
+		Named("gateway-coverage")
+	if r.Runtime != nil {
+		b = b.WatchesRawSource(source.Channel(r.Runtime.coverageEvents,
+			handler.EnqueueRequestsFromMapFunc(r.findAllGateways)))
+	}
+	return b.Complete(r)
+}
+
+// -----------------------------------------------------------------------------
+// GatewayCoverageReconciler - Reconcile
+// -----------------------------------------------------------------------------
+
+// Reconcile checks whether an Engine targets the Gateway when it must be
+// protected.
+func (r *GatewayCoverageReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	gateway := &unstructured.Unstructured{}
+	gateway.SetGroupVersionKind(schema.GroupVersionKind{Group: gatewayGroup, Version: "v1", Kind: "Gateway"})
+	if err := r.Get(ctx, req.NamespacedName, gateway); err != nil {
+		if apierrors.IsNotFound(err) {
+			r.setProtected(req.NamespacedName)
+			return ctrl.Result{}, nil
+		}
+		logAPIError(log, req, "Gateway", err, "Failed to GET", nil)
+		return ctrl.Result{}, err
+	}
+
+	if !mustBeProtected(r.Runtime.GatewayCoverage(req.Namespace), gateway) {
+		r.setProtected(req.NamespacedName)
+		return ctrl.Result{}, nil
+	}
+
+	var engines wafv1alpha1.EngineList
+	if err := r.List(ctx, &engines,
+		client.InNamespace(req.Namespace),
+		client.MatchingFields{engineTargetIndex: engineTargetKey(wafv1alpha1.EngineTargetTypeGateway, req.Name)},
+	); err != nil {
+		logAPIError(log, req, "Gateway", err, "Failed to list Engines", nil)
+		return ctrl.Result{}, err
+	}
+	if len(engines.Items) > 0 {
+		r.setProtected(req.NamespacedName)
+		return ctrl.Result{}, nil
+	}
+
+	unprotectedGateways.WithLabelValues(req.Namespace, req.Name).Set(1)
+	r.mu.Lock()
+	reported := r.unprotected[req.NamespacedName]
+	if r.unprotected == nil {
+		r.unprotected = map[types.NamespacedName]bool{}
+	}
+	r.unprotected[req.NamespacedName] = true
+	r.mu.Unlock()
+	if !reported {
+		logInfo(log, req, "Gateway", "Gateway must be protected, but no Engine targets it")
+		r.Recorder.Eventf(gateway, nil, "Warning", "GatewayNotProtected", "Reconcile",
+			"No Engine targets this Gateway, which the OperatorConfig spec.gatewayCoverage requires to be protected")
+	}
+	return ctrl.Result{}, nil
+}
+
+// setProtected clears the report of the Gateway.
+func (r *GatewayCoverageReconciler) setProtected(key types.NamespacedName) {
+	unprotectedGateways.DeleteLabelValues(key.Namespace, key.Name)
+	r.mu.Lock()
+	delete(r.unprotected, key)
+	r.mu.Unlock()
+}
+
+// mustBeProtected reports whether coverage, the gateway coverage of the
+// namespace of gateway, selects it.
+func mustBeProtected(coverage *wafv1alpha1.GatewayCoverage, gateway *unstructured.Unstructured) bool {
+	if coverage == nil {
+		return false
+	}
+	if len(coverage.GatewayClassNames) == 0 {
+		return true
+	}
+	className, _, _ := unstructured.NestedString(gateway.Object, "spec", "gatewayClassName")
+	return slices.Contains(coverage.GatewayClassNames, className)
+}
+
+// findGatewayForEngine maps an Engine to the Gateway it targets by name.
+// Engines with a target selector create an Engine per Gateway, which are
+// mapped instead.
+func findGatewayForEngine(_ context.Context, obj client.Object) []reconcile.Request {
+	engine, ok := obj.(*wafv1alpha1.Engine)
+	if !ok || engine.Spec.Target.Type != wafv1alpha1.EngineTargetTypeGateway || engine.Spec.Target.Name == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: engine.Namespace, Name: engine.Spec.Target.Name}}}
+}
+
+// findAllGateways maps a gateway coverage change to every Gateway.
+func (r *GatewayCoverageReconciler) findAllGateways(ctx context.Context, _ client.Object) []reconcile.Request {
+	log := logf.FromContext(ctx)
+
+	gateways := &unstructured.UnstructuredList{}
+	gateways.SetGroupVersionKind(schema.GroupVersionKind{Group: gatewayGroup, Version: "v1", Kind: "GatewayList"})
+	if err := r.List(ctx, gateways); err != nil {
+		log.Error(err, "Gateway: Failed to list Gateways")
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(gateways.Items))
+	for _, gw := range gateways.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&gw)})
+	}
+	return requests
+}
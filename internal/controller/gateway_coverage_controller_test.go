@@ -0,0 +1,151 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/test/utils"
+)
+
+func TestGatewayCoverageReconciler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+
+	newGateway := func(name, className string) *unstructured.Unstructured {
+		gw := &unstructured.Unstructured{}
+		gw.SetGroupVersionKind(schema.GroupVersionKind{Group: gatewayGroup, Version: "v1", Kind: "Gateway"})
+		gw.SetNamespace("team-a")
+		gw.SetName(name)
+		gw.Object["spec"] = map[string]any{"gatewayClassName": className}
+		return gw
+	}
+	index := func(obj client.Object) []string {
+		engine := obj.(*wafv1alpha1.Engine)
+		return []string{engineTargetKey(engine.Spec.Target.Type, engine.Spec.Target.Name)}
+	}
+
+	tests := []struct {
+		name            string
+		coverage        *wafv1alpha1.GatewayCoverage
+		className       string
+		engine          bool
+		wantUnprotected bool
+	}{
+		{
+			name:      "no coverage",
+			className: "public",
+		},
+		{
+			name:            "unprotected",
+			coverage:        &wafv1alpha1.GatewayCoverage{},
+			className:       "public",
+			wantUnprotected: true,
+		},
+		{
+			name:      "protected",
+			coverage:  &wafv1alpha1.GatewayCoverage{},
+			className: "public",
+			engine:    true,
+		},
+		{
+			name:      "other namespace",
+			coverage:  &wafv1alpha1.GatewayCoverage{Namespaces: []string{"team-b"}},
+			className: "public",
+		},
+		{
+			name:            "selected class",
+			coverage:        &wafv1alpha1.GatewayCoverage{GatewayClassNames: []string{"public"}},
+			className:       "public",
+			wantUnprotected: true,
+		},
+		{
+			name:      "other class",
+			coverage:  &wafv1alpha1.GatewayCoverage{GatewayClassNames: []string{"public"}},
+			className: "internal",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gateway := newGateway("gw", tt.className)
+			objs := []client.Object{gateway}
+			if tt.engine {
+				objs = append(objs, utils.NewTestEngine(utils.EngineOptions{Name: "waf", Namespace: "team-a", GatewayName: "gw"}))
+			}
+			c := fake.NewClientBuilder().WithScheme(scheme).
+				WithObjects(objs...).
+				WithIndex(&wafv1alpha1.Engine{}, engineTargetIndex, index).
+				Build()
+			runtimeConfig := NewRuntimeConfig()
+			runtimeConfig.apply(&wafv1alpha1.OperatorConfig{Spec: wafv1alpha1.OperatorConfigSpec{GatewayCoverage: tt.coverage}})
+			recorder := utils.NewFakeRecorder()
+			r := &GatewayCoverageReconciler{Client: c, Recorder: recorder, Runtime: runtimeConfig}
+			req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gateway)}
+			t.Cleanup(func() { unprotectedGateways.Reset() })
+
+			for range 2 {
+				_, err := r.Reconcile(t.Context(), req)
+				require.NoError(t, err)
+			}
+
+			if tt.wantUnprotected {
+				assert.Equal(t, float64(1), testutil.ToFloat64(unprotectedGateways.WithLabelValues("team-a", "gw")))
+				require.Len(t, recorder.Events, 1, "the event is only recorded when the Gateway becomes unprotected")
+				assert.Equal(t, "GatewayNotProtected", recorder.Events[0].Reason)
+			} else {
+				assert.Zero(t, testutil.CollectAndCount(unprotectedGateways))
+				assert.Empty(t, recorder.Events)
+			}
+		})
+	}
+
+	t.Run("protected once an Engine targets it", func(t *testing.T) {
+		gateway := newGateway("gw", "public")
+		c := fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(gateway).
+			WithIndex(&wafv1alpha1.Engine{}, engineTargetIndex, index).
+			Build()
+		runtimeConfig := NewRuntimeConfig()
+		runtimeConfig.apply(&wafv1alpha1.OperatorConfig{Spec: wafv1alpha1.OperatorConfigSpec{GatewayCoverage: &wafv1alpha1.GatewayCoverage{}}})
+		r := &GatewayCoverageReconciler{Client: c, Recorder: utils.NewFakeRecorder(), Runtime: runtimeConfig}
+		req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(gateway)}
+		t.Cleanup(func() { unprotectedGateways.Reset() })
+
+		_, err := r.Reconcile(t.Context(), req)
+		require.NoError(t, err)
+		assert.Equal(t, 1, testutil.CollectAndCount(unprotectedGateways))
+
+		engine := utils.NewTestEngine(utils.EngineOptions{Name: "waf", Namespace: "team-a", GatewayName: "gw"})
+		require.NoError(t, c.Create(t.Context(), engine))
+		assert.Equal(t, []ctrl.Request{req}, findGatewayForEngine(t.Context(), engine))
+		_, err = r.Reconcile(t.Context(), req)
+		require.NoError(t, err)
+		assert.Zero(t, testutil.CollectAndCount(unprotectedGateways))
+	})
+}
@@ -19,10 +19,15 @@ package controller
 
 import (
 	"fmt"
+	"net/http"
+	"slices"
+	"time"
 
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/kubernetes"
 	ctrl "sigs.k8s.io/controller-runtime"
 
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
 	"github.com/networking-incubator/coraza-kubernetes-operator/internal/rulesets/cache"
 )
 
@@ -47,32 +52,274 @@ import (
 // cache server.
 const DefaultRuleSetCacheServerPort = 18080
 
+// ManagedByLabel and ManagedByValue mark resources created by the operator.
+// Generated WasmPlugins carry this label so the manager's cache can be scoped
+// to them instead of every WasmPlugin in the cluster.
+const (
+	ManagedByLabel = "app.kubernetes.io/managed-by"
+	ManagedByValue = "coraza-kubernetes-operator"
+)
+
+// WasmPluginGVK is the GroupVersionKind of the Istio WasmPlugin resources
+// generated for Engines.
+var WasmPluginGVK = schema.GroupVersionKind{
+	Group:   "extensions.istio.io",
+	Version: "v1alpha1",
+	Kind:    "WasmPlugin",
+}
+
+// TelemetryGVK is the GroupVersionKind of the Istio Telemetry resources
+// generated for the access log of Engines.
+var TelemetryGVK = schema.GroupVersionKind{
+	Group:   "telemetry.istio.io",
+	Version: "v1",
+	Kind:    "Telemetry",
+}
+
+// DefaultRuleSourceDebounceWindow is the default window during which
+// RuleSource and RuleData changes are coalesced before the referencing
+// RuleSets are recomposed.
+const DefaultRuleSourceDebounceWindow = 500 * time.Millisecond
+
+// Controllers that can be enabled individually with SetupControllers.
+const (
+	ControllerOperatorConfig = "operatorconfig"
+	ControllerRuleSet        = "ruleset"
+	ControllerEngine         = "engine"
+	ControllerThreatFeed     = "threatfeed"
+	ControllerFalsePositive  = "falsepositive"
+	ControllerEmergencyBlock = "emergencyblock"
+)
+
+// Controllers lists every controller that can be enabled, in setup order.
+var Controllers = []string{ControllerOperatorConfig, ControllerRuleSet, ControllerEngine, ControllerThreatFeed, ControllerFalsePositive, ControllerEmergencyBlock}
+
 // -----------------------------------------------------------------------------
 // Manager - Setup
 // -----------------------------------------------------------------------------
 
-// SetupControllers initializes all controllers
-func SetupControllers(mgr ctrl.Manager, rulesetCache *cache.RuleSetCache, envoyClusterName, istioRevision string, defaultWasmImage, operatorNamespace string, kubeClient kubernetes.Interface) error {
-	if err := (&RuleSetReconciler{
-		Client:   mgr.GetClient(),
-		Scheme:   mgr.GetScheme(),
-		Recorder: mgr.GetEventRecorder("ruleset-controller"),
-		Cache:    rulesetCache,
-	}).SetupWithManager(mgr); err != nil {
-		return fmt.Errorf("unable to create controller RuleSet: %w", err)
+// SetupOptions configures the controllers initialized by SetupControllers.
+// The zero value runs every controller with the default settings.
+type SetupOptions struct {
+	// RuleSetCache is the cache the RuleSet controller publishes compiled
+	// rules to.
+	RuleSetCache *cache.RuleSetCache
+
+	// EnvoyClusterName is the Envoy cluster through which the WASM plugin
+	// reaches the ruleset cache server.
+	EnvoyClusterName string
+
+	// IstioRevision is the istio.io/rev label set on generated Istio
+	// resources; empty sets none.
+	IstioRevision string
+
+	// DefaultWasmImage is the WASM plugin image of Engines that do not set
+	// one.
+	DefaultWasmImage string
+
+	// OperatorNamespace is the namespace of the OperatorConfig and of the
+	// kubeconfigs of member clusters.
+	OperatorNamespace string
+
+	// KubeClient is the clientset the Engine controller requests
+	// ServiceAccount tokens with.
+	KubeClient kubernetes.Interface
+
+	// RuleSourceDebounce is the window during which RuleSource and RuleData
+	// changes are coalesced; zero disables coalescing.
+	RuleSourceDebounce time.Duration
+
+	// FleetBackend selects how the fleet controllers propagate labeled
+	// RuleSets and Engines to member clusters: FleetBackendKubeconfig or
+	// FleetBackendOCM. Empty disables the fleet controllers.
+	FleetBackend string
+
+	// EnabledControllers names the controllers to run; nil runs all of
+	// Controllers.
+	EnabledControllers []string
+
+	// ImageMirrors rewrite the registries of WASM plugin images.
+	ImageMirrors []wafv1alpha1.ImageMirror
+
+	// VerifyMirroredImages degrades Engines whose mirrored image is missing
+	// from its registry.
+	VerifyMirroredImages bool
+
+	// VerifyImagePlatforms degrades Engines whose image lacks a variant for
+	// the architecture of their gateway nodes.
+	VerifyImagePlatforms bool
+
+	// Capabilities are the optional APIs detected at startup; features that
+	// depend on a missing one are disabled. Nil assumes all are installed.
+	Capabilities Capabilities
+
+	// AdmissionWebhooks reports whether the admission webhooks of the
+	// operator are served, which record the authors and approvers of rule
+	// changes.
+	AdmissionWebhooks bool
+}
+
+// hasCapability reports whether the optional API name is installed, assuming
+// it is when capabilities were not detected.
+func (o SetupOptions) hasCapability(name Capability) bool {
+	return o.Capabilities == nil || o.Capabilities.Has(name)
+}
+
+// SetupControllers initializes the controllers enabled in opts. Its settings
+// are defaults that the OperatorConfig in the operator namespace may override
+// at runtime; without the OperatorConfig controller they apply unchanged.
+func SetupControllers(mgr ctrl.Manager, opts SetupOptions) error {
+	enabledControllers := opts.EnabledControllers
+	if enabledControllers == nil {
+		enabledControllers = Controllers
+	}
+	runtimeConfig := NewRuntimeConfig()
+
+	// The RuleSet and OperatorConfig controllers run on every replica, but
+	// only the leader writes their status and events.
+	statusClient := leaderOnlyStatusClient{Client: mgr.GetClient(), elected: mgr.Elected()}
+
+	if slices.Contains(enabledControllers, ControllerOperatorConfig) {
+		if err := (&OperatorConfigReconciler{
+			Client:            statusClient,
+			Scheme:            mgr.GetScheme(),
+			Recorder:          leaderOnlyRecorder{EventRecorder: mgr.GetEventRecorder("operatorconfig-controller"), elected: mgr.Elected()},
+			Runtime:           runtimeConfig,
+			OperatorNamespace: opts.OperatorNamespace,
+		}).SetupWithManager(mgr); err != nil {
+			return fmt.Errorf("unable to create controller OperatorConfig: %w", err)
+		}
+	}
+
+	if slices.Contains(enabledControllers, ControllerRuleSet) {
+		if err := (&RuleSetReconciler{
+			Client:            statusClient,
+			Scheme:            mgr.GetScheme(),
+			Recorder:          leaderOnlyRecorder{EventRecorder: mgr.GetEventRecorder("ruleset-controller"), elected: mgr.Elected()},
+			Cache:             opts.RuleSetCache,
+			SourceDebounce:    opts.RuleSourceDebounce,
+			Runtime:           runtimeConfig,
+			AdmissionWebhooks: opts.AdmissionWebhooks,
+			capabilities:      opts.Capabilities,
+			elected:           mgr.Elected(),
+			hooks:             registeredRuleSetHooks(),
+		}).SetupWithManager(mgr); err != nil {
+			return fmt.Errorf("unable to create controller RuleSet: %w", err)
+		}
+	}
+
+	if slices.Contains(enabledControllers, ControllerEngine) {
+		var resolver imageResolver
+		var platforms platformResolver
+		registry := newRegistryResolver(&http.Client{Timeout: imageResolveTimeout})
+		if opts.VerifyMirroredImages {
+			resolver = registry
+		}
+		if opts.VerifyImagePlatforms {
+			platforms = registry
+		}
+		if err := (&EngineReconciler{
+			Client:                    mgr.GetClient(),
+			Scheme:                    mgr.GetScheme(),
+			Recorder:                  mgr.GetEventRecorder("engine-controller"),
+			kubeClient:                opts.KubeClient,
+			ruleSetCacheServerCluster: opts.EnvoyClusterName,
+			istioRevision:             opts.IstioRevision,
+			defaultWasmImage:          opts.DefaultWasmImage,
+			operatorNamespace:         opts.OperatorNamespace,
+			runtimeConfig:             runtimeConfig,
+			imageMirrors:              opts.ImageMirrors,
+			imageResolver:             resolver,
+			platformResolver:          platforms,
+			apiReader:                 mgr.GetAPIReader(),
+			capabilities:              opts.Capabilities,
+		}).SetupWithManager(mgr); err != nil {
+			return fmt.Errorf("unable to create controller Engine: %w", err)
+		}
+		if err := (&EngineProbeReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorder("engine-probe-controller"),
+			httpClient: &http.Client{
+				Timeout: probeTimeout,
+				// A redirect is an answer of its own: the probe was let through.
+				CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+			},
+		}).SetupWithManager(mgr); err != nil {
+			return fmt.Errorf("unable to create controller EngineProbe: %w", err)
+		}
+		if opts.hasCapability(CapabilityGatewayV1) {
+			if err := (&GatewayCoverageReconciler{
+				Client:   mgr.GetClient(),
+				Recorder: mgr.GetEventRecorder("gateway-coverage-controller"),
+				Runtime:  runtimeConfig,
+			}).SetupWithManager(mgr); err != nil {
+				return fmt.Errorf("unable to create controller GatewayCoverage: %w", err)
+			}
+		}
+	}
+
+	if slices.Contains(enabledControllers, ControllerThreatFeed) {
+		if err := (&ThreatFeedReconciler{
+			Client:     mgr.GetClient(),
+			Scheme:     mgr.GetScheme(),
+			Recorder:   mgr.GetEventRecorder("threatfeed-controller"),
+			apiReader:  mgr.GetAPIReader(),
+			httpClient: newThreatFeedHTTPClient(),
+		}).SetupWithManager(mgr); err != nil {
+			return fmt.Errorf("unable to create controller ThreatFeed: %w", err)
+		}
 	}
 
-	if err := (&EngineReconciler{
-		Client:                    mgr.GetClient(),
-		Scheme:                    mgr.GetScheme(),
-		Recorder:                  mgr.GetEventRecorder("engine-controller"),
-		kubeClient:                kubeClient,
-		ruleSetCacheServerCluster: envoyClusterName,
-		istioRevision:             istioRevision,
-		defaultWasmImage:          defaultWasmImage,
-		operatorNamespace:         operatorNamespace,
-	}).SetupWithManager(mgr); err != nil {
-		return fmt.Errorf("unable to create controller Engine: %w", err)
+	if slices.Contains(enabledControllers, ControllerFalsePositive) {
+		if err := (&FalsePositiveReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorder("falsepositive-controller"),
+		}).SetupWithManager(mgr); err != nil {
+			return fmt.Errorf("unable to create controller FalsePositive: %w", err)
+		}
+	}
+
+	if slices.Contains(enabledControllers, ControllerEmergencyBlock) {
+		if err := (&EmergencyBlockReconciler{
+			Client:   mgr.GetClient(),
+			Scheme:   mgr.GetScheme(),
+			Recorder: mgr.GetEventRecorder("emergencyblock-controller"),
+		}).SetupWithManager(mgr); err != nil {
+			return fmt.Errorf("unable to create controller EmergencyBlock: %w", err)
+		}
+	}
+
+	if opts.FleetBackend != "" {
+		var propagator fleetPropagator
+		switch opts.FleetBackend {
+		case FleetBackendKubeconfig:
+			propagator = &kubeconfigPropagator{
+				reader:            mgr.GetClient(),
+				scheme:            mgr.GetScheme(),
+				members:           NewMemberClusters(mgr.GetScheme()),
+				operatorNamespace: opts.OperatorNamespace,
+			}
+		case FleetBackendOCM:
+			if !opts.hasCapability(CapabilityOCM) {
+				return fmt.Errorf("fleet backend %q requires the Open Cluster Management ManifestWork and PlacementDecision APIs", opts.FleetBackend)
+			}
+			propagator = &ocmPropagator{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+		default:
+			return fmt.Errorf("unknown fleet backend %q", opts.FleetBackend)
+		}
+
+		for _, kind := range []fleetKind{ruleSetFleetKind, engineFleetKind} {
+			if err := (&FleetReconciler{
+				Client:     mgr.GetClient(),
+				Scheme:     mgr.GetScheme(),
+				Propagator: propagator,
+				kind:       kind,
+			}).SetupWithManager(mgr); err != nil {
+				return fmt.Errorf("unable to create controller Fleet%s: %w", kind.name, err)
+			}
+		}
 	}
 
 	return nil
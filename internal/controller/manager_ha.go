@@ -0,0 +1,155 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/events"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/internal/rulesets/cache"
+)
+
+// -----------------------------------------------------------------------------
+// Manager - High Availability
+// -----------------------------------------------------------------------------
+
+// Every replica runs the RuleSet and OperatorConfig controllers so that each
+// one fills its own RuleSetCache and can serve gateway polls. Only the leader
+// persists status and emits events; followers compute the same result and
+// discard the writes. The Engine controller, which manages resources in the
+// cluster, stays leader-only.
+
+// isElected reports whether elected, as returned by ctrl.Manager.Elected, is
+// closed. The manager closes it immediately when leader election is disabled.
+func isElected(elected <-chan struct{}) bool {
+	select {
+	case <-elected:
+		return true
+	default:
+		return false
+	}
+}
+
+// leaderOnlyStatusClient is a client whose status writes are dropped unless
+// this replica is the leader.
+type leaderOnlyStatusClient struct {
+	client.Client
+	elected <-chan struct{}
+}
+
+func (c leaderOnlyStatusClient) Status() client.SubResourceWriter {
+	return leaderOnlyStatusWriter{SubResourceWriter: c.Client.Status(), elected: c.elected}
+}
+
+type leaderOnlyStatusWriter struct {
+	client.SubResourceWriter
+	elected <-chan struct{}
+}
+
+func (w leaderOnlyStatusWriter) Create(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+	if !isElected(w.elected) {
+		return nil
+	}
+	return w.SubResourceWriter.Create(ctx, obj, subResource, opts...)
+}
+
+func (w leaderOnlyStatusWriter) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	if !isElected(w.elected) {
+		return nil
+	}
+	return w.SubResourceWriter.Update(ctx, obj, opts...)
+}
+
+func (w leaderOnlyStatusWriter) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+	if !isElected(w.elected) {
+		return nil
+	}
+	return w.SubResourceWriter.Patch(ctx, obj, patch, opts...)
+}
+
+func (w leaderOnlyStatusWriter) Apply(ctx context.Context, obj runtime.ApplyConfiguration, opts ...client.SubResourceApplyOption) error {
+	if !isElected(w.elected) {
+		return nil
+	}
+	return w.SubResourceWriter.Apply(ctx, obj, opts...)
+}
+
+// leaderOnlyRecorder drops events unless this replica is the leader, so that
+// followers don't duplicate every event the leader emits.
+type leaderOnlyRecorder struct {
+	events.EventRecorder
+	elected <-chan struct{}
+}
+
+func (r leaderOnlyRecorder) Eventf(regarding runtime.Object, related runtime.Object, eventtype, reason, action, note string, args ...any) {
+	if !isElected(r.elected) {
+		return
+	}
+	r.EventRecorder.Eventf(regarding, related, eventtype, reason, action, note, args...)
+}
+
+// -----------------------------------------------------------------------------
+// Manager - Readiness
+// -----------------------------------------------------------------------------
+
+// NewRuleSetCacheReadyCheck returns a readiness check that fails until the
+// local RuleSetCache holds an entry for every RuleSet whose Ready condition
+// is true for its current generation. This keeps a starting replica out of
+// the cache Service endpoints until it can serve everything the leader has
+// reported as ready. Once passed, the check latches so that a RuleSet being
+// recomposed does not take replicas out of rotation.
+func NewRuleSetCacheReadyCheck(reader client.Reader, rulesetCache *cache.RuleSetCache) healthz.Checker {
+	var ready atomic.Bool
+	return func(req *http.Request) error {
+		if ready.Load() {
+			return nil
+		}
+
+		var rulesets wafv1alpha1.RuleSetList
+		if err := reader.List(req.Context(), &rulesets); err != nil {
+			return fmt.Errorf("listing RuleSets: %w", err)
+		}
+
+		missing := 0
+		for i := range rulesets.Items {
+			rs := &rulesets.Items[i]
+			cond := apimeta.FindStatusCondition(rs.Status.Conditions, conditionReady)
+			if cond == nil || cond.Status != metav1.ConditionTrue || cond.ObservedGeneration != rs.Generation {
+				continue
+			}
+			if !rulesetCache.Has(rs.Namespace + "/" + rs.Name) {
+				missing++
+			}
+		}
+		if missing > 0 {
+			return fmt.Errorf("%d ready RuleSets not yet cached", missing)
+		}
+
+		ready.Store(true)
+		return nil
+	}
+}
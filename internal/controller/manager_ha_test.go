@@ -0,0 +1,91 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/internal/rulesets/cache"
+)
+
+func TestLeaderOnlyStatusClient(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+
+	ruleset := &wafv1alpha1.RuleSet{ObjectMeta: metav1.ObjectMeta{Name: "rs", Namespace: "ns", Generation: 1}}
+	base := fake.NewClientBuilder().WithScheme(scheme).WithObjects(ruleset).WithStatusSubresource(ruleset).Build()
+
+	elected := make(chan struct{})
+	c := leaderOnlyStatusClient{Client: base, elected: elected}
+
+	writeReady := func() {
+		var rs wafv1alpha1.RuleSet
+		require.NoError(t, c.Get(t.Context(), client.ObjectKeyFromObject(ruleset), &rs))
+		patch := client.MergeFrom(rs.DeepCopy())
+		applyStatusReady(&rs.Status.Conditions, rs.Generation, "RulesCached", "cached")
+		require.NoError(t, c.Status().Patch(t.Context(), &rs, patch))
+	}
+	isReady := func() bool {
+		var rs wafv1alpha1.RuleSet
+		require.NoError(t, base.Get(t.Context(), client.ObjectKeyFromObject(ruleset), &rs))
+		return apimeta.IsStatusConditionTrue(rs.Status.Conditions, conditionReady)
+	}
+
+	writeReady()
+	assert.False(t, isReady(), "followers must not write status")
+
+	close(elected)
+	writeReady()
+	assert.True(t, isReady(), "the leader must write status")
+}
+
+func TestRuleSetCacheReadyCheck(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+
+	ready := &wafv1alpha1.RuleSet{ObjectMeta: metav1.ObjectMeta{Name: "ready", Namespace: "ns", Generation: 2}}
+	applyStatusReady(&ready.Status.Conditions, 2, "RulesCached", "cached")
+	stale := &wafv1alpha1.RuleSet{ObjectMeta: metav1.ObjectMeta{Name: "stale", Namespace: "ns", Generation: 3}}
+	applyStatusReady(&stale.Status.Conditions, 2, "RulesCached", "cached")
+	degraded := &wafv1alpha1.RuleSet{ObjectMeta: metav1.ObjectMeta{Name: "degraded", Namespace: "ns", Generation: 1}}
+	applyStatusConditionDegraded(&degraded.Status.Conditions, 1, "InvalidRuleSet", "bad")
+
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(ready, stale, degraded).Build()
+	rulesetCache := cache.NewRuleSetCache()
+	check := NewRuleSetCacheReadyCheck(c, rulesetCache)
+	req := httptest.NewRequest("GET", "/readyz", nil)
+
+	err := check(req)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "1 ready RuleSets not yet cached")
+
+	rulesetCache.Put("ns/ready", "rules", nil)
+	require.NoError(t, check(req), "only RuleSets ready at their current generation are awaited")
+
+	rulesetCache.Delete("ns/ready")
+	assert.NoError(t, check(req), "readiness latches once passed")
+}
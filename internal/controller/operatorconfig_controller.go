@@ -0,0 +1,101 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// OperatorConfigReconciler - RBAC
+// -----------------------------------------------------------------------------
+
+// +kubebuilder:rbac:groups=waf.k8s.coraza.io,resources=operatorconfigs,verbs=get;list;watch
+// +kubebuilder:rbac:groups=waf.k8s.coraza.io,resources=operatorconfigs/status,verbs=get;update;patch
+
+// -----------------------------------------------------------------------------
+// OperatorConfigReconciler
+// -----------------------------------------------------------------------------
+
+// OperatorConfigReconciler applies the OperatorConfig named "default" in the
+// operator namespace to the shared RuntimeConfig.
+type OperatorConfigReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder events.EventRecorder
+
+	// Runtime receives the overrides from the OperatorConfig.
+	Runtime *RuntimeConfig
+	// OperatorNamespace is the only namespace an OperatorConfig is read from.
+	OperatorNamespace string
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *OperatorConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&wafv1alpha1.OperatorConfig{}, builder.WithPredicates(
+			predicate.GenerationChangedPredicate{},
+			predicate.NewPredicateFuncs(func(obj client.Object) bool {
+				return obj.GetNamespace() == r.OperatorNamespace && obj.GetName() == wafv1alpha1.OperatorConfigName
+			}),
+		)).
+		// Every replica applies the runtime configuration; see manager_ha.go.
+		WithOptions(controller.Options{NeedLeaderElection: ptr.To(false)}).
+		Named("operatorconfig").
+		Complete(r)
+}
+
+// -----------------------------------------------------------------------------
+// OperatorConfigReconciler - Reconcile
+// -----------------------------------------------------------------------------
+
+// Reconcile handles reconciliation of the OperatorConfig resource.
+func (r *OperatorConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	var config wafv1alpha1.OperatorConfig
+	if err := r.Get(ctx, req.NamespacedName, &config); err != nil {
+		if apierrors.IsNotFound(err) {
+			logInfo(log, req, "OperatorConfig", "Resource not found, reverting to command-line configuration")
+			r.Runtime.apply(nil)
+			return ctrl.Result{}, nil
+		}
+		logAPIError(log, req, "OperatorConfig", err, "Failed to GET", nil)
+		return ctrl.Result{}, err
+	}
+
+	if r.Runtime.apply(&config) {
+		logInfo(log, req, "OperatorConfig", "Default WASM image changed, reconciling Engines", "defaultWasmImage", config.Spec.DefaultWasmImage)
+	}
+
+	msg := fmt.Sprintf("OperatorConfig %s/%s applied", config.Namespace, config.Name)
+	return ctrl.Result{}, patchReady(ctx, r.Status(), r.Recorder, log, req, "OperatorConfig", &config, &config.Status.Conditions, config.Generation, "Applied", msg)
+}
@@ -0,0 +1,161 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/internal/defaults"
+	"github.com/networking-incubator/coraza-kubernetes-operator/test/utils"
+)
+
+func TestRuntimeConfig(t *testing.T) {
+	t.Run("nil config overrides nothing", func(t *testing.T) {
+		var c *RuntimeConfig
+		assert.False(t, c.apply(&wafv1alpha1.OperatorConfig{}))
+		assert.Empty(t, c.DefaultWasmImage())
+		_, ok := c.RuleSourceDebounce()
+		assert.False(t, ok)
+	})
+
+	t.Run("apply and clear overrides", func(t *testing.T) {
+		c := NewRuntimeConfig()
+		config := &wafv1alpha1.OperatorConfig{Spec: wafv1alpha1.OperatorConfigSpec{
+			DefaultWasmImage:         "oci://example.com/coraza:v2",
+			RuleSourceDebounceWindow: &metav1.Duration{Duration: 2 * time.Second},
+		}}
+
+		assert.True(t, c.apply(config))
+		assert.Equal(t, "oci://example.com/coraza:v2", c.DefaultWasmImage())
+		window, ok := c.RuleSourceDebounce()
+		assert.True(t, ok)
+		assert.Equal(t, 2*time.Second, window)
+		assert.Len(t, c.engineEvents, 1, "Engines must be notified of the new image")
+
+		assert.False(t, c.apply(config), "unchanged image must not be reported")
+		assert.Len(t, c.engineEvents, 1, "pending notifications must not pile up")
+		<-c.engineEvents
+
+		assert.True(t, c.apply(nil))
+		assert.Empty(t, c.DefaultWasmImage())
+		_, ok = c.RuleSourceDebounce()
+		assert.False(t, ok)
+		assert.Len(t, c.engineEvents, 1)
+	})
+
+	t.Run("image mirror changes signal every Engine", func(t *testing.T) {
+		c := NewRuntimeConfig()
+		config := &wafv1alpha1.OperatorConfig{Spec: wafv1alpha1.OperatorConfigSpec{
+			ImageMirrors: []wafv1alpha1.ImageMirror{{Source: "ghcr.io", Mirror: "registry.internal"}},
+		}}
+
+		assert.False(t, c.apply(config), "the default image did not change")
+		assert.Equal(t, config.Spec.ImageMirrors, c.ImageMirrors())
+		assert.Len(t, c.mirrorEvents, 1)
+		assert.Empty(t, c.engineEvents)
+		<-c.mirrorEvents
+
+		c.apply(config)
+		assert.Empty(t, c.mirrorEvents, "unchanged mirrors must not be signalled")
+
+		c.apply(nil)
+		assert.Nil(t, c.ImageMirrors())
+		assert.Len(t, c.mirrorEvents, 1)
+	})
+
+	t.Run("namespace quota changes signal every Engine", func(t *testing.T) {
+		c := NewRuntimeConfig()
+		config := &wafv1alpha1.OperatorConfig{Spec: wafv1alpha1.OperatorConfigSpec{
+			NamespaceQuota: &wafv1alpha1.NamespaceQuota{MaxEngines: ptr.To[int32](2)},
+		}}
+
+		c.apply(config)
+		assert.Equal(t, config.Spec.NamespaceQuota, c.NamespaceQuota())
+		assert.Len(t, c.quotaEvents, 1)
+		<-c.quotaEvents
+
+		c.apply(config.DeepCopy())
+		assert.Empty(t, c.quotaEvents, "an unchanged quota must not be signalled")
+
+		c.apply(nil)
+		assert.Nil(t, c.NamespaceQuota())
+		assert.Len(t, c.quotaEvents, 1)
+	})
+}
+
+func TestOperatorConfigReconciler(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+
+	config := &wafv1alpha1.OperatorConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: wafv1alpha1.OperatorConfigName, Namespace: "operator", Generation: 1},
+		Spec: wafv1alpha1.OperatorConfigSpec{
+			DefaultWasmImage:         "oci://example.com/coraza:v2",
+			RuleSourceDebounceWindow: &metav1.Duration{Duration: 0},
+		},
+	}
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(config).
+		WithStatusSubresource(config).
+		Build()
+
+	runtimeConfig := NewRuntimeConfig()
+	r := &OperatorConfigReconciler{
+		Client:            c,
+		Scheme:            scheme,
+		Recorder:          utils.NewTestRecorder(),
+		Runtime:           runtimeConfig,
+		OperatorNamespace: "operator",
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: config.Name, Namespace: config.Namespace}}
+
+	_, err := r.Reconcile(t.Context(), req)
+	require.NoError(t, err)
+
+	engines := &EngineReconciler{defaultWasmImage: defaults.DefaultCorazaWasmOCIReference, runtimeConfig: runtimeConfig}
+	url, fromSpec := engines.wasmPluginOCIURLSource(&wafv1alpha1.Engine{})
+	assert.Equal(t, "oci://example.com/coraza:v2", url)
+	assert.False(t, fromSpec)
+
+	rulesets := &RuleSetReconciler{SourceDebounce: DefaultRuleSourceDebounceWindow, Runtime: runtimeConfig}
+	assert.Zero(t, rulesets.sourceDebounce(), "an explicit zero window must override the flag")
+
+	var got wafv1alpha1.OperatorConfig
+	require.NoError(t, c.Get(t.Context(), req.NamespacedName, &got))
+	assert.True(t, apimeta.IsStatusConditionTrue(got.Status.Conditions, conditionReady))
+
+	require.NoError(t, c.Delete(t.Context(), &got))
+	_, err = r.Reconcile(t.Context(), req)
+	require.NoError(t, err)
+
+	url, _ = engines.wasmPluginOCIURLSource(&wafv1alpha1.Engine{})
+	assert.Equal(t, defaults.DefaultCorazaWasmOCIReference, url, "deleting the OperatorConfig must restore the flag value")
+	assert.Equal(t, DefaultRuleSourceDebounceWindow, rulesets.sourceDebounce())
+}
@@ -0,0 +1,298 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// Orphan Sweeper - RBAC
+// -----------------------------------------------------------------------------
+
+// +kubebuilder:rbac:groups=waf.k8s.coraza.io,resources=ruledata,verbs=delete
+
+// -----------------------------------------------------------------------------
+// Orphan Sweeper - Vars
+// -----------------------------------------------------------------------------
+
+// DefaultOrphanSweepInterval is how often the OrphanSweeper runs by default.
+const DefaultOrphanSweepInterval = time.Hour
+
+var (
+	orphansFound = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "coraza_operator_orphaned_resources",
+			Help: "Number of operator-generated resources whose owner no longer exists, found by the last orphan sweep.",
+		},
+		[]string{"kind"},
+	)
+
+	orphansDeletedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "coraza_operator_orphaned_resources_deleted_total",
+			Help: "Total number of operator-generated resources deleted by the orphan sweeper because their owner no longer exists.",
+		},
+		[]string{"kind"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(orphansFound, orphansDeletedTotal)
+}
+
+// orphanOwner identifies the resource an operator-generated resource was
+// generated for.
+type orphanOwner struct {
+	kind      string
+	namespace string
+	name      string
+
+	// uid is the UID of the owner, when the generated resource records it.
+	uid types.UID
+}
+
+// orphanKind is a kind of operator-generated resource the OrphanSweeper
+// checks.
+type orphanKind struct {
+	gvk schema.GroupVersionKind
+
+	// capability is the optional API serving the kind, or "" when it is
+	// always installed.
+	capability Capability
+
+	// operatorNamespace restricts the sweep to the operator namespace.
+	operatorNamespace bool
+
+	// owner returns the owner of obj, or false when it records none, in
+	// which case obj is orphaned.
+	owner func(obj *unstructured.Unstructured) (orphanOwner, bool)
+}
+
+// orphanKinds are the kinds of resources the operator generates for
+// Engines, RuleSets and ThreatFeeds. Kubernetes garbage collection removes
+// most of them through their owner references, but not those whose owner
+// references were removed, such as after a delete with --cascade=orphan,
+// nor the cross-namespace NetworkPolicies of Engines deleted without their
+// finalizer running.
+var orphanKinds = []orphanKind{
+	{gvk: WasmPluginGVK, capability: CapabilityWasmPlugin, owner: controllerOwner},
+	{gvk: TelemetryGVK, capability: CapabilityIstioTelemetry, owner: controllerOwner},
+	{gvk: wafv1alpha1.GroupVersion.WithKind("RuleSetSnapshot"), owner: controllerOwner},
+	{gvk: wafv1alpha1.GroupVersion.WithKind("RuleData"), owner: controllerOwner},
+	{gvk: schema.GroupVersionKind{Group: "networking.k8s.io", Version: "v1", Kind: "NetworkPolicy"}, operatorNamespace: true, owner: networkPolicyOwner},
+}
+
+// orphanOwnerKinds are the kinds of owners whose existence the
+// OrphanSweeper checks. Resources owned by other kinds are left alone.
+var orphanOwnerKinds = map[string]func() client.Object{
+	"Engine":     func() client.Object { return &wafv1alpha1.Engine{} },
+	"RuleSet":    func() client.Object { return &wafv1alpha1.RuleSet{} },
+	"ThreatFeed": func() client.Object { return &wafv1alpha1.ThreatFeed{} },
+}
+
+// controllerOwner returns the WAF resource controlling obj.
+func controllerOwner(obj *unstructured.Unstructured) (orphanOwner, bool) {
+	ref := metav1.GetControllerOf(obj)
+	if ref == nil {
+		return orphanOwner{}, false
+	}
+	owner := orphanOwner{kind: ref.Kind, namespace: obj.GetNamespace(), name: ref.Name, uid: ref.UID}
+	if gv, err := schema.ParseGroupVersion(ref.APIVersion); err != nil || gv.Group != wafv1alpha1.GroupVersion.Group {
+		// Not generated for a WAF resource: report an owner kind that is
+		// never checked.
+		owner.kind = ref.APIVersion + "/" + ref.Kind
+	}
+	return owner, true
+}
+
+// networkPolicyOwner returns the Engine a cache server NetworkPolicy was
+// generated for, from its labels.
+func networkPolicyOwner(obj *unstructured.Unstructured) (orphanOwner, bool) {
+	labels := obj.GetLabels()
+	name, namespace := labels[networkPolicyEngineLabelName], labels[networkPolicyEngineLabelNamespace]
+	if name == "" || namespace == "" {
+		return orphanOwner{}, false
+	}
+	return orphanOwner{kind: "Engine", namespace: namespace, name: name}, true
+}
+
+// -----------------------------------------------------------------------------
+// Orphan Sweeper
+// -----------------------------------------------------------------------------
+
+// OrphanSweeper periodically deletes the resources the operator generated
+// for Engines, RuleSets and ThreatFeeds that no longer exist. In dry-run
+// mode, it only reports them in its logs and the
+// coraza_operator_orphaned_resources metric. It runs on the leader.
+//
+// RuleSet cache entries are not swept: the cache lives in the memory of the
+// leader, is rebuilt from the existing RuleSets when a replica becomes the
+// leader, and the RuleSet controller removes the entry of every RuleSet it
+// observes being deleted, including deletions replayed when its watch is
+// re-established.
+type OrphanSweeper struct {
+	client            client.Client
+	reader            client.Reader
+	capabilities      Capabilities
+	operatorNamespace string
+	namespaces        []string
+	interval          time.Duration
+	dryRun            bool
+}
+
+// NewOrphanSweeper returns a new OrphanSweeper runnable sweeping every
+// interval, in namespaces, or every namespace when empty. The reader should
+// be a direct API reader (not cached), so that an owner deleted a moment
+// ago is not mistaken for an existing one, nor a new one for a deleted one.
+func NewOrphanSweeper(c client.Client, reader client.Reader, capabilities Capabilities, operatorNamespace string, namespaces []string, interval time.Duration, dryRun bool) *OrphanSweeper {
+	return &OrphanSweeper{
+		client:            c,
+		reader:            reader,
+		capabilities:      capabilities,
+		operatorNamespace: operatorNamespace,
+		namespaces:        namespaces,
+		interval:          interval,
+		dryRun:            dryRun,
+	}
+}
+
+// NeedLeaderElection makes only the leader sweep.
+func (s *OrphanSweeper) NeedLeaderElection() bool {
+	return true
+}
+
+// Start sweeps every interval until ctx is done. It satisfies the
+// manager.Runnable interface. Failures are logged and retried on the next
+// sweep.
+func (s *OrphanSweeper) Start(ctx context.Context) error {
+	log := ctrl.Log.WithName("orphan-sweeper").WithValues("dryRun", s.dryRun)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		if err := s.sweep(ctx, log); err != nil {
+			log.Error(err, "Orphan sweep failed")
+		}
+	}
+}
+
+// sweep runs one sweep over every kind.
+func (s *OrphanSweeper) sweep(ctx context.Context, log logr.Logger) error {
+	var errs []error
+	for _, kind := range orphanKinds {
+		if kind.capability != "" && !s.capabilities.Has(kind.capability) {
+			continue
+		}
+		if err := s.sweepKind(ctx, log, kind); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// sweepKind deletes the orphaned operator-generated resources of kind.
+func (s *OrphanSweeper) sweepKind(ctx context.Context, log logr.Logger, kind orphanKind) error {
+	namespaces := s.namespaces
+	if kind.operatorNamespace {
+		namespaces = []string{s.operatorNamespace}
+	} else if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+
+	found := 0
+	for _, namespace := range namespaces {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(kind.gvk.GroupVersion().WithKind(kind.gvk.Kind + "List"))
+		if err := s.reader.List(ctx, list, client.InNamespace(namespace), client.MatchingLabels{ManagedByLabel: ManagedByValue}); err != nil {
+			return fmt.Errorf("listing %s: %w", kind.gvk.Kind, err)
+		}
+
+		for i := range list.Items {
+			obj := &list.Items[i]
+			if !obj.GetDeletionTimestamp().IsZero() {
+				continue
+			}
+			owner, orphaned, err := s.orphaned(ctx, kind, obj)
+			if err != nil {
+				return err
+			}
+			if !orphaned {
+				continue
+			}
+			found++
+
+			keys := []any{"kind", kind.gvk.Kind, "namespace", obj.GetNamespace(), "name", obj.GetName(), "owner", owner.kind + " " + owner.namespace + "/" + owner.name}
+			if s.dryRun {
+				log.Info("Found orphaned resource", keys...)
+				continue
+			}
+			uid := obj.GetUID()
+			if err := s.client.Delete(ctx, obj, client.Preconditions{UID: &uid}); client.IgnoreNotFound(err) != nil {
+				return fmt.Errorf("deleting %s %s/%s: %w", kind.gvk.Kind, obj.GetNamespace(), obj.GetName(), err)
+			}
+			orphansDeletedTotal.WithLabelValues(kind.gvk.Kind).Inc()
+			log.Info("Deleted orphaned resource", keys...)
+		}
+	}
+	orphansFound.WithLabelValues(kind.gvk.Kind).Set(float64(found))
+	return nil
+}
+
+// orphaned reports whether obj, of kind, is orphaned: it records no owner,
+// or its owner no longer exists or was recreated. It returns the owner.
+func (s *OrphanSweeper) orphaned(ctx context.Context, kind orphanKind, obj *unstructured.Unstructured) (orphanOwner, bool, error) {
+	owner, ok := kind.owner(obj)
+	if !ok {
+		return orphanOwner{kind: "none"}, true, nil
+	}
+	newOwner, known := orphanOwnerKinds[owner.kind]
+	if !known {
+		return owner, false, nil
+	}
+
+	existing := newOwner()
+	if err := s.reader.Get(ctx, types.NamespacedName{Namespace: owner.namespace, Name: owner.name}, existing); err != nil {
+		if apierrors.IsNotFound(err) {
+			return owner, true, nil
+		}
+		return owner, false, fmt.Errorf("getting %s %s/%s: %w", owner.kind, owner.namespace, owner.name, err)
+	}
+	return owner, owner.uid != "" && existing.GetUID() != owner.uid, nil
+}
@@ -0,0 +1,104 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	networkingv1 "k8s.io/api/networking/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+func TestOrphanSweeper(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+	require.NoError(t, networkingv1.AddToScheme(scheme))
+
+	managed := map[string]string{ManagedByLabel: ManagedByValue}
+	owned := func(kind, name string, uid types.UID) []metav1.OwnerReference {
+		return []metav1.OwnerReference{{APIVersion: wafv1alpha1.GroupVersion.String(), Kind: kind, Name: name, UID: uid, Controller: new(true)}}
+	}
+	snapshot := func(name string, owners []metav1.OwnerReference, labels map[string]string) *wafv1alpha1.RuleSetSnapshot {
+		return &wafv1alpha1.RuleSetSnapshot{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "team-a", Labels: labels, OwnerReferences: owners}}
+	}
+	networkPolicy := func(name, engine string) *networkingv1.NetworkPolicy {
+		return &networkingv1.NetworkPolicy{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "coraza-system", Labels: map[string]string{
+			ManagedByLabel:                    ManagedByValue,
+			networkPolicyEngineLabelName:      engine,
+			networkPolicyEngineLabelNamespace: "team-a",
+		}}}
+	}
+
+	objects := []client.Object{
+		&wafv1alpha1.RuleSet{ObjectMeta: metav1.ObjectMeta{Name: "rules", Namespace: "team-a", UID: "rules-uid"}},
+		&wafv1alpha1.Engine{ObjectMeta: metav1.ObjectMeta{Name: "waf", Namespace: "team-a", UID: "waf-uid"}},
+		snapshot("owned", owned("RuleSet", "rules", "rules-uid"), managed),
+		snapshot("owner-deleted", owned("RuleSet", "deleted", "deleted-uid"), managed),
+		snapshot("owner-recreated", owned("RuleSet", "rules", "old-uid"), managed),
+		snapshot("no-owner", nil, managed),
+		snapshot("unmanaged", owned("RuleSet", "deleted", "deleted-uid"), nil),
+		snapshot("foreign-owner", []metav1.OwnerReference{{APIVersion: "v1", Kind: "ConfigMap", Name: "deleted", UID: "cm-uid", Controller: new(true)}}, managed),
+		networkPolicy("waf-cache", "waf"),
+		networkPolicy("deleted-cache", "deleted"),
+	}
+	remaining := func(c client.Client) []string {
+		var names []string
+		var snapshots wafv1alpha1.RuleSetSnapshotList
+		require.NoError(t, c.List(t.Context(), &snapshots))
+		for _, s := range snapshots.Items {
+			names = append(names, s.Name)
+		}
+		var policies networkingv1.NetworkPolicyList
+		require.NoError(t, c.List(t.Context(), &policies))
+		for _, p := range policies.Items {
+			names = append(names, p.Name)
+		}
+		return names
+	}
+
+	t.Log("Reporting orphans without deleting them in dry-run mode")
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+	sweeper := NewOrphanSweeper(c, c, nil, "coraza-system", []string{"team-a"}, DefaultOrphanSweepInterval, true)
+	require.NoError(t, sweeper.sweep(t.Context(), logr.Discard()))
+	assert.Len(t, remaining(c), len(objects)-2)
+	assert.InDelta(t, 3, testutil.ToFloat64(orphansFound.WithLabelValues("RuleSetSnapshot")), 0)
+	assert.InDelta(t, 1, testutil.ToFloat64(orphansFound.WithLabelValues("NetworkPolicy")), 0)
+
+	t.Log("Deleting orphans")
+	deletedBefore := testutil.ToFloat64(orphansDeletedTotal.WithLabelValues("RuleSetSnapshot"))
+	sweeper.dryRun = false
+	require.NoError(t, sweeper.sweep(t.Context(), logr.Discard()))
+	assert.ElementsMatch(t, []string{"owned", "unmanaged", "foreign-owner", "waf-cache"}, remaining(c))
+	assert.InDelta(t, 3, testutil.ToFloat64(orphansDeletedTotal.WithLabelValues("RuleSetSnapshot"))-deletedBefore, 0)
+	err := c.Get(t.Context(), types.NamespacedName{Namespace: "coraza-system", Name: "deleted-cache"}, &networkingv1.NetworkPolicy{})
+	assert.True(t, apierrors.IsNotFound(err))
+
+	t.Log("Finding no orphans on the next sweep")
+	require.NoError(t, sweeper.sweep(t.Context(), logr.Discard()))
+	assert.Zero(t, testutil.ToFloat64(orphansFound.WithLabelValues("RuleSetSnapshot")))
+}
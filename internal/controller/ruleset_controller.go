@@ -25,9 +25,11 @@ import (
 	"github.com/go-logr/logr"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/events"
 	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/ptr"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -35,8 +37,10 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/internal/references"
 	"github.com/networking-incubator/coraza-kubernetes-operator/internal/rulesets/cache"
 )
 
@@ -48,6 +52,10 @@ import (
 // +kubebuilder:rbac:groups=waf.k8s.coraza.io,resources=rulesets/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=waf.k8s.coraza.io,resources=rulesources,verbs=get;list;watch
 // +kubebuilder:rbac:groups=waf.k8s.coraza.io,resources=ruledata,verbs=get;list;watch
+// +kubebuilder:rbac:groups=waf.k8s.coraza.io,resources=rulesetsnapshots,verbs=get;list;watch;create;delete
+// +kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=referencegrants,verbs=get;list;watch
+// +kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=httproutes,verbs=get;list;watch
+// +kubebuilder:rbac:groups=gateway.networking.k8s.io,resources=grpcroutes,verbs=get;list;watch
 
 // -----------------------------------------------------------------------------
 // RuleSetReconciler
@@ -59,58 +67,175 @@ type RuleSetReconciler struct {
 	Scheme   *runtime.Scheme
 	Recorder events.EventRecorder
 	Cache    *cache.RuleSetCache
+
+	// SourceDebounce delays reconciles triggered by RuleSource and RuleData
+	// changes so that bursts of edits collapse into a single composition.
+	// Zero disables debouncing.
+	SourceDebounce time.Duration
+
+	// Runtime carries OperatorConfig overrides, such as the debounce window,
+	// that take precedence over the fields above.
+	Runtime *RuntimeConfig
+
+	// AdmissionWebhooks reports whether the admission webhooks of the
+	// operator record the authors of rule changes and the approvers of
+	// RuleSetApprovals. Without them, no revision is approved.
+	AdmissionWebhooks bool
+
+	// capabilities are the optional APIs detected at startup. ReferenceGrants
+	// are only consulted and watched when their API is installed.
+	capabilities Capabilities
+
+	// elected is closed once this replica is the leader, which writes the
+	// RuleSetSnapshots. A nil channel means this replica always is.
+	elected <-chan struct{}
+
+	// hooks review the composed rules before they are validated; see
+	// RegisterRuleSetHook.
+	hooks []namedRuleSetHook
+
+	// missingSources remembers the sources found missing, so that they are
+	// fetched again with backoff.
+	missingSources *missingSourceCache
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *RuleSetReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &wafv1alpha1.RuleSet{}, "spec.sources.name", func(obj client.Object) []string {
+	r.missingSources = newMissingSourceCache()
+
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &wafv1alpha1.RuleSet{}, ruleSetSourcesIndex, func(obj client.Object) []string {
 		rs := obj.(*wafv1alpha1.RuleSet)
-		names := make([]string, len(rs.Spec.Sources))
-		for i, src := range rs.Spec.Sources {
-			names[i] = src.Name
+		keys := make([]string, 0, len(rs.Spec.Sources))
+		for _, src := range rs.Spec.Sources {
+			if isRuleSourceReference(src) {
+				keys = append(keys, referenceIndexKey(referenceNamespace(rs, src.Namespace), src.Name))
+			}
 		}
-		return names
+		return keys
 	}); err != nil {
-		return fmt.Errorf("index spec.sources.name: %w", err)
+		return fmt.Errorf("index %s: %w", ruleSetSourcesIndex, err)
 	}
 
-	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &wafv1alpha1.RuleSet{}, "spec.data.name", func(obj client.Object) []string {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &wafv1alpha1.RuleSet{}, ruleSetDataIndex, func(obj client.Object) []string {
 		rs := obj.(*wafv1alpha1.RuleSet)
-		names := make([]string, len(rs.Spec.Data))
-		for i, d := range rs.Spec.Data {
-			names[i] = d.Name
+		keys := make([]string, 0, len(rs.Spec.Data)+len(rs.Spec.ThreatFeeds)+1)
+		for _, d := range rs.Spec.Data {
+			keys = append(keys, referenceIndexKey(referenceNamespace(rs, d.Namespace), d.Name))
 		}
-		return names
+		for _, feed := range rs.Spec.ThreatFeeds {
+			keys = append(keys, referenceIndexKey(rs.Namespace, threatFeedDataName(feed.Name)))
+		}
+		if honeypotBlockDuration(rs) > 0 {
+			keys = append(keys, referenceIndexKey(rs.Namespace, honeypotDataName(rs.Name)))
+		}
+		return keys
 	}); err != nil {
-		return fmt.Errorf("index spec.data.name: %w", err)
+		return fmt.Errorf("index %s: %w", ruleSetDataIndex, err)
 	}
 
-	return ctrl.NewControllerManagedBy(mgr).
+	b := ctrl.NewControllerManagedBy(mgr).
 		For(&wafv1alpha1.RuleSet{}, builder.WithPredicates(predicate.Or(
 			predicate.GenerationChangedPredicate{},
 			annotationChangedPredicate(wafv1alpha1.AnnotationSkipUnsupportedRulesCheck),
+			rejectedRevisionsChangedPredicate(),
 		))).
 		Watches(
 			&wafv1alpha1.RuleSource{},
-			handler.EnqueueRequestsFromMapFunc(r.findRuleSetsForRuleSource),
+			debouncedEnqueueRequestsFromMapFunc(r.findRuleSetsForRuleSource, r.sourceDebounce),
 			builder.WithPredicates(predicate.Or(
 				predicate.GenerationChangedPredicate{},
 				annotationChangedPredicate(wafv1alpha1.AnnotationSkipValidation),
+				annotationChangedPredicate(wafv1alpha1.AnnotationAllowReferencesFrom),
+				annotationChangedPredicate(wafv1alpha1.AnnotationDraft),
 			)),
 		).
+		// EmergencyBlocks are not debounced: they are meant to take effect
+		// as fast as possible.
 		Watches(
-			&wafv1alpha1.RuleData{},
-			handler.EnqueueRequestsFromMapFunc(r.findRuleSetsForRuleData),
+			&wafv1alpha1.EmergencyBlock{},
+			handler.EnqueueRequestsFromMapFunc(r.findRuleSetsForEmergencyBlock),
 			builder.WithPredicates(predicate.GenerationChangedPredicate{}),
 		).
+		Watches(
+			&wafv1alpha1.Engine{},
+			handler.EnqueueRequestsFromMapFunc(r.findRuleSetsForEngine),
+			builder.WithPredicates(predicate.Or(predicate.GenerationChangedPredicate{}, predicate.LabelChangedPredicate{})),
+		).
+		Watches(
+			&wafv1alpha1.RuleSetApproval{},
+			handler.EnqueueRequestsFromMapFunc(r.findRuleSetsForApproval),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}),
+		).
+		Watches(
+			&wafv1alpha1.RuleData{},
+			debouncedEnqueueRequestsFromMapFunc(r.findRuleSetsForRuleData, r.sourceDebounce),
+			builder.WithPredicates(predicate.Or(
+				predicate.GenerationChangedPredicate{},
+				annotationChangedPredicate(wafv1alpha1.AnnotationAllowReferencesFrom),
+			)),
+		).
 		WithOptions(controller.Options{
 			RateLimiter: workqueue.NewTypedItemExponentialFailureRateLimiter[ctrl.Request](
 				1*time.Second,
 				1*time.Minute,
 			),
+			// Every replica fills its own cache; see manager_ha.go.
+			NeedLeaderElection: ptr.To(false),
 		}).
-		Named("ruleset").
-		Complete(r)
+		Named("ruleset")
+
+	// Every RuleSet is reconciled when the OperatorConfig changes the
+	// namespaces requiring rule approval.
+	if r.Runtime != nil {
+		b = b.WatchesRawSource(source.Channel(r.Runtime.approvalEvents, handler.EnqueueRequestsFromMapFunc(r.findAllRuleSets)))
+	}
+
+	// Watching a kind that is not installed fails the controller start.
+	if r.hasCapability(CapabilityReferenceGrant) {
+		referenceGrant := &unstructured.Unstructured{}
+		referenceGrant.SetGroupVersionKind(references.ReferenceGrantGVK)
+		b = b.Watches(referenceGrant, handler.EnqueueRequestsFromMapFunc(r.findRuleSetsForReferenceGrant))
+	}
+	if r.hasCapability(CapabilityHTTPRoute) {
+		httpRoute := &unstructured.Unstructured{}
+		httpRoute.SetGroupVersionKind(HTTPRouteGVK)
+		b = b.Watches(
+			httpRoute,
+			handler.EnqueueRequestsFromMapFunc(r.findRuleSetsForHTTPRoute),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}),
+		)
+	}
+	if r.hasCapability(CapabilityGRPCRoute) {
+		grpcRoute := &unstructured.Unstructured{}
+		grpcRoute.SetGroupVersionKind(GRPCRouteGVK)
+		b = b.Watches(
+			grpcRoute,
+			handler.EnqueueRequestsFromMapFunc(r.findRuleSetsForGRPCRoute),
+			builder.WithPredicates(predicate.GenerationChangedPredicate{}),
+		)
+	}
+
+	return b.Complete(r)
+}
+
+// hasCapability reports whether the optional API name was detected, or true
+// when capabilities were not detected.
+func (r *RuleSetReconciler) hasCapability(name Capability) bool {
+	return r.capabilities == nil || r.capabilities.Has(name)
+}
+
+// resolver returns the Resolver for the RuleSources and RuleData a RuleSet
+// references in other namespaces.
+func (r *RuleSetReconciler) resolver() *references.Resolver {
+	return references.NewResolver(r.Client, r.Scheme, r.hasCapability(CapabilityReferenceGrant))
+}
+
+// sourceDebounce returns the effective RuleSource debounce window.
+func (r *RuleSetReconciler) sourceDebounce() time.Duration {
+	if window, ok := r.Runtime.RuleSourceDebounce(); ok {
+		return window
+	}
+	return r.SourceDebounce
 }
 
 // -----------------------------------------------------------------------------
@@ -125,6 +250,7 @@ func (r *RuleSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 	var ruleset wafv1alpha1.RuleSet
 	if err := r.Get(ctx, req.NamespacedName, &ruleset); err != nil {
 		if apierrors.IsNotFound(err) {
+			r.missingSources.report(req.NamespacedName, "")
 			cacheKey := fmt.Sprintf("%s/%s", req.Namespace, req.Name)
 			if r.Cache.Delete(cacheKey) {
 				logDebug(log, req, "RuleSet", "Deleted cache entry for removed resource")
@@ -156,11 +282,130 @@ func (r *RuleSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		return ctrl.Result{}, err
 	}
 
+	logDebug(log, req, "RuleSet", "Loading ThreatFeed data")
+	dataFiles, done, err = r.loadThreatFeedData(ctx, log, req, &ruleset, dataFiles)
+	if done || err != nil {
+		return ctrl.Result{}, err
+	}
+
+	logDebug(log, req, "RuleSet", "Loading honeypot data")
+	dataFiles, honeypotExpiry, done, err := r.loadHoneypotData(ctx, log, req, &ruleset, dataFiles)
+	if done || err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := validateIPAccessControl(ruleset.Spec.IPAccessControl); err != nil {
+		logInfo(log, req, "RuleSet", "Invalid IP access control", "detail", err.Error())
+		if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "RuleSet", &ruleset, &ruleset.Status.Conditions, ruleset.Generation, "InvalidIPAccessControl", err.Error()); patchErr != nil {
+			return ctrl.Result{}, patchErr
+		}
+		return ctrl.Result{}, nil
+	}
+	dataFiles = ipAccessControlData(&ruleset, dataFiles)
+	if msg := missingIPAccessDataFiles(&ruleset, dataFiles); msg != "" {
+		logInfo(log, req, "RuleSet", "IP access control data files not found", "detail", msg)
+		if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "RuleSet", &ruleset, &ruleset.Status.Conditions, ruleset.Generation, "IPAccessDataFileNotFound", msg); patchErr != nil {
+			return ctrl.Result{}, patchErr
+		}
+		return ctrl.Result{}, nil
+	}
+	dataFiles = botManagementData(&ruleset, dataFiles)
+	if msg := missingBotDataFiles(&ruleset, dataFiles); msg != "" {
+		logInfo(log, req, "RuleSet", "Bot management data files not found", "detail", msg)
+		if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "RuleSet", &ruleset, &ruleset.Status.Conditions, ruleset.Generation, "BotDataFileNotFound", msg); patchErr != nil {
+			return ctrl.Result{}, patchErr
+		}
+		return ctrl.Result{}, nil
+	}
+
 	logDebug(log, req, "RuleSet", "Loading RuleSource objects")
-	aggregatedRules, aggregatedErrors, done, err := r.loadSources(ctx, log, req, &ruleset, dataFiles)
+	aggregatedRules, aggregatedErrors, sourceRefresh, done, err := r.loadSources(ctx, log, req, &ruleset, dataFiles)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if done {
+		return ctrl.Result{RequeueAfter: sourceRefresh}, nil
+	}
+	logDebug(log, req, "RuleSet", "Loading EmergencyBlocks")
+	blocks, blockExpiry, err := r.loadEmergencyBlocks(ctx, log, req, &ruleset)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	logDebug(log, req, "RuleSet", "Loading route overlays")
+	overlays, done, err := r.loadRouteOverlays(ctx, log, req, &ruleset)
+	if done || err != nil {
+		return ctrl.Result{}, err
+	}
+	logDebug(log, req, "RuleSet", "Loading route scope")
+	scope, done, err := r.loadRouteScope(ctx, log, req, &ruleset)
 	if done || err != nil {
 		return ctrl.Result{}, err
 	}
+	logDebug(log, req, "RuleSet", "Loading Engine rules")
+	engineRules, done, err := r.loadEngineRules(ctx, log, req, &ruleset)
+	if done || err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Only the rules of the sources are linted, not the operator-generated ones.
+	findings := lintFindings(&ruleset, aggregatedRules)
+	if bots := botManagementRules(&ruleset); bots != "" {
+		logDebug(log, req, "RuleSet", "Prepending bot management rules", "verifiedCrawlerCount", len(ruleset.Spec.BotManagement.VerifiedCrawlers))
+		aggregatedRules = bots + aggregatedRules
+	}
+	if honeypot := honeypotRules(&ruleset); honeypot != "" {
+		logDebug(log, req, "RuleSet", "Prepending honeypot rules")
+		aggregatedRules = honeypot + aggregatedRules
+	}
+	if feeds := threatFeedRules(&ruleset); feeds != "" {
+		logDebug(log, req, "RuleSet", "Prepending threat feed rules", "threatFeedCount", len(ruleset.Spec.ThreatFeeds))
+		aggregatedRules = feeds + aggregatedRules
+	}
+	if exemptions := exemptionRules(&ruleset); exemptions != "" {
+		logDebug(log, req, "RuleSet", "Prepending exemption rules", "exemptionCount", len(ruleset.Spec.Exemptions))
+		aggregatedRules = exemptions + aggregatedRules
+	}
+	// Route overlays run before the exemptions, which take precedence.
+	if overlays != "" {
+		logDebug(log, req, "RuleSet", "Prepending route overlay rules", "routeOverlayCount", len(ruleset.Spec.RouteOverlays))
+		aggregatedRules = overlays + aggregatedRules
+	}
+	// The CRS tuning runs before the route overlays, which can override
+	// the paranoia level of the requests they match.
+	if crs := crsTuningRules(&ruleset); crs != "" {
+		logDebug(log, req, "RuleSet", "Prepending CRS tuning rules")
+		aggregatedRules = crs + aggregatedRules
+	}
+	// The route scope runs before the route overlays, which can change the
+	// inspection of the requests it leaves out.
+	if scope != "" {
+		logDebug(log, req, "RuleSet", "Prepending route scope rules", "routeScopeCount", len(ruleset.Spec.RouteScope))
+		aggregatedRules = scope + aggregatedRules
+	}
+	// The rules generated from the Engines, such as their inspection bypass,
+	// run before those of the RuleSet, but after the IP access control and
+	// the emergency blocks, which no request must escape.
+	if engineRules != "" {
+		logDebug(log, req, "RuleSet", "Prepending Engine rules")
+		aggregatedRules = engineRules + aggregatedRules
+	}
+	// The IP access control runs before every other rule, so that allowed
+	// addresses skip them, but after the emergency blocks.
+	if ipAccess := ipAccessControlRules(&ruleset); ipAccess != "" {
+		logDebug(log, req, "RuleSet", "Prepending IP access control rules")
+		aggregatedRules = ipAccess + aggregatedRules
+	}
+	if emergency := emergencyBlockRules(blocks); emergency != "" {
+		logInfo(log, req, "RuleSet", "Prepending emergency block rules", "emergencyBlockCount", len(blocks))
+		aggregatedRules = emergency + aggregatedRules
+	}
+
+	if len(r.hooks) > 0 {
+		logDebug(log, req, "RuleSet", "Running RuleSet hooks", "hookCount", len(r.hooks))
+		aggregatedRules, done, err = r.reviewRules(ctx, log, req, &ruleset, aggregatedRules)
+		if done || err != nil {
+			return ctrl.Result{}, err
+		}
+	}
 
 	logInfo(log, req, "RuleSet", "Validating aggregated rules")
 	fsRules := getDataFilesystem(dataFiles)
@@ -181,8 +426,20 @@ func (r *RuleSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ct
 		return ctrl.Result{}, nil
 	}
 
+	if err := r.recordLintFindings(ctx, log, req, &ruleset, findings); err != nil {
+		return ctrl.Result{}, err
+	}
+
 	logInfo(log, req, "RuleSet", "Caching rules")
-	return r.cacheRules(ctx, log, req, &ruleset, aggregatedRules, dataFiles, unsupportedMsg)
+	result, err := r.cacheRules(ctx, log, req, &ruleset, aggregatedRules, dataFiles, unsupportedMsg)
+	// Rebuild the rules when the next honeypot or emergency block expires, or
+	// when a source that is not watched must be fetched again.
+	for _, expiry := range []time.Duration{honeypotExpiry, blockExpiry, sourceRefresh} {
+		if err == nil && expiry > 0 && (result.RequeueAfter == 0 || expiry < result.RequeueAfter) {
+			result.RequeueAfter = expiry
+		}
+	}
+	return result, err
 }
 
 // -----------------------------------------------------------------------------
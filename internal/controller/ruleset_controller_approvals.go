@@ -0,0 +1,189 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// RuleSet Approvals - RBAC
+// -----------------------------------------------------------------------------
+
+// +kubebuilder:rbac:groups=waf.k8s.coraza.io,resources=rulesetapprovals,verbs=get;list;watch
+
+// -----------------------------------------------------------------------------
+// RuleSet Approvals
+// -----------------------------------------------------------------------------
+
+// maxPendingRevisionAuthors bounds the authors recorded in the status of a
+// RuleSet, as its CRD does.
+const maxPendingRevisionAuthors = 32
+
+// isPendingApproval reports whether the revision id of the rules of the
+// RuleSet awaits approval, and returns its authors when it does: the
+// OperatorConfig requires the rule changes of its namespace to be approved,
+// the revision is not the one already published, and no RuleSetApproval
+// approves it. An approval counts only when the admission webhooks
+// recorded its approver, and the approver is none of the authors of the
+// revision.
+func (r *RuleSetReconciler) isPendingApproval(
+	ctx context.Context,
+	log logr.Logger,
+	req ctrl.Request,
+	ruleset *wafv1alpha1.RuleSet,
+	id string,
+) (bool, []string, error) {
+	if !r.Runtime.RuleApprovalRequired(ruleset.Namespace) {
+		return false, nil, nil
+	}
+	// The published revision was approved before, for example when the
+	// cache of a restarted replica is filled again.
+	if rev := ruleset.Status.Revision; rev != nil && rev.UUID == id {
+		return false, nil, nil
+	}
+
+	authors, err := r.revisionAuthors(ctx, ruleset)
+	if err != nil {
+		logAPIError(log, req, "RuleSet", err, "Failed to read the authors of the revision", nil)
+		return false, nil, err
+	}
+	if !r.AdmissionWebhooks {
+		return true, authors, nil
+	}
+
+	var approvals wafv1alpha1.RuleSetApprovalList
+	if err := r.List(ctx, &approvals, client.InNamespace(ruleset.Namespace)); err != nil {
+		logAPIError(log, req, "RuleSet", err, "Failed to list RuleSetApprovals", nil)
+		return false, nil, err
+	}
+	for _, approval := range approvals.Items {
+		if approval.Spec.RuleSet.Name != ruleset.Name || approval.Spec.Revision != id || !approval.DeletionTimestamp.IsZero() {
+			continue
+		}
+		approver := approval.Annotations[wafv1alpha1.AnnotationApprovedBy]
+		switch {
+		case approver == "":
+			logInfo(log, req, "RuleSet", "Ignoring approval without a recorded approver", "uuid", id, "ruleSetApproval", approval.Name)
+		case slices.Contains(authors, approver):
+			logInfo(log, req, "RuleSet", "Ignoring approval by an author of the revision", "uuid", id, "ruleSetApproval", approval.Name, "approver", approver)
+		default:
+			logInfo(log, req, "RuleSet", "Revision approved", "uuid", id, "ruleSetApproval", approval.Name, "approver", approver)
+			return false, nil, nil
+		}
+	}
+	return true, authors, nil
+}
+
+// revisionAuthors returns the users who changed the RuleSet, or the
+// RuleSources and RuleData it references, since its last published
+// revision, sorted: those already recorded in its status while a revision
+// is pending, and those of the AnnotationModifiedBy of the objects. Objects
+// that are missing are skipped; they fail the composition of the rules.
+func (r *RuleSetReconciler) revisionAuthors(ctx context.Context, ruleset *wafv1alpha1.RuleSet) ([]string, error) {
+	var authors []string
+	if ruleset.Status.PendingRevision != "" {
+		authors = append(authors, ruleset.Status.PendingRevisionAuthors...)
+	}
+	authors = append(authors, ruleset.Annotations[wafv1alpha1.AnnotationModifiedBy])
+
+	for _, src := range ruleset.Spec.Sources {
+		if !isRuleSourceReference(src) {
+			continue
+		}
+		var source wafv1alpha1.RuleSource
+		if err := r.Get(ctx, client.ObjectKey{Namespace: referenceNamespace(ruleset, src.Namespace), Name: src.Name}, &source); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		authors = append(authors, source.Annotations[wafv1alpha1.AnnotationModifiedBy])
+	}
+	for _, ref := range ruleset.Spec.Data {
+		var data wafv1alpha1.RuleData
+		if err := r.Get(ctx, client.ObjectKey{Namespace: referenceNamespace(ruleset, ref.Namespace), Name: ref.Name}, &data); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		authors = append(authors, data.Annotations[wafv1alpha1.AnnotationModifiedBy])
+	}
+
+	authors = slices.DeleteFunc(authors, func(author string) bool { return author == "" })
+	slices.Sort(authors)
+	return slices.Compact(authors), nil
+}
+
+// patchPendingApproval records the revision id awaiting approval, and its
+// authors, in the RuleSet status and marks it Degraded. The cache keeps
+// serving the previous revision.
+func (r *RuleSetReconciler) patchPendingApproval(
+	ctx context.Context,
+	log logr.Logger,
+	req ctrl.Request,
+	ruleset *wafv1alpha1.RuleSet,
+	id string,
+	authors []string,
+) error {
+	logInfo(log, req, "RuleSet", "Revision awaits approval", "uuid", id, "authors", authors)
+	msg := fmt.Sprintf("Revision %s of the rules awaits approval: create a RuleSetApproval for it; the previous revision is served until then", id)
+	if !r.AdmissionWebhooks {
+		msg = fmt.Sprintf("Revision %s of the rules awaits approval, but approvals require the admission webhooks of the operator, which are disabled; "+
+			"the previous revision is served until then", id)
+	}
+	r.Recorder.Eventf(ruleset, nil, "Warning", "PendingApproval", "Reconcile", msg)
+	return patchConditions(ctx, r.Status(), log, req, "RuleSet", ruleset, &ruleset.Status.Conditions, func() {
+		ruleset.Status.PendingRevision = id
+		ruleset.Status.PendingRevisionAuthors = authors[:min(len(authors), maxPendingRevisionAuthors)]
+		applyStatusConditionDegraded(&ruleset.Status.Conditions, ruleset.Generation, "PendingApproval", msg)
+	})
+}
+
+// findRuleSetsForApproval maps a RuleSetApproval to the RuleSet it approves.
+func (r *RuleSetReconciler) findRuleSetsForApproval(_ context.Context, obj client.Object) []reconcile.Request {
+	approval, ok := obj.(*wafv1alpha1.RuleSetApproval)
+	if !ok || approval.Spec.RuleSet.Name == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: client.ObjectKey{Namespace: approval.Namespace, Name: approval.Spec.RuleSet.Name}}}
+}
+
+// findAllRuleSets maps a change of the namespaces requiring rule approval to
+// every RuleSet.
+func (r *RuleSetReconciler) findAllRuleSets(ctx context.Context, _ client.Object) []reconcile.Request {
+	log := logf.FromContext(ctx)
+
+	var ruleSets wafv1alpha1.RuleSetList
+	if err := r.List(ctx, &ruleSets); err != nil {
+		log.Error(err, "RuleSet: Failed to list RuleSets")
+		return nil
+	}
+	return collectRequests(ruleSets.Items, func(*wafv1alpha1.RuleSet) bool { return true })
+}
@@ -0,0 +1,258 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/test/utils"
+)
+
+func TestRuntimeConfig_RuleApprovalRequired(t *testing.T) {
+	var unset *RuntimeConfig
+	assert.False(t, unset.RuleApprovalRequired("prod"))
+
+	runtimeConfig := NewRuntimeConfig()
+	assert.False(t, runtimeConfig.RuleApprovalRequired("prod"))
+
+	runtimeConfig.apply(&wafv1alpha1.OperatorConfig{Spec: wafv1alpha1.OperatorConfigSpec{
+		RuleApproval: &wafv1alpha1.RuleApproval{Namespaces: []string{"prod"}},
+	}})
+	assert.True(t, runtimeConfig.RuleApprovalRequired("prod"))
+	assert.False(t, runtimeConfig.RuleApprovalRequired("staging"))
+	assert.Len(t, runtimeConfig.approvalEvents, 1, "RuleSets are re-checked when the namespaces change")
+
+	<-runtimeConfig.approvalEvents
+	runtimeConfig.apply(&wafv1alpha1.OperatorConfig{Spec: wafv1alpha1.OperatorConfigSpec{
+		RuleApproval: &wafv1alpha1.RuleApproval{Namespaces: []string{"prod"}},
+	}})
+	assert.Empty(t, runtimeConfig.approvalEvents, "unchanged namespaces signal nothing")
+
+	runtimeConfig.apply(nil)
+	assert.False(t, runtimeConfig.RuleApprovalRequired("prod"))
+}
+
+func TestRuleSetReconciler_IsPendingApproval(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+
+	const (
+		published = "11111111-1111-1111-1111-111111111111"
+		candidate = "22222222-2222-2222-2222-222222222222"
+	)
+	approval := func(ruleSet, revision, approver string) *wafv1alpha1.RuleSetApproval {
+		approval := &wafv1alpha1.RuleSetApproval{
+			ObjectMeta: metav1.ObjectMeta{Name: ruleSet + "-" + revision[:8], Namespace: "prod"},
+			Spec: wafv1alpha1.RuleSetApprovalSpec{
+				RuleSet:  wafv1alpha1.RuleSetReference{Name: ruleSet},
+				Revision: revision,
+			},
+		}
+		if approver != "" {
+			approval.Annotations = map[string]string{wafv1alpha1.AnnotationApprovedBy: approver}
+		}
+		return approval
+	}
+
+	tests := []struct {
+		name           string
+		namespace      string
+		approvals      []client.Object
+		id             string
+		disableWebhook bool
+		pendingAuthors []string
+		wantPending    bool
+		wantAuthors    []string
+	}{
+		{
+			name:      "namespace not protected",
+			namespace: "staging",
+			id:        candidate,
+		},
+		{
+			name:      "published revision",
+			namespace: "prod",
+			id:        published,
+		},
+		{
+			name:        "no approval",
+			namespace:   "prod",
+			id:          candidate,
+			wantPending: true,
+			wantAuthors: []string{"alice", "bob"},
+		},
+		{
+			name:        "approval of another revision",
+			namespace:   "prod",
+			approvals:   []client.Object{approval("ruleset", published, "carol")},
+			id:          candidate,
+			wantPending: true,
+			wantAuthors: []string{"alice", "bob"},
+		},
+		{
+			name:        "approval of another RuleSet",
+			namespace:   "prod",
+			approvals:   []client.Object{approval("other", candidate, "carol")},
+			id:          candidate,
+			wantPending: true,
+			wantAuthors: []string{"alice", "bob"},
+		},
+		{
+			name:      "approved",
+			namespace: "prod",
+			approvals: []client.Object{approval("ruleset", candidate, "carol")},
+			id:        candidate,
+		},
+		{
+			name:        "approval without a recorded approver",
+			namespace:   "prod",
+			approvals:   []client.Object{approval("ruleset", candidate, "")},
+			id:          candidate,
+			wantPending: true,
+			wantAuthors: []string{"alice", "bob"},
+		},
+		{
+			name:        "approved by the author of the RuleSet",
+			namespace:   "prod",
+			approvals:   []client.Object{approval("ruleset", candidate, "alice")},
+			id:          candidate,
+			wantPending: true,
+			wantAuthors: []string{"alice", "bob"},
+		},
+		{
+			name:        "approved by the author of a RuleSource",
+			namespace:   "prod",
+			approvals:   []client.Object{approval("ruleset", candidate, "bob")},
+			id:          candidate,
+			wantPending: true,
+			wantAuthors: []string{"alice", "bob"},
+		},
+		{
+			name:           "approved by an earlier author of the pending revision",
+			namespace:      "prod",
+			approvals:      []client.Object{approval("ruleset", candidate, "dave")},
+			id:             candidate,
+			pendingAuthors: []string{"dave"},
+			wantPending:    true,
+			wantAuthors:    []string{"alice", "bob", "dave"},
+		},
+		{
+			name:           "admission webhooks disabled",
+			namespace:      "prod",
+			approvals:      []client.Object{approval("ruleset", candidate, "carol")},
+			id:             candidate,
+			disableWebhook: true,
+			wantPending:    true,
+			wantAuthors:    []string{"alice", "bob"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, obj := range tt.approvals {
+				obj.SetNamespace(tt.namespace)
+			}
+			source := &wafv1alpha1.RuleSource{ObjectMeta: metav1.ObjectMeta{
+				Name: "rules", Namespace: tt.namespace,
+				Annotations: map[string]string{wafv1alpha1.AnnotationModifiedBy: "bob"},
+			}}
+			ruleset := &wafv1alpha1.RuleSet{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "ruleset", Namespace: tt.namespace,
+					Annotations: map[string]string{wafv1alpha1.AnnotationModifiedBy: "alice"},
+				},
+				Spec: wafv1alpha1.RuleSetSpec{
+					Sources: []wafv1alpha1.SourceReference{{Name: "rules"}, {Name: "missing"}},
+				},
+				Status: wafv1alpha1.RuleSetStatus{
+					Revision: &wafv1alpha1.RuleSetRevision{UUID: published},
+				},
+			}
+			if tt.pendingAuthors != nil {
+				ruleset.Status.PendingRevision = candidate
+				ruleset.Status.PendingRevisionAuthors = tt.pendingAuthors
+			}
+			runtimeConfig := NewRuntimeConfig()
+			runtimeConfig.apply(&wafv1alpha1.OperatorConfig{Spec: wafv1alpha1.OperatorConfigSpec{
+				RuleApproval: &wafv1alpha1.RuleApproval{Namespaces: []string{"prod"}},
+			}})
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(append(tt.approvals, source)...).Build()
+			r := &RuleSetReconciler{
+				Client: c, Scheme: scheme, Recorder: utils.NewTestRecorder(), Runtime: runtimeConfig,
+				AdmissionWebhooks: !tt.disableWebhook,
+			}
+			req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(ruleset)}
+
+			pending, authors, err := r.isPendingApproval(t.Context(), ctrl.Log, req, ruleset, tt.id)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantPending, pending)
+			assert.Equal(t, tt.wantAuthors, authors)
+		})
+	}
+}
+
+func TestRuleSetReconciler_PatchPendingApproval(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+
+	const id = "22222222-2222-2222-2222-222222222222"
+	ruleset := &wafv1alpha1.RuleSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "ruleset", Namespace: "prod", Generation: 2},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(ruleset).
+		WithStatusSubresource(ruleset).
+		Build()
+	recorder := utils.NewFakeRecorder()
+	r := &RuleSetReconciler{Client: c, Scheme: scheme, Recorder: recorder, AdmissionWebhooks: true}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(ruleset)}
+
+	require.NoError(t, r.patchPendingApproval(t.Context(), ctrl.Log, req, ruleset, id, []string{"alice"}))
+
+	var got wafv1alpha1.RuleSet
+	require.NoError(t, c.Get(t.Context(), req.NamespacedName, &got))
+	assert.Equal(t, id, got.Status.PendingRevision)
+	assert.Equal(t, []string{"alice"}, got.Status.PendingRevisionAuthors)
+	cond := apimeta.FindStatusCondition(got.Status.Conditions, conditionDegraded)
+	require.NotNil(t, cond)
+	assert.Equal(t, "PendingApproval", cond.Reason)
+	assert.Contains(t, cond.Message, id)
+	assert.True(t, recorder.HasEvent("Warning", "PendingApproval"))
+}
+
+func TestRuleSetReconciler_FindRuleSetsForApproval(t *testing.T) {
+	r := &RuleSetReconciler{}
+	approval := &wafv1alpha1.RuleSetApproval{
+		ObjectMeta: metav1.ObjectMeta{Name: "approve", Namespace: "prod"},
+		Spec:       wafv1alpha1.RuleSetApprovalSpec{RuleSet: wafv1alpha1.RuleSetReference{Name: "ruleset"}},
+	}
+	requests := r.findRuleSetsForApproval(t.Context(), approval)
+	require.Len(t, requests, 1)
+	assert.Equal(t, client.ObjectKey{Namespace: "prod", Name: "ruleset"}, requests[0].NamespacedName)
+
+	assert.Empty(t, r.findRuleSetsForApproval(t.Context(), &wafv1alpha1.RuleSet{}))
+}
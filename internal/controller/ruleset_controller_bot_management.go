@@ -0,0 +1,203 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// RuleSet Bot Management - Vars
+// -----------------------------------------------------------------------------
+
+const (
+	// botRuleIDBase is the first rule ID of the rules generated for the bot
+	// management of a RuleSet. The verified crawler at index i uses the IDs
+	// botRuleIDBase+10+2*i and botRuleIDBase+11+2*i; RuleSources must not
+	// use IDs from this range.
+	botRuleIDBase = 89600000
+
+	// botTag tags the bot management rules.
+	botTag = "bot-management"
+
+	// unknownBotPattern matches the User-Agents of automated clients.
+	unknownBotPattern = `(?i)(?:bot|crawl|spider|scrape|slurp|curl|wget|python-requests|python-urllib|go-http-client|java/|libwww|httpclient|okhttp|headless)`
+)
+
+// builtInBadBots are the User-Agent substrings of the bad bots blocked unless
+// skipBuiltIn is set: vulnerability scanners and attack tools.
+var builtInBadBots = []string{
+	"acunetix",
+	"dirbuster",
+	"feroxbuster",
+	"gobuster",
+	"havij",
+	"masscan",
+	"nessus",
+	"netsparker",
+	"nikto",
+	"nmap",
+	"nuclei",
+	"openvas",
+	"sqlmap",
+	"w3af",
+	"wpscan",
+	"zgrab",
+}
+
+// -----------------------------------------------------------------------------
+// RuleSet Bot Management - Rules
+// -----------------------------------------------------------------------------
+
+// badBotsDataName returns the name of the data file listing the bad bot
+// User-Agents of the RuleSet name.
+func badBotsDataName(name string) string {
+	const suffix = "-bad-bots"
+	if len(name)+len(suffix) > 253 {
+		name = strings.TrimRight(name[:253-len(suffix)], "-.")
+	}
+	return name + suffix
+}
+
+// badBotUserAgents returns the built-in and inline bad bot User-Agents of the
+// bot management, lowercased, sorted and without duplicates.
+func badBotUserAgents(bm *wafv1alpha1.BotManagement) []string {
+	var agents []string
+	if bm.BadBots == nil || !bm.BadBots.SkipBuiltIn {
+		agents = append(agents, builtInBadBots...)
+	}
+	if bm.BadBots != nil {
+		for _, agent := range bm.BadBots.UserAgents {
+			agents = append(agents, strings.ToLower(agent))
+		}
+	}
+	slices.Sort(agents)
+	return slices.Compact(agents)
+}
+
+// botManagementData adds the data file listing the bad bot User-Agents of the
+// RuleSet to dataFiles.
+func botManagementData(ruleset *wafv1alpha1.RuleSet, dataFiles map[string][]byte) map[string][]byte {
+	bm := ruleset.Spec.BotManagement
+	if bm == nil {
+		return dataFiles
+	}
+	agents := badBotUserAgents(bm)
+	if len(agents) == 0 {
+		return dataFiles
+	}
+	if dataFiles == nil {
+		dataFiles = make(map[string][]byte, 1)
+	}
+	dataFiles[badBotsDataName(ruleset.Name)] = []byte(strings.Join(agents, "\n"))
+	return dataFiles
+}
+
+// missingBotDataFiles returns a message naming the data files referenced by
+// the bot management of the RuleSet that are not in dataFiles, or an empty
+// string when they all are.
+func missingBotDataFiles(ruleset *wafv1alpha1.RuleSet, dataFiles map[string][]byte) string {
+	bm := ruleset.Spec.BotManagement
+	if bm == nil {
+		return ""
+	}
+	var missing []string
+	if bm.BadBots != nil && bm.BadBots.UserAgentsFile != "" {
+		if _, ok := dataFiles[bm.BadBots.UserAgentsFile]; !ok {
+			missing = append(missing, bm.BadBots.UserAgentsFile)
+		}
+	}
+	for _, crawler := range bm.VerifiedCrawlers {
+		if _, ok := dataFiles[crawler.AddressesFile]; !ok {
+			missing = append(missing, crawler.AddressesFile)
+		}
+	}
+	if len(missing) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Bot management data files not found in spec.data: %s", strings.Join(missing, ", "))
+}
+
+// botManagementRules returns the SecRules of the bot management of the
+// RuleSet, or an empty string when it has none. The rules run in phase 1,
+// after the honeypot rules: the verified crawlers are marked first, so the
+// bad and unknown bot rules let them through.
+func botManagementRules(ruleset *wafv1alpha1.RuleSet) string {
+	bm := ruleset.Spec.BotManagement
+	if bm == nil {
+		return ""
+	}
+
+	badAction := "deny,status:403"
+	if bm.BadBots != nil && bm.BadBots.Action == wafv1alpha1.BadBotActionLog {
+		badAction = "pass"
+	}
+
+	var b strings.Builder
+	b.WriteString("# Bot management generated from the RuleSet spec.botManagement\n")
+	fmt.Fprintf(&b, "SecAction \"id:%d,phase:1,pass,nolog,t:none,setvar:tx.bot_verified=0\"\n", botRuleIDBase)
+	for i, crawler := range bm.VerifiedCrawlers {
+		fmt.Fprintf(&b, "SecRule REQUEST_HEADERS:User-Agent \"@pm %s\" \"id:%d,phase:1,pass,nolog,t:none,t:lowercase,tag:'%s',chain\"\n",
+			crawlerUserAgents(crawler), botRuleIDBase+10+2*i, botTag)
+		fmt.Fprintf(&b, "  SecRule REMOTE_ADDR \"@ipMatchFromFile %s\" \"setvar:tx.bot_verified=1\"\n", crawler.AddressesFile)
+	}
+	for i, crawler := range bm.VerifiedCrawlers {
+		fmt.Fprintf(&b, "SecRule TX:bot_verified \"@eq 0\" \"id:%d,phase:1,%s,log,t:none,tag:'%s',msg:'Request impersonating verified crawler %s',logdata:'%%{REMOTE_ADDR}',chain\"\n",
+			botRuleIDBase+11+2*i, badAction, botTag, crawler.Name)
+		fmt.Fprintf(&b, "  SecRule REQUEST_HEADERS:User-Agent \"@pm %s\" \"t:none,t:lowercase\"\n", crawlerUserAgents(crawler))
+	}
+	if len(badBotUserAgents(bm)) > 0 {
+		fmt.Fprintf(&b, "SecRule TX:bot_verified \"@eq 0\" \"id:%d,phase:1,%s,log,t:none,tag:'%s',msg:'Bad bot',logdata:'%%{MATCHED_VAR}',chain\"\n",
+			botRuleIDBase+1, badAction, botTag)
+		fmt.Fprintf(&b, "  SecRule REQUEST_HEADERS:User-Agent \"@pmFromFile %s\" \"t:none,t:lowercase\"\n", badBotsDataName(ruleset.Name))
+	}
+	if bm.BadBots != nil && bm.BadBots.UserAgentsFile != "" {
+		fmt.Fprintf(&b, "SecRule TX:bot_verified \"@eq 0\" \"id:%d,phase:1,%s,log,t:none,tag:'%s',msg:'Bad bot',logdata:'%%{MATCHED_VAR}',chain\"\n",
+			botRuleIDBase+2, badAction, botTag)
+		fmt.Fprintf(&b, "  SecRule REQUEST_HEADERS:User-Agent \"@pmFromFile %s\" \"t:none,t:lowercase\"\n", bm.BadBots.UserAgentsFile)
+	}
+
+	var unknownAction string
+	switch bm.UnknownBots {
+	case wafv1alpha1.UnknownBotActionAllow:
+		return b.String()
+	case wafv1alpha1.UnknownBotActionLog:
+		unknownAction = "pass"
+	case wafv1alpha1.UnknownBotActionDeny:
+		unknownAction = "deny,status:403"
+	default:
+		unknownAction = "deny,status:429"
+	}
+	fmt.Fprintf(&b, "SecRule TX:bot_verified \"@eq 0\" \"id:%d,phase:1,%s,log,t:none,tag:'%s',msg:'Unknown bot',logdata:'%%{MATCHED_VAR}',chain\"\n",
+		botRuleIDBase+3, unknownAction, botTag)
+	fmt.Fprintf(&b, "  SecRule REQUEST_HEADERS:User-Agent \"@rx %s\" \"t:none\"\n", unknownBotPattern)
+	return b.String()
+}
+
+// crawlerUserAgents returns the User-Agents of the crawler as the argument of
+// the @pm operator.
+func crawlerUserAgents(crawler wafv1alpha1.VerifiedCrawler) string {
+	agents := make([]string, len(crawler.UserAgents))
+	for i, agent := range crawler.UserAgents {
+		agents[i] = strings.ToLower(agent)
+	}
+	return strings.Join(agents, " ")
+}
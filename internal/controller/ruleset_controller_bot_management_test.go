@@ -0,0 +1,116 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/corazawaf/coraza/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+func TestBotManagementRules(t *testing.T) {
+	assert.Empty(t, botManagementRules(&wafv1alpha1.RuleSet{}))
+	assert.Nil(t, botManagementData(&wafv1alpha1.RuleSet{}, nil))
+
+	ruleset := &wafv1alpha1.RuleSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "rules"},
+		Spec: wafv1alpha1.RuleSetSpec{BotManagement: &wafv1alpha1.BotManagement{
+			BadBots: &wafv1alpha1.BadBots{
+				UserAgents:     []string{"EvilScraper"},
+				UserAgentsFile: "org-bad-bots",
+			},
+			VerifiedCrawlers: []wafv1alpha1.VerifiedCrawler{{
+				Name:          "google",
+				UserAgents:    []string{"Googlebot"},
+				AddressesFile: "googlebot-ranges",
+			}},
+		}},
+	}
+	assert.Equal(t, "Bot management data files not found in spec.data: org-bad-bots, googlebot-ranges",
+		missingBotDataFiles(ruleset, nil))
+
+	dataFiles := botManagementData(ruleset, map[string][]byte{
+		"org-bad-bots":     []byte("badcrawler\n"),
+		"googlebot-ranges": []byte("66.249.64.0/19\n2001:4860:4801::/48"),
+	})
+	assert.Empty(t, missingBotDataFiles(ruleset, dataFiles))
+	assert.Contains(t, string(dataFiles[badBotsDataName("rules")]), "evilscraper\n")
+
+	rules := botManagementRules(ruleset)
+	assert.Contains(t, rules, "id:89600010,")
+	assert.Contains(t, rules, "id:89600011,phase:1,deny,status:403,")
+	assert.Contains(t, rules, "id:89600003,phase:1,deny,status:429,")
+
+	conf := coraza.NewWAFConfig().
+		WithDirectives("SecRuleEngine On\n" + rules).
+		WithRootFS(getDataFilesystem(dataFiles))
+	waf, err := coraza.NewWAF(conf)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		addr       string
+		userAgent  string
+		wantStatus int
+	}{
+		{name: "browser", addr: "192.0.2.1", userAgent: "Mozilla/5.0 (X11; Linux x86_64)"},
+		{name: "verified crawler", addr: "66.249.66.1", userAgent: "Mozilla/5.0 (compatible; Googlebot/2.1)"},
+		{name: "verified IPv6 crawler", addr: "2001:4860:4801::1", userAgent: "Googlebot-Image/1.0"},
+		{name: "impersonated crawler", addr: "192.0.2.1", userAgent: "Mozilla/5.0 (compatible; Googlebot/2.1)", wantStatus: 403},
+		{name: "built-in bad bot", addr: "192.0.2.1", userAgent: "sqlmap/1.7", wantStatus: 403},
+		{name: "inline bad bot", addr: "192.0.2.1", userAgent: "EvilScraper/2.0", wantStatus: 403},
+		{name: "bad bot from file", addr: "192.0.2.1", userAgent: "BadCrawler", wantStatus: 403},
+		{name: "unknown bot", addr: "192.0.2.1", userAgent: "curl/8.5.0", wantStatus: 429},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := waf.NewTransaction()
+			defer func() { _ = tx.Close() }()
+			tx.ProcessConnection(tt.addr, 12345, "10.0.0.1", 8080)
+			tx.ProcessURI("/", "GET", "HTTP/1.1")
+			tx.AddRequestHeader("User-Agent", tt.userAgent)
+			interruption := tx.ProcessRequestHeaders()
+			if tt.wantStatus == 0 {
+				assert.Nil(t, interruption)
+				return
+			}
+			require.NotNil(t, interruption)
+			assert.Equal(t, tt.wantStatus, interruption.Status)
+		})
+	}
+
+	t.Run("log only, no built-in list and unknown bots allowed", func(t *testing.T) {
+		ruleset.Spec.BotManagement = &wafv1alpha1.BotManagement{
+			BadBots:     &wafv1alpha1.BadBots{SkipBuiltIn: true, Action: wafv1alpha1.BadBotActionLog},
+			UnknownBots: wafv1alpha1.UnknownBotActionAllow,
+		}
+		assert.Nil(t, botManagementData(ruleset, nil))
+		rules := botManagementRules(ruleset)
+		assert.NotContains(t, rules, "@pmFromFile")
+		assert.NotContains(t, rules, "Unknown bot")
+	})
+}
+
+func TestBadBotsDataName(t *testing.T) {
+	assert.Equal(t, "rules-bad-bots", badBotsDataName("rules"))
+	assert.Len(t, badBotsDataName(string(make([]byte, 253))), 253)
+}
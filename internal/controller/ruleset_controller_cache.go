@@ -8,6 +8,7 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 
 	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/internal/rulesets/cache"
 )
 
 // -----------------------------------------------------------------------------
@@ -15,7 +16,8 @@ import (
 // -----------------------------------------------------------------------------
 
 // cacheRules stores the aggregated rules in the cache and patches the RuleSet
-// status to Ready.
+// status to Ready. Rules the gateways rejected, and rules awaiting approval,
+// are not stored: the previous revision is served instead.
 func (r *RuleSetReconciler) cacheRules(
 	ctx context.Context,
 	log logr.Logger,
@@ -26,8 +28,34 @@ func (r *RuleSetReconciler) cacheRules(
 	unsupportedMsg string,
 ) (ctrl.Result, error) {
 	cacheKey := fmt.Sprintf("%s/%s", ruleset.Namespace, ruleset.Name)
+	if msg := r.exceedsRuleSetQuota(cacheKey, ruleset.Namespace, aggregatedRules, dataFiles); msg != "" {
+		logInfo(log, req, "RuleSet", "Namespace RuleSet size quota exceeded", "detail", msg)
+		if err := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "RuleSet", ruleset, &ruleset.Status.Conditions, ruleset.Generation, "QuotaExceeded", msg); err != nil {
+			return ctrl.Result{}, err
+		}
+		return ctrl.Result{RequeueAfter: quotaRecheckInterval}, nil
+	}
+
+	id := cache.RevisionID(cacheKey, aggregatedRules, dataFiles)
+	if rejected := findRejectedRevision(&ruleset.Status, id); rejected != nil {
+		return ctrl.Result{}, r.rollBackRevision(ctx, log, req, ruleset, cacheKey, rejected)
+	}
+	pending, authors, err := r.isPendingApproval(ctx, log, req, ruleset, id)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if pending {
+		return ctrl.Result{}, r.patchPendingApproval(ctx, log, req, ruleset, id, authors)
+	}
+
 	r.Cache.Put(cacheKey, aggregatedRules, dataFiles)
 	logInfo(log, req, "RuleSet", "Stored rules in cache", "cacheKey", cacheKey)
+	if err := r.recordPublishedRevision(ctx, log, req, ruleset, cacheKey, id); err != nil {
+		return ctrl.Result{}, err
+	}
+	if err := r.recordSnapshot(ctx, log, req, ruleset, cacheKey, id, aggregatedRules, dataFiles); err != nil {
+		return ctrl.Result{}, err
+	}
 
 	statusMsg := buildCacheReadyMessage(ruleset.Namespace, ruleset.Name, unsupportedMsg)
 	if err := patchReady(ctx, r.Status(), r.Recorder, log, req, "RuleSet", ruleset, &ruleset.Status.Conditions, ruleset.Generation, "RulesCached", statusMsg); err != nil {
@@ -36,3 +64,20 @@ func (r *RuleSetReconciler) cacheRules(
 
 	return ctrl.Result{}, nil
 }
+
+// exceedsRuleSetQuota returns why caching rules and dataFiles for cacheKey
+// would exceed the RuleSet size quota of namespace, or "" when it would not.
+// The other RuleSets of the namespace count with their latest cached rules.
+func (r *RuleSetReconciler) exceedsRuleSetQuota(cacheKey, namespace, rules string, dataFiles map[string][]byte) string {
+	quota := r.Runtime.NamespaceQuota()
+	if quota == nil || quota.MaxRuleSetSize == nil {
+		return ""
+	}
+
+	size := cache.PayloadSize(rules, dataFiles)
+	total := r.Cache.NamespaceSize(namespace, cacheKey) + size
+	if int64(total) <= quota.MaxRuleSetSize.Value() {
+		return ""
+	}
+	return fmt.Sprintf("Composed rules of %d bytes would bring the RuleSets of namespace %q to %d bytes, over the OperatorConfig namespace quota of %s", size, namespace, total, quota.MaxRuleSetSize.String())
+}
@@ -0,0 +1,50 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/utils/ptr"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/internal/rulesets/cache"
+)
+
+func TestRuleSetReconciler_ExceedsRuleSetQuota(t *testing.T) {
+	rulesetCache := cache.NewRuleSetCache()
+	rulesetCache.Put("team-a/base", "0123456789", nil)
+	rulesetCache.Put("team-a/extra", "01234", nil)
+	rulesetCache.Put("team-b/base", "0123456789012345678901234567890123456789", nil)
+
+	runtimeConfig := NewRuntimeConfig()
+	r := &RuleSetReconciler{Cache: rulesetCache, Runtime: runtimeConfig}
+
+	assert.Empty(t, r.exceedsRuleSetQuota("team-a/extra", "team-a", "0123456789012345678901234567890123456789", nil), "without a quota there is no limit")
+
+	runtimeConfig.apply(&wafv1alpha1.OperatorConfig{Spec: wafv1alpha1.OperatorConfigSpec{
+		NamespaceQuota: &wafv1alpha1.NamespaceQuota{MaxRuleSetSize: ptr.To(resource.MustParse("30"))},
+	}})
+
+	assert.Empty(t, r.exceedsRuleSetQuota("team-a/extra", "team-a", "01234567890123456789", nil), "the RuleSet's own cached rules are replaced")
+	assert.Equal(t,
+		`Composed rules of 21 bytes would bring the RuleSets of namespace "team-a" to 31 bytes, over the OperatorConfig namespace quota of 30`,
+		r.exceedsRuleSetQuota("team-a/extra", "team-a", "012345678901234567890", nil))
+	assert.NotEmpty(t, r.exceedsRuleSetQuota("team-a/new", "team-a", "0123456789", map[string][]byte{"data.txt": []byte("0123456789")}), "data files count")
+}
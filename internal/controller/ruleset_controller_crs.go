@@ -0,0 +1,82 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// RuleSet CRS Tuning - Vars
+// -----------------------------------------------------------------------------
+
+// crsTuningRuleID is the ID of the SecAction generated for the RuleSet CRS
+// tuning; RuleSources must not use it.
+const crsTuningRuleID = 89590000
+
+// crsVersionPattern matches the ver action of the CRS rules, such as
+// ver:'OWASP_CRS/4.24.1'.
+var crsVersionPattern = regexp.MustCompile(`ver:'OWASP_CRS/([^']{1,64})'`)
+
+// -----------------------------------------------------------------------------
+// RuleSet CRS Tuning
+// -----------------------------------------------------------------------------
+
+// crsTuningRules returns the SecAction setting the CRS transaction variables
+// of the RuleSet spec.crs, or an empty string when it has none. CRS only
+// sets the variables that are not set yet, so the SecAction must come
+// before the rules of the sources.
+func crsTuningRules(ruleset *wafv1alpha1.RuleSet) string {
+	crs := ruleset.Spec.CRS
+	if crs == nil {
+		return ""
+	}
+
+	var actions []string
+	if crs.ParanoiaLevel > 0 {
+		actions = append(actions,
+			fmt.Sprintf("setvar:tx.blocking_paranoia_level=%d", crs.ParanoiaLevel),
+			fmt.Sprintf("setvar:tx.detection_paranoia_level=%d", crs.ParanoiaLevel))
+	}
+	if crs.InboundAnomalyThreshold > 0 {
+		actions = append(actions, fmt.Sprintf("setvar:tx.inbound_anomaly_score_threshold=%d", crs.InboundAnomalyThreshold))
+	}
+	if crs.OutboundAnomalyThreshold > 0 {
+		actions = append(actions, fmt.Sprintf("setvar:tx.outbound_anomaly_score_threshold=%d", crs.OutboundAnomalyThreshold))
+	}
+	if len(actions) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("# CRS tuning generated from the RuleSet spec.crs\nSecAction \"id:%d,phase:1,pass,nolog,t:none,msg:'RuleSet CRS tuning',%s\"\n",
+		crsTuningRuleID, strings.Join(actions, ","))
+}
+
+// crsVersion returns the version of the CRS in rules, from the ver action of
+// its rules, or an empty string when rules do not include the CRS. CRS rules
+// all carry the version of their release.
+func crsVersion(rules string) string {
+	m := crsVersionPattern.FindStringSubmatch(rules)
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
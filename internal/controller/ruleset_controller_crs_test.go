@@ -0,0 +1,73 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/corazawaf/coraza/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+func TestCRSTuningRules(t *testing.T) {
+	// Stand-ins for the CRS rules reading the tuned variables.
+	const crsRules = `SecRuleEngine On
+SecRule TX:blocking_paranoia_level "@eq 3" "id:1,phase:1,deny,status:403"
+SecRule TX:inbound_anomaly_score_threshold "@eq 20" "id:2,phase:1,deny,status:403"
+SecRule TX:outbound_anomaly_score_threshold "@eq 8" "id:3,phase:1,deny,status:403"`
+
+	tests := []struct {
+		name    string
+		crs     *wafv1alpha1.CRSTuning
+		blocked bool
+	}{
+		{name: "unset"},
+		{name: "paranoia level", crs: &wafv1alpha1.CRSTuning{ParanoiaLevel: 3}, blocked: true},
+		{name: "inbound anomaly threshold", crs: &wafv1alpha1.CRSTuning{InboundAnomalyThreshold: 20}, blocked: true},
+		{name: "outbound anomaly threshold", crs: &wafv1alpha1.CRSTuning{OutboundAnomalyThreshold: 8}, blocked: true},
+		{name: "other values", crs: &wafv1alpha1.CRSTuning{ParanoiaLevel: 2, InboundAnomalyThreshold: 10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := crsTuningRules(&wafv1alpha1.RuleSet{Spec: wafv1alpha1.RuleSetSpec{CRS: tt.crs}})
+			if tt.crs == nil {
+				assert.Empty(t, rules)
+			}
+
+			waf, err := coraza.NewWAF(coraza.NewWAFConfig().WithDirectives(rules + crsRules))
+			require.NoError(t, err)
+			tx := waf.NewTransaction()
+			defer func() { _ = tx.Close() }()
+			tx.ProcessURI("/", "GET", "HTTP/1.1")
+			interruption := tx.ProcessRequestHeaders()
+			if tt.blocked {
+				assert.NotNil(t, interruption, "the tuned variable should be set")
+			} else {
+				assert.Nil(t, interruption, "no tuned variable should match")
+			}
+		})
+	}
+}
+
+func TestCRSVersion(t *testing.T) {
+	assert.Equal(t, "4.24.1", crsVersion(`SecRuleEngine On
+SecRule REQUEST_URI "@contains /admin" "id:942100,phase:2,deny,tag:'OWASP_CRS',ver:'OWASP_CRS/4.24.1'"`))
+	assert.Empty(t, crsVersion(`SecRule REQUEST_URI "@contains /admin" "id:1,phase:2,deny"`))
+}
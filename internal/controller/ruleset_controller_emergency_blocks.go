@@ -0,0 +1,148 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// RuleSet Emergency Blocks - Vars
+// -----------------------------------------------------------------------------
+
+// emergencyBlockRuleIDBase is the first rule ID of the rules generated for
+// the EmergencyBlocks of a RuleSet. The active block at index i, by name,
+// uses the ID emergencyBlockRuleIDBase+i; RuleSources must not use IDs from
+// this range.
+const emergencyBlockRuleIDBase = 89400000
+
+// -----------------------------------------------------------------------------
+// RuleSet Emergency Blocks
+// -----------------------------------------------------------------------------
+
+// loadEmergencyBlocks returns the active EmergencyBlocks selecting an Engine
+// using the RuleSet, sorted by name, and when the next one expires, for
+// rebuilding the rules then, or zero when none is active. Invalid blocks
+// are skipped, and reported by the EmergencyBlock controller.
+func (r *RuleSetReconciler) loadEmergencyBlocks(
+	ctx context.Context,
+	log logr.Logger,
+	req ctrl.Request,
+	ruleset *wafv1alpha1.RuleSet,
+) ([]wafv1alpha1.EmergencyBlock, time.Duration, error) {
+	var blocks wafv1alpha1.EmergencyBlockList
+	if err := r.List(ctx, &blocks, client.InNamespace(ruleset.Namespace)); err != nil {
+		logAPIError(log, req, "RuleSet", err, "Failed to list EmergencyBlocks", nil)
+		return nil, 0, err
+	}
+	if len(blocks.Items) == 0 {
+		return nil, 0, nil
+	}
+
+	var engines wafv1alpha1.EngineList
+	if err := r.List(ctx, &engines, client.InNamespace(ruleset.Namespace)); err != nil {
+		logAPIError(log, req, "RuleSet", err, "Failed to list Engines", nil)
+		return nil, 0, err
+	}
+
+	now := time.Now()
+	var active []wafv1alpha1.EmergencyBlock
+	var nextExpiry time.Duration
+	for _, block := range blocks.Items {
+		remaining := emergencyBlockExpiry(&block).Sub(now)
+		if remaining <= 0 || !block.DeletionTimestamp.IsZero() || validateEmergencyBlock(&block) != nil {
+			continue
+		}
+		selected := false
+		for i := range engines.Items {
+			if slices.Contains(engineRuleSets(&engines.Items[i]), ruleset.Name) && emergencyBlockSelects(&block, &engines.Items[i]) {
+				selected = true
+				break
+			}
+		}
+		if !selected {
+			continue
+		}
+		active = append(active, block)
+		if nextExpiry == 0 || remaining < nextExpiry {
+			nextExpiry = remaining
+		}
+	}
+	slices.SortFunc(active, func(a, b wafv1alpha1.EmergencyBlock) int { return strings.Compare(a.Name, b.Name) })
+	return active, nextExpiry, nil
+}
+
+// emergencyBlockRules returns the SecRules of the EmergencyBlocks, or an
+// empty string when there are none. The rules run in phase 1 and must come
+// before every other rule, including the exemptions, so that no request
+// escapes them.
+func emergencyBlockRules(blocks []wafv1alpha1.EmergencyBlock) string {
+	if len(blocks) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("# Emergency blocks generated from EmergencyBlocks\n")
+	for i, block := range blocks {
+		var variable, operator string
+		switch {
+		case len(block.Spec.ClientIPs) > 0:
+			variable, operator = "REMOTE_ADDR", "@ipMatch "+strings.Join(block.Spec.ClientIPs, ",")
+		case block.Spec.Path != "":
+			variable, operator = "REQUEST_FILENAME", "@beginsWith "+block.Spec.Path
+		default:
+			variable, operator = "REQUEST_URI", "@rx "+block.Spec.Pattern
+		}
+		fmt.Fprintf(&b, "SecRule %s \"%s\" \"id:%d,phase:1,deny,status:403,log,t:none,msg:'EmergencyBlock %s'\"\n",
+			variable, operator, emergencyBlockRuleIDBase+i, block.Name)
+	}
+	return b.String()
+}
+
+// findRuleSetsForEmergencyBlock maps an EmergencyBlock to the RuleSets of
+// the Engines it selects.
+func (r *RuleSetReconciler) findRuleSetsForEmergencyBlock(ctx context.Context, obj client.Object) []reconcile.Request {
+	log := logf.FromContext(ctx)
+
+	block, ok := obj.(*wafv1alpha1.EmergencyBlock)
+	if !ok {
+		return nil
+	}
+	var engines wafv1alpha1.EngineList
+	if err := r.List(ctx, &engines, client.InNamespace(block.Namespace)); err != nil {
+		log.Error(err, "RuleSet: Failed to list Engines", "namespace", block.Namespace)
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, name := range emergencyBlockRuleSets(block, engines.Items) {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKey{Namespace: block.Namespace, Name: name}})
+	}
+	return requests
+}
@@ -0,0 +1,119 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/go-logr/logr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// RuleSet Engine Rules
+// -----------------------------------------------------------------------------
+
+// engineRules returns the SecRules generated from the spec of engine, other
+// than its probe rule, which are composed into the RuleSets it uses, or an
+// empty string when it has none.
+func engineRules(engine *wafv1alpha1.Engine) string {
+	if validateInspectionBypass(engine.Spec.InspectionBypass) != nil {
+		return ""
+	}
+	return inspectionBypassRules(engine.Spec.InspectionBypass) + ruleExclusionRules(engine.Spec.RuleExclusions)
+}
+
+// loadEngineRules returns the SecRules generated from the Engines using the
+// RuleSet, or an empty string when they have none. The rules apply to every
+// Engine loading the RuleSet, so the Engines using it must all generate the
+// same rules, but for their probe markers; when they do not, the RuleSet is
+// degraded and done is true. Engines with an invalid configuration are
+// skipped, and reported by the Engine controller.
+func (r *RuleSetReconciler) loadEngineRules(
+	ctx context.Context,
+	log logr.Logger,
+	req ctrl.Request,
+	ruleset *wafv1alpha1.RuleSet,
+) (string, bool, error) {
+	var engines wafv1alpha1.EngineList
+	if err := r.List(ctx, &engines, client.InNamespace(ruleset.Namespace)); err != nil {
+		logAPIError(log, req, "RuleSet", err, "Failed to list Engines", nil)
+		return "", true, err
+	}
+
+	slices.SortFunc(engines.Items, func(a, b wafv1alpha1.Engine) int { return strings.Compare(a.Name, b.Name) })
+
+	var rules []string
+	var groups [][]string
+	var using []*wafv1alpha1.Engine
+	for i := range engines.Items {
+		engine := &engines.Items[i]
+		if !engine.DeletionTimestamp.IsZero() || !slices.Contains(engineRuleSets(engine), ruleset.Name) {
+			continue
+		}
+		using = append(using, engine)
+		generated := engineRules(engine)
+		j := slices.Index(rules, generated)
+		if j < 0 {
+			rules = append(rules, generated)
+			groups = append(groups, nil)
+			j = len(rules) - 1
+		}
+		groups[j] = append(groups[j], engine.Name)
+	}
+	// The probe rule comes first, so that bypassed requests are probed too.
+	switch len(rules) {
+	case 0:
+		return "", false, nil
+	case 1:
+		return probeRules(using) + rules[0], false, nil
+	}
+
+	names := make([]string, 0, len(groups))
+	for _, group := range groups {
+		names = append(names, "["+strings.Join(group, ", ")+"]")
+	}
+	msg := fmt.Sprintf("The Engines using the RuleSet configure different inspection bypasses or rule exclusions, which are composed into its rules "+
+		"and would apply to all of them. Engines with the same configuration: %s", strings.Join(names, ", "))
+	logInfo(log, req, "RuleSet", "Engines using the RuleSet generate conflicting rules", "detail", msg)
+	if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "RuleSet", ruleset, &ruleset.Status.Conditions, ruleset.Generation, "ConflictingEngineRules", msg); patchErr != nil {
+		return "", true, patchErr
+	}
+	return "", true, nil
+}
+
+// findRuleSetsForEngine maps an Engine to its RuleSets, whose rules depend
+// on the rules generated from its spec and on the EmergencyBlocks selecting
+// its labels.
+func (r *RuleSetReconciler) findRuleSetsForEngine(_ context.Context, obj client.Object) []reconcile.Request {
+	engine, ok := obj.(*wafv1alpha1.Engine)
+	if !ok || engine.Spec.RuleSet.Name == "" {
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, 2)
+	for _, name := range engineRuleSets(engine) {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKey{Namespace: engine.Namespace, Name: name}})
+	}
+	return requests
+}
@@ -0,0 +1,115 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/test/utils"
+)
+
+func TestRuleSetReconciler_LoadEngineRules(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+
+	bypass := &wafv1alpha1.InspectionBypass{ContentTypes: []string{"video/*"}}
+	newEngine := func(name, ruleSet string, bypass *wafv1alpha1.InspectionBypass) *wafv1alpha1.Engine {
+		return &wafv1alpha1.Engine{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "team-a"},
+			Spec: wafv1alpha1.EngineSpec{
+				RuleSet:          wafv1alpha1.RuleSetReference{Name: ruleSet},
+				InspectionBypass: bypass,
+			},
+		}
+	}
+	newRuleSet := func(name string) *wafv1alpha1.RuleSet {
+		return &wafv1alpha1.RuleSet{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "team-a"}}
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithStatusSubresource(&wafv1alpha1.RuleSet{}).
+		WithObjects(
+			newRuleSet("shared"), newRuleSet("conflicting"), newRuleSet("unused"),
+			newEngine("a", "shared", bypass),
+			newEngine("b", "shared", bypass.DeepCopy()),
+			newEngine("c", "conflicting", bypass),
+			newEngine("d", "conflicting", nil),
+			newEngine("e", "conflicting", bypass),
+		).
+		Build()
+	r := &RuleSetReconciler{Client: c, Scheme: scheme, Recorder: utils.NewTestRecorder()}
+
+	load := func(name string) (string, bool, *wafv1alpha1.RuleSet) {
+		var ruleset wafv1alpha1.RuleSet
+		require.NoError(t, c.Get(t.Context(), client.ObjectKey{Namespace: "team-a", Name: name}, &ruleset))
+		req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "team-a", Name: name}}
+		rules, done, err := r.loadEngineRules(t.Context(), ctrl.Log, req, &ruleset)
+		require.NoError(t, err)
+		return rules, done, &ruleset
+	}
+
+	rules, done, _ := load("shared")
+	assert.False(t, done)
+	assert.Equal(t, inspectionBypassRules(bypass), rules, "Engines sharing the RuleSet generate the same rules")
+
+	t.Log("Composing the probe rule of every Engine, before the other rules")
+	var engine wafv1alpha1.Engine
+	require.NoError(t, c.Get(t.Context(), client.ObjectKey{Namespace: "team-a", Name: "b"}, &engine))
+	engine.Spec.Probe = &wafv1alpha1.EngineProbe{}
+	engine.Spec.RuleExclusions = []wafv1alpha1.RuleExclusion{{RuleID: 942100}}
+	require.NoError(t, c.Update(t.Context(), &engine))
+	rules, done, _ = load("shared")
+	assert.True(t, done, "the rule exclusions of b differ from those of a")
+	assert.Empty(t, rules)
+	require.NoError(t, c.Get(t.Context(), client.ObjectKey{Namespace: "team-a", Name: "a"}, &engine))
+	engine.Spec.RuleExclusions = []wafv1alpha1.RuleExclusion{{RuleID: 942100}}
+	require.NoError(t, c.Update(t.Context(), &engine))
+	rules, done, _ = load("shared")
+	assert.False(t, done)
+	assert.True(t, strings.HasPrefix(rules, "# Enforcement probe"), rules)
+	assert.Contains(t, rules, "ctl:ruleRemoveById=942100")
+
+	rules, done, _ = load("unused")
+	assert.False(t, done)
+	assert.Empty(t, rules)
+
+	rules, done, ruleset := load("conflicting")
+	assert.True(t, done)
+	assert.Empty(t, rules)
+	degraded := apimeta.FindStatusCondition(ruleset.Status.Conditions, "Degraded")
+	require.NotNil(t, degraded)
+	assert.Equal(t, "ConflictingEngineRules", degraded.Reason)
+	assert.Contains(t, degraded.Message, "[c, e], [d]")
+}
+
+func TestEngineRules_SkipsInvalidInspectionBypass(t *testing.T) {
+	engine := &wafv1alpha1.Engine{Spec: wafv1alpha1.EngineSpec{
+		InspectionBypass: &wafv1alpha1.InspectionBypass{Paths: []wafv1alpha1.InspectionBypassPath{{Regex: "^/(upload"}}},
+	}}
+	assert.Empty(t, engineRules(engine), "the Engine controller reports the invalid bypass")
+}
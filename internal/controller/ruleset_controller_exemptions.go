@@ -0,0 +1,101 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// RuleSet Exemptions - Vars
+// -----------------------------------------------------------------------------
+
+// exemptionRuleIDBase is the first rule ID of the rules generated for
+// RuleSet exemptions. The exemption at index i uses the ID
+// exemptionRuleIDBase+i; RuleSources must not use IDs from this range.
+const exemptionRuleIDBase = 89000000
+
+// clientCertHeader is the header the gateway sets to the identity of a
+// client authenticated with mutual TLS. Istio gateways sanitize it, so it
+// cannot be set by clients.
+const clientCertHeader = "X-Forwarded-Client-Cert"
+
+// -----------------------------------------------------------------------------
+// RuleSet Exemptions
+// -----------------------------------------------------------------------------
+
+// exemptionRules returns the SecRules compiled from the RuleSet exemptions,
+// or an empty string when it has none. The rules run in phase 1 and switch
+// the rule engine for the matching requests with ctl:ruleEngine, so they
+// must come before every other rule.
+func exemptionRules(ruleset *wafv1alpha1.RuleSet) string {
+	if len(ruleset.Spec.Exemptions) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("# Exemptions generated from the RuleSet spec.exemptions\n")
+	for i, exemption := range ruleset.Spec.Exemptions {
+		id := exemptionRuleIDBase + i
+		ctl := "ctl:ruleEngine=" + exemptionRuleEngine(exemption.Action)
+		msg := fmt.Sprintf("msg:'RuleSet exemption %s'", exemption.Name)
+
+		switch {
+		case len(exemption.JWTClaims) > 0:
+			// A bearer token must be present: the gateway only overwrites the
+			// claim headers once it verified the token.
+			fmt.Fprintf(&b, "SecRule REQUEST_HEADERS:Authorization \"@rx (?i)^bearer \\S\" \"id:%d,phase:1,pass,nolog,t:none,%s,chain\"\n", id, msg)
+			for j, claim := range exemption.JWTClaims {
+				actions := "t:none,chain"
+				if j == len(exemption.JWTClaims)-1 {
+					actions = "t:none," + ctl
+				}
+				fmt.Fprintf(&b, "    SecRule REQUEST_HEADERS:%s \"@rx %s\" \"%s\"\n", claim.Header, exactMatchPattern(claim.Values), actions)
+			}
+		case len(exemption.ServiceAccounts) > 0:
+			identities := make([]string, 0, len(exemption.ServiceAccounts))
+			for _, sa := range exemption.ServiceAccounts {
+				identities = append(identities, fmt.Sprintf("ns/%s/sa/%s", regexp.QuoteMeta(sa.Namespace), regexp.QuoteMeta(sa.Name)))
+			}
+			pattern := fmt.Sprintf(`(?:^|[;,])URI=spiffe://[^/;,]+/(?:%s)(?:[;,]|$)`, strings.Join(identities, "|"))
+			fmt.Fprintf(&b, "SecRule REQUEST_HEADERS:%s \"@rx %s\" \"id:%d,phase:1,pass,nolog,t:none,%s,%s\"\n", clientCertHeader, pattern, id, msg, ctl)
+		}
+	}
+	return b.String()
+}
+
+// exemptionRuleEngine returns the SecRuleEngine mode of an exemption action.
+func exemptionRuleEngine(action wafv1alpha1.ExemptionAction) string {
+	if action == wafv1alpha1.ExemptionActionBypass {
+		return "Off"
+	}
+	return "DetectionOnly"
+}
+
+// exactMatchPattern returns a regular expression matching exactly one of
+// values.
+func exactMatchPattern(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, v := range values {
+		quoted = append(quoted, regexp.QuoteMeta(v))
+	}
+	return "^(?:" + strings.Join(quoted, "|") + ")$"
+}
@@ -0,0 +1,153 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/corazawaf/coraza/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+func TestExemptionRules(t *testing.T) {
+	const denyRules = `SecRuleEngine On
+SecRule REQUEST_URI "@contains attack" "id:1,phase:1,deny,status:403"`
+
+	claims := wafv1alpha1.Exemption{
+		Name:   "health-checker",
+		Action: wafv1alpha1.ExemptionActionBypass,
+		JWTClaims: []wafv1alpha1.ClaimMatch{
+			{Header: "x-jwt-sub", Values: []string{"health-checker", "tools.example.com"}},
+			{Header: "x-jwt-iss", Values: []string{"https://issuer.example.com"}},
+		},
+	}
+	serviceAccounts := wafv1alpha1.Exemption{
+		Name: "scanner",
+		ServiceAccounts: []wafv1alpha1.ServiceAccountReference{
+			{Namespace: "tools", Name: "scanner"},
+		},
+	}
+
+	tests := []struct {
+		name       string
+		exemptions []wafv1alpha1.Exemption
+		headers    map[string]string
+		blocked    bool
+	}{
+		{
+			name:    "no exemptions",
+			blocked: true,
+		},
+		{
+			name:       "matching claims bypass",
+			exemptions: []wafv1alpha1.Exemption{claims},
+			headers: map[string]string{
+				"Authorization": "Bearer token",
+				"x-jwt-sub":     "tools.example.com",
+				"x-jwt-iss":     "https://issuer.example.com",
+			},
+		},
+		{
+			name:       "claims without bearer token",
+			exemptions: []wafv1alpha1.Exemption{claims},
+			headers: map[string]string{
+				"x-jwt-sub": "health-checker",
+				"x-jwt-iss": "https://issuer.example.com",
+			},
+			blocked: true,
+		},
+		{
+			name:       "one claim not matching",
+			exemptions: []wafv1alpha1.Exemption{claims},
+			headers: map[string]string{
+				"Authorization": "Bearer token",
+				"x-jwt-sub":     "health-checker",
+				"x-jwt-iss":     "https://evil.example.com",
+			},
+			blocked: true,
+		},
+		{
+			name:       "claim value is matched exactly",
+			exemptions: []wafv1alpha1.Exemption{claims},
+			headers: map[string]string{
+				"Authorization": "Bearer token",
+				"x-jwt-sub":     "toolsXexample.com",
+				"x-jwt-iss":     "https://issuer.example.com",
+			},
+			blocked: true,
+		},
+		{
+			name:       "allow-listed ServiceAccount detection only",
+			exemptions: []wafv1alpha1.Exemption{serviceAccounts},
+			headers: map[string]string{
+				"X-Forwarded-Client-Cert": "By=spiffe://cluster.local/ns/gw/sa/gateway;Hash=abc;URI=spiffe://cluster.local/ns/tools/sa/scanner",
+			},
+		},
+		{
+			name:       "other ServiceAccount",
+			exemptions: []wafv1alpha1.Exemption{serviceAccounts},
+			headers: map[string]string{
+				"X-Forwarded-Client-Cert": "URI=spiffe://cluster.local/ns/tools/sa/scanner-2",
+			},
+			blocked: true,
+		},
+		{
+			name:       "several exemptions",
+			exemptions: []wafv1alpha1.Exemption{claims, serviceAccounts},
+			headers: map[string]string{
+				"X-Forwarded-Client-Cert": "URI=spiffe://cluster.local/ns/tools/sa/scanner",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ruleset := &wafv1alpha1.RuleSet{Spec: wafv1alpha1.RuleSetSpec{Exemptions: tt.exemptions}}
+			waf, err := coraza.NewWAF(coraza.NewWAFConfig().WithDirectives(exemptionRules(ruleset) + denyRules))
+			require.NoError(t, err)
+
+			tx := waf.NewTransaction()
+			defer func() { _ = tx.Close() }()
+			tx.ProcessURI("/attack", "GET", "HTTP/1.1")
+			for k, v := range tt.headers {
+				tx.AddRequestHeader(k, v)
+			}
+			interruption := tx.ProcessRequestHeaders()
+			if tt.blocked {
+				assert.NotNil(t, interruption, "request should be blocked")
+			} else {
+				assert.Nil(t, interruption, "request should be exempted")
+			}
+		})
+	}
+}
+
+func TestExemptionRules_IDs(t *testing.T) {
+	ruleset := &wafv1alpha1.RuleSet{Spec: wafv1alpha1.RuleSetSpec{Exemptions: []wafv1alpha1.Exemption{
+		{Name: "a", ServiceAccounts: []wafv1alpha1.ServiceAccountReference{{Namespace: "ns", Name: "a"}}},
+		{Name: "b", ServiceAccounts: []wafv1alpha1.ServiceAccountReference{{Namespace: "ns", Name: "b"}}},
+	}}}
+	rules := exemptionRules(ruleset)
+	assert.Contains(t, rules, "id:89000000,")
+	assert.Contains(t, rules, "id:89000001,")
+	assert.Contains(t, rules, "ctl:ruleEngine=DetectionOnly", "DetectionOnly is the default action")
+
+	assert.Empty(t, exemptionRules(&wafv1alpha1.RuleSet{}))
+}
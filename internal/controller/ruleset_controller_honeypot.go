@@ -0,0 +1,379 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	rcache "github.com/networking-incubator/coraza-kubernetes-operator/internal/rulesets/cache"
+)
+
+// +kubebuilder:rbac:groups=waf.k8s.coraza.io,resources=ruledata,verbs=create;update
+
+// -----------------------------------------------------------------------------
+// RuleSet Honeypot - Vars
+// -----------------------------------------------------------------------------
+
+const (
+	// honeypotRuleIDBase is the rule ID of the rule blocking the clients
+	// caught by the honeypot of a RuleSet. The decoy path at index i uses
+	// the ID honeypotRuleIDBase+1+i; RuleSources must not use IDs from this
+	// range.
+	honeypotRuleIDBase = 89200000
+
+	// honeypotTag tags the honeypot rules. The WASM plugin reports the
+	// client addresses of the requests matching a decoy path rule.
+	honeypotTag = "honeypot"
+
+	// defaultHoneypotBlockSeconds is the blockSeconds of a honeypot when
+	// it is omitted. Blocking is off until a qualified WASM plugin release
+	// reports honeypot hits.
+	defaultHoneypotBlockSeconds = 0
+
+	// honeypotReportIntervalSeconds is how often an Engine whose RuleSet
+	// blocks honeypot clients reports the hits to the cache server.
+	honeypotReportIntervalSeconds = 30
+
+	// honeypotMaxOffenders is the maximum number of blocked client
+	// addresses of a RuleSet; the addresses blocked the longest ago are
+	// dropped first.
+	honeypotMaxOffenders = 4096
+
+	// honeypotOffendersKey is the RuleData file listing the blocked client
+	// addresses and when their block expires.
+	honeypotOffendersKey = "offenders"
+
+	// honeypotMaxEventAddresses is the maximum number of client addresses
+	// listed in a HoneypotHit event.
+	honeypotMaxEventAddresses = 10
+)
+
+// defaultHoneypotPaths are the decoy paths of a honeypot without paths:
+// paths probed by scanners that applications behind a WAF rarely serve.
+var defaultHoneypotPaths = []string{
+	"/wp-login.php",
+	"/xmlrpc.php",
+	"/.env",
+	"/.git/config",
+	"/phpmyadmin/",
+	"/server-status",
+}
+
+// -----------------------------------------------------------------------------
+// RuleSet Honeypot - Rules
+// -----------------------------------------------------------------------------
+
+// honeypotBlockDuration returns how long the clients caught by the honeypot
+// of ruleset are blocked, or zero when it does not block them.
+func honeypotBlockDuration(ruleset *wafv1alpha1.RuleSet) time.Duration {
+	hp := ruleset.Spec.Honeypot
+	if hp == nil {
+		return 0
+	}
+	seconds := int32(defaultHoneypotBlockSeconds)
+	if hp.BlockSeconds != nil {
+		seconds = *hp.BlockSeconds
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// honeypotDataName returns the name of the RuleData, and of the data file,
+// listing the client addresses blocked by the honeypot of the RuleSet name.
+func honeypotDataName(name string) string {
+	const suffix = "-honeypot"
+	if len(name)+len(suffix) > 253 {
+		name = strings.TrimRight(name[:253-len(suffix)], "-.")
+	}
+	return name + suffix
+}
+
+// honeypotRules returns the SecRules of the honeypot of the RuleSet, or an
+// empty string when it has none. The rules run in phase 1, after the
+// threat feed rules.
+func honeypotRules(ruleset *wafv1alpha1.RuleSet) string {
+	hp := ruleset.Spec.Honeypot
+	if hp == nil {
+		return ""
+	}
+	paths := hp.Paths
+	if len(paths) == 0 {
+		paths = defaultHoneypotPaths
+	}
+
+	action := "deny,status:404"
+	if hp.Action == wafv1alpha1.HoneypotActionLog {
+		action = "pass"
+	}
+
+	var b strings.Builder
+	b.WriteString("# Honeypot generated from the RuleSet spec.honeypot\n")
+	for i, path := range paths {
+		operator := "@streq"
+		if strings.HasSuffix(path, "/") {
+			operator = "@beginsWith"
+		}
+		fmt.Fprintf(&b, "SecRule REQUEST_FILENAME \"%s %s\" \"id:%d,phase:1,%s,log,t:none,tag:'%s',msg:'Honeypot path requested',logdata:'%%{REMOTE_ADDR}'\"\n",
+			operator, path, honeypotRuleIDBase+1+i, action, honeypotTag)
+	}
+	if honeypotBlockDuration(ruleset) > 0 {
+		fmt.Fprintf(&b, "SecRule REMOTE_ADDR \"@ipMatchFromFile %s\" \"id:%d,phase:1,deny,status:403,log,t:none,tag:'%s',msg:'Client address caught by the honeypot'\"\n",
+			honeypotDataName(ruleset.Name), honeypotRuleIDBase, honeypotTag)
+	}
+	return b.String()
+}
+
+// -----------------------------------------------------------------------------
+// RuleSet Honeypot - Blocked Addresses
+// -----------------------------------------------------------------------------
+
+// loadHoneypotData adds the data file listing the client addresses blocked
+// by the honeypot of the RuleSet to dataFiles. It also returns when the
+// next block expires, for rebuilding the rules then, or zero when no
+// address is blocked.
+func (r *RuleSetReconciler) loadHoneypotData(
+	ctx context.Context,
+	log logr.Logger,
+	req ctrl.Request,
+	ruleset *wafv1alpha1.RuleSet,
+	dataFiles map[string][]byte,
+) (map[string][]byte, time.Duration, bool, error) {
+	if honeypotBlockDuration(ruleset) == 0 {
+		return dataFiles, 0, false, nil
+	}
+
+	name := honeypotDataName(ruleset.Name)
+	var rd wafv1alpha1.RuleData
+	if err := r.Get(ctx, types.NamespacedName{Namespace: ruleset.Namespace, Name: name}, &rd); err != nil && !apierrors.IsNotFound(err) {
+		logError(log, req, "RuleSet", err, "Failed to get honeypot RuleData")
+		msg := fmt.Sprintf("Failed to access the honeypot RuleData %s: %v", name, err)
+		if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "RuleSet", ruleset, &ruleset.Status.Conditions, ruleset.Generation, "RuleDataAccessError", msg); patchErr != nil {
+			return nil, 0, true, patchErr
+		}
+		return nil, 0, true, err
+	}
+
+	now := time.Now()
+	offenders := parseHoneypotOffenders(rd.Spec.Files[honeypotOffendersKey])
+	pruneHoneypotOffenders(offenders, now)
+	logInfo(log, req, "RuleSet", "Loading honeypot blocked addresses", "blockedCount", len(offenders))
+
+	var nextExpiry time.Duration
+	addrs := make([]string, 0, len(offenders))
+	for addr, until := range offenders {
+		addrs = append(addrs, addr.String())
+		if d := until.Sub(now); nextExpiry == 0 || d < nextExpiry {
+			nextExpiry = d
+		}
+	}
+	slices.Sort(addrs)
+
+	if dataFiles == nil {
+		dataFiles = make(map[string][]byte)
+	}
+	dataFiles[name] = []byte(strings.Join(addrs, "\n"))
+	return dataFiles, nextExpiry, false, nil
+}
+
+// parseHoneypotOffenders parses the blocked client addresses of a honeypot
+// RuleData file, one "<address> <expiry>" line per address. Invalid lines
+// are ignored.
+func parseHoneypotOffenders(data string) map[netip.Addr]time.Time {
+	offenders := make(map[netip.Addr]time.Time)
+	sc := bufio.NewScanner(strings.NewReader(data))
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		addr, err := netip.ParseAddr(fields[0])
+		if err != nil {
+			continue
+		}
+		until, err := time.Parse(time.RFC3339, fields[1])
+		if err != nil {
+			continue
+		}
+		offenders[addr] = until
+	}
+	return offenders
+}
+
+// formatHoneypotOffenders formats the blocked client addresses of a
+// honeypot RuleData file, sorted by address.
+func formatHoneypotOffenders(offenders map[netip.Addr]time.Time) string {
+	var b strings.Builder
+	for _, addr := range slices.SortedFunc(maps.Keys(offenders), netip.Addr.Compare) {
+		fmt.Fprintf(&b, "%s %s\n", addr, offenders[addr].UTC().Format(time.RFC3339))
+	}
+	return b.String()
+}
+
+// pruneHoneypotOffenders removes the blocks expired at now and, above
+// honeypotMaxOffenders, the blocks expiring first.
+func pruneHoneypotOffenders(offenders map[netip.Addr]time.Time, now time.Time) {
+	for addr, until := range offenders {
+		if !until.After(now) {
+			delete(offenders, addr)
+		}
+	}
+	if len(offenders) <= honeypotMaxOffenders {
+		return
+	}
+
+	type offender struct {
+		addr  netip.Addr
+		until time.Time
+	}
+	sorted := make([]offender, 0, len(offenders))
+	for addr, until := range offenders {
+		sorted = append(sorted, offender{addr, until})
+	}
+	slices.SortFunc(sorted, func(a, b offender) int {
+		return cmp.Or(a.until.Compare(b.until), a.addr.Compare(b.addr))
+	})
+	for _, o := range sorted[:len(sorted)-honeypotMaxOffenders] {
+		delete(offenders, o.addr)
+	}
+}
+
+// -----------------------------------------------------------------------------
+// RuleSet Honeypot - Reporter
+// -----------------------------------------------------------------------------
+
+// HoneypotReporter records the honeypot hits reported by Engines to the
+// cache server: the client addresses are added to the RuleData of the
+// honeypot of their RuleSet, which then blocks them, and a HoneypotHit
+// event is recorded on the RuleSet. It implements rcache.HoneypotReporter.
+type HoneypotReporter struct {
+	client    client.Client
+	apiReader client.Reader
+	recorder  events.EventRecorder
+}
+
+// NewHoneypotReporter returns a HoneypotReporter. Engines, RuleSets and
+// RuleData are read with apiReader, so that the reports of every replica
+// are merged into the latest RuleData.
+func NewHoneypotReporter(c client.Client, apiReader client.Reader, recorder events.EventRecorder) *HoneypotReporter {
+	return &HoneypotReporter{client: c, apiReader: apiReader, recorder: recorder}
+}
+
+// ReportHoneypotHits blocks the client addresses of hits for the
+// blockSeconds of the honeypot of the RuleSet of cacheKey. The report is
+// rejected unless caller is the cache client of an Engine using that
+// RuleSet, and the RuleSet blocks honeypot clients.
+func (h *HoneypotReporter) ReportHoneypotHits(ctx context.Context, caller rcache.AuthResult, cacheKey string, hits map[netip.Addr]int64) error {
+	if _, err := cacheClientEngine(ctx, h.apiReader, caller, cacheKey, rcache.ErrHoneypotReportRejected); err != nil {
+		return err
+	}
+
+	namespace, name, _ := strings.Cut(cacheKey, "/")
+	var ruleset wafv1alpha1.RuleSet
+	if err := h.apiReader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, &ruleset); err != nil {
+		if apierrors.IsNotFound(err) {
+			return fmt.Errorf("%w: RuleSet %s not found", rcache.ErrHoneypotReportRejected, cacheKey)
+		}
+		return err
+	}
+	block := honeypotBlockDuration(&ruleset)
+	if block == 0 {
+		return fmt.Errorf("%w: RuleSet %s does not block honeypot clients", rcache.ErrHoneypotReportRejected, cacheKey)
+	}
+	if len(hits) == 0 {
+		return nil
+	}
+
+	until := time.Now().Add(block)
+	retriable := func(err error) bool { return apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err) }
+	if err := retry.OnError(retry.DefaultRetry, retriable, func() error {
+		return h.blockOffenders(ctx, &ruleset, hits, until)
+	}); err != nil {
+		return err
+	}
+
+	addrs := make([]string, 0, len(hits))
+	var total int64
+	for addr, n := range hits {
+		addrs = append(addrs, addr.String())
+		total += n
+	}
+	slices.Sort(addrs)
+	if len(addrs) > honeypotMaxEventAddresses {
+		addrs = append(addrs[:honeypotMaxEventAddresses], fmt.Sprintf("and %d more", len(hits)-honeypotMaxEventAddresses))
+	}
+	h.recorder.Eventf(&ruleset, nil, "Warning", "HoneypotHit", "Block", "%d requests for decoy paths from %s; blocked until %s",
+		total, strings.Join(addrs, ", "), until.UTC().Format(time.RFC3339))
+	return nil
+}
+
+// blockOffenders adds the client addresses of hits, blocked until until, to
+// the honeypot RuleData of ruleset, creating it if needed.
+func (h *HoneypotReporter) blockOffenders(ctx context.Context, ruleset *wafv1alpha1.RuleSet, hits map[netip.Addr]int64, until time.Time) error {
+	name := honeypotDataName(ruleset.Name)
+	var rd wafv1alpha1.RuleData
+	err := h.apiReader.Get(ctx, types.NamespacedName{Namespace: ruleset.Namespace, Name: name}, &rd)
+	switch {
+	case apierrors.IsNotFound(err):
+		rd = wafv1alpha1.RuleData{ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ruleset.Namespace,
+			Labels: map[string]string{
+				ManagedByLabel:                     ManagedByValue,
+				"app.kubernetes.io/component":      "honeypot",
+				"app.kubernetes.io/instance":       ruleset.Name,
+				wafv1alpha1.LabelExcludeFromBackup: "true",
+			},
+		}}
+		if err := controllerutil.SetControllerReference(ruleset, &rd, h.client.Scheme()); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	case !metav1.IsControlledBy(&rd, ruleset):
+		return fmt.Errorf("%w: RuleData %s/%s is not owned by RuleSet %s", rcache.ErrHoneypotReportRejected, rd.Namespace, rd.Name, ruleset.Name)
+	}
+
+	offenders := parseHoneypotOffenders(rd.Spec.Files[honeypotOffendersKey])
+	for addr := range hits {
+		offenders[addr] = until
+	}
+	pruneHoneypotOffenders(offenders, time.Now())
+	rd.Spec.Files = map[string]string{honeypotOffendersKey: formatHoneypotOffenders(offenders)}
+
+	if rd.ResourceVersion == "" {
+		return h.client.Create(ctx, &rd)
+	}
+	return h.client.Update(ctx, &rd)
+}
@@ -0,0 +1,215 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/corazawaf/coraza/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	rcache "github.com/networking-incubator/coraza-kubernetes-operator/internal/rulesets/cache"
+	"github.com/networking-incubator/coraza-kubernetes-operator/test/utils"
+)
+
+func TestHoneypotRules(t *testing.T) {
+	assert.Empty(t, honeypotRules(&wafv1alpha1.RuleSet{}))
+
+	ruleset := &wafv1alpha1.RuleSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "rules"},
+		Spec: wafv1alpha1.RuleSetSpec{Honeypot: &wafv1alpha1.Honeypot{
+			Paths:        []string{"/wp-login.php", "/admin-old/"},
+			Action:       wafv1alpha1.HoneypotActionDeny,
+			BlockSeconds: new(int32(3600)),
+		}},
+	}
+	rules := honeypotRules(ruleset)
+	assert.Contains(t, rules, "id:89200000,")
+	assert.Contains(t, rules, "id:89200002,phase:1,deny,status:404,")
+
+	conf := coraza.NewWAFConfig().
+		WithDirectives("SecRuleEngine On\n" + rules).
+		WithRootFS(getDataFilesystem(map[string][]byte{
+			"rules-honeypot": []byte("198.51.100.7\n2001:db8::1"),
+		}))
+	waf, err := coraza.NewWAF(conf)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		addr       string
+		uri        string
+		wantStatus int
+	}{
+		{name: "decoy path", addr: "192.0.2.1", uri: "/wp-login.php?redirect=1", wantStatus: 404},
+		{name: "below decoy prefix", addr: "192.0.2.1", uri: "/admin-old/index.php", wantStatus: 404},
+		{name: "other path", addr: "192.0.2.1", uri: "/wp-login.php.bak"},
+		{name: "blocked client", addr: "198.51.100.7", uri: "/", wantStatus: 403},
+		{name: "blocked IPv6 client", addr: "2001:db8::1", uri: "/", wantStatus: 403},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := waf.NewTransaction()
+			defer func() { _ = tx.Close() }()
+			tx.ProcessConnection(tt.addr, 12345, "10.0.0.1", 8080)
+			tx.ProcessURI(tt.uri, "GET", "HTTP/1.1")
+			interruption := tx.ProcessRequestHeaders()
+			if tt.wantStatus == 0 {
+				assert.Nil(t, interruption)
+				return
+			}
+			require.NotNil(t, interruption)
+			assert.Equal(t, tt.wantStatus, interruption.Status)
+		})
+	}
+
+	t.Run("defaults, log only and no blocking", func(t *testing.T) {
+		ruleset.Spec.Honeypot = &wafv1alpha1.Honeypot{Action: wafv1alpha1.HoneypotActionLog}
+		rules := honeypotRules(ruleset)
+		assert.Contains(t, rules, `"@streq /.env" "id:89200003,phase:1,pass,`)
+		assert.NotContains(t, rules, "@ipMatchFromFile")
+	})
+}
+
+func TestHoneypotDataName(t *testing.T) {
+	assert.Equal(t, "rules-honeypot", honeypotDataName("rules"))
+	assert.Len(t, honeypotDataName(string(make([]byte, 253))), 253)
+}
+
+func TestHoneypotOffenders(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	offenders := parseHoneypotOffenders("192.0.2.1 2026-01-01T13:00:00Z\n" +
+		"192.0.2.2 2026-01-01T11:00:00Z\n" +
+		"invalid 2026-01-01T13:00:00Z\n" +
+		"2001:db8::1 2026-01-01T12:30:00Z\n")
+	require.Len(t, offenders, 3)
+
+	pruneHoneypotOffenders(offenders, now)
+	assert.Equal(t, "192.0.2.1 2026-01-01T13:00:00Z\n2001:db8::1 2026-01-01T12:30:00Z\n", formatHoneypotOffenders(offenders))
+
+	t.Run("blocks expiring first are dropped above the limit", func(t *testing.T) {
+		offenders := make(map[netip.Addr]time.Time, honeypotMaxOffenders+1)
+		first := netip.MustParseAddr("10.0.0.0")
+		addr := first
+		for i := range honeypotMaxOffenders + 1 {
+			offenders[addr] = now.Add(time.Duration(i+1) * time.Second)
+			addr = addr.Next()
+		}
+		pruneHoneypotOffenders(offenders, now)
+		assert.Len(t, offenders, honeypotMaxOffenders)
+		assert.NotContains(t, offenders, first)
+	})
+}
+
+func TestHoneypotReporter(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	engine := utils.NewTestEngine(utils.EngineOptions{Name: "waf", Namespace: "team-a", GatewayName: "gateway"})
+	engine.UID = "engine-uid"
+	ruleset := &wafv1alpha1.RuleSet{
+		ObjectMeta: metav1.ObjectMeta{Name: engine.Spec.RuleSet.Name, Namespace: "team-a", UID: "ruleset-uid"},
+		Spec: wafv1alpha1.RuleSetSpec{
+			Sources:  []wafv1alpha1.SourceReference{{Name: "base"}},
+			Honeypot: &wafv1alpha1.Honeypot{BlockSeconds: new(int32(3600))},
+		},
+	}
+	sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{
+		Name:      "coraza-engine-abc",
+		Namespace: "team-a",
+		OwnerReferences: []metav1.OwnerReference{{
+			APIVersion: wafv1alpha1.GroupVersion.String(),
+			Kind:       "Engine",
+			Name:       engine.Name,
+			UID:        engine.UID,
+			Controller: new(true),
+		}},
+	}}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(engine, ruleset, sa).Build()
+	reporter := NewHoneypotReporter(c, c, utils.NewTestRecorder())
+	caller := rcache.AuthResult{Namespace: "team-a", Name: sa.Name}
+	cacheKey := "team-a/" + ruleset.Name
+
+	first, second := netip.MustParseAddr("192.0.2.1"), netip.MustParseAddr("2001:db8::1")
+	require.NoError(t, reporter.ReportHoneypotHits(t.Context(), caller, cacheKey, map[netip.Addr]int64{first: 2}))
+	require.NoError(t, reporter.ReportHoneypotHits(t.Context(), caller, cacheKey, map[netip.Addr]int64{second: 1}))
+
+	var rd wafv1alpha1.RuleData
+	require.NoError(t, c.Get(t.Context(), types.NamespacedName{Namespace: "team-a", Name: honeypotDataName(ruleset.Name)}, &rd))
+	assert.True(t, metav1.IsControlledBy(&rd, ruleset))
+	assert.Equal(t, "true", rd.Labels[wafv1alpha1.LabelExcludeFromBackup])
+	offenders := parseHoneypotOffenders(rd.Spec.Files[honeypotOffendersKey])
+	require.Len(t, offenders, 2)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), offenders[first], time.Minute)
+
+	t.Log("Loading the blocked addresses into the rules data")
+	r := &RuleSetReconciler{Client: c, Scheme: scheme, Recorder: utils.NewTestRecorder()}
+	dataFiles, nextExpiry, done, err := r.loadHoneypotData(t.Context(), utils.NewTestLogger(t), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(ruleset)}, ruleset, nil)
+	require.NoError(t, err)
+	assert.False(t, done)
+	assert.Equal(t, "192.0.2.1\n2001:db8::1", string(dataFiles[honeypotDataName(ruleset.Name)]))
+	assert.InDelta(t, time.Hour, nextExpiry, float64(time.Minute))
+
+	tests := []struct {
+		name     string
+		caller   rcache.AuthResult
+		cacheKey string
+	}{
+		{name: "other RuleSet", caller: caller, cacheKey: "team-a/other"},
+		{name: "unknown ServiceAccount", caller: rcache.AuthResult{Namespace: "team-a", Name: "default"}, cacheKey: cacheKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := reporter.ReportHoneypotHits(t.Context(), tt.caller, tt.cacheKey, map[netip.Addr]int64{first: 1})
+			assert.ErrorIs(t, err, rcache.ErrHoneypotReportRejected)
+		})
+	}
+
+	t.Run("RuleSet without blocking", func(t *testing.T) {
+		ruleset.Spec.Honeypot.BlockSeconds = new(int32(0))
+		require.NoError(t, c.Update(t.Context(), ruleset))
+		err := reporter.ReportHoneypotHits(t.Context(), caller, cacheKey, map[netip.Addr]int64{first: 1})
+		assert.ErrorIs(t, err, rcache.ErrHoneypotReportRejected)
+	})
+}
+
+func TestHoneypotPluginConfig(t *testing.T) {
+	engine := utils.NewTestEngine(utils.EngineOptions{Name: "waf", Namespace: "team-a", GatewayName: "gateway"})
+	engine.Spec.RuleSetCacheServer = &wafv1alpha1.RuleSetCacheServerConfig{PollIntervalSeconds: 15}
+	ruleset := &wafv1alpha1.RuleSet{Spec: wafv1alpha1.RuleSetSpec{Honeypot: &wafv1alpha1.Honeypot{}}}
+	r := &EngineReconciler{}
+	assert.NotContains(t, r.wasmPluginConfig(engine, ruleset, ""), "honeypot_report_interval_seconds", "blocking is off by default")
+
+	ruleset.Spec.Honeypot.BlockSeconds = new(int32(3600))
+	assert.Equal(t, honeypotReportIntervalSeconds, r.wasmPluginConfig(engine, ruleset, "")["honeypot_report_interval_seconds"])
+	assert.NotContains(t, r.wasmPluginConfig(engine, nil, ""), "honeypot_report_interval_seconds")
+
+	ruleset.Spec.Honeypot.BlockSeconds = new(int32(0))
+	assert.NotContains(t, r.wasmPluginConfig(engine, ruleset, ""), "honeypot_report_interval_seconds")
+}
@@ -0,0 +1,203 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// RuleSet Hooks - Vars
+// -----------------------------------------------------------------------------
+
+// DefaultRuleSetHookTimeout is the default timeout of the HTTP RuleSet hook.
+const DefaultRuleSetHookTimeout = 5 * time.Second
+
+// ruleSetHookMaxResponseSize bounds the response of the HTTP RuleSet hook,
+// which may return the rules of the RuleSet.
+const ruleSetHookMaxResponseSize = 64 << 20
+
+var (
+	ruleSetHooksMu sync.RWMutex
+	ruleSetHooks   = map[string]RuleSetHook{}
+)
+
+// -----------------------------------------------------------------------------
+// RuleSet Hooks
+// -----------------------------------------------------------------------------
+
+// RuleSetHookRequest is the review of the rules composed for a RuleSet, from
+// its sources and spec, before they are validated and cached.
+type RuleSetHookRequest struct {
+	Namespace  string `json:"namespace"`
+	Name       string `json:"name"`
+	Generation int64  `json:"generation"`
+	Rules      string `json:"rules"`
+}
+
+// RuleSetHookResponse is the outcome of the review of a RuleSetHookRequest.
+type RuleSetHookResponse struct {
+	// Allowed lets the rules be cached. Denied rules degrade the RuleSet,
+	// and its previous rules keep being served.
+	Allowed bool `json:"allowed"`
+
+	// Message explains a denial, in the Degraded condition of the RuleSet.
+	Message string `json:"message,omitempty"`
+
+	// Rules replace the reviewed rules when set. They are validated like
+	// the composed rules.
+	Rules *string `json:"rules,omitempty"`
+}
+
+// RuleSetHook reviews the rules composed for RuleSets, to veto or mutate
+// them, such as a policy engine checking the rule content against the
+// policies of an organization. Hooks are called concurrently.
+type RuleSetHook interface {
+	ReviewRules(ctx context.Context, req RuleSetHookRequest) (RuleSetHookResponse, error)
+}
+
+// RegisterRuleSetHook makes hook review the rules of every RuleSet. Hooks
+// run in the order of their names, each reviewing the rules returned by the
+// previous one. It panics when name is empty or already registered, as
+// registration happens before the controllers are set up.
+func RegisterRuleSetHook(name string, hook RuleSetHook) {
+	ruleSetHooksMu.Lock()
+	defer ruleSetHooksMu.Unlock()
+	if name == "" || hook == nil {
+		panic("controller: RegisterRuleSetHook with an empty name or nil hook")
+	}
+	if _, dup := ruleSetHooks[name]; dup {
+		panic(fmt.Sprintf("controller: RegisterRuleSetHook called twice for %s", name))
+	}
+	ruleSetHooks[name] = hook
+}
+
+// namedRuleSetHook is a registered RuleSetHook.
+type namedRuleSetHook struct {
+	name string
+	hook RuleSetHook
+}
+
+// registeredRuleSetHooks returns the registered hooks, ordered by name.
+func registeredRuleSetHooks() []namedRuleSetHook {
+	ruleSetHooksMu.RLock()
+	defer ruleSetHooksMu.RUnlock()
+	hooks := make([]namedRuleSetHook, 0, len(ruleSetHooks))
+	for _, name := range slices.Sorted(maps.Keys(ruleSetHooks)) {
+		hooks = append(hooks, namedRuleSetHook{name: name, hook: ruleSetHooks[name]})
+	}
+	return hooks
+}
+
+// reviewRules runs the hooks of the reconciler on the rules composed for
+// the RuleSet, and returns the rules to validate. A hook that fails or
+// denies the rules degrades the RuleSet.
+func (r *RuleSetReconciler) reviewRules(
+	ctx context.Context,
+	log logr.Logger,
+	req ctrl.Request,
+	ruleset *wafv1alpha1.RuleSet,
+	rules string,
+) (string, bool, error) {
+	for _, h := range r.hooks {
+		resp, err := h.hook.ReviewRules(ctx, RuleSetHookRequest{
+			Namespace:  ruleset.Namespace,
+			Name:       ruleset.Name,
+			Generation: ruleset.Generation,
+			Rules:      rules,
+		})
+		if err != nil {
+			logError(log, req, "RuleSet", err, "RuleSet hook failed", "hook", h.name)
+			msg := fmt.Sprintf("RuleSet hook %s failed: %v", h.name, err)
+			if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "RuleSet", ruleset, &ruleset.Status.Conditions, ruleset.Generation, "RuleSetHookFailed", msg); patchErr != nil {
+				return "", true, patchErr
+			}
+			return "", true, err
+		}
+		if !resp.Allowed {
+			logInfo(log, req, "RuleSet", "RuleSet hook denied the rules", "hook", h.name, "detail", resp.Message)
+			msg := fmt.Sprintf("RuleSet hook %s denied the rules: %s", h.name, resp.Message)
+			if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "RuleSet", ruleset, &ruleset.Status.Conditions, ruleset.Generation, "RuleSetHookDenied", msg); patchErr != nil {
+				return "", true, patchErr
+			}
+			return "", true, nil
+		}
+		if resp.Rules != nil && *resp.Rules != rules {
+			logDebug(log, req, "RuleSet", "RuleSet hook mutated the rules", "hook", h.name)
+			rules = *resp.Rules
+		}
+	}
+	return rules, false, nil
+}
+
+// -----------------------------------------------------------------------------
+// RuleSet Hooks - HTTP
+// -----------------------------------------------------------------------------
+
+// httpRuleSetHook is a RuleSetHook calling out to an HTTP endpoint.
+type httpRuleSetHook struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPRuleSetHook returns a RuleSetHook that POSTs each RuleSetHookRequest
+// as JSON to url, and expects a RuleSetHookResponse as JSON in a 200
+// response, such as a policy engine behind a small adapter.
+func NewHTTPRuleSetHook(url string, timeout time.Duration) RuleSetHook {
+	return &httpRuleSetHook{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+func (h *httpRuleSetHook) ReviewRules(ctx context.Context, req RuleSetHookRequest) (RuleSetHookResponse, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return RuleSetHookResponse{}, err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return RuleSetHookResponse{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	httpResp, err := h.client.Do(httpReq)
+	if err != nil {
+		return RuleSetHookResponse{}, err
+	}
+	defer func() { _ = httpResp.Body.Close() }()
+	if httpResp.StatusCode != http.StatusOK {
+		return RuleSetHookResponse{}, fmt.Errorf("unexpected status %s", httpResp.Status)
+	}
+
+	var resp RuleSetHookResponse
+	if err := json.NewDecoder(io.LimitReader(httpResp.Body, ruleSetHookMaxResponseSize)).Decode(&resp); err != nil {
+		return RuleSetHookResponse{}, fmt.Errorf("invalid response: %w", err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,139 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/test/utils"
+)
+
+// ruleSetHookFunc adapts a function to a RuleSetHook.
+type ruleSetHookFunc func(req RuleSetHookRequest) (RuleSetHookResponse, error)
+
+func (f ruleSetHookFunc) ReviewRules(_ context.Context, req RuleSetHookRequest) (RuleSetHookResponse, error) {
+	return f(req)
+}
+
+func TestRuleSetReconciler_ReviewRules(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+
+	allow := ruleSetHookFunc(func(RuleSetHookRequest) (RuleSetHookResponse, error) {
+		return RuleSetHookResponse{Allowed: true}, nil
+	})
+	appendRule := ruleSetHookFunc(func(req RuleSetHookRequest) (RuleSetHookResponse, error) {
+		return RuleSetHookResponse{Allowed: true, Rules: ptr.To(req.Rules + "\nSecRule ARGS \"@contains evil\" \"id:2,deny\"")}, nil
+	})
+	denyEvil := ruleSetHookFunc(func(req RuleSetHookRequest) (RuleSetHookResponse, error) {
+		if strings.Contains(req.Rules, "evil") {
+			return RuleSetHookResponse{Message: "rules must not mention evil"}, nil
+		}
+		return RuleSetHookResponse{Allowed: true}, nil
+	})
+	fail := ruleSetHookFunc(func(RuleSetHookRequest) (RuleSetHookResponse, error) {
+		return RuleSetHookResponse{}, errors.New("connection refused")
+	})
+
+	const rules = `SecRule ARGS "@contains attack" "id:1,deny"`
+	tests := []struct {
+		name         string
+		hooks        []namedRuleSetHook
+		want         string
+		wantDegraded string
+		wantErr      bool
+	}{
+		{name: "allowed", hooks: []namedRuleSetHook{{"allow", allow}}, want: rules},
+		{name: "mutated", hooks: []namedRuleSetHook{{"append", appendRule}, {"allow", allow}}, want: rules + "\nSecRule ARGS \"@contains evil\" \"id:2,deny\""},
+		{name: "denied after mutation", hooks: []namedRuleSetHook{{"append", appendRule}, {"deny", denyEvil}}, wantDegraded: "RuleSetHookDenied"},
+		{name: "failed", hooks: []namedRuleSetHook{{"fail", fail}}, wantDegraded: "RuleSetHookFailed", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ruleset := &wafv1alpha1.RuleSet{ObjectMeta: metav1.ObjectMeta{Name: "ruleset", Namespace: "team-a", Generation: 1}}
+			c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(ruleset.DeepCopy()).WithStatusSubresource(ruleset).Build()
+			r := &RuleSetReconciler{Client: c, Scheme: scheme, Recorder: utils.NewTestRecorder(), hooks: tt.hooks}
+			req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(ruleset)}
+
+			got, done, err := r.reviewRules(t.Context(), ctrl.Log, req, ruleset, rules)
+			if tt.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			if tt.wantDegraded != "" {
+				assert.True(t, done)
+				var updated wafv1alpha1.RuleSet
+				require.NoError(t, c.Get(t.Context(), req.NamespacedName, &updated))
+				cond := apimeta.FindStatusCondition(updated.Status.Conditions, conditionDegraded)
+				require.NotNil(t, cond)
+				assert.Equal(t, tt.wantDegraded, cond.Reason)
+				return
+			}
+			assert.False(t, done)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestHTTPRuleSetHook(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req RuleSetHookRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || r.Method != http.MethodPost {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if req.Namespace == "broken" {
+			http.Error(w, "policy error", http.StatusInternalServerError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(RuleSetHookResponse{Allowed: req.Name != "denied", Message: "denied by policy"})
+	}))
+	defer server.Close()
+
+	hook := NewHTTPRuleSetHook(server.URL, time.Second)
+
+	resp, err := hook.ReviewRules(t.Context(), RuleSetHookRequest{Namespace: "team-a", Name: "allowed", Rules: "SecRuleEngine On"})
+	require.NoError(t, err)
+	assert.True(t, resp.Allowed)
+
+	resp, err = hook.ReviewRules(t.Context(), RuleSetHookRequest{Namespace: "team-a", Name: "denied"})
+	require.NoError(t, err)
+	assert.False(t, resp.Allowed)
+	assert.Equal(t, "denied by policy", resp.Message)
+
+	_, err = hook.ReviewRules(t.Context(), RuleSetHookRequest{Namespace: "broken", Name: "allowed"})
+	assert.ErrorContains(t, err, "500")
+}
@@ -0,0 +1,145 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// RuleSet IP Access Control - Vars
+// -----------------------------------------------------------------------------
+
+// ipAccessControlRuleIDBase is the first rule ID of the rules generated for
+// the IP access control of a RuleSet; RuleSources must not use IDs from
+// 88900000 to 88999999.
+const ipAccessControlRuleIDBase = 88900000
+
+// -----------------------------------------------------------------------------
+// RuleSet IP Access Control
+// -----------------------------------------------------------------------------
+
+// ipAccessDataName returns the name of the data file listing the inline
+// addresses of list, allow or deny, of the IP access control of the RuleSet
+// name.
+func ipAccessDataName(name, list string) string {
+	suffix := "-ip-" + list
+	if len(name)+len(suffix) > 253 {
+		name = strings.TrimRight(name[:253-len(suffix)], "-.")
+	}
+	return name + suffix
+}
+
+// validateIPAccessControl checks what the CRD schema cannot: that the inline
+// addresses of the IP access control are valid addresses or CIDR ranges.
+func validateIPAccessControl(ac *wafv1alpha1.IPAccessControl) error {
+	if ac == nil {
+		return nil
+	}
+	for _, list := range []struct {
+		name  string
+		addrs []string
+	}{{"allow", ac.Allow}, {"deny", ac.Deny}} {
+		for _, addr := range list.addrs {
+			if _, err := netip.ParsePrefix(addr); err == nil {
+				continue
+			}
+			if _, err := netip.ParseAddr(addr); err != nil {
+				return fmt.Errorf("ipAccessControl.%s: %s is not an IP address or CIDR range", list.name, addr)
+			}
+		}
+	}
+	return nil
+}
+
+// ipAccessControlData adds the data files listing the inline addresses of the
+// IP access control of the RuleSet to dataFiles.
+func ipAccessControlData(ruleset *wafv1alpha1.RuleSet, dataFiles map[string][]byte) map[string][]byte {
+	ac := ruleset.Spec.IPAccessControl
+	if ac == nil || len(ac.Allow)+len(ac.Deny) == 0 {
+		return dataFiles
+	}
+	if dataFiles == nil {
+		dataFiles = make(map[string][]byte, 2)
+	}
+	if len(ac.Allow) > 0 {
+		dataFiles[ipAccessDataName(ruleset.Name, "allow")] = []byte(strings.Join(ac.Allow, "\n"))
+	}
+	if len(ac.Deny) > 0 {
+		dataFiles[ipAccessDataName(ruleset.Name, "deny")] = []byte(strings.Join(ac.Deny, "\n"))
+	}
+	return dataFiles
+}
+
+// missingIPAccessDataFiles returns a message naming the data files referenced
+// by the IP access control of the RuleSet that are not in dataFiles, or an
+// empty string when they all are.
+func missingIPAccessDataFiles(ruleset *wafv1alpha1.RuleSet, dataFiles map[string][]byte) string {
+	ac := ruleset.Spec.IPAccessControl
+	if ac == nil {
+		return ""
+	}
+	var missing []string
+	for _, file := range []string{ac.DenyFile, ac.AllowFile} {
+		if _, ok := dataFiles[file]; file != "" && !ok {
+			missing = append(missing, file)
+		}
+	}
+	if len(missing) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("IP access control data files not found in spec.data: %s", strings.Join(missing, ", "))
+}
+
+// ipAccessControlRules returns the SecRules of the IP access control of the
+// RuleSet, or an empty string when it has none. The rules run in phase 1,
+// before every other rule but the emergency blocks: denied addresses are
+// checked first, then allowed addresses skip the remaining rules.
+func ipAccessControlRules(ruleset *wafv1alpha1.RuleSet) string {
+	ac := ruleset.Spec.IPAccessControl
+	if ac == nil {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("# IP access control generated from the RuleSet spec.ipAccessControl\n")
+	deny := func(id int, file string) {
+		fmt.Fprintf(&b, "SecRule REMOTE_ADDR \"@ipMatchFromFile %s\" \"id:%d,phase:1,deny,status:403,log,t:none,msg:'Client address denied by the RuleSet IP access control',logdata:'%%{REMOTE_ADDR}'\"\n",
+			file, id)
+	}
+	allow := func(id int, file string) {
+		fmt.Fprintf(&b, "SecRule REMOTE_ADDR \"@ipMatchFromFile %s\" \"id:%d,phase:1,allow,nolog,t:none,msg:'Client address allowed by the RuleSet IP access control'\"\n",
+			file, id)
+	}
+	if len(ac.Deny) > 0 {
+		deny(ipAccessControlRuleIDBase, ipAccessDataName(ruleset.Name, "deny"))
+	}
+	if ac.DenyFile != "" {
+		deny(ipAccessControlRuleIDBase+1, ac.DenyFile)
+	}
+	if len(ac.Allow) > 0 {
+		allow(ipAccessControlRuleIDBase+10, ipAccessDataName(ruleset.Name, "allow"))
+	}
+	if ac.AllowFile != "" {
+		allow(ipAccessControlRuleIDBase+11, ac.AllowFile)
+	}
+	return b.String()
+}
@@ -0,0 +1,113 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/corazawaf/coraza/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+func TestIPAccessControlRules(t *testing.T) {
+	assert.Empty(t, ipAccessControlRules(&wafv1alpha1.RuleSet{}))
+	assert.Nil(t, ipAccessControlData(&wafv1alpha1.RuleSet{}, nil))
+
+	ruleset := &wafv1alpha1.RuleSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "rules"},
+		Spec: wafv1alpha1.RuleSetSpec{IPAccessControl: &wafv1alpha1.IPAccessControl{
+			Allow:     []string{"10.0.0.0/8", "2001:db8::1"},
+			AllowFile: "partners",
+			Deny:      []string{"10.6.6.0/24", "192.0.2.66"},
+			DenyFile:  "abusers",
+		}},
+	}
+	require.NoError(t, validateIPAccessControl(ruleset.Spec.IPAccessControl))
+	assert.Equal(t, "IP access control data files not found in spec.data: abusers, partners",
+		missingIPAccessDataFiles(ruleset, nil))
+
+	dataFiles := ipAccessControlData(ruleset, map[string][]byte{
+		"partners": []byte("198.51.100.0/24\n"),
+		"abusers":  []byte("203.0.113.7\n"),
+	})
+	assert.Empty(t, missingIPAccessDataFiles(ruleset, dataFiles))
+	assert.Equal(t, "10.0.0.0/8\n2001:db8::1", string(dataFiles[ipAccessDataName("rules", "allow")]))
+
+	rules := ipAccessControlRules(ruleset)
+	assert.Contains(t, rules, "id:88900000,phase:1,deny,status:403,")
+	assert.Contains(t, rules, "id:88900011,phase:1,allow,")
+
+	// A rule of the sources that blocks every request the IP access
+	// control lets through.
+	const blockAll = `SecRule REQUEST_URI "@unconditionalMatch" "id:1,phase:2,deny,status:418"`
+	conf := coraza.NewWAFConfig().
+		WithDirectives("SecRuleEngine On\n" + rules + blockAll).
+		WithRootFS(getDataFilesystem(dataFiles))
+	waf, err := coraza.NewWAF(conf)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		addr       string
+		wantStatus int
+	}{
+		{name: "allowed", addr: "10.1.2.3"},
+		{name: "allowed IPv6", addr: "2001:db8::1"},
+		{name: "allowed from file", addr: "198.51.100.9"},
+		{name: "denied within an allowed range", addr: "10.6.6.6", wantStatus: 403},
+		{name: "denied", addr: "192.0.2.66", wantStatus: 403},
+		{name: "denied from file", addr: "203.0.113.7", wantStatus: 403},
+		{name: "other addresses go through the other rules", addr: "192.0.2.1", wantStatus: 418},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := waf.NewTransaction()
+			defer func() { _ = tx.Close() }()
+			tx.ProcessConnection(tt.addr, 12345, "10.0.0.1", 8080)
+			tx.ProcessURI("/", "GET", "HTTP/1.1")
+			interruption := tx.ProcessRequestHeaders()
+			if interruption == nil {
+				interruption, err = tx.ProcessRequestBody()
+				require.NoError(t, err)
+			}
+			if tt.wantStatus == 0 {
+				assert.Nil(t, interruption)
+				return
+			}
+			require.NotNil(t, interruption)
+			assert.Equal(t, tt.wantStatus, interruption.Status)
+		})
+	}
+}
+
+func TestValidateIPAccessControl(t *testing.T) {
+	assert.NoError(t, validateIPAccessControl(nil))
+	assert.NoError(t, validateIPAccessControl(&wafv1alpha1.IPAccessControl{AllowFile: "partners"}))
+	assert.EqualError(t, validateIPAccessControl(&wafv1alpha1.IPAccessControl{Deny: []string{"10.0.0.0/33"}}),
+		"ipAccessControl.deny: 10.0.0.0/33 is not an IP address or CIDR range")
+	assert.EqualError(t, validateIPAccessControl(&wafv1alpha1.IPAccessControl{Allow: []string{"1.2.3"}}),
+		"ipAccessControl.allow: 1.2.3 is not an IP address or CIDR range")
+}
+
+func TestIPAccessDataName(t *testing.T) {
+	assert.Equal(t, "rules-ip-deny", ipAccessDataName("rules", "deny"))
+	assert.Len(t, ipAccessDataName(string(make([]byte, 253)), "allow"), 253)
+}
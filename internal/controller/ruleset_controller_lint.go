@@ -0,0 +1,118 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/equality"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/internal/rulesets"
+)
+
+// -----------------------------------------------------------------------------
+// RuleSet Lint - Vars
+// -----------------------------------------------------------------------------
+
+const (
+	// maxLintFindings is the maximum number of lint findings listed in the
+	// status of a RuleSet.
+	maxLintFindings = 50
+
+	// maxLintMessageLength is the maximum length of the message of a lint
+	// finding.
+	maxLintMessageLength = 512
+)
+
+// -----------------------------------------------------------------------------
+// RuleSet Lint
+// -----------------------------------------------------------------------------
+
+// lintMinSeverity returns the lowest severity of the lint findings reported
+// for ruleset.
+func lintMinSeverity(ruleset *wafv1alpha1.RuleSet) rulesets.Severity {
+	if ruleset.Spec.Lint == nil {
+		return rulesets.SeverityWarning
+	}
+	switch ruleset.Spec.Lint.MinSeverity {
+	case wafv1alpha1.LintSeverityInfo:
+		return rulesets.SeverityInfo
+	case wafv1alpha1.LintSeverityError:
+		return rulesets.SeverityError
+	default:
+		return rulesets.SeverityWarning
+	}
+}
+
+// lintFindings lints the rules of the sources of ruleset and returns the
+// findings at or above its minimum severity, at most maxLintFindings.
+func lintFindings(ruleset *wafv1alpha1.RuleSet, rules string) []wafv1alpha1.LintFinding {
+	minSeverity := lintMinSeverity(ruleset)
+	var findings []wafv1alpha1.LintFinding
+	for _, f := range rulesets.Lint(rules) {
+		if f.Severity < minSeverity {
+			continue
+		}
+		if len(findings) == maxLintFindings {
+			break
+		}
+		msg := f.Message
+		if len(msg) > maxLintMessageLength {
+			msg = msg[:maxLintMessageLength]
+		}
+		findings = append(findings, wafv1alpha1.LintFinding{
+			RuleID:   int64(f.RuleID),
+			Check:    f.Check,
+			Severity: wafv1alpha1.LintSeverity(f.Severity.String()),
+			Message:  msg,
+		})
+	}
+	return findings
+}
+
+// recordLintFindings records the lint findings in the status of ruleset
+// when they changed, with a Warning event when there are any.
+func (r *RuleSetReconciler) recordLintFindings(
+	ctx context.Context,
+	log logr.Logger,
+	req ctrl.Request,
+	ruleset *wafv1alpha1.RuleSet,
+	findings []wafv1alpha1.LintFinding,
+) error {
+	if equality.Semantic.DeepEqual(ruleset.Status.LintFindings, findings) {
+		return nil
+	}
+
+	patch := client.MergeFrom(ruleset.DeepCopy())
+	ruleset.Status.LintFindings = findings
+	if err := r.Status().Patch(ctx, ruleset, patch); err != nil {
+		logAPIError(log, req, "RuleSet", err, "Failed to record lint findings", ruleset)
+		return err
+	}
+	logInfo(log, req, "RuleSet", "Recorded lint findings", "count", len(findings))
+	if len(findings) > 0 {
+		r.Recorder.Eventf(ruleset, nil, "Warning", "LintFindings", "Reconcile",
+			"The linter found %d problem(s) in the rules, the first: rule %d: %s (see status.lintFindings)",
+			len(findings), findings[0].RuleID, truncateEventNote(fmt.Sprintf("%s: %s", findings[0].Check, findings[0].Message)))
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/test/utils"
+)
+
+func TestLintFindings(t *testing.T) {
+	rules := `SecRule ARGS "@rx x" "id:1001,phase:2,deny"
+SecRule REQUEST_BODY "@rx x" "id:1002,phase:1,pass"`
+
+	tests := []struct {
+		name        string
+		minSeverity wafv1alpha1.LintSeverity
+		wantChecks  []string
+	}{
+		{name: "default threshold", wantChecks: []string{"phase-misuse", "missing-msg"}},
+		{name: "Info", minSeverity: wafv1alpha1.LintSeverityInfo, wantChecks: []string{"phase-misuse", "missing-msg", "missing-tag"}},
+		{name: "Error", minSeverity: wafv1alpha1.LintSeverityError, wantChecks: []string{"phase-misuse"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ruleset := &wafv1alpha1.RuleSet{}
+			if tt.minSeverity != "" {
+				ruleset.Spec.Lint = &wafv1alpha1.RuleLint{MinSeverity: tt.minSeverity}
+			}
+			var checks []string
+			for _, f := range lintFindings(ruleset, rules) {
+				checks = append(checks, f.Check)
+			}
+			assert.Equal(t, tt.wantChecks, checks)
+		})
+	}
+
+	t.Run("findings are capped", func(t *testing.T) {
+		var b strings.Builder
+		for i := range maxLintFindings + 5 {
+			fmt.Fprintf(&b, "SecRule ARGS \"@rx x\" \"id:%d,phase:2,deny,tag:'t'\"\n", 2000+i)
+		}
+		assert.Len(t, lintFindings(&wafv1alpha1.RuleSet{}, b.String()), maxLintFindings)
+	})
+}
+
+func TestRuleSetReconciler_RecordLintFindings(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+
+	ruleset := &wafv1alpha1.RuleSet{ObjectMeta: metav1.ObjectMeta{Name: "rules", Namespace: "team-a"}}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(ruleset).WithStatusSubresource(ruleset).Build()
+	recorder := utils.NewFakeRecorder()
+	r := &RuleSetReconciler{Client: c, Scheme: scheme, Recorder: recorder}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(ruleset)}
+
+	findings := lintFindings(ruleset, `SecRule ARGS "@rx x" "id:1001,phase:2,deny,tag:'t'"`)
+	require.Len(t, findings, 1)
+	require.NoError(t, r.recordLintFindings(t.Context(), utils.NewTestLogger(t), req, ruleset, findings))
+	require.NoError(t, r.recordLintFindings(t.Context(), utils.NewTestLogger(t), req, ruleset, findings))
+
+	var got wafv1alpha1.RuleSet
+	require.NoError(t, c.Get(t.Context(), req.NamespacedName, &got))
+	assert.Equal(t, findings, got.Status.LintFindings)
+	assert.Len(t, recorder.Events, 1, "unchanged findings are not reported again")
+
+	require.NoError(t, r.recordLintFindings(t.Context(), utils.NewTestLogger(t), req, ruleset, nil))
+	require.NoError(t, c.Get(t.Context(), req.NamespacedName, &got))
+	assert.Empty(t, got.Status.LintFindings)
+}
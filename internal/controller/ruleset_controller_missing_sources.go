@@ -0,0 +1,227 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/internal/rulesources"
+)
+
+// -----------------------------------------------------------------------------
+// RuleSet Controller - Missing Sources - Vars
+// -----------------------------------------------------------------------------
+
+const (
+	// reasonSourceMissing is the reason of the Degraded condition of a
+	// RuleSet some of whose sources do not exist.
+	reasonSourceMissing = "SourceMissing"
+
+	// missingSourceInitialBackoff and missingSourceMaxBackoff bound how long
+	// a missing source is assumed to still be missing before it is fetched
+	// again. The backoff doubles with each fetch that finds it missing.
+	missingSourceInitialBackoff = 5 * time.Second
+	missingSourceMaxBackoff     = 5 * time.Minute
+)
+
+// -----------------------------------------------------------------------------
+// RuleSet Controller - Missing Sources - Negative Cache
+// -----------------------------------------------------------------------------
+
+// missingSource is a source that was found missing.
+type missingSource struct {
+	// misses is how many fetches in a row found the source missing.
+	misses int
+
+	// retryAt is when the source is fetched again.
+	retryAt time.Time
+}
+
+// missingSourceCache remembers the sources of RuleSets that do not exist, so
+// that RuleSets reconciled for other reasons do not fetch them again until
+// their backoff expires, and the message of the SourceMissing condition of
+// each RuleSet, so that its event is only recorded when the missing sources
+// change.
+//
+// RuleSources are watched: entries are invalidated from the RuleSource watch
+// (see findRuleSetsForRuleSource), so that a RuleSource is used as soon as it
+// is created. Like targetCache, callers take an epoch with begin before
+// fetching and pass it to record, which discards the result when an
+// invalidation happened in between. Sources of other kinds are fetched again
+// when their backoff expires.
+//
+// A nil *missingSourceCache is valid and caches nothing.
+type missingSourceCache struct {
+	mu       sync.Mutex
+	epoch    uint64
+	sources  map[rulesources.Reference]*missingSource
+	reported map[types.NamespacedName]string
+}
+
+func newMissingSourceCache() *missingSourceCache {
+	return &missingSourceCache{
+		sources:  make(map[rulesources.Reference]*missingSource),
+		reported: make(map[types.NamespacedName]string),
+	}
+}
+
+// begin returns the current invalidation epoch, to be passed to record.
+func (c *missingSourceCache) begin() uint64 {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.epoch
+}
+
+// skip reports whether ref is known to be missing and is not due to be
+// fetched again at now.
+func (c *missingSourceCache) skip(ref rulesources.Reference, now time.Time) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.sources[ref]
+	return ok && now.Before(entry.retryAt)
+}
+
+// record records that a fetch found ref missing at now, doubling its
+// backoff, unless an invalidation happened since epoch was obtained from
+// begin.
+func (c *missingSourceCache) record(ref rulesources.Reference, now time.Time, epoch uint64) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.epoch != epoch {
+		return
+	}
+	entry, ok := c.sources[ref]
+	if !ok {
+		entry = &missingSource{}
+		c.sources[ref] = entry
+	}
+	entry.misses++
+	entry.retryAt = now.Add(missingSourceBackoff(entry.misses))
+}
+
+// found drops ref, which a fetch found.
+func (c *missingSourceCache) found(ref rulesources.Reference) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.sources, ref)
+}
+
+// forget drops ref, which changed, and discards the results of the fetches
+// in flight.
+func (c *missingSourceCache) forget(ref rulesources.Reference) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.epoch++
+	delete(c.sources, ref)
+}
+
+// retryAfter returns how long until the first of refs is fetched again.
+func (c *missingSourceCache) retryAfter(refs []rulesources.Reference, now time.Time) time.Duration {
+	if c == nil {
+		return missingSourceInitialBackoff
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var after time.Duration
+	for _, ref := range refs {
+		entry, ok := c.sources[ref]
+		if !ok {
+			continue
+		}
+		if d := entry.retryAt.Sub(now); after == 0 || d < after {
+			after = d
+		}
+	}
+	return max(after, time.Second)
+}
+
+// report records message as the SourceMissing condition of the RuleSet key,
+// or clears it when message is empty, and reports whether it changed.
+func (c *missingSourceCache) report(key types.NamespacedName, message string) bool {
+	if c == nil {
+		return message != ""
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	changed := c.reported[key] != message
+	if message == "" {
+		delete(c.reported, key)
+	} else {
+		c.reported[key] = message
+	}
+	return changed
+}
+
+// missingSourceBackoff returns the backoff of a source found missing by
+// misses fetches in a row.
+func missingSourceBackoff(misses int) time.Duration {
+	backoff := missingSourceInitialBackoff
+	for i := 1; i < misses && backoff < missingSourceMaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, missingSourceMaxBackoff)
+}
+
+// -----------------------------------------------------------------------------
+// RuleSet Controller - Missing Sources
+// -----------------------------------------------------------------------------
+
+// patchSourceMissing marks the RuleSet Degraded with a single SourceMissing
+// condition listing every missing source, and returns when the first of them
+// is fetched again. The event is only recorded when the missing sources
+// change, so that RuleSets reconciled while they wait do not repeat it.
+func (r *RuleSetReconciler) patchSourceMissing(ctx context.Context, log logr.Logger, req ctrl.Request, ruleset *wafv1alpha1.RuleSet, missing []rulesources.Reference) (time.Duration, error) {
+	from := ruleSetReferrer(ruleset)
+	names := make([]string, 0, len(missing))
+	for _, ref := range missing {
+		names = append(names, ref.Kind+" "+rulesources.DisplayName(from, ref))
+	}
+	msg := fmt.Sprintf("Referenced sources do not exist: %s", strings.Join(names, ", "))
+
+	retryAfter := r.missingSources.retryAfter(missing, time.Now())
+	logInfo(log, req, "RuleSet", "Sources not found; waiting for them to appear", "sources", names, "retryAfter", retryAfter)
+	if r.missingSources.report(req.NamespacedName, msg) {
+		r.Recorder.Eventf(ruleset, nil, "Warning", reasonSourceMissing, "Reconcile", truncateEventNote(msg))
+	}
+	return retryAfter, patchConditions(ctx, r.Status(), log, req, "RuleSet", ruleset, &ruleset.Status.Conditions, func() {
+		applyStatusConditionDegraded(&ruleset.Status.Conditions, ruleset.Generation, reasonSourceMissing, msg)
+	})
+}
@@ -0,0 +1,316 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	rcache "github.com/networking-incubator/coraza-kubernetes-operator/internal/rulesets/cache"
+)
+
+// -----------------------------------------------------------------------------
+// RuleSet Revisions - Vars
+// -----------------------------------------------------------------------------
+
+const (
+	// conditionRollbackPerformed is True while the cache server serves the
+	// previous revision of the rules of a RuleSet, because the gateways
+	// failed to load the latest one.
+	conditionRollbackPerformed = "RollbackPerformed"
+
+	// revisionLoadMinDeadline is the minimum time the gateways have to load
+	// a new revision of the rules before it is rejected.
+	revisionLoadMinDeadline = 2 * time.Minute
+
+	// revisionLoadPollIntervals is the number of poll intervals of an Engine
+	// its gateways have to load a new revision of the rules.
+	revisionLoadPollIntervals = 4
+
+	// defaultPollIntervalSeconds is the pollIntervalSeconds of an Engine
+	// without ruleSetCacheServer.
+	defaultPollIntervalSeconds = 15
+
+	// maxRejectedRevisions is the maximum number of rejected revisions kept
+	// in the status of a RuleSet; the oldest are dropped.
+	maxRejectedRevisions = 8
+
+	// dataPlaneHeartbeatInterval is how often the data plane status of an
+	// Engine is updated while the revision its gateways enforce does not
+	// change.
+	dataPlaneHeartbeatInterval = time.Minute
+)
+
+// engineRevisionSkew is 1 for the Engines whose gateways last reported
+// enforcing another revision of the rules than the revision published for
+// their RuleSet, and 0 for the others.
+var engineRevisionSkew = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "coraza_engine_revision_skew",
+		Help: "Whether the gateways of the Engine last reported enforcing another revision of the rules than the revision published for its RuleSet (1) or not (0).",
+	},
+	[]string{"namespace", "engine"},
+)
+
+func init() {
+	metrics.Registry.MustRegister(engineRevisionSkew)
+}
+
+// -----------------------------------------------------------------------------
+// RuleSet Revisions
+// -----------------------------------------------------------------------------
+
+// revisionLoadDeadline returns how long the gateways of engine have to load
+// a new revision of the rules.
+func revisionLoadDeadline(engine *wafv1alpha1.Engine) time.Duration {
+	poll := int32(defaultPollIntervalSeconds)
+	if engine.Spec.RuleSetCacheServer != nil && engine.Spec.RuleSetCacheServer.PollIntervalSeconds > 0 {
+		poll = engine.Spec.RuleSetCacheServer.PollIntervalSeconds
+	}
+	return max(revisionLoadMinDeadline, revisionLoadPollIntervals*time.Duration(poll)*time.Second)
+}
+
+// findRejectedRevision returns the rejected revision id of status, or nil.
+func findRejectedRevision(status *wafv1alpha1.RuleSetStatus, id string) *wafv1alpha1.RejectedRevision {
+	i := slices.IndexFunc(status.RejectedRevisions, func(r wafv1alpha1.RejectedRevision) bool { return r.UUID == id })
+	if i < 0 {
+		return nil
+	}
+	return &status.RejectedRevisions[i]
+}
+
+// rejectRevision adds the revision id to the rejected revisions of status,
+// keeping the maxRejectedRevisions most recent.
+func rejectRevision(status *wafv1alpha1.RuleSetStatus, id, reason string) {
+	if len(reason) > rcache.MaxHeartbeatErrorLength*2 {
+		reason = reason[:rcache.MaxHeartbeatErrorLength*2]
+	}
+	status.RejectedRevisions = append(status.RejectedRevisions, wafv1alpha1.RejectedRevision{
+		UUID:       id,
+		Reason:     reason,
+		RejectTime: metav1.Now(),
+	})
+	if n := len(status.RejectedRevisions); n > maxRejectedRevisions {
+		status.RejectedRevisions = slices.Clone(status.RejectedRevisions[n-maxRejectedRevisions:])
+	}
+}
+
+// rollBackRevision removes the rejected revision from the cache, so that
+// the previous revision is served again, and marks the RuleSet Degraded
+// with the RollbackPerformed condition.
+func (r *RuleSetReconciler) rollBackRevision(
+	ctx context.Context,
+	log logr.Logger,
+	req ctrl.Request,
+	ruleset *wafv1alpha1.RuleSet,
+	cacheKey string,
+	rejected *wafv1alpha1.RejectedRevision,
+) error {
+	msg := fmt.Sprintf("Revision %s was rejected: %s. ", rejected.UUID, rejected.Reason)
+	if served, ok := r.Cache.Reject(cacheKey, rejected.UUID); ok {
+		msg += fmt.Sprintf("Serving the previous revision %s until the rules change", served)
+	} else {
+		msg += "No previous revision is cached: gateways keep the rules they loaded until the rules change"
+	}
+	logInfo(log, req, "RuleSet", "Rolled back rejected revision", "uuid", rejected.UUID)
+
+	rollback := apimeta.FindStatusCondition(ruleset.Status.Conditions, conditionRollbackPerformed)
+	if rollback == nil || rollback.Status != metav1.ConditionTrue || rollback.Message != msg {
+		r.Recorder.Eventf(ruleset, nil, "Warning", "RollbackPerformed", "Reconcile", truncateEventNote(msg))
+	}
+	return patchConditions(ctx, r.Status(), log, req, "RuleSet", ruleset, &ruleset.Status.Conditions, func() {
+		setConditionTrue(&ruleset.Status.Conditions, ruleset.Generation, conditionRollbackPerformed, "RevisionRejected", msg)
+		applyStatusConditionDegraded(&ruleset.Status.Conditions, ruleset.Generation, "RollbackPerformed", msg)
+	})
+}
+
+// recordPublishedRevision records the revision id published to the cache
+// for cacheKey, and the name of its RuleSetSnapshot, in the status of
+// ruleset, and ends a rollback.
+func (r *RuleSetReconciler) recordPublishedRevision(
+	ctx context.Context,
+	log logr.Logger,
+	req ctrl.Request,
+	ruleset *wafv1alpha1.RuleSet,
+	cacheKey, id string,
+) error {
+	snapshot := rcache.SnapshotName(cacheKey, id)
+	rollback := apimeta.FindStatusCondition(ruleset.Status.Conditions, conditionRollbackPerformed)
+	rollingBack := rollback != nil && rollback.Status == metav1.ConditionTrue
+	if rev := ruleset.Status.Revision; rev != nil && rev.UUID == id && rev.Snapshot == snapshot && !rollingBack && ruleset.Status.PendingRevision == "" {
+		return nil
+	}
+
+	patch := client.MergeFrom(ruleset.DeepCopy())
+	ruleset.Status.PendingRevision = ""
+	ruleset.Status.PendingRevisionAuthors = nil
+	if rev := ruleset.Status.Revision; rev == nil || rev.UUID != id {
+		ruleset.Status.Revision = &wafv1alpha1.RuleSetRevision{UUID: id, PublishTime: metav1.Now()}
+	}
+	ruleset.Status.Revision.Snapshot = snapshot
+	if rollingBack {
+		setConditionFalse(&ruleset.Status.Conditions, ruleset.Generation, conditionRollbackPerformed, "RevisionPublished", fmt.Sprintf("Revision %s published", id))
+	}
+	if err := r.Status().Patch(ctx, ruleset, patch); err != nil {
+		logAPIError(log, req, "RuleSet", err, "Failed to record published revision", ruleset)
+		return err
+	}
+	logDebug(log, req, "RuleSet", "Recorded published revision", "uuid", id)
+	return nil
+}
+
+// rejectedRevisionsChangedPredicate triggers on Update events when a
+// revision of the rules was rejected.
+func rejectedRevisionsChangedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc:  func(e event.CreateEvent) bool { return false },
+		DeleteFunc:  func(e event.DeleteEvent) bool { return false },
+		GenericFunc: func(e event.GenericEvent) bool { return false },
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldRS, ok := e.ObjectOld.(*wafv1alpha1.RuleSet)
+			if !ok {
+				return false
+			}
+			newRS, ok := e.ObjectNew.(*wafv1alpha1.RuleSet)
+			if !ok {
+				return false
+			}
+			return !slices.EqualFunc(oldRS.Status.RejectedRevisions, newRS.Status.RejectedRevisions, func(a, b wafv1alpha1.RejectedRevision) bool {
+				return a.UUID == b.UUID
+			})
+		},
+	}
+}
+
+// -----------------------------------------------------------------------------
+// RuleSet Revisions - Heartbeats
+// -----------------------------------------------------------------------------
+
+// RevisionReporter verifies with the heartbeats of the gateways that they
+// load each revision of the rules published to the cache server. A
+// revision a gateway fails to load, or does not load within its deadline,
+// is added to the rejected revisions of the RuleSet, which rolls back to
+// the previous revision. It implements rcache.HeartbeatReporter.
+type RevisionReporter struct {
+	client    client.Client
+	apiReader client.Reader
+}
+
+// NewRevisionReporter returns a RevisionReporter. Engines and RuleSets are
+// read with apiReader, so that the heartbeats of every replica are merged
+// into the latest status.
+func NewRevisionReporter(c client.Client, apiReader client.Reader) *RevisionReporter {
+	return &RevisionReporter{client: c, apiReader: apiReader}
+}
+
+// ReportHeartbeat records the revision loaded by the gateway of caller, or
+// rejects the published revision of the RuleSet of cacheKey when the gateway
+// failed to load it, or has not loaded it within the deadline while no
+// gateway did. The heartbeat is rejected unless caller is the cache client
+// of an Engine using that RuleSet.
+func (v *RevisionReporter) ReportHeartbeat(ctx context.Context, caller rcache.AuthResult, cacheKey string, heartbeat rcache.Heartbeat) error {
+	engine, err := cacheClientEngine(ctx, v.apiReader, caller, cacheKey, rcache.ErrHeartbeatRejected)
+	if err != nil {
+		return err
+	}
+	deadline := revisionLoadDeadline(engine)
+
+	if err := v.recordDataPlane(ctx, engine, cacheKey, heartbeat); err != nil {
+		return err
+	}
+
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var ruleset wafv1alpha1.RuleSet
+		_, name, _ := strings.Cut(cacheKey, "/")
+		if err := v.apiReader.Get(ctx, types.NamespacedName{Namespace: engine.Namespace, Name: name}, &ruleset); err != nil {
+			if apierrors.IsNotFound(err) {
+				return fmt.Errorf("%w: RuleSet %s not found", rcache.ErrHeartbeatRejected, cacheKey)
+			}
+			return err
+		}
+		rev := ruleset.Status.Revision
+		if rev == nil {
+			return nil
+		}
+		skew := 0.0
+		if heartbeat.LoadedUUID != rev.UUID {
+			skew = 1
+		}
+		engineRevisionSkew.WithLabelValues(engine.Namespace, engine.Name).Set(skew)
+		if findRejectedRevision(&ruleset.Status, rev.UUID) != nil {
+			return nil
+		}
+
+		patch := client.MergeFromWithOptions(ruleset.DeepCopy(), client.MergeFromWithOptimisticLock{})
+		switch {
+		case heartbeat.FailedUUID == rev.UUID:
+			rejectRevision(&ruleset.Status, rev.UUID, fmt.Sprintf("Engine %s failed to load it: %s", engine.Name, heartbeat.Error))
+		case heartbeat.LoadedUUID == rev.UUID:
+			if rev.LoadedTime != nil {
+				return nil
+			}
+			rev.LoadedTime = new(metav1.Now())
+		case rev.LoadedTime == nil && time.Since(rev.PublishTime.Time) > deadline:
+			rejectRevision(&ruleset.Status, rev.UUID, fmt.Sprintf("Engine %s did not load it within %s", engine.Name, deadline))
+		default:
+			return nil
+		}
+		return v.client.Status().Patch(ctx, &ruleset, patch)
+	})
+}
+
+// recordDataPlane records the revision of cacheKey the gateways of engine
+// enforce, its RuleSetSnapshot, and the time of their heartbeat in its
+// status, at most once every dataPlaneHeartbeatInterval while the revision
+// does not change.
+func (v *RevisionReporter) recordDataPlane(ctx context.Context, engine *wafv1alpha1.Engine, cacheKey string, heartbeat rcache.Heartbeat) error {
+	if engine.Status != nil && engine.Status.DataPlane != nil &&
+		engine.Status.DataPlane.Revision == heartbeat.LoadedUUID &&
+		time.Since(engine.Status.DataPlane.LastHeartbeatTime.Time) < dataPlaneHeartbeatInterval {
+		return nil
+	}
+
+	patch := client.MergeFrom(engine.DeepCopy())
+	if engine.Status == nil {
+		engine.Status = &wafv1alpha1.EngineStatus{}
+	}
+	engine.Status.DataPlane = &wafv1alpha1.DataPlaneStatus{
+		Revision:          heartbeat.LoadedUUID,
+		LastHeartbeatTime: metav1.Now(),
+	}
+	if heartbeat.LoadedUUID != "" {
+		engine.Status.DataPlane.Snapshot = rcache.SnapshotName(cacheKey, heartbeat.LoadedUUID)
+	}
+	return client.IgnoreNotFound(v.client.Status().Patch(ctx, engine, patch))
+}
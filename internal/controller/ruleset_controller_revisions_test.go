@@ -0,0 +1,213 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	rcache "github.com/networking-incubator/coraza-kubernetes-operator/internal/rulesets/cache"
+	"github.com/networking-incubator/coraza-kubernetes-operator/test/utils"
+)
+
+func TestRevisionLoadDeadline(t *testing.T) {
+	engine := &wafv1alpha1.Engine{}
+	assert.Equal(t, 2*time.Minute, revisionLoadDeadline(engine))
+
+	engine.Spec.RuleSetCacheServer = &wafv1alpha1.RuleSetCacheServerConfig{PollIntervalSeconds: 60}
+	assert.Equal(t, 4*time.Minute, revisionLoadDeadline(engine))
+}
+
+func TestRejectRevision(t *testing.T) {
+	var status wafv1alpha1.RuleSetStatus
+	for i := range maxRejectedRevisions + 2 {
+		rejectRevision(&status, fmt.Sprintf("rev-%d", i), "failed")
+	}
+	require.Len(t, status.RejectedRevisions, maxRejectedRevisions)
+	assert.Nil(t, findRejectedRevision(&status, "rev-1"), "the oldest rejected revisions are dropped")
+	assert.NotNil(t, findRejectedRevision(&status, fmt.Sprintf("rev-%d", maxRejectedRevisions+1)))
+}
+
+func TestRevisionReporter(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	engine := utils.NewTestEngine(utils.EngineOptions{Name: "waf", Namespace: "team-a", GatewayName: "gateway"})
+	engine.UID = "engine-uid"
+	sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{
+		Name:      "coraza-engine-abc",
+		Namespace: "team-a",
+		OwnerReferences: []metav1.OwnerReference{{
+			APIVersion: wafv1alpha1.GroupVersion.String(),
+			Kind:       "Engine",
+			Name:       engine.Name,
+			UID:        engine.UID,
+			Controller: new(true),
+		}},
+	}}
+	caller := rcache.AuthResult{Namespace: "team-a", Name: sa.Name}
+
+	newReporter := func(t *testing.T, publishTime time.Time) (*RevisionReporter, client.Client, *wafv1alpha1.RuleSet) {
+		t.Helper()
+		ruleset := &wafv1alpha1.RuleSet{
+			ObjectMeta: metav1.ObjectMeta{Name: engine.Spec.RuleSet.Name, Namespace: "team-a"},
+			Spec:       wafv1alpha1.RuleSetSpec{Sources: []wafv1alpha1.SourceReference{{Name: "base"}}},
+			Status: wafv1alpha1.RuleSetStatus{Revision: &wafv1alpha1.RuleSetRevision{
+				UUID:        "rev-2",
+				PublishTime: metav1.NewTime(publishTime),
+			}},
+		}
+		c := fake.NewClientBuilder().WithScheme(scheme).
+			WithObjects(engine.DeepCopy(), sa.DeepCopy(), ruleset).
+			WithStatusSubresource(ruleset, engine).
+			Build()
+		return NewRevisionReporter(c, c), c, ruleset
+	}
+	cacheKey := "team-a/" + engine.Spec.RuleSet.Name
+
+	tests := []struct {
+		name         string
+		publishedAgo time.Duration
+		heartbeat    rcache.Heartbeat
+		wantLoaded   bool
+		wantRejected string
+	}{
+		{
+			name:       "revision loaded",
+			heartbeat:  rcache.Heartbeat{LoadedUUID: "rev-2"},
+			wantLoaded: true,
+		},
+		{
+			name:         "revision failed to load",
+			heartbeat:    rcache.Heartbeat{LoadedUUID: "rev-1", FailedUUID: "rev-2", Error: "invalid SecRule"},
+			wantRejected: "Engine waf failed to load it: invalid SecRule",
+		},
+		{
+			name:      "previous revision within the deadline",
+			heartbeat: rcache.Heartbeat{LoadedUUID: "rev-1"},
+		},
+		{
+			name:         "previous revision after the deadline",
+			publishedAgo: 3 * time.Minute,
+			heartbeat:    rcache.Heartbeat{LoadedUUID: "rev-1"},
+			wantRejected: "Engine waf did not load it within 2m0s",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reporter, c, ruleset := newReporter(t, time.Now().Add(-tt.publishedAgo))
+			require.NoError(t, reporter.ReportHeartbeat(t.Context(), caller, cacheKey, tt.heartbeat))
+
+			require.NoError(t, c.Get(t.Context(), client.ObjectKeyFromObject(ruleset), ruleset))
+			assert.Equal(t, tt.wantLoaded, ruleset.Status.Revision.LoadedTime != nil)
+
+			var gotEngine wafv1alpha1.Engine
+			require.NoError(t, c.Get(t.Context(), client.ObjectKeyFromObject(engine), &gotEngine))
+			require.NotNil(t, gotEngine.Status)
+			require.NotNil(t, gotEngine.Status.DataPlane, "the heartbeat is recorded on the Engine")
+			assert.Equal(t, tt.heartbeat.LoadedUUID, gotEngine.Status.DataPlane.Revision)
+			assert.Equal(t, engine.Spec.RuleSet.Name+"-"+tt.heartbeat.LoadedUUID, gotEngine.Status.DataPlane.Snapshot)
+			assert.False(t, gotEngine.Status.DataPlane.LastHeartbeatTime.IsZero())
+			wantSkew := 0.0
+			if tt.heartbeat.LoadedUUID != "rev-2" {
+				wantSkew = 1
+			}
+			assert.Equal(t, wantSkew, testutil.ToFloat64(engineRevisionSkew.WithLabelValues(engine.Namespace, engine.Name)))
+			if tt.wantRejected == "" {
+				assert.Empty(t, ruleset.Status.RejectedRevisions)
+				return
+			}
+			require.Len(t, ruleset.Status.RejectedRevisions, 1)
+			assert.Equal(t, "rev-2", ruleset.Status.RejectedRevisions[0].UUID)
+			assert.Equal(t, tt.wantRejected, ruleset.Status.RejectedRevisions[0].Reason)
+
+			t.Log("Rejecting a revision once")
+			require.NoError(t, reporter.ReportHeartbeat(t.Context(), caller, cacheKey, tt.heartbeat))
+			require.NoError(t, c.Get(t.Context(), client.ObjectKeyFromObject(ruleset), ruleset))
+			assert.Len(t, ruleset.Status.RejectedRevisions, 1)
+		})
+	}
+
+	t.Run("other RuleSet", func(t *testing.T) {
+		reporter, _, _ := newReporter(t, time.Now())
+		err := reporter.ReportHeartbeat(t.Context(), caller, "team-a/other", rcache.Heartbeat{LoadedUUID: "rev-2"})
+		assert.ErrorIs(t, err, rcache.ErrHeartbeatRejected)
+	})
+}
+
+func TestRuleSetReconciler_RollBackRevision(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+
+	ruleset := &wafv1alpha1.RuleSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "rules", Namespace: "team-a", Generation: 2},
+		Spec:       wafv1alpha1.RuleSetSpec{Sources: []wafv1alpha1.SourceReference{{Name: "base"}}},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(ruleset).WithStatusSubresource(ruleset).Build()
+	r := &RuleSetReconciler{Client: c, Scheme: scheme, Recorder: utils.NewTestRecorder(), Cache: rcache.NewRuleSetCache(), Runtime: NewRuntimeConfig()}
+	log := utils.NewTestLogger(t)
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(ruleset)}
+	cacheKey := "team-a/rules"
+
+	t.Log("Publishing two revisions")
+	_, err := r.cacheRules(t.Context(), log, req, ruleset, "SecRuleEngine On", nil, "")
+	require.NoError(t, err)
+	previous := ruleset.Status.Revision.UUID
+	assert.Equal(t, "rules-"+previous, ruleset.Status.Revision.Snapshot)
+	_, err = r.cacheRules(t.Context(), log, req, ruleset, "SecRuleEngine Oops", nil, "")
+	require.NoError(t, err)
+	bad := ruleset.Status.Revision.UUID
+	require.NotEqual(t, previous, bad)
+	latest, ok := r.Cache.Get(cacheKey)
+	require.True(t, ok)
+	assert.Equal(t, bad, latest.UUID)
+
+	t.Log("Rolling back the rejected revision")
+	rejectRevision(&ruleset.Status, bad, "Engine waf failed to load it: invalid directive")
+	require.NoError(t, c.Status().Update(t.Context(), ruleset))
+	_, err = r.cacheRules(t.Context(), log, req, ruleset, "SecRuleEngine Oops", nil, "")
+	require.NoError(t, err)
+	latest, ok = r.Cache.Get(cacheKey)
+	require.True(t, ok)
+	assert.Equal(t, previous, latest.UUID)
+	rollback := apimeta.FindStatusCondition(ruleset.Status.Conditions, conditionRollbackPerformed)
+	require.NotNil(t, rollback)
+	assert.Equal(t, metav1.ConditionTrue, rollback.Status)
+	assert.Contains(t, rollback.Message, "Serving the previous revision "+previous)
+	assert.True(t, apimeta.IsStatusConditionTrue(ruleset.Status.Conditions, conditionDegraded))
+
+	t.Log("Publishing fixed rules ends the rollback")
+	_, err = r.cacheRules(t.Context(), log, req, ruleset, "SecRuleEngine DetectionOnly", nil, "")
+	require.NoError(t, err)
+	assert.True(t, apimeta.IsStatusConditionFalse(ruleset.Status.Conditions, conditionRollbackPerformed))
+	assert.True(t, apimeta.IsStatusConditionTrue(ruleset.Status.Conditions, conditionReady))
+	assert.NotEqual(t, bad, ruleset.Status.Revision.UUID)
+}
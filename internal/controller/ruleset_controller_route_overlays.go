@@ -0,0 +1,321 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// RuleSet Route Overlays - Vars
+// -----------------------------------------------------------------------------
+
+// routeOverlayRuleIDBase is the first rule ID of the rules generated for
+// RuleSet route overlays. Match j of the route rule of the overlay at index
+// i uses the ID routeOverlayRuleIDBase+i*routeOverlayMaxMatches+j;
+// RuleSources must not use IDs from this range.
+const routeOverlayRuleIDBase = 89500000
+
+// routeOverlayMaxMatches is the maximum number of matches of an HTTPRoute
+// rule allowed by the Gateway API.
+const routeOverlayMaxMatches = 64
+
+// HTTPRouteGVK is the GroupVersionKind of the Gateway API HTTPRoute.
+var HTTPRouteGVK = schema.GroupVersionKind{
+	Group:   gatewayGroup,
+	Version: "v1",
+	Kind:    "HTTPRoute",
+}
+
+// -----------------------------------------------------------------------------
+// RuleSet Route Overlays
+// -----------------------------------------------------------------------------
+
+// loadRouteOverlays returns the SecRules compiled from the route overlays of
+// the RuleSet, or an empty string when it has none. An overlay whose
+// HTTPRoute or route rule does not exist degrades the RuleSet until it is
+// created.
+func (r *RuleSetReconciler) loadRouteOverlays(
+	ctx context.Context,
+	log logr.Logger,
+	req ctrl.Request,
+	ruleset *wafv1alpha1.RuleSet,
+) (string, bool, error) {
+	if len(ruleset.Spec.RouteOverlays) == 0 {
+		return "", false, nil
+	}
+
+	logInfo(log, req, "RuleSet", "Loading route overlays", "routeOverlayCount", len(ruleset.Spec.RouteOverlays))
+
+	if !r.hasCapability(CapabilityHTTPRoute) {
+		msg := "The RuleSet has routeOverlays, but the Gateway API HTTPRoute is not installed in the cluster"
+		if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "RuleSet", ruleset, &ruleset.Status.Conditions, ruleset.Generation, "HTTPRouteAPINotInstalled", msg); patchErr != nil {
+			return "", true, patchErr
+		}
+		return "", true, nil
+	}
+
+	var b strings.Builder
+	b.WriteString("# Route overlays generated from the RuleSet spec.routeOverlays\n")
+	for i, overlay := range ruleset.Spec.RouteOverlays {
+		route := &unstructured.Unstructured{}
+		route.SetGroupVersionKind(HTTPRouteGVK)
+		if err := r.Get(ctx, types.NamespacedName{Namespace: ruleset.Namespace, Name: overlay.HTTPRoute.Name}, route); err != nil {
+			if apierrors.IsNotFound(err) {
+				logInfo(log, req, "RuleSet", "HTTPRoute of route overlay not found", "routeOverlayName", overlay.Name, "httpRouteName", overlay.HTTPRoute.Name)
+				msg := fmt.Sprintf("HTTPRoute %s of route overlay %s does not exist", overlay.HTTPRoute.Name, overlay.Name)
+				if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "RuleSet", ruleset, &ruleset.Status.Conditions, ruleset.Generation, "HTTPRouteNotFound", msg); patchErr != nil {
+					return "", true, patchErr
+				}
+				return "", true, nil
+			}
+			logError(log, req, "RuleSet", err, "Failed to get HTTPRoute", "httpRouteName", overlay.HTTPRoute.Name)
+			msg := fmt.Sprintf("Failed to access HTTPRoute %s of route overlay %s: %v", overlay.HTTPRoute.Name, overlay.Name, err)
+			if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "RuleSet", ruleset, &ruleset.Status.Conditions, ruleset.Generation, "HTTPRouteAccessError", msg); patchErr != nil {
+				return "", true, patchErr
+			}
+			return "", true, err
+		}
+
+		rules, found := routeOverlayRules(i, overlay, route)
+		if !found {
+			logInfo(log, req, "RuleSet", "Route rule of route overlay not found", "routeOverlayName", overlay.Name, "httpRouteName", overlay.HTTPRoute.Name, "sectionName", overlay.HTTPRoute.SectionName)
+			msg := fmt.Sprintf("HTTPRoute %s has no rule named %s, targeted by route overlay %s", overlay.HTTPRoute.Name, overlay.HTTPRoute.SectionName, overlay.Name)
+			if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "RuleSet", ruleset, &ruleset.Status.Conditions, ruleset.Generation, "RouteRuleNotFound", msg); patchErr != nil {
+				return "", true, patchErr
+			}
+			return "", true, nil
+		}
+		b.WriteString(rules)
+	}
+
+	return b.String(), false, nil
+}
+
+// routeOverlayRules returns the SecRules applying overlay, at index i of the
+// RuleSet, to the requests matched by its rule of route: one chained rule
+// per match of the route rule, matching the hostnames of the route and the
+// path, method, headers and query parameters of the match. It reports false
+// when route has no rule named after the sectionName of the overlay.
+func routeOverlayRules(i int, overlay wafv1alpha1.RouteOverlay, route *unstructured.Unstructured) (string, bool) {
+	rule, found := httpRouteRule(route, overlay.HTTPRoute.SectionName)
+	if !found {
+		return "", false
+	}
+
+	var actions []string
+	if overlay.RuleEngine != "" {
+		actions = append(actions, "ctl:ruleEngine="+string(overlay.RuleEngine))
+	}
+	if overlay.ParanoiaLevel > 0 {
+		actions = append(actions,
+			fmt.Sprintf("setvar:tx.blocking_paranoia_level=%d", overlay.ParanoiaLevel),
+			fmt.Sprintf("setvar:tx.detection_paranoia_level=%d", overlay.ParanoiaLevel))
+	}
+	overlayActions := strings.Join(actions, ",")
+	msg := fmt.Sprintf("msg:'RuleSet route overlay %s'", overlay.Name)
+
+	var b strings.Builder
+	writeRouteRuleMatches(&b, routeOverlayRuleIDBase+i*routeOverlayMaxMatches, msg, overlayActions, route, rule, httpRouteMatchConditions)
+	return b.String(), true
+}
+
+// writeRouteRuleMatches writes to b one SecRule per match of rule, a rule of
+// route, running actions on the requests matching the hostnames of the route
+// and the conditions returned by matchConditions for the match, such as
+// httpRouteMatchConditions. Match j uses the ID firstID+j.
+func writeRouteRuleMatches(
+	b *strings.Builder,
+	firstID int,
+	msg, actions string,
+	route *unstructured.Unstructured,
+	rule map[string]any,
+	matchConditions func(match map[string]any) [][]string,
+) {
+	var hostCondition []string
+	hostnames, _, _ := unstructured.NestedStringSlice(route.Object, "spec", "hostnames")
+	if len(hostnames) > 0 {
+		hostCondition = []string{"REQUEST_HEADERS:Host", "@rx " + hostnamePattern(hostnames)}
+	}
+
+	matches, _, _ := unstructured.NestedSlice(rule, "matches")
+	if len(matches) == 0 {
+		// A rule without matches matches every request.
+		matches = []any{map[string]any{}}
+	}
+
+	for j, m := range matches {
+		if j == routeOverlayMaxMatches {
+			break
+		}
+		match, _ := m.(map[string]any)
+		var conditions [][]string
+		if hostCondition != nil {
+			conditions = append(conditions, hostCondition)
+		}
+		conditions = append(conditions, matchConditions(match)...)
+
+		id := firstID + j
+		if len(conditions) == 0 {
+			fmt.Fprintf(b, "SecAction \"id:%d,phase:1,pass,nolog,t:none,%s,%s\"\n", id, msg, actions)
+			continue
+		}
+		for k, condition := range conditions {
+			last := k == len(conditions)-1
+			switch {
+			case k == 0 && last:
+				fmt.Fprintf(b, "SecRule %s \"%s\" \"id:%d,phase:1,pass,nolog,t:none,%s,%s\"\n", condition[0], condition[1], id, msg, actions)
+			case k == 0:
+				fmt.Fprintf(b, "SecRule %s \"%s\" \"id:%d,phase:1,pass,nolog,t:none,%s,chain\"\n", condition[0], condition[1], id, msg)
+			case last:
+				fmt.Fprintf(b, "    SecRule %s \"%s\" \"t:none,%s\"\n", condition[0], condition[1], actions)
+			default:
+				fmt.Fprintf(b, "    SecRule %s \"%s\" \"t:none,chain\"\n", condition[0], condition[1])
+			}
+		}
+	}
+}
+
+// httpRouteRule returns the rule of route named sectionName.
+func httpRouteRule(route *unstructured.Unstructured, sectionName string) (map[string]any, bool) {
+	rules, _, _ := unstructured.NestedSlice(route.Object, "spec", "rules")
+	for _, r := range rules {
+		rule, ok := r.(map[string]any)
+		if !ok {
+			continue
+		}
+		if name, _, _ := unstructured.NestedString(rule, "name"); name == sectionName {
+			return rule, true
+		}
+	}
+	return nil, false
+}
+
+// httpRouteMatchConditions returns the variable and operator of the SecRule
+// conditions matching the path, method, headers and query parameters of an
+// HTTPRoute match. A path prefix of "/" matches every request and needs no
+// condition.
+func httpRouteMatchConditions(match map[string]any) [][]string {
+	var conditions [][]string
+
+	pathType, _, _ := unstructured.NestedString(match, "path", "type")
+	pathValue, _, _ := unstructured.NestedString(match, "path", "value")
+	switch pathType {
+	case "Exact":
+		conditions = append(conditions, []string{"REQUEST_FILENAME", "@rx " + secRulePattern("^"+regexp.QuoteMeta(pathValue)+"$")})
+	case "RegularExpression":
+		conditions = append(conditions, []string{"REQUEST_FILENAME", "@rx " + secRulePattern("^(?:"+pathValue+")$")})
+	default:
+		// PathPrefix matches on path elements, ignoring a trailing "/".
+		if prefix := strings.TrimSuffix(pathValue, "/"); prefix != "" {
+			conditions = append(conditions, []string{"REQUEST_FILENAME", "@rx " + secRulePattern("^"+regexp.QuoteMeta(prefix)+"(?:/|$)")})
+		}
+	}
+
+	if method, _, _ := unstructured.NestedString(match, "method"); method != "" {
+		conditions = append(conditions, []string{"REQUEST_METHOD", "@streq " + method})
+	}
+
+	headers, _, _ := unstructured.NestedSlice(match, "headers")
+	for _, h := range headers {
+		if header, ok := h.(map[string]any); ok {
+			conditions = append(conditions, httpRouteValueCondition("REQUEST_HEADERS", header))
+		}
+	}
+	params, _, _ := unstructured.NestedSlice(match, "queryParams")
+	for _, p := range params {
+		if param, ok := p.(map[string]any); ok {
+			conditions = append(conditions, httpRouteValueCondition("ARGS_GET", param))
+		}
+	}
+
+	return conditions
+}
+
+// httpRouteValueCondition returns the condition of a header or query
+// parameter match of an HTTPRoute, read from collection.
+func httpRouteValueCondition(collection string, match map[string]any) []string {
+	name, _, _ := unstructured.NestedString(match, "name")
+	value, _, _ := unstructured.NestedString(match, "value")
+	pattern := "^" + regexp.QuoteMeta(value) + "$"
+	if matchType, _, _ := unstructured.NestedString(match, "type"); matchType == "RegularExpression" {
+		pattern = "^(?:" + value + ")$"
+	}
+	return []string{collection + ":" + name, "@rx " + secRulePattern(pattern)}
+}
+
+// hostnamePattern returns a regular expression matching a Host header for
+// one of the hostnames of a route, with an optional port. A wildcard
+// hostname matches one or more labels.
+func hostnamePattern(hostnames []string) string {
+	quoted := make([]string, 0, len(hostnames))
+	for _, hostname := range hostnames {
+		if suffix, ok := strings.CutPrefix(hostname, "*."); ok {
+			quoted = append(quoted, `.+\.`+regexp.QuoteMeta(suffix))
+			continue
+		}
+		quoted = append(quoted, regexp.QuoteMeta(hostname))
+	}
+	return secRulePattern(`(?i)^(?:` + strings.Join(quoted, "|") + `)(?::\d+)?$`)
+}
+
+// secRulePattern escapes the characters of a regular expression that would
+// end the operator argument of a SecRule.
+func secRulePattern(pattern string) string {
+	return strings.NewReplacer(`"`, `\x22`, "\n", `\n`, "\r", `\r`).Replace(pattern)
+}
+
+// findRuleSetsForHTTPRoute maps an HTTPRoute to the RuleSets in its
+// namespace with a route overlay targeting it or with it in their route
+// scope.
+func (r *RuleSetReconciler) findRuleSetsForHTTPRoute(ctx context.Context, route client.Object) []reconcile.Request {
+	log := logf.FromContext(ctx)
+
+	var ruleSetList wafv1alpha1.RuleSetList
+	if err := r.List(ctx, &ruleSetList, client.InNamespace(route.GetNamespace())); err != nil {
+		log.Error(err, "RuleSet: Failed to list RuleSets", "namespace", route.GetNamespace())
+		return nil
+	}
+	return collectRequests(ruleSetList.Items, func(rs *wafv1alpha1.RuleSet) bool {
+		for _, overlay := range rs.Spec.RouteOverlays {
+			if overlay.HTTPRoute.Name == route.GetName() {
+				return true
+			}
+		}
+		for _, scope := range rs.Spec.RouteScope {
+			if routeScopeKind(scope) == wafv1alpha1.RouteScopeKindHTTPRoute && scope.Name == route.GetName() {
+				return true
+			}
+		}
+		return false
+	})
+}
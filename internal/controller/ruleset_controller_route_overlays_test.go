@@ -0,0 +1,319 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/corazawaf/coraza/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/test/utils"
+)
+
+// newTestHTTPRoute returns the HTTPRoute shop in team-a with a "static" rule
+// for /static/, an "api" rule for POST and PUT requests to /api or requests
+// with an X-Api header and a format query parameter, and an unnamed rule.
+func newTestHTTPRoute(t *testing.T, hostnames ...string) *unstructured.Unstructured {
+	route := &unstructured.Unstructured{Object: map[string]any{
+		"spec": map[string]any{
+			"rules": []any{
+				map[string]any{
+					"name": "static",
+					"matches": []any{
+						map[string]any{"path": map[string]any{"type": "PathPrefix", "value": "/static/"}},
+					},
+				},
+				map[string]any{
+					"name": "api",
+					"matches": []any{
+						map[string]any{"path": map[string]any{"type": "PathPrefix", "value": "/api"}, "method": "POST"},
+						map[string]any{"path": map[string]any{"type": "Exact", "value": "/api"}, "method": "PUT"},
+						map[string]any{
+							"headers":     []any{map[string]any{"name": "X-Api", "value": "v[12]", "type": "RegularExpression"}},
+							"queryParams": []any{map[string]any{"name": "format", "value": "json"}},
+						},
+					},
+				},
+				map[string]any{},
+			},
+		},
+	}}
+	route.SetGroupVersionKind(HTTPRouteGVK)
+	route.SetNamespace("team-a")
+	route.SetName("shop")
+	if len(hostnames) > 0 {
+		require.NoError(t, unstructured.SetNestedStringSlice(route.Object, hostnames, "spec", "hostnames"))
+	}
+	return route
+}
+
+func TestRouteOverlayRules(t *testing.T) {
+	const denyRules = `SecRuleEngine DetectionOnly
+SecRule REQUEST_URI "@contains attack" "id:1,phase:1,deny,status:403"
+SecRule TX:blocking_paranoia_level "@ge 3" "id:2,phase:1,deny,status:403"`
+
+	blocking := wafv1alpha1.RouteOverlay{
+		Name:       "api-blocking",
+		HTTPRoute:  wafv1alpha1.HTTPRouteRuleReference{Name: "shop", SectionName: "api"},
+		RuleEngine: wafv1alpha1.RouteOverlayRuleEngineOn,
+	}
+	paranoid := wafv1alpha1.RouteOverlay{
+		Name:          "static-paranoid",
+		HTTPRoute:     wafv1alpha1.HTTPRouteRuleReference{Name: "shop", SectionName: "static"},
+		RuleEngine:    wafv1alpha1.RouteOverlayRuleEngineOn,
+		ParanoiaLevel: 3,
+	}
+
+	tests := []struct {
+		name      string
+		overlays  []wafv1alpha1.RouteOverlay
+		hostnames []string
+		method    string
+		uri       string
+		headers   map[string]string
+		blocked   bool
+	}{
+		{
+			name:   "no overlay",
+			method: "POST",
+			uri:    "/api/attack",
+		},
+		{
+			name:     "path prefix and method",
+			overlays: []wafv1alpha1.RouteOverlay{blocking},
+			method:   "POST",
+			uri:      "/api/attack",
+			blocked:  true,
+		},
+		{
+			name:     "path prefix matches path elements",
+			overlays: []wafv1alpha1.RouteOverlay{blocking},
+			method:   "POST",
+			uri:      "/apiattack",
+		},
+		{
+			name:     "other method",
+			overlays: []wafv1alpha1.RouteOverlay{blocking},
+			method:   "GET",
+			uri:      "/api/attack",
+		},
+		{
+			name:     "exact path",
+			overlays: []wafv1alpha1.RouteOverlay{blocking},
+			method:   "PUT",
+			uri:      "/api?q=attack",
+			blocked:  true,
+		},
+		{
+			name:     "exact path is not a prefix",
+			overlays: []wafv1alpha1.RouteOverlay{blocking},
+			method:   "PUT",
+			uri:      "/api/attack",
+		},
+		{
+			name:     "header and query parameter",
+			overlays: []wafv1alpha1.RouteOverlay{blocking},
+			method:   "GET",
+			uri:      "/attack?format=json",
+			headers:  map[string]string{"X-Api": "v2"},
+			blocked:  true,
+		},
+		{
+			name:     "query parameter not matching",
+			overlays: []wafv1alpha1.RouteOverlay{blocking},
+			method:   "GET",
+			uri:      "/attack?format=xml",
+			headers:  map[string]string{"X-Api": "v2"},
+		},
+		{
+			name:     "paranoia level",
+			overlays: []wafv1alpha1.RouteOverlay{blocking, paranoid},
+			method:   "GET",
+			uri:      "/static/app.js",
+			blocked:  true,
+		},
+		{
+			name:      "matching hostname",
+			overlays:  []wafv1alpha1.RouteOverlay{blocking},
+			hostnames: []string{"*.example.com"},
+			method:    "POST",
+			uri:       "/api/attack",
+			headers:   map[string]string{"Host": "shop.Example.com:8443"},
+			blocked:   true,
+		},
+		{
+			name:      "other hostname",
+			overlays:  []wafv1alpha1.RouteOverlay{blocking},
+			hostnames: []string{"*.example.com"},
+			method:    "POST",
+			uri:       "/api/attack",
+			headers:   map[string]string{"Host": "example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := newTestHTTPRoute(t, tt.hostnames...)
+			var rules string
+			for i, overlay := range tt.overlays {
+				overlayRules, found := routeOverlayRules(i, overlay, route)
+				require.True(t, found)
+				rules += overlayRules
+			}
+			waf, err := coraza.NewWAF(coraza.NewWAFConfig().WithDirectives(rules + denyRules))
+			require.NoError(t, err)
+
+			tx := waf.NewTransaction()
+			defer func() { _ = tx.Close() }()
+			tx.ProcessURI(tt.uri, tt.method, "HTTP/1.1")
+			for k, v := range tt.headers {
+				tx.AddRequestHeader(k, v)
+			}
+			interruption := tx.ProcessRequestHeaders()
+			if tt.blocked {
+				assert.NotNil(t, interruption, "request should be blocked")
+			} else {
+				assert.Nil(t, interruption, "request should not be blocked")
+			}
+		})
+	}
+}
+
+func TestRouteOverlayRules_IDs(t *testing.T) {
+	route := newTestHTTPRoute(t)
+	overlay := wafv1alpha1.RouteOverlay{
+		Name:       "api",
+		HTTPRoute:  wafv1alpha1.HTTPRouteRuleReference{Name: "shop", SectionName: "api"},
+		RuleEngine: wafv1alpha1.RouteOverlayRuleEngineOff,
+	}
+	rules, found := routeOverlayRules(1, overlay, route)
+	require.True(t, found)
+	assert.Contains(t, rules, "id:89500064,")
+	assert.Contains(t, rules, "id:89500066,")
+	assert.Contains(t, rules, "ctl:ruleEngine=Off")
+
+	overlay.HTTPRoute.SectionName = "missing"
+	_, found = routeOverlayRules(0, overlay, route)
+	assert.False(t, found)
+}
+
+func TestRuleSetReconciler_LoadRouteOverlays(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+
+	tests := []struct {
+		name         string
+		route        string
+		sectionName  string
+		capabilities Capabilities
+		wantDegraded string
+	}{
+		{
+			name:        "route rule",
+			route:       "shop",
+			sectionName: "api",
+		},
+		{
+			name:         "HTTPRoute not found",
+			route:        "missing",
+			sectionName:  "api",
+			wantDegraded: "HTTPRouteNotFound",
+		},
+		{
+			name:         "route rule not found",
+			route:        "shop",
+			sectionName:  "missing",
+			wantDegraded: "RouteRuleNotFound",
+		},
+		{
+			name:         "HTTPRoute API not installed",
+			route:        "shop",
+			sectionName:  "api",
+			capabilities: Capabilities{},
+			wantDegraded: "HTTPRouteAPINotInstalled",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ruleset := &wafv1alpha1.RuleSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "ruleset", Namespace: "team-a", Generation: 1},
+				Spec: wafv1alpha1.RuleSetSpec{RouteOverlays: []wafv1alpha1.RouteOverlay{{
+					Name:          "api",
+					HTTPRoute:     wafv1alpha1.HTTPRouteRuleReference{Name: tt.route, SectionName: tt.sectionName},
+					ParanoiaLevel: 2,
+				}}},
+			}
+			c := fake.NewClientBuilder().WithScheme(scheme).
+				WithObjects(ruleset.DeepCopy(), newTestHTTPRoute(t)).
+				WithStatusSubresource(ruleset).
+				Build()
+			r := &RuleSetReconciler{Client: c, Scheme: scheme, Recorder: utils.NewTestRecorder(), capabilities: tt.capabilities}
+			req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(ruleset)}
+
+			rules, done, err := r.loadRouteOverlays(t.Context(), ctrl.Log, req, ruleset)
+			require.NoError(t, err)
+			if tt.wantDegraded != "" {
+				assert.True(t, done)
+				var got wafv1alpha1.RuleSet
+				require.NoError(t, c.Get(t.Context(), req.NamespacedName, &got))
+				cond := apimeta.FindStatusCondition(got.Status.Conditions, conditionDegraded)
+				require.NotNil(t, cond)
+				assert.Equal(t, tt.wantDegraded, cond.Reason)
+				return
+			}
+			assert.False(t, done)
+			assert.Contains(t, rules, "setvar:tx.blocking_paranoia_level=2")
+		})
+	}
+}
+
+func TestRuleSetReconciler_FindRuleSetsForHTTPRoute(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+
+	overlay := func(route string) []wafv1alpha1.RouteOverlay {
+		return []wafv1alpha1.RouteOverlay{{Name: "overlay", HTTPRoute: wafv1alpha1.HTTPRouteRuleReference{Name: route, SectionName: "api"}}}
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(
+		&wafv1alpha1.RuleSet{ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: "team-a"}, Spec: wafv1alpha1.RuleSetSpec{RouteOverlays: overlay("shop")}},
+		&wafv1alpha1.RuleSet{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "team-a"}, Spec: wafv1alpha1.RuleSetSpec{RouteOverlays: overlay("other")}},
+		&wafv1alpha1.RuleSet{ObjectMeta: metav1.ObjectMeta{Name: "shop", Namespace: "team-b"}, Spec: wafv1alpha1.RuleSetSpec{RouteOverlays: overlay("shop")}},
+		&wafv1alpha1.RuleSet{ObjectMeta: metav1.ObjectMeta{Name: "scoped", Namespace: "team-a"}, Spec: wafv1alpha1.RuleSetSpec{RouteScope: []wafv1alpha1.RouteScope{{Name: "shop"}}}},
+	).Build()
+	r := &RuleSetReconciler{Client: c, Scheme: scheme}
+
+	requests := r.findRuleSetsForHTTPRoute(t.Context(), newTestHTTPRoute(t))
+	var keys []client.ObjectKey
+	for _, req := range requests {
+		keys = append(keys, req.NamespacedName)
+	}
+	assert.ElementsMatch(t, []client.ObjectKey{
+		{Namespace: "team-a", Name: "shop"},
+		{Namespace: "team-a", Name: "scoped"},
+	}, keys)
+}
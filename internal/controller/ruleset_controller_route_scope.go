@@ -0,0 +1,246 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// RuleSet Route Scope - Vars
+// -----------------------------------------------------------------------------
+
+// routeScopeRuleIDBase is the first rule ID of the rules generated for the
+// RuleSet route scope. Match j of rule k of the HTTPRoute at index i of the
+// scope uses the ID routeScopeRuleIDBase+(i+1)*routeScopeMaxRouteMatches+
+// k*routeOverlayMaxMatches+j; RuleSources must not use IDs from this range.
+const routeScopeRuleIDBase = 89550000
+
+// routeScopeMaxRouteMatches is the maximum number of matches of an HTTPRoute
+// allowed by the Gateway API: 16 rules of 64 matches.
+const routeScopeMaxRouteMatches = 16 * routeOverlayMaxMatches
+
+// routeScopeMatchedVar is the transaction variable set by the requests in
+// the route scope.
+const routeScopeMatchedVar = "route_scope_matched"
+
+// GRPCRouteGVK is the GroupVersionKind of the Gateway API GRPCRoute.
+var GRPCRouteGVK = schema.GroupVersionKind{
+	Group:   gatewayGroup,
+	Version: "v1",
+	Kind:    "GRPCRoute",
+}
+
+// routeScopeKinds are the GroupVersionKind and Capability of the kinds of
+// route a route scope entry may select.
+var routeScopeKinds = map[wafv1alpha1.RouteScopeKind]struct {
+	gvk        schema.GroupVersionKind
+	capability Capability
+}{
+	wafv1alpha1.RouteScopeKindHTTPRoute: {HTTPRouteGVK, CapabilityHTTPRoute},
+	wafv1alpha1.RouteScopeKindGRPCRoute: {GRPCRouteGVK, CapabilityGRPCRoute},
+}
+
+// -----------------------------------------------------------------------------
+// RuleSet Route Scope
+// -----------------------------------------------------------------------------
+
+// loadRouteScope returns the SecRules compiled from the route scope of the
+// RuleSet, or an empty string when it has none. A scope entry whose route or
+// route rule does not exist degrades the RuleSet until it is created, rather
+// than inspecting more or fewer requests than intended.
+func (r *RuleSetReconciler) loadRouteScope(
+	ctx context.Context,
+	log logr.Logger,
+	req ctrl.Request,
+	ruleset *wafv1alpha1.RuleSet,
+) (string, bool, error) {
+	if len(ruleset.Spec.RouteScope) == 0 {
+		return "", false, nil
+	}
+
+	logInfo(log, req, "RuleSet", "Loading route scope", "routeScopeCount", len(ruleset.Spec.RouteScope))
+
+	for _, scope := range ruleset.Spec.RouteScope {
+		kind := routeScopeKind(scope)
+		if !r.hasCapability(routeScopeKinds[kind].capability) {
+			msg := fmt.Sprintf("The RuleSet has a routeScope, but the Gateway API %s is not installed in the cluster", kind)
+			if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "RuleSet", ruleset, &ruleset.Status.Conditions, ruleset.Generation, string(kind)+"APINotInstalled", msg); patchErr != nil {
+				return "", true, patchErr
+			}
+			return "", true, nil
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("# Route scope generated from the RuleSet spec.routeScope\n")
+	fmt.Fprintf(&b, "SecAction \"id:%d,phase:1,pass,nolog,t:none,setvar:tx.%s=0\"\n", routeScopeRuleIDBase, routeScopeMatchedVar)
+	for i, scope := range ruleset.Spec.RouteScope {
+		kind := routeScopeKind(scope)
+		route := &unstructured.Unstructured{}
+		route.SetGroupVersionKind(routeScopeKinds[kind].gvk)
+		if err := r.Get(ctx, types.NamespacedName{Namespace: ruleset.Namespace, Name: scope.Name}, route); err != nil {
+			if apierrors.IsNotFound(err) {
+				logInfo(log, req, "RuleSet", "Route of route scope not found", "kind", kind, "routeName", scope.Name)
+				msg := fmt.Sprintf("%s %s of the route scope does not exist", kind, scope.Name)
+				if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "RuleSet", ruleset, &ruleset.Status.Conditions, ruleset.Generation, string(kind)+"NotFound", msg); patchErr != nil {
+					return "", true, patchErr
+				}
+				return "", true, nil
+			}
+			logError(log, req, "RuleSet", err, "Failed to get route", "kind", kind, "routeName", scope.Name)
+			msg := fmt.Sprintf("Failed to access %s %s of the route scope: %v", kind, scope.Name, err)
+			if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "RuleSet", ruleset, &ruleset.Status.Conditions, ruleset.Generation, string(kind)+"AccessError", msg); patchErr != nil {
+				return "", true, patchErr
+			}
+			return "", true, err
+		}
+
+		rules, found := routeScopeRules(i, scope, route)
+		if !found {
+			logInfo(log, req, "RuleSet", "Route rule of route scope not found", "kind", kind, "routeName", scope.Name, "sectionName", scope.SectionName)
+			msg := fmt.Sprintf("%s %s has no rule named %s, in the route scope", kind, scope.Name, scope.SectionName)
+			if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "RuleSet", ruleset, &ruleset.Status.Conditions, ruleset.Generation, "RouteRuleNotFound", msg); patchErr != nil {
+				return "", true, patchErr
+			}
+			return "", true, nil
+		}
+		b.WriteString(rules)
+	}
+	fmt.Fprintf(&b, "SecRule TX:%s \"@eq 0\" \"id:%d,phase:1,pass,nolog,t:none,msg:'RuleSet route scope',ctl:ruleEngine=Off\"\n",
+		routeScopeMatchedVar, routeScopeRuleIDBase+1)
+
+	return b.String(), false, nil
+}
+
+// routeScopeKind returns the kind of route of scope, which defaults to
+// HTTPRoute.
+func routeScopeKind(scope wafv1alpha1.RouteScope) wafv1alpha1.RouteScopeKind {
+	if scope.Kind == "" {
+		return wafv1alpha1.RouteScopeKindHTTPRoute
+	}
+	return scope.Kind
+}
+
+// routeScopeRules returns the SecRules marking the requests matched by the
+// scope entry at index i of the RuleSet: one chained rule per match of each
+// selected rule of route. It reports false when the entry has a sectionName
+// and route has no rule of that name.
+func routeScopeRules(i int, scope wafv1alpha1.RouteScope, route *unstructured.Unstructured) (string, bool) {
+	msg := fmt.Sprintf("msg:'RuleSet route scope %s'", scope.Name)
+	actions := fmt.Sprintf("setvar:tx.%s=1", routeScopeMatchedVar)
+	firstID := routeScopeRuleIDBase + (i+1)*routeScopeMaxRouteMatches
+	matchConditions := httpRouteMatchConditions
+	if routeScopeKind(scope) == wafv1alpha1.RouteScopeKindGRPCRoute {
+		matchConditions = grpcRouteMatchConditions
+	}
+
+	var b strings.Builder
+	found := scope.SectionName == ""
+	rules, _, _ := unstructured.NestedSlice(route.Object, "spec", "rules")
+	for k, r := range rules {
+		if k == routeScopeMaxRouteMatches/routeOverlayMaxMatches {
+			break
+		}
+		rule, ok := r.(map[string]any)
+		if !ok {
+			continue
+		}
+		if scope.SectionName != "" {
+			if name, _, _ := unstructured.NestedString(rule, "name"); name != scope.SectionName {
+				continue
+			}
+			found = true
+		}
+		writeRouteRuleMatches(&b, firstID+k*routeOverlayMaxMatches, msg, actions, route, rule, matchConditions)
+	}
+	return b.String(), found
+}
+
+// grpcRouteMatchConditions returns the variable and operator of the SecRule
+// conditions matching a gRPC request and the method and headers of a
+// GRPCRoute match. gRPC requests are POST requests to /service/method with a
+// Content-Type of application/grpc.
+func grpcRouteMatchConditions(match map[string]any) [][]string {
+	conditions := [][]string{{"REQUEST_HEADERS:Content-Type", "@rx (?i)^application/grpc(?:[+;]|$)"}}
+
+	service, _, _ := unstructured.NestedString(match, "method", "service")
+	method, _, _ := unstructured.NestedString(match, "method", "method")
+	if service != "" || method != "" {
+		servicePattern, methodPattern := "[^/]+", "[^/]+"
+		if matchType, _, _ := unstructured.NestedString(match, "method", "type"); matchType == "RegularExpression" {
+			if service != "" {
+				servicePattern = "(?:" + service + ")"
+			}
+			if method != "" {
+				methodPattern = "(?:" + method + ")"
+			}
+		} else {
+			if service != "" {
+				servicePattern = regexp.QuoteMeta(service)
+			}
+			if method != "" {
+				methodPattern = regexp.QuoteMeta(method)
+			}
+		}
+		conditions = append(conditions, []string{"REQUEST_FILENAME", "@rx " + secRulePattern("^/"+servicePattern+"/"+methodPattern+"$")})
+	}
+
+	headers, _, _ := unstructured.NestedSlice(match, "headers")
+	for _, h := range headers {
+		if header, ok := h.(map[string]any); ok {
+			conditions = append(conditions, httpRouteValueCondition("REQUEST_HEADERS", header))
+		}
+	}
+
+	return conditions
+}
+
+// findRuleSetsForGRPCRoute maps a GRPCRoute to the RuleSets in its namespace
+// with it in their route scope.
+func (r *RuleSetReconciler) findRuleSetsForGRPCRoute(ctx context.Context, route client.Object) []reconcile.Request {
+	log := logf.FromContext(ctx)
+
+	var ruleSetList wafv1alpha1.RuleSetList
+	if err := r.List(ctx, &ruleSetList, client.InNamespace(route.GetNamespace())); err != nil {
+		log.Error(err, "RuleSet: Failed to list RuleSets", "namespace", route.GetNamespace())
+		return nil
+	}
+	return collectRequests(ruleSetList.Items, func(rs *wafv1alpha1.RuleSet) bool {
+		for _, scope := range rs.Spec.RouteScope {
+			if routeScopeKind(scope) == wafv1alpha1.RouteScopeKindGRPCRoute && scope.Name == route.GetName() {
+				return true
+			}
+		}
+		return false
+	})
+}
@@ -0,0 +1,231 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"testing"
+
+	"github.com/corazawaf/coraza/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/test/utils"
+)
+
+func newTestGRPCRoute(hostnames ...string) *unstructured.Unstructured {
+	route := &unstructured.Unstructured{Object: map[string]any{
+		"spec": map[string]any{
+			"rules": []any{
+				map[string]any{
+					"name": "orders",
+					"matches": []any{
+						map[string]any{"method": map[string]any{"service": "shop.Orders"}},
+					},
+				},
+				map[string]any{
+					"name": "admin",
+					"matches": []any{
+						map[string]any{"method": map[string]any{"type": "RegularExpression", "service": `shop\.(Admin|Audit)`}},
+					},
+				},
+			},
+		},
+	}}
+	route.SetGroupVersionKind(GRPCRouteGVK)
+	route.SetNamespace("team-a")
+	route.SetName("orders")
+	if len(hostnames) > 0 {
+		_ = unstructured.SetNestedStringSlice(route.Object, hostnames, "spec", "hostnames")
+	}
+	return route
+}
+
+func TestRuleSetReconciler_LoadRouteScope(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+
+	const denyRules = `SecRuleEngine On
+SecRule REQUEST_URI "@contains attack" "id:1,phase:1,deny,status:403"`
+
+	tests := []struct {
+		name         string
+		scope        []wafv1alpha1.RouteScope
+		overlays     []wafv1alpha1.RouteOverlay
+		capabilities Capabilities
+		hostnames    []string
+		method       string
+		uri          string
+		headers      map[string]string
+		blocked      bool
+		wantDegraded string
+	}{
+		{
+			name:    "route rule in scope",
+			scope:   []wafv1alpha1.RouteScope{{Name: "shop", SectionName: "api"}},
+			method:  "POST",
+			uri:     "/api/attack",
+			blocked: true,
+		},
+		{
+			name:   "route rule out of scope",
+			scope:  []wafv1alpha1.RouteScope{{Name: "shop", SectionName: "api"}},
+			method: "GET",
+			uri:    "/static/attack",
+		},
+		{
+			name:    "every rule of the route",
+			scope:   []wafv1alpha1.RouteScope{{Name: "shop"}},
+			method:  "GET",
+			uri:     "/static/attack",
+			blocked: true,
+		},
+		{
+			name:      "other hostname",
+			scope:     []wafv1alpha1.RouteScope{{Name: "shop"}},
+			hostnames: []string{"shop.example.com"},
+			method:    "GET",
+			uri:       "/static/attack",
+			headers:   map[string]string{"Host": "admin.example.com"},
+		},
+		{
+			name:  "route overlay out of scope",
+			scope: []wafv1alpha1.RouteScope{{Name: "shop", SectionName: "api"}},
+			overlays: []wafv1alpha1.RouteOverlay{{
+				Name:       "static",
+				HTTPRoute:  wafv1alpha1.HTTPRouteRuleReference{Name: "shop", SectionName: "static"},
+				RuleEngine: wafv1alpha1.RouteOverlayRuleEngineOn,
+			}},
+			method:  "GET",
+			uri:     "/static/attack",
+			blocked: true,
+		},
+		{
+			name:    "GRPCRoute service in scope",
+			scope:   []wafv1alpha1.RouteScope{{Kind: wafv1alpha1.RouteScopeKindGRPCRoute, Name: "orders"}},
+			method:  "POST",
+			uri:     "/shop.Orders/attack",
+			headers: map[string]string{"Content-Type": "application/grpc"},
+			blocked: true,
+		},
+		{
+			name:    "GRPCRoute method in scope",
+			scope:   []wafv1alpha1.RouteScope{{Kind: wafv1alpha1.RouteScopeKindGRPCRoute, Name: "orders", SectionName: "admin"}},
+			method:  "POST",
+			uri:     "/shop.Admin/attack",
+			headers: map[string]string{"Content-Type": "application/grpc+proto"},
+			blocked: true,
+		},
+		{
+			name:    "GRPCRoute service out of scope",
+			scope:   []wafv1alpha1.RouteScope{{Kind: wafv1alpha1.RouteScopeKindGRPCRoute, Name: "orders", SectionName: "orders"}},
+			method:  "POST",
+			uri:     "/shop.Users/attack",
+			headers: map[string]string{"Content-Type": "application/grpc"},
+		},
+		{
+			name:   "GRPCRoute without gRPC request",
+			scope:  []wafv1alpha1.RouteScope{{Kind: wafv1alpha1.RouteScopeKindGRPCRoute, Name: "orders"}},
+			method: "POST",
+			uri:    "/shop.Orders/attack",
+		},
+		{
+			name:         "GRPCRoute not found",
+			scope:        []wafv1alpha1.RouteScope{{Kind: wafv1alpha1.RouteScopeKindGRPCRoute, Name: "shop"}},
+			wantDegraded: "GRPCRouteNotFound",
+		},
+		{
+			name:         "GRPCRoute API not installed",
+			scope:        []wafv1alpha1.RouteScope{{Name: "shop"}, {Kind: wafv1alpha1.RouteScopeKindGRPCRoute, Name: "orders"}},
+			capabilities: Capabilities{CapabilityHTTPRoute: true},
+			wantDegraded: "GRPCRouteAPINotInstalled",
+		},
+		{
+			name:         "HTTPRoute not found",
+			scope:        []wafv1alpha1.RouteScope{{Name: "missing"}},
+			wantDegraded: "HTTPRouteNotFound",
+		},
+		{
+			name:         "route rule not found",
+			scope:        []wafv1alpha1.RouteScope{{Name: "shop", SectionName: "missing"}},
+			wantDegraded: "RouteRuleNotFound",
+		},
+		{
+			name:         "HTTPRoute API not installed",
+			scope:        []wafv1alpha1.RouteScope{{Name: "shop"}},
+			capabilities: Capabilities{},
+			wantDegraded: "HTTPRouteAPINotInstalled",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ruleset := &wafv1alpha1.RuleSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "ruleset", Namespace: "team-a", Generation: 1},
+				Spec:       wafv1alpha1.RuleSetSpec{RouteScope: tt.scope, RouteOverlays: tt.overlays},
+			}
+			route := newTestHTTPRoute(t, tt.hostnames...)
+			c := fake.NewClientBuilder().WithScheme(scheme).
+				WithObjects(ruleset.DeepCopy(), route, newTestGRPCRoute(tt.hostnames...)).
+				WithStatusSubresource(ruleset).
+				Build()
+			r := &RuleSetReconciler{Client: c, Scheme: scheme, Recorder: utils.NewTestRecorder(), capabilities: tt.capabilities}
+			req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(ruleset)}
+
+			rules, done, err := r.loadRouteScope(t.Context(), ctrl.Log, req, ruleset)
+			require.NoError(t, err)
+			if tt.wantDegraded != "" {
+				assert.True(t, done)
+				var got wafv1alpha1.RuleSet
+				require.NoError(t, c.Get(t.Context(), req.NamespacedName, &got))
+				cond := apimeta.FindStatusCondition(got.Status.Conditions, conditionDegraded)
+				require.NotNil(t, cond)
+				assert.Equal(t, tt.wantDegraded, cond.Reason)
+				return
+			}
+			require.False(t, done)
+
+			for i, overlay := range tt.overlays {
+				overlayRules, found := routeOverlayRules(i, overlay, route)
+				require.True(t, found)
+				rules += overlayRules
+			}
+			waf, err := coraza.NewWAF(coraza.NewWAFConfig().WithDirectives(rules + denyRules))
+			require.NoError(t, err)
+
+			tx := waf.NewTransaction()
+			defer func() { _ = tx.Close() }()
+			tx.ProcessURI(tt.uri, tt.method, "HTTP/1.1")
+			for k, v := range tt.headers {
+				tx.AddRequestHeader(k, v)
+			}
+			interruption := tx.ProcessRequestHeaders()
+			if tt.blocked {
+				assert.NotNil(t, interruption, "request should be blocked")
+			} else {
+				assert.Nil(t, interruption, "request should not be blocked")
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/corazawaf/coraza/v3"
 	"github.com/go-logr/logr"
@@ -13,23 +14,34 @@ import (
 	ctrl "sigs.k8s.io/controller-runtime"
 
 	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/internal/references"
+	"github.com/networking-incubator/coraza-kubernetes-operator/internal/rulesources"
 )
 
 // -----------------------------------------------------------------------------
 // RuleSetReconciler - Reference Validation
 // -----------------------------------------------------------------------------
 
-// findDuplicateReferences checks for duplicate RuleSource names in spec.sources
-// and duplicate RuleData names in spec.data. Returns a descriptive message if
-// any duplicates are found, or empty string if all references are unique.
+// findDuplicateReferences checks for duplicate RuleSource references in
+// spec.sources and duplicate RuleData references in spec.data. Returns a
+// descriptive message if any duplicates are found, or empty string if all
+// references are unique.
 func findDuplicateReferences(ruleset *wafv1alpha1.RuleSet) string {
 	var msgs []string
 
-	if dups := findDuplicateNames(ruleset.Spec.Sources, func(s wafv1alpha1.SourceReference) string { return s.Name }); len(dups) > 0 {
+	if dups := findDuplicateNames(ruleset.Spec.Sources, func(s wafv1alpha1.SourceReference) string {
+		name := referenceName(ruleset, s.Namespace, s.Name)
+		if !isRuleSourceReference(s) {
+			name = s.Kind + " " + name
+		}
+		return name
+	}); len(dups) > 0 {
 		msgs = append(msgs, fmt.Sprintf("spec.sources contains duplicate RuleSource name(s): %s", strings.Join(dups, ", ")))
 	}
 
-	if dups := findDuplicateNames(ruleset.Spec.Data, func(d wafv1alpha1.DataReference) string { return d.Name }); len(dups) > 0 {
+	if dups := findDuplicateNames(ruleset.Spec.Data, func(d wafv1alpha1.DataReference) string {
+		return referenceName(ruleset, d.Namespace, d.Name)
+	}); len(dups) > 0 {
 		msgs = append(msgs, fmt.Sprintf("spec.data contains duplicate RuleData name(s): %s", strings.Join(dups, ", ")))
 	}
 
@@ -53,6 +65,21 @@ func findDuplicateNames[T any](items []T, name func(T) string) []string {
 	return dups
 }
 
+// referenceName returns the name of a RuleSource or RuleData reference as
+// shown in status messages: "namespace/name" when it is in another namespace
+// than the RuleSet, and its name otherwise.
+func referenceName(ruleset *wafv1alpha1.RuleSet, namespace, name string) string {
+	if namespace == "" || namespace == ruleset.Namespace {
+		return name
+	}
+	return namespace + "/" + name
+}
+
+// ruleSetReferrer identifies the RuleSet to the reference Resolver.
+func ruleSetReferrer(ruleset *wafv1alpha1.RuleSet) references.From {
+	return references.From{Group: wafv1alpha1.GroupVersion.Group, Kind: "RuleSet", Namespace: ruleset.Namespace}
+}
+
 // -----------------------------------------------------------------------------
 // RuleSetReconciler - Data Loading
 // -----------------------------------------------------------------------------
@@ -71,23 +98,33 @@ func (r *RuleSetReconciler) loadData(
 
 	logInfo(log, req, "RuleSet", "Loading data", "dataCount", len(ruleset.Spec.Data))
 
+	resolver := r.resolver()
 	dataFiles := make(map[string][]byte)
 	for _, ref := range ruleset.Spec.Data {
+		name := referenceName(ruleset, ref.Namespace, ref.Name)
 		var rd wafv1alpha1.RuleData
-		if err := r.Get(ctx, types.NamespacedName{
+		if err := resolver.Get(ctx, ruleSetReferrer(ruleset), types.NamespacedName{
 			Name:      ref.Name,
-			Namespace: ruleset.Namespace,
+			Namespace: referenceNamespace(ruleset, ref.Namespace),
 		}, &rd); err != nil {
+			if references.IsNotPermitted(err) {
+				logInfo(log, req, "RuleSet", "Reference to RuleData not permitted; waiting for a grant", "ruleDataName", name)
+				msg := fmt.Sprintf("Reference to RuleData %s not permitted: %v", name, err)
+				if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "RuleSet", ruleset, &ruleset.Status.Conditions, ruleset.Generation, references.ReasonRefNotPermitted, msg); patchErr != nil {
+					return nil, true, patchErr
+				}
+				return nil, true, nil
+			}
 			if apierrors.IsNotFound(err) {
-				logInfo(log, req, "RuleSet", "Referenced RuleData not found; waiting for it to appear", "ruleDataName", ref.Name)
-				msg := fmt.Sprintf("Referenced RuleData %s does not exist", ref.Name)
+				logInfo(log, req, "RuleSet", "Referenced RuleData not found; waiting for it to appear", "ruleDataName", name)
+				msg := fmt.Sprintf("Referenced RuleData %s does not exist", name)
 				if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "RuleSet", ruleset, &ruleset.Status.Conditions, ruleset.Generation, "RuleDataNotFound", msg); patchErr != nil {
 					return nil, true, patchErr
 				}
 				return nil, true, nil
 			}
-			logError(log, req, "RuleSet", err, "Failed to get RuleData", "ruleDataName", ref.Name)
-			msg := fmt.Sprintf("Failed to access RuleData %s: %v", ref.Name, err)
+			logError(log, req, "RuleSet", err, "Failed to get RuleData", "ruleDataName", name)
+			msg := fmt.Sprintf("Failed to access RuleData %s: %v", name, err)
 			if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "RuleSet", ruleset, &ruleset.Status.Conditions, ruleset.Generation, "RuleDataAccessError", msg); patchErr != nil {
 				return nil, true, patchErr
 			}
@@ -106,62 +143,102 @@ func (r *RuleSetReconciler) loadData(
 // RuleSetReconciler - Source Loading
 // -----------------------------------------------------------------------------
 
-// loadSources fetches all RuleSource objects referenced by the RuleSet,
-// concatenates their rules in order, and validates each fragment individually.
-// dataFiles is passed through so @pmFromFile errors can be properly skipped.
+// loadSources fetches the rules of the sources referenced by the RuleSet from
+// the provider of their kind, concatenates them in order, and validates each
+// fragment individually. dataFiles is passed through so @pmFromFile errors
+// can be properly skipped. It also returns when the first source that is not
+// watched must be fetched again, or zero. Missing sources are all reported
+// in a single SourceMissing condition, and the RuleSet is reconciled again
+// when the first of them is due to be fetched again.
 func (r *RuleSetReconciler) loadSources(
 	ctx context.Context,
 	log logr.Logger,
 	req ctrl.Request,
 	ruleset *wafv1alpha1.RuleSet,
 	dataFiles map[string][]byte,
-) (string, []error, bool, error) {
+) (string, []error, time.Duration, bool, error) {
 	logInfo(log, req, "RuleSet", "Loading sources", "sourceCount", len(ruleset.Spec.Sources))
 
 	type ruleFragment struct {
-		name           string
+		ref            rulesources.Reference
 		rules          string
 		shouldValidate bool
 	}
 	ruleFragments := make([]ruleFragment, 0, len(ruleset.Spec.Sources))
 
+	from := ruleSetReferrer(ruleset)
+	providers := rulesources.Providers(rulesources.Env{Resolver: r.resolver()})
+	var refreshAfter time.Duration
+	var missing []rulesources.Reference
 	for _, src := range ruleset.Spec.Sources {
-		var rs wafv1alpha1.RuleSource
-		if err := r.Get(ctx, types.NamespacedName{
-			Name:      src.Name,
-			Namespace: ruleset.Namespace,
-		}, &rs); err != nil {
-			if apierrors.IsNotFound(err) {
-				logInfo(log, req, "RuleSet", "Referenced RuleSource not found; waiting for it to appear", "ruleSourceName", src.Name)
-				msg := fmt.Sprintf("Referenced RuleSource %s does not exist", src.Name)
-				if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "RuleSet", ruleset, &ruleset.Status.Conditions, ruleset.Generation, "RuleSourceNotFound", msg); patchErr != nil {
-					return "", nil, true, patchErr
-				}
-				return "", nil, true, nil
+		ref := sourceReference(ruleset, src)
+		name := rulesources.DisplayName(from, ref)
+
+		// Sources found missing are not fetched again until their backoff
+		// expires, and all of them are reported at once.
+		now := time.Now()
+		if r.missingSources.skip(ref, now) {
+			missing = append(missing, ref)
+			continue
+		}
+
+		var fragment rulesources.Fragment
+		var err error
+		epoch := r.missingSources.begin()
+		if provider, ok := providers[ref.Kind]; ok {
+			fragment, err = provider.Fetch(ctx, from, ref)
+		} else {
+			err = rulesources.Unsupported(from, ref)
+		}
+		if rulesources.IsNotFound(err) {
+			r.missingSources.record(ref, now, epoch)
+			missing = append(missing, ref)
+			continue
+		}
+		if err != nil {
+			fetchErr := rulesources.AsError(err)
+			if fetchErr == nil {
+				fetchErr = rulesources.AccessError(from, ref, err)
+			}
+			if fetchErr.Err != nil {
+				logError(log, req, "RuleSet", fetchErr.Err, "Failed to fetch source", "kind", ref.Kind, "sourceName", name)
+			} else {
+				logInfo(log, req, "RuleSet", "Source not available; waiting for it to change", "kind", ref.Kind, "sourceName", name, "reason", fetchErr.Reason)
 			}
-			logError(log, req, "RuleSet", err, "Failed to get RuleSource", "ruleSourceName", src.Name)
-			msg := fmt.Sprintf("Failed to access RuleSource %s: %v", src.Name, err)
-			if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "RuleSet", ruleset, &ruleset.Status.Conditions, ruleset.Generation, "RuleSourceAccessError", msg); patchErr != nil {
-				return "", nil, true, patchErr
+			if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "RuleSet", ruleset, &ruleset.Status.Conditions, ruleset.Generation, fetchErr.Reason, fetchErr.Error()); patchErr != nil {
+				return "", nil, 0, true, patchErr
 			}
-			return "", nil, true, err
+			return "", nil, 0, true, fetchErr.Err
 		}
 
-		shouldValidate := rs.Annotations[wafv1alpha1.AnnotationSkipValidation] != "false"
+		for _, warning := range fragment.Warnings {
+			logInfo(log, req, "RuleSet", "Source partially loaded", "kind", ref.Kind, "sourceName", name, "warning", warning)
+			r.Recorder.Eventf(ruleset, nil, "Warning", "SourcePartiallyLoaded", "Reconcile", truncateEventNote(warning))
+		}
+		if fragment.RefreshAfter > 0 && (refreshAfter == 0 || fragment.RefreshAfter < refreshAfter) {
+			refreshAfter = fragment.RefreshAfter
+		}
 		ruleFragments = append(ruleFragments, ruleFragment{
-			name:           src.Name,
-			rules:          rs.Spec.Rules,
-			shouldValidate: shouldValidate,
+			ref:            ref,
+			rules:          fragment.Rules,
+			shouldValidate: fragment.Validate,
 		})
+		r.missingSources.found(ref)
 	}
 
+	if len(missing) > 0 {
+		retryAfter, err := r.patchSourceMissing(ctx, log, req, ruleset, missing)
+		return "", nil, retryAfter, true, err
+	}
+	r.missingSources.report(req.NamespacedName, "")
+
 	var aggregatedRules strings.Builder
 	aggregatedErrors := make([]error, 0)
 
 	for i, frag := range ruleFragments {
 		if frag.shouldValidate {
-			if validationErr := validateRuleSourceRules(frag.rules, frag.name, dataFiles); validationErr != nil {
-				logDebug(log, req, "RuleSet", "RuleSource validation issue recorded", "ruleSourceName", frag.name, "error", validationErr.Error())
+			if validationErr := validateSourceRules(frag.rules, frag.ref.Kind, rulesources.DisplayName(from, frag.ref), dataFiles); validationErr != nil {
+				logDebug(log, req, "RuleSet", "Source validation issue recorded", "kind", frag.ref.Kind, "sourceName", frag.ref.Name, "error", validationErr.Error())
 				aggregatedErrors = append(aggregatedErrors, validationErr)
 			}
 		}
@@ -172,17 +249,32 @@ func (r *RuleSetReconciler) loadSources(
 		}
 	}
 
-	return aggregatedRules.String(), aggregatedErrors, false, nil
+	return aggregatedRules.String(), aggregatedErrors, refreshAfter, false, nil
+}
+
+// sourceReference returns the provider reference of a source of the RuleSet,
+// defaulting its kind and namespace.
+func sourceReference(ruleset *wafv1alpha1.RuleSet, src wafv1alpha1.SourceReference) rulesources.Reference {
+	kind := src.Kind
+	if kind == "" {
+		kind = rulesources.KindRuleSource
+	}
+	return rulesources.Reference{Kind: kind, Namespace: referenceNamespace(ruleset, src.Namespace), Name: src.Name}
+}
+
+// isRuleSourceReference reports whether a source of a RuleSet is a RuleSource.
+func isRuleSourceReference(src wafv1alpha1.SourceReference) bool {
+	return src.Kind == "" || src.Kind == rulesources.KindRuleSource
 }
 
-// validateRuleSourceRules validates a single RuleSource's rules via Coraza.
-func validateRuleSourceRules(data, ruleSourceName string, dataFiles map[string][]byte) error {
+// validateSourceRules validates the rules of a single source via Coraza.
+func validateSourceRules(data, kind, sourceName string, dataFiles map[string][]byte) error {
 	conf := coraza.NewWAFConfig().WithDirectives(data)
 	if _, err := coraza.NewWAF(conf); err != nil {
 		if shouldSkipMissingFileError(err, dataFiles) {
 			return nil
 		}
-		return fmt.Errorf("RuleSource %s doesn't contain valid rules: %w", ruleSourceName, sanitizeErrorMessage(err))
+		return fmt.Errorf("%s %s doesn't contain valid rules: %w", kind, sourceName, sanitizeErrorMessage(err))
 	}
 	return nil
 }
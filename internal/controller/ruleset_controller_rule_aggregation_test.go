@@ -0,0 +1,199 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/internal/references"
+	"github.com/networking-incubator/coraza-kubernetes-operator/internal/rulesources"
+	"github.com/networking-incubator/coraza-kubernetes-operator/test/utils"
+)
+
+// staticSourceKind is the kind of the sources served by staticProvider, as an
+// out-of-tree provider would be.
+const staticSourceKind = "TestStatic"
+
+// staticProvider serves the name of its sources as a SecAction, and is polled
+// every minute.
+type staticProvider struct{}
+
+func (staticProvider) Fetch(_ context.Context, _ references.From, ref rulesources.Reference) (rulesources.Fragment, error) {
+	return rulesources.Fragment{
+		Rules:        `SecAction "id:` + ref.Name + `,phase:1,pass,nolog"`,
+		Validate:     true,
+		RefreshAfter: time.Minute,
+	}, nil
+}
+
+func init() {
+	rulesources.Register(staticSourceKind, func(rulesources.Env) rulesources.Provider { return staticProvider{} })
+}
+
+func TestRuleSetReconciler_LoadSources(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+
+	crs := &wafv1alpha1.RuleSource{
+		ObjectMeta: metav1.ObjectMeta{Name: "crs", Namespace: "team-a"},
+		Spec:       wafv1alpha1.RuleSourceSpec{Rules: "SecRuleEngine On"},
+	}
+
+	tests := []struct {
+		name         string
+		sources      []wafv1alpha1.SourceReference
+		wantRules    string
+		wantRefresh  time.Duration
+		wantDegraded string
+	}{
+		{
+			name:      "RuleSource by default",
+			sources:   []wafv1alpha1.SourceReference{{Name: "crs"}},
+			wantRules: "SecRuleEngine On",
+		},
+		{
+			name:        "registered provider",
+			sources:     []wafv1alpha1.SourceReference{{Kind: rulesources.KindRuleSource, Name: "crs"}, {Kind: staticSourceKind, Name: "1000"}},
+			wantRules:   "SecRuleEngine On\n" + `SecAction "id:1000,phase:1,pass,nolog"`,
+			wantRefresh: time.Minute,
+		},
+		{
+			name:         "unsupported kind",
+			sources:      []wafv1alpha1.SourceReference{{Kind: "GitRepository", Name: "rules"}},
+			wantDegraded: rulesources.ReasonUnsupportedSourceKind,
+		},
+		{
+			name:         "missing RuleSource",
+			sources:      []wafv1alpha1.SourceReference{{Name: "missing"}},
+			wantDegraded: "SourceMissing",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ruleset := &wafv1alpha1.RuleSet{
+				ObjectMeta: metav1.ObjectMeta{Name: "ruleset", Namespace: "team-a", Generation: 1},
+				Spec:       wafv1alpha1.RuleSetSpec{Sources: tt.sources},
+			}
+			c := fake.NewClientBuilder().WithScheme(scheme).
+				WithObjects(crs.DeepCopy(), ruleset.DeepCopy()).
+				WithStatusSubresource(ruleset).
+				Build()
+			r := &RuleSetReconciler{Client: c, Scheme: scheme, Recorder: utils.NewTestRecorder()}
+			req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(ruleset)}
+
+			rules, validationErrs, refresh, done, err := r.loadSources(t.Context(), ctrl.Log, req, ruleset, nil)
+			require.NoError(t, err)
+			if tt.wantDegraded != "" {
+				assert.True(t, done)
+				var got wafv1alpha1.RuleSet
+				require.NoError(t, c.Get(t.Context(), req.NamespacedName, &got))
+				cond := apimeta.FindStatusCondition(got.Status.Conditions, conditionDegraded)
+				require.NotNil(t, cond)
+				assert.Equal(t, tt.wantDegraded, cond.Reason)
+				return
+			}
+			assert.False(t, done)
+			assert.Empty(t, validationErrs)
+			assert.Equal(t, tt.wantRules, rules)
+			assert.Equal(t, tt.wantRefresh, refresh)
+		})
+	}
+}
+
+func TestRuleSetReconciler_MissingSources(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+
+	ruleset := &wafv1alpha1.RuleSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "ruleset", Namespace: "team-a", Generation: 1},
+		Spec: wafv1alpha1.RuleSetSpec{Sources: []wafv1alpha1.SourceReference{
+			{Name: "crs"}, {Name: "custom"}, {Name: "extra"},
+		}},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(ruleset.DeepCopy()).
+		WithStatusSubresource(ruleset).
+		Build()
+	recorder := utils.NewFakeRecorder()
+	r := &RuleSetReconciler{Client: c, Scheme: scheme, Recorder: recorder, missingSources: newMissingSourceCache()}
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(ruleset)}
+
+	t.Log("Reporting every missing source in a single condition")
+	_, _, retryAfter, done, err := r.loadSources(t.Context(), ctrl.Log, req, ruleset, nil)
+	require.NoError(t, err)
+	assert.True(t, done)
+	assert.InDelta(t, missingSourceInitialBackoff, retryAfter, float64(time.Second))
+	cond := apimeta.FindStatusCondition(ruleset.Status.Conditions, conditionDegraded)
+	require.NotNil(t, cond)
+	assert.Equal(t, "SourceMissing", cond.Reason)
+	assert.Equal(t, "Referenced sources do not exist: RuleSource crs, RuleSource custom, RuleSource extra", cond.Message)
+	require.Len(t, recorder.Events, 1)
+
+	t.Log("Not fetching the missing sources again until their backoff expires")
+	require.NoError(t, c.Create(t.Context(), &wafv1alpha1.RuleSource{
+		ObjectMeta: metav1.ObjectMeta{Name: "custom", Namespace: "team-a"},
+		Spec:       wafv1alpha1.RuleSourceSpec{Rules: "SecRuleEngine On"},
+	}))
+	_, _, _, done, err = r.loadSources(t.Context(), ctrl.Log, req, ruleset, nil)
+	require.NoError(t, err)
+	assert.True(t, done)
+	assert.Len(t, recorder.Events, 1, "an unchanged condition is not recorded again")
+
+	t.Log("Fetching a source again once the watch reports it")
+	r.missingSources.forget(rulesources.Reference{Kind: rulesources.KindRuleSource, Namespace: "team-a", Name: "custom"})
+	_, _, _, done, err = r.loadSources(t.Context(), ctrl.Log, req, ruleset, nil)
+	require.NoError(t, err)
+	assert.True(t, done)
+	cond = apimeta.FindStatusCondition(ruleset.Status.Conditions, conditionDegraded)
+	require.NotNil(t, cond)
+	assert.Equal(t, "Referenced sources do not exist: RuleSource crs, RuleSource extra", cond.Message)
+	assert.Len(t, recorder.Events, 2)
+}
+
+func TestMissingSourceBackoff(t *testing.T) {
+	assert.Equal(t, 5*time.Second, missingSourceBackoff(1))
+	assert.Equal(t, 10*time.Second, missingSourceBackoff(2))
+	assert.Equal(t, 160*time.Second, missingSourceBackoff(6))
+	assert.Equal(t, missingSourceMaxBackoff, missingSourceBackoff(100))
+
+	c := newMissingSourceCache()
+	ref := rulesources.Reference{Kind: "Git", Namespace: "team-a", Name: "rules"}
+	now := time.Now()
+	epoch := c.begin()
+	c.forget(ref)
+	c.record(ref, now, epoch)
+	assert.False(t, c.skip(ref, now), "a result raced by an invalidation is discarded")
+
+	c.record(ref, now, c.begin())
+	c.record(ref, now, c.begin())
+	assert.True(t, c.skip(ref, now.Add(9*time.Second)))
+	assert.False(t, c.skip(ref, now.Add(10*time.Second)))
+	assert.Equal(t, 10*time.Second, c.retryAfter([]rulesources.Reference{ref}, now))
+}
@@ -0,0 +1,163 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/internal/rulesets/cache"
+)
+
+// -----------------------------------------------------------------------------
+// RuleSet Snapshots - Vars
+// -----------------------------------------------------------------------------
+
+const (
+	// maxRuleSetSnapshots is the number of RuleSetSnapshots kept per
+	// RuleSet; the oldest are deleted.
+	maxRuleSetSnapshots = 20
+
+	// snapshotRuleSetLabel is the label holding the name of the RuleSet of a
+	// RuleSetSnapshot.
+	snapshotRuleSetLabel = "waf.k8s.coraza.io/ruleset-name"
+)
+
+// -----------------------------------------------------------------------------
+// RuleSet Snapshots
+// -----------------------------------------------------------------------------
+
+// recordSnapshot creates the RuleSetSnapshot of the revision id of ruleset,
+// published to the cache with rules and dataFiles, when it does not exist
+// yet, and deletes the oldest snapshots of ruleset beyond
+// maxRuleSetSnapshots. Only the leader writes snapshots, like the status.
+func (r *RuleSetReconciler) recordSnapshot(
+	ctx context.Context,
+	log logr.Logger,
+	req ctrl.Request,
+	ruleset *wafv1alpha1.RuleSet,
+	cacheKey, id string,
+	rules string,
+	dataFiles map[string][]byte,
+) error {
+	if !r.isLeader() {
+		return nil
+	}
+
+	name := cache.SnapshotName(cacheKey, id)
+	var existing wafv1alpha1.RuleSetSnapshot
+	err := r.Get(ctx, types.NamespacedName{Namespace: ruleset.Namespace, Name: name}, &existing)
+	if err == nil {
+		return nil
+	}
+	if !apierrors.IsNotFound(err) {
+		logAPIError(log, req, "RuleSet", err, "Failed to get RuleSetSnapshot", nil)
+		return err
+	}
+
+	publishTime := metav1.Now()
+	if rev := ruleset.Status.Revision; rev != nil && rev.UUID == id {
+		publishTime = rev.PublishTime
+	}
+	snapshot := &wafv1alpha1.RuleSetSnapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ruleset.Namespace,
+			Labels: map[string]string{
+				ManagedByLabel:                     ManagedByValue,
+				wafv1alpha1.LabelExcludeFromBackup: "true",
+				snapshotRuleSetLabel:               ruleSetLabelValue(ruleset.Name),
+			},
+		},
+		Spec: wafv1alpha1.RuleSetSnapshotSpec{
+			RuleSet:     wafv1alpha1.RuleSetReference{Name: ruleset.Name},
+			Revision:    id,
+			ContentHash: cache.ContentHash(rules, dataFiles),
+			Sources:     slices.Clone(ruleset.Spec.Sources),
+			Data:        slices.Clone(ruleset.Spec.Data),
+			CRSVersion:  crsVersion(rules),
+			SizeBytes:   int64(cache.PayloadSize(rules, dataFiles)),
+			PublishTime: publishTime,
+		},
+	}
+	if err := controllerutil.SetControllerReference(ruleset, snapshot, r.Scheme); err != nil {
+		return err
+	}
+	if err := r.Create(ctx, snapshot); err != nil && !apierrors.IsAlreadyExists(err) {
+		logAPIError(log, req, "RuleSet", err, "Failed to create RuleSetSnapshot", snapshot)
+		return err
+	}
+	logInfo(log, req, "RuleSet", "Recorded RuleSetSnapshot", "snapshot", name)
+
+	return r.pruneSnapshots(ctx, log, req, ruleset, name)
+}
+
+// pruneSnapshots deletes the oldest RuleSetSnapshots of ruleset beyond
+// maxRuleSetSnapshots, never the served snapshot.
+func (r *RuleSetReconciler) pruneSnapshots(ctx context.Context, log logr.Logger, req ctrl.Request, ruleset *wafv1alpha1.RuleSet, served string) error {
+	var snapshots wafv1alpha1.RuleSetSnapshotList
+	if err := r.List(ctx, &snapshots, client.InNamespace(ruleset.Namespace), client.MatchingLabels{snapshotRuleSetLabel: ruleSetLabelValue(ruleset.Name)}); err != nil {
+		logAPIError(log, req, "RuleSet", err, "Failed to list RuleSetSnapshots", nil)
+		return err
+	}
+
+	owned := slices.DeleteFunc(snapshots.Items, func(s wafv1alpha1.RuleSetSnapshot) bool {
+		return s.Spec.RuleSet.Name != ruleset.Name || !metav1.IsControlledBy(&s, ruleset)
+	})
+	if len(owned) <= maxRuleSetSnapshots {
+		return nil
+	}
+	slices.SortFunc(owned, func(a, b wafv1alpha1.RuleSetSnapshot) int {
+		return a.Spec.PublishTime.Compare(b.Spec.PublishTime.Time)
+	})
+	for i := range owned[:len(owned)-maxRuleSetSnapshots] {
+		if owned[i].Name == served {
+			continue
+		}
+		if err := r.Delete(ctx, &owned[i]); client.IgnoreNotFound(err) != nil {
+			logAPIError(log, req, "RuleSet", err, "Failed to delete RuleSetSnapshot", &owned[i])
+			return err
+		}
+		logDebug(log, req, "RuleSet", "Deleted RuleSetSnapshot", "snapshot", owned[i].Name)
+	}
+	return nil
+}
+
+// ruleSetLabelValue returns name as a label value: RuleSet names longer than
+// 63 characters are truncated, and told apart by their controller owner.
+func ruleSetLabelValue(name string) string {
+	if len(name) > 63 {
+		name = strings.TrimRight(name[:63], "-.")
+	}
+	return name
+}
+
+// isLeader reports whether this replica is the leader, or leader election
+// is not set up.
+func (r *RuleSetReconciler) isLeader() bool {
+	return r.elected == nil || isElected(r.elected)
+}
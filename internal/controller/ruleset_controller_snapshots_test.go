@@ -0,0 +1,147 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/internal/rulesets/cache"
+	"github.com/networking-incubator/coraza-kubernetes-operator/test/utils"
+)
+
+func TestRuleSetReconciler_RecordSnapshot(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+
+	const (
+		cacheKey = "team-a/ruleset"
+		rules    = "SecRuleEngine On"
+	)
+	dataFiles := map[string][]byte{"bad-ips.data": []byte("192.0.2.1")}
+	id := cache.RevisionID(cacheKey, rules, dataFiles)
+	publishTime := metav1.NewTime(time.Now().Add(-time.Minute).Truncate(time.Second))
+
+	newRuleSet := func() *wafv1alpha1.RuleSet {
+		return &wafv1alpha1.RuleSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "ruleset", Namespace: "team-a", UID: "ruleset-uid"},
+			Spec: wafv1alpha1.RuleSetSpec{
+				Sources: []wafv1alpha1.SourceReference{{Name: "crs"}},
+				Data:    []wafv1alpha1.DataReference{{Name: "bad-ips"}},
+			},
+			Status: wafv1alpha1.RuleSetStatus{Revision: &wafv1alpha1.RuleSetRevision{UUID: id, PublishTime: publishTime}},
+		}
+	}
+
+	t.Run("creates the snapshot", func(t *testing.T) {
+		ruleset := newRuleSet()
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(ruleset.DeepCopy()).Build()
+		r := &RuleSetReconciler{Client: c, Scheme: scheme, Recorder: utils.NewTestRecorder()}
+		req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(ruleset)}
+
+		require.NoError(t, r.recordSnapshot(t.Context(), ctrl.Log, req, ruleset, cacheKey, id, rules, dataFiles))
+
+		var snapshot wafv1alpha1.RuleSetSnapshot
+		require.NoError(t, c.Get(t.Context(), types.NamespacedName{Namespace: "team-a", Name: "ruleset-" + id}, &snapshot))
+		assert.Equal(t, "ruleset", snapshot.Spec.RuleSet.Name)
+		assert.Equal(t, id, snapshot.Spec.Revision)
+		assert.Equal(t, cache.ContentHash(rules, dataFiles), snapshot.Spec.ContentHash)
+		assert.Equal(t, ruleset.Spec.Sources, snapshot.Spec.Sources)
+		assert.Equal(t, ruleset.Spec.Data, snapshot.Spec.Data)
+		assert.Equal(t, int64(cache.PayloadSize(rules, dataFiles)), snapshot.Spec.SizeBytes)
+		assert.Empty(t, snapshot.Spec.CRSVersion, "the rules do not include the CRS")
+		assert.True(t, publishTime.Equal(&snapshot.Spec.PublishTime))
+		assert.True(t, metav1.IsControlledBy(&snapshot, ruleset))
+		assert.Equal(t, "true", snapshot.Labels[wafv1alpha1.LabelExcludeFromBackup])
+
+		require.NoError(t, r.recordSnapshot(t.Context(), ctrl.Log, req, ruleset, cacheKey, id, rules, dataFiles), "an existing snapshot is kept")
+	})
+
+	t.Run("follower does not write snapshots", func(t *testing.T) {
+		ruleset := newRuleSet()
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(ruleset.DeepCopy()).Build()
+		r := &RuleSetReconciler{Client: c, Scheme: scheme, Recorder: utils.NewTestRecorder(), elected: make(chan struct{})}
+		req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(ruleset)}
+
+		require.NoError(t, r.recordSnapshot(t.Context(), ctrl.Log, req, ruleset, cacheKey, id, rules, dataFiles))
+
+		var snapshots wafv1alpha1.RuleSetSnapshotList
+		require.NoError(t, c.List(t.Context(), &snapshots))
+		assert.Empty(t, snapshots.Items)
+	})
+
+	t.Run("prunes the oldest snapshots", func(t *testing.T) {
+		ruleset := newRuleSet()
+		objs := []client.Object{ruleset.DeepCopy()}
+		for i := range maxRuleSetSnapshots + 2 {
+			snapshot := &wafv1alpha1.RuleSetSnapshot{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      fmt.Sprintf("ruleset-%02d", i),
+					Namespace: "team-a",
+					Labels:    map[string]string{snapshotRuleSetLabel: "ruleset"},
+				},
+				Spec: wafv1alpha1.RuleSetSnapshotSpec{
+					RuleSet:     wafv1alpha1.RuleSetReference{Name: "ruleset"},
+					PublishTime: metav1.NewTime(publishTime.Add(time.Duration(i-100) * time.Minute)),
+				},
+			}
+			require.NoError(t, controllerutil.SetControllerReference(ruleset, snapshot, scheme))
+			objs = append(objs, snapshot)
+		}
+		unowned := &wafv1alpha1.RuleSetSnapshot{
+			ObjectMeta: metav1.ObjectMeta{Name: "copy", Namespace: "team-a", Labels: map[string]string{snapshotRuleSetLabel: "ruleset"}},
+			Spec:       wafv1alpha1.RuleSetSnapshotSpec{RuleSet: wafv1alpha1.RuleSetReference{Name: "ruleset"}},
+		}
+		objs = append(objs, unowned)
+		c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objs...).Build()
+		r := &RuleSetReconciler{Client: c, Scheme: scheme, Recorder: utils.NewTestRecorder()}
+		req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(ruleset)}
+
+		require.NoError(t, r.recordSnapshot(t.Context(), ctrl.Log, req, ruleset, cacheKey, id, rules, dataFiles))
+
+		var snapshots wafv1alpha1.RuleSetSnapshotList
+		require.NoError(t, c.List(t.Context(), &snapshots))
+		var names []string
+		for _, s := range snapshots.Items {
+			names = append(names, s.Name)
+		}
+		assert.Len(t, names, maxRuleSetSnapshots+1)
+		assert.NotContains(t, names, "ruleset-00")
+		assert.NotContains(t, names, "ruleset-02")
+		assert.Contains(t, names, "ruleset-03")
+		assert.Contains(t, names, "ruleset-"+id)
+		assert.Contains(t, names, "copy", "snapshots not controlled by the RuleSet are left alone")
+	})
+}
+
+func TestRuleSetLabelValue(t *testing.T) {
+	assert.Equal(t, "ruleset", ruleSetLabelValue("ruleset"))
+	assert.Equal(t, strings.Repeat("a", 62), ruleSetLabelValue(strings.Repeat("a", 62)+"-b"), "truncated values must not end with a dash")
+}
@@ -23,13 +23,16 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/internal/references"
 	"github.com/networking-incubator/coraza-kubernetes-operator/internal/rulesets/cache"
 	"github.com/networking-incubator/coraza-kubernetes-operator/test/utils"
 )
@@ -227,13 +230,13 @@ func TestRuleSetReconciler_MissingRuleSource(t *testing.T) {
 
 	t.Log("Verifying cache was not populated due to missing RuleSource")
 	require.NoError(t, err)
-	assert.Equal(t, reconcile.Result{}, result, "Should requeue when RuleSource is not found")
+	assert.Equal(t, reconcile.Result{RequeueAfter: missingSourceInitialBackoff}, result, "Should requeue when RuleSource is not found")
 	cacheKey := testNamespace + "/missing-src-ruleset"
 	_, ok := ruleSetCache.Get(cacheKey)
 	assert.False(t, ok)
 
-	assert.True(t, recorder.HasEvent("Warning", "RuleSourceNotFound"),
-		"expected Warning/RuleSourceNotFound event; got: %v", recorder.Events)
+	assert.True(t, recorder.HasEvent("Warning", "SourceMissing"),
+		"expected Warning/SourceMissing event; got: %v", recorder.Events)
 }
 
 func TestRuleSetReconciler_ValidationRejection(t *testing.T) {
@@ -241,6 +244,7 @@ func TestRuleSetReconciler_ValidationRejection(t *testing.T) {
 		name          string
 		ruleSetName   string
 		sources       []wafv1alpha1.SourceReference
+		honeypot      *wafv1alpha1.Honeypot
 		expectedError string
 	}{
 		{
@@ -269,6 +273,13 @@ func TestRuleSetReconciler_ValidationRejection(t *testing.T) {
 			},
 			expectedError: "spec.sources[0].name: Required value",
 		},
+		{
+			name:          "honeypot blocking",
+			ruleSetName:   "honeypot-blocking-ruleset",
+			sources:       []wafv1alpha1.SourceReference{{Name: "test"}},
+			honeypot:      &wafv1alpha1.Honeypot{BlockSeconds: new(int32(3600))},
+			expectedError: "blocking honeypot clients is not supported yet",
+		},
 	}
 
 	for _, tt := range tests {
@@ -280,6 +291,7 @@ func TestRuleSetReconciler_ValidationRejection(t *testing.T) {
 			ruleSet.Name = tt.ruleSetName
 			ruleSet.Namespace = testNamespace
 			ruleSet.Spec.Sources = tt.sources
+			ruleSet.Spec.Honeypot = tt.honeypot
 			err := k8sClient.Create(ctx, ruleSet)
 			require.Error(t, err)
 			assert.Contains(t, err.Error(), tt.expectedError)
@@ -706,8 +718,8 @@ func TestRuleSetReconciler_ValidateRules(t *testing.T) {
 		require.NoError(t, err)
 		ready := apimeta.FindStatusCondition(ruleSet.Status.Conditions, "Ready")
 		assert.Equal(t, metav1.ConditionFalse, ready.Status)
-		assert.Equal(t, "RuleSourceNotFound", ready.Reason)
-		assert.Equal(t, "Referenced RuleSource notvalid does not exist", ready.Message)
+		assert.Equal(t, "SourceMissing", ready.Reason)
+		assert.Equal(t, "Referenced sources do not exist: RuleSource notvalid", ready.Message)
 	})
 
 	t.Run("ruleset referring @pmFromFile without a Data source should fail", func(t *testing.T) {
@@ -1195,3 +1207,139 @@ func TestRuleSetReconciler_DuplicateDataReferences(t *testing.T) {
 	assert.True(t, recorder.HasEvent("Warning", "DuplicateReference"),
 		"expected Warning/DuplicateReference event; got: %v", recorder.Events)
 }
+
+func TestRuleSetReconciler_CrossNamespaceReferences(t *testing.T) {
+	ctx := context.Background()
+	ruleSetCache := cache.NewRuleSetCache()
+
+	shared := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{GenerateName: "shared-rules-"}}
+	require.NoError(t, k8sClient.Create(ctx, shared))
+	t.Cleanup(func() {
+		if err := k8sClient.Delete(ctx, shared); err != nil {
+			t.Logf("failed to delete namespace %s: %v", shared.Name, err)
+		}
+	})
+
+	ruleSrc := utils.NewTestRuleSource("shared-src", shared.Name, "SecCollectionTimeout 1")
+	require.NoError(t, k8sClient.Create(ctx, ruleSrc))
+
+	ruleSet := utils.NewTestRuleSet(utils.RuleSetOptions{
+		Name:      "cross-ns-ruleset",
+		Namespace: testNamespace,
+		Sources: []wafv1alpha1.SourceReference{
+			{Name: "shared-src", Namespace: shared.Name},
+		},
+	})
+	require.NoError(t, k8sClient.Create(ctx, ruleSet))
+	t.Cleanup(func() {
+		if err := k8sClient.Delete(ctx, ruleSet); err != nil {
+			t.Logf("failed to delete RuleSet: %v", err)
+		}
+	})
+
+	recorder := utils.NewFakeRecorder()
+	reconciler := &RuleSetReconciler{
+		Client:       k8sClient,
+		Scheme:       scheme,
+		Recorder:     recorder,
+		Cache:        ruleSetCache,
+		capabilities: Capabilities{},
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: ruleSet.Name, Namespace: ruleSet.Namespace}}
+	cacheKey := testNamespace + "/cross-ns-ruleset"
+
+	t.Log("Reconciling RuleSet without a grant - the reference must not be permitted")
+	result, err := reconciler.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, reconcile.Result{}, result)
+	_, ok := ruleSetCache.Get(cacheKey)
+	assert.False(t, ok, "cache should be empty when the reference is not permitted")
+
+	require.NoError(t, k8sClient.Get(ctx, req.NamespacedName, ruleSet))
+	ready := apimeta.FindStatusCondition(ruleSet.Status.Conditions, "Ready")
+	require.NotNil(t, ready)
+	assert.Equal(t, metav1.ConditionFalse, ready.Status)
+	assert.Equal(t, references.ReasonRefNotPermitted, ready.Reason)
+	assert.Contains(t, ready.Message, shared.Name+"/shared-src")
+	assert.True(t, recorder.HasEvent("Warning", references.ReasonRefNotPermitted),
+		"expected Warning/RefNotPermitted event; got: %v", recorder.Events)
+
+	t.Log("Granting the RuleSet namespace with the annotation")
+	require.NoError(t, k8sClient.Get(ctx, client.ObjectKeyFromObject(ruleSrc), ruleSrc))
+	ruleSrc.Annotations = map[string]string{wafv1alpha1.AnnotationAllowReferencesFrom: testNamespace}
+	require.NoError(t, k8sClient.Update(ctx, ruleSrc))
+
+	result, err = reconciler.Reconcile(ctx, req)
+	require.NoError(t, err)
+	assert.Equal(t, reconcile.Result{}, result)
+	entry, ok := ruleSetCache.Get(cacheKey)
+	require.True(t, ok, "cache should be populated once the reference is permitted")
+	assert.Equal(t, "SecCollectionTimeout 1", entry.Rules)
+}
+
+func TestRuleSetReconciler_ThreatFeeds(t *testing.T) {
+	ctx := context.Background()
+	ruleSetCache := cache.NewRuleSetCache()
+
+	ruleSrc := utils.NewTestRuleSource("feed-src", testNamespace, "SecCollectionTimeout 1")
+	require.NoError(t, k8sClient.Create(ctx, ruleSrc))
+	t.Cleanup(func() {
+		if err := k8sClient.Delete(ctx, ruleSrc); err != nil {
+			t.Logf("failed to delete RuleSource: %v", err)
+		}
+	})
+
+	ruleSet := utils.NewTestRuleSet(utils.RuleSetOptions{
+		Name:      "feed-ruleset",
+		Namespace: testNamespace,
+		Sources:   []wafv1alpha1.SourceReference{{Name: "feed-src"}},
+	})
+	ruleSet.Spec.ThreatFeeds = []wafv1alpha1.ThreatFeedReference{{Name: "blocklist"}}
+	require.NoError(t, k8sClient.Create(ctx, ruleSet))
+	t.Cleanup(func() {
+		if err := k8sClient.Delete(ctx, ruleSet); err != nil {
+			t.Logf("failed to delete RuleSet: %v", err)
+		}
+	})
+
+	recorder := utils.NewFakeRecorder()
+	reconciler := &RuleSetReconciler{
+		Client:       k8sClient,
+		Scheme:       scheme,
+		Recorder:     recorder,
+		Cache:        ruleSetCache,
+		capabilities: Capabilities{},
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: ruleSet.Name, Namespace: ruleSet.Namespace}}
+	cacheKey := testNamespace + "/feed-ruleset"
+
+	t.Log("Reconciling RuleSet before the ThreatFeed is downloaded")
+	_, err := reconciler.Reconcile(ctx, req)
+	require.NoError(t, err)
+	_, ok := ruleSetCache.Get(cacheKey)
+	assert.False(t, ok, "cache should be empty until the ThreatFeed is downloaded")
+
+	require.NoError(t, k8sClient.Get(ctx, req.NamespacedName, ruleSet))
+	degraded := apimeta.FindStatusCondition(ruleSet.Status.Conditions, "Degraded")
+	require.NotNil(t, degraded)
+	assert.Equal(t, "ThreatFeedNotReady", degraded.Reason)
+
+	t.Log("Creating the RuleData of the ThreatFeed")
+	ruleData := &wafv1alpha1.RuleData{
+		ObjectMeta: metav1.ObjectMeta{Name: "threatfeed-blocklist", Namespace: testNamespace},
+		Spec:       wafv1alpha1.RuleDataSpec{Files: map[string]string{"threatfeed-blocklist": "198.51.100.0/24\n"}},
+	}
+	require.NoError(t, k8sClient.Create(ctx, ruleData))
+	t.Cleanup(func() {
+		if err := k8sClient.Delete(ctx, ruleData); err != nil {
+			t.Logf("failed to delete RuleData: %v", err)
+		}
+	})
+
+	_, err = reconciler.Reconcile(ctx, req)
+	require.NoError(t, err)
+	entry, ok := ruleSetCache.Get(cacheKey)
+	require.True(t, ok, "cache should be populated once the ThreatFeed is downloaded")
+	assert.Contains(t, entry.Rules, "@ipMatchFromFile threatfeed-blocklist")
+	assert.Equal(t, []byte("198.51.100.0/24\n"), entry.DataFiles["threatfeed-blocklist"])
+}
@@ -0,0 +1,109 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// RuleSet Threat Feeds - Vars
+// -----------------------------------------------------------------------------
+
+// threatFeedRuleIDBase is the first rule ID of the rules generated for the
+// ThreatFeeds of a RuleSet. The feed at index i uses the ID
+// threatFeedRuleIDBase+i; RuleSources must not use IDs from this range.
+const threatFeedRuleIDBase = 89100000
+
+// -----------------------------------------------------------------------------
+// RuleSet Threat Feeds
+// -----------------------------------------------------------------------------
+
+// threatFeedRules returns the SecRules matching the client address against
+// the ThreatFeeds of the RuleSet, or an empty string when it has none. The
+// rules run in phase 1, after the exemption rules.
+func threatFeedRules(ruleset *wafv1alpha1.RuleSet) string {
+	if len(ruleset.Spec.ThreatFeeds) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("# Threat feeds generated from the RuleSet spec.threatFeeds\n")
+	for i, feed := range ruleset.Spec.ThreatFeeds {
+		action := "deny,status:403"
+		if feed.Action == wafv1alpha1.ThreatFeedActionLog {
+			action = "pass"
+		}
+		fmt.Fprintf(&b, "SecRule REMOTE_ADDR \"@ipMatchFromFile %s\" \"id:%d,phase:1,%s,log,t:none,msg:'Client address in ThreatFeed %s'\"\n",
+			threatFeedDataName(feed.Name), threatFeedRuleIDBase+i, action, feed.Name)
+	}
+	return b.String()
+}
+
+// loadThreatFeedData adds the data files of the RuleData generated for the
+// ThreatFeeds of the RuleSet to dataFiles. A ThreatFeed whose RuleData does
+// not exist yet degrades the RuleSet until its first download.
+func (r *RuleSetReconciler) loadThreatFeedData(
+	ctx context.Context,
+	log logr.Logger,
+	req ctrl.Request,
+	ruleset *wafv1alpha1.RuleSet,
+	dataFiles map[string][]byte,
+) (map[string][]byte, bool, error) {
+	if len(ruleset.Spec.ThreatFeeds) == 0 {
+		return dataFiles, false, nil
+	}
+
+	logInfo(log, req, "RuleSet", "Loading threat feeds", "threatFeedCount", len(ruleset.Spec.ThreatFeeds))
+
+	if dataFiles == nil {
+		dataFiles = make(map[string][]byte)
+	}
+	for _, feed := range ruleset.Spec.ThreatFeeds {
+		name := threatFeedDataName(feed.Name)
+		var rd wafv1alpha1.RuleData
+		if err := r.Get(ctx, types.NamespacedName{Namespace: ruleset.Namespace, Name: name}, &rd); err != nil {
+			if apierrors.IsNotFound(err) {
+				logInfo(log, req, "RuleSet", "ThreatFeed not downloaded yet; waiting for its RuleData", "threatFeedName", feed.Name)
+				msg := fmt.Sprintf("ThreatFeed %s has not been downloaded yet: check that it exists and its status", feed.Name)
+				if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "RuleSet", ruleset, &ruleset.Status.Conditions, ruleset.Generation, "ThreatFeedNotReady", msg); patchErr != nil {
+					return nil, true, patchErr
+				}
+				return nil, true, nil
+			}
+			logError(log, req, "RuleSet", err, "Failed to get ThreatFeed RuleData", "threatFeedName", feed.Name)
+			msg := fmt.Sprintf("Failed to access the RuleData of ThreatFeed %s: %v", feed.Name, err)
+			if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "RuleSet", ruleset, &ruleset.Status.Conditions, ruleset.Generation, "RuleDataAccessError", msg); patchErr != nil {
+				return nil, true, patchErr
+			}
+			return nil, true, err
+		}
+
+		dataFiles[name] = []byte(rd.Spec.Files[name])
+	}
+
+	return dataFiles, false, nil
+}
@@ -26,14 +26,14 @@ import (
 	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
 )
 
-func TestValidateRuleSourceRules(t *testing.T) {
+func TestValidateSourceRules(t *testing.T) {
 	t.Run("valid rules return nil", func(t *testing.T) {
-		err := validateRuleSourceRules(`SecDefaultAction "phase:1,log,auditlog,pass"`, "test-rs", nil)
+		err := validateSourceRules(`SecDefaultAction "phase:1,log,auditlog,pass"`, "RuleSource", "test-rs", nil)
 		assert.NoError(t, err)
 	})
 
-	t.Run("invalid rules return error mentioning RuleSource name", func(t *testing.T) {
-		err := validateRuleSourceRules(`SecInvalidDirective "bad"`, "bad-rs", nil)
+	t.Run("invalid rules return error mentioning the source", func(t *testing.T) {
+		err := validateSourceRules(`SecInvalidDirective "bad"`, "RuleSource", "bad-rs", nil)
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "bad-rs")
 		assert.Contains(t, err.Error(), "doesn't contain valid rules")
@@ -41,17 +41,17 @@ func TestValidateRuleSourceRules(t *testing.T) {
 
 	t.Run("missing file error is skipped when file exists in dataFiles", func(t *testing.T) {
 		dataFiles := map[string][]byte{"rule1.data": []byte("content")}
-		err := validateRuleSourceRules(
+		err := validateSourceRules(
 			`SecRule REQUEST_URI "@pmFromFile rule1.data" "id:1,phase:1,deny"`,
-			"data-rs", dataFiles,
+			"RuleSource", "data-rs", dataFiles,
 		)
 		assert.NoError(t, err)
 	})
 
 	t.Run("missing file error is reported when file not in dataFiles", func(t *testing.T) {
-		err := validateRuleSourceRules(
+		err := validateSourceRules(
 			`SecRule REQUEST_URI "@pmFromFile missing.data" "id:1,phase:1,deny"`,
-			"data-rs", nil,
+			"RuleSource", "data-rs", nil,
 		)
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "data-rs")
@@ -118,6 +118,27 @@ func TestFindDuplicateReferences(t *testing.T) {
 		assert.Contains(t, msg, "spec.data", "should mention data")
 	})
 
+	t.Run("same name in other namespaces is not a duplicate", func(t *testing.T) {
+		rs := &wafv1alpha1.RuleSet{}
+		rs.Namespace = "team-a"
+		rs.Spec.Sources = []wafv1alpha1.SourceReference{
+			{Name: "a"},
+			{Name: "a", Namespace: "shared"},
+		}
+		assert.Empty(t, findDuplicateReferences(rs))
+	})
+
+	t.Run("explicit RuleSet namespace is a duplicate", func(t *testing.T) {
+		rs := &wafv1alpha1.RuleSet{}
+		rs.Namespace = "team-a"
+		rs.Spec.Data = []wafv1alpha1.DataReference{
+			{Name: "x"},
+			{Name: "x", Namespace: "team-a"},
+		}
+		msg := findDuplicateReferences(rs)
+		assert.Contains(t, msg, "spec.data")
+	})
+
 	t.Run("empty spec returns empty string", func(t *testing.T) {
 		rs := &wafv1alpha1.RuleSet{}
 		assert.Empty(t, findDuplicateReferences(rs))
@@ -19,42 +19,107 @@ package controller
 import (
 	"context"
 
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/internal/references"
+	"github.com/networking-incubator/coraza-kubernetes-operator/internal/rulesources"
 )
 
 // -----------------------------------------------------------------------------
 // RuleSet Controller - Watch Predicates
 // -----------------------------------------------------------------------------
 
-// findRuleSetsForRuleSource maps a RuleSource to the RuleSets that reference it
-// using the spec.sources.name field index registered in SetupWithManager.
+// ruleSetSourcesIndex and ruleSetDataIndex index RuleSets by the
+// "namespace/name" keys of the RuleSources and RuleData they reference,
+// including the RuleData generated for their ThreatFeeds.
+const (
+	ruleSetSourcesIndex = "spec.sources.name"
+	ruleSetDataIndex    = "spec.data.name"
+)
+
+// referenceIndexKey returns the field index value of a referenced object.
+func referenceIndexKey(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+// referenceNamespace returns the namespace of a RuleSource or RuleData
+// reference of the RuleSet, which defaults to the RuleSet namespace.
+func referenceNamespace(ruleset *wafv1alpha1.RuleSet, namespace string) string {
+	if namespace == "" {
+		return ruleset.Namespace
+	}
+	return namespace
+}
+
+// findRuleSetsForRuleSource maps a RuleSource to the RuleSets that reference
+// it, in any namespace, using the field index registered in SetupWithManager.
+// Every RuleSource event also drops it from the missing sources.
 func (r *RuleSetReconciler) findRuleSetsForRuleSource(ctx context.Context, ruleSource client.Object) []reconcile.Request {
-	return r.findRuleSetsBy(ctx, ruleSource.GetNamespace(), "spec.sources.name", ruleSource.GetName())
+	r.missingSources.forget(rulesources.Reference{Kind: rulesources.KindRuleSource, Namespace: ruleSource.GetNamespace(), Name: ruleSource.GetName()})
+	return r.findRuleSetsBy(ctx, ruleSetSourcesIndex, referenceIndexKey(ruleSource.GetNamespace(), ruleSource.GetName()))
 }
 
-// findRuleSetsForRuleData maps a RuleData to the RuleSets that reference it
-// using the spec.data.name field index registered in SetupWithManager.
+// findRuleSetsForRuleData maps a RuleData to the RuleSets that reference it,
+// in any namespace, using the field index registered in SetupWithManager.
 func (r *RuleSetReconciler) findRuleSetsForRuleData(ctx context.Context, ruleData client.Object) []reconcile.Request {
-	return r.findRuleSetsBy(ctx, ruleData.GetNamespace(), "spec.data.name", ruleData.GetName())
+	return r.findRuleSetsBy(ctx, ruleSetDataIndex, referenceIndexKey(ruleData.GetNamespace(), ruleData.GetName()))
 }
 
 // findRuleSetsBy lists RuleSets matching a field index value and returns
 // reconcile requests for each.
-func (r *RuleSetReconciler) findRuleSetsBy(ctx context.Context, namespace, indexKey, indexValue string) []reconcile.Request {
+func (r *RuleSetReconciler) findRuleSetsBy(ctx context.Context, indexKey, indexValue string) []reconcile.Request {
 	log := logf.FromContext(ctx)
 
 	var ruleSetList wafv1alpha1.RuleSetList
-	if err := r.List(ctx, &ruleSetList,
-		client.InNamespace(namespace),
-		client.MatchingFields{indexKey: indexValue},
-	); err != nil {
-		log.Error(err, "RuleSet: Failed to list RuleSets", "namespace", namespace, "index", indexKey, "value", indexValue)
+	if err := r.List(ctx, &ruleSetList, client.MatchingFields{indexKey: indexValue}); err != nil {
+		log.Error(err, "RuleSet: Failed to list RuleSets", "index", indexKey, "value", indexValue)
 		return nil
 	}
 
 	return collectRequests(ruleSetList.Items, func(_ *wafv1alpha1.RuleSet) bool { return true })
 }
+
+// findRuleSetsForReferenceGrant maps a ReferenceGrant to the RuleSets, in the
+// namespaces it grants access from, that reference the namespace of the
+// ReferenceGrant.
+func (r *RuleSetReconciler) findRuleSetsForReferenceGrant(ctx context.Context, obj client.Object) []reconcile.Request {
+	log := logf.FromContext(ctx)
+
+	grant, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, namespace := range references.GrantedNamespaces(grant, wafv1alpha1.GroupVersion.Group, "RuleSet") {
+		var ruleSetList wafv1alpha1.RuleSetList
+		if err := r.List(ctx, &ruleSetList, client.InNamespace(namespace)); err != nil {
+			log.Error(err, "RuleSet: Failed to list RuleSets", "namespace", namespace)
+			continue
+		}
+		requests = append(requests, collectRequests(ruleSetList.Items, func(rs *wafv1alpha1.RuleSet) bool {
+			return referencesNamespace(rs, grant.GetNamespace())
+		})...)
+	}
+	return requests
+}
+
+// referencesNamespace reports whether the RuleSet references a RuleSource or
+// RuleData in namespace.
+func referencesNamespace(ruleset *wafv1alpha1.RuleSet, namespace string) bool {
+	for _, src := range ruleset.Spec.Sources {
+		if referenceNamespace(ruleset, src.Namespace) == namespace {
+			return true
+		}
+	}
+	for _, d := range ruleset.Spec.Data {
+		if referenceNamespace(ruleset, d.Namespace) == namespace {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,256 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// Runtime Configuration
+// -----------------------------------------------------------------------------
+
+// RuntimeConfig holds the settings of the OperatorConfig resource that
+// override command-line flags. It is written by the OperatorConfig controller
+// and read by the other reconcilers on every use, so changes take effect
+// without a restart.
+//
+// A nil *RuntimeConfig is valid and overrides nothing.
+type RuntimeConfig struct {
+	mu                 sync.RWMutex
+	defaultWasmImage   string
+	ruleSourceDebounce *time.Duration
+	imageMirrors       []wafv1alpha1.ImageMirror
+	namespaceQuota     *wafv1alpha1.NamespaceQuota
+	approvalNamespaces []string
+	engineGuardrails   *wafv1alpha1.EngineGuardrails
+	gatewayCoverage    *wafv1alpha1.GatewayCoverage
+
+	// engineEvents notifies the Engine controller that Engines relying on
+	// the default WASM image need to be reconciled. It is buffered with a
+	// capacity of one: a pending notification already covers every Engine.
+	engineEvents chan event.GenericEvent
+
+	// mirrorEvents notifies the Engine controller that every Engine needs to
+	// be reconciled because the image mirrors changed. It is buffered like
+	// engineEvents.
+	mirrorEvents chan event.GenericEvent
+
+	// quotaEvents notifies the Engine controller that every Engine needs to
+	// be reconciled because the namespace quota or the Engine guardrails
+	// changed. It is buffered like engineEvents.
+	quotaEvents chan event.GenericEvent
+
+	// approvalEvents notifies the RuleSet controller that every RuleSet needs
+	// to be reconciled because the namespaces requiring rule approval
+	// changed. It is buffered like engineEvents.
+	approvalEvents chan event.GenericEvent
+
+	// coverageEvents notifies the GatewayCoverage controller that every
+	// Gateway needs to be checked because the Gateways that must be
+	// protected changed. It is buffered like engineEvents.
+	coverageEvents chan event.GenericEvent
+}
+
+// NewRuntimeConfig returns a RuntimeConfig without any overrides.
+func NewRuntimeConfig() *RuntimeConfig {
+	return &RuntimeConfig{
+		engineEvents:   make(chan event.GenericEvent, 1),
+		mirrorEvents:   make(chan event.GenericEvent, 1),
+		quotaEvents:    make(chan event.GenericEvent, 1),
+		approvalEvents: make(chan event.GenericEvent, 1),
+		coverageEvents: make(chan event.GenericEvent, 1),
+	}
+}
+
+// DefaultWasmImage returns the overriding default WASM image, or "" when the
+// flag value applies.
+func (c *RuntimeConfig) DefaultWasmImage() string {
+	if c == nil {
+		return ""
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.defaultWasmImage
+}
+
+// RuleSourceDebounce returns the overriding RuleSource debounce window and
+// whether it is set.
+func (c *RuntimeConfig) RuleSourceDebounce() (time.Duration, bool) {
+	if c == nil {
+		return 0, false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.ruleSourceDebounce == nil {
+		return 0, false
+	}
+	return *c.ruleSourceDebounce, true
+}
+
+// ImageMirrors returns the overriding image mirrors, or nil when the flag
+// value applies.
+func (c *RuntimeConfig) ImageMirrors() []wafv1alpha1.ImageMirror {
+	if c == nil {
+		return nil
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.imageMirrors
+}
+
+// NamespaceQuota returns the quota applied to every namespace, or nil when
+// namespaces are unlimited.
+func (c *RuntimeConfig) NamespaceQuota() *wafv1alpha1.NamespaceQuota {
+	if c == nil {
+		return nil
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.namespaceQuota
+}
+
+// EngineGuardrails returns the guardrails constraining the Engines of
+// namespace, or nil when they are unconstrained.
+func (c *RuntimeConfig) EngineGuardrails(namespace string) *wafv1alpha1.EngineGuardrails {
+	if c == nil {
+		return nil
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	g := c.engineGuardrails
+	if g == nil || (len(g.Namespaces) > 0 && !slices.Contains(g.Namespaces, namespace)) {
+		return nil
+	}
+	return g
+}
+
+// GatewayCoverage returns the Gateways of namespace that must be protected
+// by an Engine, or nil when none must be.
+func (c *RuntimeConfig) GatewayCoverage(namespace string) *wafv1alpha1.GatewayCoverage {
+	if c == nil {
+		return nil
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	g := c.gatewayCoverage
+	if g == nil || (len(g.Namespaces) > 0 && !slices.Contains(g.Namespaces, namespace)) {
+		return nil
+	}
+	return g
+}
+
+// RuleApprovalRequired reports whether the rule changes of the RuleSets in
+// namespace must be approved.
+func (c *RuntimeConfig) RuleApprovalRequired(namespace string) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return slices.Contains(c.approvalNamespaces, namespace)
+}
+
+// apply replaces all overrides with those set in spec; a nil spec clears
+// them. It reports whether the default WASM image changed, in which case
+// Engines relying on it have been signalled. A change of the image mirrors
+// or of the namespace quota or Engine guardrails signals every Engine, and a
+// change of the namespaces requiring rule approval every RuleSet. A change of
+// the gateway coverage signals every Gateway.
+func (c *RuntimeConfig) apply(obj *wafv1alpha1.OperatorConfig) (imageChanged bool) {
+	if c == nil {
+		return false
+	}
+
+	var (
+		image      string
+		debounce   *time.Duration
+		mirrors    []wafv1alpha1.ImageMirror
+		quota      *wafv1alpha1.NamespaceQuota
+		approval   []string
+		guardrails *wafv1alpha1.EngineGuardrails
+		coverage   *wafv1alpha1.GatewayCoverage
+	)
+	if obj != nil {
+		image = obj.Spec.DefaultWasmImage
+		if w := obj.Spec.RuleSourceDebounceWindow; w != nil {
+			debounce = &w.Duration
+		}
+		mirrors = slices.Clone(obj.Spec.ImageMirrors)
+		quota = obj.Spec.NamespaceQuota.DeepCopy()
+		if obj.Spec.RuleApproval != nil {
+			approval = slices.Clone(obj.Spec.RuleApproval.Namespaces)
+		}
+		guardrails = obj.Spec.EngineGuardrails.DeepCopy()
+		coverage = obj.Spec.GatewayCoverage.DeepCopy()
+	}
+
+	c.mu.Lock()
+	imageChanged = c.defaultWasmImage != image
+	mirrorsChanged := !slices.Equal(c.imageMirrors, mirrors)
+	quotaChanged := !equality.Semantic.DeepEqual(c.namespaceQuota, quota)
+	approvalChanged := !slices.Equal(c.approvalNamespaces, approval)
+	guardrailsChanged := !equality.Semantic.DeepEqual(c.engineGuardrails, guardrails)
+	coverageChanged := !equality.Semantic.DeepEqual(c.gatewayCoverage, coverage)
+	c.defaultWasmImage = image
+	c.ruleSourceDebounce = debounce
+	c.imageMirrors = mirrors
+	c.namespaceQuota = quota
+	c.approvalNamespaces = approval
+	c.engineGuardrails = guardrails
+	c.gatewayCoverage = coverage
+	c.mu.Unlock()
+
+	if mirrorsChanged {
+		notify(c.mirrorEvents, obj)
+	}
+
+	if quotaChanged || guardrailsChanged {
+		notify(c.quotaEvents, obj)
+	}
+
+	if approvalChanged {
+		notify(c.approvalEvents, obj)
+	}
+
+	if coverageChanged {
+		notify(c.coverageEvents, obj)
+	}
+
+	if imageChanged {
+		notify(c.engineEvents, obj)
+	}
+	return imageChanged
+}
+
+// notify sends obj on events unless a notification is already pending.
+func notify(events chan event.GenericEvent, obj *wafv1alpha1.OperatorConfig) {
+	if obj == nil {
+		obj = &wafv1alpha1.OperatorConfig{}
+	}
+	select {
+	case events <- event.GenericEvent{Object: obj}:
+	default:
+	}
+}
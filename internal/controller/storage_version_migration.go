@@ -0,0 +1,189 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// Storage Version Migration - RBAC
+// -----------------------------------------------------------------------------
+
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;list
+// +kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions/status,verbs=update;patch,resourceNames=engines.waf.k8s.coraza.io;rulesets.waf.k8s.coraza.io;rulesources.waf.k8s.coraza.io;ruledata.waf.k8s.coraza.io;operatorconfigs.waf.k8s.coraza.io;threatfeeds.waf.k8s.coraza.io;falsepositives.waf.k8s.coraza.io;emergencyblocks.waf.k8s.coraza.io;rulesetapprovals.waf.k8s.coraza.io;rulesetsnapshots.waf.k8s.coraza.io
+// +kubebuilder:rbac:groups=waf.k8s.coraza.io,resources=rulesources;ruledata;operatorconfigs;threatfeeds;falsepositives;emergencyblocks;rulesetapprovals;rulesetsnapshots,verbs=update
+
+// -----------------------------------------------------------------------------
+// Storage Version Migration - Vars
+// -----------------------------------------------------------------------------
+
+var customResourceDefinitionGVK = schema.GroupVersionKind{
+	Group:   "apiextensions.k8s.io",
+	Version: "v1",
+	Kind:    "CustomResourceDefinition",
+}
+
+// storageMigrationPageSize bounds the number of objects listed per request.
+const storageMigrationPageSize = 500
+
+// -----------------------------------------------------------------------------
+// Storage Version Migration
+// -----------------------------------------------------------------------------
+
+// StorageVersionMigrator rewrites the stored objects of the operator's CRDs
+// in their current storage version, then prunes every other version from the
+// status.storedVersions of the CRD. Without this, an API version can't be
+// removed from a CRD after the storage version moved on, because objects may
+// still be stored in it. It runs once, on the leader, at startup.
+type StorageVersionMigrator struct {
+	client client.Client
+	reader client.Reader
+}
+
+// NewStorageVersionMigrator returns a new StorageVersionMigrator runnable.
+// The reader should be a direct API reader (not cached), so that listing
+// every stored object does not set up informers for them.
+func NewStorageVersionMigrator(c client.Client, reader client.Reader) *StorageVersionMigrator {
+	return &StorageVersionMigrator{client: c, reader: reader}
+}
+
+// NeedLeaderElection makes only the leader migrate.
+func (m *StorageVersionMigrator) NeedLeaderElection() bool {
+	return true
+}
+
+// Start migrates every CRD of the operator's API group. It satisfies the
+// manager.Runnable interface.
+//
+// Failures are logged and do not stop the manager: objects stored in an
+// older version stay readable, and the migration is retried on the next
+// start.
+func (m *StorageVersionMigrator) Start(ctx context.Context) error {
+	log := ctrl.Log.WithName("storage-version-migration")
+
+	crds := &unstructured.UnstructuredList{}
+	crds.SetGroupVersionKind(customResourceDefinitionGVK.GroupVersion().WithKind(customResourceDefinitionGVK.Kind + "List"))
+	if err := m.reader.List(ctx, crds); err != nil {
+		log.Error(err, "Failed to list CustomResourceDefinitions")
+		return nil
+	}
+
+	for i := range crds.Items {
+		crd := &crds.Items[i]
+		if group, _, _ := unstructured.NestedString(crd.Object, "spec", "group"); group != wafv1alpha1.Group {
+			continue
+		}
+		if err := m.migrate(ctx, log, crd); err != nil {
+			log.Error(err, "Failed to migrate stored objects", "crd", crd.GetName())
+		}
+	}
+	return nil
+}
+
+// migrate rewrites the objects of crd and prunes its stored versions, unless
+// only the storage version is stored. The stored versions are only pruned
+// once every object was rewritten.
+func (m *StorageVersionMigrator) migrate(ctx context.Context, log logr.Logger, crd *unstructured.Unstructured) error {
+	storage := storageVersion(crd)
+	stored, _, _ := unstructured.NestedStringSlice(crd.Object, "status", "storedVersions")
+	if storage == "" || slices.Equal(stored, []string{storage}) {
+		return nil
+	}
+
+	kind, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "kind")
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(schema.GroupVersionKind{Group: wafv1alpha1.Group, Version: storage, Kind: kind + "List"})
+
+	migrated := 0
+	var skipped []string
+	for {
+		if err := m.reader.List(ctx, list, client.Limit(storageMigrationPageSize), client.Continue(list.GetContinue())); err != nil {
+			return fmt.Errorf("listing %s: %w", kind, err)
+		}
+		for i := range list.Items {
+			obj := &list.Items[i]
+			err := m.rewrite(ctx, obj)
+			switch {
+			case err == nil, apierrors.IsNotFound(err):
+				migrated++
+			case apierrors.IsConflict(err):
+				skipped = append(skipped, client.ObjectKeyFromObject(obj).String())
+			default:
+				return fmt.Errorf("rewriting %s %s/%s: %w", kind, obj.GetNamespace(), obj.GetName(), err)
+			}
+		}
+		if list.GetContinue() == "" {
+			break
+		}
+	}
+	if len(skipped) > 0 {
+		return fmt.Errorf("%d %s objects kept conflicting and were not rewritten, keeping stored versions %v: %v", len(skipped), kind, stored, skipped)
+	}
+
+	if err := unstructured.SetNestedStringSlice(crd.Object, []string{storage}, "status", "storedVersions"); err != nil {
+		return err
+	}
+	if err := m.client.Status().Update(ctx, crd); err != nil {
+		return fmt.Errorf("pruning stored versions: %w", err)
+	}
+
+	log.Info("Migrated stored objects", "crd", crd.GetName(), "storageVersion", storage, "objects", migrated, "prunedVersions", slices.DeleteFunc(stored, func(v string) bool { return v == storage }))
+	return nil
+}
+
+// rewrite writes obj back unchanged: an update without changes is still
+// written, in the storage version. On a conflict, the object is read again
+// and the update retried, rather than assuming that the conflicting write
+// rewrote it.
+func (m *StorageVersionMigrator) rewrite(ctx context.Context, obj *unstructured.Unstructured) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		err := m.client.Update(ctx, obj)
+		if !apierrors.IsConflict(err) {
+			return err
+		}
+		if getErr := m.reader.Get(ctx, client.ObjectKeyFromObject(obj), obj); getErr != nil {
+			return getErr
+		}
+		return err
+	})
+}
+
+// storageVersion returns the version crd stores objects in, or "".
+func storageVersion(crd *unstructured.Unstructured) string {
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	for _, v := range versions {
+		version, _ := v.(map[string]any)
+		if storage, _, _ := unstructured.NestedBool(version, "storage"); storage {
+			name, _, _ := unstructured.NestedString(version, "name")
+			return name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,151 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+func testCRD(name, group, kind string, storedVersions ...string) *unstructured.Unstructured {
+	stored := make([]any, 0, len(storedVersions))
+	for _, v := range storedVersions {
+		stored = append(stored, v)
+	}
+	crd := &unstructured.Unstructured{Object: map[string]any{
+		"metadata": map[string]any{"name": name},
+		"spec": map[string]any{
+			"group": group,
+			"names": map[string]any{"kind": kind},
+			"versions": []any{
+				map[string]any{"name": "v1alpha0", "served": true, "storage": false},
+				map[string]any{"name": "v1alpha1", "served": true, "storage": true},
+			},
+		},
+		"status": map[string]any{"storedVersions": stored},
+	}}
+	crd.SetGroupVersionKind(customResourceDefinitionGVK)
+	return crd
+}
+
+func TestStorageVersionMigrator(t *testing.T) {
+	scheme := newFleetTestScheme(t)
+
+	rulesets := testCRD("rulesets.waf.k8s.coraza.io", wafv1alpha1.Group, "RuleSet", "v1alpha0", "v1alpha1")
+	engines := testCRD("engines.waf.k8s.coraza.io", wafv1alpha1.Group, "Engine", "v1alpha1")
+	foreign := testCRD("widgets.example.com", "example.com", "Widget", "v1alpha0", "v1alpha1")
+
+	var updated []string
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(
+			rulesets, engines, foreign,
+			&wafv1alpha1.RuleSet{ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "team-a"}},
+			&wafv1alpha1.RuleSet{ObjectMeta: metav1.ObjectMeta{Name: "b", Namespace: "team-b"}},
+			&wafv1alpha1.Engine{ObjectMeta: metav1.ObjectMeta{Name: "waf", Namespace: "team-a"}},
+		).
+		WithStatusSubresource(rulesets, engines, foreign).
+		WithInterceptorFuncs(interceptor.Funcs{
+			Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+				updated = append(updated, obj.GetObjectKind().GroupVersionKind().Kind+"/"+obj.GetName())
+				return c.Update(ctx, obj, opts...)
+			},
+		}).
+		Build()
+
+	m := NewStorageVersionMigrator(c, c)
+	require.NoError(t, m.Start(t.Context()))
+
+	assert.ElementsMatch(t, []string{"RuleSet/a", "RuleSet/b"}, updated, "only objects of CRDs with stale stored versions are rewritten")
+
+	storedVersions := func(crd *unstructured.Unstructured) []string {
+		got := &unstructured.Unstructured{}
+		got.SetGroupVersionKind(customResourceDefinitionGVK)
+		require.NoError(t, c.Get(t.Context(), client.ObjectKeyFromObject(crd), got))
+		versions, _, _ := unstructured.NestedStringSlice(got.Object, "status", "storedVersions")
+		return versions
+	}
+	assert.Equal(t, []string{"v1alpha1"}, storedVersions(rulesets))
+	assert.Equal(t, []string{"v1alpha1"}, storedVersions(engines))
+	assert.Equal(t, []string{"v1alpha0", "v1alpha1"}, storedVersions(foreign), "CRDs of other groups are left alone")
+}
+
+func TestStorageVersionMigrator_Conflicts(t *testing.T) {
+	scheme := newFleetTestScheme(t)
+
+	tests := []struct {
+		name       string
+		conflicts  int
+		wantErr    bool
+		wantStored []string
+	}{
+		{name: "conflict is retried", conflicts: 1, wantStored: []string{"v1alpha1"}},
+		{name: "persistent conflict keeps stored versions", conflicts: 100, wantErr: true, wantStored: []string{"v1alpha0", "v1alpha1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rulesets := testCRD("rulesets.waf.k8s.coraza.io", wafv1alpha1.Group, "RuleSet", "v1alpha0", "v1alpha1")
+			conflicts, updates := tt.conflicts, 0
+			c := fake.NewClientBuilder().
+				WithScheme(scheme).
+				WithObjects(rulesets, &wafv1alpha1.RuleSet{ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "team-a"}}).
+				WithStatusSubresource(rulesets).
+				WithInterceptorFuncs(interceptor.Funcs{
+					Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+						if obj.GetObjectKind().GroupVersionKind().Kind == "RuleSet" {
+							updates++
+							if conflicts > 0 {
+								conflicts--
+								return apierrors.NewConflict(schema.GroupResource{Group: wafv1alpha1.Group, Resource: "rulesets"}, obj.GetName(), nil)
+							}
+						}
+						return c.Update(ctx, obj, opts...)
+					},
+				}).
+				Build()
+
+			m := NewStorageVersionMigrator(c, c)
+			err := m.migrate(t.Context(), ctrl.Log, rulesets)
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "team-a/a")
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, 2, updates, "the object is read again and rewritten after a conflict")
+			}
+
+			got := &unstructured.Unstructured{}
+			got.SetGroupVersionKind(customResourceDefinitionGVK)
+			require.NoError(t, c.Get(t.Context(), client.ObjectKeyFromObject(rulesets), got))
+			versions, _, _ := unstructured.NestedStringSlice(got.Object, "status", "storedVersions")
+			assert.Equal(t, tt.wantStored, versions)
+		})
+	}
+}
@@ -206,7 +206,7 @@ func TestMain(m *testing.M) {
 // Envtest Suite - Helpers
 // -----------------------------------------------------------------------------
 
-func setupTest(t *testing.T) (context.Context, func()) {
+func setupTest(t testing.TB) (context.Context, func()) {
 	ctx := context.Background()
 
 	ns := &corev1.Namespace{}
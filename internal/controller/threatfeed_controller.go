@@ -0,0 +1,443 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/events"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// ThreatFeedReconciler - RBAC
+// -----------------------------------------------------------------------------
+
+// +kubebuilder:rbac:groups=waf.k8s.coraza.io,resources=threatfeeds,verbs=get;list;watch
+// +kubebuilder:rbac:groups=waf.k8s.coraza.io,resources=threatfeeds/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=waf.k8s.coraza.io,resources=ruledata,verbs=create;patch
+
+// The authentication Secrets of ThreatFeeds are read through Roles in the
+// namespaces that allow it, not through the ClusterRole, so that a
+// ThreatFeed can't make the operator read the Secrets of any namespace.
+
+// -----------------------------------------------------------------------------
+// ThreatFeedReconciler - Vars
+// -----------------------------------------------------------------------------
+
+const (
+	// threatFeedFetchTimeout bounds the download of a feed.
+	threatFeedFetchTimeout = 30 * time.Second
+
+	// threatFeedRetryInterval is the delay before downloading a feed again
+	// after a failed download.
+	threatFeedRetryInterval = time.Minute
+
+	// threatFeedMaxDownloadBytes bounds the size of a downloaded feed.
+	threatFeedMaxDownloadBytes = 16 << 20
+
+	// threatFeedMaxDataBytes bounds the size of the data file generated from
+	// a feed, which must fit in a RuleData object.
+	threatFeedMaxDataBytes = 1 << 20
+
+	// defaultThreatFeedRefreshInterval is used when the ThreatFeed does not
+	// set an interval.
+	defaultThreatFeedRefreshInterval = time.Hour
+)
+
+// errThreatFeedTooLarge is returned for feeds that exceed the size limits.
+var errThreatFeedTooLarge = errors.New("feed too large")
+
+// errThreatFeedHostNotAllowed is returned for feed URLs whose host is not a
+// public DNS name or address.
+var errThreatFeedHostNotAllowed = errors.New("feed host not allowed")
+
+// threatFeedSharedAddressSpace is the carrier-grade NAT range, which some
+// clusters use for Pods and Services.
+var threatFeedSharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// threatFeedInternalDomains are the DNS suffixes of hosts that are not on
+// the internet, such as Services.
+var threatFeedInternalDomains = []string{".localhost", ".local", ".internal", ".svc", ".cluster.local"}
+
+// threatFeedDataName returns the name of the RuleData, and of its single
+// data file, generated for the ThreatFeed name.
+func threatFeedDataName(name string) string {
+	return wafv1alpha1.ThreatFeedDataPrefix + name
+}
+
+// -----------------------------------------------------------------------------
+// ThreatFeedReconciler
+// -----------------------------------------------------------------------------
+
+// ThreatFeedReconciler downloads ThreatFeeds into RuleData objects.
+type ThreatFeedReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder events.EventRecorder
+
+	// apiReader reads the authentication Secrets without caching them, so
+	// that the operator only needs to get Secrets, not to list and watch
+	// them.
+	apiReader client.Reader
+
+	// httpClient downloads the feeds.
+	httpClient *http.Client
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ThreatFeedReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&wafv1alpha1.ThreatFeed{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Owns(&wafv1alpha1.RuleData{}).
+		Named("threatfeed").
+		Complete(r)
+}
+
+// -----------------------------------------------------------------------------
+// ThreatFeedReconciler - Reconcile
+// -----------------------------------------------------------------------------
+
+// Reconcile downloads the ThreatFeed into its RuleData when it is due for a
+// refresh, and schedules the next refresh.
+func (r *ThreatFeedReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := logf.FromContext(ctx)
+
+	var feed wafv1alpha1.ThreatFeed
+	if err := r.Get(ctx, req.NamespacedName, &feed); err != nil {
+		if apierrors.IsNotFound(err) {
+			logDebug(log, req, "ThreatFeed", "Resource not found, the RuleData is garbage collected")
+			return ctrl.Result{}, nil
+		}
+		logAPIError(log, req, "ThreatFeed", err, "Failed to GET", nil)
+		return ctrl.Result{}, err
+	}
+
+	interval := defaultThreatFeedRefreshInterval
+	if feed.Spec.RefreshIntervalSeconds > 0 {
+		interval = time.Duration(feed.Spec.RefreshIntervalSeconds) * time.Second
+	}
+
+	var existing wafv1alpha1.RuleData
+	dataKey := types.NamespacedName{Namespace: feed.Namespace, Name: threatFeedDataName(feed.Name)}
+	if err := r.Get(ctx, dataKey, &existing); err != nil {
+		if !apierrors.IsNotFound(err) {
+			logAPIError(log, req, "ThreatFeed", err, "Failed to GET RuleData", nil)
+			return ctrl.Result{}, err
+		}
+	} else if due := threatFeedRefreshDue(&feed, interval); due > 0 {
+		logDebug(log, req, "ThreatFeed", "Feed is up to date", "requeueAfter", due)
+		return ctrl.Result{RequeueAfter: due}, nil
+	}
+
+	logInfo(log, req, "ThreatFeed", "Downloading feed", "url", feed.Spec.URL)
+	entries, reason, err := r.fetch(ctx, &feed)
+	if err != nil {
+		logInfo(log, req, "ThreatFeed", "Failed to download feed, keeping the previous entries", "reason", reason, "error", err.Error())
+		msg := fmt.Sprintf("Failed to download %s: %v", feed.Spec.URL, err)
+		if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "ThreatFeed", &feed, &feed.Status.Conditions, feed.Generation, reason, msg); patchErr != nil {
+			return ctrl.Result{}, patchErr
+		}
+		return ctrl.Result{RequeueAfter: min(threatFeedRetryInterval, interval)}, nil
+	}
+
+	data := strings.Join(entries, "\n") + "\n"
+	if err := r.applyRuleData(ctx, log, req, &feed, data); err != nil {
+		msg := fmt.Sprintf("Failed to apply RuleData %s: %v", dataKey.Name, err)
+		if patchErr := patchDegraded(ctx, r.Status(), r.Recorder, log, req, "ThreatFeed", &feed, &feed.Status.Conditions, feed.Generation, "RuleDataFailed", msg); patchErr != nil {
+			return ctrl.Result{}, patchErr
+		}
+		return ctrl.Result{}, err
+	}
+
+	msg := fmt.Sprintf("Downloaded %d entries into RuleData %s", len(entries), dataKey.Name)
+	if existing.Spec.Files[dataKey.Name] != data {
+		r.Recorder.Eventf(&feed, nil, "Normal", "Updated", "Reconcile", msg)
+	}
+	patch := client.MergeFrom(feed.DeepCopy())
+	feed.Status.LastFetchTime = &metav1.Time{Time: time.Now()}
+	feed.Status.Entries = int32(len(entries))
+	applyStatusReady(&feed.Status.Conditions, feed.Generation, "Fetched", msg)
+	if err := r.Status().Patch(ctx, &feed, patch); err != nil {
+		logAPIError(log, req, "ThreatFeed", err, "Failed to patch status", &feed)
+		return ctrl.Result{}, err
+	}
+
+	logInfo(log, req, "ThreatFeed", "Feed downloaded", "entries", len(entries), "requeueAfter", interval)
+	return ctrl.Result{RequeueAfter: interval}, nil
+}
+
+// threatFeedRefreshDue returns the time until the next refresh of feed, or
+// zero when it is due now: it was never downloaded, its spec changed since,
+// or the interval elapsed.
+func threatFeedRefreshDue(feed *wafv1alpha1.ThreatFeed, interval time.Duration) time.Duration {
+	if feed.Status.LastFetchTime == nil {
+		return 0
+	}
+	ready := apimeta.FindStatusCondition(feed.Status.Conditions, conditionReady)
+	if ready == nil || ready.Status != metav1.ConditionTrue || ready.ObservedGeneration != feed.Generation {
+		return 0
+	}
+	return max(time.Until(feed.Status.LastFetchTime.Add(interval)), 0)
+}
+
+// applyRuleData creates or updates the RuleData of feed with data.
+func (r *ThreatFeedReconciler) applyRuleData(ctx context.Context, log logr.Logger, req ctrl.Request, feed *wafv1alpha1.ThreatFeed, data string) error {
+	name := threatFeedDataName(feed.Name)
+	ruleData := &unstructured.Unstructured{Object: map[string]any{
+		"spec": map[string]any{
+			"files": map[string]any{name: data},
+		},
+	}}
+	ruleData.SetGroupVersionKind(wafv1alpha1.GroupVersion.WithKind("RuleData"))
+	ruleData.SetName(name)
+	ruleData.SetNamespace(feed.Namespace)
+	ruleData.SetLabels(map[string]string{
+		ManagedByLabel:                     ManagedByValue,
+		wafv1alpha1.LabelExcludeFromBackup: "true",
+	})
+
+	if err := controllerutil.SetControllerReference(feed, ruleData, r.Scheme); err != nil {
+		logError(log, req, "ThreatFeed", err, "Failed to set owner reference on RuleData")
+		return err
+	}
+	if err := serverSideApply(ctx, r.Client, ruleData); err != nil {
+		logAPIError(log, req, "ThreatFeed", err, "Failed to apply RuleData", ruleData)
+		return err
+	}
+	return nil
+}
+
+// -----------------------------------------------------------------------------
+// ThreatFeedReconciler - Download
+// -----------------------------------------------------------------------------
+
+// fetch downloads and parses feed. On error, it also returns the reason to
+// report.
+func (r *ThreatFeedReconciler) fetch(ctx context.Context, feed *wafv1alpha1.ThreatFeed) (entries []string, reason string, err error) {
+	ctx, cancel := context.WithTimeout(ctx, threatFeedFetchTimeout)
+	defer cancel()
+
+	if err := validateThreatFeedURL(feed.Spec.URL); err != nil {
+		return nil, "InvalidURL", err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, feed.Spec.URL, nil)
+	if err != nil {
+		return nil, "InvalidURL", err
+	}
+	if feed.Spec.AuthSecretRef != "" {
+		var secret corev1.Secret
+		if err := r.apiReader.Get(ctx, types.NamespacedName{Namespace: feed.Namespace, Name: feed.Spec.AuthSecretRef}, &secret); err != nil {
+			if apierrors.IsForbidden(err) {
+				return nil, "AuthSecretError", fmt.Errorf("the operator may not read Secrets in namespace %s: allow it with the threatFeed.credentialNamespaces Helm value", feed.Namespace)
+			}
+			return nil, "AuthSecretError", fmt.Errorf("get Secret %s: %w", feed.Spec.AuthSecretRef, err)
+		}
+		if secret.Labels[wafv1alpha1.LabelThreatFeedCredentials] != "true" {
+			return nil, "AuthSecretError", fmt.Errorf("Secret %s is not labeled %s=true", feed.Spec.AuthSecretRef, wafv1alpha1.LabelThreatFeedCredentials)
+		}
+		authorization, ok := secret.Data[wafv1alpha1.ThreatFeedAuthorizationKey]
+		if !ok {
+			return nil, "AuthSecretError", fmt.Errorf("Secret %s has no %q key", feed.Spec.AuthSecretRef, wafv1alpha1.ThreatFeedAuthorizationKey)
+		}
+		httpReq.Header.Set("Authorization", strings.TrimSpace(string(authorization)))
+	}
+
+	resp, err := r.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, "FetchFailed", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "FetchFailed", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	entries, err = parseThreatFeed(io.LimitReader(resp.Body, threatFeedMaxDownloadBytes+1), feed.Spec.Format)
+	switch {
+	case errors.Is(err, errThreatFeedTooLarge):
+		return nil, "FeedTooLarge", err
+	case err != nil:
+		return nil, "FetchFailed", err
+	case len(entries) == 0:
+		return nil, "NoEntries", errors.New("the feed contains no valid IP address or CIDR range")
+	}
+	return entries, "", nil
+}
+
+// newThreatFeedHTTPClient returns the HTTP client downloading the feeds. It
+// only connects to public addresses, whatever the host of a feed resolves
+// to, and only follows redirects to valid feed URLs. It does not use an HTTP
+// proxy, which would connect on its behalf.
+func newThreatFeedHTTPClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: threatFeedFetchTimeout,
+		Control: func(_, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !threatFeedAddrAllowed(addrPort.Addr()) {
+				return fmt.Errorf("%w: %s is not a public address", errThreatFeedHostNotAllowed, addrPort.Addr())
+			}
+			return nil
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   threatFeedFetchTimeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return validateThreatFeedURL(req.URL.String())
+		},
+	}
+}
+
+// validateThreatFeedURL checks that raw is an HTTPS URL whose host is a
+// public DNS name or address. Names are checked again once resolved, when
+// connecting.
+func validateThreatFeedURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "https" {
+		return fmt.Errorf("the URL scheme must be https, not %q", u.Scheme)
+	}
+	if u.User != nil {
+		return errors.New("the URL must not contain credentials, use authSecretRef")
+	}
+	host := strings.ToLower(strings.TrimSuffix(u.Hostname(), "."))
+	if host == "" {
+		return errors.New("the URL has no host")
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if !threatFeedAddrAllowed(addr) {
+			return fmt.Errorf("%w: %s is not a public address", errThreatFeedHostNotAllowed, addr)
+		}
+		return nil
+	}
+	// Names without a dot resolve through the search domains of the
+	// operator pod, to Services of its namespace.
+	if host == "localhost" || !strings.Contains(host, ".") ||
+		slices.ContainsFunc(threatFeedInternalDomains, func(suffix string) bool { return strings.HasSuffix(host, suffix) }) {
+		return fmt.Errorf("%w: %s is not a public DNS name", errThreatFeedHostNotAllowed, host)
+	}
+	return nil
+}
+
+// threatFeedAddrAllowed reports whether feeds may be downloaded from addr:
+// loopback, private, link-local and shared addresses may belong to the
+// cluster or to its cloud provider, such as instance metadata services.
+func threatFeedAddrAllowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsGlobalUnicast() && !addr.IsPrivate() && !threatFeedSharedAddressSpace.Contains(addr)
+}
+
+// parseThreatFeed returns the sorted, unique IP addresses and CIDR ranges of
+// a feed in format. Lines that do not start with a valid address are
+// skipped.
+func parseThreatFeed(body io.Reader, format wafv1alpha1.ThreatFeedFormat) ([]string, error) {
+	separators := " \t;#"
+	if format == wafv1alpha1.ThreatFeedFormatCSV {
+		separators = ",;#"
+	}
+
+	seen := map[string]struct{}{}
+	read, size := 0, 0
+	scanner := bufio.NewScanner(body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if read += len(line) + 1; read > threatFeedMaxDownloadBytes {
+			return nil, fmt.Errorf("%w: larger than %d bytes", errThreatFeedTooLarge, threatFeedMaxDownloadBytes)
+		}
+
+		field := strings.TrimSpace(line)
+		if i := strings.IndexAny(field, separators); i >= 0 {
+			field = field[:i]
+		}
+		entry, ok := parseThreatFeedEntry(strings.Trim(strings.TrimSpace(field), `"`))
+		if !ok {
+			continue
+		}
+		if _, dup := seen[entry]; dup {
+			continue
+		}
+		if size += len(entry) + 1; size > threatFeedMaxDataBytes {
+			return nil, fmt.Errorf("%w: more than %d bytes of entries", errThreatFeedTooLarge, threatFeedMaxDataBytes)
+		}
+		seen[entry] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	entries := make([]string, 0, len(seen))
+	for entry := range seen {
+		entries = append(entries, entry)
+	}
+	slices.Sort(entries)
+	return entries, nil
+}
+
+// parseThreatFeedEntry returns the canonical form of an IP address or CIDR
+// range, and whether s is one.
+func parseThreatFeedEntry(s string) (string, bool) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return "", false
+		}
+		return prefix.Masked().String(), true
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil || addr.Zone() != "" {
+		return "", false
+	}
+	return addr.String(), true
+}
@@ -21,19 +21,26 @@ import (
 	"errors"
 	"fmt"
 	"io/fs"
+	"math/rand/v2"
 	"path/filepath"
+	"slices"
+	"time"
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/events"
+	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
@@ -50,6 +57,18 @@ const (
 	conditionDegraded    = "Degraded"
 	conditionProgressing = "Progressing"
 	conditionAccepted    = "Accepted"
+
+	// conditionWorkloadsSelected reports whether the workload selector of an
+	// Engine matches a running gateway pod.
+	conditionWorkloadsSelected = "WorkloadsSelected"
+
+	// conditionFailingClosed reports whether an Engine with failurePolicy
+	// fail is Degraded, so that the traffic of its gateway may be blocked.
+	conditionFailingClosed = "FailingClosed"
+
+	// conditionDataPlaneReady reports whether Istio accepted the WasmPlugin
+	// of an Engine and its gateways run it.
+	conditionDataPlaneReady = "DataPlaneReady"
 )
 
 // logInfo logs an info-level message with consistent structured context.
@@ -121,7 +140,7 @@ func extractStatusErrorFields(err error) []any {
 
 // trackedConditionTypes are the operator-owned condition types whose transitions
 // are logged at Info level.
-var trackedConditionTypes = []string{conditionReady, conditionDegraded, conditionProgressing, conditionAccepted}
+var trackedConditionTypes = []string{conditionReady, conditionDegraded, conditionProgressing, conditionAccepted, conditionWorkloadsSelected, conditionFailingClosed, conditionDataPlaneReady}
 
 // conditionSnapshot captures the Status and Reason of each tracked condition
 // type before mutation. A nil entry means the condition was absent.
@@ -225,25 +244,53 @@ func truncateEventNote(msg string) string {
 	return msg[:maxEventNoteBytes-3] + "..."
 }
 
-// patchDegraded marks a resource as Degraded, emits a Warning event, and
-// patches the status in a single call. It consolidates the repeated pattern of
-// Eventf → DeepCopy → apply status → Status().Patch → logConditionTransitions on success.
-func patchDegraded(
+// conditionsEqual reports whether two condition lists are semantically equal.
+// Order and LastTransitionTime are ignored, so re-applying an unchanged state
+// compares equal even though the setters stamp a fresh timestamp.
+func conditionsEqual(a, b []metav1.Condition) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for _, ca := range a {
+		cb := apimeta.FindStatusCondition(b, ca.Type)
+		if cb == nil ||
+			ca.Status != cb.Status ||
+			ca.Reason != cb.Reason ||
+			ca.Message != cb.Message ||
+			ca.ObservedGeneration != cb.ObservedGeneration {
+			return false
+		}
+	}
+	return true
+}
+
+// patchConditions applies mutate to conditions and patches the status
+// subresource. mutate may change other status fields too. When the mutation
+// leaves the conditions semantically unchanged (see conditionsEqual) and the
+// rest of the status equal, the API call is skipped and the status restored,
+// so steady-state reconciles don't generate status writes.
+func patchConditions(
 	ctx context.Context,
 	statusWriter client.StatusWriter,
-	recorder events.EventRecorder,
 	log logr.Logger,
 	req ctrl.Request,
 	kind string,
 	obj client.Object,
 	conditions *[]metav1.Condition,
-	generation int64,
-	reason, message string,
+	mutate func(),
 ) error {
-	recorder.Eventf(obj, nil, "Warning", reason, "Reconcile", truncateEventNote(message))
-	patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
+	base := obj.DeepCopyObject().(client.Object)
+	patch := client.MergeFrom(base)
 	before := snapshotConditions(*conditions)
-	applyStatusConditionDegraded(conditions, generation, reason, message)
+	original := slices.Clone(*conditions)
+	mutate()
+	if conditionsEqual(original, *conditions) && statusEqualIgnoringConditions(base, obj) {
+		// The rest of the status is equal, so restoring the conditions
+		// restores the status.
+		*conditions = original
+		logDebug(log, req, kind, "Status unchanged, skipping patch")
+		return nil
+	}
 	if err := statusWriter.Patch(ctx, obj, patch); err != nil {
 		logAPIError(log, req, kind, err, "Failed to patch status", obj)
 		return err
@@ -252,19 +299,29 @@ func patchDegraded(
 	return nil
 }
 
-// applyStatusNotAccepted mutates conditions to signal that the Engine is not
-// accepted (e.g., target not found or target conflict). It clears Progressing
-// and Degraded and sets Ready=False.
-func applyStatusNotAccepted(conditions *[]metav1.Condition, generation int64, reason, message string) {
-	setConditionFalse(conditions, generation, conditionAccepted, reason, message)
-	setConditionFalse(conditions, generation, conditionReady, reason, message)
-	apimeta.RemoveStatusCondition(conditions, conditionDegraded)
-	apimeta.RemoveStatusCondition(conditions, conditionProgressing)
+// statusEqualIgnoringConditions reports whether the status of a and b is
+// semantically equal apart from status.conditions, which conditionsEqual
+// compares. Objects that cannot be converted are reported as different, so
+// that their status is patched.
+func statusEqualIgnoringConditions(a, b client.Object) bool {
+	status := func(obj client.Object) (any, bool) {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return nil, false
+		}
+		unstructured.RemoveNestedField(content, "status", "conditions")
+		return content["status"], true
+	}
+	statusA, okA := status(a)
+	statusB, okB := status(b)
+	return okA && okB && equality.Semantic.DeepEqual(statusA, statusB)
 }
 
-// patchNotAccepted marks a resource as not accepted, emits a Warning event,
-// and patches the status in a single call.
-func patchNotAccepted(
+// patchDegraded marks a resource as Degraded, emits a Warning event, and
+// patches the status in a single call. It consolidates the repeated pattern of
+// Eventf → apply status → Status().Patch (skipped when unchanged) →
+// logConditionTransitions on success.
+func patchDegraded(
 	ctx context.Context,
 	statusWriter client.StatusWriter,
 	recorder events.EventRecorder,
@@ -277,15 +334,51 @@ func patchNotAccepted(
 	reason, message string,
 ) error {
 	recorder.Eventf(obj, nil, "Warning", reason, "Reconcile", truncateEventNote(message))
-	patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
-	before := snapshotConditions(*conditions)
-	applyStatusNotAccepted(conditions, generation, reason, message)
-	if err := statusWriter.Patch(ctx, obj, patch); err != nil {
-		logAPIError(log, req, kind, err, "Failed to patch status", obj)
-		return err
+	return patchConditions(ctx, statusWriter, log, req, kind, obj, conditions, func() {
+		applyStatusConditionDegraded(conditions, generation, reason, message)
+	})
+}
+
+// patchDegradedOnChange is patchDegraded for causes shared by many objects,
+// such as a RuleSet referenced by several Engines. When the object is already
+// Degraded with the same reason and message for this generation, it neither
+// records an event nor patches status, so a shared root cause produces one
+// event per dependent rather than one per reconcile.
+func patchDegradedOnChange(
+	ctx context.Context,
+	statusWriter client.StatusWriter,
+	recorder events.EventRecorder,
+	log logr.Logger,
+	req ctrl.Request,
+	kind string,
+	obj client.Object,
+	conditions *[]metav1.Condition,
+	generation int64,
+	reason, message string,
+) error {
+	if cond := apimeta.FindStatusCondition(*conditions, conditionDegraded); cond != nil &&
+		cond.Status == metav1.ConditionTrue &&
+		cond.Reason == reason &&
+		cond.Message == message &&
+		cond.ObservedGeneration == generation {
+		logDebug(log, req, kind, "Already degraded for the same cause; skipping status patch", "reason", reason)
+		return nil
 	}
-	logConditionTransitions(log, req, kind, before, *conditions)
-	return nil
+	return patchDegraded(ctx, statusWriter, recorder, log, req, kind, obj, conditions, generation, reason, message)
+}
+
+// applyStatusNotAccepted mutates conditions to signal that the Engine is not
+// accepted (e.g., target not found or target conflict). It clears Progressing,
+// Degraded, WorkloadsSelected, FailingClosed and DataPlaneReady and sets
+// Ready=False.
+func applyStatusNotAccepted(conditions *[]metav1.Condition, generation int64, reason, message string) {
+	setConditionFalse(conditions, generation, conditionAccepted, reason, message)
+	setConditionFalse(conditions, generation, conditionReady, reason, message)
+	apimeta.RemoveStatusCondition(conditions, conditionDegraded)
+	apimeta.RemoveStatusCondition(conditions, conditionProgressing)
+	apimeta.RemoveStatusCondition(conditions, conditionWorkloadsSelected)
+	apimeta.RemoveStatusCondition(conditions, conditionFailingClosed)
+	apimeta.RemoveStatusCondition(conditions, conditionDataPlaneReady)
 }
 
 // applyStatusReady mutates conditions to Ready=True, clears Degraded and
@@ -311,15 +404,9 @@ func patchReady(
 	reason, message string,
 ) error {
 	recorder.Eventf(obj, nil, "Normal", reason, "Reconcile", truncateEventNote(message))
-	patch := client.MergeFrom(obj.DeepCopyObject().(client.Object))
-	before := snapshotConditions(*conditions)
-	applyStatusReady(conditions, generation, reason, message)
-	if err := statusWriter.Patch(ctx, obj, patch); err != nil {
-		logAPIError(log, req, kind, err, "Failed to patch status", obj)
-		return err
-	}
-	logConditionTransitions(log, req, kind, before, *conditions)
-	return nil
+	return patchConditions(ctx, statusWriter, log, req, kind, obj, conditions, func() {
+		applyStatusReady(conditions, generation, reason, message)
+	})
 }
 
 // -----------------------------------------------------------------------------
@@ -347,6 +434,96 @@ func collectRequests[E any, P interface {
 	return requests
 }
 
+// debouncedEnqueueRequestsFromMapFunc behaves like
+// handler.EnqueueRequestsFromMapFunc, except that mapped requests are added
+// to the queue after the window instead of immediately. The workqueue keeps a
+// single pending entry per request with the earliest deadline, so a burst of
+// events for the same object inside the window coalesces into one reconcile
+// that observes the final state. The window is evaluated for every event so
+// that it can be changed at runtime; a non-positive window enqueues
+// immediately.
+func debouncedEnqueueRequestsFromMapFunc(fn handler.MapFunc, window func() time.Duration) handler.EventHandler {
+	enqueue := func(ctx context.Context, obj client.Object, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+		if obj == nil {
+			return
+		}
+		delay := window()
+		for _, req := range fn(ctx, obj) {
+			if delay <= 0 {
+				q.Add(req)
+				continue
+			}
+			q.AddAfter(req, delay)
+		}
+	}
+
+	return handler.Funcs{
+		CreateFunc: func(ctx context.Context, e event.CreateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			enqueue(ctx, e.Object, q)
+		},
+		UpdateFunc: func(ctx context.Context, e event.UpdateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			enqueue(ctx, e.ObjectOld, q)
+			enqueue(ctx, e.ObjectNew, q)
+		},
+		DeleteFunc: func(ctx context.Context, e event.DeleteEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			enqueue(ctx, e.Object, q)
+		},
+		GenericFunc: func(ctx context.Context, e event.GenericEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			enqueue(ctx, e.Object, q)
+		},
+	}
+}
+
+// jitteredEnqueueRequestsFromMapFunc behaves like
+// handler.EnqueueRequestsFromMapFunc, except that when a single event maps to
+// more than one request, each request is delayed by a random duration. The
+// spread grows with the fan-out (perRequest per mapped request) up to
+// maxSpread, so a change to an object shared by thousands of dependents
+// results in their reconciles, and the status writes they produce, being
+// spread out instead of hitting the API server at once. Single-request
+// fan-outs are enqueued immediately.
+func jitteredEnqueueRequestsFromMapFunc(fn handler.MapFunc, perRequest, maxSpread time.Duration) handler.EventHandler {
+	enqueue := func(ctx context.Context, obj client.Object, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+		if obj == nil {
+			return
+		}
+		reqs := fn(ctx, obj)
+		spread := fanOutSpread(len(reqs), perRequest, maxSpread)
+		for _, req := range reqs {
+			if spread <= 0 {
+				q.Add(req)
+				continue
+			}
+			q.AddAfter(req, rand.N(spread))
+		}
+	}
+
+	return handler.Funcs{
+		CreateFunc: func(ctx context.Context, e event.CreateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			enqueue(ctx, e.Object, q)
+		},
+		UpdateFunc: func(ctx context.Context, e event.UpdateEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			enqueue(ctx, e.ObjectOld, q)
+			enqueue(ctx, e.ObjectNew, q)
+		},
+		DeleteFunc: func(ctx context.Context, e event.DeleteEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			enqueue(ctx, e.Object, q)
+		},
+		GenericFunc: func(ctx context.Context, e event.GenericEvent, q workqueue.TypedRateLimitingInterface[reconcile.Request]) {
+			enqueue(ctx, e.Object, q)
+		},
+	}
+}
+
+// fanOutSpread returns the window over which n requests produced by a single
+// event are spread. It is zero when n <= 1.
+func fanOutSpread(n int, perRequest, maxSpread time.Duration) time.Duration {
+	if n <= 1 || perRequest <= 0 || maxSpread <= 0 {
+		return 0
+	}
+	return min(time.Duration(n)*perRequest, maxSpread)
+}
+
 // -----------------------------------------------------------------------------
 // Predicate Helpers
 // -----------------------------------------------------------------------------
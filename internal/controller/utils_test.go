@@ -17,17 +17,29 @@ limitations under the License.
 package controller
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io/fs"
 	"strings"
 	"syscall"
 	"testing"
+	"time"
 
+	"github.com/go-logr/logr"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
@@ -90,6 +102,222 @@ func TestCollectRequests(t *testing.T) {
 	})
 }
 
+func TestDebouncedEnqueueRequestsFromMapFunc(t *testing.T) {
+	source := &wafv1alpha1.RuleSource{ObjectMeta: metav1.ObjectMeta{Name: "src", Namespace: "ns"}}
+	want := reconcile.Request{NamespacedName: types.NamespacedName{Name: "rs", Namespace: "ns"}}
+	mapFn := func(_ context.Context, _ client.Object) []reconcile.Request {
+		return []reconcile.Request{want}
+	}
+	newQueue := func() workqueue.TypedRateLimitingInterface[reconcile.Request] {
+		q := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+		t.Cleanup(q.ShutDown)
+		return q
+	}
+	fixed := func(d time.Duration) func() time.Duration {
+		return func() time.Duration { return d }
+	}
+
+	t.Run("zero window enqueues immediately", func(t *testing.T) {
+		q := newQueue()
+		h := debouncedEnqueueRequestsFromMapFunc(mapFn, fixed(0))
+		h.Create(t.Context(), event.CreateEvent{Object: source}, q)
+		assert.Equal(t, 1, q.Len())
+	})
+
+	t.Run("burst coalesces into one request after the window", func(t *testing.T) {
+		q := newQueue()
+		h := debouncedEnqueueRequestsFromMapFunc(mapFn, fixed(50*time.Millisecond))
+		for range 10 {
+			h.Update(t.Context(), event.UpdateEvent{ObjectOld: source, ObjectNew: source}, q)
+		}
+		assert.Equal(t, 0, q.Len(), "requests must not be enqueued before the window elapses")
+
+		require.Eventually(t, func() bool { return q.Len() == 1 }, 5*time.Second, 10*time.Millisecond)
+		got, _ := q.Get()
+		assert.Equal(t, want, got)
+		q.Done(got)
+
+		// Nothing else may follow: all ten events collapsed into one request.
+		time.Sleep(100 * time.Millisecond)
+		assert.Equal(t, 0, q.Len())
+	})
+
+	t.Run("nil object is ignored", func(t *testing.T) {
+		q := newQueue()
+		h := debouncedEnqueueRequestsFromMapFunc(mapFn, fixed(time.Millisecond))
+		h.Delete(t.Context(), event.DeleteEvent{}, q)
+		time.Sleep(20 * time.Millisecond)
+		assert.Equal(t, 0, q.Len())
+	})
+
+	t.Run("window is read on every event", func(t *testing.T) {
+		q := newQueue()
+		window := time.Hour
+		h := debouncedEnqueueRequestsFromMapFunc(mapFn, func() time.Duration { return window })
+		h.Create(t.Context(), event.CreateEvent{Object: source}, q)
+		assert.Equal(t, 0, q.Len())
+
+		window = 0
+		h.Create(t.Context(), event.CreateEvent{Object: source}, q)
+		assert.Equal(t, 1, q.Len())
+	})
+}
+
+func TestFanOutSpread(t *testing.T) {
+	tests := []struct {
+		name string
+		n    int
+		want time.Duration
+	}{
+		{name: "no requests", n: 0, want: 0},
+		{name: "single request is immediate", n: 1, want: 0},
+		{name: "scales with fan-out", n: 100, want: time.Second},
+		{name: "capped at max spread", n: 10000, want: 30 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, fanOutSpread(tt.n, 10*time.Millisecond, 30*time.Second))
+		})
+	}
+}
+
+func TestJitteredEnqueueRequestsFromMapFunc(t *testing.T) {
+	source := &wafv1alpha1.RuleSet{ObjectMeta: metav1.ObjectMeta{Name: "rs", Namespace: "ns"}}
+	newQueue := func() workqueue.TypedRateLimitingInterface[reconcile.Request] {
+		q := workqueue.NewTypedRateLimitingQueue(workqueue.DefaultTypedControllerRateLimiter[reconcile.Request]())
+		t.Cleanup(q.ShutDown)
+		return q
+	}
+	mapTo := func(n int) handler.MapFunc {
+		return func(_ context.Context, _ client.Object) []reconcile.Request {
+			reqs := make([]reconcile.Request, n)
+			for i := range reqs {
+				reqs[i] = reconcile.Request{NamespacedName: types.NamespacedName{Name: fmt.Sprintf("engine-%d", i), Namespace: "ns"}}
+			}
+			return reqs
+		}
+	}
+
+	t.Run("single request is enqueued immediately", func(t *testing.T) {
+		q := newQueue()
+		h := jitteredEnqueueRequestsFromMapFunc(mapTo(1), time.Hour, time.Hour)
+		h.Update(t.Context(), event.UpdateEvent{ObjectOld: source, ObjectNew: source}, q)
+		assert.Equal(t, 1, q.Len())
+	})
+
+	t.Run("fan-out is spread and eventually fully enqueued", func(t *testing.T) {
+		q := newQueue()
+		h := jitteredEnqueueRequestsFromMapFunc(mapTo(50), 4*time.Millisecond, 200*time.Millisecond)
+		h.Create(t.Context(), event.CreateEvent{Object: source}, q)
+		assert.Less(t, q.Len(), 50, "requests must not all be enqueued at once")
+		require.Eventually(t, func() bool { return q.Len() == 50 }, 5*time.Second, 10*time.Millisecond)
+	})
+}
+
+func TestConditionsEqual(t *testing.T) {
+	base := []metav1.Condition{
+		{Type: conditionReady, Status: metav1.ConditionTrue, Reason: "RulesCached", Message: "ok", ObservedGeneration: 1, LastTransitionTime: metav1.NewTime(time.Unix(100, 0))},
+		{Type: conditionAccepted, Status: metav1.ConditionTrue, Reason: "Accepted", Message: "ok", ObservedGeneration: 1},
+	}
+	modify := func(f func(c []metav1.Condition) []metav1.Condition) []metav1.Condition {
+		return f(append([]metav1.Condition(nil), base...))
+	}
+
+	tests := []struct {
+		name  string
+		other []metav1.Condition
+		want  bool
+	}{
+		{name: "identical", other: modify(func(c []metav1.Condition) []metav1.Condition { return c }), want: true},
+		{name: "timestamp ignored", other: modify(func(c []metav1.Condition) []metav1.Condition {
+			c[0].LastTransitionTime = metav1.Now()
+			return c
+		}), want: true},
+		{name: "order ignored", other: modify(func(c []metav1.Condition) []metav1.Condition {
+			return []metav1.Condition{c[1], c[0]}
+		}), want: true},
+		{name: "status differs", other: modify(func(c []metav1.Condition) []metav1.Condition {
+			c[0].Status = metav1.ConditionFalse
+			return c
+		}), want: false},
+		{name: "reason differs", other: modify(func(c []metav1.Condition) []metav1.Condition {
+			c[0].Reason = "Other"
+			return c
+		}), want: false},
+		{name: "message differs", other: modify(func(c []metav1.Condition) []metav1.Condition {
+			c[1].Message = "changed"
+			return c
+		}), want: false},
+		{name: "generation differs", other: modify(func(c []metav1.Condition) []metav1.Condition {
+			c[1].ObservedGeneration = 2
+			return c
+		}), want: false},
+		{name: "condition removed", other: modify(func(c []metav1.Condition) []metav1.Condition { return c[:1] }), want: false},
+		{name: "condition replaced", other: modify(func(c []metav1.Condition) []metav1.Condition {
+			c[1].Type = conditionDegraded
+			return c
+		}), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, conditionsEqual(base, tt.other))
+		})
+	}
+}
+
+func TestPatchConditionsSkipsNoop(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+
+	ruleset := &wafv1alpha1.RuleSet{ObjectMeta: metav1.ObjectMeta{Name: "rs", Namespace: "ns", Generation: 1}}
+	var patches int
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(ruleset).
+		WithStatusSubresource(ruleset).
+		WithInterceptorFuncs(interceptor.Funcs{
+			SubResourcePatch: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+				patches++
+				return c.SubResource(subResourceName).Patch(ctx, obj, patch, opts...)
+			},
+		}).
+		Build()
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "rs", Namespace: "ns"}}
+	log := logr.Discard()
+	setReady := func() error {
+		return patchConditions(t.Context(), c.Status(), log, req, "RuleSet", ruleset, &ruleset.Status.Conditions, func() {
+			applyStatusReady(&ruleset.Status.Conditions, ruleset.Generation, "RulesCached", "cached")
+		})
+	}
+
+	require.NoError(t, setReady())
+	assert.Equal(t, 1, patches)
+	firstTransition := apimeta.FindStatusCondition(ruleset.Status.Conditions, conditionReady).LastTransitionTime
+
+	require.NoError(t, setReady())
+	require.NoError(t, setReady())
+	assert.Equal(t, 1, patches, "unchanged conditions must not be patched")
+	assert.Equal(t, firstTransition, apimeta.FindStatusCondition(ruleset.Status.Conditions, conditionReady).LastTransitionTime)
+
+	require.NoError(t, patchConditions(t.Context(), c.Status(), log, req, "RuleSet", ruleset, &ruleset.Status.Conditions, func() {
+		applyStatusConditionDegraded(&ruleset.Status.Conditions, ruleset.Generation, "InvalidRuleSet", "broken")
+	}))
+	assert.Equal(t, 2, patches)
+
+	// Other status fields changed by mutate are patched even when the
+	// conditions are unchanged.
+	require.NoError(t, patchConditions(t.Context(), c.Status(), log, req, "RuleSet", ruleset, &ruleset.Status.Conditions, func() {
+		applyStatusConditionDegraded(&ruleset.Status.Conditions, ruleset.Generation, "InvalidRuleSet", "broken")
+		ruleset.Status.PendingRevision = "pending"
+	}))
+	assert.Equal(t, 3, patches, "status fields other than conditions must be patched")
+	var got wafv1alpha1.RuleSet
+	require.NoError(t, c.Get(t.Context(), req.NamespacedName, &got))
+	assert.Equal(t, "pending", got.Status.PendingRevision)
+}
+
 func TestExtractMissingFileBasename(t *testing.T) {
 	tests := []struct {
 		name     string
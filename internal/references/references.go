@@ -0,0 +1,212 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package references resolves references between objects, permitting those
+// that cross namespaces only when the referenced namespace grants them.
+//
+// A reference from an object of kind From to an object in another namespace
+// is permitted when either:
+//
+//   - a Gateway API ReferenceGrant in the referenced namespace lists the From
+//     group, kind and namespace, and the referenced group, kind and
+//     optionally name; or
+//   - the referenced object carries the waf.k8s.coraza.io/allow-references-from
+//     annotation listing the From namespace, or "*".
+//
+// References that are not permitted resolve to an error matched by
+// IsNotPermitted, which controllers report with ReasonRefNotPermitted. The
+// existence of an object that may not be referenced is not disclosed.
+package references
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// References - Vars
+// -----------------------------------------------------------------------------
+
+// ReasonRefNotPermitted is the condition reason reported for a reference to
+// another namespace that no ReferenceGrant or annotation permits.
+const ReasonRefNotPermitted = "RefNotPermitted"
+
+// ReferenceGrantGVK is the GroupVersionKind of the Gateway API ReferenceGrant.
+var ReferenceGrantGVK = schema.GroupVersionKind{
+	Group:   "gateway.networking.k8s.io",
+	Version: "v1beta1",
+	Kind:    "ReferenceGrant",
+}
+
+// errNotPermitted is wrapped by the errors of references that are not
+// permitted.
+var errNotPermitted = errors.New("reference not permitted")
+
+// IsNotPermitted reports whether err is the error of a reference that is not
+// permitted.
+func IsNotPermitted(err error) bool {
+	return errors.Is(err, errNotPermitted)
+}
+
+// -----------------------------------------------------------------------------
+// References - Resolver
+// -----------------------------------------------------------------------------
+
+// From identifies the kind and namespace of the referencing object.
+type From struct {
+	Group     string
+	Kind      string
+	Namespace string
+}
+
+// Resolver gets referenced objects on behalf of referencing objects.
+type Resolver struct {
+	reader          client.Reader
+	scheme          *runtime.Scheme
+	referenceGrants bool
+}
+
+// NewResolver returns a Resolver reading with reader. The group and kind of
+// referenced objects are looked up in scheme. ReferenceGrants are only
+// consulted when referenceGrants is true, that is when the ReferenceGrant API
+// is installed; the annotation is always honored.
+func NewResolver(reader client.Reader, scheme *runtime.Scheme, referenceGrants bool) *Resolver {
+	return &Resolver{reader: reader, scheme: scheme, referenceGrants: referenceGrants}
+}
+
+// Get fetches the object key into obj on behalf of an object of kind from.
+// Objects in the from namespace are always permitted. Otherwise, the error
+// matches IsNotPermitted when the reference is not permitted, including when
+// the object does not exist and no ReferenceGrant permits it.
+func (r *Resolver) Get(ctx context.Context, from From, key types.NamespacedName, obj client.Object) error {
+	if key.Namespace == from.Namespace {
+		return r.reader.Get(ctx, key, obj)
+	}
+
+	gvk, err := apiutil.GVKForObject(obj, r.scheme)
+	if err != nil {
+		return err
+	}
+	granted, err := r.granted(ctx, from, gvk.GroupKind(), key)
+	if err != nil {
+		return err
+	}
+
+	if err := r.reader.Get(ctx, key, obj); err != nil {
+		if apierrors.IsNotFound(err) && !granted {
+			return notPermitted(from, gvk.Kind, key)
+		}
+		return err
+	}
+	if granted || AnnotationAllows(obj, from.Namespace) {
+		return nil
+	}
+	return notPermitted(from, gvk.Kind, key)
+}
+
+// granted reports whether a ReferenceGrant in the namespace of key permits
+// the reference.
+func (r *Resolver) granted(ctx context.Context, from From, to schema.GroupKind, key types.NamespacedName) (bool, error) {
+	if !r.referenceGrants {
+		return false, nil
+	}
+
+	grants := &unstructured.UnstructuredList{}
+	grants.SetGroupVersionKind(ReferenceGrantGVK.GroupVersion().WithKind(ReferenceGrantGVK.Kind + "List"))
+	if err := r.reader.List(ctx, grants, client.InNamespace(key.Namespace)); err != nil {
+		return false, fmt.Errorf("list ReferenceGrants in %s: %w", key.Namespace, err)
+	}
+	for i := range grants.Items {
+		if grantPermits(&grants.Items[i], from, to, key.Name) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// grantPermits reports whether the ReferenceGrant lists from in spec.from and
+// the referenced kind and name in spec.to.
+func grantPermits(grant *unstructured.Unstructured, from From, to schema.GroupKind, name string) bool {
+	fromEntries, _, _ := unstructured.NestedSlice(grant.Object, "spec", "from")
+	fromMatched := slices.ContainsFunc(fromEntries, func(entry any) bool {
+		m, ok := entry.(map[string]any)
+		return ok && m["group"] == from.Group && m["kind"] == from.Kind && m["namespace"] == from.Namespace
+	})
+	if !fromMatched {
+		return false
+	}
+
+	toEntries, _, _ := unstructured.NestedSlice(grant.Object, "spec", "to")
+	return slices.ContainsFunc(toEntries, func(entry any) bool {
+		m, ok := entry.(map[string]any)
+		if !ok || m["group"] != to.Group || m["kind"] != to.Kind {
+			return false
+		}
+		grantedName, _ := m["name"].(string)
+		return grantedName == "" || grantedName == name
+	})
+}
+
+// GrantedNamespaces returns the namespaces the ReferenceGrant permits objects
+// of kind from to reference its namespace from.
+func GrantedNamespaces(grant *unstructured.Unstructured, fromGroup, fromKind string) []string {
+	var namespaces []string
+	fromEntries, _, _ := unstructured.NestedSlice(grant.Object, "spec", "from")
+	for _, entry := range fromEntries {
+		m, ok := entry.(map[string]any)
+		if !ok || m["group"] != fromGroup || m["kind"] != fromKind {
+			continue
+		}
+		if ns, _ := m["namespace"].(string); ns != "" && !slices.Contains(namespaces, ns) {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	return namespaces
+}
+
+// AnnotationAllows reports whether the allow-references-from annotation of
+// obj lists namespace, or "*".
+func AnnotationAllows(obj client.Object, namespace string) bool {
+	value, ok := obj.GetAnnotations()[wafv1alpha1.AnnotationAllowReferencesFrom]
+	if !ok {
+		return false
+	}
+	for allowed := range strings.SplitSeq(value, ",") {
+		allowed = strings.TrimSpace(allowed)
+		if allowed == "*" || allowed == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+func notPermitted(from From, kind string, key types.NamespacedName) error {
+	return fmt.Errorf("%w: %s in namespace %s may not reference %s %s: no ReferenceGrant or %s annotation permits it",
+		errNotPermitted, from.Kind, from.Namespace, kind, key, wafv1alpha1.AnnotationAllowReferencesFrom)
+}
@@ -0,0 +1,202 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package references
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+var ruleSetFrom = From{Group: wafv1alpha1.GroupVersion.Group, Kind: "RuleSet", Namespace: "team-a"}
+
+func newScheme(t *testing.T) *runtime.Scheme {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+	scheme.AddKnownTypeWithName(ReferenceGrantGVK, &unstructured.Unstructured{})
+	scheme.AddKnownTypeWithName(ReferenceGrantGVK.GroupVersion().WithKind(ReferenceGrantGVK.Kind+"List"), &unstructured.UnstructuredList{})
+	return scheme
+}
+
+func referenceGrant(namespace, name string, from []any, to []any) *unstructured.Unstructured {
+	grant := &unstructured.Unstructured{Object: map[string]any{
+		"spec": map[string]any{"from": from, "to": to},
+	}}
+	grant.SetGroupVersionKind(ReferenceGrantGVK)
+	grant.SetNamespace(namespace)
+	grant.SetName(name)
+	return grant
+}
+
+func ruleSourceGrant(fromNamespace, name string) *unstructured.Unstructured {
+	return referenceGrant("shared", "rulesets",
+		[]any{map[string]any{"group": wafv1alpha1.GroupVersion.Group, "kind": "RuleSet", "namespace": fromNamespace}},
+		[]any{map[string]any{"group": wafv1alpha1.GroupVersion.Group, "kind": "RuleSource", "name": name}},
+	)
+}
+
+func ruleSource(namespace, allowFrom string) *wafv1alpha1.RuleSource {
+	rs := &wafv1alpha1.RuleSource{
+		ObjectMeta: metav1.ObjectMeta{Name: "crs", Namespace: namespace},
+		Spec:       wafv1alpha1.RuleSourceSpec{Rules: "SecRuleEngine On"},
+	}
+	if allowFrom != "" {
+		rs.Annotations = map[string]string{wafv1alpha1.AnnotationAllowReferencesFrom: allowFrom}
+	}
+	return rs
+}
+
+func TestResolver_Get(t *testing.T) {
+	tests := []struct {
+		name            string
+		objects         []client.Object
+		key             types.NamespacedName
+		referenceGrants bool
+		wantErr         func(error) bool
+	}{
+		{
+			name:    "same namespace",
+			objects: []client.Object{ruleSource("team-a", "")},
+			key:     types.NamespacedName{Namespace: "team-a", Name: "crs"},
+		},
+		{
+			name:    "same namespace not found",
+			key:     types.NamespacedName{Namespace: "team-a", Name: "crs"},
+			wantErr: apierrors.IsNotFound,
+		},
+		{
+			name:            "other namespace without grant",
+			objects:         []client.Object{ruleSource("shared", "")},
+			key:             types.NamespacedName{Namespace: "shared", Name: "crs"},
+			referenceGrants: true,
+			wantErr:         IsNotPermitted,
+		},
+		{
+			name:            "other namespace without grant hides existence",
+			key:             types.NamespacedName{Namespace: "shared", Name: "crs"},
+			referenceGrants: true,
+			wantErr:         IsNotPermitted,
+		},
+		{
+			name:            "granted by ReferenceGrant",
+			objects:         []client.Object{ruleSource("shared", ""), ruleSourceGrant("team-a", "")},
+			key:             types.NamespacedName{Namespace: "shared", Name: "crs"},
+			referenceGrants: true,
+		},
+		{
+			name:            "granted by ReferenceGrant naming the object",
+			objects:         []client.Object{ruleSource("shared", ""), ruleSourceGrant("team-a", "crs")},
+			key:             types.NamespacedName{Namespace: "shared", Name: "crs"},
+			referenceGrants: true,
+		},
+		{
+			name:            "granted object not found",
+			objects:         []client.Object{ruleSourceGrant("team-a", "")},
+			key:             types.NamespacedName{Namespace: "shared", Name: "crs"},
+			referenceGrants: true,
+			wantErr:         apierrors.IsNotFound,
+		},
+		{
+			name:            "ReferenceGrant naming another object",
+			objects:         []client.Object{ruleSource("shared", ""), ruleSourceGrant("team-a", "other")},
+			key:             types.NamespacedName{Namespace: "shared", Name: "crs"},
+			referenceGrants: true,
+			wantErr:         IsNotPermitted,
+		},
+		{
+			name:            "ReferenceGrant for another namespace",
+			objects:         []client.Object{ruleSource("shared", ""), ruleSourceGrant("team-b", "")},
+			key:             types.NamespacedName{Namespace: "shared", Name: "crs"},
+			referenceGrants: true,
+			wantErr:         IsNotPermitted,
+		},
+		{
+			name: "ReferenceGrant for another kind",
+			objects: []client.Object{ruleSource("shared", ""), referenceGrant("shared", "data",
+				[]any{map[string]any{"group": wafv1alpha1.GroupVersion.Group, "kind": "RuleSet", "namespace": "team-a"}},
+				[]any{map[string]any{"group": wafv1alpha1.GroupVersion.Group, "kind": "RuleData"}},
+			)},
+			key:             types.NamespacedName{Namespace: "shared", Name: "crs"},
+			referenceGrants: true,
+			wantErr:         IsNotPermitted,
+		},
+		{
+			name:    "ReferenceGrants ignored without the API",
+			objects: []client.Object{ruleSource("shared", ""), ruleSourceGrant("team-a", "")},
+			key:     types.NamespacedName{Namespace: "shared", Name: "crs"},
+			wantErr: IsNotPermitted,
+		},
+		{
+			name:    "granted by annotation",
+			objects: []client.Object{ruleSource("shared", "team-b, team-a")},
+			key:     types.NamespacedName{Namespace: "shared", Name: "crs"},
+		},
+		{
+			name:    "granted by wildcard annotation",
+			objects: []client.Object{ruleSource("shared", "*")},
+			key:     types.NamespacedName{Namespace: "shared", Name: "crs"},
+		},
+		{
+			name:    "annotation for another namespace",
+			objects: []client.Object{ruleSource("shared", "team-b")},
+			key:     types.NamespacedName{Namespace: "shared", Name: "crs"},
+			wantErr: IsNotPermitted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := newScheme(t)
+			reader := fake.NewClientBuilder().WithScheme(scheme).WithObjects(tt.objects...).Build()
+			resolver := NewResolver(reader, scheme, tt.referenceGrants)
+
+			var got wafv1alpha1.RuleSource
+			err := resolver.Get(t.Context(), ruleSetFrom, tt.key, &got)
+			if tt.wantErr != nil {
+				require.Error(t, err)
+				assert.True(t, tt.wantErr(err), "unexpected error: %v", err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "SecRuleEngine On", got.Spec.Rules)
+		})
+	}
+}
+
+func TestGrantedNamespaces(t *testing.T) {
+	grant := referenceGrant("shared", "rulesets",
+		[]any{
+			map[string]any{"group": wafv1alpha1.GroupVersion.Group, "kind": "RuleSet", "namespace": "team-a"},
+			map[string]any{"group": wafv1alpha1.GroupVersion.Group, "kind": "Engine", "namespace": "team-b"},
+			map[string]any{"group": wafv1alpha1.GroupVersion.Group, "kind": "RuleSet", "namespace": "team-c"},
+			map[string]any{"group": wafv1alpha1.GroupVersion.Group, "kind": "RuleSet", "namespace": "team-a"},
+		},
+		[]any{map[string]any{"group": wafv1alpha1.GroupVersion.Group, "kind": "RuleSource"}},
+	)
+	assert.Equal(t, []string{"team-a", "team-c"}, GrantedNamespaces(grant, wafv1alpha1.GroupVersion.Group, "RuleSet"))
+}
@@ -19,6 +19,13 @@ package cache
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"io"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
@@ -76,26 +83,38 @@ func (c *RuleSetCache) SetLogger(l logr.Logger) {
 
 // Get retrieves the latest ruleset entry for the given instance
 func (c *RuleSetCache) Get(instance string) (*RuleSetEntry, bool) {
+	entry, ok := c.latest(instance)
+	if !ok {
+		return nil, false
+	}
+
+	var copiedDataFiles map[string][]byte
+	if entry.DataFiles != nil {
+		copiedDataFiles = make(map[string][]byte, len(entry.DataFiles))
+		for name, contents := range entry.DataFiles {
+			copiedDataFiles[name] = bytes.Clone(contents)
+		}
+	}
+	return &RuleSetEntry{
+		UUID:      entry.UUID,
+		Timestamp: entry.Timestamp,
+		Rules:     entry.Rules,
+		DataFiles: copiedDataFiles,
+	}, true
+}
+
+// latest returns the stored latest entry for the given instance without
+// copying it. Stored entries are never modified after Put, so the result is
+// safe for concurrent read-only use (e.g. streaming it to a client), but
+// callers must not mutate it.
+func (c *RuleSetCache) latest(instance string) (*RuleSetEntry, bool) {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 	entries, ok := c.entries[instance]
 	if ok && len(entries.Entries) > 0 {
 		for _, entry := range entries.Entries {
 			if entry.UUID == entries.Latest {
-				var copiedDataFiles map[string][]byte
-				if entry.DataFiles != nil {
-					copiedDataFiles = make(map[string][]byte, len(entry.DataFiles))
-					for name, contents := range entry.DataFiles {
-						copiedDataFiles[name] = bytes.Clone(contents)
-					}
-				}
-				copiedEntry := &RuleSetEntry{
-					UUID:      entry.UUID,
-					Timestamp: entry.Timestamp,
-					Rules:     entry.Rules,
-					DataFiles: copiedDataFiles,
-				}
-				return copiedEntry, true
+				return entry, true
 			}
 		}
 		c.logger.Info("cache invariant violation: Latest UUID not found among entries",
@@ -108,12 +127,26 @@ func (c *RuleSetCache) Get(instance string) (*RuleSetEntry, bool) {
 	return nil, false
 }
 
-// Put stores rules for the given instance with a new UUID and timestamp.
-// New entries are appended to the end, maintaining oldest-to-newest order.
+// Put stores rules for the given instance with a content-derived UUID and a
+// new timestamp. New entries are appended to the end, maintaining
+// oldest-to-newest order.
+//
+// The UUID only depends on the instance and the content, so every operator
+// replica computing the same RuleSet serves the same UUID and clients
+// switching replicas do not reload unchanged rules. Putting the content that
+// is already latest is a no-op; putting the content of an older entry moves
+// it to the end.
 func (c *RuleSetCache) Put(instance string, rules string, datafiles map[string][]byte) {
+	id := RevisionID(instance, rules, datafiles)
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	existing := c.entries[instance]
+	if existing != nil && existing.Latest == id {
+		return
+	}
+
 	// Deep-copy to avoid races if the caller mutates the map after Put returns.
 	var internalData map[string][]byte
 	if len(datafiles) > 0 {
@@ -124,26 +157,93 @@ func (c *RuleSetCache) Put(instance string, rules string, datafiles map[string][
 	}
 
 	newEntry := &RuleSetEntry{
-		UUID:      uuid.New().String(),
+		UUID:      id,
 		Timestamp: time.Now(),
 		Rules:     rules,
 		DataFiles: internalData,
 	}
 	newEntrySize := entrySize(newEntry)
 
-	if c.entries[instance] == nil {
+	if existing == nil {
 		c.entries[instance] = &RuleSetEntries{
 			Latest:  newEntry.UUID,
 			Entries: []*RuleSetEntry{newEntry},
 		}
 	} else {
-		c.entries[instance].Entries = append(c.entries[instance].Entries, newEntry)
-		c.entries[instance].Latest = newEntry.UUID
+		// Drop an older revision with identical content so UUIDs stay unique.
+		existing.Entries = slices.DeleteFunc(existing.Entries, func(e *RuleSetEntry) bool {
+			if e.UUID != id {
+				return false
+			}
+			c.totalSize -= entrySize(e)
+			c.totalEntries--
+			return true
+		})
+		existing.Entries = append(existing.Entries, newEntry)
+		existing.Latest = newEntry.UUID
 	}
 	c.totalSize += newEntrySize
 	c.totalEntries++
 }
 
+// revisionNamespace is the UUID namespace for content-derived revision IDs.
+var revisionNamespace = uuid.MustParse("5b0f2a4e-4a8e-4c1f-9d43-6f1d2b9c7e10")
+
+// RevisionID returns the UUID under which Put stores rules and datafiles for
+// instance: a name-based (version 5) UUID derived from the SHA-256 of the
+// instance, rules and data files. Every field is length-prefixed so that
+// distinct inputs cannot produce the same byte stream.
+func RevisionID(instance, rules string, datafiles map[string][]byte) string {
+	h := sha256.New()
+	writeHashField(h, instance)
+	writeContent(h, rules, datafiles)
+	return uuid.NewSHA1(revisionNamespace, h.Sum(nil)).String()
+}
+
+// ContentHash returns the SHA-256 of rules and datafiles, as
+// "sha256:<hex>". Unlike RevisionID it does not depend on the instance, so
+// the same content has the same hash in every RuleSet.
+func ContentHash(rules string, datafiles map[string][]byte) string {
+	h := sha256.New()
+	writeContent(h, rules, datafiles)
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// writeContent writes rules and datafiles, sorted by name, to h.
+func writeContent(h hash.Hash, rules string, datafiles map[string][]byte) {
+	writeHashField(h, rules)
+	names := make([]string, 0, len(datafiles))
+	for name := range datafiles {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		writeHashField(h, name)
+		_ = binary.Write(h, binary.BigEndian, uint64(len(datafiles[name])))
+		_, _ = h.Write(datafiles[name])
+	}
+}
+
+// writeHashField writes b to h, prefixed with its length.
+func writeHashField(h hash.Hash, b string) {
+	_ = binary.Write(h, binary.BigEndian, uint64(len(b)))
+	_, _ = io.WriteString(h, b)
+}
+
+// SnapshotName returns the name of the RuleSetSnapshot recording the
+// revision id of instance: the name of the RuleSet followed by the
+// revision, with the name truncated so that it fits in 253 characters.
+func SnapshotName(instance, id string) string {
+	name := instance
+	if i := strings.LastIndexByte(instance, '/'); i >= 0 {
+		name = instance[i+1:]
+	}
+	if maxLen := 253 - len(id) - 1; len(name) > maxLen {
+		name = strings.TrimRight(name[:maxLen], "-.")
+	}
+	return name + "-" + id
+}
+
 // Delete removes all entries for the given instance from the cache.
 // Returns true if the instance existed.
 func (c *RuleSetCache) Delete(instance string) bool {
@@ -163,6 +263,41 @@ func (c *RuleSetCache) Delete(instance string) bool {
 	return true
 }
 
+// Reject removes the revision id of instance, so that the previous revision
+// is served again when id is the latest. It returns the revision now
+// served, and false when no revision of instance remains.
+func (c *RuleSetCache) Reject(instance, id string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries, ok := c.entries[instance]
+	if !ok {
+		return "", false
+	}
+	entries.Entries = slices.DeleteFunc(entries.Entries, func(e *RuleSetEntry) bool {
+		if e.UUID != id {
+			return false
+		}
+		c.totalSize -= entrySize(e)
+		c.totalEntries--
+		return true
+	})
+	if len(entries.Entries) == 0 {
+		delete(c.entries, instance)
+		return "", false
+	}
+	if entries.Latest == id {
+		entries.Latest = entries.Entries[len(entries.Entries)-1].UUID
+	}
+	return entries.Latest, true
+}
+
+// Has reports whether an entry is cached for the given instance.
+func (c *RuleSetCache) Has(instance string) bool {
+	_, ok := c.latest(instance)
+	return ok
+}
+
 // Len returns the number of instances stored in the cache
 func (c *RuleSetCache) Len() int {
 	c.mu.RLock()
@@ -206,6 +341,27 @@ func (c *RuleSetCache) CountEntries(instance string) int {
 	return 0
 }
 
+// NamespaceSize returns the total size in bytes of the latest entries of the
+// instances in namespace, except the instance exclude. Older revisions are
+// not counted.
+func (c *RuleSetCache) NamespaceSize(namespace, exclude string) int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	size := 0
+	for instance, entries := range c.entries {
+		if instance == exclude || !strings.HasPrefix(instance, namespace+"/") {
+			continue
+		}
+		for _, entry := range entries.Entries {
+			if entry.UUID == entries.Latest {
+				size += entrySize(entry)
+			}
+		}
+	}
+	return size
+}
+
 // -----------------------------------------------------------------------------
 // RuleSetCache - Cleanup
 // -----------------------------------------------------------------------------
@@ -295,8 +451,14 @@ func (c *RuleSetCache) PruneBySize(maxSize int) int {
 // depends on entries being immutable after creation — never mutate Rules or
 // DataFiles on a stored entry.
 func entrySize(entry *RuleSetEntry) int {
-	size := len(entry.Rules)
-	for filename, v := range entry.DataFiles {
+	return PayloadSize(entry.Rules, entry.DataFiles)
+}
+
+// PayloadSize returns the number of bytes that rules and datafiles occupy in
+// the cache.
+func PayloadSize(rules string, datafiles map[string][]byte) int {
+	size := len(rules)
+	for filename, v := range datafiles {
 		size += len(filename)
 		size += len(v)
 	}
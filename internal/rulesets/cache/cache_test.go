@@ -17,6 +17,7 @@ limitations under the License.
 package cache
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -276,6 +277,18 @@ func TestRuleSetCache_TotalSize(t *testing.T) {
 	assert.Equal(t, 33, cache.TotalSize())
 }
 
+func TestRuleSetCache_NamespaceSize(t *testing.T) {
+	cache := NewRuleSetCache()
+	cache.Put("team-a/one", "12345", nil)
+	cache.Put("team-a/one", "123", nil)
+	cache.Put("team-a/two", "1234567890", map[string][]byte{"file1": []byte("abcde")})
+	cache.Put("team-ab/three", "12345", nil)
+
+	assert.Equal(t, 23, cache.NamespaceSize("team-a", ""), "only the latest revisions of the namespace count")
+	assert.Equal(t, 20, cache.NamespaceSize("team-a", "team-a/one"))
+	assert.Equal(t, 0, cache.NamespaceSize("team-b", ""))
+}
+
 // TestRuleSetCache_TotalSizeInvariant verifies that TotalSize stays exactly
 // correct through a sequence of Put, Prune, and PruneBySize operations.
 func TestRuleSetCache_TotalSizeInvariant(t *testing.T) {
@@ -395,6 +408,32 @@ func TestRuleSetCache_Delete(t *testing.T) {
 	assert.False(t, ok, "Delete should return false for non-existent instance")
 }
 
+func TestRuleSetCache_Reject(t *testing.T) {
+	c := NewRuleSetCache()
+	c.Put("ns/rs", "rules v1", nil)
+	c.Put("ns/rs", "rules v2", nil)
+	good := RevisionID("ns/rs", "rules v1", nil)
+	bad := RevisionID("ns/rs", "rules v2", nil)
+	entry, _ := c.Get("ns/rs")
+	require.Equal(t, bad, entry.UUID, "pre-condition: RevisionID matches the stored UUID")
+
+	served, ok := c.Reject("ns/rs", bad)
+	require.True(t, ok)
+	assert.Equal(t, good, served)
+	entry, _ = c.Get("ns/rs")
+	assert.Equal(t, "rules v1", entry.Rules, "the previous revision must be served again")
+	assert.Equal(t, 1, c.TotalEntries())
+	assert.Equal(t, len("rules v1"), c.TotalSize())
+
+	_, ok = c.Reject("ns/rs", good)
+	assert.False(t, ok, "rejecting the last revision leaves nothing to serve")
+	assert.False(t, c.Has("ns/rs"))
+	assert.Zero(t, c.TotalEntries())
+
+	_, ok = c.Reject("ns/missing", bad)
+	assert.False(t, ok)
+}
+
 func TestRuleSetCache_GetNonExistent(t *testing.T) {
 	cache := NewRuleSetCache()
 	entry, ok := cache.Get("non-existent")
@@ -402,3 +441,48 @@ func TestRuleSetCache_GetNonExistent(t *testing.T) {
 	assert.Nil(t, entry)
 	assert.Zero(t, cache.CountEntries("non-existent"))
 }
+
+func TestRuleSetCache_PutContentDerivedUUID(t *testing.T) {
+	data := map[string][]byte{"a.data": []byte("a"), "b.data": []byte("b")}
+
+	a, b := NewRuleSetCache(), NewRuleSetCache()
+	a.Put("ns/rs", "rules v1", data)
+	b.Put("ns/rs", "rules v1", map[string][]byte{"b.data": []byte("b"), "a.data": []byte("a")})
+	entryA, _ := a.Get("ns/rs")
+	entryB, _ := b.Get("ns/rs")
+	assert.Equal(t, entryA.UUID, entryB.UUID, "replicas caching the same content must agree on the UUID")
+
+	b.Put("ns/other", "rules v1", data)
+	other, _ := b.Get("ns/other")
+	assert.NotEqual(t, entryA.UUID, other.UUID, "the UUID must be scoped to the instance")
+
+	a.Put("ns/rs", "rules v1", data)
+	assert.Equal(t, 1, a.CountEntries("ns/rs"), "re-putting the latest content must be a no-op")
+
+	a.Put("ns/rs", "rules v2", data)
+	a.Put("ns/rs", "rules v1", data)
+	assert.Equal(t, 2, a.CountEntries("ns/rs"), "reverting must not duplicate the older revision")
+	reverted, _ := a.Get("ns/rs")
+	assert.Equal(t, entryA.UUID, reverted.UUID)
+	assert.Equal(t, len("rules v1")+len("rules v2")+2*(len("a.data")+1+len("b.data")+1), a.TotalSize())
+}
+
+func TestContentHash(t *testing.T) {
+	data := map[string][]byte{"a.data": []byte("a"), "b.data": []byte("b")}
+
+	hash := ContentHash("rules v1", data)
+	assert.Regexp(t, `^sha256:[0-9a-f]{64}$`, hash)
+	assert.Equal(t, hash, ContentHash("rules v1", map[string][]byte{"b.data": []byte("b"), "a.data": []byte("a")}))
+	assert.NotEqual(t, hash, ContentHash("rules v2", data))
+	assert.NotEqual(t, hash, ContentHash("rules v1", nil))
+}
+
+func TestSnapshotName(t *testing.T) {
+	const id = "0b4e6a3c-2d8f-5e1a-9c7b-3f6d8e2a1b4c"
+
+	assert.Equal(t, "rs-"+id, SnapshotName("ns/rs", id))
+
+	long := SnapshotName("ns/"+strings.Repeat("a", 215)+"-"+strings.Repeat("b", 37), id)
+	assert.Len(t, long, 252)
+	assert.Equal(t, strings.Repeat("a", 215)+"-"+id, long, "truncated names must not end with a dash")
+}
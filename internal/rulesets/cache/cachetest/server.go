@@ -0,0 +1,216 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package cachetest provides an in-memory RuleSet cache server for tests.
+//
+// The Server runs the production cache handlers on an httptest.Server,
+// backed by an in-memory RuleSetCache and TokenReview, so that the fetch
+// protocol and its authentication can be exercised without a cluster.
+// Failures and latency can be injected to test client behavior.
+//
+// Usage:
+//
+//	srv := cachetest.NewServer(t)
+//	srv.Cache.Put("team-a/rules", "SecRuleEngine On", nil)
+//	token := srv.IssueToken("team-a", "coraza-engine-waf", "team-a/rules")
+//	resp := srv.Get(t, "/rules/team-a/rules/latest", token)
+package cachetest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	authv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	authclient "k8s.io/client-go/kubernetes/typed/authentication/v1"
+
+	"github.com/networking-incubator/coraza-kubernetes-operator/internal/rulesets/cache"
+)
+
+// -----------------------------------------------------------------------------
+// Server
+// -----------------------------------------------------------------------------
+
+// Server is an in-memory RuleSet cache server.
+type Server struct {
+	*httptest.Server
+
+	// Cache is the in-memory cache the server serves from. Put entries in
+	// it directly.
+	Cache *cache.RuleSetCache
+
+	tokens *TokenReview
+
+	mu       sync.Mutex
+	failures []int
+	latency  time.Duration
+	requests int
+}
+
+// NewServer starts a Server that is closed when the test ends.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+	s := &Server{
+		Cache:  cache.NewRuleSetCache(),
+		tokens: NewTokenReview(),
+	}
+	handler := cache.NewServer(s.Cache, "", logr.Discard(), nil, s.tokens).Handler()
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status, delay := s.next()
+		time.Sleep(delay)
+		if status != 0 {
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// IssueToken returns a bearer token for the ServiceAccount namespace/name,
+// valid for the given cache keys ("namespace/ruleset").
+func (s *Server) IssueToken(namespace, serviceAccount string, cacheKeys ...string) string {
+	return s.tokens.Issue(namespace, serviceAccount, cacheKeys...)
+}
+
+// FailNext makes the next len(statuses) requests fail, in order, with the
+// given HTTP statuses instead of reaching the cache handlers.
+func (s *Server) FailNext(statuses ...int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = append(s.failures, statuses...)
+}
+
+// SetLatency delays every response by d.
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// Requests returns the number of requests the server received, including
+// the injected failures.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// Get sends a GET for path with the bearer token, if not empty. The caller
+// closes the response body.
+func (s *Server) Get(t testing.TB, path, token string) *http.Response {
+	t.Helper()
+	req, err := http.NewRequestWithContext(context.Background(), http.MethodGet, s.URL+path, nil)
+	if err != nil {
+		t.Fatalf("build request for %s: %v", path, err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := s.Client().Do(req)
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	return resp
+}
+
+// next counts a request and returns the failure status to answer it with,
+// zero for none, and the latency to apply.
+func (s *Server) next() (int, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	if len(s.failures) == 0 {
+		return 0, s.latency
+	}
+	status := s.failures[0]
+	s.failures = s.failures[1:]
+	return status, s.latency
+}
+
+// -----------------------------------------------------------------------------
+// TokenReview
+// -----------------------------------------------------------------------------
+
+var _ authclient.TokenReviewInterface = &TokenReview{}
+
+// TokenReview is an in-memory TokenReview API that authenticates the
+// tokens it issued, for the audiences they were issued for.
+type TokenReview struct {
+	mu     sync.Mutex
+	tokens map[string]issuedToken
+	issued int
+}
+
+type issuedToken struct {
+	username  string
+	audiences []string
+}
+
+// NewTokenReview returns an empty TokenReview.
+func NewTokenReview() *TokenReview {
+	return &TokenReview{tokens: map[string]issuedToken{}}
+}
+
+// Issue returns a new token for the ServiceAccount namespace/name, valid for
+// the cache server audiences of the given cache keys.
+func (r *TokenReview) Issue(namespace, serviceAccount string, cacheKeys ...string) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.issued++
+	token := fmt.Sprintf("cachetest-token-%d", r.issued)
+	audiences := make([]string, 0, len(cacheKeys))
+	for _, key := range cacheKeys {
+		audiences = append(audiences, cache.Audience(key))
+	}
+	r.tokens[token] = issuedToken{
+		username:  fmt.Sprintf("system:serviceaccount:%s:%s", namespace, serviceAccount),
+		audiences: audiences,
+	}
+	return token
+}
+
+// Create authenticates review.Spec.Token when it was issued for one of the
+// requested audiences.
+func (r *TokenReview) Create(_ context.Context, review *authv1.TokenReview, _ metav1.CreateOptions) (*authv1.TokenReview, error) {
+	r.mu.Lock()
+	token, ok := r.tokens[review.Spec.Token]
+	r.mu.Unlock()
+
+	out := review.DeepCopy()
+	if !ok {
+		return out, nil
+	}
+	for _, audience := range review.Spec.Audiences {
+		if slices.Contains(token.audiences, audience) {
+			out.Status = authv1.TokenReviewStatus{
+				Authenticated: true,
+				User:          authv1.UserInfo{Username: token.username},
+				Audiences:     []string{audience},
+			}
+			return out, nil
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,94 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cachetest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/networking-incubator/coraza-kubernetes-operator/internal/rulesets/cache"
+)
+
+func TestServer(t *testing.T) {
+	srv := NewServer(t)
+	srv.Cache.Put("team-a/rules", "SecRuleEngine On", nil)
+	token := srv.IssueToken("team-a", "coraza-engine-waf", "team-a/rules")
+
+	get := func(path, token string) (int, []byte) {
+		resp := srv.Get(t, path, token)
+		defer func() { _ = resp.Body.Close() }()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, body
+	}
+
+	t.Run("serves latest and rules", func(t *testing.T) {
+		status, body := get("/rules/team-a/rules/latest", token)
+		require.Equal(t, http.StatusOK, status)
+		var latest cache.LatestResponse
+		require.NoError(t, json.Unmarshal(body, &latest))
+		entry, ok := srv.Cache.Get("team-a/rules")
+		require.True(t, ok)
+		assert.Equal(t, entry.UUID, latest.UUID)
+
+		status, body = get("/rules/team-a/rules", token)
+		require.Equal(t, http.StatusOK, status)
+		assert.Contains(t, string(body), "SecRuleEngine On")
+	})
+
+	t.Run("authenticates", func(t *testing.T) {
+		status, _ := get("/rules/team-a/rules", "")
+		assert.Equal(t, http.StatusForbidden, status, "missing token")
+
+		other := srv.IssueToken("team-a", "coraza-engine-waf", "team-a/other")
+		status, _ = get("/rules/team-a/rules", other)
+		assert.Equal(t, http.StatusForbidden, status, "token for another RuleSet")
+
+		foreign := srv.IssueToken("team-b", "coraza-engine-waf", "team-a/rules")
+		status, _ = get("/rules/team-a/rules", foreign)
+		assert.Equal(t, http.StatusForbidden, status, "ServiceAccount from another namespace")
+	})
+
+	t.Run("injects failures", func(t *testing.T) {
+		srv.FailNext(http.StatusServiceUnavailable, http.StatusInternalServerError)
+		before := srv.Requests()
+
+		status, _ := get("/rules/team-a/rules", token)
+		assert.Equal(t, http.StatusServiceUnavailable, status)
+		status, _ = get("/rules/team-a/rules", token)
+		assert.Equal(t, http.StatusInternalServerError, status)
+		status, _ = get("/rules/team-a/rules", token)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, before+3, srv.Requests())
+	})
+
+	t.Run("injects latency", func(t *testing.T) {
+		srv.SetLatency(50 * time.Millisecond)
+		defer srv.SetLatency(0)
+
+		start := time.Now()
+		status, _ := get("/rules/team-a/rules/latest", token)
+		assert.Equal(t, http.StatusOK, status)
+		assert.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	})
+}
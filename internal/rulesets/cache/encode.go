@@ -0,0 +1,113 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"slices"
+	"unicode/utf8"
+)
+
+// -----------------------------------------------------------------------------
+// Streaming Entry Encoding
+// -----------------------------------------------------------------------------
+
+// streamChunkSize is the maximum number of bytes of rule text escaped per
+// write. It bounds the per-request scratch memory regardless of bundle size.
+const streamChunkSize = 32 * 1024
+
+// writeEntryJSON streams entry to w as JSON. The output is byte-for-byte
+// identical to json.NewEncoder(w).Encode(entry), but rule text and data files
+// are written in bounded chunks instead of being marshalled into a single
+// buffer first. This keeps memory flat when many gateways fetch a large
+// bundle concurrently.
+//
+// The entry must not be mutated while it is being written; stored cache
+// entries are immutable, so it is safe to pass them without copying.
+func writeEntryJSON(w io.Writer, entry *RuleSetEntry) error {
+	bw := bufio.NewWriterSize(w, streamChunkSize)
+
+	uuid, err := json.Marshal(entry.UUID)
+	if err != nil {
+		return err
+	}
+	timestamp, err := json.Marshal(entry.Timestamp)
+	if err != nil {
+		return err
+	}
+
+	_, _ = bw.WriteString(`{"uuid":`)
+	_, _ = bw.Write(uuid)
+	_, _ = bw.WriteString(`,"timestamp":`)
+	_, _ = bw.Write(timestamp)
+	_, _ = bw.WriteString(`,"rules":`)
+	if err := writeJSONString(bw, entry.Rules); err != nil {
+		return err
+	}
+
+	if len(entry.DataFiles) > 0 {
+		_, _ = bw.WriteString(`,"dataFiles":{`)
+		names := make([]string, 0, len(entry.DataFiles))
+		for name := range entry.DataFiles {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for i, name := range names {
+			if i > 0 {
+				_ = bw.WriteByte(',')
+			}
+			if err := writeJSONString(bw, name); err != nil {
+				return err
+			}
+			_, _ = bw.WriteString(`:"`)
+			enc := base64.NewEncoder(base64.StdEncoding, bw)
+			_, _ = enc.Write(entry.DataFiles[name])
+			_ = enc.Close()
+			_ = bw.WriteByte('"')
+		}
+		_ = bw.WriteByte('}')
+	}
+
+	_, _ = bw.WriteString("}\n")
+	return bw.Flush()
+}
+
+// writeJSONString writes s as a quoted JSON string, escaping it in chunks
+// that never split a UTF-8 sequence so the result matches json.Marshal.
+// Write errors are sticky on the bufio.Writer and surface on Flush.
+func writeJSONString(bw *bufio.Writer, s string) error {
+	_ = bw.WriteByte('"')
+	for len(s) > 0 {
+		n := min(len(s), streamChunkSize)
+		if n < len(s) {
+			// Back up to a rune boundary; at most utf8.UTFMax-1 bytes.
+			for back := 0; back < utf8.UTFMax-1 && n > 0 && !utf8.RuneStart(s[n]); back++ {
+				n--
+			}
+		}
+		quoted, err := json.Marshal(s[:n])
+		if err != nil {
+			return err
+		}
+		_, _ = bw.Write(quoted[1 : len(quoted)-1])
+		s = s[n:]
+	}
+	return bw.WriteByte('"')
+}
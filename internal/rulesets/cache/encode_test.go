@@ -0,0 +1,139 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteEntryJSON_MatchesEncoder(t *testing.T) {
+	t.Parallel()
+
+	// A multi-byte rune straddling every chunk boundary exercises the
+	// rune-boundary backoff in writeJSONString.
+	straddling := strings.Repeat("a", streamChunkSize-1) + "é€😀" + strings.Repeat("<&>", streamChunkSize)
+
+	tests := []struct {
+		name  string
+		entry RuleSetEntry
+	}{
+		{
+			name:  "empty rules",
+			entry: RuleSetEntry{UUID: "u-1"},
+		},
+		{
+			name: "escaped rules",
+			entry: RuleSetEntry{
+				UUID:  "u-2",
+				Rules: "SecRule REQUEST_URI \"@contains /admin\" \"id:1,deny,msg:'<x>&'\"\n\t\\",
+			},
+		},
+		{
+			name: "rules spanning many chunks",
+			entry: RuleSetEntry{
+				UUID:  "u-3",
+				Rules: straddling,
+			},
+		},
+		{
+			name: "invalid utf-8",
+			entry: RuleSetEntry{
+				UUID:  "u-4",
+				Rules: strings.Repeat("x", streamChunkSize-1) + "\xff\x80\x80\x80\x80" + "tail",
+			},
+		},
+		{
+			name: "data files",
+			entry: RuleSetEntry{
+				UUID:  "u-5",
+				Rules: "SecRule ARGS \"@pmFromFile b.data\" \"id:2,deny\"",
+				DataFiles: map[string][]byte{
+					"b.data":         []byte("second"),
+					"a.data":         []byte("first"),
+					"\"quoted\".txt": bytes.Repeat([]byte{0, 1, 2, 0xff}, streamChunkSize),
+					"empty.data":     {},
+				},
+			},
+		},
+		{
+			name: "empty data files map",
+			entry: RuleSetEntry{
+				UUID:      "u-6",
+				DataFiles: map[string][]byte{},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			tt.entry.Timestamp = time.Date(2025, 1, 2, 3, 4, 5, 6, time.UTC)
+
+			var want bytes.Buffer
+			require.NoError(t, json.NewEncoder(&want).Encode(&tt.entry))
+
+			var got bytes.Buffer
+			require.NoError(t, writeEntryJSON(&got, &tt.entry))
+
+			assert.Equal(t, want.String(), got.String())
+		})
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+func TestWriteEntryJSON_WriteError(t *testing.T) {
+	t.Parallel()
+
+	entry := &RuleSetEntry{UUID: "u-1", Rules: strings.Repeat("r", 3*streamChunkSize)}
+	err := writeEntryJSON(failingWriter{}, entry)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "connection reset")
+}
+
+func TestRuleSetCache_LatestSharesStoredEntry(t *testing.T) {
+	t.Parallel()
+
+	cache := NewRuleSetCache()
+	cache.Put("ns/name", "rules", map[string][]byte{"a.data": []byte("a")})
+
+	first, ok := cache.latest("ns/name")
+	require.True(t, ok)
+	second, ok := cache.latest("ns/name")
+	require.True(t, ok)
+	assert.Same(t, first, second, "latest must not copy the stored entry")
+
+	copied, ok := cache.Get("ns/name")
+	require.True(t, ok)
+	assert.NotSame(t, first, copied, "Get must return a copy")
+	assert.Equal(t, first, copied)
+
+	_, ok = cache.latest("ns/missing")
+	assert.False(t, ok)
+}
@@ -0,0 +1,92 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// -----------------------------------------------------------------------------
+// Heartbeats
+// -----------------------------------------------------------------------------
+
+// MaxHeartbeatErrorLength is the maximum length of the load error of a
+// heartbeat; longer errors are truncated.
+const MaxHeartbeatErrorLength = 512
+
+// ErrHeartbeatRejected is returned by a HeartbeatReporter when the caller is
+// not expected to send heartbeats for the RuleSet, for example because it
+// is not the cache client of an Engine using it.
+var ErrHeartbeatRejected = errors.New("heartbeat rejected")
+
+// Heartbeat is the body of a heartbeat a gateway sends periodically, and
+// after each attempt to load a new revision of its rules.
+type Heartbeat struct {
+	// LoadedUUID is the revision the gateway enforces.
+	LoadedUUID string `json:"loadedUUID,omitempty"`
+
+	// FailedUUID is the revision the gateway last failed to load, if any.
+	FailedUUID string `json:"failedUUID,omitempty"`
+
+	// Error is why the gateway failed to load FailedUUID.
+	Error string `json:"error,omitempty"`
+}
+
+// HeartbeatReporter receives the heartbeats of gateways.
+type HeartbeatReporter interface {
+	// ReportHeartbeat records the heartbeat sent by the authenticated caller
+	// for the RuleSet cache key.
+	ReportHeartbeat(ctx context.Context, caller AuthResult, cacheKey string, heartbeat Heartbeat) error
+}
+
+// handleHeartbeat passes a heartbeat of an authenticated caller to the
+// HeartbeatReporter.
+func (s *ruleSetCacheServer) handleHeartbeat(w http.ResponseWriter, r *http.Request, cacheKey string, caller *AuthResult) {
+	if s.heartbeatReporter == nil {
+		http.Error(w, "Heartbeats not supported", http.StatusNotFound)
+		return
+	}
+
+	var heartbeat Heartbeat
+	if err := json.NewDecoder(r.Body).Decode(&heartbeat); err != nil {
+		http.Error(w, "Invalid heartbeat", http.StatusBadRequest)
+		return
+	}
+	if heartbeat.LoadedUUID == "" && heartbeat.FailedUUID == "" {
+		http.Error(w, "Invalid heartbeat", http.StatusBadRequest)
+		return
+	}
+	if len(heartbeat.Error) > MaxHeartbeatErrorLength {
+		heartbeat.Error = heartbeat.Error[:MaxHeartbeatErrorLength]
+	}
+
+	if err := s.heartbeatReporter.ReportHeartbeat(r.Context(), *caller, cacheKey, heartbeat); err != nil {
+		if errors.Is(err, ErrHeartbeatRejected) {
+			s.logger.Info("Heartbeat rejected", "cacheKey", cacheKey, "serviceAccount", caller.Name, "reason", err.Error())
+			http.Error(w, "Heartbeat rejected", http.StatusConflict)
+			return
+		}
+		s.logger.Error(err, "Failed to record heartbeat", "cacheKey", cacheKey, "serviceAccount", caller.Name)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
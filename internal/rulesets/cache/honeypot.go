@@ -0,0 +1,101 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/netip"
+)
+
+// -----------------------------------------------------------------------------
+// Honeypot Reports
+// -----------------------------------------------------------------------------
+
+// MaxHoneypotReportClients is the maximum number of client addresses in a
+// honeypot report.
+const MaxHoneypotReportClients = 1024
+
+// ErrHoneypotReportRejected is returned by a HoneypotReporter when the caller
+// is not expected to report honeypot hits, for example because its RuleSet
+// does not block honeypot clients.
+var ErrHoneypotReportRejected = errors.New("honeypot report rejected")
+
+// HoneypotReport is the body of a honeypot report: the number of requests
+// for a decoy path of each client address since the previous report.
+type HoneypotReport struct {
+	Hits []HoneypotHit `json:"hits"`
+}
+
+// HoneypotHit is the number of requests for a decoy path of a client
+// address.
+type HoneypotHit struct {
+	ClientIP string `json:"clientIP"`
+	Count    int64  `json:"count"`
+}
+
+// HoneypotReporter receives the honeypot reports of Engines.
+type HoneypotReporter interface {
+	// ReportHoneypotHits records the number of requests for a decoy path of
+	// each client address reported by the authenticated caller for the
+	// RuleSet cache key.
+	ReportHoneypotHits(ctx context.Context, caller AuthResult, cacheKey string, hits map[netip.Addr]int64) error
+}
+
+// handleHoneypot passes a honeypot report of an authenticated caller to the
+// HoneypotReporter.
+func (s *ruleSetCacheServer) handleHoneypot(w http.ResponseWriter, r *http.Request, cacheKey string, caller *AuthResult) {
+	if s.honeypotReporter == nil {
+		http.Error(w, "Honeypot reports not supported", http.StatusNotFound)
+		return
+	}
+
+	var report HoneypotReport
+	if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+		http.Error(w, "Invalid honeypot report", http.StatusBadRequest)
+		return
+	}
+	if len(report.Hits) > MaxHoneypotReportClients {
+		http.Error(w, "Too many clients in honeypot report", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	hits := make(map[netip.Addr]int64, len(report.Hits))
+	for _, h := range report.Hits {
+		addr, err := netip.ParseAddr(h.ClientIP)
+		if err != nil || h.Count <= 0 {
+			http.Error(w, "Invalid honeypot report", http.StatusBadRequest)
+			return
+		}
+		hits[addr.Unmap()] += h.Count
+	}
+
+	if err := s.honeypotReporter.ReportHoneypotHits(r.Context(), *caller, cacheKey, hits); err != nil {
+		if errors.Is(err, ErrHoneypotReportRejected) {
+			s.logger.Info("Honeypot report rejected", "cacheKey", cacheKey, "serviceAccount", caller.Name, "reason", err.Error())
+			http.Error(w, "Honeypot report rejected", http.StatusConflict)
+			return
+		}
+		s.logger.Error(err, "Failed to record honeypot report", "cacheKey", cacheKey, "serviceAccount", caller.Name)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
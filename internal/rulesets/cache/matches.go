@@ -0,0 +1,96 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// -----------------------------------------------------------------------------
+// Match Reports
+// -----------------------------------------------------------------------------
+
+// MaxMatchReportRules is the maximum number of rules in a match report.
+const MaxMatchReportRules = 1024
+
+// ErrMatchReportRejected is returned by a MatchReporter when the caller is not
+// expected to report matches, for example because its Engine is not learning.
+var ErrMatchReportRejected = errors.New("match report rejected")
+
+// MatchReport is the body of a match report: the number of matches of each
+// rule since the previous report.
+type MatchReport struct {
+	Matches []RuleMatch `json:"matches"`
+}
+
+// RuleMatch is the number of matches of a rule.
+type RuleMatch struct {
+	RuleID int   `json:"ruleId"`
+	Count  int64 `json:"count"`
+}
+
+// MatchReporter receives the match reports of Engines in learning mode.
+type MatchReporter interface {
+	// ReportMatches records the number of matches of each rule, keyed by
+	// rule ID, reported by the authenticated caller for the RuleSet cache
+	// key.
+	ReportMatches(ctx context.Context, caller AuthResult, cacheKey string, matches map[int]int64) error
+}
+
+// handleMatches passes a match report of an authenticated caller to the
+// MatchReporter.
+func (s *ruleSetCacheServer) handleMatches(w http.ResponseWriter, r *http.Request, cacheKey string, caller *AuthResult) {
+	if s.matchReporter == nil {
+		http.Error(w, "Match reports not supported", http.StatusNotFound)
+		return
+	}
+
+	var report MatchReport
+	if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+		http.Error(w, "Invalid match report", http.StatusBadRequest)
+		return
+	}
+	if len(report.Matches) > MaxMatchReportRules {
+		http.Error(w, "Too many rules in match report", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	matches := make(map[int]int64, len(report.Matches))
+	for _, m := range report.Matches {
+		if m.RuleID <= 0 || m.Count <= 0 {
+			http.Error(w, "Invalid match report", http.StatusBadRequest)
+			return
+		}
+		matches[m.RuleID] += m.Count
+	}
+
+	if err := s.matchReporter.ReportMatches(r.Context(), *caller, cacheKey, matches); err != nil {
+		if errors.Is(err, ErrMatchReportRejected) {
+			s.logger.Info("Match report rejected", "cacheKey", cacheKey, "serviceAccount", caller.Name, "reason", err.Error())
+			http.Error(w, "Match report rejected", http.StatusConflict)
+			return
+		}
+		s.logger.Error(err, "Failed to record match report", "cacheKey", cacheKey, "serviceAccount", caller.Name)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -19,11 +19,16 @@ package cache
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -53,6 +58,9 @@ const (
 	// MaxBodySize is the maximum size of HTTP request bodies (0 bytes - no body expected)
 	MaxBodySize = 0
 
+	// MaxMatchReportSize is the maximum size of a report body, such as a match report (64KB)
+	MaxMatchReportSize = 64 * 1024
+
 	// ReadTimeout is the maximum duration for reading the entire request
 	ReadTimeout = 15 * time.Second
 
@@ -64,8 +72,23 @@ const (
 
 	// GracefulShutdownTimeout is the max time to drain existing connections on shutdown
 	GracefulShutdownTimeout = 10 * time.Second
+
+	// DefaultDrainPeriod is how long the server keeps answering fetches after
+	// shutdown begins. It matches the default WASM plugin poll interval, so
+	// every gateway gets at least one more poll answered while it is moved to
+	// another replica.
+	DefaultDrainPeriod = 15 * time.Second
+
+	// ProtocolVersionHeader is the header a client lists the cache protocol
+	// versions it speaks in, comma-separated, and the server answers with the
+	// version it chose. Clients that do not send it speak version 1.
+	ProtocolVersionHeader = "X-Coraza-Cache-Protocol"
 )
 
+// ProtocolVersions are the cache protocol versions the server speaks, oldest
+// first.
+var ProtocolVersions = []int{1}
+
 // -----------------------------------------------------------------------------
 // API Response Types
 // -----------------------------------------------------------------------------
@@ -74,6 +97,8 @@ const (
 type LatestResponse struct {
 	UUID      string `json:"uuid"`
 	Timestamp string `json:"timestamp"`
+	// Snapshot is the name of the RuleSetSnapshot recording the revision.
+	Snapshot string `json:"snapshot,omitempty"`
 }
 
 // -----------------------------------------------------------------------------
@@ -87,6 +112,15 @@ type ruleSetCacheServer struct {
 	srv    *http.Server
 	logger logr.Logger
 	gc     GarbageCollectionConfig
+
+	drainPeriod time.Duration
+	draining    atomic.Bool
+
+	matchReporter     MatchReporter
+	honeypotReporter  HoneypotReporter
+	heartbeatReporter HeartbeatReporter
+
+	spiffe *spiffeAuth
 }
 
 // NewServer creates a new RuleSetCacheServer instance.
@@ -98,10 +132,11 @@ func NewServer(cache *RuleSetCache, addr string, logger logr.Logger, gc *Garbage
 	}
 
 	s := &ruleSetCacheServer{
-		cache:  cache,
-		auth:   NewTokenAuthenticator(tokenReview),
-		logger: logger,
-		gc:     gcConfig,
+		cache:       cache,
+		auth:        NewTokenAuthenticator(tokenReview),
+		logger:      logger,
+		gc:          gcConfig,
+		drainPeriod: DefaultDrainPeriod,
 	}
 
 	mux := http.NewServeMux()
@@ -120,22 +155,77 @@ func NewServer(cache *RuleSetCache, addr string, logger logr.Logger, gc *Garbage
 	return s
 }
 
-// Start the cache server and the GC loop. Both are stopped when ctx is cancelled.
+// Handler returns the HTTP handler serving the cache endpoints, with the
+// same instrumentation as the server itself, for serving them from another
+// listener such as an httptest.Server.
+func (s *ruleSetCacheServer) Handler() http.Handler {
+	return s.srv.Handler
+}
+
+// SetDrainPeriod configures how long the server keeps serving after shutdown
+// begins. Zero shuts down immediately.
+func (s *ruleSetCacheServer) SetDrainPeriod(d time.Duration) {
+	s.drainPeriod = d
+}
+
+// SetMatchReporter configures the receiver of the rule match reports of
+// Engines in learning mode. Without one, reports are refused.
+func (s *ruleSetCacheServer) SetMatchReporter(reporter MatchReporter) {
+	s.matchReporter = reporter
+}
+
+// SetHoneypotReporter configures the receiver of the honeypot reports of
+// Engines. Without one, reports are refused.
+func (s *ruleSetCacheServer) SetHoneypotReporter(reporter HoneypotReporter) {
+	s.honeypotReporter = reporter
+}
+
+// SetHeartbeatReporter configures the receiver of the heartbeats of
+// gateways. Without one, heartbeats are refused.
+func (s *ruleSetCacheServer) SetHeartbeatReporter(reporter HeartbeatReporter) {
+	s.heartbeatReporter = reporter
+}
+
+// SetSPIFFEAuth makes the server require mutual TLS, presenting the SVID of
+// svids and requiring a client SVID of trustDomain, and requires the
+// authorizer to accept the SPIFFE ID of each client on top of its token.
+// It must be called before Start.
+func (s *ruleSetCacheServer) SetSPIFFEAuth(svids *SVIDSource, trustDomain string, authorizer WorkloadAuthorizer) {
+	s.spiffe = &spiffeAuth{
+		svids:       svids,
+		trustDomain: trustDomain,
+		authorizer:  authorizer,
+		authorized:  make(map[string]time.Time),
+	}
+}
+
+// ReadyzCheck fails once the server is draining, so that the replica is
+// taken out of the cache Service endpoints. It matches healthz.Checker.
+func (s *ruleSetCacheServer) ReadyzCheck(_ *http.Request) error {
+	if s.draining.Load() {
+		return errors.New("ruleset cache server is draining")
+	}
+	return nil
+}
+
+// Start the cache server and the GC loop. Both are stopped when ctx is
+// cancelled, after the drain period.
 func (s *ruleSetCacheServer) Start(ctx context.Context) error {
 	go s.rungc(ctx)
 
 	errChan := make(chan error, 1)
 	go func() {
-		s.logger.Info("Starting ruleset cache server", "addr", s.srv.Addr)
-		if err := s.srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		s.logger.Info("Starting ruleset cache server", "addr", s.srv.Addr, "spiffe", s.spiffe != nil)
+		if err := s.serve(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			errChan <- err
 		}
 	}()
 
 	select {
 	case <-ctx.Done():
+		s.drain()
+
 		s.logger.Info("Shutting down ruleset cache server")
-		s.srv.SetKeepAlivesEnabled(false)
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), GracefulShutdownTimeout)
 		defer cancel()
 
@@ -151,6 +241,36 @@ func (s *ruleSetCacheServer) Start(ctx context.Context) error {
 	}
 }
 
+// serve listens on the server address, over mutual TLS when SPIFFE
+// authentication is configured. The TLS listener is set up directly rather
+// than with ListenAndServeTLS, which would advertise ALPN protocols and
+// reject clients offering others.
+func (s *ruleSetCacheServer) serve() error {
+	if s.spiffe == nil {
+		return s.srv.ListenAndServe()
+	}
+	ln, err := net.Listen("tcp", s.srv.Addr)
+	if err != nil {
+		return err
+	}
+	return s.srv.Serve(tls.NewListener(ln, s.spiffe.svids.TLSConfig(s.spiffe.trustDomain)))
+}
+
+// drain keeps answering fetches for the drain period while failing
+// readiness and closing every connection after its current response, so
+// that clients which still reach this replica are served and then reconnect
+// elsewhere instead of failing (and, with a fail-closed policy, blocking
+// traffic) during a rolling update.
+func (s *ruleSetCacheServer) drain() {
+	s.draining.Store(true)
+	s.srv.SetKeepAlivesEnabled(false)
+	if s.drainPeriod <= 0 {
+		return
+	}
+	s.logger.Info("Draining ruleset cache server", "drainPeriod", s.drainPeriod)
+	time.Sleep(s.drainPeriod)
+}
+
 // NeedLeaderElection implements the LeaderElectionRunnable interface.
 func (s *ruleSetCacheServer) NeedLeaderElection() bool {
 	return false
@@ -161,35 +281,64 @@ func (s *ruleSetCacheServer) NeedLeaderElection() bool {
 // -----------------------------------------------------------------------------
 
 func (s *ruleSetCacheServer) handleRules(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
+	path := strings.TrimPrefix(r.URL.Path, "/rules/")
+
+	// Reports are the only requests with a body.
+	cacheKey, report := reportPath(path, r.Method)
+	switch {
+	case report != "":
+		r.Body = http.MaxBytesReader(w, r.Body, MaxMatchReportSize)
+	case r.Method != http.MethodGet:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 		return
+	default:
+		r.Body = http.MaxBytesReader(w, r.Body, MaxBodySize)
 	}
 
-	r.Body = http.MaxBytesReader(w, r.Body, MaxBodySize)
-
-	path := strings.TrimPrefix(r.URL.Path, "/rules/")
 	if path == "" {
 		http.Error(w, "RuleSet key required", http.StatusBadRequest)
 		return
 	}
 
+	version, err := NegotiateProtocol(r.Header.Get(ProtocolVersionHeader))
+	if err != nil {
+		s.logger.Info("Cache protocol negotiation failed", "path", path, "error", err)
+		http.Error(w, err.Error(), http.StatusNotAcceptable)
+		return
+	}
+	w.Header().Set(ProtocolVersionHeader, strconv.Itoa(version))
+
 	// Determine the cache key (strip /latest suffix if present).
-	cacheKey := path
 	isLatest := false
-	if stripped, ok := strings.CutSuffix(path, "/latest"); ok {
-		cacheKey = stripped
-		isLatest = true
+	if report == "" {
+		cacheKey = path
+		if stripped, ok := strings.CutSuffix(path, "/latest"); ok {
+			cacheKey = stripped
+			isLatest = true
+		}
 	}
 
 	// Authenticate: the token audience must match the requested RuleSet, and
 	// the SA namespace must match the cache key namespace.
-	if err := s.authenticateRequest(r, cacheKey); err != nil {
+	caller, err := s.authenticateRequest(r, cacheKey)
+	if err != nil {
 		s.logger.Info("Authentication failed", "cacheKey", cacheKey, "error", err)
 		http.Error(w, "Forbidden", http.StatusForbidden)
 		return
 	}
 
+	switch report {
+	case reportMatches:
+		s.handleMatches(w, r, cacheKey, caller)
+		return
+	case reportHoneypot:
+		s.handleHoneypot(w, r, cacheKey, caller)
+		return
+	case reportHeartbeat:
+		s.handleHeartbeat(w, r, cacheKey, caller)
+		return
+	}
+
 	if isLatest {
 		s.handleLatest(w, r, cacheKey)
 		return
@@ -198,32 +347,93 @@ func (s *ruleSetCacheServer) handleRules(w http.ResponseWriter, r *http.Request)
 	s.handleGetRules(w, r, cacheKey)
 }
 
+// Report endpoints, appended to the RuleSet path.
+const (
+	reportMatches   = "matches"
+	reportHoneypot  = "honeypot"
+	reportHeartbeat = "heartbeat"
+)
+
+// reportPath returns the cache key and report endpoint of a POST to path,
+// or an empty report for other requests.
+func reportPath(path, method string) (string, string) {
+	if method != http.MethodPost {
+		return "", ""
+	}
+	for _, report := range []string{reportMatches, reportHoneypot, reportHeartbeat} {
+		if cacheKey, ok := strings.CutSuffix(path, "/"+report); ok {
+			return cacheKey, report
+		}
+	}
+	return "", ""
+}
+
+// NegotiateProtocol returns the newest cache protocol version both the
+// server and a client listing the versions it speaks in header speak. A
+// client without the header speaks version 1.
+func NegotiateProtocol(header string) (int, error) {
+	if strings.TrimSpace(header) == "" {
+		return 1, nil
+	}
+	chosen := 0
+	for field := range strings.SplitSeq(header, ",") {
+		version, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			continue
+		}
+		if version > chosen && slices.Contains(ProtocolVersions, version) {
+			chosen = version
+		}
+	}
+	if chosen == 0 {
+		return 0, fmt.Errorf("unsupported cache protocol versions %q, the server speaks %s", header, FormatVersions(ProtocolVersions))
+	}
+	return chosen, nil
+}
+
+// FormatVersions returns versions as a comma-separated list.
+func FormatVersions(versions []int) string {
+	fields := make([]string, 0, len(versions))
+	for _, version := range versions {
+		fields = append(fields, strconv.Itoa(version))
+	}
+	return strings.Join(fields, ", ")
+}
+
 // authenticateRequest validates the Bearer token from the request and checks
 // that the ServiceAccount namespace matches the cache key namespace.
 // The audience-scoped TokenReview ensures the token is authorized for the
 // specific RuleSet being accessed (audience = "coraza-cache:namespace/rulesetName").
-func (s *ruleSetCacheServer) authenticateRequest(r *http.Request, cacheKey string) error {
+// With SPIFFE authentication, the SPIFFE ID of the client SVID must also be
+// authorized for the caller and the RuleSet.
+func (s *ruleSetCacheServer) authenticateRequest(r *http.Request, cacheKey string) (*AuthResult, error) {
 	token := extractBearerToken(r)
 	if token == "" {
-		return fmt.Errorf("missing bearer token")
+		return nil, fmt.Errorf("missing bearer token")
 	}
 
 	audience := Audience(cacheKey)
 	result, err := s.auth.Authenticate(r.Context(), token, audience)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Extract the namespace from the cache key and verify the SA lives in it.
 	keyNS, _, ok := strings.Cut(cacheKey, "/")
 	if !ok {
-		return fmt.Errorf("invalid cache key format: %s", cacheKey)
+		return nil, fmt.Errorf("invalid cache key format: %s", cacheKey)
 	}
 	if result.Namespace != keyNS {
-		return fmt.Errorf("service account namespace %s does not match cache key namespace %s", result.Namespace, keyNS)
+		return nil, fmt.Errorf("service account namespace %s does not match cache key namespace %s", result.Namespace, keyNS)
 	}
 
-	return nil
+	if s.spiffe != nil {
+		if err := s.spiffe.authorize(r, *result, cacheKey); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
 }
 
 // extractBearerToken extracts the token from the Authorization header.
@@ -237,7 +447,7 @@ func extractBearerToken(r *http.Request) string {
 }
 
 func (s *ruleSetCacheServer) handleLatest(w http.ResponseWriter, _ *http.Request, cacheKey string) {
-	entry, ok := s.cache.Get(cacheKey)
+	entry, ok := s.cache.latest(cacheKey)
 	if !ok {
 		http.Error(w, "RuleSet not found", http.StatusNotFound)
 		return
@@ -246,6 +456,7 @@ func (s *ruleSetCacheServer) handleLatest(w http.ResponseWriter, _ *http.Request
 	response := LatestResponse{
 		UUID:      entry.UUID,
 		Timestamp: entry.Timestamp.Format(TimestampFormat),
+		Snapshot:  SnapshotName(cacheKey, entry.UUID),
 	}
 
 	var buf bytes.Buffer
@@ -260,7 +471,9 @@ func (s *ruleSetCacheServer) handleLatest(w http.ResponseWriter, _ *http.Request
 }
 
 func (s *ruleSetCacheServer) handleGetRules(w http.ResponseWriter, _ *http.Request, cacheKey string) {
-	entry, ok := s.cache.Get(cacheKey)
+	// The stored entry is served directly and streamed to the client, so a
+	// fetch never holds a private copy of the bundle or its encoding in memory.
+	entry, ok := s.cache.latest(cacheKey)
 	if !ok {
 		http.Error(w, "RuleSet not found", http.StatusNotFound)
 		return
@@ -268,15 +481,12 @@ func (s *ruleSetCacheServer) handleGetRules(w http.ResponseWriter, _ *http.Reque
 
 	s.logger.Info("Serving rules from cache", "cacheKey", cacheKey, "uuid", entry.UUID, "availableKeysCount", s.cache.Len(), "cacheSizeBytes", s.cache.TotalSize())
 
-	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(entry); err != nil {
-		s.logger.Error(err, "Failed to encode rules response")
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
-		return
-	}
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write(buf.Bytes())
+	if err := writeEntryJSON(w, entry); err != nil {
+		// Headers are already sent; the client sees a truncated body.
+		s.logger.Error(err, "Failed to stream rules response", "cacheKey", cacheKey, "uuid", entry.UUID)
+	}
 }
 
 // -----------------------------------------------------------------------------
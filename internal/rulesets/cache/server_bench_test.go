@@ -0,0 +1,120 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// -----------------------------------------------------------------------------
+// Cache Server Benchmarks
+// -----------------------------------------------------------------------------
+
+// benchmarkRules returns roughly size bytes of SecLang directives.
+func benchmarkRules(size int) string {
+	var b strings.Builder
+	for i := 0; b.Len() < size; i++ {
+		fmt.Fprintf(&b, "SecRule REQUEST_URI \"@contains /bench/%d\" \"id:%d,phase:1,deny,status:403,msg:'benchmark rule <%d>'\"\n", i, 100000+i, i)
+	}
+	return b.String()
+}
+
+// BenchmarkServer_GetRules measures end-to-end fetch latency of the rules
+// endpoint over HTTP while many clients (gateway pods) poll concurrently.
+// Besides ns/op it reports the p99 request latency.
+func BenchmarkServer_GetRules(b *testing.B) {
+	for _, size := range []int{64 << 10, 1 << 20, 8 << 20} {
+		for _, clients := range []int{1, 64, 256} {
+			b.Run(fmt.Sprintf("size=%dKiB/clients=%d", size>>10, clients), func(b *testing.B) {
+				benchmarkGetRules(b, size, clients)
+			})
+		}
+	}
+}
+
+func benchmarkGetRules(b *testing.B, size, clients int) {
+	cache := NewRuleSetCache()
+	cache.Put("default/test-instance", benchmarkRules(size), map[string][]byte{
+		"bench.data": []byte(strings.Repeat("blocked-value\n", 1024)),
+	})
+	server := NewServer(cache, testServerAddr, logr.Discard(), nil, testTokenReview())
+
+	ts := httptest.NewServer(server.srv.Handler)
+	defer ts.Close()
+
+	httpClient := ts.Client()
+	httpClient.Transport.(*http.Transport).MaxIdleConnsPerHost = clients
+
+	var (
+		mu        sync.Mutex
+		latencies = make([]time.Duration, 0, b.N)
+	)
+
+	// RunParallel starts parallelism*GOMAXPROCS goroutines.
+	b.SetParallelism(max(1, clients/runtime.GOMAXPROCS(0)))
+	b.SetBytes(int64(size))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		local := make([]time.Duration, 0, 64)
+		for pb.Next() {
+			req, err := http.NewRequest(http.MethodGet, ts.URL+"/rules/default/test-instance", nil)
+			if err != nil {
+				b.Error(err)
+				return
+			}
+			req.Header.Set("Authorization", "Bearer test-token")
+
+			start := time.Now()
+			resp, err := httpClient.Do(req)
+			if err != nil {
+				b.Error(err)
+				return
+			}
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+			local = append(local, time.Since(start))
+
+			if resp.StatusCode != http.StatusOK {
+				b.Errorf("unexpected status %d", resp.StatusCode)
+				return
+			}
+		}
+		mu.Lock()
+		latencies = append(latencies, local...)
+		mu.Unlock()
+	})
+
+	b.StopTimer()
+	if len(latencies) > 0 {
+		slices.Sort(latencies)
+		p99 := latencies[(len(latencies)*99)/100]
+		b.ReportMetric(float64(p99.Microseconds())/1000, "p99-ms")
+	}
+}
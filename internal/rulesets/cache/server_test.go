@@ -23,6 +23,8 @@ import (
 	"maps"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
+	"strings"
 	"testing"
 	"time"
 
@@ -54,6 +56,7 @@ func TestServer_StartAndStop(t *testing.T) {
 	cache := NewRuleSetCache()
 	logger := utils.NewTestLogger(t)
 	server := NewServer(cache, testServerAddr, logger, nil, newNoopTokenReview())
+	server.SetDrainPeriod(0)
 
 	t.Log("Starting server in background goroutine")
 	ctx, cancel := context.WithCancel(context.Background())
@@ -78,6 +81,60 @@ func TestServer_StartAndStop(t *testing.T) {
 	}
 }
 
+func TestServer_DrainsBeforeShutdown(t *testing.T) {
+	const addr = "127.0.0.1:38081"
+	cache := NewRuleSetCache()
+	cache.Put("default/test-instance", "SecRuleEngine On", nil)
+	server := NewServer(cache, addr, utils.NewTestLogger(t), nil, testTokenReview())
+	server.SetDrainPeriod(500 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.Start(ctx)
+	}()
+
+	fetch := func() (*http.Response, error) {
+		req, err := http.NewRequest(http.MethodGet, "http://"+addr+"/rules/default/test-instance", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer test-token")
+		return http.DefaultClient.Do(req)
+	}
+	require.Eventually(t, func() bool {
+		resp, err := fetch()
+		if err != nil {
+			return false
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode == http.StatusOK
+	}, 2*time.Second, 20*time.Millisecond)
+	require.NoError(t, server.ReadyzCheck(nil))
+
+	started := time.Now()
+	cancel()
+	require.Eventually(t, func() bool { return server.ReadyzCheck(nil) != nil }, time.Second, 5*time.Millisecond,
+		"readiness must fail once draining")
+
+	t.Log("Fetches are still answered during the drain period")
+	resp, err := fetch()
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.True(t, resp.Close, "connections must be closed after each response while draining")
+
+	select {
+	case err := <-errChan:
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, time.Since(started), 500*time.Millisecond, "server stopped before the drain period elapsed")
+	case <-time.After(5 * time.Second):
+		t.Fatal("Server did not shut down after the drain period")
+	}
+
+	_, err = fetch()
+	assert.Error(t, err, "no new fetches may be accepted after shutdown")
+}
+
 // testTokenReview returns a fakeTokenReview that authenticates "test-token" as
 // system:serviceaccount:default:coraza-engine-test-engine. Used by handler tests.
 func testTokenReview() *fakeTokenReview {
@@ -172,6 +229,7 @@ func TestServer_HandleLatest_Success(t *testing.T) {
 	assert.NotEmpty(t, response.Timestamp)
 	_, err = time.Parse(TimestampFormat, response.Timestamp)
 	assert.NoError(t, err, "Timestamp should be in RFC3339Nano format")
+	assert.Equal(t, "test-instance-"+response.UUID, response.Snapshot)
 }
 
 func TestServer_HandleRules_UUIDConsistency(t *testing.T) {
@@ -205,6 +263,55 @@ func TestServer_HandleRules_UUIDConsistency(t *testing.T) {
 	assert.Equal(t, latestResp.Timestamp, rulesResp.Timestamp.Format(TimestampFormat))
 }
 
+func TestNegotiateProtocol(t *testing.T) {
+	tests := []struct {
+		name        string
+		header      string
+		wantVersion int
+		wantErr     bool
+	}{
+		{name: "no header", wantVersion: 1},
+		{name: "single version", header: "1", wantVersion: 1},
+		{name: "newest common version", header: "1, 7", wantVersion: 1},
+		{name: "invalid entries ignored", header: "v2,1", wantVersion: 1},
+		{name: "no common version", header: "7,8", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			version, err := NegotiateProtocol(tt.header)
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), "the server speaks 1")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantVersion, version)
+		})
+	}
+}
+
+func TestServer_HandleRules_ProtocolNegotiation(t *testing.T) {
+	cache := NewRuleSetCache()
+	logger := utils.NewTestLogger(t)
+	server := NewServer(cache, testServerAddr, logger, nil, testTokenReview())
+	cache.Put("default/test-instance", "test rules", nil)
+
+	req := authenticatedRequest("/rules/default/test-instance/latest")
+	req.Header.Set(ProtocolVersionHeader, "1,2")
+	w := httptest.NewRecorder()
+	server.handleRules(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get(ProtocolVersionHeader))
+
+	req = authenticatedRequest("/rules/default/test-instance/latest")
+	req.Header.Set(ProtocolVersionHeader, "2")
+	w = httptest.NewRecorder()
+	server.handleRules(w, req)
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+	assert.Empty(t, w.Header().Get(ProtocolVersionHeader))
+}
+
 func TestServer_GCByAge(t *testing.T) {
 	cache := NewRuleSetCache()
 	logger := utils.NewTestLogger(t)
@@ -396,3 +503,237 @@ func TestServer_HandleRules_MethodNotAllowed(t *testing.T) {
 		})
 	}
 }
+
+// recordingReporter is a MatchReporter, HoneypotReporter and
+// HeartbeatReporter that records the reports it receives.
+type recordingReporter struct {
+	caller    AuthResult
+	cacheKey  string
+	matches   map[int]int64
+	hits      map[netip.Addr]int64
+	heartbeat *Heartbeat
+	err       error
+}
+
+func (r *recordingReporter) ReportMatches(_ context.Context, caller AuthResult, cacheKey string, matches map[int]int64) error {
+	r.caller, r.cacheKey, r.matches = caller, cacheKey, matches
+	return r.err
+}
+
+func (r *recordingReporter) ReportHoneypotHits(_ context.Context, caller AuthResult, cacheKey string, hits map[netip.Addr]int64) error {
+	r.caller, r.cacheKey, r.hits = caller, cacheKey, hits
+	return r.err
+}
+
+func (r *recordingReporter) ReportHeartbeat(_ context.Context, caller AuthResult, cacheKey string, heartbeat Heartbeat) error {
+	r.caller, r.cacheKey, r.heartbeat = caller, cacheKey, &heartbeat
+	return r.err
+}
+
+func TestServer_HandleMatches(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		token       string
+		reporterErr error
+		noReporter  bool
+		wantCode    int
+		wantMatches map[int]int64
+	}{
+		{
+			name:        "report recorded",
+			body:        `{"matches":[{"ruleId":942100,"count":3},{"ruleId":920350,"count":1},{"ruleId":942100,"count":2}]}`,
+			token:       "test-token",
+			wantCode:    http.StatusNoContent,
+			wantMatches: map[int]int64{942100: 5, 920350: 1},
+		},
+		{
+			name:     "unauthenticated",
+			body:     `{"matches":[]}`,
+			wantCode: http.StatusForbidden,
+		},
+		{
+			name:     "invalid rule ID",
+			body:     `{"matches":[{"ruleId":0,"count":1}]}`,
+			token:    "test-token",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "malformed body",
+			body:     `{"matches":`,
+			token:    "test-token",
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:        "rejected by reporter",
+			body:        `{"matches":[{"ruleId":942100,"count":1}]}`,
+			token:       "test-token",
+			reporterErr: ErrMatchReportRejected,
+			wantCode:    http.StatusConflict,
+		},
+		{
+			name:        "reporter failure",
+			body:        `{"matches":[{"ruleId":942100,"count":1}]}`,
+			token:       "test-token",
+			reporterErr: errors.New("boom"),
+			wantCode:    http.StatusInternalServerError,
+		},
+		{
+			name:       "no reporter",
+			body:       `{"matches":[]}`,
+			token:      "test-token",
+			noReporter: true,
+			wantCode:   http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewServer(NewRuleSetCache(), testServerAddr, utils.NewTestLogger(t), nil, testTokenReview())
+			reporter := &recordingReporter{err: tt.reporterErr}
+			if !tt.noReporter {
+				server.SetMatchReporter(reporter)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/rules/default/test-instance/matches", strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			server.handleRules(w, req)
+			assert.Equal(t, tt.wantCode, w.Code)
+
+			if tt.wantMatches != nil {
+				assert.Equal(t, tt.wantMatches, reporter.matches)
+				assert.Equal(t, "default/test-instance", reporter.cacheKey)
+				assert.Equal(t, AuthResult{Namespace: "default", Name: "coraza-engine-test-engine"}, reporter.caller)
+			}
+		})
+	}
+
+	t.Run("GET is not a match report", func(t *testing.T) {
+		server := NewServer(NewRuleSetCache(), testServerAddr, utils.NewTestLogger(t), nil, testTokenReview())
+		reporter := &recordingReporter{}
+		server.SetMatchReporter(reporter)
+		w := httptest.NewRecorder()
+		server.handleRules(w, authenticatedRequest("/rules/default/test-instance/matches"))
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Nil(t, reporter.matches)
+	})
+}
+
+func TestServer_HandleHoneypot(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		reporterErr error
+		noReporter  bool
+		wantCode    int
+		wantHits    map[netip.Addr]int64
+	}{
+		{
+			name:     "report recorded",
+			body:     `{"hits":[{"clientIP":"192.0.2.1","count":2},{"clientIP":"::ffff:192.0.2.1","count":1},{"clientIP":"2001:db8::1","count":4}]}`,
+			wantCode: http.StatusNoContent,
+			wantHits: map[netip.Addr]int64{netip.MustParseAddr("192.0.2.1"): 3, netip.MustParseAddr("2001:db8::1"): 4},
+		},
+		{
+			name:     "invalid client address",
+			body:     `{"hits":[{"clientIP":"192.0.2.0/24","count":1}]}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:     "invalid count",
+			body:     `{"hits":[{"clientIP":"192.0.2.1","count":0}]}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:        "rejected by reporter",
+			body:        `{"hits":[{"clientIP":"192.0.2.1","count":1}]}`,
+			reporterErr: ErrHoneypotReportRejected,
+			wantCode:    http.StatusConflict,
+		},
+		{
+			name:       "no reporter",
+			body:       `{"hits":[]}`,
+			noReporter: true,
+			wantCode:   http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewServer(NewRuleSetCache(), testServerAddr, utils.NewTestLogger(t), nil, testTokenReview())
+			reporter := &recordingReporter{err: tt.reporterErr}
+			server.SetMatchReporter(reporter)
+			if !tt.noReporter {
+				server.SetHoneypotReporter(reporter)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/rules/default/test-instance/honeypot", strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer test-token")
+			w := httptest.NewRecorder()
+			server.handleRules(w, req)
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Nil(t, reporter.matches)
+
+			if tt.wantHits != nil {
+				assert.Equal(t, tt.wantHits, reporter.hits)
+				assert.Equal(t, "default/test-instance", reporter.cacheKey)
+			}
+		})
+	}
+}
+
+func TestServer_HandleHeartbeat(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		reporterErr   error
+		wantCode      int
+		wantHeartbeat *Heartbeat
+	}{
+		{
+			name:          "loaded revision",
+			body:          `{"loadedUUID":"a"}`,
+			wantCode:      http.StatusNoContent,
+			wantHeartbeat: &Heartbeat{LoadedUUID: "a"},
+		},
+		{
+			name:          "load failure with a long error",
+			body:          `{"loadedUUID":"a","failedUUID":"b","error":"` + strings.Repeat("x", MaxHeartbeatErrorLength+1) + `"}`,
+			wantCode:      http.StatusNoContent,
+			wantHeartbeat: &Heartbeat{LoadedUUID: "a", FailedUUID: "b", Error: strings.Repeat("x", MaxHeartbeatErrorLength)},
+		},
+		{
+			name:     "empty heartbeat",
+			body:     `{}`,
+			wantCode: http.StatusBadRequest,
+		},
+		{
+			name:        "rejected by reporter",
+			body:        `{"loadedUUID":"a"}`,
+			reporterErr: ErrHeartbeatRejected,
+			wantCode:    http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewServer(NewRuleSetCache(), testServerAddr, utils.NewTestLogger(t), nil, testTokenReview())
+			reporter := &recordingReporter{err: tt.reporterErr}
+			server.SetHeartbeatReporter(reporter)
+
+			req := httptest.NewRequest(http.MethodPost, "/rules/default/test-instance/heartbeat", strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer test-token")
+			w := httptest.NewRecorder()
+			server.handleRules(w, req)
+			assert.Equal(t, tt.wantCode, w.Code)
+
+			if tt.wantHeartbeat != nil {
+				assert.Equal(t, tt.wantHeartbeat, reporter.heartbeat)
+				assert.Equal(t, "default/test-instance", reporter.cacheKey)
+			}
+		})
+	}
+}
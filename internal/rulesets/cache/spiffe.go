@@ -0,0 +1,320 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+// SPIFFE Workload Authentication
+// -----------------------------------------------------------------------------
+
+const (
+	// SVIDCertFile is the name of the file holding the X.509 SVID of the
+	// cache server and its intermediates, in the SVID directory.
+	SVIDCertFile = "tls.crt"
+
+	// SVIDKeyFile is the name of the file holding the private key of the
+	// X.509 SVID of the cache server, in the SVID directory.
+	SVIDKeyFile = "tls.key"
+
+	// SVIDBundleFile is the name of the file holding the X.509 trust bundle
+	// of the trust domain, in the SVID directory.
+	SVIDBundleFile = "ca.crt"
+)
+
+// ErrWorkloadNotAuthorized is returned by a WorkloadAuthorizer when the
+// workload is not expected to fetch the RuleSet, for example because it is
+// not a gateway of an Engine using it.
+var ErrWorkloadNotAuthorized = errors.New("workload not authorized")
+
+// SPIFFEID is the identity of a workload, "spiffe://<trust domain><path>".
+type SPIFFEID struct {
+	// TrustDomain is the trust domain the workload belongs to.
+	TrustDomain string
+
+	// Path identifies the workload within its trust domain, such as
+	// "/ns/<namespace>/sa/<service account>".
+	Path string
+}
+
+// String returns the URI form of the SPIFFE ID.
+func (id SPIFFEID) String() string {
+	return "spiffe://" + id.TrustDomain + id.Path
+}
+
+// ParseSPIFFEID parses the URI form of the SPIFFE ID of a workload.
+func ParseSPIFFEID(raw string) (SPIFFEID, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return SPIFFEID{}, fmt.Errorf("invalid SPIFFE ID %q: %w", raw, err)
+	}
+	if u.Scheme != "spiffe" || u.User != nil || u.Port() != "" || u.RawQuery != "" || u.Fragment != "" || u.Opaque != "" {
+		return SPIFFEID{}, fmt.Errorf("invalid SPIFFE ID %q", raw)
+	}
+	if err := ValidateTrustDomain(u.Host); err != nil {
+		return SPIFFEID{}, fmt.Errorf("invalid SPIFFE ID %q: %w", raw, err)
+	}
+	if u.Path == "" || u.Path == "/" || u.RawPath != "" || strings.Contains(u.Path, "//") || strings.HasSuffix(u.Path, "/") {
+		return SPIFFEID{}, fmt.Errorf("invalid SPIFFE ID %q: not a workload path", raw)
+	}
+	return SPIFFEID{TrustDomain: u.Host, Path: u.Path}, nil
+}
+
+// ValidateTrustDomain checks that name is a valid SPIFFE trust domain name:
+// lowercase letters, digits, dots, dashes and underscores.
+func ValidateTrustDomain(name string) error {
+	if name == "" {
+		return errors.New("trust domain must not be empty")
+	}
+	for _, c := range name {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '.' && c != '-' && c != '_' {
+			return fmt.Errorf("trust domain %q may only contain lowercase letters, digits, dots, dashes and underscores", name)
+		}
+	}
+	return nil
+}
+
+// spiffeIDFromCertificate returns the SPIFFE ID of an X.509 SVID, its only
+// URI SAN.
+func spiffeIDFromCertificate(cert *x509.Certificate) (SPIFFEID, error) {
+	if len(cert.URIs) != 1 {
+		return SPIFFEID{}, fmt.Errorf("certificate has %d URI SANs, an SVID has exactly one", len(cert.URIs))
+	}
+	return ParseSPIFFEID(cert.URIs[0].String())
+}
+
+// WorkloadAuthorizer decides which workloads may fetch a RuleSet.
+type WorkloadAuthorizer interface {
+	// AuthorizeWorkload returns nil when the workload identified by id, whose
+	// token authenticated it as caller, may fetch the RuleSet cache key.
+	AuthorizeWorkload(ctx context.Context, caller AuthResult, id SPIFFEID, cacheKey string) error
+}
+
+// -----------------------------------------------------------------------------
+// SVIDSource
+// -----------------------------------------------------------------------------
+
+// SVIDSource serves the X.509 SVID of the cache server and the trust bundle
+// of its trust domain from a directory, such as a volume of the
+// csi.cert-manager.io or SPIFFE CSI driver. SVIDs are short-lived and
+// rotated in place: the files are read again whenever they change.
+type SVIDSource struct {
+	dir string
+
+	mu      sync.Mutex
+	modTime time.Time
+	cert    *tls.Certificate
+	bundle  *x509.CertPool
+}
+
+// NewSVIDSource returns an SVIDSource reading the files in dir, which must
+// hold a valid SVID and trust bundle already.
+func NewSVIDSource(dir string) (*SVIDSource, error) {
+	s := &SVIDSource{dir: dir}
+	if _, _, err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load returns the SVID and trust bundle, reading them again when the files
+// changed. The previous ones are kept while the new files are incomplete.
+func (s *SVIDSource) load() (*tls.Certificate, *x509.CertPool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var modTime time.Time
+	for _, name := range []string{SVIDCertFile, SVIDKeyFile, SVIDBundleFile} {
+		info, err := os.Stat(filepath.Join(s.dir, name))
+		if err != nil {
+			return s.loaded(err)
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+	if s.cert != nil && modTime.Equal(s.modTime) {
+		return s.cert, s.bundle, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(filepath.Join(s.dir, SVIDCertFile), filepath.Join(s.dir, SVIDKeyFile))
+	if err != nil {
+		return s.loaded(fmt.Errorf("failed to load SVID: %w", err))
+	}
+	bundlePEM, err := os.ReadFile(filepath.Join(s.dir, SVIDBundleFile))
+	if err != nil {
+		return s.loaded(err)
+	}
+	bundle := x509.NewCertPool()
+	if !bundle.AppendCertsFromPEM(bundlePEM) {
+		return s.loaded(fmt.Errorf("no certificates in trust bundle %s", SVIDBundleFile))
+	}
+
+	s.modTime, s.cert, s.bundle = modTime, &cert, bundle
+	return s.cert, s.bundle, nil
+}
+
+// loaded returns the previously loaded SVID and trust bundle, or err when
+// none were loaded yet. Must be called with mu held.
+func (s *SVIDSource) loaded(err error) (*tls.Certificate, *x509.CertPool, error) {
+	if s.cert == nil {
+		return nil, nil, err
+	}
+	return s.cert, s.bundle, nil
+}
+
+// TLSConfig returns the TLS configuration of a server presenting the SVID
+// and requiring a client SVID of trustDomain, signed by the trust bundle.
+// The SPIFFE ID of clients is not checked against a name: that is the job
+// of the WorkloadAuthorizer. No ALPN protocols are advertised, so that any
+// offered by the client, such as Istio's, are accepted.
+func (s *SVIDSource) TLSConfig(trustDomain string) *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ClientAuth: tls.RequireAnyClientCert,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			cert, _, err := s.load()
+			return cert, err
+		},
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			_, bundle, err := s.load()
+			if err != nil {
+				return err
+			}
+			_, err = verifySVID(rawCerts, bundle, trustDomain)
+			return err
+		},
+	}
+}
+
+// verifySVID verifies that the certificate chain presented by a client is
+// an X.509 SVID of trustDomain signed by bundle, and returns its SPIFFE ID.
+func verifySVID(rawCerts [][]byte, bundle *x509.CertPool, trustDomain string) (SPIFFEID, error) {
+	if len(rawCerts) == 0 {
+		return SPIFFEID{}, errors.New("no client SVID presented")
+	}
+	certs := make([]*x509.Certificate, 0, len(rawCerts))
+	for _, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return SPIFFEID{}, fmt.Errorf("invalid client certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+
+	leaf := certs[0]
+	if leaf.IsCA {
+		return SPIFFEID{}, errors.New("client SVID must not be a CA certificate")
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         bundle,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}); err != nil {
+		return SPIFFEID{}, fmt.Errorf("client SVID not signed by the trust bundle: %w", err)
+	}
+
+	id, err := spiffeIDFromCertificate(leaf)
+	if err != nil {
+		return SPIFFEID{}, err
+	}
+	if id.TrustDomain != trustDomain {
+		return SPIFFEID{}, fmt.Errorf("client SVID %s is not in trust domain %s", id, trustDomain)
+	}
+	return id, nil
+}
+
+// peerSPIFFEID returns the SPIFFE ID of the client SVID of r, verified
+// during the TLS handshake.
+func peerSPIFFEID(r *http.Request) (SPIFFEID, error) {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return SPIFFEID{}, errors.New("missing client SVID")
+	}
+	return spiffeIDFromCertificate(r.TLS.PeerCertificates[0])
+}
+
+// -----------------------------------------------------------------------------
+// RuleSetCacheServer - SPIFFE Authorization
+// -----------------------------------------------------------------------------
+
+// spiffeAuth authorizes the workloads fetching RuleSets by SPIFFE ID. It
+// caches successful authorizations like the TokenAuthenticator, so that
+// polls do not query the API server each time.
+type spiffeAuth struct {
+	svids       *SVIDSource
+	trustDomain string
+	authorizer  WorkloadAuthorizer
+
+	mu         sync.Mutex
+	authorized map[string]time.Time // workload, caller and cache key -> expiry
+}
+
+// authorize checks that the workload of r is authorized to fetch cacheKey
+// on behalf of caller.
+func (a *spiffeAuth) authorize(r *http.Request, caller AuthResult, cacheKey string) error {
+	id, err := peerSPIFFEID(r)
+	if err != nil {
+		return err
+	}
+	if id.TrustDomain != a.trustDomain {
+		return fmt.Errorf("client SVID %s is not in trust domain %s", id, a.trustDomain)
+	}
+
+	key := strings.Join([]string{id.String(), caller.Namespace, caller.Name, cacheKey}, " ")
+	now := time.Now()
+	a.mu.Lock()
+	expiresAt, ok := a.authorized[key]
+	a.mu.Unlock()
+	if ok && now.Before(expiresAt) {
+		return nil
+	}
+
+	if err := a.authorizer.AuthorizeWorkload(r.Context(), caller, id, cacheKey); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.authorized) >= maxAuthCacheSize {
+		for k, expiresAt := range a.authorized {
+			if now.After(expiresAt) {
+				delete(a.authorized, k)
+			}
+		}
+	}
+	if len(a.authorized) < maxAuthCacheSize {
+		a.authorized[key] = now.Add(defaultAuthCacheTTL)
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cache
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/networking-incubator/coraza-kubernetes-operator/test/utils"
+)
+
+// testCA issues X.509 SVIDs for tests.
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{cert: cert, key: key}
+}
+
+// issue returns an SVID for the SPIFFE ID, when not empty, valid for the
+// loopback address.
+func (ca *testCA) issue(t *testing.T, spiffeID string) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	if spiffeID != "" {
+		u, err := url.Parse(spiffeID)
+		require.NoError(t, err)
+		template.URIs = []*url.URL{u}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+// writeSVID writes the SVID and the trust bundle of ca to dir.
+func (ca *testCA) writeSVID(t *testing.T, dir string, svid tls.Certificate) {
+	t.Helper()
+	keyDER, err := x509.MarshalPKCS8PrivateKey(svid.PrivateKey)
+	require.NoError(t, err)
+	files := map[string][]byte{
+		SVIDCertFile:   pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: svid.Certificate[0]}),
+		SVIDKeyFile:    pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}),
+		SVIDBundleFile: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}),
+	}
+	for name, data := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), data, 0o600))
+	}
+}
+
+// fakeWorkloadAuthorizer authorizes the SPIFFE IDs in allowed.
+type fakeWorkloadAuthorizer struct {
+	allowed map[string]bool
+	calls   int
+}
+
+func (a *fakeWorkloadAuthorizer) AuthorizeWorkload(_ context.Context, _ AuthResult, id SPIFFEID, _ string) error {
+	a.calls++
+	if !a.allowed[id.String()] {
+		return fmt.Errorf("%w: %s", ErrWorkloadNotAuthorized, id)
+	}
+	return nil
+}
+
+func TestParseSPIFFEID(t *testing.T) {
+	tests := []struct {
+		raw     string
+		want    SPIFFEID
+		wantErr bool
+	}{
+		{raw: "spiffe://cluster.local/ns/team-a/sa/gateway", want: SPIFFEID{TrustDomain: "cluster.local", Path: "/ns/team-a/sa/gateway"}},
+		{raw: "spiffe://cluster.local", wantErr: true},
+		{raw: "spiffe://cluster.local/", wantErr: true},
+		{raw: "spiffe://cluster.local/ns//sa/gateway", wantErr: true},
+		{raw: "spiffe://Cluster.Local/ns/team-a/sa/gateway", wantErr: true},
+		{raw: "spiffe://cluster.local:8443/ns/team-a/sa/gateway", wantErr: true},
+		{raw: "spiffe://cluster.local/ns/team-a/sa/gateway?x=y", wantErr: true},
+		{raw: "https://cluster.local/ns/team-a/sa/gateway", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			id, err := ParseSPIFFEID(tt.raw)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, id)
+			assert.Equal(t, tt.raw, id.String())
+		})
+	}
+}
+
+func TestServer_SPIFFEAuth(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	ca.writeSVID(t, dir, ca.issue(t, "spiffe://cluster.local/ns/coraza-system/sa/coraza-operator"))
+	svids, err := NewSVIDSource(dir)
+	require.NoError(t, err)
+
+	const addr = "127.0.0.1:38082"
+	cache := NewRuleSetCache()
+	cache.Put("default/test-instance", "SecRuleEngine On", nil)
+	server := NewServer(cache, addr, utils.NewTestLogger(t), nil, testTokenReview())
+	server.SetDrainPeriod(0)
+	authorizer := &fakeWorkloadAuthorizer{allowed: map[string]bool{"spiffe://cluster.local/ns/default/sa/gateway": true}}
+	server.SetSPIFFEAuth(svids, "cluster.local", authorizer)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errChan := make(chan error, 1)
+	go func() {
+		errChan <- server.Start(ctx)
+	}()
+	defer func() {
+		cancel()
+		assert.NoError(t, <-errChan)
+	}()
+	require.Eventually(t, func() bool {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			return false
+		}
+		_ = conn.Close()
+		return true
+	}, 2*time.Second, 20*time.Millisecond)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	fetch := func(clientCerts ...tls.Certificate) (int, error) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:      roots,
+			Certificates: clientCerts,
+		}}}
+		defer client.CloseIdleConnections()
+		req, err := http.NewRequest(http.MethodGet, "https://"+addr+"/rules/default/test-instance", nil)
+		require.NoError(t, err)
+		req.Header.Set("Authorization", "Bearer test-token")
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode, nil
+	}
+
+	t.Run("authorized workload", func(t *testing.T) {
+		svid := ca.issue(t, "spiffe://cluster.local/ns/default/sa/gateway")
+		for range 2 {
+			code, err := fetch(svid)
+			require.NoError(t, err)
+			assert.Equal(t, http.StatusOK, code)
+		}
+		assert.Equal(t, 1, authorizer.calls, "authorizations are cached")
+	})
+
+	t.Run("workload not authorized", func(t *testing.T) {
+		code, err := fetch(ca.issue(t, "spiffe://cluster.local/ns/default/sa/app"))
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusForbidden, code)
+	})
+
+	rejected := []struct {
+		name  string
+		certs []tls.Certificate
+	}{
+		{name: "no client SVID"},
+		{name: "other trust domain", certs: []tls.Certificate{ca.issue(t, "spiffe://example.org/ns/default/sa/gateway")}},
+		{name: "not an SVID", certs: []tls.Certificate{ca.issue(t, "")}},
+		{name: "untrusted CA", certs: []tls.Certificate{newTestCA(t).issue(t, "spiffe://cluster.local/ns/default/sa/gateway")}},
+	}
+	for _, tt := range rejected {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := fetch(tt.certs...)
+			assert.Error(t, err, "the TLS handshake must fail")
+		})
+	}
+}
+
+func TestSVIDSource_Rotation(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	first := ca.issue(t, "spiffe://cluster.local/ns/coraza-system/sa/coraza-operator")
+	ca.writeSVID(t, dir, first)
+	svids, err := NewSVIDSource(dir)
+	require.NoError(t, err)
+
+	cert, _, err := svids.load()
+	require.NoError(t, err)
+	assert.Equal(t, first.Certificate[0], cert.Certificate[0])
+
+	t.Log("Rotating the SVID")
+	second := ca.issue(t, "spiffe://cluster.local/ns/coraza-system/sa/coraza-operator")
+	ca.writeSVID(t, dir, second)
+	later := time.Now().Add(time.Minute)
+	for _, name := range []string{SVIDCertFile, SVIDKeyFile, SVIDBundleFile} {
+		require.NoError(t, os.Chtimes(filepath.Join(dir, name), later, later))
+	}
+	cert, _, err = svids.load()
+	require.NoError(t, err)
+	assert.Equal(t, second.Certificate[0], cert.Certificate[0])
+
+	t.Log("Keeping the SVID while the files are incomplete")
+	require.NoError(t, os.Remove(filepath.Join(dir, SVIDKeyFile)))
+	cert, _, err = svids.load()
+	require.NoError(t, err)
+	assert.Equal(t, second.Certificate[0], cert.Certificate[0])
+
+	_, err = NewSVIDSource(t.TempDir())
+	assert.Error(t, err, "an SVID is required at startup")
+}
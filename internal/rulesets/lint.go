@@ -0,0 +1,323 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rulesets
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// -----------------------------------------------------------------------------
+// Lint - Vars
+// -----------------------------------------------------------------------------
+
+// Severity grades a lint finding. Higher values are more severe.
+type Severity int
+
+const (
+	// SeverityInfo marks findings that are matters of style.
+	SeverityInfo Severity = iota
+	// SeverityWarning marks findings that make rules harder to operate or
+	// likely to misbehave.
+	SeverityWarning
+	// SeverityError marks rules that can never behave as intended.
+	SeverityError
+)
+
+// String returns the name of the severity.
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "Info"
+	case SeverityWarning:
+		return "Warning"
+	default:
+		return "Error"
+	}
+}
+
+// Lint checks.
+const (
+	CheckDeprecatedAction = "deprecated-action"
+	CheckMissingMsg       = "missing-msg"
+	CheckMissingTag       = "missing-tag"
+	CheckBroadVariable    = "broad-variable"
+	CheckPhaseMisuse      = "phase-misuse"
+)
+
+// deprecatedActions are the actions that were removed from ModSecurity v3,
+// or that Coraza accepts but ignores.
+var deprecatedActions = []string{
+	"append", "deprecatevar", "pause", "prepend", "proxy",
+	"sanitiseArg", "sanitiseMatched", "sanitiseMatchedBytes",
+	"sanitiseRequestHeader", "sanitiseResponseHeader",
+}
+
+// disruptiveActions are the actions that interrupt a transaction.
+var disruptiveActions = []string{"block", "deny", "drop", "redirect"}
+
+// requestBodyVariables are only populated from phase 2.
+var requestBodyVariables = []string{
+	"ARGS_POST", "ARGS_POST_NAMES", "FILES", "FILES_COMBINED_SIZE", "FILES_NAMES",
+	"FILES_SIZES", "FILES_TMPNAMES", "MULTIPART_FILENAME", "MULTIPART_NAME",
+	"MULTIPART_PART_HEADERS", "MULTIPART_STRICT_ERROR", "REQBODY_ERROR",
+	"REQBODY_ERROR_MSG", "REQBODY_PROCESSOR_ERROR", "REQUEST_BODY", "REQUEST_BODY_LENGTH", "XML",
+}
+
+// responseHeaderVariables are only populated from phase 3.
+var responseHeaderVariables = []string{
+	"RESPONSE_CONTENT_LENGTH", "RESPONSE_CONTENT_TYPE", "RESPONSE_HEADERS",
+	"RESPONSE_HEADERS_NAMES", "RESPONSE_PROTOCOL", "RESPONSE_STATUS",
+}
+
+// responseBodyVariables are only populated from phase 4.
+var responseBodyVariables = []string{"RESPONSE_ARGS", "RESPONSE_BODY", "RESPONSE_XML"}
+
+// matchAllSelectors are regular expression selectors that select every
+// member of a collection.
+var matchAllSelectors = []string{"/.*/", "/.+/", "/^.*$/", "/^.+$/", "/./"}
+
+// -----------------------------------------------------------------------------
+// Lint
+// -----------------------------------------------------------------------------
+
+// Finding is a problem the linter found in a rule.
+type Finding struct {
+	RuleID   int
+	Check    string
+	Severity Severity
+	Message  string
+}
+
+// Lint checks the SecLang rules for problems that do not prevent them from
+// compiling: deprecated actions, blocking rules without a msg or tag,
+// overly broad variables, and variables read in a phase where they are not
+// populated yet. Findings are returned most severe first, then in rule
+// order.
+func Lint(rules string) []Finding {
+	var findings []Finding
+	chained := false
+	chainID, chainPhase := 0, 2
+	for _, directive := range directives(rules) {
+		args := splitArgs(directive)
+		if len(args) == 0 {
+			continue
+		}
+
+		var variables, actions string
+		switch strings.ToLower(args[0]) {
+		case "secrule":
+			if len(args) < 3 {
+				continue
+			}
+			variables = args[1]
+			if len(args) > 3 {
+				actions = args[3]
+			}
+		case "secaction":
+			if len(args) > 1 {
+				actions = args[1]
+			}
+		default:
+			continue
+		}
+
+		parsed := parseActions(actions)
+		id, _ := strconv.Atoi(parsed["id"])
+		phase := parsePhase(parsed["phase"])
+		if chained {
+			id, phase = chainID, chainPhase
+		}
+		add := func(check string, severity Severity, format string, a ...any) {
+			findings = append(findings, Finding{RuleID: id, Check: check, Severity: severity, Message: fmt.Sprintf(format, a...)})
+		}
+
+		for _, name := range deprecatedActions {
+			if _, ok := parsed[strings.ToLower(name)]; ok {
+				add(CheckDeprecatedAction, SeverityWarning, "action %q is deprecated and has no effect", name)
+			}
+		}
+		if !chained && variables != "" && hasAny(parsed, disruptiveActions) {
+			if _, ok := parsed["msg"]; !ok {
+				add(CheckMissingMsg, SeverityWarning, "disruptive rule has no msg, so its matches cannot be told apart in the logs")
+			}
+			if _, ok := parsed["tag"]; !ok {
+				add(CheckMissingTag, SeverityInfo, "disruptive rule has no tag")
+			}
+		}
+		for _, variable := range strings.Split(variables, "|") {
+			variable = strings.TrimSpace(variable)
+			if variable == "" || variable[0] == '!' {
+				continue
+			}
+			name, selector, _ := strings.Cut(strings.TrimPrefix(variable, "&"), ":")
+			name = strings.ToUpper(name)
+			switch {
+			case name == "FULL_REQUEST":
+				add(CheckBroadVariable, SeverityWarning, "variable FULL_REQUEST inspects the whole request; target the parts the rule is about")
+			case slices.Contains(matchAllSelectors, selector):
+				add(CheckBroadVariable, SeverityWarning, "selector %s of %s selects every member; drop it or select the members the rule is about", selector, name)
+			}
+			switch {
+			case phase < 2 && slices.Contains(requestBodyVariables, name):
+				add(CheckPhaseMisuse, SeverityError, "variable %s is not populated before phase 2, but the rule runs in phase %d", name, phase)
+			case phase < 3 && slices.Contains(responseHeaderVariables, name):
+				add(CheckPhaseMisuse, SeverityError, "variable %s is not populated before phase 3, but the rule runs in phase %d", name, phase)
+			case phase < 4 && slices.Contains(responseBodyVariables, name):
+				add(CheckPhaseMisuse, SeverityError, "variable %s is not populated before phase 4, but the rule runs in phase %d", name, phase)
+			}
+		}
+
+		_, chain := parsed["chain"]
+		if !chained {
+			chainID, chainPhase = id, phase
+		}
+		chained = chain
+	}
+
+	slices.SortStableFunc(findings, func(a, b Finding) int { return int(b.Severity - a.Severity) })
+	return findings
+}
+
+// -----------------------------------------------------------------------------
+// Lint - Parsing
+// -----------------------------------------------------------------------------
+
+// directives returns the directives of rules, with continuation lines joined
+// and comments dropped.
+func directives(rules string) []string {
+	var result []string
+	var current strings.Builder
+	for line := range strings.SplitSeq(rules, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if current.Len() == 0 && (trimmed == "" || trimmed[0] == '#') {
+			continue
+		}
+		if continued, ok := strings.CutSuffix(trimmed, "\\"); ok {
+			current.WriteString(continued)
+			current.WriteByte(' ')
+			continue
+		}
+		current.WriteString(trimmed)
+		result = append(result, current.String())
+		current.Reset()
+	}
+	if current.Len() > 0 {
+		result = append(result, current.String())
+	}
+	return result
+}
+
+// splitArgs splits a directive into its whitespace-separated arguments,
+// which may be double-quoted with backslash escapes.
+func splitArgs(directive string) []string {
+	var args []string
+	var current strings.Builder
+	inQuotes, inArg := false, false
+	for i := 0; i < len(directive); i++ {
+		c := directive[i]
+		switch {
+		case c == '\\' && inQuotes && i+1 < len(directive) && directive[i+1] == '"':
+			current.WriteByte('"')
+			i++
+		case c == '"':
+			inQuotes = !inQuotes
+			inArg = true
+		case (c == ' ' || c == '\t') && !inQuotes:
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteByte(c)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args
+}
+
+// parseActions parses a comma-separated action list into the lowercased
+// action names and the value of their first occurrence. Commas within
+// single-quoted values do not separate actions.
+func parseActions(actions string) map[string]string {
+	parsed := map[string]string{}
+	var current strings.Builder
+	inQuotes := false
+	flush := func() {
+		action := strings.TrimSpace(current.String())
+		current.Reset()
+		if action == "" {
+			return
+		}
+		name, value, _ := strings.Cut(action, ":")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := parsed[name]; !ok {
+			parsed[name] = strings.Trim(strings.TrimSpace(value), "'")
+		}
+	}
+	for i := 0; i < len(actions); i++ {
+		c := actions[i]
+		switch {
+		case c == '\\' && i+1 < len(actions):
+			current.WriteByte(c)
+			current.WriteByte(actions[i+1])
+			i++
+		case c == '\'':
+			inQuotes = !inQuotes
+			current.WriteByte(c)
+		case c == ',' && !inQuotes:
+			flush()
+		default:
+			current.WriteByte(c)
+		}
+	}
+	flush()
+	return parsed
+}
+
+// parsePhase returns the phase number of a phase action value, defaulting
+// to phase 2.
+func parsePhase(value string) int {
+	switch strings.ToLower(value) {
+	case "request":
+		return 2
+	case "response":
+		return 4
+	case "logging":
+		return 5
+	}
+	if phase, err := strconv.Atoi(value); err == nil && phase >= 1 && phase <= 5 {
+		return phase
+	}
+	return 2
+}
+
+// hasAny reports whether actions contains any of names.
+func hasAny(actions map[string]string, names []string) bool {
+	for _, name := range names {
+		if _, ok := actions[name]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rulesets
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules string
+		want  []Finding
+	}{
+		{
+			name: "clean rules",
+			rules: `# Comment with deny and FULL_REQUEST
+SecRuleEngine On
+SecRule REQUEST_URI "@contains /admin" \
+    "id:1001,phase:1,deny,status:403,msg:'Admin access, denied',tag:'custom'"
+SecAction "id:1002,phase:1,pass,nolog,setvar:tx.score=0"
+SecRule ARGS_POST "@rx attack" "id:1003,phase:2,block,msg:'Attack',tag:'custom'"
+SecRule RESPONSE_BODY "@contains secret" "id:1004,phase:response,block,msg:'Leak',tag:'custom'"`,
+		},
+		{
+			name:  "deprecated action",
+			rules: `SecRule ARGS "@rx x" "id:2001,phase:2,pass,sanitiseArg:password"`,
+			want:  []Finding{{RuleID: 2001, Check: CheckDeprecatedAction, Severity: SeverityWarning, Message: `action "sanitiseArg" is deprecated and has no effect`}},
+		},
+		{
+			name:  "disruptive rule without metadata",
+			rules: `SecRule ARGS "@rx x" "id:3001,phase:2,deny"`,
+			want: []Finding{
+				{RuleID: 3001, Check: CheckMissingMsg, Severity: SeverityWarning, Message: "disruptive rule has no msg, so its matches cannot be told apart in the logs"},
+				{RuleID: 3001, Check: CheckMissingTag, Severity: SeverityInfo, Message: "disruptive rule has no tag"},
+			},
+		},
+		{
+			name:  "broad variables",
+			rules: `SecRule FULL_REQUEST|REQUEST_HEADERS:/.*/|!ARGS:/.*/ "@rx x" "id:4001,phase:2,pass"`,
+			want: []Finding{
+				{RuleID: 4001, Check: CheckBroadVariable, Severity: SeverityWarning, Message: "variable FULL_REQUEST inspects the whole request; target the parts the rule is about"},
+				{RuleID: 4001, Check: CheckBroadVariable, Severity: SeverityWarning, Message: "selector /.*/ of REQUEST_HEADERS selects every member; drop it or select the members the rule is about"},
+			},
+		},
+		{
+			name: "phase misuse, sorted by severity",
+			rules: `SecRule ARGS "@rx x" "id:5001,phase:2,deny"
+SecRule REQUEST_HEADERS:Host "@rx x" "id:5002,phase:1,pass,chain"
+    SecRule REQUEST_BODY "@rx y" "deny"
+SecRule RESPONSE_STATUS "@streq 500" "id:5003,pass"`,
+			want: []Finding{
+				{RuleID: 5002, Check: CheckPhaseMisuse, Severity: SeverityError, Message: "variable REQUEST_BODY is not populated before phase 2, but the rule runs in phase 1"},
+				{RuleID: 5003, Check: CheckPhaseMisuse, Severity: SeverityError, Message: "variable RESPONSE_STATUS is not populated before phase 3, but the rule runs in phase 2"},
+				{RuleID: 5001, Check: CheckMissingMsg, Severity: SeverityWarning, Message: "disruptive rule has no msg, so its matches cannot be told apart in the logs"},
+				{RuleID: 5001, Check: CheckMissingTag, Severity: SeverityInfo, Message: "disruptive rule has no tag"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Lint(tt.rules))
+		})
+	}
+}
+
+func TestSplitArgs(t *testing.T) {
+	args := splitArgs(`SecRule ARGS "@rx \"quoted\" value" "id:1,msg:'a, b'"`)
+	require.Len(t, args, 4)
+	assert.Equal(t, `@rx "quoted" value`, args[2])
+
+	actions := parseActions(args[3])
+	assert.Equal(t, map[string]string{"id": "1", "msg": "a, b"}, actions)
+}
@@ -0,0 +1,149 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rulesources
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// -----------------------------------------------------------------------------
+// Remote Rules - Vars
+// -----------------------------------------------------------------------------
+
+const (
+	// remoteRulesFetchTimeout bounds the download of remote rules.
+	remoteRulesFetchTimeout = 30 * time.Second
+
+	// remoteRulesMaxBytes is the maximum size of remote rules, the maximum
+	// size of the rules of a RuleSource.
+	remoteRulesMaxBytes = 1572864
+
+	// remoteRulesCacheMaxBytes bounds the size of the verified remote rules
+	// kept in memory; the oldest are dropped.
+	remoteRulesCacheMaxBytes = 64 << 20
+
+	// RemoteRulesRetryInterval is how long until remote rules left out by
+	// their Warn failAction are downloaded again.
+	RemoteRulesRetryInterval = 5 * time.Minute
+)
+
+var (
+	// ErrRemoteRulesIntegrity is returned for remote rules that do not match
+	// their SHA-256.
+	ErrRemoteRulesIntegrity = errors.New("remote rules do not match their sha256")
+
+	// ErrRemoteRulesTooLarge is returned for remote rules larger than a
+	// RuleSource may be.
+	ErrRemoteRulesTooLarge = errors.New("remote rules too large")
+)
+
+// defaultRemoteFetcher downloads the remote rules of providers built with an
+// Env without RemoteFetcher.
+var defaultRemoteFetcher = NewRemoteFetcher(nil)
+
+// -----------------------------------------------------------------------------
+// Remote Rules - Fetcher
+// -----------------------------------------------------------------------------
+
+// RemoteFetcher downloads remote rules and verifies them against their
+// SHA-256. Verified rules are kept in memory by hash: rules pinned to a hash
+// never change, so they are downloaded once.
+type RemoteFetcher struct {
+	client *http.Client
+
+	mu    sync.Mutex
+	rules map[string]string
+	order []string
+	size  int
+}
+
+// NewRemoteFetcher returns a RemoteFetcher downloading with client, or with
+// a client timing out after 30 seconds when client is nil.
+func NewRemoteFetcher(client *http.Client) *RemoteFetcher {
+	if client == nil {
+		client = &http.Client{Timeout: remoteRulesFetchTimeout}
+	}
+	return &RemoteFetcher{client: client, rules: map[string]string{}}
+}
+
+// Fetch returns the rules at url, which must have the hex-encoded SHA-256
+// sum, or ErrRemoteRulesIntegrity.
+func (f *RemoteFetcher) Fetch(ctx context.Context, url, sum string) (string, error) {
+	f.mu.Lock()
+	rules, ok := f.rules[sum]
+	f.mu.Unlock()
+	if ok {
+		return rules, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, remoteRulesFetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, remoteRulesMaxBytes+1))
+	if err != nil {
+		return "", err
+	}
+	if len(body) > remoteRulesMaxBytes {
+		return "", fmt.Errorf("%w: larger than %d bytes", ErrRemoteRulesTooLarge, remoteRulesMaxBytes)
+	}
+	got := sha256.Sum256(body)
+	if hex.EncodeToString(got[:]) != sum {
+		return "", fmt.Errorf("%w: got sha256 %s", ErrRemoteRulesIntegrity, hex.EncodeToString(got[:]))
+	}
+
+	rules = string(body)
+	f.store(sum, rules)
+	return rules, nil
+}
+
+// store keeps the verified rules of sum, dropping the oldest rules beyond
+// remoteRulesCacheMaxBytes.
+func (f *RemoteFetcher) store(sum, rules string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.rules[sum]; ok {
+		return
+	}
+	f.rules[sum] = rules
+	f.order = append(f.order, sum)
+	f.size += len(rules)
+	for f.size > remoteRulesCacheMaxBytes && len(f.order) > 1 {
+		f.size -= len(f.rules[f.order[0]])
+		delete(f.rules, f.order[0])
+		f.order = slices.Delete(f.order, 0, 1)
+	}
+}
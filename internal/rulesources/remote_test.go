@@ -0,0 +1,100 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rulesources
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sha256Hex returns the hex-encoded SHA-256 of s.
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// newRemoteRulesServer serves body at /rules.conf and counts the requests.
+func newRemoteRulesServer(t *testing.T, body string) (*httptest.Server, *int) {
+	t.Helper()
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/rules.conf" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestRemoteFetcher_Fetch(t *testing.T) {
+	const rules = `SecRule REQUEST_URI "@contains attack" "id:1,phase:1,deny"`
+	server, requests := newRemoteRulesServer(t, rules)
+
+	tests := []struct {
+		name       string
+		path       string
+		sum        string
+		wantErr    error
+		wantErrMsg string
+	}{
+		{name: "matching sha256", path: "/rules.conf", sum: sha256Hex(rules)},
+		{name: "sha256 mismatch", path: "/rules.conf", sum: sha256Hex("other rules"), wantErr: ErrRemoteRulesIntegrity},
+		{name: "not found", path: "/missing.conf", sum: sha256Hex(rules), wantErrMsg: "unexpected status 404"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewRemoteFetcher(server.Client()).Fetch(t.Context(), server.URL+tt.path, tt.sum)
+			switch {
+			case tt.wantErr != nil:
+				assert.ErrorIs(t, err, tt.wantErr)
+			case tt.wantErrMsg != "":
+				assert.ErrorContains(t, err, tt.wantErrMsg)
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, rules, got)
+			}
+		})
+	}
+
+	t.Run("verified rules are downloaded once", func(t *testing.T) {
+		fetcher := NewRemoteFetcher(server.Client())
+		before := *requests
+		for range 2 {
+			got, err := fetcher.Fetch(t.Context(), server.URL+"/rules.conf", sha256Hex(rules))
+			require.NoError(t, err)
+			assert.Equal(t, rules, got)
+		}
+		assert.Equal(t, before+1, *requests)
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		large := strings.Repeat("#", remoteRulesMaxBytes+1)
+		server, _ := newRemoteRulesServer(t, large)
+		_, err := NewRemoteFetcher(server.Client()).Fetch(t.Context(), server.URL+"/rules.conf", sha256Hex(large))
+		assert.ErrorIs(t, err, ErrRemoteRulesTooLarge)
+	})
+}
@@ -0,0 +1,118 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rulesources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/internal/references"
+)
+
+// -----------------------------------------------------------------------------
+// RuleSource Provider
+// -----------------------------------------------------------------------------
+
+// KindRuleSource is the kind of the RuleSource sources, the default kind of
+// the sources of RuleSets.
+const KindRuleSource = "RuleSource"
+
+const (
+	// ReasonDraftRuleSource is reported for a RuleSource that is a draft
+	// awaiting approval.
+	ReasonDraftRuleSource = "DraftRuleSource"
+
+	// ReasonRemoteRulesFetchFailed is reported for remote rules of a
+	// RuleSource that could not be downloaded.
+	ReasonRemoteRulesFetchFailed = "RemoteRulesFetchFailed"
+
+	// ReasonRemoteRulesIntegrityMismatch is reported for remote rules of a
+	// RuleSource that do not match their SHA-256.
+	ReasonRemoteRulesIntegrityMismatch = "RemoteRulesIntegrityMismatch"
+)
+
+func init() {
+	Register(KindRuleSource, func(env Env) Provider {
+		remote := env.Remote
+		if remote == nil {
+			remote = defaultRemoteFetcher
+		}
+		return &ruleSourceProvider{resolver: env.Resolver, remote: remote}
+	})
+}
+
+// ruleSourceProvider fetches the rules of RuleSource objects. The RuleSet
+// controller watches RuleSources, so their rules are never refreshed, except
+// for remote rules left out by their Warn failAction.
+type ruleSourceProvider struct {
+	resolver *references.Resolver
+	remote   *RemoteFetcher
+}
+
+// Fetch implements Provider.
+func (p *ruleSourceProvider) Fetch(ctx context.Context, from references.From, ref Reference) (Fragment, error) {
+	var rs wafv1alpha1.RuleSource
+	if err := p.resolver.Get(ctx, from, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, &rs); err != nil {
+		switch {
+		case references.IsNotPermitted(err):
+			return Fragment{}, NotPermitted(from, ref, err)
+		case apierrors.IsNotFound(err):
+			return Fragment{}, NotFound(from, ref)
+		default:
+			return Fragment{}, AccessError(from, ref, err)
+		}
+	}
+
+	if rs.Annotations[wafv1alpha1.AnnotationDraft] == "true" {
+		return Fragment{}, &Error{
+			Reason:  ReasonDraftRuleSource,
+			Message: fmt.Sprintf("RuleSource %s is a draft: remove its %s annotation to approve it", DisplayName(from, ref), wafv1alpha1.AnnotationDraft),
+		}
+	}
+
+	fragment := Fragment{Validate: rs.Annotations[wafv1alpha1.AnnotationSkipValidation] != "false"}
+	parts := make([]string, 0, len(rs.Spec.RemoteRules)+1)
+	for _, remote := range rs.Spec.RemoteRules {
+		rules, err := p.remote.Fetch(ctx, remote.URL, remote.SHA256)
+		if err == nil {
+			parts = append(parts, rules)
+			continue
+		}
+		reason := ReasonRemoteRulesFetchFailed
+		if errors.Is(err, ErrRemoteRulesIntegrity) {
+			reason = ReasonRemoteRulesIntegrityMismatch
+		}
+		msg := fmt.Sprintf("Remote rules %s of RuleSource %s", remote.URL, DisplayName(from, ref))
+		if remote.FailAction == wafv1alpha1.RemoteRulesFailActionWarn {
+			fragment.Warnings = append(fragment.Warnings, fmt.Sprintf("%s left out: %v", msg, err))
+			fragment.RefreshAfter = RemoteRulesRetryInterval
+			continue
+		}
+		return Fragment{}, &Error{Reason: reason, Message: msg + " unavailable", Err: err}
+	}
+	if rs.Spec.Rules != "" {
+		parts = append(parts, rs.Spec.Rules)
+	}
+	fragment.Rules = strings.Join(parts, "\n")
+	return fragment, nil
+}
@@ -0,0 +1,245 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package rulesources fetches the rules of the sources of RuleSets through
+// providers, one per kind of source.
+//
+// The RuleSet controller looks up the provider of each source by the kind of
+// its reference, and aggregates the rules the providers return; it does not
+// know how any kind of source is stored. The RuleSource provider is built in.
+// Custom builds of the operator add kinds by calling Register from an init
+// function, before the manager starts.
+//
+// Providers report failures as an *Error, whose reason becomes the reason of
+// the Degraded condition of the RuleSet, so that every kind of source reports
+// its status in the same way.
+package rulesources
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/networking-incubator/coraza-kubernetes-operator/internal/references"
+)
+
+// -----------------------------------------------------------------------------
+// Rule Sources - Provider
+// -----------------------------------------------------------------------------
+
+// Reference identifies a source of a RuleSet. Namespace is always set, to the
+// namespace of the RuleSet when the reference omits it.
+type Reference struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+// Fragment is the rules fetched from a source.
+type Fragment struct {
+	// Rules is the SecLang rule text of the source.
+	Rules string
+
+	// Validate reports whether the rules are validated on their own before
+	// being aggregated, which pinpoints the source of an invalid rule. The
+	// aggregated rules are always validated.
+	Validate bool
+
+	// RefreshAfter is how long until the source is fetched again, for
+	// sources whose changes are not watched, or zero. The RuleSet controller
+	// watches RuleSources.
+	RefreshAfter time.Duration
+
+	// Warnings explain why parts of the source were left out of Rules, and
+	// are recorded as events on the RuleSet.
+	Warnings []string
+}
+
+// Provider fetches the rules of one kind of source.
+type Provider interface {
+	// Fetch returns the rules of the source ref on behalf of the RuleSet
+	// from. Failures the RuleSet reports are returned as an *Error.
+	Fetch(ctx context.Context, from references.From, ref Reference) (Fragment, error)
+}
+
+// Env carries what providers are built with.
+type Env struct {
+	// Resolver gets referenced objects, enforcing ReferenceGrants across
+	// namespaces.
+	Resolver *references.Resolver
+
+	// Remote downloads remote rules. A shared RemoteFetcher is used when it
+	// is nil.
+	Remote *RemoteFetcher
+}
+
+// Factory builds the provider of a kind of source.
+type Factory func(Env) Provider
+
+// -----------------------------------------------------------------------------
+// Rule Sources - Registry
+// -----------------------------------------------------------------------------
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]Factory{}
+)
+
+// Register makes the provider built by factory serve the sources of kind. It
+// panics when kind is empty or already registered, as registration happens
+// in init functions.
+func Register(kind string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if kind == "" || factory == nil {
+		panic("rulesources: Register with an empty kind or nil factory")
+	}
+	if _, dup := registry[kind]; dup {
+		panic(fmt.Sprintf("rulesources: Register called twice for kind %s", kind))
+	}
+	registry[kind] = factory
+}
+
+// Kinds returns the sorted kinds of the registered providers.
+func Kinds() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	return slices.Sorted(maps.Keys(registry))
+}
+
+// Providers builds the provider of every registered kind with env.
+func Providers(env Env) map[string]Provider {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	providers := make(map[string]Provider, len(registry))
+	for kind, factory := range registry {
+		providers[kind] = factory(env)
+	}
+	return providers
+}
+
+// -----------------------------------------------------------------------------
+// Rule Sources - Status
+// -----------------------------------------------------------------------------
+
+// The reasons reported for sources, other than references.ReasonRefNotPermitted.
+// The NotFound and AccessError reasons are prefixed with the kind of the
+// source, such as RuleSourceNotFound.
+const (
+	reasonSuffixNotFound    = "NotFound"
+	reasonSuffixAccessError = "AccessError"
+
+	// ReasonUnsupportedSourceKind is reported for sources of a kind no
+	// provider is registered for.
+	ReasonUnsupportedSourceKind = "UnsupportedSourceKind"
+)
+
+// Error is a failure to fetch a source, reported in the Degraded condition of
+// the RuleSet.
+type Error struct {
+	// Reason is the reason of the Degraded condition.
+	Reason string
+
+	// Message is the message of the Degraded condition.
+	Message string
+
+	// Err is the cause of a transient failure, retried with backoff, or nil
+	// when the RuleSet waits for the source to change.
+	Err error
+
+	// notFound reports whether the source does not exist.
+	notFound bool
+}
+
+// Error implements error.
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+// Unwrap returns the cause of a transient failure.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// AsError returns err as an *Error, or nil when it is not one.
+func AsError(err error) *Error {
+	var fetchErr *Error
+	if errors.As(err, &fetchErr) {
+		return fetchErr
+	}
+	return nil
+}
+
+// NotFound returns the error of a source that does not exist.
+func NotFound(from references.From, ref Reference) *Error {
+	return &Error{
+		Reason:   ref.Kind + reasonSuffixNotFound,
+		Message:  fmt.Sprintf("Referenced %s %s does not exist", ref.Kind, DisplayName(from, ref)),
+		notFound: true,
+	}
+}
+
+// IsNotFound reports whether err is the error of a source that does not
+// exist.
+func IsNotFound(err error) bool {
+	fetchErr := AsError(err)
+	return fetchErr != nil && fetchErr.notFound
+}
+
+// NotPermitted returns the error of a source in another namespace that the
+// RuleSet may not reference.
+func NotPermitted(from references.From, ref Reference, err error) *Error {
+	return &Error{
+		Reason:  references.ReasonRefNotPermitted,
+		Message: fmt.Sprintf("Reference to %s %s not permitted: %v", ref.Kind, DisplayName(from, ref), err),
+	}
+}
+
+// AccessError returns the transient error of a source that could not be
+// fetched.
+func AccessError(from references.From, ref Reference, err error) *Error {
+	return &Error{
+		Reason:  ref.Kind + reasonSuffixAccessError,
+		Message: fmt.Sprintf("Failed to access %s %s", ref.Kind, DisplayName(from, ref)),
+		Err:     err,
+	}
+}
+
+// Unsupported returns the error of a source of a kind no provider serves.
+func Unsupported(from references.From, ref Reference) *Error {
+	return &Error{
+		Reason:  ReasonUnsupportedSourceKind,
+		Message: fmt.Sprintf("Source %s is of kind %s, which no provider serves; supported kinds: %s", DisplayName(from, ref), ref.Kind, strings.Join(Kinds(), ", ")),
+	}
+}
+
+// DisplayName returns the name of the source as shown in status messages:
+// "namespace/name" when it is in another namespace than the RuleSet, and
+// its name otherwise.
+func DisplayName(from references.From, ref Reference) string {
+	if ref.Namespace == "" || ref.Namespace == from.Namespace {
+		return ref.Name
+	}
+	return ref.Namespace + "/" + ref.Name
+}
@@ -0,0 +1,187 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rulesources
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/internal/references"
+)
+
+var ruleSetFrom = references.From{Group: wafv1alpha1.GroupVersion.Group, Kind: "RuleSet", Namespace: "team-a"}
+
+func TestRegister(t *testing.T) {
+	assert.Contains(t, Kinds(), KindRuleSource, "the RuleSource provider is built in")
+	assert.Panics(t, func() { Register(KindRuleSource, func(Env) Provider { return nil }) }, "kinds register once")
+	assert.Panics(t, func() { Register("", func(Env) Provider { return nil }) })
+
+	providers := Providers(Env{})
+	assert.Contains(t, providers, KindRuleSource)
+}
+
+func TestErrors(t *testing.T) {
+	local := Reference{Kind: KindRuleSource, Namespace: "team-a", Name: "crs"}
+	shared := Reference{Kind: "ConfigMap", Namespace: "shared", Name: "rules"}
+	cause := errors.New("connection refused")
+
+	tests := []struct {
+		name        string
+		err         *Error
+		wantReason  string
+		wantMessage string
+		wantCause   bool
+	}{
+		{
+			name:        "not found",
+			err:         NotFound(ruleSetFrom, local),
+			wantReason:  "RuleSourceNotFound",
+			wantMessage: "Referenced RuleSource crs does not exist",
+		},
+		{
+			name:        "not found in another namespace",
+			err:         NotFound(ruleSetFrom, shared),
+			wantReason:  "ConfigMapNotFound",
+			wantMessage: "Referenced ConfigMap shared/rules does not exist",
+		},
+		{
+			name:        "not permitted",
+			err:         NotPermitted(ruleSetFrom, shared, cause),
+			wantReason:  references.ReasonRefNotPermitted,
+			wantMessage: "Reference to ConfigMap shared/rules not permitted: connection refused",
+		},
+		{
+			name:        "access error",
+			err:         AccessError(ruleSetFrom, local, cause),
+			wantReason:  "RuleSourceAccessError",
+			wantMessage: "Failed to access RuleSource crs: connection refused",
+			wantCause:   true,
+		},
+		{
+			name:        "unsupported kind",
+			err:         Unsupported(ruleSetFrom, shared),
+			wantReason:  ReasonUnsupportedSourceKind,
+			wantMessage: "Source shared/rules is of kind ConfigMap, which no provider serves; supported kinds: RuleSource",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.wantReason, tt.err.Reason)
+			assert.Equal(t, tt.wantMessage, tt.err.Error())
+			assert.Equal(t, tt.wantCause, errors.Is(tt.err, cause))
+
+			wrapped := AsError(errors.Join(errors.New("fetch"), tt.err))
+			require.NotNil(t, wrapped)
+			assert.Equal(t, tt.wantReason, wrapped.Reason)
+			assert.Equal(t, strings.HasPrefix(tt.name, "not found"), IsNotFound(wrapped))
+		})
+	}
+
+	assert.Nil(t, AsError(cause))
+	assert.False(t, IsNotFound(cause))
+}
+
+func TestRuleSourceProvider_Fetch(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+
+	ruleSource := func(namespace, name string, annotations map[string]string) *wafv1alpha1.RuleSource {
+		return &wafv1alpha1.RuleSource{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace, Annotations: annotations},
+			Spec:       wafv1alpha1.RuleSourceSpec{Rules: "SecRuleEngine On"},
+		}
+	}
+	const remote = "SecRule REQUEST_URI \"@contains attack\" \"id:1,phase:1,deny\""
+	server, _ := newRemoteRulesServer(t, remote)
+	withRemote := func(name string, failAction wafv1alpha1.RemoteRulesFailAction, sum string) *wafv1alpha1.RuleSource {
+		rs := ruleSource("team-a", name, nil)
+		rs.Spec.RemoteRules = []wafv1alpha1.RemoteRules{{URL: server.URL + "/rules.conf", SHA256: sum, FailAction: failAction}}
+		return rs
+	}
+	objects := []client.Object{
+		withRemote("remote", "", sha256Hex(remote)),
+		withRemote("tampered", wafv1alpha1.RemoteRulesFailActionAbort, sha256Hex("other rules")),
+		withRemote("optional", wafv1alpha1.RemoteRulesFailActionWarn, sha256Hex("other rules")),
+		ruleSource("team-a", "crs", nil),
+		ruleSource("team-a", "unvalidated", map[string]string{wafv1alpha1.AnnotationSkipValidation: "false"}),
+		ruleSource("team-a", "draft", map[string]string{wafv1alpha1.AnnotationDraft: "true"}),
+		ruleSource("shared", "crs", nil),
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+	provider := Providers(Env{Resolver: references.NewResolver(c, scheme, false), Remote: NewRemoteFetcher(server.Client())})[KindRuleSource]
+	require.NotNil(t, provider)
+
+	tests := []struct {
+		name          string
+		ref           Reference
+		wantRules     string
+		wantValidate  bool
+		wantReason    string
+		wantTransient bool
+		wantWarning   bool
+	}{
+		{name: "rules", ref: Reference{Kind: KindRuleSource, Namespace: "team-a", Name: "crs"}, wantValidate: true},
+		{name: "remote rules", ref: Reference{Kind: KindRuleSource, Namespace: "team-a", Name: "remote"}, wantRules: remote + "\nSecRuleEngine On", wantValidate: true},
+		{name: "remote rules integrity mismatch", ref: Reference{Kind: KindRuleSource, Namespace: "team-a", Name: "tampered"}, wantReason: ReasonRemoteRulesIntegrityMismatch, wantTransient: true},
+		{name: "remote rules left out", ref: Reference{Kind: KindRuleSource, Namespace: "team-a", Name: "optional"}, wantValidate: true, wantWarning: true},
+		{name: "validation skipped", ref: Reference{Kind: KindRuleSource, Namespace: "team-a", Name: "unvalidated"}},
+		{name: "draft", ref: Reference{Kind: KindRuleSource, Namespace: "team-a", Name: "draft"}, wantReason: ReasonDraftRuleSource},
+		{name: "not found", ref: Reference{Kind: KindRuleSource, Namespace: "team-a", Name: "missing"}, wantReason: "RuleSourceNotFound"},
+		{name: "not permitted", ref: Reference{Kind: KindRuleSource, Namespace: "shared", Name: "crs"}, wantReason: references.ReasonRefNotPermitted},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fragment, err := provider.Fetch(t.Context(), ruleSetFrom, tt.ref)
+			if tt.wantReason != "" {
+				fetchErr := AsError(err)
+				require.NotNil(t, fetchErr)
+				assert.Equal(t, tt.wantReason, fetchErr.Reason)
+				if tt.wantTransient {
+					assert.Error(t, fetchErr.Err, "the source is fetched again with backoff")
+				} else {
+					assert.NoError(t, fetchErr.Err, "the RuleSet waits for the source to change")
+				}
+				return
+			}
+			require.NoError(t, err)
+			if tt.wantRules == "" {
+				tt.wantRules = "SecRuleEngine On"
+			}
+			assert.Equal(t, tt.wantRules, fragment.Rules)
+			assert.Equal(t, tt.wantValidate, fragment.Validate)
+			if tt.wantWarning {
+				require.Len(t, fragment.Warnings, 1)
+				assert.Contains(t, fragment.Warnings[0], "left out")
+				assert.Equal(t, RemoteRulesRetryInterval, fragment.RefreshAfter)
+				return
+			}
+			assert.Empty(t, fragment.Warnings)
+			assert.Zero(t, fragment.RefreshAfter, "RuleSources are watched")
+		})
+	}
+}
@@ -0,0 +1,282 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// Chaos - Vars
+// -----------------------------------------------------------------------------
+
+// operatorPodSelector selects the operator pods, which also serve the RuleSet
+// cache.
+const operatorPodSelector = "control-plane=coraza-controller-manager"
+
+// cacheServerPortName is the name of the operator Service port that fronts
+// the RuleSet cache server.
+const cacheServerPortName = "http-ruleset-cache-server"
+
+// -----------------------------------------------------------------------------
+// Chaos - Disruptions
+// -----------------------------------------------------------------------------
+//
+// The disruptions below affect every scenario using the operator or the
+// gateway, so tests calling them must not call t.Parallel(). Go only starts
+// parallel tests once every sequential test has finished.
+
+// KillOperatorPods force-deletes every operator pod in operatorNamespace and
+// waits until a replacement pod is Ready.
+func (s *Scenario) KillOperatorPods(operatorNamespace string) {
+	s.T.Helper()
+	s.replacePods(operatorNamespace, operatorPodSelector, DefaultTimeout)
+	s.T.Logf("Operator pods in %s replaced", operatorNamespace)
+}
+
+// RestartGateway deletes every pod of the named Gateway and waits until a
+// replacement pod is Ready. The gateway Deployment recreates the pods, and
+// GatewayProxy port-forwards reconnect to them.
+func (s *Scenario) RestartGateway(namespace, gatewayName string) {
+	s.T.Helper()
+	labelSelector := fmt.Sprintf("gateway.networking.k8s.io/gateway-name=%s", gatewayName)
+	s.replacePods(namespace, labelSelector, GatewayReadyTimeout)
+	s.T.Logf("Gateway pods %s/%s replaced", namespace, gatewayName)
+}
+
+// DisruptCacheEndpoints deletes the operator Service fronting the RuleSet
+// cache server, waits for outage, and recreates it. Engines fetching rules
+// in the meantime fail to reach the cache. The Service is recreated on
+// cleanup if the test fails before it is restored.
+func (s *Scenario) DisruptCacheEndpoints(operatorNamespace string, outage time.Duration) {
+	s.T.Helper()
+	services := s.F.KubeClient.CoreV1().Services(operatorNamespace)
+
+	svc := s.cacheServerService(operatorNamespace)
+	restore := svc.DeepCopy()
+	restore.ObjectMeta = metav1.ObjectMeta{
+		Name:        svc.Name,
+		Namespace:   svc.Namespace,
+		Labels:      svc.Labels,
+		Annotations: svc.Annotations,
+	}
+	restore.Spec.ClusterIP = ""
+	restore.Spec.ClusterIPs = nil
+	restore.Status = corev1.ServiceStatus{}
+
+	recreate := func() error {
+		_, err := services.Create(context.Background(), restore, metav1.CreateOptions{})
+		if apierrors.IsAlreadyExists(err) {
+			return nil
+		}
+		return err
+	}
+	s.OnCleanup(func() {
+		if err := recreate(); err != nil {
+			s.T.Logf("warning: failed to restore Service %s/%s: %v", svc.Namespace, svc.Name, err)
+		}
+	})
+
+	require.NoError(s.T, services.Delete(s.T.Context(), svc.Name, metav1.DeleteOptions{}),
+		"delete cache Service %s/%s", svc.Namespace, svc.Name)
+	s.waitForServiceGone(operatorNamespace, svc.Name, svc.UID)
+	s.T.Logf("Cache Service %s/%s deleted for %s", svc.Namespace, svc.Name, outage)
+
+	time.Sleep(outage)
+
+	require.NoError(s.T, recreate(), "recreate cache Service %s/%s", svc.Namespace, svc.Name)
+	s.waitForServiceEndpoints(operatorNamespace, svc.Name)
+	s.T.Logf("Cache Service %s/%s restored", svc.Namespace, svc.Name)
+}
+
+// cacheServerService returns the operator Service exposing the cache server
+// port.
+func (s *Scenario) cacheServerService(operatorNamespace string) *corev1.Service {
+	s.T.Helper()
+	services, err := s.F.KubeClient.CoreV1().Services(operatorNamespace).List(s.T.Context(), metav1.ListOptions{})
+	require.NoError(s.T, err, "list Services in %s", operatorNamespace)
+	for i := range services.Items {
+		for _, port := range services.Items[i].Spec.Ports {
+			if port.Name == cacheServerPortName {
+				return &services.Items[i]
+			}
+		}
+	}
+	require.FailNow(s.T, "cache Service not found", "no Service in %s has a %s port", operatorNamespace, cacheServerPortName)
+	return nil
+}
+
+// replacePods force-deletes the pods matching labelSelector, then waits
+// until one of their replacements is Ready.
+func (s *Scenario) replacePods(namespace, labelSelector string, timeout time.Duration) {
+	s.T.Helper()
+	pods := s.F.KubeClient.CoreV1().Pods(namespace)
+
+	list, err := pods.List(s.T.Context(), metav1.ListOptions{LabelSelector: labelSelector})
+	require.NoError(s.T, err, "list pods matching %s in %s", labelSelector, namespace)
+	require.NotEmpty(s.T, list.Items, "no pods matching %s in %s", labelSelector, namespace)
+
+	killed := map[types.UID]bool{}
+	for _, pod := range list.Items {
+		killed[pod.UID] = true
+		err := pods.Delete(s.T.Context(), pod.Name, metav1.DeleteOptions{GracePeriodSeconds: new(int64)})
+		if err != nil && !apierrors.IsNotFound(err) {
+			require.NoError(s.T, err, "delete pod %s/%s", namespace, pod.Name)
+		}
+	}
+
+	require.Eventually(s.T, func() bool {
+		list, err := pods.List(s.T.Context(), metav1.ListOptions{LabelSelector: labelSelector})
+		if err != nil {
+			return false
+		}
+		for _, pod := range list.Items {
+			if !killed[pod.UID] && pod.DeletionTimestamp == nil && isPodReady(&pod) {
+				return true
+			}
+		}
+		return false
+	}, timeout, DefaultInterval,
+		"no replacement pod matching %s in %s became ready", labelSelector, namespace,
+	)
+}
+
+func (s *Scenario) waitForServiceGone(namespace, name string, uid types.UID) {
+	s.T.Helper()
+	require.Eventually(s.T, func() bool {
+		svc, err := s.F.KubeClient.CoreV1().Services(namespace).Get(s.T.Context(), name, metav1.GetOptions{})
+		return apierrors.IsNotFound(err) || (err == nil && svc.UID != uid)
+	}, DefaultTimeout, DefaultInterval, "Service %s/%s not deleted", namespace, name)
+}
+
+// waitForServiceEndpoints waits until an EndpointSlice of the Service has a
+// ready endpoint.
+func (s *Scenario) waitForServiceEndpoints(namespace, name string) {
+	s.T.Helper()
+	require.Eventually(s.T, func() bool {
+		slices, err := s.F.KubeClient.DiscoveryV1().EndpointSlices(namespace).List(s.T.Context(), metav1.ListOptions{
+			LabelSelector: "kubernetes.io/service-name=" + name,
+		})
+		if err != nil {
+			return false
+		}
+		for _, slice := range slices.Items {
+			for _, ep := range slice.Endpoints {
+				if ep.Conditions.Ready != nil && *ep.Conditions.Ready {
+					return true
+				}
+			}
+		}
+		return false
+	}, DefaultTimeout, DefaultInterval, "Service %s/%s has no ready endpoints", namespace, name)
+}
+
+// -----------------------------------------------------------------------------
+// Chaos - Assertions
+// -----------------------------------------------------------------------------
+
+// ExpectVerdictsThrough sends the requests in a loop while disrupt runs, and
+// fails the test if the WAF answered a request against failurePolicy:
+//
+//   - FailurePolicyFail: a request expected to be blocked must never reach
+//     the backend (HTTP 2xx). Allowed requests may be denied.
+//   - FailurePolicyAllow: a request expected to be allowed must never be
+//     denied with HTTP 403. Blocked requests may get through.
+//
+// Transport errors and 5xx answers are tolerated while disrupt runs, since
+// the gateway itself may be unavailable. Once disrupt returns, every request
+// must get its expected status again, as with ExpectVerdicts. disrupt runs
+// on the test goroutine, so it may use the Scenario helpers:
+//
+//	gw.ExpectVerdictsThrough(wafv1alpha1.FailurePolicyFail, func() {
+//		s.KillOperatorPods("coraza-system")
+//	}, framework.Blocked(framework.AttackRequests()...)...)
+func (g *GatewayProxy) ExpectVerdictsThrough(failurePolicy wafv1alpha1.FailurePolicy, disrupt func(), expectations ...TrafficExpectation) {
+	g.s.T.Helper()
+
+	var (
+		mu         sync.Mutex
+		violations []string
+		sent       int
+	)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			for _, e := range expectations {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				res := g.Send(e.Request)
+				mu.Lock()
+				sent++
+				if res.Err == nil && violatesFailurePolicy(failurePolicy, e.Status, res.StatusCode) {
+					violations = append(violations, fmt.Sprintf("%s (%s %s) expected %d, got %d",
+						e.Request.Name, methodOrGet(e.Request.Method), e.Request.Path, e.Status, res.StatusCode))
+				}
+				mu.Unlock()
+			}
+			select {
+			case <-done:
+				return
+			case <-time.After(DefaultInterval):
+			}
+		}
+	}()
+
+	func() {
+		defer func() {
+			close(done)
+			<-stopped
+		}()
+		disrupt()
+	}()
+
+	mu.Lock()
+	g.s.T.Logf("Sent %d requests during the disruption", sent)
+	assert.Empty(g.s.T, violations, "failurePolicy %q violated during the disruption", failurePolicy)
+	mu.Unlock()
+
+	g.ExpectVerdicts(expectations...)
+}
+
+// violatesFailurePolicy reports whether got, answered to a request expected
+// to return want, breaks failurePolicy.
+func violatesFailurePolicy(failurePolicy wafv1alpha1.FailurePolicy, want, got int) bool {
+	switch failurePolicy {
+	case wafv1alpha1.FailurePolicyAllow:
+		return want != http.StatusForbidden && got == http.StatusForbidden
+	default:
+		return want == http.StatusForbidden && got >= 200 && got < 300
+	}
+}
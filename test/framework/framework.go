@@ -79,6 +79,9 @@ type Framework struct {
 	// Gateways built by BuildGateway. Empty means omit the label (default-revision
 	// Istio). Set via ISTIO_GATEWAY_REVISION (e.g. "coraza" for kind, "openshift-gateway" for OCP).
 	IstioGatewayRevision string
+
+	// namespaces holds the namespaces handed out by Scenario.PooledNamespace.
+	namespaces *namespacePool
 }
 
 // New creates a Framework by detecting the cluster environment.
@@ -129,6 +132,7 @@ func New() (*Framework, error) {
 		DynamicClient:        dynamicClient,
 		ClusterName:          clusterName,
 		IstioGatewayRevision: strings.TrimSpace(os.Getenv("ISTIO_GATEWAY_REVISION")),
+		namespaces:           &namespacePool{},
 	}
 
 	// Set up operator metrics RBAC for integration tests
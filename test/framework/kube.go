@@ -35,3 +35,13 @@ func (f *Framework) kubectlArgs(namespace string, args ...string) []string {
 	cmdArgs = append(cmdArgs, args...)
 	return cmdArgs
 }
+
+// Helm returns an exec.Cmd for running helm against the cluster.
+func (f *Framework) Helm(args ...string) *exec.Cmd {
+	cmdArgs := make([]string, 0, len(args)+2)
+	if ctx := f.KubeContext(); ctx != "" {
+		cmdArgs = append(cmdArgs, "--kube-context", ctx)
+	}
+	cmdArgs = append(cmdArgs, args...)
+	return exec.Command("helm", cmdArgs...)
+}
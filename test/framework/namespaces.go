@@ -0,0 +1,218 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// -----------------------------------------------------------------------------
+// Namespace Pool - Vars
+// -----------------------------------------------------------------------------
+
+// namespacePoolLabel marks the namespaces created by the pool, so leftovers
+// of an interrupted run can be found and deleted.
+const namespacePoolLabel = "test.coraza.io/namespace-pool"
+
+// namespacePoolPrefix prefixes the names of pooled namespaces.
+const namespacePoolPrefix = "pool"
+
+// pooledResource is a namespaced resource that scenarios, or the operator on
+// their behalf, create in a pooled namespace.
+type pooledResource struct {
+	gvr schema.GroupVersionResource
+
+	// preexisting names the objects of the resource that every namespace
+	// has, which are not left behind by a scenario.
+	preexisting []string
+}
+
+// pooledResources are the namespaced resources the framework, the scenarios
+// and the operator create. A released namespace is only reused once none of
+// them is left.
+var pooledResources = []pooledResource{
+	{gvr: EngineGVR},
+	{gvr: RuleSetGVR},
+	{gvr: RuleSourceGVR},
+	{gvr: RuleDataGVR},
+	{gvr: schema.GroupVersionResource{Group: "waf.k8s.coraza.io", Version: "v1alpha1", Resource: "rulesetapprovals"}},
+	{gvr: schema.GroupVersionResource{Group: "waf.k8s.coraza.io", Version: "v1alpha1", Resource: "rulesetsnapshots"}},
+	{gvr: schema.GroupVersionResource{Group: "waf.k8s.coraza.io", Version: "v1alpha1", Resource: "falsepositives"}},
+	{gvr: schema.GroupVersionResource{Group: "waf.k8s.coraza.io", Version: "v1alpha1", Resource: "emergencyblocks"}},
+	{gvr: schema.GroupVersionResource{Group: "waf.k8s.coraza.io", Version: "v1alpha1", Resource: "threatfeeds"}},
+	{gvr: HTTPRouteGVR},
+	{gvr: GatewayGVR},
+	{gvr: WasmPluginGVR},
+	{gvr: schema.GroupVersionResource{Group: "telemetry.istio.io", Version: "v1", Resource: "telemetries"}},
+	{gvr: schema.GroupVersionResource{Group: "networking.k8s.io", Version: "v1", Resource: "networkpolicies"}},
+	{gvr: schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}},
+	{gvr: schema.GroupVersionResource{Version: "v1", Resource: "services"}},
+	{gvr: schema.GroupVersionResource{Version: "v1", Resource: "pods"}},
+	{gvr: schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, preexisting: []string{"kube-root-ca.crt", "istio-ca-root-cert"}},
+	{gvr: schema.GroupVersionResource{Version: "v1", Resource: "secrets"}},
+	{gvr: schema.GroupVersionResource{Version: "v1", Resource: "serviceaccounts"}, preexisting: []string{"default"}},
+}
+
+// -----------------------------------------------------------------------------
+// Namespace Pool
+// -----------------------------------------------------------------------------
+
+// namespacePool keeps the namespaces released by finished scenarios for
+// reuse, sparing parallel scenarios the namespace creation and Istio CA
+// certificate setup.
+type namespacePool struct {
+	mu   sync.Mutex
+	free []string
+}
+
+func (p *namespacePool) get() (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.free) == 0 {
+		return "", false
+	}
+	name := p.free[len(p.free)-1]
+	p.free = p.free[:len(p.free)-1]
+	return name, true
+}
+
+func (p *namespacePool) put(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.free = append(p.free, name)
+}
+
+func (p *namespacePool) drain() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.free = nil
+}
+
+// PooledNamespace returns an empty namespace for the scenario, reusing one
+// released by a finished scenario when available. Names are unique per
+// pool ("pool-a1b2c3"), so parallel scenarios never share a namespace.
+//
+// When the scenario ends, after its other cleanups, the namespace goes back
+// to the pool once every resource the framework creates is gone from it. It
+// is deleted instead if the scenario failed or resources are left behind.
+// Call Framework.DrainNamespacePool from TestMain to delete the pool.
+func (s *Scenario) PooledNamespace() string {
+	s.T.Helper()
+
+	name, reused := s.F.namespaces.get()
+	if !reused {
+		b := make([]byte, 3)
+		if _, err := rand.Read(b); err != nil {
+			s.T.Fatalf("generate random suffix: %v", err)
+		}
+		name = fmt.Sprintf("%s-%x", namespacePoolPrefix, b)
+
+		namespaceMu.Lock()
+		_, err := s.F.KubeClient.CoreV1().Namespaces().Create(s.T.Context(), &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   name,
+				Labels: map[string]string{namespacePoolLabel: "true"},
+			},
+		}, metav1.CreateOptions{})
+		namespaceMu.Unlock()
+		if err != nil {
+			s.T.Fatalf("create pooled namespace %s: %v", name, err)
+		}
+	}
+
+	s.trackNamespace(name)
+	s.T.Logf("Using pooled namespace: %s (reused: %t)", name, reused)
+
+	s.OnCleanup(func() {
+		if !s.T.Failed() {
+			err := s.F.waitForNamespaceEmpty(name)
+			if err == nil {
+				s.F.namespaces.put(name)
+				return
+			}
+			s.T.Logf("cleanup: not reusing namespace %s: %v", name, err)
+		}
+		if err := s.F.KubeClient.CoreV1().Namespaces().Delete(
+			context.Background(), name, metav1.DeleteOptions{},
+		); err != nil {
+			s.T.Logf("cleanup: failed to delete namespace %s: %v", name, err)
+		}
+	})
+	return name
+}
+
+// DrainNamespacePool deletes the namespaces waiting in the pool, as well as
+// pooled namespaces left over from an earlier interrupted run.
+func (f *Framework) DrainNamespacePool() {
+	f.namespaces.drain()
+
+	ctx := context.Background()
+	namespaces, err := f.KubeClient.CoreV1().Namespaces().List(ctx, metav1.ListOptions{
+		LabelSelector: namespacePoolLabel + "=true",
+	})
+	if err != nil {
+		fmt.Printf("warning: failed to list pooled namespaces: %v\n", err)
+		return
+	}
+	for _, ns := range namespaces.Items {
+		if err := f.KubeClient.CoreV1().Namespaces().Delete(ctx, ns.Name, metav1.DeleteOptions{}); err != nil {
+			fmt.Printf("warning: failed to delete pooled namespace %s: %v\n", ns.Name, err)
+		}
+	}
+}
+
+// waitForNamespaceEmpty waits until none of the pooledResources is left in
+// the namespace, other than the objects every namespace has. Resources deleted by scenario cleanup may still be
+// finalizing, or being garbage collected.
+func (f *Framework) waitForNamespaceEmpty(namespace string) error {
+	var remaining string
+	err := wait.PollUntilContextTimeout(context.Background(), DefaultInterval, DefaultTimeout, true,
+		func(ctx context.Context) (bool, error) {
+			for _, resource := range pooledResources {
+				opts := metav1.ListOptions{}
+				if len(resource.preexisting) == 0 {
+					opts.Limit = 1
+				}
+				list, err := f.DynamicClient.Resource(resource.gvr).Namespace(namespace).List(ctx, opts)
+				if err != nil {
+					// Kinds that are not installed cannot be left behind.
+					continue
+				}
+				for _, item := range list.Items {
+					if !slices.Contains(resource.preexisting, item.GetName()) {
+						remaining = fmt.Sprintf("%s %s", resource.gvr.Resource, item.GetName())
+						return false, nil
+					}
+				}
+			}
+			return true, nil
+		})
+	if err != nil {
+		return fmt.Errorf("%s still present after %s: %w", remaining, DefaultTimeout.Round(time.Second), err)
+	}
+	return nil
+}
@@ -409,17 +409,15 @@ func (c *CacheServerProxy) maintain(ctx context.Context, operatorNamespace strin
 }
 
 func (c *CacheServerProxy) runPortForward(ctx context.Context, operatorNamespace string) error {
-	const labelSelector = "control-plane=coraza-controller-manager"
-
 	pods, err := c.s.F.KubeClient.CoreV1().Pods(operatorNamespace).List(
 		ctx,
-		metav1.ListOptions{LabelSelector: labelSelector},
+		metav1.ListOptions{LabelSelector: operatorPodSelector},
 	)
 	if err != nil {
 		return fmt.Errorf("list pods: %w", err)
 	}
 	if len(pods.Items) == 0 {
-		return fmt.Errorf("no pods matching %s in %s", labelSelector, operatorNamespace)
+		return fmt.Errorf("no pods matching %s in %s", operatorPodSelector, operatorNamespace)
 	}
 
 	podName := pods.Items[0].Name
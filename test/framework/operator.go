@@ -0,0 +1,230 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package framework
+
+import (
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// -----------------------------------------------------------------------------
+// Operator Installation - Vars
+// -----------------------------------------------------------------------------
+
+const (
+	// OperatorReleaseName is the Helm release name of the operator.
+	OperatorReleaseName = "coraza-kubernetes-operator"
+
+	// OperatorChartRepo is the Helm repository operator releases are
+	// published to.
+	OperatorChartRepo = "https://networking-incubator.github.io/coraza-kubernetes-operator/"
+
+	// OperatorChartDir is the operator chart of the current build, relative
+	// to the repository root.
+	OperatorChartDir = "charts/coraza-kubernetes-operator"
+
+	// OperatorInstallTimeout bounds a Helm install or upgrade, including the
+	// rollout of the operator Deployment.
+	OperatorInstallTimeout = 5 * time.Minute
+)
+
+// crdGVR is the GroupVersionResource for CustomResourceDefinitions.
+var crdGVR = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+
+// -----------------------------------------------------------------------------
+// Operator Installation
+// -----------------------------------------------------------------------------
+
+// OperatorRelease describes a Helm installation of the operator.
+type OperatorRelease struct {
+	// Namespace is the operator namespace. Defaults to "coraza-system".
+	Namespace string
+
+	// Version installs the published chart of that release from
+	// OperatorChartRepo. When empty, ChartDir is installed instead.
+	Version string
+
+	// ChartDir is a local chart directory, used when Version is empty. Its
+	// crds/ are applied first, since Helm never upgrades CRDs.
+	ChartDir string
+
+	// Values are passed to Helm with --set.
+	Values map[string]string
+}
+
+// InstallOperator installs or upgrades the operator Helm release and waits
+// for the operator Deployment to be rolled out.
+func (s *Scenario) InstallOperator(release OperatorRelease) {
+	s.T.Helper()
+	if release.Namespace == "" {
+		release.Namespace = "coraza-system"
+	}
+
+	args := []string{"upgrade", "--install", OperatorReleaseName}
+	if release.Version != "" {
+		args = append(args, "coraza-kubernetes-operator",
+			"--repo", OperatorChartRepo,
+			"--version", release.Version,
+		)
+	} else {
+		require.NotEmpty(s.T, release.ChartDir, "a chart directory or a release version is required")
+		crds := filepath.Join(release.ChartDir, "crds")
+		out, err := s.F.Kubectl("", "apply", "--server-side", "--force-conflicts", "-f", crds).CombinedOutput()
+		require.NoError(s.T, err, "apply CRDs from %s: %s", crds, string(out))
+		args = append(args, release.ChartDir)
+	}
+	args = append(args,
+		"--namespace", release.Namespace,
+		"--create-namespace",
+		"--wait",
+		"--timeout", OperatorInstallTimeout.String(),
+	)
+	for _, k := range slices.Sorted(maps.Keys(release.Values)) {
+		args = append(args, "--set", fmt.Sprintf("%s=%s", k, release.Values[k]))
+	}
+
+	out, err := s.F.Helm(args...).CombinedOutput()
+	require.NoError(s.T, err, "helm %v: %s", args, string(out))
+
+	out, err = s.F.Kubectl(release.Namespace, "rollout", "status", "deployment/"+OperatorReleaseName,
+		"--timeout", OperatorInstallTimeout.String()).CombinedOutput()
+	require.NoError(s.T, err, "operator rollout: %s", string(out))
+
+	if release.Version != "" {
+		s.T.Logf("Installed operator release %s in %s", release.Version, release.Namespace)
+	} else {
+		s.T.Logf("Installed operator from %s in %s", release.ChartDir, release.Namespace)
+	}
+}
+
+// CurrentOperatorRelease returns the OperatorRelease of the current build:
+// the chart in repoRoot, with the image from
+// CONTROLLER_MANAGER_CONTAINER_IMAGE_BASE and
+// CONTROLLER_MANAGER_CONTAINER_IMAGE_TAG when set.
+func CurrentOperatorRelease(repoRoot string) OperatorRelease {
+	release := OperatorRelease{
+		ChartDir: filepath.Join(repoRoot, OperatorChartDir),
+		Values:   map[string]string{},
+	}
+	if repo := os.Getenv("CONTROLLER_MANAGER_CONTAINER_IMAGE_BASE"); repo != "" {
+		release.Values["image.repository"] = repo
+	}
+	if tag := os.Getenv("CONTROLLER_MANAGER_CONTAINER_IMAGE_TAG"); tag != "" {
+		release.Values["image.tag"] = tag
+	}
+	return release
+}
+
+// ExpectStoredVersionsMigrated polls until every WAF CRD lists only its
+// storage version in status.storedVersions, that is until the operator has
+// migrated the objects stored in older API versions.
+func (s *Scenario) ExpectStoredVersionsMigrated() {
+	s.T.Helper()
+	for _, gvr := range []schema.GroupVersionResource{EngineGVR, RuleSetGVR, RuleSourceGVR, RuleDataGVR} {
+		name := gvr.Resource + "." + gvr.Group
+		require.EventuallyWithT(s.T, func(collect *assert.CollectT) {
+			crd, err := s.F.DynamicClient.Resource(crdGVR).Get(s.T.Context(), name, metav1.GetOptions{})
+			if !assert.NoError(collect, err, "get CRD %s", name) {
+				return
+			}
+			stored, _, _ := unstructured.NestedStringSlice(crd.Object, "status", "storedVersions")
+			assert.Equal(collect, []string{crdStorageVersion(crd)}, stored, "storedVersions of CRD %s", name)
+		}, DefaultTimeout, DefaultInterval)
+	}
+}
+
+// crdStorageVersion returns the name of the version of the CRD marked as
+// the storage version.
+func crdStorageVersion(crd *unstructured.Unstructured) string {
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	for _, v := range versions {
+		version, ok := v.(map[string]any)
+		if !ok {
+			continue
+		}
+		if storage, _ := version["storage"].(bool); storage {
+			name, _ := version["name"].(string)
+			return name
+		}
+	}
+	return ""
+}
+
+// -----------------------------------------------------------------------------
+// Operator Installation - Assertions
+// -----------------------------------------------------------------------------
+
+// ExpectServingThrough polls path on the cache server with the bearer token
+// while disrupt runs, and fails the test if the server answered anything but
+// HTTP 200: the RuleSet must stay served, for example by the new replica
+// during an operator upgrade. Transport errors are tolerated, since the
+// port-forward follows the operator pod. disrupt runs on the test goroutine.
+func (c *CacheServerProxy) ExpectServingThrough(path, token string, disrupt func()) {
+	c.s.T.Helper()
+
+	var (
+		mu       sync.Mutex
+		gaps     []string
+		answered int
+	)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			res := c.GetWithBearer(path, token)
+			if res.Err == nil {
+				mu.Lock()
+				answered++
+				if res.StatusCode != http.StatusOK {
+					gaps = append(gaps, fmt.Sprintf("%s: %d %s", time.Now().Format(time.TimeOnly), res.StatusCode, string(res.Body)))
+				}
+				mu.Unlock()
+			}
+			select {
+			case <-done:
+				return
+			case <-time.After(time.Second):
+			}
+		}
+	}()
+
+	func() {
+		defer func() {
+			close(done)
+			<-stopped
+		}()
+		disrupt()
+	}()
+
+	mu.Lock()
+	defer mu.Unlock()
+	c.s.T.Logf("Cache server answered %d polls of %s during the disruption", answered, path)
+	assert.Empty(c.s.T, gaps, "cache server stopped serving %s during the disruption", path)
+}
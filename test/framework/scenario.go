@@ -23,6 +23,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
 	"testing"
@@ -62,7 +63,9 @@ var namespaceMu sync.Mutex
 // creation, resource cleanup, and step tracking.
 //
 // Cleanup is registered automatically via t.Cleanup when the Scenario
-// is created — there is no need to defer it manually.
+// is created — there is no need to defer it manually. Cleanup registration
+// is safe from concurrent goroutines and parallel subtests sharing the
+// Scenario.
 //
 // Usage:
 //
@@ -71,8 +74,10 @@ var namespaceMu sync.Mutex
 //	s.Step("do something")
 //	// ... test logic ...
 type Scenario struct {
-	T          *testing.T
-	F          *Framework
+	T *testing.T
+	F *Framework
+
+	mu         sync.Mutex
 	cleanups   []func()
 	namespaces []string
 }
@@ -98,8 +103,10 @@ func (f *Framework) NewScenario(t *testing.T) *Scenario {
 // Cleanup runs all registered cleanup functions in reverse order.
 // It is idempotent: subsequent calls are no-ops.
 func (s *Scenario) Cleanup() {
+	s.mu.Lock()
 	cleanups := s.cleanups
 	s.cleanups = nil
+	s.mu.Unlock()
 	for i := len(cleanups) - 1; i >= 0; i-- {
 		cleanups[i]()
 	}
@@ -107,9 +114,18 @@ func (s *Scenario) Cleanup() {
 
 // OnCleanup registers a function to run during Cleanup (LIFO order).
 func (s *Scenario) OnCleanup(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.cleanups = append(s.cleanups, fn)
 }
 
+// trackNamespace records a namespace of the scenario for failure diagnostics.
+func (s *Scenario) trackNamespace(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.namespaces = append(s.namespaces, name)
+}
+
 // Step logs a named step in the test output for readability.
 func (s *Scenario) Step(name string) {
 	s.T.Helper()
@@ -146,7 +162,7 @@ func (s *Scenario) CreateNamespace(name string) {
 	_, err := s.F.KubeClient.CoreV1().Namespaces().Create(ctx, ns, metav1.CreateOptions{})
 	require.NoError(s.T, err, "create namespace %s", name)
 
-	s.namespaces = append(s.namespaces, name)
+	s.trackNamespace(name)
 	s.T.Logf("Created namespace: %s", name)
 
 	s.OnCleanup(func() {
@@ -260,7 +276,11 @@ func (s *Scenario) dumpOnFailure() {
 		return
 	}
 
-	for _, ns := range s.namespaces {
+	s.mu.Lock()
+	namespaces := slices.Clone(s.namespaces)
+	s.mu.Unlock()
+
+	for _, ns := range namespaces {
 		s.dumpNamespace(ns)
 	}
 }
@@ -0,0 +1,219 @@
+package framework
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// -----------------------------------------------------------------------------
+// Traffic - Requests
+// -----------------------------------------------------------------------------
+
+// TrafficRequest is a crafted HTTP request sent through a GatewayProxy.
+type TrafficRequest struct {
+	// Name identifies the request in assertion failures.
+	Name string
+	// Method defaults to GET.
+	Method      string
+	Path        string
+	Headers     map[string]string
+	ContentType string
+	Body        string
+}
+
+// TrafficExpectation is a request and the HTTP status the WAF must answer
+// it with.
+type TrafficExpectation struct {
+	Request TrafficRequest
+	Status  int
+}
+
+// Blocked expects each request to be denied with HTTP 403.
+func Blocked(reqs ...TrafficRequest) []TrafficExpectation {
+	return expectStatus(http.StatusForbidden, reqs)
+}
+
+// Allowed expects each request to reach the backend and return HTTP 200.
+func Allowed(reqs ...TrafficRequest) []TrafficExpectation {
+	return expectStatus(http.StatusOK, reqs)
+}
+
+func expectStatus(code int, reqs []TrafficRequest) []TrafficExpectation {
+	expectations := make([]TrafficExpectation, 0, len(reqs))
+	for _, r := range reqs {
+		expectations = append(expectations, TrafficExpectation{Request: r, Status: code})
+	}
+	return expectations
+}
+
+// -----------------------------------------------------------------------------
+// Traffic - Catalog
+// -----------------------------------------------------------------------------
+
+// AttackRequests returns a catalog of common attacks: SQL injection, XSS,
+// path traversal, command injection and a vulnerability scanner, in the
+// query string, the request body and the headers. AttackDetectionRules
+// blocks all of them.
+func AttackRequests() []TrafficRequest {
+	query := func(name, param, payload string) TrafficRequest {
+		return TrafficRequest{Name: name, Path: "/?" + param + "=" + url.QueryEscape(payload)}
+	}
+	return []TrafficRequest{
+		query("sqli_union_select", "id", "1 UNION SELECT password FROM users"),
+		query("sqli_tautology", "user", "' OR '1'='1"),
+		query("xss_script_tag", "q", "<script>alert(1)</script>"),
+		query("xss_event_handler", "q", `<img src=x onerror=alert(1)>`),
+		query("path_traversal", "file", "../../etc/passwd"),
+		query("command_injection", "host", "example.com; cat /etc/passwd"),
+		{
+			Name:        "sqli_form_body",
+			Method:      http.MethodPost,
+			Path:        "/login",
+			ContentType: "application/x-www-form-urlencoded",
+			Body:        "user=" + url.QueryEscape("admin'--") + "&password=x",
+		},
+		{
+			Name:    "scanner_user_agent",
+			Path:    "/",
+			Headers: map[string]string{"User-Agent": "sqlmap/1.7.2#stable (https://sqlmap.org)"},
+		},
+	}
+}
+
+// CleanRequests returns benign requests resembling the AttackRequests, which
+// AttackDetectionRules must let through.
+func CleanRequests() []TrafficRequest {
+	return []TrafficRequest{
+		{Name: "root", Path: "/"},
+		{Name: "search", Path: "/?q=" + url.QueryEscape("select a union rep")},
+		{Name: "numeric_id", Path: "/?id=12345"},
+		{Name: "file_name", Path: "/?file=report.pdf"},
+		{
+			Name:        "form_body",
+			Method:      http.MethodPost,
+			Path:        "/login",
+			ContentType: "application/x-www-form-urlencoded",
+			Body:        "user=admin&password=correct-horse",
+		},
+		{
+			Name:    "browser_user_agent",
+			Path:    "/",
+			Headers: map[string]string{"User-Agent": "Mozilla/5.0 (X11; Linux x86_64)"},
+		},
+	}
+}
+
+// AttackDetectionRules returns SecLang rules, numbered from firstID, that
+// deny every request of AttackRequests with HTTP 403. They need a RuleSource
+// with "SecRuleEngine On" and "SecRequestBodyAccess On" before them.
+func AttackDetectionRules(firstID int) string {
+	rules := []struct{ target, operator, msg string }{
+		{"ARGS", `@rx (?i)union\s+(all\s+)?select`, "SQLi union select"},
+		{"ARGS", `@rx '\s*or\s+'[^']*'\s*=\s*'`, "SQLi tautology"},
+		{"ARGS", `@rx '\s*(--|#)`, "SQLi comment"},
+		{"ARGS", `@rx (?i)<script`, "XSS script tag"},
+		{"ARGS", `@rx (?i)\bon(error|load|click)\s*=`, "XSS event handler"},
+		{"ARGS", `@contains ../`, "Path traversal"},
+		{"ARGS", `@rx ;\s*(cat|ls|id|wget|curl)\b`, "Command injection"},
+		{"REQUEST_HEADERS:User-Agent", `@rx (?i)(sqlmap|nikto|nmap)`, "Scanner"},
+	}
+	lines := make([]string, 0, len(rules))
+	for i, r := range rules {
+		lines = append(lines, fmt.Sprintf(`SecRule %s "%s" "id:%d,phase:2,deny,status:403,msg:'%s'"`, r.target, r.operator, firstID+i, r.msg))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// -----------------------------------------------------------------------------
+// Traffic - Assertions
+// -----------------------------------------------------------------------------
+
+// Send makes the request through the proxy.
+func (g *GatewayProxy) Send(r TrafficRequest) *HTTPResult {
+	req, err := http.NewRequest(methodOrGet(r.Method), g.URL(r.Path), strings.NewReader(r.Body))
+	if err != nil {
+		return &HTTPResult{Err: err}
+	}
+	if r.ContentType != "" {
+		req.Header.Set("Content-Type", r.ContentType)
+	}
+	for k, v := range r.Headers {
+		req.Header.Set(k, v)
+	}
+	return g.DoRequest(req)
+}
+
+// ExpectVerdicts polls until every request returns its expected status, for
+// example:
+//
+//	gw.ExpectVerdicts(append(
+//		framework.Blocked(framework.AttackRequests()...),
+//		framework.Allowed(framework.CleanRequests()...)...,
+//	)...)
+//
+// Every poll sends every request; on timeout, all mismatches of the last
+// poll are reported together.
+func (g *GatewayProxy) ExpectVerdicts(expectations ...TrafficExpectation) {
+	g.s.T.Helper()
+	require.EventuallyWithT(g.s.T, func(collect *assert.CollectT) {
+		for _, e := range expectations {
+			res := g.Send(e.Request)
+			if !assert.NoError(collect, res.Err, "request %s", e.Request.Name) {
+				continue
+			}
+			assert.Equal(collect, e.Status, res.StatusCode,
+				"expected request %s (%s %s) to return %d, got: %d",
+				e.Request.Name, methodOrGet(e.Request.Method), e.Request.Path, e.Status, res.StatusCode)
+		}
+	}, DefaultTimeout, DefaultInterval)
+}
+
+// -----------------------------------------------------------------------------
+// Traffic - Propagation
+// -----------------------------------------------------------------------------
+
+// PropagationProbeInterval is how often MeasurePropagation sends its probe.
+// It is finer than DefaultInterval so the measured latency is accurate to
+// within a fraction of a second.
+const PropagationProbeInterval = 250 * time.Millisecond
+
+// MeasurePropagation replaces the rules of a RuleSource and returns the time
+// until probe is first blocked with HTTP 403 through gw, that is how long the
+// operator, the cache server and the WAF take to enforce a rule change. The
+// probe must be allowed before the update; rules must block it. The test
+// fails if the probe is not blocked within DefaultTimeout.
+func (s *Scenario) MeasurePropagation(gw *GatewayProxy, namespace, ruleSourceName, rules string, probe TrafficRequest) time.Duration {
+	s.T.Helper()
+
+	res := gw.Send(probe)
+	require.NoError(s.T, res.Err, "probe %s before the update", probe.Name)
+	require.NotEqual(s.T, http.StatusForbidden, res.StatusCode,
+		"probe %s must not be blocked before the update", probe.Name)
+
+	start := time.Now()
+	s.UpdateRuleSource(namespace, ruleSourceName, rules)
+
+	require.Eventually(s.T, func() bool {
+		res := gw.Send(probe)
+		return res.Err == nil && res.StatusCode == http.StatusForbidden
+	}, DefaultTimeout, PropagationProbeInterval,
+		"probe %s not blocked after updating RuleSource %s/%s", probe.Name, namespace, ruleSourceName,
+	)
+
+	latency := time.Since(start)
+	s.T.Logf("Rule change to RuleSource %s/%s enforced after %s", namespace, ruleSourceName, latency.Round(time.Millisecond))
+	return latency
+}
+
+func methodOrGet(method string) string {
+	if method == "" {
+		return http.MethodGet
+	}
+	return method
+}
@@ -20,6 +20,9 @@ package integration
 
 import (
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
 
 	"github.com/networking-incubator/coraza-kubernetes-operator/test/framework"
 )
@@ -101,3 +104,43 @@ func TestReconciliation(t *testing.T) {
 	gw.ExpectBlocked("/maniacalmonkey")
 
 }
+
+// TestRulePropagationLatency measures how long a RuleSource change takes to
+// be enforced at the gateway, and bounds it by the Engine poll interval plus
+// headroom for reconciliation and cache updates.
+func TestRulePropagationLatency(t *testing.T) {
+	t.Parallel()
+	s := fw.NewScenario(t)
+
+	const pollInterval = 5 * time.Second
+	const maxLatency = 3*pollInterval + 15*time.Second
+
+	ns := s.GenerateNamespace("propagation")
+
+	s.Step("create gateway")
+	s.CreateGateway(ns, "gw")
+	s.ExpectGatewayProgrammed(ns, "gw")
+
+	s.Step("deploy rules")
+	s.CreateRuleSource(ns, "base-rules", `SecRuleEngine On`)
+	s.CreateRuleSource(ns, "block-rules", framework.SimpleBlockRule(3101, "before"))
+	s.CreateRuleSet(ns, "ruleset", []string{"base-rules", "block-rules"}, nil)
+
+	s.CreateEngine(ns, "engine", framework.EngineOpts{
+		RuleSetName:  "ruleset",
+		GatewayName:  "gw",
+		PollInterval: int32(pollInterval.Seconds()),
+	})
+	s.ExpectEngineReady(ns, "engine")
+
+	s.CreateEchoBackend(ns, "echo")
+	s.CreateHTTPRoute(ns, "route", "gw", "echo")
+
+	gw := s.ProxyToGateway(ns, "gw")
+	gw.ExpectBlocked("/?test=before")
+
+	s.Step("measure rule propagation")
+	latency := s.MeasurePropagation(gw, ns, "block-rules", framework.SimpleBlockRule(3102, "after"),
+		framework.TrafficRequest{Name: "after", Path: "/?test=after"})
+	assert.Less(t, latency, maxLatency, "rule change took too long to be enforced")
+}
@@ -19,15 +19,14 @@ limitations under the License.
 package integration
 
 import (
-	"context"
 	"fmt"
 	"net/http"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
 	"github.com/networking-incubator/coraza-kubernetes-operator/test/framework"
 )
 
@@ -112,27 +111,8 @@ func TestGatewayPodRestart(t *testing.T) {
 	gw.ExpectBlocked("/?test=blocked")
 	gw.ExpectAllowed("/?test=safe")
 
-	s.Step("delete gateway pods to simulate restart")
-	pods, err := s.F.KubeClient.CoreV1().Pods(ns).List(
-		context.Background(),
-		metav1.ListOptions{
-			LabelSelector: fmt.Sprintf("gateway.networking.k8s.io/gateway-name=%s", "gw"),
-		},
-	)
-	if err != nil {
-		t.Fatalf("failed to list gateway pods: %v", err)
-	}
-
-	for _, pod := range pods.Items {
-		err := s.F.KubeClient.CoreV1().Pods(ns).Delete(
-			context.Background(), pod.Name, metav1.DeleteOptions{},
-		)
-		if err != nil {
-			t.Logf("failed to delete pod %s: %v", pod.Name, err)
-		}
-	}
-
-	s.Step("wait for gateway to be ready again")
+	s.Step("restart gateway pods")
+	s.RestartGateway(ns, "gw")
 	s.ExpectGatewayProgrammed(ns, "gw")
 
 	s.Step("verify WAF rules re-apply after restart")
@@ -141,6 +121,59 @@ func TestGatewayPodRestart(t *testing.T) {
 	gw.ExpectAllowed("/?test=safe")
 }
 
+// TestEnforcementThroughDisruptions verifies that attacks stay blocked while
+// the operator, the gateway and the cache server endpoints are disrupted,
+// and that enforcement is intact afterwards. The disruptions affect every
+// scenario, so this test does not run in parallel.
+func TestEnforcementThroughDisruptions(t *testing.T) {
+	s := fw.NewScenario(t)
+
+	ns := s.GenerateNamespace("disruptions")
+
+	s.Step("create gateway")
+	s.CreateGateway(ns, "gw")
+	s.ExpectGatewayProgrammed(ns, "gw")
+
+	s.Step("deploy attack detection rules")
+	s.CreateRuleSource(ns, "base-rules", `SecRuleEngine On
+SecRequestBodyAccess On`)
+	s.CreateRuleSource(ns, "attack-rules", framework.AttackDetectionRules(5200))
+	s.CreateRuleSet(ns, "ruleset", []string{"base-rules", "attack-rules"}, nil)
+
+	s.CreateEngine(ns, "engine", framework.EngineOpts{
+		RuleSetName:   "ruleset",
+		GatewayName:   "gw",
+		FailurePolicy: wafv1alpha1.FailurePolicyFail,
+	})
+	s.ExpectEngineReady(ns, "engine")
+
+	s.CreateEchoBackend(ns, "echo")
+	s.CreateHTTPRoute(ns, "route", "gw", "echo")
+
+	gw := s.ProxyToGateway(ns, "gw")
+	traffic := append(
+		framework.Blocked(framework.AttackRequests()...),
+		framework.Allowed(framework.CleanRequests()...)...,
+	)
+	gw.ExpectVerdicts(traffic...)
+
+	s.Step("kill operator pods")
+	gw.ExpectVerdictsThrough(wafv1alpha1.FailurePolicyFail, func() {
+		s.KillOperatorPods(operatorNamespace)
+	}, traffic...)
+	s.ExpectEngineReady(ns, "engine")
+
+	s.Step("disrupt cache server endpoints")
+	gw.ExpectVerdictsThrough(wafv1alpha1.FailurePolicyFail, func() {
+		s.DisruptCacheEndpoints(operatorNamespace, 30*time.Second)
+	}, traffic...)
+
+	s.Step("restart gateway")
+	gw.ExpectVerdictsThrough(wafv1alpha1.FailurePolicyFail, func() {
+		s.RestartGateway(ns, "gw")
+	}, traffic...)
+}
+
 // TestEngineRecreateAfterGateway verifies that creating a Gateway after
 // the Engine exists properly applies WAF rules.
 func TestEngineRecreateAfterGateway(t *testing.T) {
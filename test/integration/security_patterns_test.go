@@ -32,7 +32,7 @@ func TestSQLInjectionPatterns(t *testing.T) {
 	t.Parallel()
 	s := fw.NewScenario(t)
 
-	ns := s.GenerateNamespace("sqli-patterns")
+	ns := s.PooledNamespace()
 
 	s.Step("create gateway")
 	s.CreateGateway(ns, "gw")
@@ -114,7 +114,7 @@ func TestXSSPatterns(t *testing.T) {
 	t.Parallel()
 	s := fw.NewScenario(t)
 
-	ns := s.GenerateNamespace("xss-patterns")
+	ns := s.PooledNamespace()
 
 	s.Step("create gateway")
 	s.CreateGateway(ns, "gw")
@@ -194,7 +194,7 @@ func TestPathTraversal(t *testing.T) {
 	t.Parallel()
 	s := fw.NewScenario(t)
 
-	ns := s.GenerateNamespace("path-traversal")
+	ns := s.PooledNamespace()
 
 	s.Step("create gateway")
 	s.CreateGateway(ns, "gw")
@@ -260,7 +260,7 @@ func TestCommandInjection(t *testing.T) {
 	t.Parallel()
 	s := fw.NewScenario(t)
 
-	ns := s.GenerateNamespace("cmd-injection")
+	ns := s.PooledNamespace()
 
 	s.Step("create gateway")
 	s.CreateGateway(ns, "gw")
@@ -330,7 +330,7 @@ func TestProtocolAttacks(t *testing.T) {
 	t.Parallel()
 	s := fw.NewScenario(t)
 
-	ns := s.GenerateNamespace("protocol-attacks")
+	ns := s.PooledNamespace()
 
 	s.Step("create gateway")
 	s.CreateGateway(ns, "gw")
@@ -49,7 +49,8 @@ func TestMain(m *testing.M) {
 	// Run tests
 	code := m.Run()
 
-	// Cleanup metrics RBAC
+	// Cleanup pooled namespaces and metrics RBAC
+	fw.DrainNamespacePool()
 	fw.CleanupMetricsRBAC()
 
 	os.Exit(code)
@@ -48,7 +48,7 @@ func TestBlockByStatusCode(t *testing.T) {
 			t.Parallel()
 			s := fw.NewScenario(t)
 
-			ns := s.GenerateNamespace("status-code")
+			ns := s.PooledNamespace()
 
 			s.Step("create gateway")
 			s.CreateGateway(ns, "gw")
@@ -83,6 +83,43 @@ func TestBlockByStatusCode(t *testing.T) {
 	}
 }
 
+// TestAttackCatalogEnforcement verifies that the framework's attack catalog
+// is blocked end to end while the matching clean requests reach the backend.
+func TestAttackCatalogEnforcement(t *testing.T) {
+	t.Parallel()
+	s := fw.NewScenario(t)
+
+	ns := s.PooledNamespace()
+
+	s.Step("create gateway")
+	s.CreateGateway(ns, "gw")
+	s.ExpectGatewayProgrammed(ns, "gw")
+
+	s.Step("deploy attack detection rules")
+	s.CreateRuleSource(ns, "base-rules", `SecRuleEngine On
+SecRequestBodyAccess On`)
+	s.CreateRuleSource(ns, "attack-rules", framework.AttackDetectionRules(5100))
+	s.CreateRuleSet(ns, "ruleset", []string{"base-rules", "attack-rules"}, nil)
+
+	s.Step("create engine")
+	s.CreateEngine(ns, "engine", framework.EngineOpts{
+		RuleSetName: "ruleset",
+		GatewayName: "gw",
+	})
+	s.ExpectEngineReady(ns, "engine")
+
+	s.Step("deploy backend and route")
+	s.CreateEchoBackend(ns, "echo")
+	s.CreateHTTPRoute(ns, "route", "gw", "echo")
+
+	s.Step("verify attacks are blocked and clean traffic is allowed")
+	gw := s.ProxyToGateway(ns, "gw")
+	gw.ExpectVerdicts(append(
+		framework.Blocked(framework.AttackRequests()...),
+		framework.Allowed(framework.CleanRequests()...)...,
+	)...)
+}
+
 // TestDegradedEngineDoesNotBlockTraffic verifies that an Engine referencing a
 // non-existent RuleSet becomes Degraded (no WasmPlugin is created) and traffic
 // continues to flow through the Gateway without being blocked when failure
@@ -91,7 +128,7 @@ func TestDegradedEngineDoesNotBlockTraffic(t *testing.T) {
 	t.Parallel()
 	s := fw.NewScenario(t)
 
-	ns := s.GenerateNamespace("degraded-engine")
+	ns := s.PooledNamespace()
 
 	s.Step("create gateway")
 	s.CreateGateway(ns, "gw")
@@ -123,7 +160,7 @@ func TestRequestBodyInspection(t *testing.T) {
 	t.Parallel()
 	s := fw.NewScenario(t)
 
-	ns := s.GenerateNamespace("body-inspection")
+	ns := s.PooledNamespace()
 
 	s.Step("create gateway")
 	s.CreateGateway(ns, "gw")
@@ -180,7 +217,7 @@ func TestRequestHeaderInspection(t *testing.T) {
 	t.Parallel()
 	s := fw.NewScenario(t)
 
-	ns := s.GenerateNamespace("header-inspection")
+	ns := s.PooledNamespace()
 
 	s.Step("create gateway")
 	s.CreateGateway(ns, "gw")
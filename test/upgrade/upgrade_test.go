@@ -0,0 +1,111 @@
+//go:build upgrade
+
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package upgrade
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+	"github.com/networking-incubator/coraza-kubernetes-operator/test/framework"
+)
+
+// operatorNamespace is the namespace both releases are installed in.
+const operatorNamespace = "coraza-system"
+
+// TestUpgradeFromPreviousRelease installs the release named by
+// UPGRADE_FROM_VERSION, creates WAF resources, then upgrades to the current
+// build. Attacks must stay blocked and the RuleSet must stay served by the
+// cache server throughout the upgrade, and the CRD storage versions must be
+// migrated afterwards.
+func TestUpgradeFromPreviousRelease(t *testing.T) {
+	from := os.Getenv("UPGRADE_FROM_VERSION")
+	if from == "" {
+		t.Skip("UPGRADE_FROM_VERSION is not set")
+	}
+	s := fw.NewScenario(t)
+
+	values := map[string]string{}
+	if fw.IstioGatewayRevision != "" {
+		values["istio.revision"] = fw.IstioGatewayRevision
+	}
+
+	s.Step("install previous release " + from)
+	s.InstallOperator(framework.OperatorRelease{
+		Namespace: operatorNamespace,
+		Version:   from,
+		Values:    values,
+	})
+
+	ns := s.GenerateNamespace("upgrade")
+
+	s.Step("create resources with the previous release")
+	s.CreateGateway(ns, "gw")
+	s.ExpectGatewayProgrammed(ns, "gw")
+
+	s.CreateRuleSource(ns, "base-rules", `SecRuleEngine On
+SecRequestBodyAccess On`)
+	s.CreateRuleSource(ns, "attack-rules", framework.AttackDetectionRules(5300))
+	s.CreateRuleSet(ns, "ruleset", []string{"base-rules", "attack-rules"}, nil)
+	s.ExpectRuleSetReady(ns, "ruleset")
+
+	s.CreateEngine(ns, "engine", framework.EngineOpts{
+		RuleSetName:   "ruleset",
+		GatewayName:   "gw",
+		FailurePolicy: wafv1alpha1.FailurePolicyFail,
+	})
+	s.ExpectEngineReady(ns, "engine")
+
+	s.CreateEchoBackend(ns, "echo")
+	s.CreateHTTPRoute(ns, "route", "gw", "echo")
+
+	gw := s.ProxyToGateway(ns, "gw")
+	traffic := append(
+		framework.Blocked(framework.AttackRequests()...),
+		framework.Allowed(framework.CleanRequests()...)...,
+	)
+	gw.ExpectVerdicts(traffic...)
+
+	wasmPlugin := s.ExpectWasmPluginExists(ns, "coraza-engine-engine")
+	token, _, err := unstructured.NestedString(wasmPlugin.Object, "spec", "pluginConfig", "cache_token")
+	require.NoError(t, err)
+	require.NotEmpty(t, token, "the WasmPlugin carries a cache token")
+	cs := s.ProxyToCacheServer(operatorNamespace)
+
+	s.Step("upgrade to the current build")
+	current := framework.CurrentOperatorRelease("../..")
+	current.Namespace = operatorNamespace
+	for k, v := range values {
+		current.Values[k] = v
+	}
+	gw.ExpectVerdictsThrough(wafv1alpha1.FailurePolicyFail, func() {
+		cs.ExpectServingThrough("/rules/"+ns+"/ruleset/latest", token, func() {
+			s.InstallOperator(current)
+		})
+	}, traffic...)
+
+	s.Step("verify resources after the upgrade")
+	s.ExpectStoredVersionsMigrated()
+	s.ExpectRuleSetReady(ns, "ruleset")
+	s.ExpectEngineReady(ns, "engine")
+	gw.ExpectVerdicts(traffic...)
+}
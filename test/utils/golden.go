@@ -0,0 +1,62 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// -----------------------------------------------------------------------------
+// Golden Files
+// -----------------------------------------------------------------------------
+
+var updateGolden = flag.Bool("update-golden", false, "rewrite golden files with the current output instead of comparing against them")
+
+// AssertGoldenYAML renders obj as YAML and compares it against the golden
+// file at path, relative to the package under test. Run the test with
+// -update-golden to write the current output to the golden file instead,
+// then review the diff:
+//
+//	go test ./internal/controller -run TestEngineReconciler_WasmPluginGolden -update-golden
+func AssertGoldenYAML(t *testing.T, path string, obj any) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	require.NoError(t, enc.Encode(obj), "render %s", path)
+	require.NoError(t, enc.Close())
+	got := buf.Bytes()
+
+	if *updateGolden {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, got, 0o644))
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	require.NoError(t, err, "read golden file %s (create it with -update-golden)", path)
+	assert.Equal(t, string(want), string(got), "output differs from %s (update it with -update-golden)", path)
+}
@@ -1,7 +1,9 @@
 package corerulesetgen
 
 import (
+	"fmt"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -40,7 +42,10 @@ type ManifestBundle struct {
 }
 
 // Build produces base RuleSource, per-.conf RuleSources, optional RuleData,
-// and RuleSet from a parsed [CRSVersion]. It does not read stderr or write to stdout.
+// and RuleSet from a parsed [CRSVersion]. The RuleSources converted from an
+// existing crs-setup.conf and exclusion files, when set in opts, are placed
+// around the CoreRuleSet rules as a raw Coraza configuration would include
+// them. It does not read stderr or write to stdout.
 func Build(opts Options, scan ScanResult, ver CRSVersion) (*ManifestBundle, error) {
 	opts = mergeUnsupportedIDs(opts)
 
@@ -55,7 +60,40 @@ func Build(opts Options, scan ScanResult, ver CRSVersion) (*ManifestBundle, erro
 	var names []string
 	processed, skipped := 0, 0
 
-	for _, p := range scan.ConfPaths {
+	if opts.CRSSetupFile != "" {
+		rsYAML, skipReason, warns, berr := buildSetupRuleSourceYAML(opts.CRSSetupFile, opts)
+		confResults = append(confResults, ConfFileResult{
+			BaseName:   filepath.Base(opts.CRSSetupFile),
+			Warns:      warns,
+			SourceName: CRSSetupSourceName,
+			YAML:       rsYAML,
+			SkipReason: skipReason,
+		})
+		if berr != nil {
+			return nil, berr
+		}
+		if rsYAML != "" {
+			extra = append(extra, NamedYAML{Name: CRSSetupSourceName, Doc: rsYAML})
+			names = append(names, CRSSetupSourceName)
+			processed++
+		} else {
+			skipped++
+		}
+	}
+
+	// Exclusion files are loaded before the CoreRuleSet rules, except those
+	// named like RESPONSE-999-EXCLUSION-RULES-AFTER-CRS.conf.
+	var before, after []string
+	for _, p := range opts.ExclusionFiles {
+		if isAfterCRSExclusion(p) {
+			after = append(after, p)
+		} else {
+			before = append(before, p)
+		}
+	}
+	paths := slices.Concat(before, scan.ConfPaths, after)
+
+	for _, p := range paths {
 		name, rsYAML, skipReason, warns, berr := buildRuleSourceYAML(p, opts)
 		confResults = append(confResults, ConfFileResult{
 			BaseName:   filepath.Base(p),
@@ -68,6 +106,9 @@ func Build(opts Options, scan ScanResult, ver CRSVersion) (*ManifestBundle, erro
 			return nil, berr
 		}
 		if rsYAML != "" {
+			if slices.Contains(names, name) {
+				return nil, fmt.Errorf("%s: RuleSource name %q is already generated from another file", p, name)
+			}
 			extra = append(extra, NamedYAML{Name: name, Doc: rsYAML})
 			names = append(names, name)
 			processed++
@@ -422,3 +422,87 @@ func TestBuild_wasmProfileTrimmedAndCaseInsensitive(t *testing.T) {
 	require.NotContains(t, bundle.ExtraRuleSources[0].Doc, "id:922110,")
 	require.Contains(t, bundle.ExtraRuleSources[0].Doc, "id:42,")
 }
+
+func TestBuild_convertsCRSSetupAndExclusions(t *testing.T) {
+	dir := filepath.Join("testdata", "minimal", "rules")
+	ver := mustParseCRSVersion(t, "4.24.1")
+	scan, err := Scan(dir)
+	require.NoError(t, err)
+
+	tmp := t.TempDir()
+	setup := filepath.Join(tmp, "crs-setup.conf")
+	require.NoError(t, os.WriteFile(setup, []byte(`# Paranoia level
+SecDefaultAction "phase:1,log,auditlog,deny,status:403"
+SecDefaultAction "phase:2,log,auditlog,deny,status:403"
+SecAction \
+    "id:900000,\
+    phase:1,\
+    pass,\
+    t:none,\
+    nolog,\
+    setvar:tx.blocking_paranoia_level=2"
+SecAction \
+    "id:900990,\
+    phase:1,\
+    pass,\
+    t:none,\
+    nolog,\
+    setvar:tx.crs_setup_version=4240"
+Include /etc/coraza/local.conf
+`), 0o600))
+	before := filepath.Join(tmp, "REQUEST-900-EXCLUSION-RULES-BEFORE-CRS.conf")
+	require.NoError(t, os.WriteFile(before, []byte(`SecRule REQUEST_URI "@beginsWith /upload" "id:1000,phase:1,pass,nolog,ctl:ruleRemoveById=920420"`), 0o600))
+	after := filepath.Join(tmp, "RESPONSE-999-EXCLUSION-RULES-AFTER-CRS.conf")
+	require.NoError(t, os.WriteFile(after, []byte(`SecRuleRemoveById 942100
+SecRule REQUEST_URI "@beginsWith /search" "id:1001,phase:1,pass,nolog,ctl:ruleRemoveTargetById=942100;ARGS:q"`), 0o600))
+
+	bundle, err := Build(Options{
+		RulesDir:       dir,
+		Version:        "4.24.1",
+		RuleSetName:    "migrated",
+		DataSourceName: "coreruleset-data",
+		CRSSetupFile:   setup,
+		ExclusionFiles: []string{after, before},
+	}, scan, ver)
+	require.NoError(t, err)
+
+	names := make([]string, 0, len(bundle.ExtraRuleSources))
+	for _, rs := range bundle.ExtraRuleSources {
+		names = append(names, rs.Name)
+	}
+	require.Equal(t, []string{
+		"crs-setup",
+		"request-900-exclusion-rules-before-crs",
+		"simple",
+		"response-999-exclusion-rules-after-crs",
+	}, names)
+	require.Contains(t, bundle.RuleSetDoc, "    - name: base-rules\n    - name: crs-setup\n    - name: request-900-exclusion-rules-before-crs\n    - name: simple\n    - name: response-999-exclusion-rules-after-crs\n")
+
+	setupDoc := bundle.ExtraRuleSources[0].Doc
+	require.Contains(t, setupDoc, "setvar:tx.blocking_paranoia_level=2")
+	require.NotContains(t, setupDoc, "SecDefaultAction")
+	require.NotContains(t, setupDoc, "900990")
+	require.NotContains(t, setupDoc, "Include")
+	require.Contains(t, bundle.ExtraRuleSources[3].Doc, "SecRuleRemoveById 942100")
+
+	warns := strings.Join(bundle.ConfFileResults[0].Warns, "")
+	require.Contains(t, warns, "Rule ID: 900990 (defined by base-rules)")
+	require.Contains(t, warns, "base-rules defines the default actions of phase 1")
+	require.Contains(t, warns, "Include /etc/coraza/local.conf")
+}
+
+func TestBuild_rejectsDuplicateRuleSourceNames(t *testing.T) {
+	dir := filepath.Join("testdata", "minimal", "rules")
+	ver := mustParseCRSVersion(t, "4.24.1")
+	scan, err := Scan(dir)
+	require.NoError(t, err)
+
+	_, err = Build(Options{
+		RulesDir:       dir,
+		Version:        "4.24.1",
+		RuleSetName:    "default-ruleset",
+		DataSourceName: "coreruleset-data",
+		ExclusionFiles: []string{filepath.Join(dir, "simple.conf")},
+	}, scan, ver)
+	require.ErrorContains(t, err, `RuleSource name "simple" is already generated`)
+}
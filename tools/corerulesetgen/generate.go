@@ -42,6 +42,16 @@ type Options struct {
 	// can be added without changing the flag surface.
 	IgnoreUnsupportedRules string
 
+	// CRSSetupFile is an existing crs-setup.conf, such as the one of a raw
+	// coraza-proxy-wasm deployment, converted into the crs-setup RuleSource
+	// loaded right after base-rules. Optional.
+	CRSSetupFile string
+
+	// ExclusionFiles are existing rule exclusion files, each converted into a
+	// RuleSource. Files named like RESPONSE-999-EXCLUSION-RULES-AFTER-CRS.conf
+	// are loaded after the CoreRuleSet rules, the others before them.
+	ExclusionFiles []string
+
 	// autoIgnoredIDs is populated by Build/mergeUnsupportedIDs: rule IDs
 	// dropped due to a profile merge but not present in the user's
 	// --ignore-rules. Used for clearer warnings in processFileContent.
@@ -110,7 +120,7 @@ func Generate(out io.Writer, opts Options) (*Result, error) {
 		stderrf(opts.Stderr, "Found %d .data files in %s\n", len(scan.DataPaths), rulesPath)
 	}
 
-	stderrf(opts.Stderr, "\nProcessing %d rule files...\n\n", len(scan.ConfPaths))
+	stderrf(opts.Stderr, "\nProcessing %d rule files...\n\n", len(bundle.ConfFileResults))
 
 	for _, r := range bundle.ConfFileResults {
 		stderrf(opts.Stderr, "Processing: %s\n", r.BaseName)
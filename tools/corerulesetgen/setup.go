@@ -0,0 +1,96 @@
+package corerulesetgen
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// CRSSetupSourceName is the name of the RuleSource generated from an existing
+// crs-setup.conf, loaded right after base-rules.
+const CRSSetupSourceName = "crs-setup"
+
+var (
+	// includeLine matches the Include directives of raw Coraza configurations,
+	// which RuleSets replace with their ordered list of sources.
+	includeLine = regexp.MustCompile(`^\s*Include(Optional)?\s`)
+	// defaultActionLine matches SecDefaultAction directives and their phase.
+	defaultActionLine = regexp.MustCompile(`^\s*SecDefaultAction\s+"[^"]*\bphase:'?(\d|request|response|logging)\b`)
+)
+
+// baseRulesIDs are the IDs of the rules of crs-setup.conf that base-rules
+// already defines; Coraza rejects a duplicate rule ID.
+var baseRulesIDs = map[string]struct{}{
+	"900120": {},
+	"900990": {},
+}
+
+// baseRulesDefaultActionPhases are the phases base-rules sets the
+// SecDefaultAction of; Coraza rejects a second one for the same phase.
+var baseRulesDefaultActionPhases = map[string]struct{}{
+	"1":       {},
+	"2":       {},
+	"request": {},
+}
+
+// isAfterCRSExclusion reports whether an exclusion file is loaded after the
+// CoreRuleSet rules, following the CoreRuleSet naming of
+// RESPONSE-999-EXCLUSION-RULES-AFTER-CRS.conf.
+func isAfterCRSExclusion(path string) bool {
+	return strings.Contains(strings.ToUpper(filepath.Base(path)), "AFTER-CRS")
+}
+
+// convertSetupContent adapts an existing crs-setup.conf to the operator: the
+// rules and default actions base-rules already defines are dropped, as are
+// Include directives, since RuleSets list their sources instead.
+func convertSetupContent(content, base string) (string, []string) {
+	var warns []string
+	blocks := splitIntoRules(content)
+	filtered := make([]string, 0, len(blocks))
+	for i := 0; i < len(blocks); i++ {
+		block := blocks[i]
+		stripped := strings.TrimSpace(block)
+		switch {
+		case stripped == "" || strings.HasPrefix(stripped, "#"):
+		case includeLine.MatchString(block):
+			warns = append(warns, fmt.Sprintf("  [warn] Dropped in %s:\n    - %s (add the included rules as RuleSources of the RuleSet instead)\n", base, stripped))
+			continue
+		case defaultActionLine.MatchString(block):
+			phase := defaultActionLine.FindStringSubmatch(block)[1]
+			if _, defined := baseRulesDefaultActionPhases[phase]; defined {
+				warns = append(warns, fmt.Sprintf("  [warn] Dropped in %s:\n    - %s (base-rules defines the default actions of phase %s; edit base-rules to change them)\n", base, stripped, phase))
+				continue
+			}
+		case strings.HasPrefix(stripped, "SecAction") || strings.HasPrefix(stripped, "SecRule"):
+			rid := extractRuleID(block)
+			if _, defined := baseRulesIDs[rid]; defined {
+				warns = append(warns, fmt.Sprintf("  [warn] Dropped in %s:\n    - Rule ID: %s (defined by base-rules)\n", base, rid))
+				continue
+			}
+		}
+		filtered = append(filtered, block)
+	}
+	return strings.Join(filtered, "\n"), warns
+}
+
+// buildSetupRuleSourceYAML converts an existing crs-setup.conf into the
+// crs-setup RuleSource.
+func buildSetupRuleSourceYAML(path string, opts Options) (yamlOut, skipReason string, warns []string, err error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	content := strings.TrimRight(strings.ToValidUTF8(string(raw), ""), "\n\r")
+	processed, warns := convertSetupContent(content, filepath.Base(path))
+	if !strings.Contains(processed, "SecRule") && !strings.Contains(processed, "SecAction") {
+		return "", "No SecRule or SecAction directives found", warns, nil
+	}
+
+	indented := indentMultiline(processed, 4)
+	if err := checkPayloadSize(indented+"\n", CRSSetupSourceName, opts); err != nil {
+		return "", "", warns, err
+	}
+	return formatRuleSourceYAML(CRSSetupSourceName, opts.Namespace, indented), "", warns, nil
+}
@@ -0,0 +1,239 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package wafexport exports the WAF resources of a cluster as a bundle of
+// manifests that can be applied again, for backup and restore or to move
+// them to GitOps.
+//
+// The bundle lists the resources in restore order: OperatorConfig, RuleData,
+// RuleSource, ThreatFeed, FalsePositive, RuleSetApproval, RuleSet and Engine,
+// so that every resource exists before the resources referencing it, and
+// RuleSets find the approvals of their rules. Resources the
+// operator generates, which have a controller owner or the
+// LabelExcludeFromBackup label, are left out: the operator recreates them. Server-populated fields and status
+// are removed, and Engines keep their original creation time in the
+// AnnotationCreatedAt annotation, so that target conflicts are resolved as
+// before the export.
+package wafexport
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// Vars
+// -----------------------------------------------------------------------------
+
+// restoreOrder lists the exported kinds in the order they must be restored.
+var restoreOrder = []struct {
+	kind string
+	list func() client.ObjectList
+}{
+	{"OperatorConfig", func() client.ObjectList { return &wafv1alpha1.OperatorConfigList{} }},
+	{"RuleData", func() client.ObjectList { return &wafv1alpha1.RuleDataList{} }},
+	{"RuleSource", func() client.ObjectList { return &wafv1alpha1.RuleSourceList{} }},
+	{"ThreatFeed", func() client.ObjectList { return &wafv1alpha1.ThreatFeedList{} }},
+	{"FalsePositive", func() client.ObjectList { return &wafv1alpha1.FalsePositiveList{} }},
+	{"RuleSetApproval", func() client.ObjectList { return &wafv1alpha1.RuleSetApprovalList{} }},
+	{"RuleSet", func() client.ObjectList { return &wafv1alpha1.RuleSetList{} }},
+	{"Engine", func() client.ObjectList { return &wafv1alpha1.EngineList{} }},
+}
+
+// removedMetadata are the metadata fields populated by the API server, or
+// tied to the objects of the source cluster, that are removed on export.
+var removedMetadata = []string{
+	"uid",
+	"resourceVersion",
+	"generation",
+	"creationTimestamp",
+	"deletionTimestamp",
+	"deletionGracePeriodSeconds",
+	"managedFields",
+	"ownerReferences",
+	"finalizers",
+	"selfLink",
+}
+
+// -----------------------------------------------------------------------------
+// Export
+// -----------------------------------------------------------------------------
+
+// Options configures an export.
+type Options struct {
+	// Namespace restricts the export to a namespace. Empty exports every
+	// namespace.
+	Namespace string
+}
+
+// Export lists the WAF resources and returns them in restore order, cleaned
+// up for applying again. Within a kind, resources are sorted by namespace and
+// name, except Engines, which are sorted oldest first.
+func Export(ctx context.Context, c client.Reader, opts Options) ([]*unstructured.Unstructured, error) {
+	var listOpts []client.ListOption
+	if opts.Namespace != "" {
+		listOpts = append(listOpts, client.InNamespace(opts.Namespace))
+	}
+
+	var result []*unstructured.Unstructured
+	for _, k := range restoreOrder {
+		list := k.list()
+		if err := c.List(ctx, list, listOpts...); err != nil {
+			return nil, fmt.Errorf("listing %s resources: %w", k.kind, err)
+		}
+		items, err := exportItems(list, k.kind)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, items...)
+	}
+	return result, nil
+}
+
+// exportItems returns the exported items of list, which holds resources of
+// kind, sorted.
+func exportItems(list client.ObjectList, kind string) ([]*unstructured.Unstructured, error) {
+	objects, err := metaItems(list)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*unstructured.Unstructured
+	for _, obj := range objects {
+		if isGenerated(obj) {
+			continue
+		}
+		u, err := exportObject(obj, kind)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, u)
+	}
+
+	slices.SortFunc(items, func(a, b *unstructured.Unstructured) int {
+		if kind == "Engine" {
+			if c := createdAt(a).Compare(createdAt(b)); c != 0 {
+				return c
+			}
+		}
+		return strings.Compare(a.GetNamespace()+"/"+a.GetName(), b.GetNamespace()+"/"+b.GetName())
+	})
+	return items, nil
+}
+
+// metaItems returns the items of list.
+func metaItems(list client.ObjectList) ([]client.Object, error) {
+	items, err := apimeta.ExtractList(list)
+	if err != nil {
+		return nil, err
+	}
+	objects := make([]client.Object, 0, len(items))
+	for _, item := range items {
+		obj, ok := item.(client.Object)
+		if !ok {
+			return nil, fmt.Errorf("unsupported list item type %T", item)
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+// isGenerated reports whether obj was generated by the operator, which
+// recreates it from its owner.
+func isGenerated(obj client.Object) bool {
+	return metav1.GetControllerOf(obj) != nil || obj.GetLabels()[wafv1alpha1.LabelExcludeFromBackup] == "true"
+}
+
+// exportObject converts obj to an unstructured object of kind without its
+// status and server-populated metadata. Engines get the AnnotationCreatedAt
+// annotation when it is missing.
+func exportObject(obj client.Object, kind string) (*unstructured.Unstructured, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, fmt.Errorf("converting %s %s/%s: %w", kind, obj.GetNamespace(), obj.GetName(), err)
+	}
+	u := &unstructured.Unstructured{Object: content}
+	u.SetGroupVersionKind(wafv1alpha1.GroupVersion.WithKind(kind))
+
+	if kind == "Engine" {
+		created := obj.GetCreationTimestamp()
+		if _, ok := obj.GetAnnotations()[wafv1alpha1.AnnotationCreatedAt]; !ok && !created.IsZero() {
+			annotations := u.GetAnnotations()
+			if annotations == nil {
+				annotations = map[string]string{}
+			}
+			annotations[wafv1alpha1.AnnotationCreatedAt] = created.UTC().Format(time.RFC3339)
+			u.SetAnnotations(annotations)
+		}
+	}
+
+	delete(u.Object, "status")
+	for _, field := range removedMetadata {
+		unstructured.RemoveNestedField(u.Object, "metadata", field)
+	}
+	annotations := u.GetAnnotations()
+	delete(annotations, corev1.LastAppliedConfigAnnotation)
+	if len(annotations) == 0 {
+		unstructured.RemoveNestedField(u.Object, "metadata", "annotations")
+	} else {
+		u.SetAnnotations(annotations)
+	}
+	return u, nil
+}
+
+// createdAt returns the time in the AnnotationCreatedAt annotation of an
+// exported Engine.
+func createdAt(u *unstructured.Unstructured) time.Time {
+	t, _ := time.Parse(time.RFC3339, u.GetAnnotations()[wafv1alpha1.AnnotationCreatedAt])
+	return t
+}
+
+// -----------------------------------------------------------------------------
+// Output
+// -----------------------------------------------------------------------------
+
+// WriteManifests writes objects as a multi-document YAML stream.
+func WriteManifests(w io.Writer, objects []*unstructured.Unstructured) error {
+	for i, obj := range objects {
+		doc, err := yaml.Marshal(obj.Object)
+		if err != nil {
+			return fmt.Errorf("encoding %s %s/%s: %w", obj.GetKind(), obj.GetNamespace(), obj.GetName(), err)
+		}
+		if i > 0 {
+			if _, err := io.WriteString(w, "---\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := w.Write(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
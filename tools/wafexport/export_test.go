@@ -0,0 +1,129 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wafexport
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+func TestExport(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+
+	created := func(hour int) metav1.Time {
+		return metav1.NewTime(time.Date(2026, 1, 1, hour, 0, 0, 0, time.UTC))
+	}
+	objects := []runtime.Object{
+		&wafv1alpha1.Engine{
+			ObjectMeta: metav1.ObjectMeta{Name: "a-newer", Namespace: "team-a", CreationTimestamp: created(2), Finalizers: []string{"waf.k8s.coraza.io/networkpolicy-cleanup"}},
+			Spec:       wafv1alpha1.EngineSpec{RuleSet: wafv1alpha1.RuleSetReference{Name: "rules"}},
+			Status:     &wafv1alpha1.EngineStatus{Conditions: []metav1.Condition{{Type: "Ready", Status: metav1.ConditionTrue}}},
+		},
+		&wafv1alpha1.Engine{
+			ObjectMeta: metav1.ObjectMeta{Name: "b-older", Namespace: "team-a", CreationTimestamp: created(3), Annotations: map[string]string{
+				wafv1alpha1.AnnotationCreatedAt: "2026-01-01T01:00:00Z",
+			}},
+			Spec: wafv1alpha1.EngineSpec{RuleSet: wafv1alpha1.RuleSetReference{Name: "rules"}},
+		},
+		&wafv1alpha1.RuleSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "rules", Namespace: "team-a", Annotations: map[string]string{
+				corev1.LastAppliedConfigAnnotation: "{}",
+			}},
+			Spec: wafv1alpha1.RuleSetSpec{Sources: []wafv1alpha1.SourceReference{{Name: "base"}}},
+		},
+		&wafv1alpha1.RuleSetApproval{
+			ObjectMeta: metav1.ObjectMeta{Name: "rules-approval", Namespace: "team-a"},
+			Spec: wafv1alpha1.RuleSetApprovalSpec{
+				RuleSet:  wafv1alpha1.RuleSetReference{Name: "rules"},
+				Revision: "0b6b8a61-3c3e-5b8e-9a53-3b1f2f7a9e10",
+			},
+		},
+		&wafv1alpha1.RuleSource{
+			ObjectMeta: metav1.ObjectMeta{Name: "base", Namespace: "team-a"},
+			Spec:       wafv1alpha1.RuleSourceSpec{Rules: "SecRuleEngine On"},
+		},
+		&wafv1alpha1.RuleSource{
+			ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "team-b"},
+			Spec:       wafv1alpha1.RuleSourceSpec{Rules: "SecRuleEngine On"},
+		},
+		&wafv1alpha1.ThreatFeed{
+			ObjectMeta: metav1.ObjectMeta{Name: "feed", Namespace: "team-a", UID: "feed-uid"},
+			Spec:       wafv1alpha1.ThreatFeedSpec{URL: "https://example.com/feed.txt"},
+		},
+		&wafv1alpha1.RuleData{
+			ObjectMeta: metav1.ObjectMeta{Name: "threatfeed-feed", Namespace: "team-a", OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: wafv1alpha1.GroupVersion.String(), Kind: "ThreatFeed", Name: "feed", UID: "feed-uid", Controller: new(true),
+			}}},
+		},
+		&wafv1alpha1.RuleData{
+			ObjectMeta: metav1.ObjectMeta{Name: "labelled", Namespace: "team-a", Labels: map[string]string{wafv1alpha1.LabelExcludeFromBackup: "true"}},
+		},
+		&wafv1alpha1.RuleData{
+			ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "team-a"},
+			Spec:       wafv1alpha1.RuleDataSpec{Files: map[string]string{"ips.txt": "192.0.2.1\n"}},
+		},
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objects...).Build()
+
+	exported, err := Export(t.Context(), c, Options{Namespace: "team-a"})
+	require.NoError(t, err)
+
+	var order []string
+	for _, obj := range exported {
+		order = append(order, obj.GetKind()+"/"+obj.GetName())
+		assert.Empty(t, obj.GetResourceVersion())
+		assert.Empty(t, obj.GetFinalizers())
+		assert.NotContains(t, obj.Object, "status")
+		assert.NotContains(t, obj.GetAnnotations(), corev1.LastAppliedConfigAnnotation)
+	}
+	assert.Equal(t, []string{
+		"RuleData/data",
+		"RuleSource/base",
+		"ThreatFeed/feed",
+		"RuleSetApproval/rules-approval",
+		"RuleSet/rules",
+		"Engine/b-older",
+		"Engine/a-newer",
+	}, order)
+	assert.Empty(t, exported[2].GetUID())
+	assert.Equal(t, "2026-01-01T02:00:00Z", exported[6].GetAnnotations()[wafv1alpha1.AnnotationCreatedAt])
+
+	var out bytes.Buffer
+	require.NoError(t, WriteManifests(&out, exported))
+	docs := strings.Split(out.String(), "---\n")
+	require.Len(t, docs, len(exported))
+	assert.Contains(t, docs[0], "apiVersion: waf.k8s.coraza.io/v1alpha1\nkind: RuleData\n")
+	assert.NotContains(t, out.String(), "creationTimestamp")
+
+	t.Run("all namespaces", func(t *testing.T) {
+		exported, err := Export(t.Context(), c, Options{})
+		require.NoError(t, err)
+		assert.Len(t, exported, 8)
+	})
+}
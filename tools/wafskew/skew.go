@@ -0,0 +1,191 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package wafskew reports which revisions of the rules, and which versions
+// of the OWASP CoreRuleSet, the gateways of each Engine enforce, compared to
+// the revision published for its RuleSet, to spot the gateways left behind
+// by a partial rollout.
+//
+// The report is read from the status the operator records: the
+// status.revision of the RuleSets, the status.dataPlane of the Engines, which
+// the gateways update with their heartbeats, and the spec.crsVersion of the
+// RuleSetSnapshots of both revisions.
+package wafskew
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+// -----------------------------------------------------------------------------
+// Report
+// -----------------------------------------------------------------------------
+
+// Options configures a report.
+type Options struct {
+	// Namespace restricts the report to a namespace. Empty reports every
+	// namespace.
+	Namespace string
+}
+
+// Row is the report of an Engine.
+type Row struct {
+	Namespace string
+	Engine    string
+	Gateway   string
+	RuleSet   string
+
+	// PublishedRevision is the revision of the rules published for the
+	// RuleSet, and PublishedCRSVersion the CRS version it includes.
+	PublishedRevision   string
+	PublishedCRSVersion string
+
+	// LiveRevision is the revision of the rules the gateways of the Engine
+	// last reported enforcing, and LiveCRSVersion the CRS version it
+	// includes.
+	LiveRevision   string
+	LiveCRSVersion string
+
+	// LastHeartbeatTime is when a gateway of the Engine last reported, or
+	// zero when none did.
+	LastHeartbeatTime time.Time
+
+	// Skewed is true when the gateways do not enforce the published
+	// revision.
+	Skewed bool
+}
+
+// Report lists the Engines, their RuleSets and the RuleSetSnapshots of their
+// revisions, and returns the report of each Engine, sorted by namespace and
+// name. CRS versions are empty when the snapshot of a revision was pruned.
+func Report(ctx context.Context, c client.Reader, opts Options) ([]Row, error) {
+	var listOpts []client.ListOption
+	if opts.Namespace != "" {
+		listOpts = append(listOpts, client.InNamespace(opts.Namespace))
+	}
+
+	var engines wafv1alpha1.EngineList
+	if err := c.List(ctx, &engines, listOpts...); err != nil {
+		return nil, fmt.Errorf("listing Engine resources: %w", err)
+	}
+	var rulesets wafv1alpha1.RuleSetList
+	if err := c.List(ctx, &rulesets, listOpts...); err != nil {
+		return nil, fmt.Errorf("listing RuleSet resources: %w", err)
+	}
+	var snapshots wafv1alpha1.RuleSetSnapshotList
+	if err := c.List(ctx, &snapshots, listOpts...); err != nil {
+		return nil, fmt.Errorf("listing RuleSetSnapshot resources: %w", err)
+	}
+
+	revisions := make(map[types.NamespacedName]*wafv1alpha1.RuleSetRevision, len(rulesets.Items))
+	for i := range rulesets.Items {
+		revisions[client.ObjectKeyFromObject(&rulesets.Items[i])] = rulesets.Items[i].Status.Revision
+	}
+	crsVersions := make(map[types.NamespacedName]string, len(snapshots.Items))
+	for i := range snapshots.Items {
+		crsVersions[client.ObjectKeyFromObject(&snapshots.Items[i])] = snapshots.Items[i].Spec.CRSVersion
+	}
+
+	rows := make([]Row, 0, len(engines.Items))
+	for _, engine := range engines.Items {
+		row := Row{
+			Namespace: engine.Namespace,
+			Engine:    engine.Name,
+			Gateway:   engine.Spec.Target.Name,
+			RuleSet:   engine.Spec.RuleSet.Name,
+		}
+		// An Engine loading its fallback RuleSet is compared to the
+		// revision of the fallback.
+		if engine.Status != nil && engine.Status.ActiveRuleSet != "" {
+			row.RuleSet = engine.Status.ActiveRuleSet
+		}
+		if rev := revisions[types.NamespacedName{Namespace: engine.Namespace, Name: row.RuleSet}]; rev != nil {
+			row.PublishedRevision = rev.UUID
+			row.PublishedCRSVersion = crsVersions[types.NamespacedName{Namespace: engine.Namespace, Name: rev.Snapshot}]
+		}
+		if engine.Status != nil && engine.Status.DataPlane != nil {
+			dataPlane := engine.Status.DataPlane
+			row.LiveRevision = dataPlane.Revision
+			row.LiveCRSVersion = crsVersions[types.NamespacedName{Namespace: engine.Namespace, Name: dataPlane.Snapshot}]
+			row.LastHeartbeatTime = dataPlane.LastHeartbeatTime.Time
+		}
+		row.Skewed = row.LiveRevision != row.PublishedRevision
+		rows = append(rows, row)
+	}
+	slices.SortFunc(rows, func(a, b Row) int {
+		if c := strings.Compare(a.Namespace, b.Namespace); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Engine, b.Engine)
+	})
+	return rows, nil
+}
+
+// Skewed returns the number of skewed rows.
+func Skewed(rows []Row) int {
+	n := 0
+	for _, row := range rows {
+		if row.Skewed {
+			n++
+		}
+	}
+	return n
+}
+
+// -----------------------------------------------------------------------------
+// Output
+// -----------------------------------------------------------------------------
+
+// WriteTable writes rows as a table, like kubectl get, with the skewed rows
+// marked in the SKEW column.
+func WriteTable(w io.Writer, rows []Row) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "NAMESPACE\tENGINE\tGATEWAY\tRULESET\tPUBLISHED\tPUBLISHED CRS\tLIVE\tLIVE CRS\tLAST HEARTBEAT\tSKEW")
+	for _, row := range rows {
+		heartbeat := ""
+		if !row.LastHeartbeatTime.IsZero() {
+			heartbeat = row.LastHeartbeatTime.UTC().Format(time.RFC3339)
+		}
+		skew := "-"
+		if row.Skewed {
+			skew = "SKEWED"
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			row.Namespace, row.Engine, orNone(row.Gateway), row.RuleSet,
+			orNone(row.PublishedRevision), orNone(row.PublishedCRSVersion),
+			orNone(row.LiveRevision), orNone(row.LiveCRSVersion),
+			orNone(heartbeat), skew)
+	}
+	return tw.Flush()
+}
+
+// orNone returns s, or <none> when it is empty, like kubectl get.
+func orNone(s string) string {
+	if s == "" {
+		return "<none>"
+	}
+	return s
+}
@@ -0,0 +1,108 @@
+/*
+Copyright Coraza Kubernetes Operator contributors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package wafskew
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	wafv1alpha1 "github.com/networking-incubator/coraza-kubernetes-operator/api/v1alpha1"
+)
+
+func TestReport(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, wafv1alpha1.AddToScheme(scheme))
+
+	const (
+		published = "0b6b8a61-3c3e-5b8e-9a53-3b1f2f7a9e10"
+		previous  = "7d1c2e44-9f0a-5c6b-8e21-4a5b6c7d8e9f"
+	)
+	heartbeat := metav1.NewTime(time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC))
+	engine := func(name, gateway string, dataPlane *wafv1alpha1.DataPlaneStatus) *wafv1alpha1.Engine {
+		return &wafv1alpha1.Engine{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "team-a"},
+			Spec: wafv1alpha1.EngineSpec{
+				RuleSet: wafv1alpha1.RuleSetReference{Name: "rules"},
+				Target:  wafv1alpha1.EngineTarget{Type: wafv1alpha1.EngineTargetTypeGateway, Name: gateway},
+			},
+			Status: &wafv1alpha1.EngineStatus{DataPlane: dataPlane},
+		}
+	}
+	snapshot := func(id, crsVersion string) *wafv1alpha1.RuleSetSnapshot {
+		return &wafv1alpha1.RuleSetSnapshot{
+			ObjectMeta: metav1.ObjectMeta{Name: "rules-" + id, Namespace: "team-a"},
+			Spec:       wafv1alpha1.RuleSetSnapshotSpec{Revision: id, CRSVersion: crsVersion},
+		}
+	}
+	objects := []runtime.Object{
+		engine("b-current", "gw-b", &wafv1alpha1.DataPlaneStatus{Revision: published, Snapshot: "rules-" + published, LastHeartbeatTime: heartbeat}),
+		engine("a-behind", "gw-a", &wafv1alpha1.DataPlaneStatus{Revision: previous, Snapshot: "rules-" + previous, LastHeartbeatTime: heartbeat}),
+		engine("c-silent", "gw-c", nil),
+		&wafv1alpha1.RuleSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "rules", Namespace: "team-a"},
+			Status: wafv1alpha1.RuleSetStatus{Revision: &wafv1alpha1.RuleSetRevision{
+				UUID: published, PublishTime: heartbeat, Snapshot: "rules-" + published,
+			}},
+		},
+		snapshot(published, "4.24.1"),
+		snapshot(previous, "4.23.0"),
+	}
+	c := fake.NewClientBuilder().WithScheme(scheme).WithRuntimeObjects(objects...).Build()
+
+	rows, err := Report(t.Context(), c, Options{Namespace: "team-a"})
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+
+	assert.True(t, heartbeat.Time.Equal(rows[0].LastHeartbeatTime))
+	rows[0].LastHeartbeatTime = heartbeat.Time
+	assert.Equal(t, Row{
+		Namespace: "team-a", Engine: "a-behind", Gateway: "gw-a", RuleSet: "rules",
+		PublishedRevision: published, PublishedCRSVersion: "4.24.1",
+		LiveRevision: previous, LiveCRSVersion: "4.23.0",
+		LastHeartbeatTime: heartbeat.Time, Skewed: true,
+	}, rows[0])
+	assert.Equal(t, "b-current", rows[1].Engine)
+	assert.False(t, rows[1].Skewed)
+	assert.Equal(t, "4.24.1", rows[1].LiveCRSVersion)
+	assert.Equal(t, "c-silent", rows[2].Engine)
+	assert.True(t, rows[2].Skewed, "an Engine whose gateways never reported is skewed")
+	assert.Equal(t, 2, Skewed(rows))
+
+	var out bytes.Buffer
+	require.NoError(t, WriteTable(&out, rows))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 4)
+	assert.True(t, strings.HasPrefix(lines[0], "NAMESPACE"))
+	assert.Contains(t, lines[1], "4.23.0")
+	assert.True(t, strings.HasSuffix(lines[1], "SKEWED"))
+	assert.True(t, strings.HasSuffix(lines[2], "-"))
+	assert.Contains(t, lines[3], "<none>")
+
+	t.Run("other namespace", func(t *testing.T) {
+		rows, err := Report(t.Context(), c, Options{Namespace: "team-b"})
+		require.NoError(t, err)
+		assert.Empty(t, rows)
+	})
+}